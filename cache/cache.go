@@ -6,6 +6,7 @@ import (
 	"sccsmsserver/cache/rediscache"
 	"sccsmsserver/pub"
 	"sccsmsserver/setting"
+	"time"
 )
 
 // Whether to use Redis cache
@@ -93,3 +94,54 @@ func DelOther(key string) (err error) {
 	err = localcache.Del(key)
 	return
 }
+
+// Get and delete Other cache in one step, concurrent callers can't both get the value
+func GetDelOther(key string) (exist int32, v []byte, err error) {
+	if redisEnabled {
+		return rediscache.GetDel(key)
+	}
+	return localcache.GetDel(key)
+}
+
+// Take a token from the rate limit bucket of the key
+func TakeToken(key string, rate float64, burst int32) (allowed bool, retryAfter time.Duration, err error) {
+	if redisEnabled {
+		return rediscache.TakeToken(key, rate, burst)
+	}
+	return localcache.TakeToken(key, rate, burst)
+}
+
+// Acquire the lock of the key, with Redis the lock is shared by all application servers.
+// The lock expires after ttl; ok is false when it isn't acquired within wait.
+func Lock(key string, ttl time.Duration, wait time.Duration) (token string, ok bool, err error) {
+	if redisEnabled {
+		return rediscache.Lock(key, ttl, wait)
+	}
+	return localcache.Lock(key, ttl, wait)
+}
+
+// Release the lock of the key acquired with the token
+func Unlock(key string, token string) (err error) {
+	if redisEnabled {
+		return rediscache.Unlock(key, token)
+	}
+	return localcache.Unlock(key, token)
+}
+
+// Publish a message to the subscribers of the channel.
+// With Redis the subscribers on all application servers receive it.
+func Publish(channel string, v []byte) (err error) {
+	if redisEnabled {
+		return rediscache.Publish(channel, v)
+	}
+	return localcache.Publish(channel, v)
+}
+
+// Subscribe to the messages of the channel
+func Subscribe(channel string, handle func(v []byte)) {
+	if redisEnabled {
+		rediscache.Subscribe(channel, handle)
+		return
+	}
+	localcache.Subscribe(channel, handle)
+}
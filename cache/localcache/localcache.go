@@ -3,6 +3,7 @@ package localcache
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/allegro/bigcache/v3"
@@ -10,6 +11,9 @@ import (
 )
 
 var localCache *bigcache.BigCache
+
+// Serializes GetDel, so a value is only taken once
+var getDelMutex sync.Mutex
 var ctx = context.Background()
 
 // Initialize local cache
@@ -62,3 +66,18 @@ func Del(key string) (err error) {
 	}
 	return
 }
+
+// Get and delete a value from the local cache in one step
+func GetDel(key string) (exist int32, v []byte, err error) {
+	getDelMutex.Lock()
+	defer getDelMutex.Unlock()
+	exist, v, err = Get(key)
+	if exist == 0 || err != nil {
+		return
+	}
+	err = Del(key)
+	if err != nil {
+		return 0, nil, err
+	}
+	return
+}
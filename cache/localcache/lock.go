@@ -0,0 +1,57 @@
+package localcache
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Interval between two attempts to acquire a lock
+const lockRetryInterval = 10 * time.Millisecond
+
+// Lock held on a key
+type heldLock struct {
+	token      string
+	expireTime time.Time
+}
+
+// Serializes the acquisition and release of the locks
+var lockMutex sync.Mutex
+
+// Locks held, by key
+var locks = make(map[string]heldLock)
+
+// Tokens of the acquired locks
+var lockSeq uint64
+
+// Acquire the lock of the key.
+// The lock expires after ttl, like the Redis lock.
+// ok is false when the lock isn't acquired within wait, token is needed to release the lock.
+func Lock(key string, ttl time.Duration, wait time.Duration) (token string, ok bool, err error) {
+	deadline := time.Now().Add(wait)
+	for {
+		lockMutex.Lock()
+		l, held := locks[key]
+		if !held || time.Now().After(l.expireTime) {
+			lockSeq++
+			token = strconv.FormatUint(lockSeq, 10)
+			locks[key] = heldLock{token: token, expireTime: time.Now().Add(ttl)}
+			ok = true
+		}
+		lockMutex.Unlock()
+		if ok || time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// Release the lock of the key acquired with the token
+func Unlock(key string, token string) (err error) {
+	lockMutex.Lock()
+	defer lockMutex.Unlock()
+	if l, held := locks[key]; held && l.token == token {
+		delete(locks, key)
+	}
+	return
+}
@@ -0,0 +1,26 @@
+package localcache
+
+import "sync"
+
+// Subscribers by channel, a single application server publishes to itself
+var subscribers = make(map[string][]func(v []byte))
+var subscribersMutex sync.RWMutex
+
+// Publish a message to the subscribers of the channel.
+// The subscribers are called in turn and must not block.
+func Publish(channel string, v []byte) (err error) {
+	subscribersMutex.RLock()
+	handlers := subscribers[channel]
+	subscribersMutex.RUnlock()
+	for _, handle := range handlers {
+		handle(v)
+	}
+	return
+}
+
+// Subscribe to the messages of the channel
+func Subscribe(channel string, handle func(v []byte)) {
+	subscribersMutex.Lock()
+	defer subscribersMutex.Unlock()
+	subscribers[channel] = append(subscribers[channel], handle)
+}
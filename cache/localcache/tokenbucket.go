@@ -0,0 +1,39 @@
+package localcache
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+)
+
+// Serializes the read and update of the token buckets
+var bucketMutex sync.Mutex
+
+// Take a token from the bucket of the key.
+// The bucket is refilled at rate tokens per second up to burst.
+// When the bucket is empty, retryAfter is the time until the next token.
+func TakeToken(key string, rate float64, burst int32) (allowed bool, retryAfter time.Duration, err error) {
+	bucketMutex.Lock()
+	defer bucketMutex.Unlock()
+	now := time.Now()
+	tokens := float64(burst)
+	// The bucket is stored as the tokens and the last update time in nanoseconds
+	v, err := localCache.Get(key)
+	if err == nil && len(v) == 16 {
+		last := time.Unix(0, int64(binary.BigEndian.Uint64(v[8:])))
+		tokens = math.Float64frombits(binary.BigEndian.Uint64(v[:8])) + now.Sub(last).Seconds()*rate
+		tokens = math.Min(tokens, float64(burst))
+	}
+	if tokens >= 1 {
+		tokens--
+		allowed = true
+	} else {
+		retryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	v = make([]byte, 16)
+	binary.BigEndian.PutUint64(v[:8], math.Float64bits(tokens))
+	binary.BigEndian.PutUint64(v[8:], uint64(now.UnixNano()))
+	err = Set(key, v)
+	return
+}
@@ -0,0 +1,58 @@
+package rediscache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Interval between two attempts to acquire a lock
+const lockRetryInterval = 10 * time.Millisecond
+
+// Release script, run atomically by Redis so that only the holder of the lock deletes it
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Acquire the lock of the key, shared by all application servers.
+// The lock expires after ttl, so a server that stops while holding it doesn't block the others.
+// ok is false when the lock isn't acquired within wait, token is needed to release the lock.
+func Lock(key string, ttl time.Duration, wait time.Duration) (token string, ok bool, err error) {
+	b := make([]byte, 16)
+	_, err = rand.Read(b)
+	if err != nil {
+		zap.L().Error("Lock rand.Read failed", zap.Error(err))
+		return
+	}
+	token = hex.EncodeToString(b)
+	deadline := time.Now().Add(wait)
+	for {
+		ok, err = rdb.SetNX(ctx, key, token, ttl).Result()
+		if err != nil {
+			msg := fmt.Sprintf("%s%s", key, " Lock redis rdb.SetNX failed: ")
+			zap.L().Error(msg, zap.Error(err))
+			return
+		}
+		if ok || time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// Release the lock of the key acquired with the token
+func Unlock(key string, token string) (err error) {
+	err = unlockScript.Run(ctx, rdb, []string{key}, token).Err()
+	if err != nil {
+		msg := fmt.Sprintf("%s%s", key, " Unlock redis unlockScript.Run failed: ")
+		zap.L().Error(msg, zap.Error(err))
+	}
+	return
+}
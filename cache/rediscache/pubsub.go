@@ -0,0 +1,28 @@
+package rediscache
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Publish a message to the subscribers of the channel on all application servers
+func Publish(channel string, v []byte) (err error) {
+	err = rdb.Publish(ctx, channel, v).Err()
+	if err != nil {
+		msg := fmt.Sprintf("%s%s", channel, " Publish redis rdb.Publish failed: ")
+		zap.L().Error(msg, zap.Error(err))
+	}
+	return
+}
+
+// Subscribe to the messages of the channel.
+// The subscription reconnects by itself when the connection to Redis is lost.
+func Subscribe(channel string, handle func(v []byte)) {
+	pubsub := rdb.Subscribe(ctx, channel)
+	go func() {
+		for msg := range pubsub.Channel() {
+			handle([]byte(msg.Payload))
+		}
+	}()
+}
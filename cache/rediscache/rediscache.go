@@ -77,3 +77,17 @@ func Del(key string) (err error) {
 	}
 	return
 }
+
+// Get and delete a value from the Redis cache in one step
+func GetDel(key string) (exist int32, v []byte, err error) {
+	p, err := rdb.GetDel(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, []byte{}, nil
+		}
+		msg := fmt.Sprintf("%s%s", key, " GetDel redis rdb.GetDel failed: ")
+		zap.L().Error(msg, zap.Error(err))
+		return
+	}
+	return 1, []byte(p), nil
+}
@@ -0,0 +1,51 @@
+package rediscache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Token bucket script, run atomically by Redis so that all application servers share the bucket.
+// The Redis server time is used, the clocks of the application servers may differ.
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+if tokens == nil then
+	tokens = burst
+else
+	tokens = math.min(burst, tokens + (now - tonumber(bucket[2])) / 1000000 * rate)
+end
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, wait}
+`)
+
+// Take a token from the bucket of the key.
+// The bucket is refilled at rate tokens per second up to burst.
+// When the bucket is empty, retryAfter is the time until the next token.
+func TakeToken(key string, rate float64, burst int32) (allowed bool, retryAfter time.Duration, err error) {
+	res, err := takeTokenScript.Run(ctx, rdb, []string{key}, rate, burst).Int64Slice()
+	if err != nil {
+		msg := fmt.Sprintf("%s%s", key, " TakeToken redis takeTokenScript.Run failed: ")
+		zap.L().Error(msg, zap.Error(err))
+		return
+	}
+	allowed = res[0] == 1
+	retryAfter = time.Duration(res[1]) * time.Microsecond
+	return
+}
@@ -0,0 +1,602 @@
+package pg
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/lib/pq"
+	"sccsmsserver/cache"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pub"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// API key status
+const (
+	APIKeyActive   int16 = 0
+	APIKeyDisabled int16 = 1
+)
+
+// API key format: "sk_" + prefix + "_" + secret.
+// The prefix identifies the key, only the hash of the whole key is stored.
+const (
+	apiKeyTag         = "sk_"
+	apiKeyPrefixBytes = 6
+	apiKeySecretBytes = 32
+)
+
+// API key of a service user.
+// A service user is a user that isn't an operator, it calls the API with its keys and doesn't log in.
+type APIKey struct {
+	ID           int32     `db:"id" json:"id"`
+	Name         string    `db:"name" json:"name" binding:"required"`
+	User         Person    `db:"userid" json:"user"`
+	Prefix       string    `db:"prefix" json:"prefix"`
+	Description  string    `db:"description" json:"description"`
+	Roles        []Role    `json:"roles"`
+	ExpireDate   time.Time `db:"expiretime" json:"expireDate"`
+	LastUsedDate time.Time `db:"lastusedtime" json:"lastUsedDate"`
+	LastUsedIP   string    `db:"lastusedip" json:"lastUsedIp"`
+	Status       int16     `db:"status" json:"status"`
+	// Plain key, only returned once after the key is created
+	Key        string    `json:"key,omitempty"`
+	CreateDate time.Time `db:"createtime" json:"createDate"`
+	Creator    Person    `db:"creatorid" json:"creator"`
+	ModifyDate time.Time `db:"modifytime" json:"modifyDate"`
+	Modifier   Person    `db:"modifierid" json:"modifier"`
+	Dr         int16     `db:"dr" json:"dr"`
+	Ts         time.Time `db:"ts" json:"ts"`
+}
+
+// Caller authenticated with an API key
+type APIKeyCaller struct {
+	KeyID    int32
+	UserID   int32
+	UserCode string
+}
+
+// Hash of the plain key
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Generate a new plain key and its prefix
+func generateAPIKey() (key string, prefix string, err error) {
+	b := make([]byte, apiKeyPrefixBytes+apiKeySecretBytes)
+	_, err = rand.Read(b)
+	if err != nil {
+		zap.L().Error("generateAPIKey rand.Read failed", zap.Error(err))
+		return
+	}
+	prefix = hex.EncodeToString(b[:apiKeyPrefixBytes])
+	key = apiKeyTag + prefix + "_" + base64.RawURLEncoding.EncodeToString(b[apiKeyPrefixBytes:])
+	return
+}
+
+// Get the prefix of the plain key
+func apiKeyPrefix(key string) (prefix string, ok bool) {
+	rest, found := strings.CutPrefix(key, apiKeyTag)
+	if !found {
+		return
+	}
+	prefix, _, ok = strings.Cut(rest, "_")
+	return prefix, ok && len(prefix) == apiKeyPrefixBytes*2
+}
+
+// Authenticate the caller with the plain key.
+// The key must be active and not expired, and its user must be a valid service user.
+func AuthenticateAPIKey(key string, clientIP string) (caller APIKeyCaller, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusAPIKeyInvalid
+	prefix, ok := apiKeyPrefix(key)
+	if !ok {
+		return
+	}
+	var keyHash string
+	var expireTime, lastUsedTime time.Time
+	var lastUsedIP string
+	sqlStr := `select k.id,k.userid,u.code,k.keyhash,k.expiretime,k.lastusedtime,k.lastusedip
+	from sysapikey as k
+	left join sysuser as u on k.userid = u.id
+	where k.prefix=$1 and k.status=$2 and k.dr=0
+	and u.dr=0 and u.isoperator=0 and u.locked=0 and u.status=0`
+	err = db.QueryRow(sqlStr, prefix, APIKeyActive).Scan(&caller.KeyID, &caller.UserID, &caller.UserCode,
+		&keyHash, &expireTime, &lastUsedTime, &lastUsedIP)
+	if err == sql.ErrNoRows {
+		err = nil
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("AuthenticateAPIKey db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(key)), []byte(keyHash)) != 1 {
+		zap.L().Info("AuthenticateAPIKey key mismatch", zap.String("prefix", prefix), zap.String("clientIP", clientIP))
+		return
+	}
+	if !time.Now().Before(expireTime) {
+		return
+	}
+	resStatus = i18n.StatusOK
+	// Record the key usage, at most once per touch interval
+	if time.Since(lastUsedTime) < pub.SessionTouchInterval && lastUsedIP == clientIP {
+		return
+	}
+	_, err = db.Exec("update sysapikey set lastusedtime=current_timestamp,lastusedip=$1 where id=$2", clientIP, caller.KeyID)
+	if err != nil {
+		zap.L().Error("AuthenticateAPIKey db.Exec failed", zap.Error(err))
+		err = nil
+	}
+	return
+}
+
+// Get the permissions granted through the roles of the API key, from the cache when possible
+func GetAPIKeyPermissions(keyID int32) (up UserPermissions, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	// Get from cache
+	number, b, _ := cache.Get(pub.APIKeyPerm, keyID)
+	if number > 0 {
+		err = json.Unmarshal(b, &up)
+		if err == nil {
+			return
+		}
+		zap.L().Error("GetAPIKeyPermissions json.Unmarshal failed", zap.Error(err))
+	}
+	// Get from database
+	err = db.QueryRow("select userid from sysapikey where id=$1", keyID).Scan(&up.UserID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetAPIKeyPermissions db.QueryRow failed", zap.Error(err))
+		return
+	}
+	up.Menus = make(map[int32][]string)
+	var adminNumber int32
+	err = db.QueryRow("select count(id) from sysapikeyrole where keyid=$1 and roleid=$2 and dr=0", keyID, systemAdminRoleID).Scan(&adminNumber)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetAPIKeyPermissions db.QueryRow admin failed", zap.Error(err))
+		return
+	}
+	up.IsAdmin = adminNumber > 0
+	up.DataScope, err = widestRoleDataScope("select roleid from sysapikeyrole where keyid=$1 and dr=0", keyID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	sqlStr := `select rm.menuid,coalesce(rm.actions,'')
+	from sysrolemenu as rm
+	left join sysrole as r on rm.roleid = r.id
+	where rm.dr=0 and r.dr=0
+	and rm.roleid in (select roleid from sysapikeyrole where keyid=$1 and dr=0)`
+	rows, err := db.Query(sqlStr, keyID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetAPIKeyPermissions db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var menuID int32
+		var actions string
+		err = rows.Scan(&menuID, &actions)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("GetAPIKeyPermissions rows.Scan failed", zap.Error(err))
+			return
+		}
+		up.Menus[menuID] = mergeActions(up.Menus[menuID], actions)
+	}
+	// Write into cache
+	upB, _ := json.Marshal(up)
+	_ = cache.Set(pub.APIKeyPerm, keyID, upB)
+	return
+}
+
+// Get the data scope of the API key
+func GetAPIKeyDataScope(keyID int32) (ds DataScope, resStatus i18n.ResKey, err error) {
+	up, resStatus, err := GetAPIKeyPermissions(keyID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return dataScopeOf(up)
+}
+
+// Delete the cached permissions of the API keys
+func clearAPIKeyPermissions(keyIDs ...int32) {
+	for _, id := range keyIDs {
+		_ = cache.Del(pub.APIKeyPerm, id)
+	}
+}
+
+// Delete the cached permissions of all API keys with the role
+func clearRoleAPIKeyPermissions(roleID int32) {
+	rows, err := db.Query("select keyid from sysapikeyrole where roleid=$1 and dr=0", roleID)
+	if err != nil {
+		zap.L().Error("clearRoleAPIKeyPermissions db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var keyID int32
+		if err = rows.Scan(&keyID); err != nil {
+			zap.L().Error("clearRoleAPIKeyPermissions rows.Scan failed", zap.Error(err))
+			return
+		}
+		clearAPIKeyPermissions(keyID)
+	}
+}
+
+// Get API key list
+func GetAPIKeys() (aks []APIKey, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	aks = make([]APIKey, 0)
+	sqlStr := `select id,name,userid,prefix,description,expiretime,lastusedtime,lastusedip,status,
+	createtime,creatorid,modifytime,modifierid,dr,ts
+	from sysapikey
+	where dr=0 order by id`
+	rows, err := db.Query(sqlStr)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetAPIKeys db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ak APIKey
+		err = rows.Scan(&ak.ID, &ak.Name, &ak.User.ID, &ak.Prefix, &ak.Description, &ak.ExpireDate,
+			&ak.LastUsedDate, &ak.LastUsedIP, &ak.Status,
+			&ak.CreateDate, &ak.Creator.ID, &ak.ModifyDate, &ak.Modifier.ID, &ak.Dr, &ak.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("GetAPIKeys rows.Scan failed", zap.Error(err))
+			return
+		}
+		aks = append(aks, ak)
+	}
+	for i := range aks {
+		resStatus, err = aks[i].fillDetail()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Get the user, roles, creator and modifier of the API key
+func (ak *APIKey) fillDetail() (resStatus i18n.ResKey, err error) {
+	resStatus, err = ak.User.GetPersonInfoByID()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	resStatus, err = ak.getRoles()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	if ak.Creator.ID > 0 {
+		resStatus, err = ak.Creator.GetPersonInfoByID()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	if ak.Modifier.ID > 0 {
+		resStatus, err = ak.Modifier.GetPersonInfoByID()
+	}
+	return
+}
+
+// Get the roles of the API key
+func (ak *APIKey) getRoles() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	ak.Roles = make([]Role, 0)
+	sqlStr := `select id,name,description,systemflag,alluserflag
+	from sysrole
+	where id in (select roleid from sysapikeyrole where keyid=$1 and dr=0)`
+	rows, err := db.Query(sqlStr, ak.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("APIKey.getRoles db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var role Role
+		err = rows.Scan(&role.ID, &role.Name, &role.Description, &role.SystemFlag, &role.AllUserFlag)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("APIKey.getRoles rows.Scan failed", zap.Error(err))
+			return
+		}
+		ak.Roles = append(ak.Roles, role)
+	}
+	return
+}
+
+// Check the API key before it is written
+func (ak *APIKey) check() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	if !ak.ExpireDate.After(time.Now()) {
+		resStatus = i18n.StatusAPIKeyExpiryInvalid
+		return
+	}
+	if ak.Status != APIKeyActive && ak.Status != APIKeyDisabled {
+		resStatus = i18n.CodeInvalidParm
+		return
+	}
+	var isOperator int16
+	err = db.QueryRow("select isoperator from sysuser where id=$1 and dr=0", ak.User.ID).Scan(&isOperator)
+	if err == sql.ErrNoRows {
+		resStatus = i18n.StatusUserNotExist
+		err = nil
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("APIKey.check db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if isOperator != 0 {
+		resStatus = i18n.StatusAPIKeyNotServiceUser
+	}
+	return
+}
+
+// Check that the operator may assign the roles of the API key.
+// Administrators assign any role, other operators only the roles they hold themselves,
+// through their user or through the API key they call with. Roles the key already has are kept.
+func (ak *APIKey) checkRoles(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	roleIDsSql, ownerID := "select roleid from sysuserrole where userid=$1", actor.UserID
+	if actor.APIKeyID > 0 {
+		roleIDsSql, ownerID = "select roleid from sysapikeyrole where keyid=$1 and dr=0", actor.APIKeyID
+	}
+	roleIDs := make([]int32, 0, len(ak.Roles))
+	for _, role := range ak.Roles {
+		roleIDs = append(roleIDs, role.ID)
+	}
+	sqlStr := `select count(id) from unnest($2::int[]) as id
+	where id not in (` + roleIDsSql + `)
+	and id not in (select roleid from sysapikeyrole where keyid=$3 and dr=0)
+	and $4 not in (` + roleIDsSql + `)`
+	var notHeld int32
+	err = db.QueryRow(sqlStr, ownerID, pq.Array(roleIDs), ak.ID, systemAdminRoleID).Scan(&notHeld)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("APIKey.checkRoles db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if notHeld > 0 {
+		resStatus = i18n.StatusAPIKeyRoleNotHeld
+	}
+	return
+}
+
+// Write the roles of the API key
+func (ak *APIKey) writeRoles(tx *sql.Tx, operatorID int32) (err error) {
+	_, err = tx.Exec("update sysapikeyrole set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp where keyid=$2 and dr=0",
+		operatorID, ak.ID)
+	if err != nil {
+		zap.L().Error("APIKey.writeRoles tx.Exec failed", zap.Error(err))
+		return
+	}
+	for _, role := range ak.Roles {
+		_, err = tx.Exec("insert into sysapikeyrole(keyid,roleid,creatorid) values($1,$2,$3)", ak.ID, role.ID, operatorID)
+		if err != nil {
+			zap.L().Error("APIKey.writeRoles tx.Exec insert failed", zap.Error(err))
+			return
+		}
+	}
+	return
+}
+
+// Add API key.
+// The plain key is returned once in Key, it can't be read again.
+func (ak *APIKey) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = ak.check()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	resStatus, err = ak.checkRoles(actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("APIKey.Add db.Begin failed", zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	// Start the audit trail
+	at, err := beginAudit(tx, actor, pub.APIKey, AuditActionAdd)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	sqlStr := `insert into sysapikey(name,userid,prefix,keyhash,description,expiretime,status,creatorid)
+	values($1,$2,$3,$4,$5,$6,$7,$8)
+	returning id,createtime,ts`
+	err = tx.QueryRow(sqlStr, ak.Name, ak.User.ID, prefix, hashAPIKey(key), ak.Description, ak.ExpireDate,
+		ak.Status, ak.Creator.ID).Scan(&ak.ID, &ak.CreateDate, &ak.Ts)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("APIKey.Add tx.QueryRow failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	err = ak.writeRoles(tx, ak.Creator.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	ak.Prefix = prefix
+	ak.Key = key
+	zap.L().Info("API key created", zap.Int32("keyID", ak.ID), zap.Int32("userID", ak.User.ID), zap.Int32("operatorID", ak.Creator.ID))
+	// Write the audit trail
+	err = at.write(ak.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
+	return
+}
+
+// Modify API key.
+// The user and the key itself can't be changed, a new key has to be created instead.
+func (ak *APIKey) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	var userID int32
+	err = db.QueryRow("select userid from sysapikey where id=$1 and dr=0", ak.ID).Scan(&userID)
+	if err == sql.ErrNoRows {
+		resStatus = i18n.StatusOtherEdit
+		err = nil
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("APIKey.Edit db.QueryRow failed", zap.Error(err))
+		return
+	}
+	ak.User.ID = userID
+	resStatus, err = ak.check()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	resStatus, err = ak.checkRoles(actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("APIKey.Edit db.Begin failed", zap.Error(err))
+		return
+	}
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.APIKey, AuditActionEdit, ak.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	sqlStr := `update sysapikey set name=$1,description=$2,expiretime=$3,status=$4,modifierid=$5,
+	modifytime=current_timestamp,ts=current_timestamp
+	where id=$6 and ts=$7 and dr=0`
+	res, err := tx.Exec(sqlStr, ak.Name, ak.Description, ak.ExpireDate, ak.Status, ak.Modifier.ID, ak.ID, ak.Ts)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("APIKey.Edit tx.Exec failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("APIKey.Edit res.RowsAffected failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	// Someone else has already updated the data
+	if affected < 1 {
+		resStatus = i18n.StatusOtherEdit
+		tx.Rollback()
+		return
+	}
+	err = ak.writeRoles(tx, ak.Modifier.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	// Write the audit trail
+	err = at.write(ak.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("APIKey.Edit tx.Commit failed", zap.Error(err))
+		return
+	}
+	clearAPIKeyPermissions(ak.ID)
+	return
+}
+
+// Delete API key, the key stops working at once
+func (ak *APIKey) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("APIKey.Delete db.Begin failed", zap.Error(err))
+		return
+	}
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.APIKey, AuditActionDelete, ak.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	sqlStr := `update sysapikey set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp
+	where id=$2 and ts=$3 and dr=0`
+	res, err := tx.Exec(sqlStr, ak.Modifier.ID, ak.ID, ak.Ts)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("APIKey.Delete tx.Exec failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("APIKey.Delete res.RowsAffected failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	// Someone else has already updated the data
+	if affected < 1 {
+		resStatus = i18n.StatusOtherEdit
+		tx.Rollback()
+		return
+	}
+	ak.Roles = nil
+	err = ak.writeRoles(tx, ak.Modifier.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	// Write the audit trail
+	err = at.write(ak.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("APIKey.Delete tx.Commit failed", zap.Error(err))
+		return
+	}
+	clearAPIKeyPermissions(ak.ID)
+	zap.L().Info("API key deleted", zap.Int32("keyID", ak.ID), zap.Int32("operatorID", ak.Modifier.ID))
+	return
+}
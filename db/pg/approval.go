@@ -0,0 +1,1041 @@
+package pg
+
+import (
+	"database/sql"
+	"fmt"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pub"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Approver types of an approval step
+const (
+	ApproverRole     int16 = 1 // Users with the role
+	ApproverPosition int16 = 2 // Users in the position
+	ApproverDeptHead int16 = 3 // Leader of the voucher department
+)
+
+// Approval flow status
+const (
+	ApprovalFlowEnabled  int16 = 0
+	ApprovalFlowDisabled int16 = 1
+)
+
+// Approval status of a voucher
+const (
+	ApprovalPending  int16 = 0
+	ApprovalApproved int16 = 1
+	ApprovalRejected int16 = 2
+	ApprovalReturned int16 = 3
+	ApprovalCanceled int16 = 4 // The approved voucher was unconfirmed
+)
+
+// Actions recorded in the approval history
+const (
+	ApprovalActionSubmit    = "submit"
+	ApprovalActionApprove   = "approve"
+	ApprovalActionReject    = "reject"
+	ApprovalActionReturn    = "return"
+	ApprovalActionUnConfirm = "unconfirm"
+)
+
+// Voucher that goes through an approval flow before it is confirmed
+type approvalVoucher struct {
+	Table        string // Header table
+	MenuID       int32  // System menu of the voucher
+	DeptColumn   string // Department column, empty when the voucher has no department
+	NumberColumn string // Bill number column, empty when the voucher has no bill number
+	// Confirm the voucher in the approval transaction once its last approval step is approved,
+	// confirmed is run once the transaction is committed
+	Confirm func(tx *sql.Tx, voucherID int32, confirmer AuditActor) (confirmed func(), resStatus i18n.ResKey, err error)
+}
+
+// Vouchers with approval flows
+var approvalVouchers = map[pub.DataType]approvalVoucher{
+	pub.WO: {Table: "workorder_h", MenuID: MenuIDWO, DeptColumn: "deptid", NumberColumn: "billnumber",
+		Confirm: func(tx *sql.Tx, id int32, confirmer AuditActor) (func(), i18n.ResKey, error) {
+			wo := &WorkOrder{HID: id}
+			resStatus, err := wo.writeConfirmTx(tx, confirmer)
+			return func() { wo.confirmed(confirmer) }, resStatus, err
+		}},
+	pub.EO: {Table: "executionorder_h", MenuID: MenuIDEO, DeptColumn: "deptid", NumberColumn: "billnumber",
+		Confirm: func(tx *sql.Tx, id int32, confirmer AuditActor) (func(), i18n.ResKey, error) {
+			eo := &ExecutionOrder{HID: id}
+			resStatus, err := eo.writeConfirmTx(tx, confirmer)
+			return func() { eo.confirmed(confirmer) }, resStatus, err
+		}},
+	pub.IRF: {Table: "issueresolutionform", MenuID: MenuIDIRF, DeptColumn: "deptid", NumberColumn: "billnumber",
+		Confirm: confirmIRFByID},
+	pub.PPEIF: {Table: "ppeissuanceform_h", MenuID: MenuIDPPEIF, DeptColumn: "deptid", NumberColumn: "billnumber",
+		Confirm: func(tx *sql.Tx, id int32, confirmer AuditActor) (func(), i18n.ResKey, error) {
+			pif := &PPEIssuanceForm{HID: id}
+			resStatus, err := pif.writeConfirmTx(tx, confirmer)
+			return pif.confirmed, resStatus, err
+		}},
+	pub.PQ: {Table: "ppequotas_h", MenuID: MenuIDPQ,
+		Confirm: func(tx *sql.Tx, id int32, confirmer AuditActor) (func(), i18n.ResKey, error) {
+			resStatus, err := (&PPEQuota{HID: id}).confirmTx(tx, confirmer)
+			return func() {}, resStatus, err
+		}},
+	pub.TR: {Table: "trainingrecord_h", MenuID: MenuIDTR, DeptColumn: "deptid", NumberColumn: "billnumber",
+		Confirm: func(tx *sql.Tx, id int32, confirmer AuditActor) (func(), i18n.ResKey, error) {
+			resStatus, err := (&TrainingRecord{HID: id}).confirmTx(tx, confirmer)
+			return func() {}, resStatus, err
+		}},
+}
+
+// Steps of the current stage the operator $1 can approve, s is the step and i the voucher approval
+const approvalCandidateSql = `((s.approvertype=1 and s.approverid in (select roleid from sysuserrole where userid=$1 and dr=0))
+	or (s.approvertype=2 and s.approverid=(select positionid from sysuser where id=$1 and dr=0))
+	or (s.approvertype=3 and i.deptid>0 and $1=(select leader from department where id=i.deptid and dr=0)))
+	and not exists(select 1 from sysapprovalrecord as r
+	where r.instanceid=i.id and r.stepid=s.id and r.round=i.round and r.action='approve')`
+
+// Approval flow of a voucher type.
+// The flow of the voucher department takes precedence over the flow for all departments.
+type ApprovalFlow struct {
+	ID          int32          `db:"id" json:"id"`
+	VoucherType pub.DataType   `db:"vouchertype" json:"voucherType" binding:"required"`
+	Department  SimpDept       `db:"deptid" json:"department"`
+	Name        string         `db:"name" json:"name" binding:"required"`
+	Description string         `db:"description" json:"description"`
+	Status      int16          `db:"status" json:"status"`
+	Steps       []ApprovalStep `json:"steps"`
+	CreateDate  time.Time      `db:"createtime" json:"createDate"`
+	Creator     Person         `db:"creatorid" json:"creator"`
+	ModifyDate  time.Time      `db:"modifytime" json:"modifyDate"`
+	Modifier    Person         `db:"modifierid" json:"modifier"`
+	Dr          int16          `db:"dr" json:"dr"`
+	Ts          time.Time      `db:"ts" json:"ts"`
+}
+
+// Approval flow step.
+// Steps are approved in ascending step number. Steps with the same number are approved in parallel,
+// all of them must be approved, each by a different approver, before the flow moves on.
+type ApprovalStep struct {
+	ID           int32  `db:"id" json:"id"`
+	StepNumber   int32  `db:"stepnumber" json:"stepNumber"`
+	Name         string `db:"name" json:"name"`
+	ApproverType int16  `db:"approvertype" json:"approverType"`
+	ApproverID   int32  `db:"approverid" json:"approverID"`
+	ApproverName string `json:"approverName"`
+}
+
+// Approval of a voucher
+type VoucherApproval struct {
+	ID          int32            `db:"id" json:"id"`
+	VoucherType pub.DataType     `db:"vouchertype" json:"voucherType"`
+	VoucherID   int32            `db:"voucherid" json:"voucherID"`
+	BillNumber  string           `json:"billNumber"`
+	Department  SimpDept         `db:"deptid" json:"department"`
+	FlowID      int32            `db:"flowid" json:"flowID"`
+	FlowName    string           `json:"flowName"`
+	CurrentStep int32            `db:"currentstep" json:"currentStep"`
+	Round       int32            `db:"round" json:"round"`
+	Status      int16            `db:"status" json:"status"`
+	Submitter   Person           `db:"submitterid" json:"submitter"`
+	SubmitDate  time.Time        `db:"submittime" json:"submitDate"`
+	FinishDate  time.Time        `db:"finishtime" json:"finishDate"`
+	Records     []ApprovalRecord `json:"records"`
+}
+
+// Approval history record
+type ApprovalRecord struct {
+	ID         int32     `db:"id" json:"id"`
+	StepNumber int32     `db:"stepnumber" json:"stepNumber"`
+	StepName   string    `json:"stepName"`
+	Round      int32     `db:"round" json:"round"`
+	Action     string    `db:"action" json:"action"`
+	Comment    string    `db:"comment" json:"comment"`
+	Operator   Person    `db:"operatorid" json:"operator"`
+	CreateDate time.Time `db:"createtime" json:"createDate"`
+}
+
+// Approval action params
+type ApprovalParams struct {
+	VoucherType pub.DataType `json:"voucherType" binding:"required"`
+	VoucherID   int32        `json:"voucherID" binding:"required"`
+	Comment     string       `json:"comment"`
+}
+
+// Get approval flow list
+func GetApprovalFlows() (afs []ApprovalFlow, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	afs = make([]ApprovalFlow, 0)
+	sqlStr := `select id,vouchertype,deptid,name,description,status,
+	createtime,creatorid,modifytime,modifierid,dr,ts
+	from sysapprovalflow
+	where dr=0 order by vouchertype,deptid,id`
+	rows, err := db.Query(sqlStr)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetApprovalFlows db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var af ApprovalFlow
+		err = rows.Scan(&af.ID, &af.VoucherType, &af.Department.ID, &af.Name, &af.Description, &af.Status,
+			&af.CreateDate, &af.Creator.ID, &af.ModifyDate, &af.Modifier.ID, &af.Dr, &af.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("GetApprovalFlows rows.Scan failed", zap.Error(err))
+			return
+		}
+		afs = append(afs, af)
+	}
+	for i := range afs {
+		resStatus, err = afs[i].fillDetail()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Get the department, steps, creator and modifier of the approval flow
+func (af *ApprovalFlow) fillDetail() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	if af.Department.ID > 0 {
+		resStatus, err = af.Department.GetSimpDeptInfoByID()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	resStatus, err = af.getSteps()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	if af.Creator.ID > 0 {
+		resStatus, err = af.Creator.GetPersonInfoByID()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	if af.Modifier.ID > 0 {
+		resStatus, err = af.Modifier.GetPersonInfoByID()
+	}
+	return
+}
+
+// Get the steps of the approval flow
+func (af *ApprovalFlow) getSteps() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	af.Steps = make([]ApprovalStep, 0)
+	sqlStr := `select s.id,s.stepnumber,s.name,s.approvertype,s.approverid,
+	case s.approvertype when 1 then coalesce(r.name,'') when 2 then coalesce(p.name,'') else '' end
+	from sysapprovalstep as s
+	left join sysrole as r on s.approvertype=1 and s.approverid=r.id
+	left join position as p on s.approvertype=2 and s.approverid=p.id
+	where s.flowid=$1 and s.dr=0
+	order by s.stepnumber,s.id`
+	rows, err := db.Query(sqlStr, af.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ApprovalFlow.getSteps db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var step ApprovalStep
+		err = rows.Scan(&step.ID, &step.StepNumber, &step.Name, &step.ApproverType, &step.ApproverID, &step.ApproverName)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("ApprovalFlow.getSteps rows.Scan failed", zap.Error(err))
+			return
+		}
+		af.Steps = append(af.Steps, step)
+	}
+	return
+}
+
+// Check the approval flow before it is written
+func (af *ApprovalFlow) check() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	av, ok := approvalVouchers[af.VoucherType]
+	if !ok || (af.Status != ApprovalFlowEnabled && af.Status != ApprovalFlowDisabled) {
+		resStatus = i18n.CodeInvalidParm
+		return
+	}
+	// Vouchers without a department only have flows for all departments
+	if len(af.Steps) == 0 || (af.Department.ID > 0 && av.DeptColumn == "") {
+		resStatus = i18n.StatusApprovalFlowInvalid
+		return
+	}
+	for _, step := range af.Steps {
+		if step.StepNumber < 1 {
+			resStatus = i18n.StatusApprovalFlowInvalid
+			return
+		}
+		var sqlStr string
+		switch step.ApproverType {
+		case ApproverRole:
+			sqlStr = "select count(id) from sysrole where id=$1 and dr=0"
+		case ApproverPosition:
+			sqlStr = "select count(id) from position where id=$1 and dr=0"
+		case ApproverDeptHead:
+			if av.DeptColumn == "" {
+				resStatus = i18n.StatusApprovalFlowInvalid
+				return
+			}
+			continue
+		default:
+			resStatus = i18n.StatusApprovalFlowInvalid
+			return
+		}
+		var number int32
+		err = db.QueryRow(sqlStr, step.ApproverID).Scan(&number)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("ApprovalFlow.check db.QueryRow approver failed", zap.Error(err))
+			return
+		}
+		if number == 0 {
+			resStatus = i18n.StatusApprovalFlowInvalid
+			return
+		}
+	}
+	// Only one flow is enabled for a voucher type and department
+	if af.Status != ApprovalFlowEnabled {
+		return
+	}
+	var flowNumber int32
+	sqlStr := `select count(id) from sysapprovalflow
+	where vouchertype=$1 and deptid=$2 and status=$3 and id<>$4 and dr=0`
+	err = db.QueryRow(sqlStr, af.VoucherType, af.Department.ID, ApprovalFlowEnabled, af.ID).Scan(&flowNumber)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ApprovalFlow.check db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if flowNumber > 0 {
+		resStatus = i18n.StatusApprovalFlowExist
+	}
+	return
+}
+
+// Check that no voucher is waiting for approval in the approval flow
+func (af *ApprovalFlow) checkInUse() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	var number int32
+	sqlStr := "select count(id) from sysapprovalinstance where flowid=$1 and status=$2 and dr=0"
+	err = db.QueryRow(sqlStr, af.ID, ApprovalPending).Scan(&number)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ApprovalFlow.checkInUse db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if number > 0 {
+		resStatus = i18n.StatusApprovalFlowInUse
+	}
+	return
+}
+
+// Write the steps of the approval flow
+func (af *ApprovalFlow) writeSteps(tx *sql.Tx, operatorID int32) (err error) {
+	_, err = tx.Exec("update sysapprovalstep set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp where flowid=$2 and dr=0",
+		operatorID, af.ID)
+	if err != nil {
+		zap.L().Error("ApprovalFlow.writeSteps tx.Exec failed", zap.Error(err))
+		return
+	}
+	sqlStr := `insert into sysapprovalstep(flowid,stepnumber,name,approvertype,approverid,creatorid)
+	values($1,$2,$3,$4,$5,$6) returning id`
+	for i := range af.Steps {
+		step := &af.Steps[i]
+		if step.ApproverType == ApproverDeptHead {
+			step.ApproverID = 0
+		}
+		err = tx.QueryRow(sqlStr, af.ID, step.StepNumber, step.Name, step.ApproverType, step.ApproverID, operatorID).Scan(&step.ID)
+		if err != nil {
+			zap.L().Error("ApprovalFlow.writeSteps tx.QueryRow insert failed", zap.Error(err))
+			return
+		}
+	}
+	return
+}
+
+// Add approval flow
+func (af *ApprovalFlow) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = af.check()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ApprovalFlow.Add db.Begin failed", zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	// Start the audit trail
+	at, err := beginAudit(tx, actor, pub.ApprovalFlow, AuditActionAdd)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	sqlStr := `insert into sysapprovalflow(vouchertype,deptid,name,description,status,creatorid)
+	values($1,$2,$3,$4,$5,$6)
+	returning id,createtime,ts`
+	err = tx.QueryRow(sqlStr, af.VoucherType, af.Department.ID, af.Name, af.Description, af.Status,
+		af.Creator.ID).Scan(&af.ID, &af.CreateDate, &af.Ts)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ApprovalFlow.Add tx.QueryRow failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	err = af.writeSteps(tx, af.Creator.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	// Write the audit trail
+	err = at.write(af.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
+	return
+}
+
+// Modify approval flow.
+// The flow can't be modified while vouchers are waiting for approval in it.
+func (af *ApprovalFlow) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = af.checkInUse()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	resStatus, err = af.check()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ApprovalFlow.Edit db.Begin failed", zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.ApprovalFlow, AuditActionEdit, af.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	sqlStr := `update sysapprovalflow set vouchertype=$1,deptid=$2,name=$3,description=$4,status=$5,modifierid=$6,
+	modifytime=current_timestamp,ts=current_timestamp
+	where id=$7 and ts=$8 and dr=0`
+	res, err := tx.Exec(sqlStr, af.VoucherType, af.Department.ID, af.Name, af.Description, af.Status,
+		af.Modifier.ID, af.ID, af.Ts)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ApprovalFlow.Edit tx.Exec failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ApprovalFlow.Edit res.RowsAffected failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	// Someone else has already updated the data
+	if affected < 1 {
+		resStatus = i18n.StatusOtherEdit
+		tx.Rollback()
+		return
+	}
+	err = af.writeSteps(tx, af.Modifier.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	// Write the audit trail
+	err = at.write(af.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
+	return
+}
+
+// Delete approval flow
+func (af *ApprovalFlow) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = af.checkInUse()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ApprovalFlow.Delete db.Begin failed", zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.ApprovalFlow, AuditActionDelete, af.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	sqlStr := `update sysapprovalflow set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp
+	where id=$2 and ts=$3 and dr=0`
+	res, err := tx.Exec(sqlStr, af.Modifier.ID, af.ID, af.Ts)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ApprovalFlow.Delete tx.Exec failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ApprovalFlow.Delete res.RowsAffected failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	// Someone else has already updated the data
+	if affected < 1 {
+		resStatus = i18n.StatusOtherEdit
+		tx.Rollback()
+		return
+	}
+	af.Steps = nil
+	err = af.writeSteps(tx, af.Modifier.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	// Write the audit trail
+	err = at.write(af.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
+	return
+}
+
+// Check that the voucher isn't waiting for approval.
+// A voucher waiting for approval can't be modified, deleted or confirmed.
+func checkApprovalPending(voucherType pub.DataType, voucherID int32) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	var number int32
+	sqlStr := "select count(id) from sysapprovalinstance where vouchertype=$1 and voucherid=$2 and status=$3 and dr=0"
+	err = db.QueryRow(sqlStr, voucherType, voucherID, ApprovalPending).Scan(&number)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("checkApprovalPending db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if number > 0 {
+		resStatus = i18n.StatusApprovalPending
+	}
+	return
+}
+
+// Find the enabled approval flow of the voucher and its first step number.
+// The flow of the voucher department takes precedence over the flow for all departments.
+func findApprovalFlow(voucherType pub.DataType, deptID int32) (flowID int32, firstStep int32, err error) {
+	sqlStr := `select f.id,
+	(select coalesce(min(stepnumber),0) from sysapprovalstep where flowid=f.id and dr=0)
+	from sysapprovalflow as f
+	where f.vouchertype=$1 and (f.deptid=$2 or f.deptid=0) and f.status=$3 and f.dr=0
+	order by f.deptid desc limit 1`
+	err = db.QueryRow(sqlStr, voucherType, deptID, ApprovalFlowEnabled).Scan(&flowID, &firstStep)
+	if err == sql.ErrNoRows {
+		err = nil
+		return
+	}
+	if err != nil {
+		zap.L().Error("findApprovalFlow db.QueryRow failed", zap.Error(err))
+	}
+	return
+}
+
+// Submit the voucher for approval when an approval flow applies to it.
+// Returns StatusOK when the voucher has no approval flow and can be confirmed at once,
+// otherwise the voucher is confirmed when the last step of the flow is approved.
+func submitForApproval(voucherType pub.DataType, voucherID int32, submitter AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = checkApprovalPending(voucherType, voucherID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	av := approvalVouchers[voucherType]
+	deptColumn := "0"
+	if av.DeptColumn != "" {
+		deptColumn = av.DeptColumn
+	}
+	var status int16
+	var deptID int32
+	err = db.QueryRow("select status,"+deptColumn+" from "+av.Table+" where id=$1 and dr=0", voucherID).Scan(&status, &deptID)
+	if err == sql.ErrNoRows {
+		resStatus = i18n.StatusDataDeleted
+		err = nil
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("submitForApproval db.QueryRow voucher failed", zap.Error(err))
+		return
+	}
+	// Confirm reports vouchers that aren't free
+	if status != 0 {
+		return
+	}
+	flowID, firstStep, err := findApprovalFlow(voucherType, deptID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	if flowID == 0 || firstStep == 0 {
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("submitForApproval db.Begin failed", zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	var instanceID int32
+	sqlStr := `insert into sysapprovalinstance(vouchertype,voucherid,deptid,flowid,currentstep,submitterid)
+	values($1,$2,$3,$4,$5,$6) returning id`
+	err = tx.QueryRow(sqlStr, voucherType, voucherID, deptID, flowID, firstStep, submitter.UserID).Scan(&instanceID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("submitForApproval tx.QueryRow failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	err = insertApprovalRecord(tx, instanceID, 0, 0, 1, ApprovalActionSubmit, "", submitter.UserID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	err = auditAction(tx, submitter, voucherType, ApprovalActionSubmit, voucherID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	resStatus = i18n.StatusApprovalSubmitted
+	return
+}
+
+// Write an approval history record
+func insertApprovalRecord(tx *sql.Tx, instanceID, stepID, stepNumber, round int32, action, comment string, operatorID int32) (err error) {
+	sqlStr := `insert into sysapprovalrecord(instanceid,stepid,stepnumber,round,action,comment,operatorid)
+	values($1,$2,$3,$4,$5,$6,$7)`
+	_, err = tx.Exec(sqlStr, instanceID, stepID, stepNumber, round, action, comment, operatorID)
+	if err != nil {
+		zap.L().Error("insertApprovalRecord tx.Exec failed", zap.Error(err))
+	}
+	return
+}
+
+// Approve a step of the current stage the operator is an approver of.
+// The submitter can't approve the voucher, and an operator approves one step per stage.
+// When the last stage is approved the voucher is confirmed by the operator.
+func (p *ApprovalParams) Approve(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	return p.act(ApprovalActionApprove, actor)
+}
+
+// Reject the voucher, the approval ends and the voucher can be modified again
+func (p *ApprovalParams) Reject(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	return p.act(ApprovalActionReject, actor)
+}
+
+// Return the voucher to the previous stage, or to the submitter from the first stage
+func (p *ApprovalParams) Return(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	return p.act(ApprovalActionReturn, actor)
+}
+
+// Perform an approval action on the pending approval of the voucher
+func (p *ApprovalParams) act(action string, actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	av, ok := approvalVouchers[p.VoucherType]
+	if !ok {
+		resStatus = i18n.CodeInvalidParm
+		return
+	}
+	p.Comment = strings.TrimSpace(p.Comment)
+	if action != ApprovalActionApprove && p.Comment == "" {
+		resStatus = i18n.StatusApprovalCommentRequired
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ApprovalParams.act db.Begin failed", zap.Error(err))
+		return
+	}
+	// Notification of the voucher confirmed by the approval
+	confirmed := func() {}
+	// Lock the pending approval
+	var instanceID, flowID, currentStep, round, submitterID int32
+	sqlStr := `select id,flowid,currentstep,round,submitterid from sysapprovalinstance
+	where vouchertype=$1 and voucherid=$2 and status=$3 and dr=0 for update`
+	err = tx.QueryRow(sqlStr, p.VoucherType, p.VoucherID, ApprovalPending).Scan(&instanceID, &flowID, &currentStep, &round, &submitterID)
+	if err == sql.ErrNoRows {
+		resStatus = i18n.StatusApprovalNotPending
+		err = nil
+		tx.Rollback()
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ApprovalParams.act tx.QueryRow instance failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	if action == ApprovalActionApprove {
+		// The submitter doesn't approve the own voucher
+		if submitterID == actor.UserID {
+			resStatus = i18n.StatusApprovalSubmitter
+			tx.Rollback()
+			return
+		}
+		// An operator holding several approver roles approves one step of a stage,
+		// the parallel steps are left to other approvers
+		var approved int32
+		sqlStr = `select count(id) from sysapprovalrecord
+		where instanceid=$1 and stepnumber=$2 and round=$3 and action='approve' and operatorid=$4`
+		err = tx.QueryRow(sqlStr, instanceID, currentStep, round, actor.UserID).Scan(&approved)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("ApprovalParams.act tx.QueryRow approved failed", zap.Error(err))
+			tx.Rollback()
+			return
+		}
+		if approved > 0 {
+			resStatus = i18n.StatusApprovalStageApproved
+			tx.Rollback()
+			return
+		}
+	}
+	// Steps of the current stage the operator is an approver of
+	sqlStr = `select s.id from sysapprovalstep as s, sysapprovalinstance as i
+	where i.id=$2 and s.flowid=i.flowid and s.stepnumber=i.currentstep and s.dr=0 and ` + approvalCandidateSql
+	rows, err := tx.Query(sqlStr, actor.UserID, instanceID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ApprovalParams.act tx.Query steps failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	stepIDs := make([]int32, 0)
+	for rows.Next() {
+		var stepID int32
+		err = rows.Scan(&stepID)
+		if err != nil {
+			rows.Close()
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("ApprovalParams.act rows.Scan failed", zap.Error(err))
+			tx.Rollback()
+			return
+		}
+		stepIDs = append(stepIDs, stepID)
+	}
+	rows.Close()
+	if len(stepIDs) == 0 {
+		resStatus = i18n.StatusApprovalNotApprover
+		tx.Rollback()
+		return
+	}
+
+	switch action {
+	case ApprovalActionApprove:
+		err = insertApprovalRecord(tx, instanceID, stepIDs[0], currentStep, round, action, p.Comment, actor.UserID)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			tx.Rollback()
+			return
+		}
+		// Parallel steps still waiting for approval
+		var waiting int32
+		sqlStr = `select count(s.id) from sysapprovalstep as s
+		where s.flowid=$1 and s.stepnumber=$2 and s.dr=0
+		and not exists(select 1 from sysapprovalrecord as r
+		where r.instanceid=$3 and r.stepid=s.id and r.round=$4 and r.action='approve')`
+		err = tx.QueryRow(sqlStr, flowID, currentStep, instanceID, round).Scan(&waiting)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("ApprovalParams.act tx.QueryRow waiting failed", zap.Error(err))
+			tx.Rollback()
+			return
+		}
+		if waiting > 0 {
+			break
+		}
+		var nextStep int32
+		sqlStr = "select coalesce(min(stepnumber),0) from sysapprovalstep where flowid=$1 and stepnumber>$2 and dr=0"
+		err = tx.QueryRow(sqlStr, flowID, currentStep).Scan(&nextStep)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("ApprovalParams.act tx.QueryRow next step failed", zap.Error(err))
+			tx.Rollback()
+			return
+		}
+		if nextStep > 0 {
+			_, err = tx.Exec("update sysapprovalinstance set currentstep=$1,ts=current_timestamp where id=$2", nextStep, instanceID)
+			break
+		}
+		// The last stage is approved, confirm the voucher
+		_, err = tx.Exec("update sysapprovalinstance set status=$1,finishtime=current_timestamp,ts=current_timestamp where id=$2",
+			ApprovalApproved, instanceID)
+		if err != nil {
+			break
+		}
+		confirmed, resStatus, err = av.Confirm(tx, p.VoucherID, actor)
+		if resStatus != i18n.StatusOK || err != nil {
+			tx.Rollback()
+			return
+		}
+	case ApprovalActionReject:
+		err = insertApprovalRecord(tx, instanceID, stepIDs[0], currentStep, round, action, p.Comment, actor.UserID)
+		if err != nil {
+			break
+		}
+		_, err = tx.Exec("update sysapprovalinstance set status=$1,finishtime=current_timestamp,ts=current_timestamp where id=$2",
+			ApprovalRejected, instanceID)
+	case ApprovalActionReturn:
+		err = insertApprovalRecord(tx, instanceID, stepIDs[0], currentStep, round, action, p.Comment, actor.UserID)
+		if err != nil {
+			break
+		}
+		var previousStep int32
+		sqlStr = "select coalesce(max(stepnumber),0) from sysapprovalstep where flowid=$1 and stepnumber<$2 and dr=0"
+		err = tx.QueryRow(sqlStr, flowID, currentStep).Scan(&previousStep)
+		if err != nil {
+			break
+		}
+		// The previous stage approves again in a new round
+		if previousStep > 0 {
+			_, err = tx.Exec("update sysapprovalinstance set currentstep=$1,round=round+1,ts=current_timestamp where id=$2",
+				previousStep, instanceID)
+			break
+		}
+		_, err = tx.Exec("update sysapprovalinstance set status=$1,finishtime=current_timestamp,ts=current_timestamp where id=$2",
+			ApprovalReturned, instanceID)
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ApprovalParams.act "+action+" failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	err = auditAction(tx, actor, p.VoucherType, action, p.VoucherID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	// The approval and the confirmation of the voucher are committed together
+	err = tx.Commit()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ApprovalParams.act tx.Commit failed", zap.Error(err))
+		return
+	}
+	confirmed()
+	return
+}
+
+// Cancel the approval of the unconfirmed voucher.
+// The voucher has to be approved again before it is confirmed.
+func cancelApproval(voucherType pub.DataType, voucherID int32, operatorID int32) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("cancelApproval db.Begin failed", zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	var instanceID, currentStep, round int32
+	sqlStr := `update sysapprovalinstance set status=$1,ts=current_timestamp
+	where vouchertype=$2 and voucherid=$3 and status=$4 and dr=0
+	returning id,currentstep,round`
+	err = tx.QueryRow(sqlStr, ApprovalCanceled, voucherType, voucherID, ApprovalApproved).Scan(&instanceID, &currentStep, &round)
+	// The voucher was confirmed without approval
+	if err == sql.ErrNoRows {
+		err = nil
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("cancelApproval tx.QueryRow failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	err = insertApprovalRecord(tx, instanceID, 0, currentStep, round, ApprovalActionUnConfirm, "", operatorID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
+	return
+}
+
+// Get the approvals of the voucher with their history, the latest first.
+// The user must be allowed to view the voucher, and the voucher must be in the data scope of the user.
+func GetVoucherApprovals(voucherType pub.DataType, voucherID int32, up UserPermissions, ds DataScope) (vas []VoucherApproval, resStatus i18n.ResKey, err error) {
+	vas = make([]VoucherApproval, 0)
+	av, ok := approvalVouchers[voucherType]
+	if !ok {
+		resStatus = i18n.CodeInvalidParm
+		return
+	}
+	if !up.Allowed(av.MenuID, ActionView) {
+		resStatus = i18n.StatusPermissionDenied
+		return
+	}
+	// Vouchers without a department aren't restricted by the data scope, like their lists
+	if av.DeptColumn != "" && ds.Scope != DataScopeAll {
+		var deptID, creatorID int32
+		sqlStr := fmt.Sprintf("select coalesce(%s,0),creatorid from %s where id=$1", av.DeptColumn, av.Table)
+		err = db.QueryRow(sqlStr, voucherID).Scan(&deptID, &creatorID)
+		if err == sql.ErrNoRows {
+			resStatus = i18n.StatusPermissionDenied
+			err = nil
+			return
+		}
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("GetVoucherApprovals db.QueryRow failed", zap.Error(err))
+			return
+		}
+		if !ds.Contains(deptID, creatorID) {
+			resStatus = i18n.StatusPermissionDenied
+			return
+		}
+	}
+	sqlStr := `select id,vouchertype,voucherid,deptid,flowid,currentstep,round,status,submitterid,submittime,finishtime
+	from sysapprovalinstance
+	where vouchertype=$1 and voucherid=$2 and dr=0
+	order by id desc`
+	return queryVoucherApprovals(sqlStr, voucherType, voucherID)
+}
+
+// Get the approvals waiting for the operator
+func GetPendingApprovals(operatorID int32) (vas []VoucherApproval, resStatus i18n.ResKey, err error) {
+	sqlStr := `select i.id,i.vouchertype,i.voucherid,i.deptid,i.flowid,i.currentstep,i.round,i.status,
+	i.submitterid,i.submittime,i.finishtime
+	from sysapprovalinstance as i
+	where i.status=$2 and i.dr=0
+	and exists(select 1 from sysapprovalstep as s
+	where s.flowid=i.flowid and s.stepnumber=i.currentstep and s.dr=0 and ` + approvalCandidateSql + `)
+	and i.submitterid<>$1
+	and not exists(select 1 from sysapprovalrecord as r
+	where r.instanceid=i.id and r.stepnumber=i.currentstep and r.round=i.round and r.action='approve' and r.operatorid=$1)
+	order by i.submittime`
+	return queryVoucherApprovals(sqlStr, operatorID, ApprovalPending)
+}
+
+// Get the voucher approvals selected by the query, with their details and history
+func queryVoucherApprovals(sqlStr string, args ...interface{}) (vas []VoucherApproval, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	vas = make([]VoucherApproval, 0)
+	rows, err := db.Query(sqlStr, args...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("queryVoucherApprovals db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var va VoucherApproval
+		err = rows.Scan(&va.ID, &va.VoucherType, &va.VoucherID, &va.Department.ID, &va.FlowID, &va.CurrentStep,
+			&va.Round, &va.Status, &va.Submitter.ID, &va.SubmitDate, &va.FinishDate)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("queryVoucherApprovals rows.Scan failed", zap.Error(err))
+			return
+		}
+		vas = append(vas, va)
+	}
+	for i := range vas {
+		resStatus, err = vas[i].fillDetail()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Get the bill number, department, flow name, submitter and history of the voucher approval
+func (va *VoucherApproval) fillDetail() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	if av := approvalVouchers[va.VoucherType]; av.NumberColumn != "" {
+		err = db.QueryRow("select "+av.NumberColumn+" from "+av.Table+" where id=$1", va.VoucherID).Scan(&va.BillNumber)
+		if err != nil && err != sql.ErrNoRows {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("VoucherApproval.fillDetail db.QueryRow bill number failed", zap.Error(err))
+			return
+		}
+	}
+	err = db.QueryRow("select name from sysapprovalflow where id=$1", va.FlowID).Scan(&va.FlowName)
+	if err != nil && err != sql.ErrNoRows {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("VoucherApproval.fillDetail db.QueryRow flow failed", zap.Error(err))
+		return
+	}
+	err = nil
+	if va.Department.ID > 0 {
+		resStatus, err = va.Department.GetSimpDeptInfoByID()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	resStatus, err = va.Submitter.GetPersonInfoByID()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return va.getRecords()
+}
+
+// Get the approval history records
+func (va *VoucherApproval) getRecords() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	va.Records = make([]ApprovalRecord, 0)
+	sqlStr := `select r.id,r.stepnumber,coalesce(s.name,''),r.round,r.action,r.comment,r.operatorid,r.createtime
+	from sysapprovalrecord as r
+	left join sysapprovalstep as s on r.stepid=s.id
+	where r.instanceid=$1
+	order by r.id`
+	rows, err := db.Query(sqlStr, va.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("VoucherApproval.getRecords db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ar ApprovalRecord
+		err = rows.Scan(&ar.ID, &ar.StepNumber, &ar.StepName, &ar.Round, &ar.Action, &ar.Comment, &ar.Operator.ID, &ar.CreateDate)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("VoucherApproval.getRecords rows.Scan failed", zap.Error(err))
+			return
+		}
+		va.Records = append(va.Records, ar)
+	}
+	for i := range va.Records {
+		resStatus, err = va.Records[i].Operator.GetPersonInfoByID()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	return
+}
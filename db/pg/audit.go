@@ -0,0 +1,464 @@
+package pg
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/filter"
+	"sccsmsserver/pub"
+	"sccsmsserver/setting"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Audited actions
+const (
+	AuditActionAdd       = "add"
+	AuditActionEdit      = "edit"
+	AuditActionDelete    = "delete"
+	AuditActionConfirm   = "confirm"
+	AuditActionUnConfirm = "unconfirm"
+)
+
+// Detail table of an audited entity
+type auditDetail struct {
+	Table     string // Table name
+	KeyColumn string // Column referencing the entity ID
+}
+
+// Tables an audited entity is stored in
+type auditEntity struct {
+	Table        string        // Header table, the entity ID is its id column
+	Details      []auditDetail // Body and attachment tables
+	NumberColumn string        // Bill number column, used to find vouchers generated in batches
+	Omit         []string      // Columns never written to the audit trail
+}
+
+// Audited entities
+var auditEntities = map[pub.DataType]auditEntity{
+	pub.User:       {Table: "sysuser", Details: []auditDetail{{"sysuserrole", "userid"}}, Omit: []string{"password", "totpsecret"}},
+	pub.Role:       {Table: "sysrole", Details: []auditDetail{{"sysrolemenu", "roleid"}}},
+	pub.Department: {Table: "department"},
+	pub.Position:   {Table: "position"},
+	pub.CSC:        {Table: "csc"},
+	pub.CSA:        {Table: "csa"},
+	pub.CSO:        {Table: "cso"},
+	pub.UDC:        {Table: "udc"},
+	pub.UDA:        {Table: "uda"},
+	pub.EPC:        {Table: "epc"},
+	pub.EPA:        {Table: "epa"},
+	pub.EPT:        {Table: "ept_h", Details: []auditDetail{{"ept_b", "hid"}}},
+	pub.RL:         {Table: "risklevel"},
+	pub.DC:         {Table: "dc"},
+	pub.Document:   {Table: "document", Details: []auditDetail{{"document_file", "billhid"}}},
+	pub.TC:         {Table: "tc", Details: []auditDetail{{"tc_file", "billhid"}}},
+	pub.PPE:        {Table: "ppe"},
+	pub.IPAccess:   {Table: "sysipaccess"},
+	pub.APIKey:     {Table: "sysapikey", Details: []auditDetail{{"sysapikeyrole", "keyid"}}, Omit: []string{"keyhash"}},
+	pub.WO:         {Table: "workorder_h", Details: []auditDetail{{"workorder_b", "hid"}}, NumberColumn: "billnumber"},
+	pub.EO: {Table: "executionorder_h", Details: []auditDetail{{"executionorder_b", "hid"}, {"executionorder_file", "billhid"}},
+		NumberColumn: "billnumber"},
+	pub.IRF: {Table: "issueresolutionform", Details: []auditDetail{{"issueresolutionform_file", "billhid"}},
+		NumberColumn: "billnumber"},
+	pub.TR: {Table: "trainingrecord_h", Details: []auditDetail{{"trainingrecord_b", "hid"}, {"trainingrecord_file", "billhid"}},
+		NumberColumn: "billnumber"},
+	pub.PQ: {Table: "ppequotas_h", Details: []auditDetail{{"ppequotas_b", "hid"}}, NumberColumn: "billnumber"},
+	pub.PPEIF: {Table: "ppeissuanceform_h", Details: []auditDetail{{"ppeissuanceform_b", "hid"}, {"ppeissuanceform_file", "billhid"}},
+		NumberColumn: "billnumber"},
+	pub.ApprovalFlow: {Table: "sysapprovalflow", Details: []auditDetail{{"sysapprovalstep", "flowid"}}},
+	pub.WOSchedule:   {Table: "woschedule"},
+	pub.Webhook:      {Table: "syswebhook", Omit: []string{"secret"}},
+}
+
+// Columns that change on every write and are left out of the change set
+var auditIgnoredColumns = map[string]bool{"ts": true}
+
+// Audit trail record
+type AuditLog struct {
+	ID         int64           `db:"id" json:"id"`
+	EntityType string          `db:"entitytype" json:"entityType"`
+	EntityID   int32           `db:"entityid" json:"entityID"`
+	Action     string          `db:"action" json:"action"`
+	Operator   Person          `db:"operatorid" json:"operator"`
+	ClientIP   string          `db:"clientip" json:"clientIp"`
+	ClientType string          `db:"clienttype" json:"clientType"`
+	Changes    json.RawMessage `db:"changes" json:"changes"`
+	CreateDate time.Time       `db:"createtime" json:"createDate"`
+}
+
+// Audit trail record pagination
+type AuditLogsPaging struct {
+	Logs    []AuditLog `json:"logs"`
+	Count   int32      `json:"count"`
+	Page    int32      `json:"page"`
+	PerPage int32      `json:"perPage"`
+}
+
+// Audit trail query fields
+var auditLogFields = filter.Fields{
+	"entityType": {Column: "a.entitytype", Type: filter.String},
+	"entityID":   {Column: "a.entityid", Type: filter.Number},
+	"action":     {Column: "a.action", Type: filter.String},
+	"operatorID": {Column: "a.operatorid", Type: filter.Number},
+	"clientIp":   {Column: "a.clientip", Type: filter.String},
+	"clientType": {Column: "a.clienttype", Type: filter.String},
+	"createDate": {Column: "a.createtime", Type: filter.Time},
+}
+
+// The user and client an audited action was performed by
+type AuditActor struct {
+	UserID     int32
+	ClientIP   string
+	ClientType string
+	// API key the request was authenticated with, 0 when the user signed in with a token
+	APIKeyID int32
+}
+
+// Client type of the actions the server performs itself, such as scheduled Work Orders
+const AuditClientSystem = "system"
+
+// Actor of an action the server performs itself on behalf of the user
+func systemActor(userID int32) AuditActor {
+	return AuditActor{UserID: userID, ClientType: AuditClientSystem}
+}
+
+// Entity state, the header row and the rows of each detail table
+type auditSnapshot struct {
+	Row     map[string]interface{}
+	Details map[string][]map[string]interface{}
+}
+
+// Audit trail of an action, written in the transaction of the action.
+// The state of the entities is taken before the action, and compared with their state after it.
+type AuditTrail struct {
+	tx         *sql.Tx
+	actor      AuditActor
+	entityType pub.DataType
+	action     string
+	before     map[int32]*auditSnapshot
+}
+
+// Field change
+type auditFieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// Detail row changes
+type auditRowChange struct {
+	ID     interface{}                 `json:"id"`
+	Fields map[string]auditFieldChange `json:"fields"`
+}
+
+// Detail table changes
+type auditDetailChanges struct {
+	Added   []map[string]interface{} `json:"added,omitempty"`
+	Removed []map[string]interface{} `json:"removed,omitempty"`
+	Changed []auditRowChange         `json:"changed,omitempty"`
+}
+
+// Change set written to the audit trail
+type auditChanges struct {
+	Fields  map[string]auditFieldChange   `json:"fields,omitempty"`
+	Details map[string]auditDetailChanges `json:"details,omitempty"`
+}
+
+// Read the current state of the entity in the transaction.
+// The header row is locked until the transaction ends.
+func takeAuditSnapshot(tx *sql.Tx, entityType pub.DataType, entityID int32) (s *auditSnapshot, err error) {
+	ae := auditEntities[entityType]
+	var rowJSON []byte
+	sqlStr := fmt.Sprintf("select row_to_json(t) from %s as t where t.id=$1 for update", ae.Table)
+	err = tx.QueryRow(sqlStr, entityID).Scan(&rowJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		zap.L().Error("takeAuditSnapshot tx.QueryRow failed", zap.String("table", ae.Table), zap.Error(err))
+		return
+	}
+	s = &auditSnapshot{Details: make(map[string][]map[string]interface{})}
+	err = json.Unmarshal(rowJSON, &s.Row)
+	if err != nil {
+		zap.L().Error("takeAuditSnapshot json.Unmarshal failed", zap.Error(err))
+		return
+	}
+	for _, column := range ae.Omit {
+		delete(s.Row, column)
+	}
+	for _, d := range ae.Details {
+		var detailJSON []byte
+		sqlStr = fmt.Sprintf(`select coalesce(json_agg(row_to_json(t) order by t.id),'[]')
+		from %s as t where t.%s=$1 and t.dr=0`, d.Table, d.KeyColumn)
+		err = tx.QueryRow(sqlStr, entityID).Scan(&detailJSON)
+		if err != nil {
+			zap.L().Error("takeAuditSnapshot tx.QueryRow detail failed", zap.String("table", d.Table), zap.Error(err))
+			return
+		}
+		rows := make([]map[string]interface{}, 0)
+		err = json.Unmarshal(detailJSON, &rows)
+		if err != nil {
+			zap.L().Error("takeAuditSnapshot json.Unmarshal detail failed", zap.Error(err))
+			return
+		}
+		s.Details[d.Table] = rows
+	}
+	return
+}
+
+// Compare two rows, columns present in only one row are compared with null
+func diffAuditRows(before map[string]interface{}, after map[string]interface{}) map[string]auditFieldChange {
+	changes := make(map[string]auditFieldChange)
+	for k, nv := range after {
+		if auditIgnoredColumns[k] {
+			continue
+		}
+		ov := before[k]
+		if !reflect.DeepEqual(ov, nv) {
+			changes[k] = auditFieldChange{Old: ov, New: nv}
+		}
+	}
+	for k, ov := range before {
+		if _, ok := after[k]; ok || auditIgnoredColumns[k] {
+			continue
+		}
+		changes[k] = auditFieldChange{Old: ov, New: nil}
+	}
+	return changes
+}
+
+// Compare two entity states, a nil state is an entity that doesn't exist
+func diffAuditSnapshots(before *auditSnapshot, after *auditSnapshot) (c auditChanges) {
+	if before == nil {
+		before = &auditSnapshot{}
+	}
+	if after == nil {
+		after = &auditSnapshot{}
+	}
+	if fields := diffAuditRows(before.Row, after.Row); len(fields) > 0 {
+		c.Fields = fields
+	}
+	tables := make(map[string]bool)
+	for t := range before.Details {
+		tables[t] = true
+	}
+	for t := range after.Details {
+		tables[t] = true
+	}
+	for t := range tables {
+		var dc auditDetailChanges
+		beforeRows := make(map[string]map[string]interface{})
+		for _, r := range before.Details[t] {
+			beforeRows[fmt.Sprint(r["id"])] = r
+		}
+		for _, r := range after.Details[t] {
+			id := fmt.Sprint(r["id"])
+			old, ok := beforeRows[id]
+			if !ok {
+				dc.Added = append(dc.Added, r)
+				continue
+			}
+			delete(beforeRows, id)
+			if fields := diffAuditRows(old, r); len(fields) > 0 {
+				dc.Changed = append(dc.Changed, auditRowChange{ID: r["id"], Fields: fields})
+			}
+		}
+		for _, r := range before.Details[t] {
+			if _, ok := beforeRows[fmt.Sprint(r["id"])]; ok {
+				dc.Removed = append(dc.Removed, r)
+			}
+		}
+		if len(dc.Added) > 0 || len(dc.Removed) > 0 || len(dc.Changed) > 0 {
+			if c.Details == nil {
+				c.Details = make(map[string]auditDetailChanges)
+			}
+			c.Details[t] = dc
+		}
+	}
+	return
+}
+
+// Start the audit trail of an action on the entities in the transaction of the action.
+// The entities are locked and their state is taken before the action,
+// nothing is taken before an add, the entities don't exist yet.
+func beginAudit(tx *sql.Tx, actor AuditActor, entityType pub.DataType, action string, entityIDs ...int32) (at *AuditTrail, err error) {
+	at = &AuditTrail{
+		tx:         tx,
+		actor:      actor,
+		entityType: entityType,
+		action:     action,
+		before:     make(map[int32]*auditSnapshot),
+	}
+	if action == AuditActionAdd {
+		return
+	}
+	for _, id := range entityIDs {
+		at.before[id], err = takeAuditSnapshot(tx, entityType, id)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Write the audit trail of the entities after the action,
+// and queue the webhook deliveries of voucher actions, in the transaction of the action.
+// The action has to be rolled back when it fails.
+func (at *AuditTrail) write(entityIDs ...int32) (err error) {
+	sqlStr := `insert into sysauditlog(entitytype,entityid,action,operatorid,clientip,clienttype,changes)
+	values($1,$2,$3,$4,$5,$6,$7)`
+	for _, id := range entityIDs {
+		if id <= 0 {
+			continue
+		}
+		var after *auditSnapshot
+		after, err = takeAuditSnapshot(at.tx, at.entityType, id)
+		if err != nil {
+			return
+		}
+		changes, _ := json.Marshal(diffAuditSnapshots(at.before[id], after))
+		_, err = at.tx.Exec(sqlStr, string(at.entityType), id, at.action, at.actor.UserID,
+			at.actor.ClientIP, at.actor.ClientType, string(changes))
+		if err != nil {
+			zap.L().Error("AuditTrail.write tx.Exec failed", zap.String("entityType", string(at.entityType)),
+				zap.Int32("entityID", id), zap.Error(err))
+			return
+		}
+		if after != nil {
+			err = queueWebhooks(at.tx, at.entityType, at.action, id, after)
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// Write the audit trail of an action that doesn't change the entity itself, such as an approval action
+func auditAction(tx *sql.Tx, actor AuditActor, entityType pub.DataType, action string, entityID int32) (err error) {
+	at, err := beginAudit(tx, actor, entityType, action, entityID)
+	if err != nil {
+		return
+	}
+	return at.write(entityID)
+}
+
+// Write an action on the entity and its audit trail in one transaction.
+// The ID is read after the write, an added entity is audited with its new ID.
+func writeAudited(actor AuditActor, entityType pub.DataType, action string, entityID *int32,
+	write func(tx *sql.Tx) (i18n.ResKey, error)) (resStatus i18n.ResKey, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("writeAudited db.Begin failed", zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	at, err := beginAudit(tx, actor, entityType, action, *entityID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	resStatus, err = write(tx)
+	if resStatus != i18n.StatusOK || err != nil {
+		tx.Rollback()
+		return
+	}
+	err = at.write(*entityID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
+	return
+}
+
+// Get the audit trail by pagination
+func GetAuditLogsPagination(con PagingQueryParams) (alp AuditLogsPaging, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	alp.Logs = make([]AuditLog, 0)
+	whereSql, args, resStatus := compileFilter(con.Filter, auditLogFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
+	var build strings.Builder
+	// Assemble the SQL for checking
+	build.WriteString("select count(a.id) as rownumber from sysauditlog as a where 1=1")
+	if whereSql != "" {
+		build.WriteString(" and (")
+		build.WriteString(whereSql)
+		build.WriteString(")")
+	}
+	err = db.QueryRow(build.String(), args...).Scan(&alp.Count)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetAuditLogsPagination db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if alp.Count == 0 {
+		resStatus = i18n.StatusResNoData
+		return
+	}
+	if alp.Count > setting.Conf.PqConfig.MaxRecord {
+		resStatus = i18n.StatusOverRecord
+		return
+	}
+	// Recalculate pagination
+	if con.PerPage <= 0 || con.PerPage > alp.Count {
+		con.Page = 0
+		con.PerPage = alp.Count
+	} else {
+		var totalPage = int32(math.Ceil(float64(alp.Count) / float64(con.PerPage)))
+		if (con.Page + 1) > totalPage {
+			con.Page = totalPage - 1
+		}
+	}
+	alp.Page = con.Page
+	alp.PerPage = con.PerPage
+	build.Reset()
+	// Assemble the SQL for data retrieve
+	build.WriteString(`select a.id,a.entitytype,a.entityid,a.action,a.operatorid,
+	a.clientip,a.clienttype,a.changes,a.createtime
+	from sysauditlog as a where 1=1`)
+	if whereSql != "" {
+		build.WriteString(" and (")
+		build.WriteString(whereSql)
+		build.WriteString(")")
+	}
+	limitIndex := len(args) + 1
+	build.WriteString(fmt.Sprintf(" order by a.id desc limit $%d offset $%d", limitIndex, limitIndex+1))
+	args = append(args, con.PerPage, con.Page*con.PerPage)
+	rows, err := db.Query(build.String(), args...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetAuditLogsPagination db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var al AuditLog
+		var changes []byte
+		err = rows.Scan(&al.ID, &al.EntityType, &al.EntityID, &al.Action, &al.Operator.ID,
+			&al.ClientIP, &al.ClientType, &changes, &al.CreateDate)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("GetAuditLogsPagination rows.Scan failed", zap.Error(err))
+			return
+		}
+		al.Changes = json.RawMessage(changes)
+		// Get operator detail
+		if al.Operator.ID > 0 {
+			resStatus, err = al.Operator.GetPersonInfoByID()
+			if resStatus != i18n.StatusOK || err != nil {
+				return
+			}
+		}
+		alp.Logs = append(alp.Logs, al)
+	}
+	return
+}
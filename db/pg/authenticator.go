@@ -0,0 +1,277 @@
+package pg
+
+import (
+	"database/sql"
+	"errors"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/ldapauth"
+	"sccsmsserver/pub"
+	"sccsmsserver/setting"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Source of the user credentials, stored in sysuser.authsource
+const (
+	AuthSourceLocal = "local"
+	AuthSourceLDAP  = "ldap"
+)
+
+// The system default user 'admin', recorded as creator of provisioned users
+const systemAdminUserID int32 = 10000
+
+// Login authenticator
+type Authenticator interface {
+	// Verify the login credentials.
+	// user holds the sysuser row of the login, its ID is 0 when the user doesn't exist yet.
+	// Authenticators that provision users create or update the row and set user.ID.
+	Authenticate(p *ParamLogin, user *User) (resStatus i18n.ResKey, err error)
+	// Whether users that don't exist yet can be authenticated and created
+	Provisions() bool
+}
+
+var (
+	// Registered authenticators by source
+	authenticators = map[string]Authenticator{AuthSourceLocal: localAuthenticator{}}
+	// Sources tried in turn for users that don't exist yet
+	provisioningSources = make([]string, 0)
+)
+
+// Register an authenticator for the source
+func RegisterAuthenticator(source string, a Authenticator) {
+	authenticators[source] = a
+	if a.Provisions() {
+		provisioningSources = append(provisioningSources, source)
+	}
+}
+
+// Register the authenticators enabled in the configuration
+func InitAuthenticators(ldapCfg *setting.LDAPConfig) (err error) {
+	if ldapCfg != nil && ldapCfg.Enabled {
+		if ldapCfg.URL == "" || ldapCfg.BaseDN == "" {
+			return errors.New("ldap: url and basedn are required")
+		}
+		RegisterAuthenticator(AuthSourceLDAP, &ldapAuthenticator{cfg: ldapCfg})
+		zap.L().Info("LDAP authenticator enabled.", zap.String("url", ldapCfg.URL))
+	}
+	return
+}
+
+// Authenticate with the password stored in sysuser
+type localAuthenticator struct{}
+
+func (localAuthenticator) Provisions() bool {
+	return false
+}
+
+func (localAuthenticator) Authenticate(p *ParamLogin, user *User) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	// Check if the password matchs.
+	result, needsUpgrade := checkPassword(p.Password, user.Password)
+	if !result {
+		resStatus = i18n.StatusInvalidPassword
+		return
+	}
+	// Replace a legacy password hash with the current algorithm
+	if needsUpgrade {
+		upgradePassword(user.ID, p.Password)
+	}
+	return
+}
+
+// Authenticate against an LDAP / Active Directory server
+type ldapAuthenticator struct {
+	cfg *setting.LDAPConfig
+}
+
+func (*ldapAuthenticator) Provisions() bool {
+	return true
+}
+
+func (la *ldapAuthenticator) Authenticate(p *ParamLogin, user *User) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	entry, err := ldapauth.Authenticate(la.cfg, p.UserCode, p.Password)
+	if err == ldapauth.ErrUserNotFound {
+		resStatus = i18n.StatusUserNotExist
+		err = nil
+		return
+	}
+	if err == ldapauth.ErrInvalidCredentials {
+		resStatus = i18n.StatusInvalidPassword
+		err = nil
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusAuthSourceUnavailable
+		zap.L().Error("ldapAuthenticator.Authenticate failed", zap.Error(err))
+		return
+	}
+	return provisionLDAPUser(la.cfg, entry, p.UserCode, user)
+}
+
+// Create or update the user from the directory entry
+func provisionLDAPUser(cfg *setting.LDAPConfig, entry *ldapauth.Entry, userCode string, user *User) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	name := entry.Name
+	if name == "" {
+		name = userCode
+	}
+	// A person without login permission may use the code already
+	if user.ID == 0 {
+		resStatus, err = user.CheckUserCodeExist()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	deptID, err := findDeptID(entry.Dept)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("provisionLDAPUser db.Begin failed", zap.Error(err))
+		return
+	}
+	// The directory changes are audited as made by the server
+	action := AuditActionAdd
+	ids := make([]int32, 0, 1)
+	if user.ID > 0 {
+		action = AuditActionEdit
+		ids = append(ids, user.ID)
+	}
+	at, err := beginAudit(tx, systemActor(systemAdminUserID), pub.User, action, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	if user.ID == 0 {
+		// The password is never used, the directory verifies it
+		var password string
+		password, err = genRefreshToken()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			tx.Rollback()
+			return
+		}
+		password, err = encryptPassword(password)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			tx.Rollback()
+			return
+		}
+		sqlStr := `insert into sysuser(code,name,password,mobile,email,deptid,authsource,creatorid)
+		values($1,$2,$3,$4,$5,$6,$7,$8) returning id`
+		err = tx.QueryRow(sqlStr, userCode, name, password, entry.Mobile, entry.Email,
+			deptID, AuthSourceLDAP, systemAdminUserID).Scan(&user.ID)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("provisionLDAPUser insert sysuser failed", zap.Error(err))
+			tx.Rollback()
+			return
+		}
+		user.Code = userCode
+		// Assign the default roles
+		for _, roleName := range cfg.DefaultRoles {
+			_, err = tx.Exec(`insert into sysuserrole(userid,roleid,creatorid)
+			select $1,id,$2 from sysrole where name=$3 and dr=0`, user.ID, systemAdminUserID, roleName)
+			if err != nil {
+				resStatus = i18n.StatusInternalError
+				zap.L().Error("provisionLDAPUser insert default role failed", zap.Error(err))
+				tx.Rollback()
+				return
+			}
+		}
+		zap.L().Info("User created from LDAP", zap.String("userCode", userCode), zap.String("dn", entry.DN))
+	} else {
+		// The department is kept when the directory value has no match
+		sqlStr := `update sysuser set name=$1,mobile=$2,email=$3,
+		deptid=case when $4>0 then $4 else deptid end,
+		modifytime=current_timestamp,ts=current_timestamp
+		where id=$5 and (name<>$1 or coalesce(mobile,'')<>$2 or coalesce(email,'')<>$3 or ($4>0 and deptid<>$4))`
+		_, err = tx.Exec(sqlStr, name, entry.Mobile, entry.Email, deptID, user.ID)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("provisionLDAPUser update sysuser failed", zap.Error(err))
+			tx.Rollback()
+			return
+		}
+	}
+	// Synchronize the mapped roles, roles without a mapping are managed in the application
+	for _, m := range cfg.RoleMappings {
+		var roleID int32
+		err = tx.QueryRow("select id from sysrole where name=$1 and dr=0", m.Role).Scan(&roleID)
+		if err == sql.ErrNoRows {
+			zap.L().Warn("provisionLDAPUser mapped role not found", zap.String("role", m.Role))
+			err = nil
+			continue
+		}
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("provisionLDAPUser query role failed", zap.String("role", m.Role), zap.Error(err))
+			tx.Rollback()
+			return
+		}
+		if entry.InGroup(m.Group) {
+			_, err = tx.Exec(`insert into sysuserrole(userid,roleid,creatorid)
+			select $1,$2,$3 where not exists (select 1 from sysuserrole where userid=$1 and roleid=$2)`,
+				user.ID, roleID, systemAdminUserID)
+		} else if !inMappedGroup(cfg, entry, m.Role) {
+			_, err = tx.Exec("delete from sysuserrole where userid=$1 and roleid=$2", user.ID, roleID)
+		}
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("provisionLDAPUser sync role failed", zap.String("role", m.Role), zap.Error(err))
+			tx.Rollback()
+			return
+		}
+	}
+	// Write the audit trail
+	err = at.write(user.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("provisionLDAPUser tx.Commit failed", zap.Error(err))
+		return
+	}
+	clearUserPermissions(user.ID)
+	user.DelFromLocalCache()
+	return
+}
+
+// Check if any group mapped to the role contains the user
+func inMappedGroup(cfg *setting.LDAPConfig, entry *ldapauth.Entry, role string) bool {
+	for _, m := range cfg.RoleMappings {
+		if m.Role == role && entry.InGroup(m.Group) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find the department by code, then by name
+func findDeptID(dept string) (deptID int32, err error) {
+	dept = strings.TrimSpace(dept)
+	if dept == "" {
+		return
+	}
+	sqlStr := `select id from department where dr=0 and (code=$1 or lower(name)=lower($1))
+	order by case when code=$1 then 0 else 1 end limit 1`
+	err = db.QueryRow(sqlStr, dept).Scan(&deptID)
+	if err == sql.ErrNoRows {
+		zap.L().Warn("findDeptID department not found", zap.String("dept", dept))
+		return 0, nil
+	}
+	if err != nil {
+		zap.L().Error("findDeptID db.QueryRow failed", zap.Error(err))
+	}
+	return
+}
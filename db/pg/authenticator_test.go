@@ -0,0 +1,160 @@
+package pg
+
+import (
+	"database/sql/driver"
+	"errors"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/ldapauth"
+	"sccsmsserver/pkg/ldapauth/ldapauthtest"
+	"sccsmsserver/setting"
+	"strings"
+	"testing"
+)
+
+// Directory with a user in the Safety Officers group, connected in place of the server
+func useDirectory(t *testing.T) {
+	t.Helper()
+	d := &ldapauthtest.Directory{
+		Users: []ldapauthtest.User{
+			{DN: "CN=Ann Lee,OU=Sales,DC=example,DC=com", Password: "ann-secret", Attributes: map[string][]string{
+				"objectClass":    {"user"},
+				"sAMAccountName": {"ann"},
+				"displayName":    {"Ann Lee"},
+				"mail":           {"ann@example.com"},
+				"memberOf":       {"CN=Safety Officers,OU=Groups,DC=example,DC=com"},
+			}},
+		},
+	}
+	old := ldapauth.Dial
+	ldapauth.Dial = d.Dial
+	t.Cleanup(func() { ldapauth.Dial = old })
+}
+
+func ldapConfig() *setting.LDAPConfig {
+	return &setting.LDAPConfig{
+		Enabled:      true,
+		URL:          "ldap://dc1.example.com",
+		BaseDN:       "DC=example,DC=com",
+		DefaultRoles: []string{"Employees"},
+		RoleMappings: []setting.LDAPRoleMapping{
+			{Group: "Safety Officers", Role: "Safety"},
+			{Group: "Auditors", Role: "Audit"},
+		},
+	}
+}
+
+// Answer the queries of the provisioning of a new user
+func provisioningQueries(query string, args []driver.Value) (rows fakeRows, err error) {
+	switch {
+	case strings.Contains(query, "select count(id) from sysuser"):
+		return fakeRows{Columns: []string{"count"}, Values: [][]driver.Value{{int64(0)}}}, nil
+	case strings.Contains(query, "from department"):
+		if args[0] == "Sales" {
+			rows.Values = [][]driver.Value{{int64(5)}}
+		}
+		return fakeRows{Columns: []string{"id"}, Values: rows.Values}, nil
+	case strings.Contains(query, "insert into sysuser("):
+		return fakeRows{Columns: []string{"id"}, Values: [][]driver.Value{{int64(20001)}}}, nil
+	case strings.Contains(query, "select id from sysrole"):
+		roleIDs := map[string]int64{"Safety": 3, "Audit": 4}
+		if id, ok := roleIDs[args[0].(string)]; ok {
+			rows.Values = [][]driver.Value{{id}}
+		}
+		return fakeRows{Columns: []string{"id"}, Values: rows.Values}, nil
+	case strings.Contains(query, "select row_to_json(t) from sysuser"):
+		return fakeRows{Columns: []string{"row_to_json"}, Values: [][]driver.Value{{[]byte(`{"id":20001,"code":"ann","password":"x"}`)}}}, nil
+	case strings.Contains(query, "from sysuserrole as t"):
+		return fakeRows{Columns: []string{"json_agg"}, Values: [][]driver.Value{{[]byte(`[]`)}}}, nil
+	}
+	return rows, errors.New("unexpected query: " + query)
+}
+
+// Find the statements containing the text
+func findExecs(execs []fakeCall, text string) (found []fakeCall) {
+	for _, e := range execs {
+		if strings.Contains(e.Query, text) {
+			found = append(found, e)
+		}
+	}
+	return
+}
+
+func TestLDAPAuthenticateProvisions(t *testing.T) {
+	useDirectory(t)
+	f := useFakeDB(t, provisioningQueries)
+	la := &ldapAuthenticator{cfg: ldapConfig()}
+	user := User{Code: "ann"}
+	resStatus, err := la.Authenticate(&ParamLogin{UserCode: "ann", Password: "ann-secret"}, &user)
+	if resStatus != i18n.StatusOK || err != nil {
+		t.Fatalf("Authenticate() = %s, %v, want StatusOK", resStatus, err)
+	}
+	if user.ID != 20001 || user.Code != "ann" {
+		t.Errorf("user ID, Code = %d, %q, want 20001, ann", user.ID, user.Code)
+	}
+	execs := f.Execs()
+	// The default role is assigned by name
+	defaultRoles := findExecs(execs, "select $1,id,$2 from sysrole where name=$3")
+	if len(defaultRoles) != 1 || defaultRoles[0].Args[0] != int64(20001) || defaultRoles[0].Args[2] != "Employees" {
+		t.Errorf("default role statements = %v, want Employees assigned to user 20001", defaultRoles)
+	}
+	// The role of the group of the user is assigned, the role of the other group is removed
+	mapped := findExecs(execs, "select $1,$2,$3 where not exists")
+	if len(mapped) != 1 || mapped[0].Args[1] != int64(3) {
+		t.Errorf("mapped role statements = %v, want role 3 assigned", mapped)
+	}
+	removed := findExecs(execs, "delete from sysuserrole")
+	if len(removed) != 1 || removed[0].Args[1] != int64(4) {
+		t.Errorf("removed role statements = %v, want role 4 removed", removed)
+	}
+	// The new user is audited as added by the server
+	audits := findExecs(execs, "insert into sysauditlog")
+	if len(audits) != 1 {
+		t.Fatalf("ran %d audit statements, want 1", len(audits))
+	}
+	a := audits[0].Args
+	if a[1] != int64(20001) || a[2] != AuditActionAdd || a[3] != int64(systemAdminUserID) || a[5] != "system" {
+		t.Errorf("audit args = %v, want user 20001 added by the system", a)
+	}
+	if strings.Contains(a[6].(string), "password") {
+		t.Errorf("audit changes = %s, want the password omitted", a[6])
+	}
+}
+
+func TestLDAPAuthenticateFailed(t *testing.T) {
+	tests := []struct {
+		name     string
+		userCode string
+		password string
+		want     i18n.ResKey
+	}{
+		{name: "bad password", userCode: "ann", password: "bob-secret", want: i18n.StatusInvalidPassword},
+		{name: "empty password", userCode: "ann", password: "", want: i18n.StatusInvalidPassword},
+		{name: "missing user", userCode: "bob", password: "bob-secret", want: i18n.StatusUserNotExist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useDirectory(t)
+			f := useFakeDB(t, provisioningQueries)
+			la := &ldapAuthenticator{cfg: ldapConfig()}
+			user := User{Code: tt.userCode}
+			resStatus, err := la.Authenticate(&ParamLogin{UserCode: tt.userCode, Password: tt.password}, &user)
+			if resStatus != tt.want || err != nil {
+				t.Fatalf("Authenticate() = %s, %v, want %s", resStatus, err, tt.want)
+			}
+			if user.ID != 0 || len(f.Execs()) != 0 {
+				t.Errorf("user ID = %d with %d statements, want no user provisioned", user.ID, len(f.Execs()))
+			}
+		})
+	}
+}
+
+func TestLDAPAuthenticateUnavailable(t *testing.T) {
+	old := ldapauth.Dial
+	ldapauth.Dial = (&ldapauthtest.Directory{DialErr: errors.New("connection refused")}).Dial
+	t.Cleanup(func() { ldapauth.Dial = old })
+	la := &ldapAuthenticator{cfg: ldapConfig()}
+	resStatus, err := la.Authenticate(&ParamLogin{UserCode: "ann", Password: "ann-secret"}, &User{Code: "ann"})
+	if resStatus != i18n.StatusAuthSourceUnavailable || err == nil {
+		t.Errorf("Authenticate() = %s, %v, want StatusAuthSourceUnavailable", resStatus, err)
+	}
+}
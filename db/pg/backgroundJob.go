@@ -0,0 +1,78 @@
+package pg
+
+import (
+	"sccsmsserver/setting"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Default seconds between two runs of the background jobs
+const defaultJobInterval = 60
+
+// Advisory locks of the background jobs
+const (
+	woScheduleLockID = 20180
+	overdueLockID    = 20200
+	mailQueueLockID  = 20220
+	webhookLockID    = 20240
+)
+
+// Job run periodically in the background on every server.
+// Each run holds an advisory lock, a server skips the run while another server holds it.
+type backgroundJob struct {
+	Name   string
+	LockID int64
+	Run    func() error
+}
+
+// Background jobs, run in this order
+var backgroundJobs = []backgroundJob{
+	{Name: "Work Order schedules", LockID: woScheduleLockID, Run: generateScheduledWOs},
+	{Name: "Overdue escalations", LockID: overdueLockID, Run: detectOverdueItems},
+	{Name: "Email notifications", LockID: mailQueueLockID, Run: sendQueuedMails},
+	{Name: "Webhook deliveries", LockID: webhookLockID, Run: sendWebhookDeliveries},
+}
+
+// Start the background jobs
+func StartBackgroundJobs(cfg *setting.SchedulerConfig) {
+	if cfg != nil && cfg.Disabled {
+		zap.L().Info("Background jobs are disabled on this server")
+		return
+	}
+	interval := defaultJobInterval
+	if cfg != nil && cfg.Interval > 0 {
+		interval = cfg.Interval
+	}
+	go func() {
+		for range time.Tick(time.Duration(interval) * time.Second) {
+			for _, job := range backgroundJobs {
+				runBackgroundJob(job)
+			}
+		}
+	}()
+}
+
+// Run the job unless another server is running it.
+// The lock is released when the transaction ends, or when the connection of a crashed server is closed.
+func runBackgroundJob(job backgroundJob) {
+	tx, err := db.Begin()
+	if err != nil {
+		zap.L().Error("runBackgroundJob db.Begin failed", zap.String("job", job.Name), zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	var locked bool
+	err = tx.QueryRow("select pg_try_advisory_xact_lock($1)", job.LockID).Scan(&locked)
+	if err != nil {
+		zap.L().Error("runBackgroundJob pg_try_advisory_xact_lock failed", zap.String("job", job.Name), zap.Error(err))
+		return
+	}
+	if !locked {
+		return
+	}
+	err = job.Run()
+	if err != nil {
+		zap.L().Error("Background job failed", zap.String("job", job.Name), zap.Error(err))
+	}
+}
@@ -339,7 +339,7 @@ func (csa *ConstructionSite) GetInfoByID() (resStatus i18n.ResKey, err error) {
 }
 
 // Add Construction Site
-func (csa *ConstructionSite) Add() (resStatus i18n.ResKey, err error) {
+func (csa *ConstructionSite) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 
 	// Check if the EndDate field is a zero value to prevent writing
@@ -359,32 +359,35 @@ func (csa *ConstructionSite) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Write data into the csa table
-	sqlStr := `insert into csa(code,name,description,cscid,subdeptid,
-	respdeptid,resppersonid,status,endflag,enddate,
-	longitude,latitude,udf1,udf2,udf3,
-	udf4,udf5,udf6,udf7,udf8,
-	udf9,udf10,creatorid,modifierid)
-	values($1,$2,$3,$4,$5,
-	$6,$7,$8,$9,$10,
-	$11,$12,$13,$14,$15,
-	$16,$17,$18,$19,$20,
-	$21,$22,$23,$24)
-	returning id`
-	_, err = db.Exec(sqlStr, csa.Code, csa.Name, csa.Description, csa.Csc.ID, csa.Department.ID,
-		csa.RespDept.ID, csa.RespPerson.ID, csa.Status, csa.EndFlag, csa.EndDate,
-		csa.Longitude, csa.Latitude, csa.Udf1.ID, csa.Udf2.ID, csa.Udf3.ID,
-		csa.Udf4.ID, csa.Udf5.ID, csa.Udf6.ID, csa.Udf7.ID, csa.Udf8.ID,
-		csa.Udf9.ID, csa.Udf10.ID, csa.Creator.ID, csa.Modifier.ID)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("ConstructionSite.Add db.Exec failed", zap.Error(err))
+	return writeAudited(actor, pub.CSA, AuditActionAdd, &csa.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `insert into csa(code,name,description,cscid,subdeptid,
+		respdeptid,resppersonid,status,endflag,enddate,
+		longitude,latitude,udf1,udf2,udf3,
+		udf4,udf5,udf6,udf7,udf8,
+		udf9,udf10,creatorid,modifierid)
+		values($1,$2,$3,$4,$5,
+		$6,$7,$8,$9,$10,
+		$11,$12,$13,$14,$15,
+		$16,$17,$18,$19,$20,
+		$21,$22,$23,$24)
+		returning id`
+		err = tx.QueryRow(sqlStr, csa.Code, csa.Name, csa.Description, csa.Csc.ID, csa.Department.ID,
+			csa.RespDept.ID, csa.RespPerson.ID, csa.Status, csa.EndFlag, csa.EndDate,
+			csa.Longitude, csa.Latitude, csa.Udf1.ID, csa.Udf2.ID, csa.Udf3.ID,
+			csa.Udf4.ID, csa.Udf5.ID, csa.Udf6.ID, csa.Udf7.ID, csa.Udf8.ID,
+			csa.Udf9.ID, csa.Udf10.ID, csa.Creator.ID, csa.Modifier.ID).Scan(&csa.ID)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("ConstructionSite.Add tx.QueryRow failed", zap.Error(err))
+			return
+		}
 		return
-	}
-	return
+	})
 }
 
 // Edit Construction Site
-func (csa *ConstructionSite) Edit() (resStatus i18n.ResKey, err error) {
+func (csa *ConstructionSite) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the EndDate field is a zero value to prevent writing
 	// a zero value to the dateabase.
@@ -403,45 +406,48 @@ func (csa *ConstructionSite) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update record in the csa table
-	sqlStr := `update csa set code=$1,name=$2,description=$3,cscid=$4,subdeptid=$5,
-	respdeptid=$6,resppersonid=$7,status=$8,endflag=$9,enddate=$10,
-	longitude=$11,latitude=$12,udf1=$13,udf2=$14,udf3=$15,
-	udf4=$16,udf5=$17,udf6=$18,udf7=$19,udf8=$20,
-	udf9=$21,udf10=$22,modifierid=$23,
-	modifytime=current_timestamp,ts=current_timestamp
-	where id=$24 and ts=$25 and dr=0`
-	res, err := db.Exec(sqlStr, csa.Code, csa.Name, csa.Description, csa.Csc.ID, csa.Department.ID,
-		csa.RespDept.ID, csa.RespPerson.ID, csa.Status, csa.EndFlag, csa.EndDate,
-		csa.Longitude, csa.Latitude, csa.Udf1.ID, csa.Udf2.ID, csa.Udf3.ID,
-		csa.Udf4.ID, csa.Udf5.ID, csa.Udf6.ID, csa.Udf7.ID, csa.Udf8.ID,
-		csa.Udf9.ID, csa.Udf10.ID, csa.Modifier.ID,
-		csa.ID, csa.Ts)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("ConstructionSite.Edit db.Exec failed", zap.Error(err))
-		return
-	}
-	// Check the number of rows affected by SQL update operation
-	affected, err := res.RowsAffected()
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("ConstructionSite.Edit res.RowsAffected failed", zap.Error(err))
-		return
-	}
-	// If the number of affected rows is less than one,
-	// it means that someone else already updated the record.
-	if affected < 1 {
-		zap.L().Info("ConstructionSite.Edit failed,Other user are Editing")
-		resStatus = i18n.StatusOtherEdit
+	return writeAudited(actor, pub.CSA, AuditActionEdit, &csa.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update csa set code=$1,name=$2,description=$3,cscid=$4,subdeptid=$5,
+		respdeptid=$6,resppersonid=$7,status=$8,endflag=$9,enddate=$10,
+		longitude=$11,latitude=$12,udf1=$13,udf2=$14,udf3=$15,
+		udf4=$16,udf5=$17,udf6=$18,udf7=$19,udf8=$20,
+		udf9=$21,udf10=$22,modifierid=$23,
+		modifytime=current_timestamp,ts=current_timestamp
+		where id=$24 and ts=$25 and dr=0`
+		res, err := tx.Exec(sqlStr, csa.Code, csa.Name, csa.Description, csa.Csc.ID, csa.Department.ID,
+			csa.RespDept.ID, csa.RespPerson.ID, csa.Status, csa.EndFlag, csa.EndDate,
+			csa.Longitude, csa.Latitude, csa.Udf1.ID, csa.Udf2.ID, csa.Udf3.ID,
+			csa.Udf4.ID, csa.Udf5.ID, csa.Udf6.ID, csa.Udf7.ID, csa.Udf8.ID,
+			csa.Udf9.ID, csa.Udf10.ID, csa.Modifier.ID,
+			csa.ID, csa.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("ConstructionSite.Edit tx.Exec failed", zap.Error(err))
+			return
+		}
+		// Check the number of rows affected by SQL update operation
+		affected, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("ConstructionSite.Edit res.RowsAffected failed", zap.Error(err))
+			return
+		}
+		// If the number of affected rows is less than one,
+		// it means that someone else already updated the record.
+		if affected < 1 {
+			zap.L().Info("ConstructionSite.Edit failed,Other user are Editing")
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		// Delete from cache
+		csa.DelFromLocalCache()
 		return
-	}
-	// Delete from cache
-	csa.DelFromLocalCache()
-	return
+	})
 }
 
 // Delte Construction Site master data
-func (csa *ConstructionSite) Delete() (resStatus i18n.ResKey, err error) {
+func (csa *ConstructionSite) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the CS ID is refrenced
 	resStatus, err = csa.CheckUsed()
@@ -449,36 +455,38 @@ func (csa *ConstructionSite) Delete() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the delection field for this data in the csa table
-	sqlStr := `update csa set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp 
-	where id=$2 and dr=0 and ts=$3`
-	res, err := db.Exec(sqlStr, csa.Modifier.ID, csa.ID, csa.Ts)
-	if err != nil {
-		zap.L().Error("ConstructionSite.Delete db.Exec failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
+	return writeAudited(actor, pub.CSA, AuditActionDelete, &csa.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update csa set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp 
+		where id=$2 and dr=0 and ts=$3`
+		res, err := tx.Exec(sqlStr, csa.Modifier.ID, csa.ID, csa.Ts)
+		if err != nil {
+			zap.L().Error("ConstructionSite.Delete tx.Exec failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
 
-	// Check the number of rows affected by the SQL update operation
-	affected, err := res.RowsAffected()
-	if err != nil {
-		zap.L().Error("ConstructionSite.Delete  res.RowsAffected failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// If the number of affected rows is less than one,
-	// it means that someone else has already updated the record.
-	if affected < 1 {
-		resStatus = i18n.StatusOtherEdit
+		// Check the number of rows affected by the SQL update operation
+		affected, err := res.RowsAffected()
+		if err != nil {
+			zap.L().Error("ConstructionSite.Delete  res.RowsAffected failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// If the number of affected rows is less than one,
+		// it means that someone else has already updated the record.
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		// Delete from cache
+		csa.DelFromLocalCache()
 		return
-	}
-	// Delete from cache
-	csa.DelFromLocalCache()
-
-	return i18n.StatusOK, nil
+	})
 }
 
 // Batch delete Construction Sites
-func DeleteCSs(css *[]ConstructionSite, modifyUserId int32) (resStatus i18n.ResKey, err error) {
+func DeleteCSs(css *[]ConstructionSite, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Begin a databese transaction
 	tx, err := db.Begin()
@@ -488,6 +496,17 @@ func DeleteCSs(css *[]ConstructionSite, modifyUserId int32) (resStatus i18n.ResK
 		return
 	}
 	defer tx.Commit()
+	// Lock the Construction Sites and take their state for the audit trail
+	ids := make([]int32, 0, len(*css))
+	for _, item := range *css {
+		ids = append(ids, item.ID)
+	}
+	at, err := beginAudit(tx, actor, pub.CSA, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	delSqlStr := `update csa set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp
 	where id=$2 and dr=0 and ts=$3`
@@ -509,7 +528,7 @@ func DeleteCSs(css *[]ConstructionSite, modifyUserId int32) (resStatus i18n.ResK
 			return
 		}
 		// Write the deletion field for this record in csa table
-		res, err1 := stmt.Exec(modifyUserId, csa.ID, csa.Ts)
+		res, err1 := stmt.Exec(actor.UserID, csa.ID, csa.Ts)
 		if err != nil {
 			zap.L().Error("DeleteCSs stmt.Exec failed", zap.Error(err1))
 			resStatus = i18n.StatusInternalError
@@ -534,7 +553,13 @@ func DeleteCSs(css *[]ConstructionSite, modifyUserId int32) (resStatus i18n.ResK
 		// Delete from cache
 		csa.DelFromLocalCache()
 	}
-	return i18n.StatusOK, nil
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
+	return
 }
 
 // Check if the Construction Site code exists
@@ -584,6 +609,11 @@ func (csa *ConstructionSite) CheckUsed() (resStatus i18n.ResKey, err error) {
 			SqlStr:         `select count(id) as usednumber from issueresolutionform where dr=0 and csaid=$1`,
 			UsedReturnCode: i18n.StatusIRFUsed,
 		},
+		{
+			Description:    "Referenced by Work Order schedule",
+			SqlStr:         `select count(id) as usednumber from woschedule where dr=0 and csaid=$1`,
+			UsedReturnCode: i18n.StatusWOScheduleUsed,
+		},
 	}
 	// Check item by item
 	var usedNum int32
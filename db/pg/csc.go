@@ -305,7 +305,7 @@ func (csc *CSC) CheckNameExist() (resStatus i18n.ResKey, err error) {
 }
 
 // Add CSC
-func (csc *CSC) Add() (resStatus i18n.ResKey, err error) {
+func (csc *CSC) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the csc name exists
 	resStatus, err = csc.CheckNameExist()
@@ -313,20 +313,23 @@ func (csc *CSC) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Write data into the csc table
-	sqlStr := `insert into csc(name,description,fatherid,status,creatorid) 
-	values($1,$2,$3,$4,$5) 
-	returning id`
-	err = db.QueryRow(sqlStr, csc.Name, csc.Description, csc.Father.ID, csc.Status, csc.Creator.ID).Scan(&csc.ID)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("CSC.Add stmt.QueryRow failed", zap.Error(err))
+	return writeAudited(actor, pub.CSC, AuditActionAdd, &csc.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `insert into csc(name,description,fatherid,status,creatorid) 
+		values($1,$2,$3,$4,$5) 
+		returning id`
+		err = tx.QueryRow(sqlStr, csc.Name, csc.Description, csc.Father.ID, csc.Status, csc.Creator.ID).Scan(&csc.ID)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("CSC.Add stmt.QueryRow failed", zap.Error(err))
+			return
+		}
 		return
-	}
-	return
+	})
 }
 
 // Edit CSC
-func (csc *CSC) Edit() (resStatus i18n.ResKey, err error) {
+func (csc *CSC) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the parent CSC is compliant.
 	if csc.Father.ID > 0 {
@@ -358,31 +361,34 @@ func (csc *CSC) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update data in the csc table
-	sqlStr := `update csc set
-	name=$1,description=$2,fatherid=$3,status=$4,modifyTime=current_timestamp,
-	modifierid=$5,ts=current_timestamp
-	where id=$6 and dr=0 and ts=$7`
-	res, err := db.Exec(sqlStr, csc.Name, csc.Description, csc.Father.ID, csc.Status,
-		csc.Modifier.ID, csc.ID, csc.Ts)
-	if err != nil {
-		zap.L().Error("CSC.Edit stmt.Exec failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// Check the number of rows updated by SQL
-	updateNumber, err := res.RowsAffected()
-	if err != nil {
-		zap.L().Error("CSC.Edit res.RowsAffected failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	if updateNumber < 1 {
-		resStatus = i18n.StatusOtherEdit
+	return writeAudited(actor, pub.CSC, AuditActionEdit, &csc.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update csc set
+		name=$1,description=$2,fatherid=$3,status=$4,modifyTime=current_timestamp,
+		modifierid=$5,ts=current_timestamp
+		where id=$6 and dr=0 and ts=$7`
+		res, err := tx.Exec(sqlStr, csc.Name, csc.Description, csc.Father.ID, csc.Status,
+			csc.Modifier.ID, csc.ID, csc.Ts)
+		if err != nil {
+			zap.L().Error("CSC.Edit stmt.Exec failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// Check the number of rows updated by SQL
+		updateNumber, err := res.RowsAffected()
+		if err != nil {
+			zap.L().Error("CSC.Edit res.RowsAffected failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		if updateNumber < 1 {
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		// Delete from cache
+		csc.DelFromLocalCache()
 		return
-	}
-	// Delete from cache
-	csc.DelFromLocalCache()
-	return
+	})
 }
 
 // Check if the CSC have been refrenced
@@ -451,7 +457,7 @@ func (csc *CSC) CheckIsUsed() (resStatus i18n.ResKey, err error) {
 }
 
 // Delete Construction Site Cateory
-func (csc *CSC) Delete() (resStatus i18n.ResKey, err error) {
+func (csc *CSC) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if it's refrenced by other data.
 	resStatus, err = csc.CheckIsUsed()
@@ -459,33 +465,36 @@ func (csc *CSC) Delete() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the deletion flag for the record
-	sqlStr := `update csc set dr=1,modifyTime=current_timestamp,modifierid=$1,ts=current_timestamp where id=$2 and dr=0 and ts=$3`
-	res, err := db.Exec(sqlStr, csc.Modifier.ID, csc.ID, csc.Ts)
-	if err != nil {
-		zap.L().Error("CSC.Delete stmt.Exec failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// Check the number of the rows affected by the update operation.
-	affected, err := res.RowsAffected()
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("CSC.Delete res.RowsAffedted failed", zap.Error(err))
-		return
-	}
+	return writeAudited(actor, pub.CSC, AuditActionDelete, &csc.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update csc set dr=1,modifyTime=current_timestamp,modifierid=$1,ts=current_timestamp where id=$2 and dr=0 and ts=$3`
+		res, err := tx.Exec(sqlStr, csc.Modifier.ID, csc.ID, csc.Ts)
+		if err != nil {
+			zap.L().Error("CSC.Delete stmt.Exec failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// Check the number of the rows affected by the update operation.
+		affected, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("CSC.Delete res.RowsAffedted failed", zap.Error(err))
+			return
+		}
 
-	if affected < 1 {
-		resStatus = i18n.StatusOtherEdit
-		zap.L().Info("CSC.Delete Other User Edit")
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+			zap.L().Info("CSC.Delete Other User Edit")
+			return
+		}
+		// Delete from the local cache
+		csc.DelFromLocalCache()
 		return
-	}
-	// Delete from the local cache
-	csc.DelFromLocalCache()
-	return
+	})
 }
 
 // Batch delete cscs
-func DeleteCSCs(cscs *[]CSC, modifierID int32) (resStatus i18n.ResKey, err error) {
+func DeleteCSCs(cscs *[]CSC, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Start a database transaction
 	tx, err := db.Begin()
@@ -495,6 +504,17 @@ func DeleteCSCs(cscs *[]CSC, modifierID int32) (resStatus i18n.ResKey, err error
 		return
 	}
 	defer tx.Commit()
+	// Lock the cscs and take their state for the audit trail
+	ids := make([]int32, 0, len(*cscs))
+	for _, item := range *cscs {
+		ids = append(ids, item.ID)
+	}
+	at, err := beginAudit(tx, actor, pub.CSC, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	delSqlStr := `update csc set dr=1,modifyTime=current_timestamp,modifierid=$1,ts=current_timestamp where id=$2 and dr=0 and ts=$3`
 
@@ -515,7 +535,7 @@ func DeleteCSCs(cscs *[]CSC, modifierID int32) (resStatus i18n.ResKey, err error
 			return
 		}
 		// Write into database table
-		res, err := stmt.Exec(modifierID, csc.ID, csc.Ts)
+		res, err := stmt.Exec(actor.UserID, csc.ID, csc.Ts)
 		if err != nil {
 			zap.L().Error("DeleteCSCs stmt.Exec failed", zap.Error(err))
 			resStatus = i18n.StatusInternalError
@@ -540,6 +560,12 @@ func DeleteCSCs(cscs *[]CSC, modifierID int32) (resStatus i18n.ResKey, err error
 		// Delete from local cache
 		csc.DelFromLocalCache()
 	}
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
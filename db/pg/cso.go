@@ -3,6 +3,7 @@ package pg
 import (
 	"database/sql"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pub"
 	"time"
 
 	"go.uber.org/zap"
@@ -236,33 +237,36 @@ func (cso *ConstructionSiteOption) CheckIsModify() (resStatus i18n.ResKey, err e
 }
 
 // Edit Construction Site Option
-func (cso *ConstructionSiteOption) Edit() (resStatus i18n.ResKey, err error) {
+func (cso *ConstructionSiteOption) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Update the record in the cso table
-	sqlStr := `update cso set displayname=$1,udcid=$2,defaultvalueid=$3 ,enable=$4,
-	modifytime=current_timestamp, modifierid=$5,ts=current_timestamp 
-	where id=$6 and ts=$7`
-	res, err := db.Exec(sqlStr, cso.DisplayName, cso.UDC.ID, cso.DefaultValue.ID, cso.Enable,
-		cso.Modifier.ID, cso.ID, cso.Ts)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("ConstructionSiteOption.Edit db.Exec failed", zap.Error(err))
-		return
-	}
+	return writeAudited(actor, pub.CSO, AuditActionEdit, &cso.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update cso set displayname=$1,udcid=$2,defaultvalueid=$3 ,enable=$4,
+		modifytime=current_timestamp, modifierid=$5,ts=current_timestamp 
+		where id=$6 and ts=$7`
+		res, err := tx.Exec(sqlStr, cso.DisplayName, cso.UDC.ID, cso.DefaultValue.ID, cso.Enable,
+			cso.Modifier.ID, cso.ID, cso.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("ConstructionSiteOption.Edit tx.Exec failed", zap.Error(err))
+			return
+		}
 
-	// Check the number of rows affected by the SQL update operation
-	affected, err := res.RowsAffected()
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("ConstructionSiteOption.Edit  res.RowsAffected failed", zap.Error(err))
-		return
-	}
-	// if the number of updated rows is less than one,
-	// it means that someone else already updated the record.
-	if affected < 1 {
-		resStatus = i18n.StatusOtherEdit
-		return
-	}
+		// Check the number of rows affected by the SQL update operation
+		affected, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("ConstructionSiteOption.Edit  res.RowsAffected failed", zap.Error(err))
+			return
+		}
+		// if the number of updated rows is less than one,
+		// it means that someone else already updated the record.
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
 
-	return
+		return
+	})
 }
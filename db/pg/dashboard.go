@@ -2,6 +2,7 @@ package pg
 
 import (
 	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/filter"
 	"strings"
 	"time"
 
@@ -93,6 +94,14 @@ type BeReviewedItem struct {
 	ReviewerName   string    `json:"reviewerName"`
 }
 
+// Overdue items escalated to the user
+type OverdueCount struct {
+	WOCount     int32 `json:"woCount"`
+	IssueCount  int32 `json:"issueCount"`
+	IRFCount    int32 `json:"irfCount"`
+	UnReadCount int32 `json:"unReadCount"`
+}
+
 // DashBoard Data struct
 type DashBoardData struct {
 	StartDate       time.Time          `json:"startDate"`
@@ -104,6 +113,7 @@ type DashBoardData struct {
 	IssueItems      []IssueItem        `json:"issueItems"`
 	ReviewedItems   []ReviewedEORecord `json:"reviewedItems"`
 	BeReviewedItems []BeReviewedItem   `json:"beReviewedItems"`
+	Overdue         OverdueCount       `json:"overdue"`
 }
 
 // Risk Count struct
@@ -124,8 +134,9 @@ type RiskTrendData struct {
 }
 
 // Get Dashboard Data
-func (dd *DashBoardData) Get(userID int32) (resStatus i18n.ResKey, err error) {
+func (dd *DashBoardData) Get(ds DataScope) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
+	userID := ds.UserID
 	resStatus, err = dd.GiveWO.Get(userID, dd.StartDate, dd.EndDate)
 	if resStatus != i18n.StatusOK || err != nil {
 		return
@@ -142,7 +153,7 @@ func (dd *DashBoardData) Get(userID int32) (resStatus i18n.ResKey, err error) {
 	if resStatus != i18n.StatusOK || err != nil {
 		return
 	}
-	dd.IssueItems, resStatus, err = GetIssueItems(dd.StartDate, dd.EndDate)
+	dd.IssueItems, resStatus, err = GetIssueItems(dd.StartDate, dd.EndDate, ds)
 	if resStatus != i18n.StatusOK || err != nil {
 		return
 	}
@@ -154,10 +165,32 @@ func (dd *DashBoardData) Get(userID int32) (resStatus i18n.ResKey, err error) {
 	if resStatus != i18n.StatusOK || err != nil {
 		return
 	}
+	resStatus, err = dd.Overdue.Get(userID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 
 	return
 }
 
+// Statistics on the open escalations of the user
+func (oc *OverdueCount) Get(userID int32) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	sqlStr := `select count(distinct itemid) filter (where itemtype='wo'),
+	count(distinct itemid) filter (where itemtype='eo'),
+	count(distinct itemid) filter (where itemtype='irf'),
+	count(id) filter (where isread=0)
+	from escalation
+	where status=0 and sendtoid=$1`
+	err = db.QueryRow(sqlStr, userID).Scan(&oc.WOCount, &oc.IssueCount, &oc.IRFCount, &oc.UnReadCount)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("OverdueCount.Get db.QueryRow failed", zap.Error(err))
+		return
+	}
+	return
+}
+
 // Statistics on Work Orders Issued by Users
 func (gw *GiveWO) Get(userID int32, startDate time.Time, endDate time.Time) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
@@ -361,14 +394,30 @@ func (dp *ProcessIssue) Get(userID int32, startDate time.Time, endDate time.Time
 	return
 }
 
+// Filterable fields used to restrict the company-wide dashboard queries to the data scope
+var dashboardScopeFields = filter.Fields{
+	"departmentID": {Column: "h.deptid", Type: filter.Number},
+	"creatorID":    {Column: "h.creatorid", Type: filter.Number},
+}
+
 // Get Issue Items List
-func GetIssueItems(startDate time.Time, endDate time.Time) (iis []IssueItem, resStatus i18n.ResKey, err error) {
+func GetIssueItems(startDate time.Time, endDate time.Time, ds DataScope) (iis []IssueItem, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	iis = make([]IssueItem, 0)
+	// Compile the data scope, the date range binds $1 and $2
+	scopeWhere, scopeArgs, resStatus := compileFilter(ds.Restrict(filter.Group{}, "departmentID", "creatorID"), dashboardScopeFields, 3)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Concatenate SQL strings
 	build.WriteString(" and h.startTime>=$1")
 	build.WriteString(" and h.startTime<=$2")
+	if scopeWhere != "" {
+		build.WriteString(" and (")
+		build.WriteString(scopeWhere)
+		build.WriteString(")")
+	}
 	sqlString := build.String()
 	build.Reset()
 
@@ -408,7 +457,7 @@ func GetIssueItems(startDate time.Time, endDate time.Time) (iis []IssueItem, res
 	build.WriteString(sqlString)
 
 	sqlStr := build.String()
-	rows, err := db.Query(sqlStr, startDate, endDate)
+	rows, err := db.Query(sqlStr, append([]interface{}{startDate, endDate}, scopeArgs...)...)
 	if err != nil {
 		zap.L().Error("GetIssueItems db.Query(sqlStr) failed", zap.Error(err))
 		resStatus = i18n.StatusInternalError
@@ -547,13 +596,23 @@ func GetBeReviewedItems(userID int32, startDate time.Time, endDate time.Time) (b
 }
 
 // Summarize Risk Records
-func GetRiskRecords(startDate time.Time, endDate time.Time) (rcs []RiskCount, resStatus i18n.ResKey, err error) {
+func GetRiskRecords(startDate time.Time, endDate time.Time, ds DataScope) (rcs []RiskCount, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	rcs = make([]RiskCount, 0)
+	// Compile the data scope, the date range binds $1 and $2
+	scopeWhere, scopeArgs, resStatus := compileFilter(ds.Restrict(filter.Group{}, "departmentID", "creatorID"), dashboardScopeFields, 3)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Concatenate SQL strings
 	build.WriteString(" and h.billdate>=$1")
 	build.WriteString(" and h.billdate<=$2")
+	if scopeWhere != "" {
+		build.WriteString(" and (")
+		build.WriteString(scopeWhere)
+		build.WriteString(")")
+	}
 	build.WriteString("	group by occyear,occmonth,occday,rlid")
 	build.WriteString(" order by occday")
 	conString := build.String()
@@ -575,7 +634,7 @@ func GetRiskRecords(startDate time.Time, endDate time.Time) (rcs []RiskCount, re
 	build.WriteString(conString)
 	sqlStr := build.String()
 	// Retrieve Records from database
-	rows, err := db.Query(sqlStr, startDate, endDate)
+	rows, err := db.Query(sqlStr, append([]interface{}{startDate, endDate}, scopeArgs...)...)
 	if err != nil {
 		zap.L().Error("GetRiskRecords db.Query(sqlStr) failed", zap.Error(err))
 		resStatus = i18n.StatusInternalError
@@ -604,9 +663,9 @@ func GetRiskRecords(startDate time.Time, endDate time.Time) (rcs []RiskCount, re
 }
 
 // Get Risk Trend data
-func (rtd *RiskTrendData) Get() (resStatus i18n.ResKey, err error) {
+func (rtd *RiskTrendData) Get(ds DataScope) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
-	rtd.RiskTrends, resStatus, err = GetRiskRecords(rtd.StartDate, rtd.EndDate)
+	rtd.RiskTrends, resStatus, err = GetRiskRecords(rtd.StartDate, rtd.EndDate, ds)
 	if resStatus != i18n.StatusOK || err != nil {
 		return
 	}
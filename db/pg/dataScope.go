@@ -0,0 +1,224 @@
+package pg
+
+import (
+	"database/sql"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/filter"
+	"sccsmsserver/pub"
+
+	"go.uber.org/zap"
+)
+
+// Role data scope, restricts the voucher records a role member can see
+const (
+	DataScopeAll      int16 = 0 // All records
+	DataScopeSelf     int16 = 1 // Records the user owns
+	DataScopeDept     int16 = 2 // Records of the user's department
+	DataScopeDeptTree int16 = 3 // Records of the user's department and its sub-departments
+)
+
+// Check whether the value is a valid data scope
+func isDataScope(scope int16) bool {
+	return scope >= DataScopeAll && scope <= DataScopeDeptTree
+}
+
+// Data scope of a user
+type DataScope struct {
+	Scope   int16   `json:"scope"`
+	UserID  int32   `json:"userID"`
+	DeptIDs []int32 `json:"deptIDs"`
+}
+
+// Get the widest data scope of the user's roles.
+// A user without roles, or with any role scoped to all records, sees all records.
+func getRolesDataScope(userID int32) (scope int16, err error) {
+	return widestRoleDataScope("select roleid from sysuserrole where userid=$1", userID)
+}
+
+// Get the widest data scope of the roles selected by roleIDsSql with the owner ID
+func widestRoleDataScope(roleIDsSql string, ownerID int32) (scope int16, err error) {
+	sqlStr := `select coalesce(r.datascope,0)
+	from sysrole as r
+	where r.dr=0 and r.id in (` + roleIDsSql + `)`
+	rows, err := db.Query(sqlStr, ownerID)
+	if err != nil {
+		zap.L().Error("widestRoleDataScope db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	var roleNumber int32
+	for rows.Next() {
+		var roleScope int16
+		err = rows.Scan(&roleScope)
+		if err != nil {
+			zap.L().Error("widestRoleDataScope rows.Scan failed", zap.Error(err))
+			return
+		}
+		roleNumber++
+		if roleScope == DataScopeAll {
+			return DataScopeAll, nil
+		}
+		if roleScope > scope {
+			scope = roleScope
+		}
+	}
+	if roleNumber == 0 {
+		scope = DataScopeAll
+	}
+	return
+}
+
+// Get the data scope of the user
+func GetUserDataScope(userID int32) (ds DataScope, resStatus i18n.ResKey, err error) {
+	up, resStatus, err := GetUserPermissions(userID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return dataScopeOf(up)
+}
+
+// Get the data scope granted by the permissions
+func dataScopeOf(up UserPermissions) (ds DataScope, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	ds.UserID = up.UserID
+	userID := up.UserID
+	if up.IsAdmin {
+		ds.Scope = DataScopeAll
+		return
+	}
+	ds.Scope = up.DataScope
+	if ds.Scope == DataScopeAll || ds.Scope == DataScopeSelf {
+		return
+	}
+	// Get the user's department
+	var deptID int32
+	err = db.QueryRow("select coalesce(deptid,0) from sysuser where id=$1", userID).Scan(&deptID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetUserDataScope db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if deptID == 0 {
+		// A user without a department only sees own records
+		ds.Scope = DataScopeSelf
+		return
+	}
+	ds.DeptIDs = []int32{deptID}
+	if ds.Scope == DataScopeDept {
+		return
+	}
+	// Get the sub-departments
+	rows, err := db.Query("select id,fatherid from department where dr=0")
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetUserDataScope db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	var depts []SimpDept
+	for rows.Next() {
+		var dept SimpDept
+		err = rows.Scan(&dept.ID, &dept.FatherID)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("GetUserDataScope rows.Scan failed", zap.Error(err))
+			return
+		}
+		depts = append(depts, dept)
+	}
+	for _, dept := range FindSimpDeptChildrens(depts, deptID) {
+		ds.DeptIDs = append(ds.DeptIDs, dept.ID)
+	}
+	return
+}
+
+// Check whether the record of the department and owner is in the data scope
+func (ds *DataScope) Contains(deptID int32, ownerID int32) bool {
+	if ds.Scope == DataScopeAll || ownerID == ds.UserID {
+		return true
+	}
+	if ds.Scope == DataScopeSelf {
+		return false
+	}
+	for _, id := range ds.DeptIDs {
+		if id == deptID {
+			return true
+		}
+	}
+	return false
+}
+
+// Restrict the filter to the records in the data scope.
+// deptField and ownerField are the filter field names of the record department and owner.
+// The scope is added as a restriction, it doesn't count against the limits of the client filter.
+func (ds *DataScope) Restrict(f filter.Group, deptField string, ownerField string) filter.Group {
+	if ds.Scope == DataScopeAll {
+		return f
+	}
+	owner := filter.Condition{Field: ownerField, Operator: filter.OpEq, Value: ds.UserID}
+	var scope filter.Group
+	if ds.Scope == DataScopeSelf || len(ds.DeptIDs) == 0 {
+		scope.Conditions = []filter.Condition{owner}
+	} else {
+		scope.Logic = "or"
+		// Split the department list to stay within the filter list limit
+		for start := 0; start < len(ds.DeptIDs); start += filter.MaxListValues {
+			end := start + filter.MaxListValues
+			if end > len(ds.DeptIDs) {
+				end = len(ds.DeptIDs)
+			}
+			deptIDs := make([]interface{}, 0, end-start)
+			for _, id := range ds.DeptIDs[start:end] {
+				deptIDs = append(deptIDs, id)
+			}
+			scope.Conditions = append(scope.Conditions, filter.Condition{Field: deptField, Operator: filter.OpIn, Value: deptIDs})
+		}
+		scope.Conditions = append(scope.Conditions, owner)
+	}
+	f.Restrictions = append(f.Restrictions, scope)
+	return f
+}
+
+// Queries of the department of a voucher $1 and whether the user $2 owns it,
+// the owners are the ones the lists of the voucher are restricted by
+var scopedVoucherSqls = map[pub.DataType]string{
+	pub.WO: `select coalesce(h.deptid,0),h.creatorid=$2
+	or exists(select 1 from workorder_b as b where b.hid=h.id and b.executorid=$2 and b.dr=0)
+	from workorder_h as h where h.id=$1 and h.dr=0`,
+	pub.EO: `select coalesce(h.deptid,0),h.creatorid=$2 or h.executorid=$2
+	or exists(select 1 from executionorder_b as b where b.hid=h.id and b.issueownerid=$2 and b.dr=0)
+	from executionorder_h as h where h.id=$1 and h.dr=0`,
+	pub.PPEIF: `select coalesce(h.deptid,0),h.creatorid=$2
+	or exists(select 1 from ppeissuanceform_b as b where b.hid=h.id and b.recipientid=$2 and b.dr=0)
+	from ppeissuanceform_h as h where h.id=$1 and h.dr=0`,
+	pub.TR: `select coalesce(h.deptid,0),h.creatorid=$2 or h.lecturerid=$2
+	or exists(select 1 from trainingrecord_b as b where b.hid=h.id and b.studentid=$2 and b.dr=0)
+	from trainingrecord_h as h where h.id=$1 and h.dr=0`,
+}
+
+// Check whether the voucher is in the data scope.
+// Deleted vouchers are left to the detail query, which reports them as deleted.
+func (ds *DataScope) CheckVoucher(voucherType pub.DataType, voucherID int32) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	sqlStr, ok := scopedVoucherSqls[voucherType]
+	if !ok || ds.Scope == DataScopeAll {
+		return
+	}
+	var deptID int32
+	var owned bool
+	err = db.QueryRow(sqlStr, voucherID, ds.UserID).Scan(&deptID, &owned)
+	if err == sql.ErrNoRows {
+		err = nil
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("DataScope.CheckVoucher db.QueryRow failed", zap.Error(err))
+		return
+	}
+	// The owners were checked by the query, only the department is left
+	if !owned && !ds.Contains(deptID, 0) {
+		resStatus = i18n.StatusPermissionDenied
+	}
+	return
+}
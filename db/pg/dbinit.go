@@ -10,6 +10,7 @@ var SysFunctionList SystemMenus = SystemMenus{
 	SystemMenu{ID: 20, FatherID: 0, Title: "MenuAddressBook", Path: "/private/addressBook", Icon: "ContactPhone", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.0.0"},
 	SystemMenu{ID: 30, FatherID: 0, Title: "MenuCSM", Path: "/private/constructionSiteManagement", Icon: "Streetview", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.0.0"},
 	SystemMenu{ID: 110, FatherID: 30, Title: "MenuWO", Path: "/private/csm/workOrder", Icon: "", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.0.0"},
+	SystemMenu{ID: 120, FatherID: 30, Title: "MenuWOS", Path: "/private/csm/workOrderSchedule", Icon: "", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.13.0"},
 	SystemMenu{ID: 210, FatherID: 30, Title: "MenuEO", Path: "/private/csm/executionOrder", Icon: "", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.0.0"},
 	SystemMenu{ID: 220, FatherID: 30, Title: "MenuEOReview", Path: "/private/csm/EOReview", Icon: "", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.0.0"},
 	SystemMenu{ID: 310, FatherID: 30, Title: "MenuIRF", Path: "/private/csm/issueResolutionForm", Icon: "", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.0.0"},
@@ -48,9 +49,14 @@ var SysFunctionList SystemMenus = SystemMenus{
 	SystemMenu{ID: 9020, FatherID: 9000, Title: "MenuUser", Path: "/private/permission/user", Icon: "", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.0.0"},
 	SystemMenu{ID: 9030, FatherID: 9000, Title: "MenuPA", Path: "/private/permission/permissionAssignment", Icon: "", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.0.0"},
 	SystemMenu{ID: 9040, FatherID: 9000, Title: "MenuOU", Path: "/private/permission/onlineUser", Icon: "", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.0.0"},
+	SystemMenu{ID: 9050, FatherID: 9000, Title: "MenuLS", Path: "/private/permission/loginSecurity", Icon: "", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.8.0"},
+	SystemMenu{ID: 9060, FatherID: 9000, Title: "MenuAT", Path: "/private/permission/auditTrail", Icon: "", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.9.0"},
+	SystemMenu{ID: 9070, FatherID: 9000, Title: "MenuAK", Path: "/private/permission/apiKeys", Icon: "", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.10.0"},
 	SystemMenu{ID: 9100, FatherID: 0, Title: "MenuSettings", Path: "/private/options", Icon: "Settings", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.0.0"},
 	SystemMenu{ID: 9110, FatherID: 9100, Title: "MenuCSO", Path: "/private/options/constructionSiteOptions", Icon: "", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.0.0"},
 	SystemMenu{ID: 9130, FatherID: 9100, Title: "MenuLPS", Path: "/private/options/landingPageSetup", Icon: "", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.1.0"},
+	SystemMenu{ID: 9140, FatherID: 9100, Title: "MenuAF", Path: "/private/options/approvalFlows", Icon: "", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.12.0"},
+	SystemMenu{ID: 9150, FatherID: 9100, Title: "MenuWH", Path: "/private/options/webhooks", Icon: "", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.17.0"},
 	SystemMenu{ID: 9910, FatherID: 0, Title: "MenuProfile", Path: "/private/my/profile", Icon: "ManageAccounts", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.0.0"},
 	SystemMenu{ID: 9920, FatherID: 0, Title: "MenuAbout", Path: "/private/my/about", Icon: "Info", Component: "", Selected: false, Indeterminate: false, AddFromVersion: "1.0.0"},
 }
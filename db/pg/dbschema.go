@@ -90,6 +90,8 @@ var tables []table = []table{
 			description varchar(256),
 			systemflag smallint DEFAULT 0,
 			alluserflag smallint DEFAULT 0,
+			datascope smallint DEFAULT 0,
+			requiretotp smallint DEFAULT 0,
 			status smallint default 0,
 			createtime timestamp with time zone default current_timestamp,
 			creatorid int DEFAULT 0,
@@ -112,7 +114,7 @@ var tables []table = []table{
 			id serial NOT NULL,
 			code varchar(32) NOT NULL,
 			name varchar(64) NOT NULL,
-			password varchar(64) NOT NULL,
+			password varchar(256) NOT NULL,
 			mobile varchar(32) default '',
 			email varchar(64) default '',
 			isoperator smallint DEFAULT 1,
@@ -124,6 +126,12 @@ var tables []table = []table{
 			locked smallint DEFAULT 0,
 			status smallint DEFAULT 0,			
 			systemflag smallint DEFAULT 0,	
+			totpsecret varchar(256) DEFAULT '',
+			totpenabled smallint DEFAULT 0,
+			totplaststep bigint DEFAULT 0,
+			authsource varchar(16) DEFAULT 'local',
+			mustchangepwd smallint DEFAULT 0,
+			pwdchangetime timestamp with time zone default CURRENT_TIMESTAMP,
 			createtime timestamp  with time zone default CURRENT_TIMESTAMP,
 			creatorid int DEFAULT 0,
 			modifytime timestamp  with time zone default to_timestamp(0),
@@ -164,6 +172,7 @@ var tables []table = []table{
 			menuid int,
 			selected bool default true,
 			indeterminate bool,
+			actions varchar(256) default '',
 			createtime timestamp  with time zone default current_timestamp,
 			creatorid int DEFAULT 0,
 			modifytime timestamp  with time zone default to_timestamp(0),
@@ -186,6 +195,7 @@ var tables []table = []table{
 			clientip varchar(32),
 			useragent varchar(256),
 			type smallint DEFAULT 0,
+			cleared smallint DEFAULT 0,
 			dr smallint  DEFAULT 0,
 			ts timestamp with time zone default current_timestamp,
 			PRIMARY KEY (id)
@@ -434,6 +444,7 @@ var tables []table = []table{
 			isrequirefile smallint default 0,
 			isonsitephoto smallint default 0,
 			risklevelid int default 0,
+			errorrules text default '',
 			createtime timestamp with time zone default current_timestamp,
 			creatorid int DEFAULT 0,
 			modifytime timestamp with time zone default to_timestamp(0),
@@ -487,6 +498,7 @@ var tables []table = []table{
 			isrequirefile smallint default 0,
 			isonsitephoto smallint default 0,
 			risklevelid int default 0,
+			errorrules text default '',
 			createtime timestamp with time zone default current_timestamp,
 			creatorid int DEFAULT 0,
 			modifytime timestamp with time zone default to_timestamp(0),
@@ -506,6 +518,9 @@ var tables []table = []table{
 			name varchar(128),
 			description varchar(512),
 			color varchar(128),
+			ownerhours int default 0,
+			resphours int default 24,
+			headhours int default 72,
 			status smallint default 0, 
 			createtime timestamp with time zone default current_timestamp,
 			creatorid int DEFAULT 0,				
@@ -631,6 +646,7 @@ var tables []table = []table{
 			description varchar(256),
 			status smallint default 0,
 			workdate timestamp with time zone default current_timestamp,
+			drafteo smallint default 0,
 			createtime timestamp with time zone default current_timestamp,
 			creatorid int DEFAULT 0,
 			confirmtime timestamp with time zone default to_timestamp(0),
@@ -1135,6 +1151,405 @@ var tables []table = []table{
 		AddFromVersion: "1.0.0",
 		InitFunc:       genericInitTable,
 	},
+	{
+		TableName:   "sysrefreshtoken",
+		Description: "User refresh token",
+		CreateSQL: `
+			create table if not exists sysrefreshtoken (
+			id serial NOT NULL,
+			userid int DEFAULT 0,
+			clienttype varchar(32),
+			sessionid varchar(64),
+			tokenhash varchar(64) NOT NULL UNIQUE,
+			expiretime timestamp with time zone,
+			sessionexpiretime timestamp with time zone,
+			usedtime timestamp with time zone,
+			revoked smallint DEFAULT 0,
+			createtime timestamp with time zone default current_timestamp,
+			PRIMARY KEY (id)
+			);`,
+		AddFromVersion: "1.4.0",
+		InitFunc:       genericInitTable,
+	},
+	{
+		TableName:   "sysrecoverycode",
+		Description: "User two-factor authentication recovery code",
+		CreateSQL: `
+			create table if not exists sysrecoverycode (
+			id serial NOT NULL,
+			userid int DEFAULT 0,
+			codehash varchar(64) NOT NULL,
+			usedtime timestamp with time zone,
+			createtime timestamp with time zone default current_timestamp,
+			PRIMARY KEY (id)
+			);`,
+		AddFromVersion: "1.5.0",
+		InitFunc:       genericInitTable,
+	},
+	{
+		TableName:   "syspasswordhistory",
+		Description: "User password history",
+		CreateSQL: `
+			create table if not exists syspasswordhistory (
+			id serial NOT NULL,
+			userid int DEFAULT 0,
+			password varchar(256) NOT NULL,
+			createtime timestamp with time zone default current_timestamp,
+			PRIMARY KEY (id)
+			);`,
+		AddFromVersion: "1.7.0",
+		InitFunc:       genericInitTable,
+	},
+	{
+		TableName:   "sysipaccess",
+		Description: "IP address allow and deny list",
+		CreateSQL: `
+			create table if not exists sysipaccess (
+			id serial NOT NULL,
+			cidr varchar(64) NOT NULL,
+			accesstype smallint DEFAULT 2,
+			description varchar(256) DEFAULT '',
+			createtime timestamp with time zone default current_timestamp,
+			creatorid int DEFAULT 0,
+			modifytime timestamp with time zone default current_timestamp,
+			modifierid int DEFAULT 0,
+			ts timestamp with time zone default current_timestamp,
+			dr smallint DEFAULT 0,
+			PRIMARY KEY (id)
+			);`,
+		AddFromVersion: "1.8.0",
+		InitFunc:       genericInitTable,
+	},
+	{
+		TableName:   "sysauditlog",
+		Description: "Audit trail of data changes",
+		CreateSQL: `
+			create table if not exists sysauditlog (
+			id bigserial NOT NULL,
+			entitytype varchar(32) NOT NULL,
+			entityid int DEFAULT 0,
+			action varchar(16) NOT NULL,
+			operatorid int DEFAULT 0,
+			clientip varchar(64) DEFAULT '',
+			clienttype varchar(16) DEFAULT '',
+			changes jsonb,
+			createtime timestamp with time zone default current_timestamp,
+			PRIMARY KEY (id)
+			);
+			create index if not exists sysauditlog_entity on sysauditlog(entitytype,entityid);
+			create index if not exists sysauditlog_operator on sysauditlog(operatorid,createtime);`,
+		AddFromVersion: "1.9.0",
+		InitFunc:       genericInitTable,
+	},
+	{
+		TableName:   "sysapikey",
+		Description: "API keys of service users",
+		CreateSQL: `
+			create table if not exists sysapikey (
+			id serial NOT NULL,
+			name varchar(64) NOT NULL,
+			userid int NOT NULL,
+			prefix varchar(16) NOT NULL,
+			keyhash varchar(128) NOT NULL,
+			description varchar(256) DEFAULT '',
+			expiretime timestamp with time zone NOT NULL,
+			lastusedtime timestamp with time zone default to_timestamp(0),
+			lastusedip varchar(64) DEFAULT '',
+			status smallint DEFAULT 0,
+			createtime timestamp with time zone default current_timestamp,
+			creatorid int DEFAULT 0,
+			modifytime timestamp with time zone default to_timestamp(0),
+			modifierid int DEFAULT 0,
+			dr smallint DEFAULT 0,
+			ts timestamp with time zone default current_timestamp,
+			PRIMARY KEY (id)
+			);
+			create unique index if not exists sysapikey_prefix on sysapikey(prefix);`,
+		AddFromVersion: "1.10.0",
+		InitFunc:       genericInitTable,
+	},
+	{
+		TableName:   "sysapikeyrole",
+		Description: "API Key and Role Mapping",
+		CreateSQL: `
+			create table if not exists sysapikeyrole (
+			id serial NOT NULL,
+			keyid int NOT NULL,
+			roleid int NOT NULL,
+			createtime timestamp with time zone default current_timestamp,
+			creatorid int DEFAULT 0,
+			modifytime timestamp with time zone default to_timestamp(0),
+			modifierid int DEFAULT 0,
+			dr smallint DEFAULT 0,
+			ts timestamp with time zone default current_timestamp,
+			PRIMARY KEY (id)
+			);
+			create index if not exists sysapikeyrole_key on sysapikeyrole(keyid);`,
+		AddFromVersion: "1.10.0",
+		InitFunc:       genericInitTable,
+	},
+	{
+		TableName:   "sysrsakey",
+		Description: "Login RSA keys",
+		CreateSQL: `
+			create table if not exists sysrsakey (
+			id serial NOT NULL,
+			kid varchar(32) NOT NULL,
+			publickey varchar(2048) NOT NULL,
+			privatekey varchar(4096) NOT NULL,
+			activetime timestamp with time zone default current_timestamp,
+			retiretime timestamp with time zone default to_timestamp(0),
+			createtime timestamp with time zone default current_timestamp,
+			dr smallint DEFAULT 0,
+			ts timestamp with time zone default current_timestamp,
+			PRIMARY KEY (id)
+			);
+			create unique index if not exists sysrsakey_kid on sysrsakey(kid);`,
+		AddFromVersion: "1.11.0",
+		InitFunc:       initSysRsaKey,
+	},
+	{
+		TableName:   "sysapprovalflow",
+		Description: "Voucher approval flows",
+		CreateSQL: `
+			create table if not exists sysapprovalflow (
+			id serial NOT NULL,
+			vouchertype varchar(16) NOT NULL,
+			deptid int DEFAULT 0,
+			name varchar(64) NOT NULL,
+			description varchar(256) DEFAULT '',
+			status smallint DEFAULT 0,
+			createtime timestamp with time zone default current_timestamp,
+			creatorid int DEFAULT 0,
+			modifytime timestamp with time zone default to_timestamp(0),
+			modifierid int DEFAULT 0,
+			dr smallint DEFAULT 0,
+			ts timestamp with time zone default current_timestamp,
+			PRIMARY KEY (id)
+			);`,
+		AddFromVersion: "1.12.0",
+		InitFunc:       genericInitTable,
+	},
+	{
+		TableName:   "sysapprovalstep",
+		Description: "Approval flow steps",
+		CreateSQL: `
+			create table if not exists sysapprovalstep (
+			id serial NOT NULL,
+			flowid int NOT NULL,
+			stepnumber int NOT NULL,
+			name varchar(64) DEFAULT '',
+			approvertype smallint NOT NULL,
+			approverid int DEFAULT 0,
+			createtime timestamp with time zone default current_timestamp,
+			creatorid int DEFAULT 0,
+			modifytime timestamp with time zone default to_timestamp(0),
+			modifierid int DEFAULT 0,
+			dr smallint DEFAULT 0,
+			ts timestamp with time zone default current_timestamp,
+			PRIMARY KEY (id)
+			);
+			create index if not exists sysapprovalstep_flow on sysapprovalstep(flowid);`,
+		AddFromVersion: "1.12.0",
+		InitFunc:       genericInitTable,
+	},
+	{
+		TableName:   "sysapprovalinstance",
+		Description: "Voucher approvals",
+		CreateSQL: `
+			create table if not exists sysapprovalinstance (
+			id serial NOT NULL,
+			vouchertype varchar(16) NOT NULL,
+			voucherid int NOT NULL,
+			deptid int DEFAULT 0,
+			flowid int NOT NULL,
+			currentstep int DEFAULT 0,
+			round int DEFAULT 1,
+			status smallint DEFAULT 0,
+			submitterid int DEFAULT 0,
+			submittime timestamp with time zone default current_timestamp,
+			finishtime timestamp with time zone default to_timestamp(0),
+			dr smallint DEFAULT 0,
+			ts timestamp with time zone default current_timestamp,
+			PRIMARY KEY (id)
+			);
+			create index if not exists sysapprovalinstance_voucher on sysapprovalinstance(vouchertype,voucherid);
+			create unique index if not exists sysapprovalinstance_pending on sysapprovalinstance(vouchertype,voucherid) where status=0 and dr=0;`,
+		AddFromVersion: "1.12.0",
+		InitFunc:       genericInitTable,
+	},
+	{
+		TableName:   "sysapprovalrecord",
+		Description: "Voucher approval history",
+		CreateSQL: `
+			create table if not exists sysapprovalrecord (
+			id serial NOT NULL,
+			instanceid int NOT NULL,
+			stepid int DEFAULT 0,
+			stepnumber int DEFAULT 0,
+			round int DEFAULT 1,
+			action varchar(16) NOT NULL,
+			comment varchar(512) DEFAULT '',
+			operatorid int DEFAULT 0,
+			createtime timestamp with time zone default current_timestamp,
+			PRIMARY KEY (id)
+			);
+			create index if not exists sysapprovalrecord_instance on sysapprovalrecord(instanceid);`,
+		AddFromVersion: "1.12.0",
+		InitFunc:       genericInitTable,
+	},
+	{
+		TableName:   "woschedule",
+		Description: "Recurring Work Order schedules",
+		CreateSQL: `
+			create table if not exists woschedule (
+			id serial NOT NULL,
+			name varchar(64) NOT NULL,
+			description varchar(256) DEFAULT '',
+			deptid int DEFAULT 0,
+			csaid int NOT NULL,
+			eptid int NOT NULL,
+			executorid int NOT NULL,
+			frequency smallint NOT NULL,
+			repeatinterval int DEFAULT 1,
+			weekdays int[] DEFAULT '{}',
+			monthday int DEFAULT 0,
+			rrule varchar(256) DEFAULT '',
+			startdate timestamp with time zone NOT NULL,
+			enddate timestamp with time zone default to_timestamp(0),
+			starttime varchar(5) DEFAULT '08:00',
+			duration int DEFAULT 0,
+			leaddays int DEFAULT 0,
+			autoconfirm smallint DEFAULT 0,
+			status smallint DEFAULT 0,
+			lastdate timestamp with time zone default to_timestamp(0),
+			createtime timestamp with time zone default current_timestamp,
+			creatorid int DEFAULT 0,
+			modifytime timestamp with time zone default to_timestamp(0),
+			modifierid int DEFAULT 0,
+			dr smallint DEFAULT 0,
+			ts timestamp with time zone default current_timestamp,
+			PRIMARY KEY (id)
+			);`,
+		AddFromVersion: "1.13.0",
+		InitFunc:       genericInitTable,
+	},
+	{
+		TableName:   "escalation",
+		Description: "Overdue escalations",
+		CreateSQL: `create table if not exists escalation(
+			id serial NOT NULL,
+			itemtype varchar(32) DEFAULT '',
+			itemid int DEFAULT 0,
+			hid int DEFAULT 0,
+			billnumber varchar(128) DEFAULT '',
+			rownumber int DEFAULT 0,
+			csaid int DEFAULT 0,
+			deptid int DEFAULT 0,
+			risklevelid int DEFAULT 0,
+			duetime timestamp with time zone default to_timestamp(0),
+			level smallint DEFAULT 0,
+			sendtoid int DEFAULT 0,
+			isread smallint DEFAULT 0,
+			readtime timestamp with time zone default to_timestamp(0),
+			status smallint DEFAULT 0,
+			createtime timestamp with time zone default current_timestamp,
+			closetime timestamp with time zone default to_timestamp(0),
+			ts timestamp with time zone default current_timestamp,
+			PRIMARY KEY (id)
+			);
+			create index if not exists escalation_sendto on escalation(sendtoid,status);
+			create unique index if not exists escalation_open on escalation(itemtype,itemid,level) where status=0;`,
+		AddFromVersion: "1.15.0",
+		InitFunc:       genericInitTable,
+	},
+	{
+		TableName:   "sysnotifypref",
+		Description: "Notification preferences of the users",
+		CreateSQL: `create table if not exists sysnotifypref(
+			userid int NOT NULL,
+			language varchar(16) DEFAULT '',
+			email smallint DEFAULT 1,
+			mutedevents varchar(32)[] DEFAULT '{}',
+			modifytime timestamp with time zone default current_timestamp,
+			ts timestamp with time zone default current_timestamp,
+			PRIMARY KEY (userid)
+			);`,
+		AddFromVersion: "1.16.0",
+		InitFunc:       genericInitTable,
+	},
+	{
+		TableName:   "sysmailqueue",
+		Description: "Email notifications queue",
+		CreateSQL: `create table if not exists sysmailqueue(
+			id serial NOT NULL,
+			userid int DEFAULT 0,
+			event varchar(32) DEFAULT '',
+			toaddress varchar(256) DEFAULT '',
+			subject varchar(512) DEFAULT '',
+			body text DEFAULT '',
+			status smallint DEFAULT 0,
+			attempts int DEFAULT 0,
+			nextattempt timestamp with time zone default current_timestamp,
+			lasterror varchar(1024) DEFAULT '',
+			createtime timestamp with time zone default current_timestamp,
+			senttime timestamp with time zone default to_timestamp(0),
+			ts timestamp with time zone default current_timestamp,
+			PRIMARY KEY (id)
+			);
+			create index if not exists sysmailqueue_due on sysmailqueue(status,nextattempt);`,
+		AddFromVersion: "1.16.0",
+		InitFunc:       genericInitTable,
+	},
+	{
+		TableName:   "syswebhook",
+		Description: "Voucher event webhooks",
+		CreateSQL: `create table if not exists syswebhook(
+			id serial NOT NULL,
+			name varchar(64) NOT NULL,
+			url varchar(1024) NOT NULL,
+			secret varchar(256) DEFAULT '',
+			events varchar(64)[] DEFAULT '{}',
+			description varchar(256) DEFAULT '',
+			status smallint DEFAULT 0,
+			createtime timestamp with time zone default current_timestamp,
+			creatorid int DEFAULT 0,
+			modifytime timestamp with time zone default to_timestamp(0),
+			modifierid int DEFAULT 0,
+			dr smallint DEFAULT 0,
+			ts timestamp with time zone default current_timestamp,
+			PRIMARY KEY (id)
+			);`,
+		AddFromVersion: "1.17.0",
+		InitFunc:       genericInitTable,
+	},
+	{
+		TableName:   "syswebhookdelivery",
+		Description: "Webhook deliveries",
+		CreateSQL: `create table if not exists syswebhookdelivery(
+			id serial NOT NULL,
+			webhookid int NOT NULL,
+			event varchar(64) DEFAULT '',
+			vouchertype varchar(16) DEFAULT '',
+			voucherid int DEFAULT 0,
+			billnumber varchar(64) DEFAULT '',
+			payload text DEFAULT '',
+			status smallint DEFAULT 0,
+			attempts int DEFAULT 0,
+			nextattempt timestamp with time zone default current_timestamp,
+			responsecode int DEFAULT 0,
+			lasterror varchar(1024) DEFAULT '',
+			redeliveryof int DEFAULT 0,
+			createtime timestamp with time zone default current_timestamp,
+			deliverytime timestamp with time zone default to_timestamp(0),
+			ts timestamp with time zone default current_timestamp,
+			PRIMARY KEY (id)
+			);
+			create index if not exists syswebhookdelivery_due on syswebhookdelivery(status,nextattempt);
+			create index if not exists syswebhookdelivery_webhook on syswebhookdelivery(webhookid);`,
+		AddFromVersion: "1.17.0",
+		InitFunc:       genericInitTable,
+	},
 }
 
 // Generic database table initialization function.
@@ -2,10 +2,141 @@ package pg
 
 import (
 	"sccsmsserver/pub"
+	"strconv"
+	"strings"
 
 	"go.uber.org/zap"
 )
 
+// Database schema upgrade struct
+type dbUpgrade struct {
+	Version     string   // Database schema version after the upgrade
+	Description string   // Upgrade description
+	UpgradeSQL  []string // SQL statements that modify existing tables
+}
+
+// All database schema upgrades, in ascending version order.
+// New tables are created from the tables list by their AddFromVersion,
+// UpgradeSQL only needs to modify tables that already exist.
+var dbUpgrades = []dbUpgrade{
+	{
+		Version:     "1.1.0",
+		Description: "Role menu permission actions",
+		UpgradeSQL: []string{
+			`alter table sysrolemenu add column if not exists actions varchar(256) default ''`,
+		},
+	},
+	{
+		Version:     "1.2.0",
+		Description: "Role data scope",
+		UpgradeSQL: []string{
+			`alter table sysrole add column if not exists datascope smallint default 0`,
+		},
+	},
+	{
+		Version:     "1.3.0",
+		Description: "Adaptive password hashes",
+		UpgradeSQL: []string{
+			`alter table sysuser alter column password type varchar(256)`,
+		},
+	},
+	{
+		Version:     "1.4.0",
+		Description: "Refresh tokens",
+	},
+	{
+		Version:     "1.5.0",
+		Description: "TOTP two-factor authentication",
+		UpgradeSQL: []string{
+			`alter table sysuser add column if not exists totpsecret varchar(64) default ''`,
+			`alter table sysuser add column if not exists totpenabled smallint default 0`,
+			`alter table sysuser add column if not exists totplaststep bigint default 0`,
+			`alter table sysrole add column if not exists requiretotp smallint default 0`,
+		},
+	},
+	{
+		Version:     "1.6.0",
+		Description: "User authentication source",
+		UpgradeSQL: []string{
+			`alter table sysuser add column if not exists authsource varchar(16) default 'local'`,
+		},
+	},
+	{
+		Version:     "1.7.0",
+		Description: "Password policy",
+		UpgradeSQL: []string{
+			`alter table sysuser add column if not exists mustchangepwd smallint default 0`,
+			`alter table sysuser add column if not exists pwdchangetime timestamp with time zone default current_timestamp`,
+		},
+	},
+	{
+		Version:     "1.8.0",
+		Description: "IP access list and lockout administration",
+		UpgradeSQL: []string{
+			`alter table sysloginfault add column if not exists cleared smallint default 0`,
+		},
+	},
+	{
+		Version:     "1.9.0",
+		Description: "Audit trail",
+	},
+	{
+		Version:     "1.10.0",
+		Description: "API keys of service users",
+	},
+	{
+		Version:     "1.11.0",
+		Description: "Rotatable login RSA keys",
+	},
+	{
+		Version:     "1.12.0",
+		Description: "Voucher approval workflow",
+	},
+	{
+		Version:     "1.13.0",
+		Description: "Recurring Work Order schedules",
+	},
+	{
+		Version:     "1.14.0",
+		Description: "Execution Order drafts of Work Orders",
+		UpgradeSQL: []string{
+			`alter table workorder_h add column if not exists drafteo smallint default 0`,
+		},
+	},
+	{
+		Version:     "1.15.0",
+		Description: "Overdue escalations by Risk Level",
+		UpgradeSQL: []string{
+			`alter table risklevel add column if not exists ownerhours int default 0`,
+			`alter table risklevel add column if not exists resphours int default 24`,
+			`alter table risklevel add column if not exists headhours int default 72`,
+		},
+	},
+	{
+		Version:     "1.16.0",
+		Description: "Email notifications",
+	},
+	{
+		Version:     "1.17.0",
+		Description: "Voucher event webhooks",
+	},
+	{
+		Version:     "1.18.0",
+		Description: "Error rules of Execution Projects",
+		UpgradeSQL: []string{
+			`alter table epa add column if not exists errorrules text default ''`,
+			`alter table ept_b add column if not exists errorrules text default ''`,
+		},
+	},
+	{
+		Version:     "1.19.0",
+		Description: "Encrypted TOTP secrets",
+		UpgradeSQL: []string{
+			`alter table sysuser alter column totpsecret type varchar(256)`,
+		},
+	},
+}
+
 // Upgrade database schema version
 func upgradeDb() (isFinish bool, err error) {
 	isFinish = true
@@ -19,13 +150,13 @@ func upgradeDb() (isFinish bool, err error) {
 		return
 	}
 	// If the database schema version and the application's database version are the same, no upgrade is needed.
-	if pub.DbVersion == currentDbVer {
+	if compareVersion(pub.DbVersion, currentDbVer) == 0 {
 		return
 	}
 
 	// If the database schema version is greater than the application's database version,
 	// output an error log asking the user to upgrade the application.
-	if pub.DbVersion < currentDbVer {
+	if compareVersion(pub.DbVersion, currentDbVer) < 0 {
 		isFinish = false
 		zap.L().Error("The current database version is newer than the version used by the application. The application cannot start. Please upgrade the application.")
 		return
@@ -33,9 +164,107 @@ func upgradeDb() (isFinish bool, err error) {
 
 	// if the database schema version is less than the application's database version,
 	// call the relevant function to upgrade the database schema.
-	if pub.DbVersion > currentDbVer {
-		// call the relevant function
+	for _, upgrade := range dbUpgrades {
+		if compareVersion(upgrade.Version, currentDbVer) <= 0 || compareVersion(upgrade.Version, pub.DbVersion) > 0 {
+			continue
+		}
+		isFinish, err = upgrade.apply()
+		if !isFinish || err != nil {
+			return
+		}
+		zap.L().Info("Database schema upgraded to version " + upgrade.Version + ": " + upgrade.Description)
+	}
+	// Record the application's database version
+	_, err = db.Exec("update sysinfo set dbversion=$1", pub.DbVersion)
+	if err != nil {
+		isFinish = false
+		zap.L().Error("upgradeDb db.Exec update dbversion failed:", zap.Error(err))
 	}
+	return
+}
 
+// Apply a database schema upgrade
+func (u *dbUpgrade) apply() (isFinish bool, err error) {
+	isFinish = true
+	// Step 1: Create the tables added in this version
+	var rowNum int
+	for _, table := range tables {
+		if table.AddFromVersion != u.Version {
+			continue
+		}
+		sqlStr := "select count(tablename) from pg_tables where tablename=$1"
+		err = db.QueryRow(sqlStr, table.TableName).Scan(&rowNum)
+		if err != nil {
+			isFinish = false
+			zap.L().Error("dbUpgrade.apply check table "+table.TableName+" exist failed", zap.Error(err))
+			return
+		}
+		if rowNum > 0 {
+			continue
+		}
+		_, err = db.Exec(table.CreateSQL)
+		if err != nil {
+			isFinish = false
+			zap.L().Error("dbUpgrade.apply create table "+table.TableName+" failed", zap.Error(err))
+			return
+		}
+		isFinish, err = table.InitFunc()
+		if !isFinish || err != nil {
+			zap.L().Error("dbUpgrade.apply initialize table "+table.TableName+" failed", zap.Error(err))
+			return
+		}
+	}
+	// Step 2: Modify the existing tables in a transaction
+	tx, err := db.Begin()
+	if err != nil {
+		isFinish = false
+		zap.L().Error("dbUpgrade.apply db.Begin failed", zap.Error(err))
+		return
+	}
+	for _, sqlStr := range u.UpgradeSQL {
+		_, err = tx.Exec(sqlStr)
+		if err != nil {
+			isFinish = false
+			zap.L().Error("dbUpgrade.apply tx.Exec failed: "+sqlStr, zap.Error(err))
+			_ = tx.Rollback()
+			return
+		}
+	}
+	// Step 3: Record the upgraded version
+	_, err = tx.Exec("update sysinfo set dbversion=$1", u.Version)
+	if err != nil {
+		isFinish = false
+		zap.L().Error("dbUpgrade.apply tx.Exec update dbversion failed", zap.Error(err))
+		_ = tx.Rollback()
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		isFinish = false
+		zap.L().Error("dbUpgrade.apply tx.Commit failed", zap.Error(err))
+	}
 	return
 }
+
+// Compare two dotted version numbers.
+// Returns -1 if a < b, 0 if a == b and 1 if a > b.
+func compareVersion(a string, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var an, bn int
+		if i < len(as) {
+			an, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			bn, _ = strconv.Atoi(bs[i])
+		}
+		if an < bn {
+			return -1
+		}
+		if an > bn {
+			return 1
+		}
+	}
+	return 0
+}
@@ -310,7 +310,7 @@ func (dc *DC) CheckNameExist() (resStatus i18n.ResKey, err error) {
 }
 
 // Add Document Category
-func (dc *DC) Add() (resStatus i18n.ResKey, err error) {
+func (dc *DC) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the DC name exists
 	resStatus, err = dc.CheckNameExist()
@@ -318,20 +318,23 @@ func (dc *DC) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Inser record into the dc table
-	sqlStr := `insert into dc(name,description,fatherid,status,creatorid)
-	values($1,$2,$3,$4,$5)
-	returning id`
-	err = db.QueryRow(sqlStr, dc.Name, dc.Description, dc.Father.ID, dc.Status, dc.Creator.ID).Scan(&dc.ID)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("DC.Add stmt.QueryRow failed", zap.Error(err))
+	return writeAudited(actor, pub.DC, AuditActionAdd, &dc.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `insert into dc(name,description,fatherid,status,creatorid)
+		values($1,$2,$3,$4,$5)
+		returning id`
+		err = tx.QueryRow(sqlStr, dc.Name, dc.Description, dc.Father.ID, dc.Status, dc.Creator.ID).Scan(&dc.ID)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("DC.Add stmt.QueryRow failed", zap.Error(err))
+			return
+		}
 		return
-	}
-	return
+	})
 }
 
 // Edit Document Category
-func (dc *DC) Edit() (resStatus i18n.ResKey, err error) {
+func (dc *DC) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check whether the superior category is compliant
 	// If the superior category ID is great than 0,
@@ -365,34 +368,37 @@ func (dc *DC) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the record in the dc table
-	sqlStr := `update dc set
-	name=$1,description=$2,fatherid=$3,status=$4,
-	modifytime=current_timestamp,modifierid=$5,ts=current_timestamp
-	where id=$6 and dr=0 and ts=$7`
-	res, err := db.Exec(sqlStr, dc.Name, dc.Description, dc.Father.ID, dc.Status,
-		dc.Modifier.ID, dc.ID, dc.Ts)
-	if err != nil {
-		zap.L().Error("DC.Edit db.Exec failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// Get the number of rows affected by SQL update operation.
-	affected, err := res.RowsAffected()
-	if err != nil {
-		zap.L().Error("DC.Edit res.RowsAffected failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// If the number of affected rows is less than one,
-	// it means that someone else has already modified the record.
-	if affected < 1 {
-		resStatus = i18n.StatusOtherEdit
-		return
-	}
-	// Delete from cache
-	dc.DelFromLocalCache()
+	return writeAudited(actor, pub.DC, AuditActionEdit, &dc.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update dc set
+		name=$1,description=$2,fatherid=$3,status=$4,
+		modifytime=current_timestamp,modifierid=$5,ts=current_timestamp
+		where id=$6 and dr=0 and ts=$7`
+		res, err := tx.Exec(sqlStr, dc.Name, dc.Description, dc.Father.ID, dc.Status,
+			dc.Modifier.ID, dc.ID, dc.Ts)
+		if err != nil {
+			zap.L().Error("DC.Edit tx.Exec failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// Get the number of rows affected by SQL update operation.
+		affected, err := res.RowsAffected()
+		if err != nil {
+			zap.L().Error("DC.Edit res.RowsAffected failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// If the number of affected rows is less than one,
+		// it means that someone else has already modified the record.
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		// Delete from cache
+		dc.DelFromLocalCache()
 
-	return
+		return
+	})
 }
 
 // Check if the DC id is referenced
@@ -429,7 +435,7 @@ func (dc *DC) CheckIsUsed() (resStatus i18n.ResKey, err error) {
 }
 
 // Delete Document Category
-func (dc *DC) Delete() (resStatus i18n.ResKey, err error) {
+func (dc *DC) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the category is referenced
 	resStatus, err = dc.CheckIsUsed()
@@ -437,29 +443,32 @@ func (dc *DC) Delete() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the record in the dc table
-	sqlStr := `update dc set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp where id=$2 and dr=0 and ts=$3`
-	res, err := db.Exec(sqlStr, dc.Modifier.ID, dc.ID, dc.Ts)
-	if err != nil {
-		zap.L().Error("DC.Delete stmt.Exec failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// Check the number of rows affected by SQL update operation.
-	affected, err := res.RowsAffected()
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("DC.Delete res.RowsAffedted failed", zap.Error(err))
-		return
-	}
+	return writeAudited(actor, pub.DC, AuditActionDelete, &dc.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update dc set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp where id=$2 and dr=0 and ts=$3`
+		res, err := tx.Exec(sqlStr, dc.Modifier.ID, dc.ID, dc.Ts)
+		if err != nil {
+			zap.L().Error("DC.Delete stmt.Exec failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// Check the number of rows affected by SQL update operation.
+		affected, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("DC.Delete res.RowsAffedted failed", zap.Error(err))
+			return
+		}
 
-	if affected < 1 {
-		resStatus = i18n.StatusOtherEdit
-		zap.L().Info("DC.Delete Other User Edit")
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+			zap.L().Info("DC.Delete Other User Edit")
+			return
+		}
+		// Delete from cache
+		dc.DelFromLocalCache()
 		return
-	}
-	// Delete from cache
-	dc.DelFromLocalCache()
-	return
+	})
 }
 
 // Delete DC from cache
@@ -471,7 +480,7 @@ func (dc *DC) DelFromLocalCache() {
 }
 
 // Batch Delete Document Category
-func DeleteDCs(dcs *[]DC, modifyUserID int32) (resStatus i18n.ResKey, err error) {
+func DeleteDCs(dcs *[]DC, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Begin a database transaction
 	tx, err := db.Begin()
@@ -481,6 +490,17 @@ func DeleteDCs(dcs *[]DC, modifyUserID int32) (resStatus i18n.ResKey, err error)
 		return
 	}
 	defer tx.Commit()
+	// Lock the categories and take their state for the audit trail
+	ids := make([]int32, 0, len(*dcs))
+	for _, item := range *dcs {
+		ids = append(ids, item.ID)
+	}
+	at, err := beginAudit(tx, actor, pub.DC, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Prepare update SQL statement
 	delSqlStr := `update dc set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp where id=$2 and dr=0 and ts=$3`
 	stmt, err := tx.Prepare(delSqlStr)
@@ -501,7 +521,7 @@ func DeleteDCs(dcs *[]DC, modifyUserID int32) (resStatus i18n.ResKey, err error)
 		}
 
 		// Execute to update
-		res, err := stmt.Exec(modifyUserID, dc.ID, dc.Ts)
+		res, err := stmt.Exec(actor.UserID, dc.ID, dc.Ts)
 		if err != nil {
 			zap.L().Error("DeleteDCs stmt.Exec failed", zap.Error(err))
 			resStatus = i18n.StatusInternalError
@@ -527,6 +547,12 @@ func DeleteDCs(dcs *[]DC, modifyUserID int32) (resStatus i18n.ResKey, err error)
 		dc.DelFromLocalCache()
 	}
 
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
@@ -324,7 +324,7 @@ func (d *Department) CheckDeptCodeExist() (resStatus i18n.ResKey, err error) {
 }
 
 // Add department
-func (d *Department) AddDept() (resStatus i18n.ResKey, err error) {
+func (d *Department) AddDept(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the department code exists.
 	resStatus, err = d.CheckDeptCodeExist()
@@ -332,22 +332,25 @@ func (d *Department) AddDept() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Write a record to the department table
-	sqlStr := `insert into department(code,name,fatherid,leader,description,
-		status,createtime,creatorid) 
-		values($1,$2,$3,$4,$5,$6,now(),$7) 
-		returning id`
-	err = db.QueryRow(sqlStr, d.Code, d.Name, d.FatherID.ID, d.Leader.ID, d.Description, d.Status, d.Creator.ID).Scan(&d.ID)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("department.AddDept stmt.QueryRow failed", zap.Error(err))
-		return
-	}
+	return writeAudited(actor, pub.Department, AuditActionAdd, &d.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `insert into department(code,name,fatherid,leader,description,
+			status,createtime,creatorid) 
+			values($1,$2,$3,$4,$5,$6,now(),$7) 
+			returning id`
+		err = tx.QueryRow(sqlStr, d.Code, d.Name, d.FatherID.ID, d.Leader.ID, d.Description, d.Status, d.Creator.ID).Scan(&d.ID)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("department.AddDept stmt.QueryRow failed", zap.Error(err))
+			return
+		}
 
-	return
+		return
+	})
 }
 
 // Edit Department
-func (dept *Department) Edit() (resStatus i18n.ResKey, err error) {
+func (dept *Department) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the parent department is compliant.
 	resStatus, err = dept.CheckFather()
@@ -360,31 +363,34 @@ func (dept *Department) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the record in the department table
-	sqlStr := `update department set code=$1,name=$2,fatherid=$3,leader=$4,description=$5,
-	status=$6,modifierid=$7,modifytime=now(),ts=current_timestamp where id = $8 and ts = $9`
-	res, err := db.Exec(sqlStr, dept.Code, dept.Name, dept.FatherID.ID, dept.Leader.ID, dept.Description,
-		dept.Status, dept.Modifier.ID, dept.ID, dept.Ts)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("Department.Edit stmt.exec failed", zap.Error(err))
-		return
-	}
-	updateNumber, err := res.RowsAffected()
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("Department.Edit res.RowsAffected falied", zap.Error(err))
-		return
-	}
+	return writeAudited(actor, pub.Department, AuditActionEdit, &dept.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update department set code=$1,name=$2,fatherid=$3,leader=$4,description=$5,
+		status=$6,modifierid=$7,modifytime=now(),ts=current_timestamp where id = $8 and ts = $9`
+		res, err := tx.Exec(sqlStr, dept.Code, dept.Name, dept.FatherID.ID, dept.Leader.ID, dept.Description,
+			dept.Status, dept.Modifier.ID, dept.ID, dept.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("Department.Edit stmt.exec failed", zap.Error(err))
+			return
+		}
+		updateNumber, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("Department.Edit res.RowsAffected falied", zap.Error(err))
+			return
+		}
 
-	if updateNumber < 1 {
-		resStatus = i18n.StatusOtherEdit
-		return
-	}
+		if updateNumber < 1 {
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
 
-	// Delete from local cache
-	dept.DelFromLocalCache()
+		// Delete from local cache
+		dept.DelFromLocalCache()
 
-	return
+		return
+	})
 }
 
 // Check if the parent department is compliant.
@@ -434,7 +440,7 @@ func FindSimpDeptChildrens(arr []SimpDept, id int32) []SimpDept {
 }
 
 // Delete department
-func (dept *Department) Delete() (resStatus i18n.ResKey, err error) {
+func (dept *Department) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the department ID redrenced.
 	resStatus, err = dept.CheckIsUsed()
@@ -442,35 +448,38 @@ func (dept *Department) Delete() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the department table with a deletion flag.
-	sqlStr := `update department set dr=1,modifytime=now(),modifierid=$1,ts=current_timestamp 
-	where id=$2 and dr=0 and ts=$3`
-	res, err := db.Exec(sqlStr, dept.Modifier.ID, dept.ID, dept.Ts)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("Department.DelDept stmt.exec failed", zap.Error(err))
-		return
-	}
-	// Check the number of rows affected by the SQL update operation.
-	affected, err := res.RowsAffected()
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("Department.DelDept check RowsAffected failed", zap.Error(err))
-		return
-	}
-	if affected < 1 {
-		resStatus = i18n.StatusOtherEdit
-		zap.L().Info("Department.DelDept other edit")
-		return
-	}
+	return writeAudited(actor, pub.Department, AuditActionDelete, &dept.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update department set dr=1,modifytime=now(),modifierid=$1,ts=current_timestamp 
+		where id=$2 and dr=0 and ts=$3`
+		res, err := tx.Exec(sqlStr, dept.Modifier.ID, dept.ID, dept.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("Department.DelDept stmt.exec failed", zap.Error(err))
+			return
+		}
+		// Check the number of rows affected by the SQL update operation.
+		affected, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("Department.DelDept check RowsAffected failed", zap.Error(err))
+			return
+		}
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+			zap.L().Info("Department.DelDept other edit")
+			return
+		}
 
-	// Delete from local cache
-	dept.DelFromLocalCache()
+		// Delete from local cache
+		dept.DelFromLocalCache()
 
-	return
+		return
+	})
 }
 
 // Batch delete department
-func DeleteDepts(depts *[]Department, modifyUserid int32) (resStatus i18n.ResKey, err error) {
+func DeleteDepts(depts *[]Department, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Begin a database transaction.
 	tx, err := db.Begin()
@@ -480,6 +489,17 @@ func DeleteDepts(depts *[]Department, modifyUserid int32) (resStatus i18n.ResKey
 		return
 	}
 	defer tx.Commit()
+	// Lock the departments and take their state for the audit trail
+	ids := make([]int32, 0, len(*depts))
+	for _, item := range *depts {
+		ids = append(ids, item.ID)
+	}
+	at, err := beginAudit(tx, actor, pub.Department, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Pre-processing for update the department table deletion flag.
 	delSqlStr := `update department set dr=1,modifytime=now(),modifierid=$1,ts=current_timestamp 
 	where id=$2 and dr=0 and ts=$3`
@@ -501,7 +521,7 @@ func DeleteDepts(depts *[]Department, modifyUserid int32) (resStatus i18n.ResKey
 		}
 
 		// Update the department table with a deletction flag.
-		res, err := stmt.Exec(modifyUserid, dept.ID, dept.Ts)
+		res, err := stmt.Exec(actor.UserID, dept.ID, dept.Ts)
 		if err != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("DeleteDepts stmt.exec failed", zap.Error(err))
@@ -526,6 +546,12 @@ func DeleteDepts(depts *[]Department, modifyUserid int32) (resStatus i18n.ResKey
 		dept.DelFromLocalCache()
 	}
 
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
@@ -618,6 +644,16 @@ func (dept *Department) CheckIsUsed() (resStatus i18n.ResKey, err error) {
 			SqlStr:         `select count(id) from ppeissuanceform_h where deptid=$1 and dr=0`,
 			UsedReturnCode: i18n.StatusPPEIFDeptUsed,
 		},
+		{
+			Description:    "Referenced by approval flow department",
+			SqlStr:         `select count(id) from sysapprovalflow where deptid=$1 and dr=0`,
+			UsedReturnCode: i18n.StatusApprovalFlowUsed,
+		},
+		{
+			Description:    "Referenced by Work Order schedule department",
+			SqlStr:         `select count(id) from woschedule where deptid=$1 and dr=0`,
+			UsedReturnCode: i18n.StatusWOScheduleUsed,
+		},
 	}
 
 	// Check item by item
@@ -5,6 +5,7 @@ import (
 	"math"
 	"sccsmsserver/cache"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/filter"
 	"sccsmsserver/pub"
 	"sccsmsserver/setting"
 	"strings"
@@ -220,7 +221,7 @@ func (d *Document) GetDetailByID() (resStatus i18n.ResKey, err error) {
 }
 
 // Add Document
-func (d *Document) Add() (resStatus i18n.ResKey, err error) {
+func (d *Document) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check the number of files, zero is not allowed
 	if len(d.Files) == 0 {
@@ -235,6 +236,13 @@ func (d *Document) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Start the audit trail
+	at, err := beginAudit(tx, actor, pub.Document, AuditActionAdd)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Insert document record into the document table
 	headSql := `insert into document(dcid,name,edition,author,releasedate,
 		tags,description,creatorid) 
@@ -269,11 +277,17 @@ func (d *Document) Add() (resStatus i18n.ResKey, err error) {
 			return
 		}
 	}
+	// Write the audit trail
+	err = at.write(d.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Edit Document
-func (d *Document) Edit() (resStatus i18n.ResKey, err error) {
+func (d *Document) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if  creator and modifier are the same person
 	if d.Creator.ID != d.Modifier.ID {
@@ -299,6 +313,13 @@ func (d *Document) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.Document, AuditActionEdit, d.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Modify the Document info in the document table
 	editDocSql := `update document set dcid=$1,name=$2,edition=$3,author=$4,releasedate=$5,
@@ -383,6 +404,12 @@ func (d *Document) Edit() (resStatus i18n.ResKey, err error) {
 	}
 	// Delete from cache
 	d.DelFromLocalCache()
+	// Write the audit trail
+	err = at.write(d.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
@@ -395,11 +422,11 @@ func (d *Document) DelFromLocalCache() {
 }
 
 // Delete Document
-func (d *Document) Delete(modifyUserId int32) (resStatus i18n.ResKey, err error) {
+func (d *Document) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the Document is referenced
 	// Check the operator and creator are a same person
-	if d.Creator.ID != modifyUserId {
+	if d.Creator.ID != actor.UserID {
 		resStatus = i18n.StatusOtherEdit
 		return
 	}
@@ -411,10 +438,17 @@ func (d *Document) Delete(modifyUserId int32) (resStatus i18n.ResKey, err error)
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.Document, AuditActionDelete, d.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Modify the delete flag for the document in the document table
 	delDocSql := `update document set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp 
 		where id=$2 and dr=0 and ts=$3`
-	delDocRes, err := tx.Exec(delDocSql, modifyUserId, d.ID, d.Ts)
+	delDocRes, err := tx.Exec(delDocSql, actor.UserID, d.ID, d.Ts)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("Document.Delete tx.Exec(delDocSql) failed", zap.Error(err))
@@ -448,7 +482,7 @@ func (d *Document) Delete(modifyUserId int32) (resStatus i18n.ResKey, err error)
 	defer delFileStmt.Close()
 	// update the file delete flag one by one
 	for _, file := range d.Files {
-		delFileRes, errDelFile := delFileStmt.Exec(modifyUserId, file.ID, file.BillHID, file.Ts)
+		delFileRes, errDelFile := delFileStmt.Exec(actor.UserID, file.ID, file.BillHID, file.Ts)
 		if errDelFile != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("Document.Delete delFileStmt.Exec failed", zap.Error(errDelFile))
@@ -471,11 +505,17 @@ func (d *Document) Delete(modifyUserId int32) (resStatus i18n.ResKey, err error)
 	// Delete from cache
 	d.DelFromLocalCache()
 
+	// Write the audit trail
+	err = at.write(d.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Batche Delete Documents
-func DeleteDocuments(docs *[]Document, modifierID int32) (resStatus i18n.ResKey, err error) {
+func DeleteDocuments(docs *[]Document, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Begin a database transaction
 	tx, err := db.Begin()
@@ -485,6 +525,17 @@ func DeleteDocuments(docs *[]Document, modifierID int32) (resStatus i18n.ResKey,
 		return
 	}
 	defer tx.Commit()
+	// Lock the documents and take their state for the audit trail
+	ids := make([]int32, 0, len(*docs))
+	for _, item := range *docs {
+		ids = append(ids, item.ID)
+	}
+	at, err := beginAudit(tx, actor, pub.Document, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Prepare modify the delete flag in the document table
 	delDocSql := `update document set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp 
@@ -512,13 +563,13 @@ func DeleteDocuments(docs *[]Document, modifierID int32) (resStatus i18n.ResKey,
 	// Modify Document one by one
 	for _, d := range *docs {
 		// Check the creator and modifier are same person
-		if d.Creator.ID != modifierID {
+		if d.Creator.ID != actor.UserID {
 			resStatus = i18n.StatusOtherEdit
 			tx.Rollback()
 			return
 		}
 		// Modify record in the document table
-		delDocRes, errDelDoc := docStmt.Exec(modifierID, d.ID, d.Ts)
+		delDocRes, errDelDoc := docStmt.Exec(actor.UserID, d.ID, d.Ts)
 		if errDelDoc != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("DeleteDocuments docStmt.Exec failed", zap.Error(errDelDoc))
@@ -541,7 +592,7 @@ func DeleteDocuments(docs *[]Document, modifierID int32) (resStatus i18n.ResKey,
 
 		// Modify attachment record in the document_file table
 		for _, file := range d.Files {
-			delFileRes, errDelFile := fileStmt.Exec(modifierID, file.ID, file.BillHID, file.Ts)
+			delFileRes, errDelFile := fileStmt.Exec(actor.UserID, file.ID, file.BillHID, file.Ts)
 			if errDelFile != nil {
 				resStatus = i18n.StatusInternalError
 				zap.L().Error("DeleteDocuments fileStmt.Exec failed", zap.Error(errDelFile))
@@ -565,13 +616,40 @@ func DeleteDocuments(docs *[]Document, modifierID int32) (resStatus i18n.ResKey,
 		d.DelFromLocalCache()
 	}
 
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
+// Filterable fields of the Document report
+var documentReportFields = filter.Fields{
+	"name":        {Column: "d.name", Type: filter.String},
+	"edition":     {Column: "d.edition", Type: filter.String},
+	"author":      {Column: "d.author", Type: filter.String},
+	"uploadDate":  {Column: "d.uploaddate", Type: filter.Time},
+	"releaseDate": {Column: "d.releasedate", Type: filter.Time},
+	"tags":        {Column: "d.tags", Type: filter.String},
+	"description": {Column: "d.description", Type: filter.String},
+	"dcID":        {Column: "d.dcid", Type: filter.Number},
+	"dcName":      {Column: "dc.name", Type: filter.String},
+	"creatorID":   {Column: "d.creatorid", Type: filter.Number},
+	"creatorName": {Column: "creator.name", Type: filter.String},
+	"createDate":  {Column: "d.createtime", Type: filter.Time},
+}
+
 // Get the document Report
-func GetQueryDocumentReport(queryString string) (qds []QueryDocument, resStatus i18n.ResKey, err error) {
+func GetQueryDocumentReport(queryFilter filter.Group) (qds []QueryDocument, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	qds = make([]QueryDocument, 0)
+	// Compile the query filter
+	whereSql, args, resStatus := compileFilter(queryFilter, documentReportFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Concatenate the SQL string for check
 	build.WriteString(`select count(d.id) as rowcount
@@ -579,15 +657,15 @@ func GetQueryDocumentReport(queryString string) (qds []QueryDocument, resStatus
 	left join dc on d.dcid = dc.id
 	left join sysuser as creator on d.creatorid = creator.id
 	where d.dr=0 `)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 	// Check
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, args...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetQueryDocumentReport db.QueryRow(checkSql) failed", zap.Error(err))
@@ -621,14 +699,14 @@ func GetQueryDocumentReport(queryString string) (qds []QueryDocument, resStatus
 	left join dc on d.dcid = dc.id
 	left join sysuser as creator on d.creatorid = creator.id
 	where d.dr=0`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	repSql := build.String()
 	// Retrieve Document report from database
-	qdRep, err := db.Query(repSql)
+	qdRep, err := db.Query(repSql, args...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetQueryDocumentReport db.Query failed", zap.Error(err))
@@ -32,6 +32,7 @@ type ExecutionProject struct {
 	IsCheckError     int16              `db:"ischeckerror" json:"isCheckError"`
 	ErrorValue       string             `db:"errorvalue" json:"errorValue"`
 	ErrorValueDisp   string             `db:"errorvaluedisp" json:"errorValueDisp"`
+	ErrorRules       ErrorRules         `db:"errorrules" json:"errorRules"`
 	IsRequireFile    int16              `db:"isrequirefile" json:"isRequireFile"`
 	IsOnsitePhoto    int16              `db:"isonsitephoto" json:"isOnSitePhoto"`
 	RiskLevel        RiskLevel          `db:"risklevelid" json:"riskLevel"`
@@ -62,7 +63,7 @@ func GetEPList() (epList []ExecutionProject, resStatus i18n.ResKey, err error) {
 	status,resulttypeid,udcid,defaultvalue,defaultvaluedisp,
 	ischeckerror,errorvalue,errorvaluedisp,isrequirefile,isonsitephoto,
 	risklevelid,createtime,creatorid,modifytime,modifierid,
-	ts,dr 
+	ts,dr,errorrules 
 	from epa 
 	where dr=0 order by ts desc`
 
@@ -79,7 +80,7 @@ func GetEPList() (epList []ExecutionProject, resStatus i18n.ResKey, err error) {
 			&ep.Status, &ep.ResultType.ID, &ep.UDC.ID, &ep.DefaultValue, &ep.DefaultValueDisp,
 			&ep.IsCheckError, &ep.ErrorValue, &ep.ErrorValueDisp, &ep.IsRequireFile, &ep.IsOnsitePhoto,
 			&ep.RiskLevel.ID, &ep.CreateDate, &ep.Creator.ID, &ep.ModifyDate, &ep.Modifier.ID,
-			&ep.Ts, &ep.Dr)
+			&ep.Ts, &ep.Dr, &ep.ErrorRules)
 		if err != nil {
 			zap.L().Error("GetEPList rows.Next failed", zap.Error(err))
 			resStatus = i18n.StatusInternalError
@@ -111,6 +112,11 @@ func GetEPList() (epList []ExecutionProject, resStatus i18n.ResKey, err error) {
 				return
 			}
 		}
+		// Get Risk Level detail of the error rules
+		resStatus, err = ep.ErrorRules.fillDetail()
+		if err != nil || resStatus != i18n.StatusOK {
+			return
+		}
 		// Get Creator detail
 		if ep.Creator.ID > 0 {
 			resStatus, err = ep.Creator.GetPersonInfoByID()
@@ -157,7 +163,7 @@ func (epac *EPCache) GetEPCache() (resStatus i18n.ResKey, err error) {
 	status,resulttypeid,udcid,defaultvalue,defaultvaluedisp,
 	ischeckerror,errorvalue,errorvaluedisp,isrequirefile,isonsitephoto,
 	risklevelid,createtime,creatorid,modifytime,modifierid,
-	ts,dr 
+	ts,dr,errorrules 
 	from epa 
 	where ts > $1 order by ts desc`
 	rows, err := db.Query(sqlStr, epac.QueryTs)
@@ -174,7 +180,7 @@ func (epac *EPCache) GetEPCache() (resStatus i18n.ResKey, err error) {
 			&ep.Status, &ep.ResultType.ID, &ep.UDC.ID, &ep.DefaultValue, &ep.DefaultValueDisp,
 			&ep.IsCheckError, &ep.ErrorValue, &ep.ErrorValueDisp, &ep.IsRequireFile, &ep.IsOnsitePhoto,
 			&ep.RiskLevel.ID, &ep.CreateDate, &ep.Creator.ID, &ep.ModifyDate, &ep.Modifier.ID,
-			&ep.Ts, &ep.Dr)
+			&ep.Ts, &ep.Dr, &ep.ErrorRules)
 		if err != nil {
 			zap.L().Error("GetEPCache rows.Next failed", zap.Error(err))
 			resStatus = i18n.StatusInternalError
@@ -206,6 +212,11 @@ func (epac *EPCache) GetEPCache() (resStatus i18n.ResKey, err error) {
 				return
 			}
 		}
+		// Get Risk Level detail of the error rules
+		resStatus, err = ep.ErrorRules.fillDetail()
+		if err != nil || resStatus != i18n.StatusOK {
+			return
+		}
 		// Get creator detail
 		if ep.Creator.ID > 0 {
 			resStatus, err = ep.Creator.GetPersonInfoByID()
@@ -241,41 +252,54 @@ func (epac *EPCache) GetEPCache() (resStatus i18n.ResKey, err error) {
 }
 
 // Add Execution Project master data
-func (ep *ExecutionProject) Add() (resStatus i18n.ResKey, err error) {
+func (ep *ExecutionProject) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the EP code exists
 	resStatus, err = ep.CheckCodeExist()
 	if resStatus != i18n.StatusOK || err != nil {
 		return
 	}
-	// Add data to the epa table
-	sqlStr := `insert into epa(code,name,epcid,description,status,
-	resulttypeid,udcid,defaultvalue,defaultvaluedisp,ischeckerror,
-	errorvalue,errorvaluedisp,isrequirefile,isonsitephoto,risklevelid,
-	creatorid)
-	values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)
-	returning id`
-	err = db.QueryRow(sqlStr, ep.Code, ep.Name, ep.EPC.ID, ep.Description, ep.Status,
-		ep.ResultType.ID, ep.UDC.ID, ep.DefaultValue, ep.DefaultValueDisp, ep.IsCheckError,
-		ep.ErrorValue, ep.ErrorValueDisp, ep.IsRequireFile, ep.IsOnsitePhoto, ep.RiskLevel.ID,
-		ep.Creator.ID).Scan(&ep.ID)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("ExecutionProject.Add db.QueryRow failed", zap.Error(err))
+	// Check the error rules
+	resStatus, err = ep.ErrorRules.check()
+	if resStatus != i18n.StatusOK || err != nil {
 		return
 	}
+	// Add data to the epa table
+	return writeAudited(actor, pub.EPA, AuditActionAdd, &ep.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `insert into epa(code,name,epcid,description,status,
+		resulttypeid,udcid,defaultvalue,defaultvaluedisp,ischeckerror,
+		errorvalue,errorvaluedisp,isrequirefile,isonsitephoto,risklevelid,
+		creatorid,errorrules)
+		values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17)
+		returning id`
+		err = tx.QueryRow(sqlStr, ep.Code, ep.Name, ep.EPC.ID, ep.Description, ep.Status,
+			ep.ResultType.ID, ep.UDC.ID, ep.DefaultValue, ep.DefaultValueDisp, ep.IsCheckError,
+			ep.ErrorValue, ep.ErrorValueDisp, ep.IsRequireFile, ep.IsOnsitePhoto, ep.RiskLevel.ID,
+			ep.Creator.ID, ep.ErrorRules).Scan(&ep.ID)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("ExecutionProject.Add tx.QueryRow failed", zap.Error(err))
+			return
+		}
 
-	return
+		return
+	})
 }
 
 // Edit Execution Project master data
-func (ep *ExecutionProject) Edit() (resStatus i18n.ResKey, err error) {
+func (ep *ExecutionProject) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the EP code exists
 	resStatus, err = ep.CheckCodeExist()
 	if resStatus != i18n.StatusOK || err != nil {
 		return
 	}
+	// Check the error rules
+	resStatus, err = ep.ErrorRules.check()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 	// Check if the EP id is referenced
 	var isUsed bool = false
 	statusIsUsed, err := ep.CheckUsed()
@@ -301,38 +325,41 @@ func (ep *ExecutionProject) Edit() (resStatus i18n.ResKey, err error) {
 	}
 
 	// Modify record in the epa table
-	sqlStr := `update epa set code=$1,name=$2,epcid=$3,description=$4,status=$5,
-	resulttypeid=$6,udcid=$7,defaultvalue=$8,defaultvaluedisp=$9,ischeckerror=$10,
-	errorvalue=$11,errorvaluedisp=$12,isrequirefile=$13,isonsitephoto=$14,risklevelid=$15,
-	modifytime=current_timestamp,modifierid=$16,ts=current_timestamp
-	where id=$17 and dr = 0 and ts=$18`
-	res, err := db.Exec(sqlStr, ep.Code, ep.Name, ep.EPC.ID, ep.Description, ep.Status,
-		ep.ResultType.ID, ep.UDC.ID, ep.DefaultValue, ep.DefaultValueDisp, ep.IsCheckError,
-		ep.ErrorValue, ep.ErrorValueDisp, ep.IsRequireFile, ep.IsOnsitePhoto, ep.RiskLevel.ID,
-		ep.Modifier.ID,
-		ep.ID, ep.Ts)
-	if err != nil {
-		zap.L().Error("ExecutionProject.Edit db.Exec failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// Check if the number of rows affected by SQL update operation
-	updateNumber, err := res.RowsAffected()
-	if err != nil {
-		zap.L().Error("ExecutionProject.Edit res.RowsAffected failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// If the number of affected rows is less than 1.
-	// it means someone else already modified the record.
-	if updateNumber < 1 {
-		resStatus = i18n.StatusOtherEdit
-		return
-	}
-	// Delete from cache
-	ep.DelFromLocalCache()
+	return writeAudited(actor, pub.EPA, AuditActionEdit, &ep.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update epa set code=$1,name=$2,epcid=$3,description=$4,status=$5,
+		resulttypeid=$6,udcid=$7,defaultvalue=$8,defaultvaluedisp=$9,ischeckerror=$10,
+		errorvalue=$11,errorvaluedisp=$12,isrequirefile=$13,isonsitephoto=$14,risklevelid=$15,
+		modifytime=current_timestamp,modifierid=$16,ts=current_timestamp,errorrules=$19
+		where id=$17 and dr = 0 and ts=$18`
+		res, err := tx.Exec(sqlStr, ep.Code, ep.Name, ep.EPC.ID, ep.Description, ep.Status,
+			ep.ResultType.ID, ep.UDC.ID, ep.DefaultValue, ep.DefaultValueDisp, ep.IsCheckError,
+			ep.ErrorValue, ep.ErrorValueDisp, ep.IsRequireFile, ep.IsOnsitePhoto, ep.RiskLevel.ID,
+			ep.Modifier.ID,
+			ep.ID, ep.Ts, ep.ErrorRules)
+		if err != nil {
+			zap.L().Error("ExecutionProject.Edit tx.Exec failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// Check if the number of rows affected by SQL update operation
+		updateNumber, err := res.RowsAffected()
+		if err != nil {
+			zap.L().Error("ExecutionProject.Edit res.RowsAffected failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// If the number of affected rows is less than 1.
+		// it means someone else already modified the record.
+		if updateNumber < 1 {
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		// Delete from cache
+		ep.DelFromLocalCache()
 
-	return
+		return
+	})
 }
 
 // Get ScDataType Detail by id
@@ -376,7 +403,7 @@ func (ep *ExecutionProject) CheckCodeExist() (resStatus i18n.ResKey, err error)
 }
 
 // Delete Execution Project Master Data
-func (ep *ExecutionProject) Delete() (resStatus i18n.ResKey, err error) {
+func (ep *ExecutionProject) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the EP is refrenced
 	resStatus, err = ep.CheckUsed()
@@ -384,34 +411,37 @@ func (ep *ExecutionProject) Delete() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the delete flag of this EP in the epa table
-	sqlStr := `update epa set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp where id=$2 and dr=0 and ts=$3`
-	res, err := db.Exec(sqlStr, ep.Modifier.ID, ep.ID, ep.Ts)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("ExecutionProject.Delete db.Exec failed", zap.Error(err))
-		return
-	}
-	// Check the number of rows affected by SQL udpate operation
-	affected, err := res.RowsAffected()
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("ExecutionProject.Delete res.RowsAffected failed", zap.Error(err))
-		return
-	}
-	// If the number of affected rows if less than 1,
-	// it means sonmeone else already modified this record.
-	if affected < 1 {
-		resStatus = i18n.StatusOtherEdit
-		zap.L().Info("ExectiveItemClass.Delete Other user edit")
+	return writeAudited(actor, pub.EPA, AuditActionDelete, &ep.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update epa set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp where id=$2 and dr=0 and ts=$3`
+		res, err := tx.Exec(sqlStr, ep.Modifier.ID, ep.ID, ep.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("ExecutionProject.Delete tx.Exec failed", zap.Error(err))
+			return
+		}
+		// Check the number of rows affected by SQL udpate operation
+		affected, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("ExecutionProject.Delete res.RowsAffected failed", zap.Error(err))
+			return
+		}
+		// If the number of affected rows if less than 1,
+		// it means sonmeone else already modified this record.
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+			zap.L().Info("ExectiveItemClass.Delete Other user edit")
+			return
+		}
+		// Delete from cache
+		ep.DelFromLocalCache()
 		return
-	}
-	// Delete from cache
-	ep.DelFromLocalCache()
-	return
+	})
 }
 
 // Batch delete Execution Project master data
-func DeleteEPs(epas *[]ExecutionProject, modifyUserID int32) (resStatus i18n.ResKey, err error) {
+func DeleteEPs(epas *[]ExecutionProject, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Begin a database transaction
 	tx, err := db.Begin()
@@ -421,6 +451,17 @@ func DeleteEPs(epas *[]ExecutionProject, modifyUserID int32) (resStatus i18n.Res
 		return
 	}
 	defer tx.Commit()
+	// Lock the Execution Projects and take their state for the audit trail
+	ids := make([]int32, 0, len(*epas))
+	for _, item := range *epas {
+		ids = append(ids, item.ID)
+	}
+	at, err := beginAudit(tx, actor, pub.EPA, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Prepare a SQL statement execution
 	delSqlStr := `update epa set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp where id=$2 and dr=0 and ts=$3`
@@ -441,7 +482,7 @@ func DeleteEPs(epas *[]ExecutionProject, modifyUserID int32) (resStatus i18n.Res
 			return
 		}
 		// Update the delete flag of this EP in epa table
-		res, err := stmt.Exec(modifyUserID, ep.ID, ep.Ts)
+		res, err := stmt.Exec(actor.UserID, ep.ID, ep.Ts)
 		if err != nil {
 			zap.L().Error("DeleteEPs stmt.Exec failed", zap.Error(err))
 			resStatus = i18n.StatusInternalError
@@ -469,6 +510,12 @@ func DeleteEPs(epas *[]ExecutionProject, modifyUserID int32) (resStatus i18n.Res
 		ep.DelFromLocalCache()
 	}
 
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
@@ -485,12 +532,14 @@ func (ep *ExecutionProject) GetInfoByID() (resStatus i18n.ResKey, err error) {
 	sqlStr := `select code,name,epcid,description,status,
 	resulttypeid,udcid,defaultvalue,defaultvaluedisp,ischeckerror,
 	errorvalue,errorvaluedisp,isrequirefile,isonsitephoto,risklevelid,
-	createtime,creatorid,modifytime,modifierid,ts,dr
+	createtime,creatorid,modifytime,modifierid,ts,dr,
+	errorrules
 	from epa where id = $1`
 	err = db.QueryRow(sqlStr, ep.ID).Scan(&ep.Code, &ep.Name, &ep.EPC.ID, &ep.Description, &ep.Status,
 		&ep.ResultType.ID, &ep.UDC.ID, &ep.DefaultValue, &ep.DefaultValueDisp, &ep.IsCheckError,
 		&ep.ErrorValue, &ep.ErrorValueDisp, &ep.IsRequireFile, &ep.IsOnsitePhoto, &ep.RiskLevel.ID,
-		&ep.CreateDate, &ep.Creator.ID, &ep.ModifyDate, &ep.Modifier.ID, &ep.Ts, &ep.Dr)
+		&ep.CreateDate, &ep.Creator.ID, &ep.ModifyDate, &ep.Modifier.ID, &ep.Ts, &ep.Dr,
+		&ep.ErrorRules)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("ep.GetInfoByID db.QueryRow failed", zap.Error(err))
@@ -522,6 +571,11 @@ func (ep *ExecutionProject) GetInfoByID() (resStatus i18n.ResKey, err error) {
 			return
 		}
 	}
+	// Get Risk Level detail of the error rules
+	resStatus, err = ep.ErrorRules.fillDetail()
+	if err != nil || resStatus != i18n.StatusOK {
+		return
+	}
 	// Get Creator detail
 	if ep.Creator.ID > 0 {
 		resStatus, err = ep.Creator.GetPersonInfoByID()
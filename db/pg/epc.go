@@ -296,7 +296,7 @@ func (epc *EPC) CheckNameExist() (resStatus i18n.ResKey, err error) {
 }
 
 // Add Execution Project Archive
-func (epc *EPC) Add() (resStatus i18n.ResKey, err error) {
+func (epc *EPC) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the EPC name exists
 	resStatus, err = epc.CheckNameExist()
@@ -304,21 +304,24 @@ func (epc *EPC) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Write data into the epc table
-	sqlStr := `insert into epc(name,description,fatherid,status,creatorid)
-	values($1,$2,$3,$4,$5)
-	returning id`
-	err = db.QueryRow(sqlStr, epc.Name, epc.Description, epc.Father.ID, epc.Status, epc.Creator.ID).Scan(&epc.ID)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("EPC.Add stmt.QueryRow failed", zap.Error(err))
-		return
-	}
+	return writeAudited(actor, pub.EPC, AuditActionAdd, &epc.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `insert into epc(name,description,fatherid,status,creatorid)
+		values($1,$2,$3,$4,$5)
+		returning id`
+		err = tx.QueryRow(sqlStr, epc.Name, epc.Description, epc.Father.ID, epc.Status, epc.Creator.ID).Scan(&epc.ID)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("EPC.Add stmt.QueryRow failed", zap.Error(err))
+			return
+		}
 
-	return
+		return
+	})
 }
 
 // Edit Execution Project Archive
-func (epc *EPC) Edit() (resStatus i18n.ResKey, err error) {
+func (epc *EPC) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the parent EPC is compliant
 	if epc.Father.ID > 0 {
@@ -351,33 +354,36 @@ func (epc *EPC) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Write the updates to the epc table
-	sqlStr := `update epc set
-	name=$1,description=$2,fatherid=$3,status=$4,modifytime=current_timestamp,
-	modifierid=$5,ts=current_timestamp 
-	where id=$6 and dr=0 and ts=$7`
+	return writeAudited(actor, pub.EPC, AuditActionEdit, &epc.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update epc set
+		name=$1,description=$2,fatherid=$3,status=$4,modifytime=current_timestamp,
+		modifierid=$5,ts=current_timestamp 
+		where id=$6 and dr=0 and ts=$7`
 
-	res, err := db.Exec(sqlStr, epc.Name, epc.Description, epc.Father.ID, epc.Status,
-		epc.Modifier.ID, epc.ID, epc.Ts)
-	if err != nil {
-		zap.L().Error("EPC.Edit stmt.Exec failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// Check the number of affected rows by SQL update
-	updateNumber, err := res.RowsAffected()
-	if err != nil {
-		zap.L().Error("EPC.Edit res.RowsAffected failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
+		res, err := tx.Exec(sqlStr, epc.Name, epc.Description, epc.Father.ID, epc.Status,
+			epc.Modifier.ID, epc.ID, epc.Ts)
+		if err != nil {
+			zap.L().Error("EPC.Edit stmt.Exec failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// Check the number of affected rows by SQL update
+		updateNumber, err := res.RowsAffected()
+		if err != nil {
+			zap.L().Error("EPC.Edit res.RowsAffected failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
 
-	if updateNumber < 1 {
-		resStatus = i18n.StatusOtherEdit
+		if updateNumber < 1 {
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		// Delete from cache
+		epc.DelFromLocalCache()
 		return
-	}
-	// Delete from cache
-	epc.DelFromLocalCache()
-	return
+	})
 }
 
 // Check if the EPC ID is refrenced
@@ -444,7 +450,7 @@ func (epc *EPC) CheckIsUsed() (resStatus i18n.ResKey, err error) {
 }
 
 // Delete Execution Project Category
-func (epc *EPC) Delete() (resStatus i18n.ResKey, err error) {
+func (epc *EPC) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the EPC is refrenced
 	resStatus, err = epc.CheckIsUsed()
@@ -452,34 +458,37 @@ func (epc *EPC) Delete() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Write the updates to the epc table
-	sqlStr := `update epc set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp where id=$2 and dr=0 and ts=$3`
-	res, err := db.Exec(sqlStr, epc.Modifier.ID, epc.ID, epc.Ts)
-	if err != nil {
-		zap.L().Error("EPC.Delete stmt.Exec failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// Check the number of affected rows by SQL update
-	affected, err := res.RowsAffected()
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("EPC.Delete res.RowsAffedted failed", zap.Error(err))
-		return
-	}
+	return writeAudited(actor, pub.EPC, AuditActionDelete, &epc.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update epc set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp where id=$2 and dr=0 and ts=$3`
+		res, err := tx.Exec(sqlStr, epc.Modifier.ID, epc.ID, epc.Ts)
+		if err != nil {
+			zap.L().Error("EPC.Delete stmt.Exec failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// Check the number of affected rows by SQL update
+		affected, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("EPC.Delete res.RowsAffedted failed", zap.Error(err))
+			return
+		}
 
-	if affected < 1 {
-		resStatus = i18n.StatusOtherEdit
-		zap.L().Info("EPC.Delete Other User Edit")
-		return
-	}
-	// Delete from cache
-	epc.DelFromLocalCache()
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+			zap.L().Info("EPC.Delete Other User Edit")
+			return
+		}
+		// Delete from cache
+		epc.DelFromLocalCache()
 
-	return
+		return
+	})
 }
 
 // Batch delete EPCs
-func DeleteEPCs(epcs *[]EPC, modifyUserID int32) (resStatus i18n.ResKey, err error) {
+func DeleteEPCs(epcs *[]EPC, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Begin a database transaction
 	tx, err := db.Begin()
@@ -489,6 +498,17 @@ func DeleteEPCs(epcs *[]EPC, modifyUserID int32) (resStatus i18n.ResKey, err err
 		return
 	}
 	defer tx.Commit()
+	// Lock the categories and take their state for the audit trail
+	ids := make([]int32, 0, len(*epcs))
+	for _, item := range *epcs {
+		ids = append(ids, item.ID)
+	}
+	at, err := beginAudit(tx, actor, pub.EPC, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	delSqlStr := `update epc set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp where id=$2 and dr=0 and ts=$3`
 	stmt, err := tx.Prepare(delSqlStr)
 	if err != nil {
@@ -507,7 +527,7 @@ func DeleteEPCs(epcs *[]EPC, modifyUserID int32) (resStatus i18n.ResKey, err err
 			return
 		}
 		// Write delete flag to the epc table
-		res, err := stmt.Exec(modifyUserID, epc.ID, epc.Ts)
+		res, err := stmt.Exec(actor.UserID, epc.ID, epc.Ts)
 		if err != nil {
 			zap.L().Error("DeleteEPCs stmt.Exec failed", zap.Error(err))
 			resStatus = i18n.StatusInternalError
@@ -531,6 +551,12 @@ func DeleteEPCs(epcs *[]EPC, modifyUserID int32) (resStatus i18n.ResKey, err err
 		// Delete from cache
 		epc.DelFromLocalCache()
 	}
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
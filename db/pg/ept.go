@@ -3,6 +3,7 @@ package pg
 import (
 	"database/sql"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pub"
 	"time"
 
 	"go.uber.org/zap"
@@ -39,6 +40,7 @@ type EPTRow struct {
 	IsCheckError     int16            `db:"ischeckerror" json:"isCheckError"`
 	ErrorValue       string           `db:"errorvalue" json:"errorValue"`
 	ErrorValueDisp   string           `db:"errorvaluedisp" json:"errorValueDisp"`
+	ErrorRules       ErrorRules       `db:"errorrules" json:"errorRules"`
 	IsRequireFile    int16            `db:"isrequirefile" json:"isRequireFile"`
 	IsOnsitePhoto    int16            `db:"isonsitephoto" json:"isOnSitePhoto"`
 	RiskLevel        RiskLevel        `db:"risklevelid" json:"riskLevel"`
@@ -203,7 +205,8 @@ func getEPTBody(hid int32) (rows []EPTRow, resStatus i18n.ResKey, err error) {
 	bodySql := `select id,hid,rownumber,epaid,allowdelrow,
 	description,defaultvalue,defaultvaluedisp,ischeckerror,errorvalue,
 	errorvaluedisp,isrequirefile,isonsitephoto,risklevelid,createtime,
-	creatorid,modifytime,modifierid,dr,ts
+	creatorid,modifytime,modifierid,dr,ts,
+	errorrules
 	from ept_b where dr=0 and hid=$1 order by rownumber asc`
 	var bodyRowNumber = 0
 	bRows, err := db.Query(bodySql, hid)
@@ -220,7 +223,8 @@ func getEPTBody(hid int32) (rows []EPTRow, resStatus i18n.ResKey, err error) {
 		err = bRows.Scan(&eptRow.BID, &eptRow.HID, &eptRow.RowNumber, &eptRow.EP.ID, &eptRow.AllowDelRow,
 			&eptRow.Description, &eptRow.DefaultValue, &eptRow.DefaultValueDisp, &eptRow.IsCheckError, &eptRow.ErrorValue,
 			&eptRow.ErrorValueDisp, &eptRow.IsRequireFile, &eptRow.IsOnsitePhoto, &eptRow.RiskLevel.ID, &eptRow.CreateDate,
-			&eptRow.Creator.ID, &eptRow.ModifyDate, &eptRow.Modifier.ID, &eptRow.Dr, &eptRow.Ts)
+			&eptRow.Creator.ID, &eptRow.ModifyDate, &eptRow.Modifier.ID, &eptRow.Dr, &eptRow.Ts,
+			&eptRow.ErrorRules)
 		if err != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("GetEPTBody bRows.Next Scan EitRow failed", zap.Error(err))
@@ -241,6 +245,11 @@ func getEPTBody(hid int32) (rows []EPTRow, resStatus i18n.ResKey, err error) {
 				return
 			}
 		}
+		// Fill in risk level details of the error rules
+		resStatus, err = eptRow.ErrorRules.fillDetail()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
 		// Fill in creator details
 		if eptRow.Creator.ID > 0 {
 			resStatus, err = eptRow.Creator.GetPersonInfoByID()
@@ -321,7 +330,7 @@ func (ept *EPT) CheckCodeExist() (resStatus i18n.ResKey, err error) {
 }
 
 // Add a new Execution Project Template
-func (ept *EPT) Add() (resStatus i18n.ResKey, err error) {
+func (ept *EPT) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the code already exists
 	resStatus, err = ept.CheckCodeExist()
@@ -333,6 +342,11 @@ func (ept *EPT) Add() (resStatus i18n.ResKey, err error) {
 		resStatus = i18n.StatusVoucherNoBody
 		return
 	}
+	// Check the error rules of the rows
+	resStatus, err = ept.checkErrorRules()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 	// Create a transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -341,6 +355,13 @@ func (ept *EPT) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Start the audit trail
+	at, err := beginAudit(tx, actor, pub.EPT, AuditActionAdd)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Write header information into ept_h table
 	addHeaderSql := `insert into ept_h(code,name,description,
@@ -357,8 +378,8 @@ func (ept *EPT) Add() (resStatus i18n.ResKey, err error) {
 	// Prepare to write body rows into ept_b table
 	addBodySql := `insert into ept_b(hid,rownumber,epaid,allowdelrow,description,
 		defaultvalue,defaultvaluedisp,ischeckerror,errorvalue,errorvaluedisp,
-		isrequirefile,isonsitephoto,risklevelid,creatorid) 
-		values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14) 
+		isrequirefile,isonsitephoto,risklevelid,creatorid,errorrules) 
+		values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15) 
 		returning id`
 	bodyStmt, err := tx.Prepare(addBodySql)
 	if err != nil {
@@ -372,7 +393,7 @@ func (ept *EPT) Add() (resStatus i18n.ResKey, err error) {
 	for _, row := range ept.Body {
 		err = bodyStmt.QueryRow(ept.HID, row.RowNumber, row.EP.ID, row.AllowDelRow, row.Description,
 			row.DefaultValue, row.DefaultValueDisp, row.IsCheckError, row.ErrorValue, row.ErrorValueDisp,
-			row.IsRequireFile, row.IsOnsitePhoto, row.RiskLevel.ID, ept.Creator.ID).Scan(&row.BID)
+			row.IsRequireFile, row.IsOnsitePhoto, row.RiskLevel.ID, ept.Creator.ID, row.ErrorRules).Scan(&row.BID)
 		if err != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("EPT bodyStmt.QueryRow failed", zap.Error(err))
@@ -380,11 +401,17 @@ func (ept *EPT) Add() (resStatus i18n.ResKey, err error) {
 			return
 		}
 	}
+	// Write the audit trail
+	err = at.write(ept.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Modify an existing Execution Project Template
-func (ept *EPT) Edit() (resStatus i18n.ResKey, err error) {
+func (ept *EPT) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the code already exists
 	resStatus, err = ept.CheckCodeExist()
@@ -396,6 +423,11 @@ func (ept *EPT) Edit() (resStatus i18n.ResKey, err error) {
 		resStatus = i18n.StatusVoucherNoBody
 		return
 	}
+	// Check the error rules of the rows
+	resStatus, err = ept.checkErrorRules()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 	// Create a database transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -404,6 +436,13 @@ func (ept *EPT) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.EPT, AuditActionEdit, ept.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Modify header information in ept_h table
 	editHeadSql := `update ept_h set code=$1,name=$2, description=$3,status=$4,
 	allowaddrow=$5,allowdelrow=$6,modifytime=current_timestamp,modifierid=$7,ts=current_timestamp 
@@ -434,12 +473,13 @@ func (ept *EPT) Edit() (resStatus i18n.ResKey, err error) {
 	updateRowSql := `update ept_b set hid=$1,rownumber=$2,epaid=$3,allowdelrow=$4,description=$5,
 	defaultvalue=$6,defaultvaluedisp=$7,ischeckerror=$8,errorvalue=$9,errorvaluedisp=$10,
 	isrequirefile=$11,isonsitephoto=$12,risklevelid=$13,modifierid=$14,modifytime=current_timestamp,
-	ts=current_timestamp,dr=$15 
+	ts=current_timestamp,dr=$15,errorrules=$18 
 	where id=$16 and ts=$17 and dr=0`
 	addRowSql := `insert into ept_b(hid,rownumber,epaid,allowdelrow,description,
 		defaultvalue,defaultvaluedisp,ischeckerror,errorvalue,errorvaluedisp,
-		isrequirefile,isonsitephoto,risklevelid,creatorid,modifierid) 
-	values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15) 
+		isrequirefile,isonsitephoto,risklevelid,creatorid,modifierid,
+		errorrules) 
+	values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16) 
 	returning id`
 	// Prepare update statement
 	updateRowStmt, err := tx.Prepare(updateRowSql)
@@ -465,7 +505,8 @@ func (ept *EPT) Edit() (resStatus i18n.ResKey, err error) {
 		if eptRow.BID == 0 { // if BID is 0, it's a new row, need to add
 			errAddRow := addRowStmt.QueryRow(ept.HID, eptRow.RowNumber, eptRow.EP.ID, eptRow.AllowDelRow, eptRow.Description,
 				eptRow.DefaultValue, eptRow.DefaultValueDisp, eptRow.IsCheckError, eptRow.ErrorValue, eptRow.ErrorValueDisp,
-				eptRow.IsRequireFile, eptRow.IsOnsitePhoto, eptRow.RiskLevel.ID, ept.Modifier.ID, ept.Modifier.ID).Scan(&eptRow.BID)
+				eptRow.IsRequireFile, eptRow.IsOnsitePhoto, eptRow.RiskLevel.ID, ept.Modifier.ID, ept.Modifier.ID,
+				eptRow.ErrorRules).Scan(&eptRow.BID)
 			if errAddRow != nil {
 				zap.L().Error("EPT.Edit addRowStmt.QueryRow failed", zap.Error(errAddRow))
 				resStatus = i18n.StatusInternalError
@@ -477,7 +518,7 @@ func (ept *EPT) Edit() (resStatus i18n.ResKey, err error) {
 				eptRow.DefaultValue, eptRow.DefaultValueDisp, eptRow.IsCheckError, eptRow.ErrorValue, eptRow.ErrorValueDisp,
 				eptRow.IsRequireFile, eptRow.IsOnsitePhoto, eptRow.RiskLevel.ID, ept.Modifier.ID,
 				eptRow.Dr,
-				eptRow.BID, eptRow.Ts)
+				eptRow.BID, eptRow.Ts, eptRow.ErrorRules)
 			if errUpdate != nil {
 				zap.L().Error("EPT.Edit updateRowStmt.QueryRow failed", zap.Error(errUpdate))
 				resStatus = i18n.StatusInternalError
@@ -500,11 +541,17 @@ func (ept *EPT) Edit() (resStatus i18n.ResKey, err error) {
 			}
 		}
 	}
+	// Write the audit trail
+	err = at.write(ept.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Delete an existing Execution Project Template
-func (ept *EPT) Delete() (resStatus i18n.ResKey, err error) {
+func (ept *EPT) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the Execution Project Template is referenced by other documents
 	resStatus, err = ept.CheckUsed()
@@ -519,6 +566,13 @@ func (ept *EPT) Delete() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.EPT, AuditActionDelete, ept.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Update the header to mark it as deleted
 	delHeadSql := `update ept_h set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp
@@ -579,11 +633,17 @@ func (ept *EPT) Delete() (resStatus i18n.ResKey, err error) {
 			return
 		}
 	}
+	// Write the audit trail
+	err = at.write(ept.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Batch delete Execution Project Templates
-func DeleteEPTs(eits *[]EPT, modifyUserID int32) (resStatus i18n.ResKey, err error) {
+func DeleteEPTs(eits *[]EPT, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Begin a database transaction
 	tx, err := db.Begin()
@@ -593,6 +653,17 @@ func DeleteEPTs(eits *[]EPT, modifyUserID int32) (resStatus i18n.ResKey, err err
 		return
 	}
 	defer tx.Commit()
+	// Lock the templates and take their state for the audit trail
+	ids := make([]int32, 0, len(*eits))
+	for _, item := range *eits {
+		ids = append(ids, item.HID)
+	}
+	at, err := beginAudit(tx, actor, pub.EPT, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// SQL to delete header data
 	delHeadSql := `update ept_h set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp
 	where id=$2 and dr=0 and ts=$3`
@@ -626,7 +697,7 @@ func DeleteEPTs(eits *[]EPT, modifyUserID int32) (resStatus i18n.ResKey, err err
 			return
 		}
 		// Delete header
-		delHeadRes, errH := delHeadStmt.Exec(modifyUserID, ept.HID, ept.Ts)
+		delHeadRes, errH := delHeadStmt.Exec(actor.UserID, ept.HID, ept.Ts)
 		if errH != nil {
 			zap.L().Error("DeleteEPTs delHeadStmt.Exec failed", zap.Error(errH))
 			resStatus = i18n.StatusInternalError
@@ -650,7 +721,7 @@ func DeleteEPTs(eits *[]EPT, modifyUserID int32) (resStatus i18n.ResKey, err err
 
 		// Delete body rows
 		for _, row := range ept.Body {
-			delRowRes, errDelRow := delRowStmt.Exec(modifyUserID, row.BID, row.Ts)
+			delRowRes, errDelRow := delRowStmt.Exec(actor.UserID, row.BID, row.Ts)
 			if errDelRow != nil {
 				zap.L().Error("DeleteEPTs delRowStmt.Exec failed", zap.Error(errDelRow))
 				resStatus = i18n.StatusInternalError
@@ -674,6 +745,12 @@ func DeleteEPTs(eits *[]EPT, modifyUserID int32) (resStatus i18n.ResKey, err err
 		}
 	}
 
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
@@ -692,6 +769,11 @@ func (ept *EPT) CheckUsed() (resStatus i18n.ResKey, err error) {
 			SqlStr:         `select count(id) as usednumber from executionorder_h where dr=0 and eptid=$1`,
 			UsedReturnCode: i18n.StatusEOUsed,
 		},
+		{
+			Description:    "Referenced by Work Order schedules",
+			SqlStr:         `select count(id) as usednumber from woschedule where dr=0 and eptid=$1`,
+			UsedReturnCode: i18n.StatusWOScheduleUsed,
+		},
 	}
 	// Check each item
 	var usedNum int32
@@ -709,3 +791,18 @@ func (ept *EPT) CheckUsed() (resStatus i18n.ResKey, err error) {
 	}
 	return
 }
+
+// Check the error rules of the Execution Project Template rows
+func (ept *EPT) checkErrorRules() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	for _, row := range ept.Body {
+		if row.Dr != 0 {
+			continue
+		}
+		resStatus, err = row.ErrorRules.check()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,217 @@
+package pg
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/errorrule"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Error rule of an Execution Project or an Execution Project Template row.
+// Expr is an errorrule expression on the execution value, such as "value < 15" or "text in ('No', 'N/A')".
+type ErrorRule struct {
+	Expr        string    `json:"expr"`
+	RiskLevel   RiskLevel `json:"riskLevel"` // Risk Level of the issue, empty for the Risk Level of the row
+	Description string    `json:"description"`
+}
+
+// Error rules, checked in order, the first rule the value matches flags the issue.
+// Stored as JSON text with the Risk Level ID only.
+type ErrorRules []ErrorRule
+
+// Error rule as stored in the database
+type storedErrorRule struct {
+	Expr        string `json:"expr"`
+	RiskLevelID int32  `json:"riskLevelID"`
+	Description string `json:"description"`
+}
+
+// Error checking and required files of an Execution Order row, taken from its template row or Execution Project
+type errorCheck struct {
+	IsCheckError  int16
+	ErrorValue    string
+	ErrorRules    ErrorRules
+	RiskLevel     RiskLevel
+	IsRequireFile int16
+	IsOnsitePhoto int16
+}
+
+// Implements driver.Valuer
+func (rules ErrorRules) Value() (driver.Value, error) {
+	if len(rules) == 0 {
+		return "", nil
+	}
+	stored := make([]storedErrorRule, 0, len(rules))
+	for _, r := range rules {
+		stored = append(stored, storedErrorRule{Expr: r.Expr, RiskLevelID: r.RiskLevel.ID, Description: r.Description})
+	}
+	b, err := json.Marshal(stored)
+	return string(b), err
+}
+
+// Implements sql.Scanner
+func (rules *ErrorRules) Scan(src interface{}) (err error) {
+	*rules = make(ErrorRules, 0)
+	var s string
+	switch v := src.(type) {
+	case nil:
+		return
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return errors.New("ErrorRules.Scan: unsupported type")
+	}
+	if s == "" {
+		return
+	}
+	var stored []storedErrorRule
+	err = json.Unmarshal([]byte(s), &stored)
+	if err != nil {
+		return
+	}
+	for _, r := range stored {
+		*rules = append(*rules, ErrorRule{Expr: r.Expr, RiskLevel: RiskLevel{ID: r.RiskLevelID}, Description: r.Description})
+	}
+	return
+}
+
+// Check that the rules are valid expressions and their Risk Levels exist
+func (rules ErrorRules) check() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	for i := range rules {
+		rules[i].Expr = strings.TrimSpace(rules[i].Expr)
+		if _, errParse := errorrule.Parse(rules[i].Expr); errParse != nil {
+			resStatus = i18n.StatusErrorRuleInvalid
+			return
+		}
+		if rules[i].RiskLevel.ID <= 0 {
+			continue
+		}
+		var number int32
+		err = db.QueryRow("select count(id) from risklevel where id=$1 and dr=0", rules[i].RiskLevel.ID).Scan(&number)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("ErrorRules.check db.QueryRow failed", zap.Error(err))
+			return
+		}
+		if number == 0 {
+			resStatus = i18n.StatusErrorRuleRLNotExist
+			return
+		}
+	}
+	return
+}
+
+// Fill in the Risk Level details of the rules
+func (rules ErrorRules) fillDetail() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	for i := range rules {
+		if rules[i].RiskLevel.ID > 0 {
+			resStatus, err = rules[i].RiskLevel.GetRLInfoByID()
+			if resStatus != i18n.StatusOK || err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// Check whether the value is an error.
+// The rules are checked in order, without rules the value is an error when it equals the error value.
+// A rule that can't be evaluated for the value, e.g. arithmetic on text, doesn't match.
+// riskLevel is the Risk Level of the matched rule, or the Risk Level of the check.
+func (ec errorCheck) match(value string, text string) (isError bool, riskLevel RiskLevel) {
+	riskLevel = ec.RiskLevel
+	if len(ec.ErrorRules) == 0 {
+		isError = value == ec.ErrorValue
+		return
+	}
+	for _, r := range ec.ErrorRules {
+		expr, err := errorrule.Parse(r.Expr)
+		if err != nil {
+			continue
+		}
+		if ok, _ := expr.Match(value, text); ok {
+			if r.RiskLevel.ID > 0 {
+				riskLevel = r.RiskLevel
+			}
+			return true, riskLevel
+		}
+	}
+	return
+}
+
+// Get the error checking and the required files of the row.
+// Rows from the template use the template row of the same Execution Project, preferably with the same row number,
+// other rows and rows whose template row was removed use the Execution Project.
+func (row *ExecutionOrderRow) errorCheck(eptID int32) (ec errorCheck, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	if row.IsFromEPT == 1 && eptID > 0 {
+		sqlStr := `select ischeckerror,errorvalue,errorrules,risklevelid,isrequirefile,isonsitephoto
+		from ept_b
+		where hid=$1 and epaid=$2 and dr=0
+		order by (rownumber=$3) desc,rownumber limit 1`
+		err = db.QueryRow(sqlStr, eptID, row.EPA.ID, row.RowNumber).Scan(&ec.IsCheckError, &ec.ErrorValue, &ec.ErrorRules, &ec.RiskLevel.ID,
+			&ec.IsRequireFile, &ec.IsOnsitePhoto)
+		if err == nil {
+			return
+		}
+		if err != sql.ErrNoRows {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("ExecutionOrderRow.errorCheck db.QueryRow failed", zap.Error(err))
+			return
+		}
+		err = nil
+	}
+	ep := ExecutionProject{ID: row.EPA.ID}
+	resStatus, err = ep.GetInfoByID()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	ec = errorCheck{IsCheckError: ep.IsCheckError, ErrorValue: ep.ErrorValue, ErrorRules: ep.ErrorRules, RiskLevel: ep.RiskLevel,
+		IsRequireFile: ep.IsRequireFile, IsOnsitePhoto: ep.IsOnsitePhoto}
+	return
+}
+
+// Flag the issues of the Execution Order rows from the error checking of their template rows or Execution Projects.
+// A checked row with a value is an issue when the value is an error, with the Risk Level of the matched rule.
+// Rows that aren't checked keep the issue flag they were saved with, rows already being handled are left alone.
+func (eo *ExecutionOrder) flagIssues() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	for i := range eo.Body {
+		row := &eo.Body[i]
+		if row.Dr != 0 || row.IsHandle == 1 || row.ExecutionValue == "" {
+			continue
+		}
+		var ec errorCheck
+		ec, resStatus, err = row.errorCheck(eo.EPT.HID)
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+		if ec.IsCheckError != 1 {
+			continue
+		}
+		isError, riskLevel := ec.match(row.ExecutionValue, row.ExecutionValueDisp)
+		row.RiskLevel = riskLevel
+		if isError {
+			row.IsIssue = 1
+		} else {
+			row.IsIssue = 0
+			row.IsRectify = 0
+		}
+		if row.RiskLevel.ID > 0 && row.RiskLevel.Name == "" {
+			resStatus, err = row.RiskLevel.GetRLInfoByID()
+			if resStatus != i18n.StatusOK || err != nil {
+				return
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,79 @@
+package pg
+
+import (
+	"database/sql/driver"
+	"errors"
+	"sccsmsserver/i18n"
+	"strings"
+	"testing"
+)
+
+func TestErrorRulesCheck(t *testing.T) {
+	// Risk Levels 1 and 2 exist, 3 is deleted
+	useFakeDB(t, func(query string, args []driver.Value) (rows fakeRows, err error) {
+		if !strings.Contains(query, "from risklevel") {
+			return rows, errors.New("unexpected query: " + query)
+		}
+		number := int64(0)
+		if id := args[0].(int64); id == 1 || id == 2 {
+			number = 1
+		}
+		return fakeRows{Columns: []string{"count"}, Values: [][]driver.Value{{number}}}, nil
+	})
+	tests := []struct {
+		name  string
+		rules ErrorRules
+		want  i18n.ResKey
+	}{
+		{name: "no rules", want: i18n.StatusOK},
+		{name: "rules without Risk Level", rules: ErrorRules{{Expr: "value < 15"}, {Expr: " text in ('No') "}}, want: i18n.StatusOK},
+		{name: "existing Risk Levels", rules: ErrorRules{{Expr: "< 15", RiskLevel: RiskLevel{ID: 1}}, {Expr: "> 30", RiskLevel: RiskLevel{ID: 2}}}, want: i18n.StatusOK},
+		{name: "deleted Risk Level", rules: ErrorRules{{Expr: "< 15", RiskLevel: RiskLevel{ID: 1}}, {Expr: "> 30", RiskLevel: RiskLevel{ID: 3}}}, want: i18n.StatusErrorRuleRLNotExist},
+		{name: "missing Risk Level", rules: ErrorRules{{Expr: "< 15", RiskLevel: RiskLevel{ID: 99}}}, want: i18n.StatusErrorRuleRLNotExist},
+		{name: "invalid expression", rules: ErrorRules{{Expr: "value <", RiskLevel: RiskLevel{ID: 1}}}, want: i18n.StatusErrorRuleInvalid},
+		{name: "empty expression", rules: ErrorRules{{Expr: "  "}}, want: i18n.StatusErrorRuleInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resStatus, err := tt.rules.check()
+			if resStatus != tt.want || err != nil {
+				t.Errorf("check() = %s, %v, want %s", resStatus, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorCheckMatch(t *testing.T) {
+	ec := errorCheck{
+		RiskLevel: RiskLevel{ID: 1},
+		ErrorRules: ErrorRules{
+			{Expr: "value / 0 > 1", RiskLevel: RiskLevel{ID: 4}},
+			{Expr: "value > 30", RiskLevel: RiskLevel{ID: 3}},
+			{Expr: "value > 20"},
+		},
+	}
+	tests := []struct {
+		value     string
+		isError   bool
+		riskLevel int32
+	}{
+		{"35", true, 3},
+		{"25", true, 1},
+		{"15", false, 1},
+		{"abc", true, 3},
+	}
+	for _, tt := range tests {
+		isError, rl := ec.match(tt.value, "")
+		if isError != tt.isError || rl.ID != tt.riskLevel {
+			t.Errorf("match(%q) = %v, %d, want %v, %d", tt.value, isError, rl.ID, tt.isError, tt.riskLevel)
+		}
+	}
+	// Without rules the value is an error when it equals the error value
+	ec = errorCheck{ErrorValue: "No", RiskLevel: RiskLevel{ID: 2}}
+	if isError, rl := ec.match("No", ""); !isError || rl.ID != 2 {
+		t.Errorf("match(No) = %v, %d, want true, 2", isError, rl.ID)
+	}
+	if isError, _ := ec.match("Yes", ""); isError {
+		t.Errorf("match(Yes) = true, want false")
+	}
+}
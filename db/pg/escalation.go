@@ -0,0 +1,328 @@
+package pg
+
+import (
+	"sccsmsserver/i18n"
+	"sccsmsserver/pub"
+	"sccsmsserver/setting"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// Overdue item types
+const (
+	OverdueWO    = "wo"  // Work Order row without an executed Execution Order by its end time
+	OverdueIssue = "eo"  // Execution Order issue row not finished by its handle end time
+	OverdueIRF   = "irf" // Issue Resolution Form still open at its end time
+)
+
+// Escalation levels
+const (
+	EscalationOwner      int16 = 1 // Executor of the Work Order row or owner of the issue
+	EscalationRespPerson int16 = 2 // Responsible person of the Construction Site
+	EscalationDeptHead   int16 = 3 // Leader of the department of the voucher
+)
+
+// Escalation status
+const (
+	EscalationOpen   int16 = 0
+	EscalationClosed int16 = 1
+)
+
+// Default hours overdue before each escalation level
+const (
+	defaultOwnerHours = 0
+	defaultRespHours  = 24
+	defaultHeadHours  = 72
+)
+
+// Overdue item escalated to a user
+type Escalation struct {
+	ID         int32            `db:"id" json:"id"`
+	ItemType   string           `db:"itemtype" json:"itemType"`
+	ItemID     int32            `db:"itemid" json:"itemID"`
+	HID        int32            `db:"hid" json:"hid"`
+	BillNumber string           `db:"billnumber" json:"billNumber"`
+	RowNumber  int32            `db:"rownumber" json:"rowNumber"`
+	CSA        ConstructionSite `db:"csaid" json:"csa"`
+	Department SimpDept         `db:"deptid" json:"department"`
+	RiskLevel  RiskLevel        `db:"risklevelid" json:"riskLevel"`
+	DueTime    time.Time        `db:"duetime" json:"dueTime"`
+	Level      int16            `db:"level" json:"level"`
+	SendTo     Person           `db:"sendtoid" json:"sendTo"`
+	IsRead     int16            `db:"isread" json:"isRead"`
+	ReadTime   time.Time        `db:"readtime" json:"readTime"`
+	Status     int16            `db:"status" json:"status"`
+	CreateDate time.Time        `db:"createtime" json:"createDate"`
+	CloseTime  time.Time        `db:"closetime" json:"closeTime"`
+	Ts         time.Time        `db:"ts" json:"ts"`
+}
+
+// Overdue item found by the detection job
+type overdueItem struct {
+	ItemType    string
+	ItemID      int32
+	HID         int32
+	BillNumber  string
+	RowNumber   int32
+	CSAID       int32
+	DeptID      int32
+	RiskLevelID int32
+	DueTime     time.Time
+	SendTo      [3]int32 // Recipients of the owner, responsible person and department head levels
+	Hours       [3]int32 // Hours overdue before each level
+}
+
+// Overdue items of each type.
+// The queries take the default hours of the levels as $1, $2 and $3,
+// Risk Levels override them.
+var overdueSqls = map[string]string{
+	OverdueWO: `select b.id,b.hid,h.billnumber,b.rownumber,b.csaid,
+	h.deptid,0,b.endtime,b.executorid,coalesce(csa.resppersonid,0),
+	coalesce(d.leader,0),$1,$2,$3
+	from workorder_b as b
+	left join workorder_h as h on b.hid = h.id
+	left join csa on b.csaid = csa.id
+	left join department as d on h.deptid = d.id
+	where b.dr=0 and h.dr=0 and ` + woReferStatusSql + ` and b.endtime < current_timestamp`,
+	OverdueIssue: `select b.id,b.hid,h.billnumber,b.rownumber,h.csaid,
+	h.deptid,b.risklevelid,b.handleendtime,b.issueownerid,coalesce(csa.resppersonid,0),
+	coalesce(d.leader,0),coalesce(rl.ownerhours,$1),coalesce(rl.resphours,$2),coalesce(rl.headhours,$3)
+	from executionorder_b as b
+	left join executionorder_h as h on b.hid = h.id
+	left join csa on h.csaid = csa.id
+	left join department as d on h.deptid = d.id
+	left join risklevel as rl on b.risklevelid = rl.id and rl.dr=0
+	where b.ishandle=1 and b.dr=0 and h.dr=0 and b.isfinish=0 and b.status=1
+	and b.handleendtime < current_timestamp`,
+	OverdueIRF: `select f.id,f.id,f.billnumber,0,f.csaid,
+	f.deptid,f.risklevelid,f.endtime,f.issueownerid,coalesce(csa.resppersonid,0),
+	coalesce(d.leader,0),coalesce(rl.ownerhours,$1),coalesce(rl.resphours,$2),coalesce(rl.headhours,$3)
+	from issueresolutionform as f
+	left join csa on f.csaid = csa.id
+	left join department as d on f.deptid = d.id
+	left join risklevel as rl on f.risklevelid = rl.id and rl.dr=0
+	where f.dr=0 and f.status=0 and f.endtime < current_timestamp`,
+}
+
+// Background job: escalate the overdue items and close the escalations of the items that are no longer overdue
+func detectOverdueItems() (err error) {
+	ownerHours, respHours, headHours := overdueDefaultHours()
+	now := time.Now()
+	for _, itemType := range []string{OverdueWO, OverdueIssue, OverdueIRF} {
+		var items []overdueItem
+		items, err = getOverdueItems(itemType, ownerHours, respHours, headHours)
+		if err != nil {
+			return
+		}
+		itemIDs := make([]int32, 0, len(items))
+		for _, item := range items {
+			itemIDs = append(itemIDs, item.ItemID)
+			err = item.escalate(now)
+			if err != nil {
+				return
+			}
+		}
+		err = closeEscalations(itemType, itemIDs)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Default hours overdue before each level, for Work Orders and items without a Risk Level
+func overdueDefaultHours() (ownerHours, respHours, headHours int32) {
+	ownerHours, respHours, headHours = defaultOwnerHours, defaultRespHours, defaultHeadHours
+	cfg := setting.Conf.OverdueConfig
+	if cfg == nil {
+		return
+	}
+	if cfg.OwnerHours > 0 {
+		ownerHours = int32(cfg.OwnerHours)
+	}
+	if cfg.RespHours > 0 {
+		respHours = int32(cfg.RespHours)
+	}
+	if cfg.HeadHours > 0 {
+		headHours = int32(cfg.HeadHours)
+	}
+	return
+}
+
+// Get the overdue items of the type
+func getOverdueItems(itemType string, ownerHours, respHours, headHours int32) (items []overdueItem, err error) {
+	rows, err := db.Query(overdueSqls[itemType], ownerHours, respHours, headHours)
+	if err != nil {
+		zap.L().Error("getOverdueItems db.Query failed", zap.String("itemType", itemType), zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		item := overdueItem{ItemType: itemType}
+		err = rows.Scan(&item.ItemID, &item.HID, &item.BillNumber, &item.RowNumber, &item.CSAID,
+			&item.DeptID, &item.RiskLevelID, &item.DueTime, &item.SendTo[0], &item.SendTo[1],
+			&item.SendTo[2], &item.Hours[0], &item.Hours[1], &item.Hours[2])
+		if err != nil {
+			zap.L().Error("getOverdueItems rows.Scan failed", zap.String("itemType", itemType), zap.Error(err))
+			return
+		}
+		items = append(items, item)
+	}
+	return
+}
+
+// Raise the escalation levels that are due.
+// A level is skipped when it has no recipient or its recipient already got a lower level.
+func (item overdueItem) escalate(now time.Time) (err error) {
+	sqlStr := `insert into escalation(itemtype,itemid,hid,billnumber,rownumber,
+	csaid,deptid,risklevelid,duetime,level,
+	sendtoid)
+	values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+	on conflict (itemtype,itemid,level) where status=0 do nothing`
+	for i, sendTo := range item.SendTo {
+		if sendTo == 0 || now.Before(item.DueTime.Add(time.Duration(item.Hours[i])*time.Hour)) {
+			continue
+		}
+		sent := false
+		for _, lower := range item.SendTo[:i] {
+			sent = sent || lower == sendTo
+		}
+		if sent {
+			continue
+		}
+		res, errExec := db.Exec(sqlStr, item.ItemType, item.ItemID, item.HID, item.BillNumber, item.RowNumber,
+			item.CSAID, item.DeptID, item.RiskLevelID, item.DueTime, EscalationOwner+int16(i),
+			sendTo)
+		if errExec != nil {
+			zap.L().Error("overdueItem.escalate db.Exec failed", zap.String("itemType", item.ItemType),
+				zap.Int32("itemID", item.ItemID), zap.Error(errExec))
+			return errExec
+		}
+		// Push the new escalation to its recipient
+		if inserted, _ := res.RowsAffected(); inserted > 0 {
+			push([]int32{sendTo}, PushMessage{Event: PushEscalation, VoucherType: pub.DataType(item.ItemType),
+				HID: item.HID, BID: item.ItemID, BillNumber: item.BillNumber, RowNumber: item.RowNumber})
+		}
+	}
+	return
+}
+
+// Close the open escalations of the type whose items are not among the overdue items
+func closeEscalations(itemType string, overdueIDs []int32) (err error) {
+	sqlStr := `update escalation set status=1,closetime=current_timestamp,ts=current_timestamp
+	where status=0 and itemtype=$1 and itemid <> all($2)`
+	_, err = db.Exec(sqlStr, itemType, pq.Array(overdueIDs))
+	if err != nil {
+		zap.L().Error("closeEscalations db.Exec failed", zap.String("itemType", itemType), zap.Error(err))
+	}
+	return
+}
+
+// Get the open escalations sent to the user
+func GetUserEscalations(userID int32) (escalations []Escalation, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	escalations = make([]Escalation, 0)
+	// Check
+	var rowNumber int32
+	err = db.QueryRow(`select count(id) from escalation where status=0 and sendtoid=$1`, userID).Scan(&rowNumber)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetUserEscalations db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if rowNumber > setting.Conf.PqConfig.MaxRecord {
+		resStatus = i18n.StatusOverRecord
+		return
+	}
+	// Retrieve the escalations
+	sqlStr := `select id,itemtype,itemid,hid,billnumber,
+	rownumber,csaid,deptid,risklevelid,duetime,
+	level,sendtoid,isread,readtime,status,
+	createtime,closetime,ts
+	from escalation
+	where status=0 and sendtoid=$1
+	order by duetime,level`
+	rows, err := db.Query(sqlStr, userID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetUserEscalations db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e Escalation
+		err = rows.Scan(&e.ID, &e.ItemType, &e.ItemID, &e.HID, &e.BillNumber,
+			&e.RowNumber, &e.CSA.ID, &e.Department.ID, &e.RiskLevel.ID, &e.DueTime,
+			&e.Level, &e.SendTo.ID, &e.IsRead, &e.ReadTime, &e.Status,
+			&e.CreateDate, &e.CloseTime, &e.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("GetUserEscalations rows.Scan failed", zap.Error(err))
+			return
+		}
+		resStatus, err = e.fillDetail()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+		escalations = append(escalations, e)
+	}
+	return
+}
+
+// Get the Construction Site, Department, Risk Level and recipient details
+func (e *Escalation) fillDetail() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	if e.CSA.ID > 0 {
+		resStatus, err = e.CSA.GetInfoByID()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	if e.Department.ID > 0 {
+		resStatus, err = e.Department.GetSimpDeptInfoByID()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	if e.RiskLevel.ID > 0 {
+		resStatus, err = e.RiskLevel.GetRLInfoByID()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	if e.SendTo.ID > 0 {
+		resStatus, err = e.SendTo.GetPersonInfoByID()
+	}
+	return
+}
+
+// User reads the escalation
+func (e *Escalation) Read(operatorID int32) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	// Check the Send To User and current User are the same person
+	if e.SendTo.ID != operatorID {
+		resStatus = i18n.StatusMsgOnlyReadSelf
+		return
+	}
+	sqlStr := `update escalation set isread=1,readtime=current_timestamp,ts=current_timestamp
+	where id=$1 and sendtoid=$2 and isread=0 and ts=$3`
+	res, err := db.Exec(sqlStr, e.ID, operatorID, e.Ts)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("Escalation.Read db.Exec failed", zap.Error(err))
+		return
+	}
+	// Check the number of rows affected by SQL statement
+	updateNumber, err := res.RowsAffected()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("Escalation.Read res.RowsAffected failed", zap.Error(err))
+		return
+	}
+	if updateNumber < 1 {
+		resStatus = i18n.StatusOtherEdit
+	}
+	return
+}
@@ -1,8 +1,12 @@
 package pg
 
 import (
+	"database/sql"
+	"fmt"
 	"math"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/filter"
+	"sccsmsserver/pub"
 	"sccsmsserver/setting"
 	"strings"
 	"time"
@@ -32,6 +36,7 @@ type ExecutionOrder struct {
 	AllowAddRow      int16               `db:"allowaddrow" json:"allowAddRow"`
 	AllowDelRow      int16               `db:"allowdelrow" json:"allowDelRow"`
 	Body             []ExecutionOrderRow `json:"body"`
+	RowErrors        []EORowError        `json:"rowErrors,omitempty"` // Rows that failed the checks
 	IssueNumber      int32               `json:"issueNumber"`
 	ReviewedNumber   int16               `json:"reviewedNumber"`
 	ReviewedSeconds  int32               `json:"reviewedSeconds"`
@@ -164,10 +169,39 @@ type EOListPaging struct {
 	PerPage int32            `json:"perPage"`
 }
 
+// Filterable fields of the Execution Order refer list
+var referEOFields = filter.Fields{
+	"billNumber":      {Column: "h.billnumber", Type: filter.String},
+	"billDate":        {Column: "h.billdate", Type: filter.Time},
+	"departmentID":    {Column: "h.deptid", Type: filter.Number},
+	"departmentName":  {Column: "dept.name", Type: filter.String},
+	"csaID":           {Column: "h.csaid", Type: filter.Number},
+	"executorID":      {Column: "h.executorid", Type: filter.Number},
+	"executorName":    {Column: "epuser.name", Type: filter.String},
+	"epaID":           {Column: "b.epaid", Type: filter.Number},
+	"epaCode":         {Column: "epa.code", Type: filter.String},
+	"epaName":         {Column: "epa.name", Type: filter.String},
+	"executionValue":  {Column: "b.executionvaluedisp", Type: filter.String},
+	"issueOwnerID":    {Column: "b.issueownerid", Type: filter.Number},
+	"issueOwnerName":  {Column: "issueowner.name", Type: filter.String},
+	"riskLevelID":     {Column: "b.risklevelid", Type: filter.Number},
+	"handleStartTime": {Column: "b.handlestarttime", Type: filter.Time},
+	"handleEndTime":   {Column: "b.handleendtime", Type: filter.Time},
+	"creatorID":       {Column: "h.creatorid", Type: filter.Number},
+	"creatorName":     {Column: "creator.name", Type: filter.String},
+}
+
 // Get the list of execution orders to be referenced
-func GetReferEOs(queryString string) (reos []ReferExecutionOrder, resStatus i18n.ResKey, err error) {
+func GetReferEOs(queryFilter filter.Group, ds DataScope) (reos []ReferExecutionOrder, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
+	// Restrict the query to the data scope of the user
+	queryFilter = ds.Restrict(queryFilter, "departmentID", "issueOwnerID")
 	reos = make([]ReferExecutionOrder, 0)
+	// Compile the query filter
+	whereSql, args, resStatus := compileFilter(queryFilter, referEOFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Assemble the SQL for checking
 	build.WriteString(`select count(b.id) as rownumber
@@ -179,15 +213,15 @@ func GetReferEOs(queryString string) (reos []ReferExecutionOrder, resStatus i18n
 	left join sysuser as creator on h.creatorid = creator.id
 	left join department as dept on h.deptid = dept.id
 	where (b.ishandle=1 and b.dr = 0 and b.isfinish=0 and b.status=1)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 	// Check
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, args...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetReferEOs db.QueryRow(checkSql) failed", zap.Error(err))
@@ -216,14 +250,14 @@ func GetReferEOs(queryString string) (reos []ReferExecutionOrder, resStatus i18n
 	left join sysuser as creator on h.creatorid = creator.id
 	left join department as dept on h.deptid = dept.id
 	where (b.ishandle=1 and b.dr = 0 and b.isfinish=0 and (b.status=1 or b.status=2))`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	refSql := build.String()
 	// Retrieve the list of Execution Orders to be referenced
-	edRef, err := db.Query(refSql)
+	edRef, err := db.Query(refSql, args...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetReferEOs db.Query failed", zap.Error(err))
@@ -296,10 +330,44 @@ func GetReferEOs(queryString string) (reos []ReferExecutionOrder, resStatus i18n
 	return
 }
 
+// Filterable fields of the Execution Order list and review list
+var eoListFields = filter.Fields{
+	"billNumber":       {Column: "h.billnumber", Type: filter.String},
+	"billDate":         {Column: "h.billdate", Type: filter.Time},
+	"description":      {Column: "h.description", Type: filter.String},
+	"status":           {Column: "h.status", Type: filter.Number},
+	"sourceType":       {Column: "h.sourcetype", Type: filter.String},
+	"sourceBillNumber": {Column: "h.sourcebillnumber", Type: filter.String},
+	"csaID":            {Column: "h.csaid", Type: filter.Number},
+	"executorID":       {Column: "h.executorid", Type: filter.Number},
+	"eptID":            {Column: "h.eptid", Type: filter.Number},
+	"startTime":        {Column: "h.starttime", Type: filter.Time},
+	"endTime":          {Column: "h.endtime", Type: filter.Time},
+	"departmentID":     {Column: "h.deptid", Type: filter.Number},
+	"departmentCode":   {Column: "department.code", Type: filter.String},
+	"departmentName":   {Column: "department.name", Type: filter.String},
+	"creatorID":        {Column: "h.creatorid", Type: filter.Number},
+	"creatorCode":      {Column: "creator.code", Type: filter.String},
+	"creatorName":      {Column: "creator.name", Type: filter.String},
+	"modifierID":       {Column: "h.modifierid", Type: filter.Number},
+	"modifierName":     {Column: "modifier.name", Type: filter.String},
+	"confirmerID":      {Column: "h.confirmerid", Type: filter.Number},
+	"createDate":       {Column: "h.createtime", Type: filter.Time},
+	"confirmDate":      {Column: "h.confirmtime", Type: filter.Time},
+	"modifyDate":       {Column: "h.modifytime", Type: filter.Time},
+}
+
 // Get Execution Order list
-func GetEOList(queryString string) (eos []ExecutionOrder, resStatus i18n.ResKey, err error) {
+func GetEOList(queryFilter filter.Group, ds DataScope) (eos []ExecutionOrder, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
+	// Restrict the query to the data scope of the user
+	queryFilter = ds.Restrict(queryFilter, "departmentID", "creatorID")
 	eos = make([]ExecutionOrder, 0)
+	// Compile the query filter
+	whereSql, args, resStatus := compileFilter(queryFilter, eoListFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Assemble the SQL for checking
 	build.WriteString(`select count(h.id) as rownumber
@@ -308,15 +376,15 @@ func GetEOList(queryString string) (eos []ExecutionOrder, resStatus i18n.ResKey,
 	left join sysuser as creator on h.creatorid = creator.id
 	left join sysuser as modifier on h.modifierid = modifier.id
 	where (h.dr = 0) `)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 	// Check
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, args...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetEOList db.QueryRow(checkSql) failed", zap.Error(err))
@@ -344,14 +412,14 @@ func GetEOList(queryString string) (eos []ExecutionOrder, resStatus i18n.ResKey,
 	left join sysuser as creator on h.creatorid = creator.id
 	left join sysuser as modifier on h.modifierid = modifier.id
 	where (h.dr = 0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	headSql := build.String()
 	// Retrieve Execution Order list from database
-	headRows, err := db.Query(headSql)
+	headRows, err := db.Query(headSql, args...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetEOList db.Query failed", zap.Error(err))
@@ -383,9 +451,21 @@ func GetEOList(queryString string) (eos []ExecutionOrder, resStatus i18n.ResKey,
 }
 
 // Get the list of Execution Order to be reviewed
-func GetEOReviewList(queryString string, useID int32) (eos []ExecutionOrder, resStatus i18n.ResKey, err error) {
+func GetEOReviewList(queryFilter filter.Group, useID int32, ds DataScope) (eos []ExecutionOrder, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
+	// Restrict the query to the data scope of the user
+	queryFilter = ds.Restrict(queryFilter, "departmentID", "creatorID")
 	eos = make([]ExecutionOrder, 0)
+	// Compile the query filter.
+	// The data retrieval SQL binds the user ID to $1, so its placeholders start at $2.
+	checkWhere, checkArgs, resStatus := compileFilter(queryFilter, eoListFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
+	whereSql, args, resStatus := compileFilter(queryFilter, eoListFields, 2)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Assemble the SQL for check
 	build.WriteString(`select count(h.id) as rownumber
@@ -394,15 +474,15 @@ func GetEOReviewList(queryString string, useID int32) (eos []ExecutionOrder, res
 	left join sysuser as creator on h.creatorid = creator.id
 	left join sysuser as modifier on h.modifierid = modifier.id
 	where (h.dr = 0) `)
-	if queryString != "" {
+	if checkWhere != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(checkWhere)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 	// Check the number of rows
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, checkArgs...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetEOReviewList db.QueryRow(checkSql) failed", zap.Error(err))
@@ -433,14 +513,14 @@ func GetEOReviewList(queryString string, useID int32) (eos []ExecutionOrder, res
 	left join sysuser as creator on h.creatorid = creator.id
 	left join sysuser as modifier on h.modifierid = modifier.id
 	where (h.dr = 0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	headSql := build.String()
 	// Retrieve Execution Order from database
-	headRows, err := db.Query(headSql, useID)
+	headRows, err := db.Query(headSql, append([]interface{}{useID}, args...)...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetEOReviewList db.Query failed", zap.Error(err))
@@ -472,9 +552,21 @@ func GetEOReviewList(queryString string, useID int32) (eos []ExecutionOrder, res
 }
 
 // Get the list of Execution Orders to be reviewed by pagination
-func GetEOReviewListPagination(con PagingQueryParams, userID int32) (edsp EOListPaging, resStatus i18n.ResKey, err error) {
+func GetEOReviewListPagination(con PagingQueryParams, userID int32, ds DataScope) (edsp EOListPaging, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
+	// Restrict the query to the data scope of the user
+	con.Filter = ds.Restrict(con.Filter, "departmentID", "creatorID")
 	edsp.EOs = make([]ExecutionOrder, 0)
+	// Compile the query filter.
+	// The data retrieval SQL binds the user ID to $1, so its placeholders start at $2.
+	checkWhere, checkArgs, resStatus := compileFilter(con.Filter, eoListFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
+	whereSql, args, resStatus := compileFilter(con.Filter, eoListFields, 2)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Assemble the SQL for checking
 	build.WriteString(`select count(h.id) as rownumber
@@ -483,15 +575,15 @@ func GetEOReviewListPagination(con PagingQueryParams, userID int32) (edsp EOList
 	left join sysuser as creator on h.creatorid = creator.id
 	left join sysuser as modifier on h.modifierid = modifier.id
 	where (h.dr = 0) `)
-	if con.QueryString != "" {
+	if checkWhere != "" {
 		build.WriteString(" and (")
-		build.WriteString(con.QueryString)
+		build.WriteString(checkWhere)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 
 	// Check
-	err = db.QueryRow(checkSql).Scan(&edsp.Count)
+	err = db.QueryRow(checkSql, checkArgs...).Scan(&edsp.Count)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetEOReviewListPagination db.QueryRow(checkSql) failed", zap.Error(err))
@@ -531,16 +623,19 @@ func GetEOReviewListPagination(con PagingQueryParams, userID int32) (edsp EOList
 	left join sysuser as creator on h.creatorid = creator.id
 	left join sysuser as modifier on h.modifierid = modifier.id
 	where (h.dr = 0)`)
-	if con.QueryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(con.QueryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
-	build.WriteString(" order by h.id limit $2 offset $3")
+	limitIndex := len(args) + 2
+	build.WriteString(fmt.Sprintf(" order by h.id limit $%d offset $%d", limitIndex, limitIndex+1))
 	headSql := build.String()
+	queryArgs := append([]interface{}{userID}, args...)
+	queryArgs = append(queryArgs, con.PerPage, con.Page*con.PerPage)
 
 	// Get Execution Order from database
-	headRows, err := db.Query(headSql, userID, con.PerPage, con.Page*con.PerPage)
+	headRows, err := db.Query(headSql, queryArgs...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetEOReviewListPagination db.Query failed", zap.Error(err))
@@ -787,8 +882,23 @@ func (eo *ExecutionOrder) GetDetailByHID() (resStatus i18n.ResKey, err error) {
 	return
 }
 
-// Add Execution Order
-func (eo *ExecutionOrder) Add() (resStatus i18n.ResKey, err error) {
+// Add Execution Order.
+// The rows are checked first, their values and required files and on-site photos,
+// then their issues are flagged by the error rules.
+func (eo *ExecutionOrder) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = eo.checkRows(true)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	resStatus, err = eo.flagIssues()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return eo.add(actor)
+}
+
+// Write the new Execution Order
+func (eo *ExecutionOrder) add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check the number of body rows, zero is not allowed
 	if len(eo.Body) == 0 {
@@ -803,6 +913,13 @@ func (eo *ExecutionOrder) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Start the audit trail
+	at, err := beginAudit(tx, actor, pub.EO, AuditActionAdd)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Get the latest serial number
 	billNo, resStatus, err := GetLatestSerialNo(tx, "EO")
 	if resStatus != i18n.StatusOK || err != nil {
@@ -905,11 +1022,17 @@ func (eo *ExecutionOrder) Add() (resStatus i18n.ResKey, err error) {
 		}
 	}
 
+	// Write the audit trail
+	err = at.write(eo.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Edit Execution Order
-func (eo *ExecutionOrder) Edit() (resStatus i18n.ResKey, err error) {
+func (eo *ExecutionOrder) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check the number of rows in the Execution Order body
 	if len(eo.Body) == 0 {
@@ -921,6 +1044,21 @@ func (eo *ExecutionOrder) Edit() (resStatus i18n.ResKey, err error) {
 		resStatus = i18n.StatusVoucherOnlyCreateEdit
 		return
 	}
+	// Vouchers waiting for approval can't be modified
+	resStatus, err = checkApprovalPending(pub.EO, eo.HID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	// Check the row values, files and on-site photos
+	resStatus, err = eo.checkRows(true)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	// Flag the issues by the error rules
+	resStatus, err = eo.flagIssues()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 
 	// Create a database transaction
 	tx, err := db.Begin()
@@ -930,6 +1068,13 @@ func (eo *ExecutionOrder) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.EO, AuditActionEdit, eo.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Modify Construction Order Header in the executionorder_h table
 	editHeadSql := `update executionorder_h set billdate=$1,deptid=$2,description=$3,starttime=$4,endtime=$5,
@@ -1114,11 +1259,17 @@ func (eo *ExecutionOrder) Edit() (resStatus i18n.ResKey, err error) {
 			}
 		}
 	}
+	// Write the audit trail
+	err = at.write(eo.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Delete Execution Order
-func (eo *ExecutionOrder) Delete(modifyUserId int32) (resStatus i18n.ResKey, err error) {
+func (eo *ExecutionOrder) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Get the Execution Order Header details
 	resStatus, err = eo.GetDetailByHID()
@@ -1131,10 +1282,15 @@ func (eo *ExecutionOrder) Delete(modifyUserId int32) (resStatus i18n.ResKey, err
 		return
 	}
 	// Check if the creator and modifier are the same person
-	if eo.Creator.ID != modifyUserId {
+	if eo.Creator.ID != actor.UserID {
 		resStatus = i18n.StatusVoucherOnlyCreateEdit
 		return
 	}
+	// Vouchers waiting for approval can't be deleted
+	resStatus, err = checkApprovalPending(pub.EO, eo.HID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 
 	// Create a database transaction
 	tx, err := db.Begin()
@@ -1144,10 +1300,17 @@ func (eo *ExecutionOrder) Delete(modifyUserId int32) (resStatus i18n.ResKey, err
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.EO, AuditActionDelete, eo.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Modify the Execution Order Header delete flag to 1 in the executionorder_b table
 	delHeadSql := `update executionorder_h set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp 
 	where id=$2 and dr=0 and ts=$3`
-	delHeadRes, err := tx.Exec(delHeadSql, modifyUserId, eo.HID, eo.Ts)
+	delHeadRes, err := tx.Exec(delHeadSql, actor.UserID, eo.HID, eo.Ts)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("ExecutionOrder.Delete tx.Exec(delHeadSql) failed", zap.Error(err))
@@ -1198,7 +1361,7 @@ func (eo *ExecutionOrder) Delete(modifyUserId int32) (resStatus i18n.ResKey, err
 			tx.Rollback()
 			return
 		}
-		delRowRes, errDelRow := delRowStmt.Exec(modifyUserId, row.BID, row.Ts)
+		delRowRes, errDelRow := delRowStmt.Exec(actor.UserID, row.BID, row.Ts)
 		if errDelRow != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("ExecutionOrder.Delete delRowStmt.Exec() failed", zap.Error(errDelRow))
@@ -1222,7 +1385,7 @@ func (eo *ExecutionOrder) Delete(modifyUserId int32) (resStatus i18n.ResKey, err
 		// Handle the Execution Order attaments
 		if len(row.Files) > 0 {
 			for _, file := range row.Files {
-				delFileRes, delFileErr := delFileStmt.Exec(modifyUserId, file.ID, row.BID, file.Ts)
+				delFileRes, delFileErr := delFileStmt.Exec(actor.UserID, file.ID, row.BID, file.Ts)
 				if delFileErr != nil {
 					resStatus = i18n.StatusInternalError
 					zap.L().Error("ExecutionOrder.Delete delFileStmt.Exec() failed", zap.Error(delFileErr))
@@ -1262,11 +1425,70 @@ func (eo *ExecutionOrder) Delete(modifyUserId int32) (resStatus i18n.ResKey, err
 		}
 	}
 
+	// Write the audit trail
+	err = at.write(eo.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
+	return
+}
+
+// Confirm Execution Order.
+// The saved rows are checked before the Execution Order is submitted or confirmed.
+// With an approval flow the Execution Order is submitted for approval instead,
+// it is confirmed once the last approval step is approved.
+func (eo *ExecutionOrder) Confirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = eo.checkSavedRows()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	resStatus, err = submitForApproval(pub.EO, eo.HID, actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return eo.confirm(actor)
+}
+
+// Confirm Execution Order without approval, then notify the issue owners
+func (eo *ExecutionOrder) confirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = eo.writeConfirm(actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	eo.confirmed(actor)
 	return
 }
 
-// Confirm Execution Order
-func (eo *ExecutionOrder) Confirm(confirmUserID int32) (resStatus i18n.ResKey, err error) {
+// Notify the issue owners of the committed confirmation
+func (eo *ExecutionOrder) confirmed(actor AuditActor) {
+	pushRows(PushIssue, pub.EO, eo.HID, `select b.id,b.rownumber,b.issueownerid,h.billnumber
+	from executionorder_b as b
+	left join executionorder_h as h on b.hid = h.id
+	where b.hid=$1 and b.dr=0 and b.ishandle=1`)
+	pushConfirmed(pub.EO, eo.HID, actor.UserID)
+}
+
+// Write the confirmation of the Execution Order
+func (eo *ExecutionOrder) writeConfirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	// Begin a database transaction
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("ExecutionOrder.Confirm db.Begin failed", zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	resStatus, err = eo.writeConfirmTx(tx, actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		tx.Rollback()
+	}
+	return
+}
+
+// Write the confirmation of the Execution Order and its audit trail in the transaction
+func (eo *ExecutionOrder) writeConfirmTx(tx *sql.Tx, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Get the Execution Order details
 	resStatus, err = eo.GetDetailByHID()
@@ -1278,22 +1500,19 @@ func (eo *ExecutionOrder) Confirm(confirmUserID int32) (resStatus i18n.ResKey, e
 		resStatus = i18n.StatusVoucherNoFree
 		return
 	}
-	// Begin a database transaction
-	tx, err := db.Begin()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.EO, AuditActionConfirm, eo.HID)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
-		zap.L().Error("ExecutionOrder.Confirm db.Begin failed", zap.Error(err))
 		return
 	}
-	defer tx.Commit()
 	// Write the confirmation information to the executionorder_h table
 	confirmHeadSql := `update executionorder_h set status=1,confirmtime=current_timestamp,confirmerid=$1,ts=current_timestamp 
 	where id=$2 and dr=0 and status=0 and ts=$3`
-	headRes, err := tx.Exec(confirmHeadSql, confirmUserID, eo.HID, eo.Ts)
+	headRes, err := tx.Exec(confirmHeadSql, actor.UserID, eo.HID, eo.Ts)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("ExecutionOrder.Confirm tx.Exec(confirmHeadSql) failed", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	// Check the number of rows affected by SQL statement
@@ -1301,12 +1520,10 @@ func (eo *ExecutionOrder) Confirm(confirmUserID int32) (resStatus i18n.ResKey, e
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("ExecutionOrder.Confirm headRes.RowsAffected failed", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	if confirmHeadNumber < 1 {
 		resStatus = i18n.StatusOtherEdit
-		tx.Rollback()
 		return
 	}
 
@@ -1317,7 +1534,6 @@ func (eo *ExecutionOrder) Confirm(confirmUserID int32) (resStatus i18n.ResKey, e
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("ExecutionOrder.Confirm tx.Prepare(confirmRowSql) failed", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	defer rowStmt.Close()
@@ -1326,14 +1542,12 @@ func (eo *ExecutionOrder) Confirm(confirmUserID int32) (resStatus i18n.ResKey, e
 		// Check the Execution Order rows status
 		if row.Status != 0 {
 			resStatus = i18n.StatusVoucherNoFree
-			tx.Rollback()
 			return
 		}
-		confirmRowRes, errConfirmRow := rowStmt.Exec(confirmUserID, row.BID, row.Ts)
+		confirmRowRes, errConfirmRow := rowStmt.Exec(actor.UserID, row.BID, row.Ts)
 		if errConfirmRow != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("ExecutionOrder.Confirm rowStmt.Exec failed", zap.Error(errConfirmRow))
-			tx.Rollback()
 			return resStatus, errConfirmRow
 		}
 
@@ -1341,12 +1555,10 @@ func (eo *ExecutionOrder) Confirm(confirmUserID int32) (resStatus i18n.ResKey, e
 		if errConfirmRow != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("ExecutionOrder.Confirm confirmRowRes.RowsAffected failed", zap.Error(errConfirmRow))
-			tx.Rollback()
 			return resStatus, errConfirmRow
 		}
 		if confirmRowNumber < 1 {
 			resStatus = i18n.StatusOtherEdit
-			tx.Rollback()
 			return
 		}
 	}
@@ -1362,16 +1574,30 @@ func (eo *ExecutionOrder) Confirm(confirmUserID int32) (resStatus i18n.ResKey, e
 
 		resStatus, err = wor.Complete()
 		if resStatus != i18n.StatusOK || err != nil {
-			tx.Rollback()
 			return
 		}
 	}
 
+	// Write the audit trail
+	err = at.write(eo.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+	}
 	return
 }
 
-// UnConfirm Execution Order
-func (eo *ExecutionOrder) UnConfirm(confirmUserID int32) (resStatus i18n.ResKey, err error) {
+// UnConfirm Execution Order.
+// Its approval is canceled, it has to be approved again before it is confirmed.
+func (eo *ExecutionOrder) UnConfirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = eo.unConfirm(actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return cancelApproval(pub.EO, eo.HID, actor.UserID)
+}
+
+// UnConfirm Execution Order without touching its approval
+func (eo *ExecutionOrder) unConfirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Get the Execution Order details
 	resStatus, err = eo.GetDetailByHID()
@@ -1384,7 +1610,7 @@ func (eo *ExecutionOrder) UnConfirm(confirmUserID int32) (resStatus i18n.ResKey,
 		return
 	}
 	// Check the Execution Order Confirmer and Unconfirmer are the same person
-	if eo.Confirmer.ID != confirmUserID {
+	if eo.Confirmer.ID != actor.UserID {
 		resStatus = i18n.StatusVoucherCancelConfirmSelf
 		return
 	}
@@ -1408,6 +1634,13 @@ func (eo *ExecutionOrder) UnConfirm(confirmUserID int32) (resStatus i18n.ResKey,
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.EO, AuditActionUnConfirm, eo.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Write the un-confirmation information to the executionorder_h table
 	confirmHeadSql := `update executionorder_h set status=0,confirmerid=0,confirmtime=to_timestamp(0),ts=current_timestamp 
 	where id=$1 and dr=0 and status=1 and ts=$2`
@@ -1487,6 +1720,12 @@ func (eo *ExecutionOrder) UnConfirm(confirmUserID int32) (resStatus i18n.ResKey,
 			return
 		}
 	}
+	// Write the audit trail
+	err = at.write(eo.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
@@ -1613,6 +1852,9 @@ func (eoc *ExecutionOrderComment) Add() (resStatus i18n.ResKey, err error) {
 		zap.L().Error("ExecutionOrderComment.Add db.QueryRow(sqlStr) failed", zap.Error(err))
 		return
 	}
+	// Push the comment to the user it is sent to
+	push([]int32{eoc.SendTo.ID}, PushMessage{Event: PushComment, VoucherType: pub.EO, HID: eoc.HID,
+		BID: eoc.BID, BillNumber: eoc.BillNumber, RowNumber: eoc.RowNUmber, Content: eoc.Content})
 	return
 }
 
@@ -0,0 +1,210 @@
+package pg
+
+import (
+	"database/sql"
+	"sccsmsserver/i18n"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// Fields of an Execution Order row error
+const (
+	EORowFieldEPA   = "epa"
+	EORowFieldValue = "executionValue"
+	EORowFieldFiles = "files"
+)
+
+// Accepted layouts of date and date time values
+var eoDateLayouts = []string{"2006-01-02", time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02T15:04"}
+
+// Tables the selected archives of a result type are stored in, by ScDataType ID
+var eoArchiveTables = map[int32]string{
+	510: "sysuser",
+	520: "department",
+	525: "csc",
+	530: "udc",
+	540: "epc",
+}
+
+// Execution Order row error.
+// The message is formatted with the row number in the language of the request.
+type EORowError struct {
+	RowNumber int32       `json:"rowNumber"`
+	Field     string      `json:"field"`
+	ResKey    i18n.ResKey `json:"resKey"`
+	Msg       string      `json:"msg"`
+}
+
+// Check the rows of the Execution Order.
+// The values are checked against the result types of their Execution Projects, empty values are allowed.
+// The required files and on-site photos of the rows are taken from their template rows or Execution Projects.
+// With requireFiles the rows must have them, an on-site photo is an image file.
+// The errors are returned in RowErrors with the status StatusEORowInvalid.
+func (eo *ExecutionOrder) checkRows(requireFiles bool) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	eo.RowErrors = nil
+	for i := range eo.Body {
+		row := &eo.Body[i]
+		// Rows deleted by the edit
+		if row.Dr != 0 {
+			continue
+		}
+		ep := ExecutionProject{ID: row.EPA.ID}
+		if ep.ID > 0 {
+			resStatus, err = ep.GetInfoByID()
+			if err != nil && err != sql.ErrNoRows {
+				return
+			}
+		}
+		resStatus = i18n.StatusOK
+		if ep.ID <= 0 || err == sql.ErrNoRows || ep.Dr != 0 {
+			err = nil
+			eo.addRowError(row.RowNumber, EORowFieldEPA, i18n.StatusEORowEPAInvalid)
+			continue
+		}
+		var errKey i18n.ResKey
+		errKey, err = ep.checkValue(row.ExecutionValue)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		eo.addRowError(row.RowNumber, EORowFieldValue, errKey)
+		var ec errorCheck
+		ec, resStatus, err = row.errorCheck(eo.EPT.HID)
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+		row.IsRequireFile = ec.IsRequireFile
+		row.IsOnsitePhoto = ec.IsOnsitePhoto
+		if !requireFiles {
+			continue
+		}
+		errKey, err = row.checkFiles()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		eo.addRowError(row.RowNumber, EORowFieldFiles, errKey)
+	}
+	if len(eo.RowErrors) > 0 {
+		resStatus = i18n.StatusEORowInvalid
+	}
+	return
+}
+
+// Add a row error, unless errKey is empty
+func (eo *ExecutionOrder) addRowError(rowNumber int32, field string, errKey i18n.ResKey) {
+	if errKey != "" {
+		eo.RowErrors = append(eo.RowErrors, EORowError{RowNumber: rowNumber, Field: field, ResKey: errKey})
+	}
+}
+
+// Check the rows of the saved Execution Order, before it is confirmed.
+// The required files are taken from the template rows and Execution Projects, not from the saved rows.
+func (eo *ExecutionOrder) checkSavedRows() (resStatus i18n.ResKey, err error) {
+	saved := ExecutionOrder{HID: eo.HID}
+	resStatus, err = saved.GetDetailByHID()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	resStatus, err = saved.checkRows(true)
+	eo.RowErrors = saved.RowErrors
+	return
+}
+
+// Check that the value matches the result type of the Execution Project.
+// errKey is empty when the value is valid.
+func (ep *ExecutionProject) checkValue(value string) (errKey i18n.ResKey, err error) {
+	if value == "" {
+		return
+	}
+	switch ep.ResultType.TypeName {
+	case "number":
+		if _, errParse := strconv.ParseFloat(value, 64); errParse != nil {
+			errKey = i18n.StatusEOValueNotNumber
+		}
+		return
+	case "date", "dateTime":
+		if !parsesAsDate(value) {
+			errKey = i18n.StatusEOValueNotDate
+		}
+		return
+	case "gender", "bool":
+		n, errParse := strconv.ParseInt(value, 10, 16)
+		if errParse != nil || n < 0 || (ep.ResultType.TypeName == "bool" && n > 1) {
+			errKey = i18n.StatusEOValueNotOption
+		}
+		return
+	}
+	if ep.ResultType.InputMode != "Select" {
+		return
+	}
+	// Selected archives are stored by ID
+	id, errParse := strconv.ParseInt(value, 10, 32)
+	if errParse != nil || id <= 0 {
+		errKey = i18n.StatusEOValueNotFound
+		return
+	}
+	var number int32
+	if ep.ResultType.ID == 550 {
+		// User-defined Archives must belong to the User-defined Category of the Execution Project
+		err = db.QueryRow("select count(id) from uda where id=$1 and udcid=$2 and dr=0", id, ep.UDC.ID).Scan(&number)
+	} else if table, ok := eoArchiveTables[ep.ResultType.ID]; ok {
+		err = db.QueryRow("select count(id) from "+table+" where id=$1 and dr=0", id).Scan(&number)
+	} else {
+		return
+	}
+	if err != nil {
+		zap.L().Error("ExecutionProject.checkValue db.QueryRow failed", zap.Int32("epaID", ep.ID), zap.Error(err))
+		return
+	}
+	if number == 0 {
+		errKey = i18n.StatusEOValueNotFound
+	}
+	return
+}
+
+// Check whether the value is a date or a date time
+func parsesAsDate(value string) bool {
+	for _, layout := range eoDateLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Check that the row has its required files and on-site photos.
+// errKey is empty when nothing is missing.
+func (row *ExecutionOrderRow) checkFiles() (errKey i18n.ResKey, err error) {
+	if row.IsRequireFile != 1 && row.IsOnsitePhoto != 1 {
+		return
+	}
+	fileIDs := make([]int32, 0, len(row.Files))
+	for _, f := range row.Files {
+		if f.File.ID > 0 && f.Dr == 0 {
+			fileIDs = append(fileIDs, f.File.ID)
+		}
+	}
+	var fileNumber, imageNumber int32
+	if len(fileIDs) > 0 {
+		sqlStr := `select count(id),count(id) filter (where isimage=1)
+		from filelist where id = any($1) and dr=0`
+		err = db.QueryRow(sqlStr, pq.Array(fileIDs)).Scan(&fileNumber, &imageNumber)
+		if err != nil {
+			zap.L().Error("ExecutionOrderRow.checkFiles db.QueryRow failed", zap.Error(err))
+			return
+		}
+	}
+	if row.IsRequireFile == 1 && fileNumber == 0 {
+		errKey = i18n.StatusEOFileRequired
+		return
+	}
+	if row.IsOnsitePhoto == 1 && imageNumber == 0 {
+		errKey = i18n.StatusEOPhotoRequired
+	}
+	return
+}
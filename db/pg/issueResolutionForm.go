@@ -1,7 +1,10 @@
 package pg
 
 import (
+	"database/sql"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/filter"
+	"sccsmsserver/pub"
 	"sccsmsserver/setting"
 	"strings"
 	"time"
@@ -48,7 +51,7 @@ type IssueResolutionForm struct {
 }
 
 // Add Issue Resolution Form
-func (irf *IssueResolutionForm) Add() (resStatus i18n.ResKey, err error) {
+func (irf *IssueResolutionForm) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 
 	// Begin a database transaction
@@ -59,6 +62,13 @@ func (irf *IssueResolutionForm) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Start the audit trail
+	at, err := beginAudit(tx, actor, pub.IRF, AuditActionAdd)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Get the latest serial number
 	irf.BillNumber, resStatus, err = GetLatestSerialNo(tx, "IRF")
 	if resStatus != i18n.StatusOK || err != nil {
@@ -124,17 +134,28 @@ func (irf *IssueResolutionForm) Add() (resStatus i18n.ResKey, err error) {
 			return
 		}
 	}
+	// Write the audit trail
+	err = at.write(irf.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Edit Issue Resolution Form
-func (irf *IssueResolutionForm) Edit() (resStatus i18n.ResKey, err error) {
+func (irf *IssueResolutionForm) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the cereator and modifier are the same person
 	if irf.Creator.ID != irf.Modifier.ID {
 		resStatus = i18n.StatusVoucherOnlyCreateEdit
 		return
 	}
+	// Vouchers waiting for approval can't be modified
+	resStatus, err = checkApprovalPending(pub.IRF, irf.ID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 	// Begin a transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -143,6 +164,13 @@ func (irf *IssueResolutionForm) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.IRF, AuditActionEdit, irf.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Modify Issue Resolution From in issueresoltionform table
 	editSql := `update issueresolutionform set billdate=$1,deptid=$2,handlerid=$3,isfinish=$4,starttime=$5,
@@ -230,11 +258,17 @@ func (irf *IssueResolutionForm) Edit() (resStatus i18n.ResKey, err error) {
 		}
 	}
 
+	// Write the audit trail
+	err = at.write(irf.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Delete Issue Resolution Form
-func (irf *IssueResolutionForm) Delete(modifyUserId int32) (resStatus i18n.ResKey, err error) {
+func (irf *IssueResolutionForm) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the Issue Resolution Form status
 	if irf.Status != 0 {
@@ -242,10 +276,15 @@ func (irf *IssueResolutionForm) Delete(modifyUserId int32) (resStatus i18n.ResKe
 		return
 	}
 	// Check if the Creator and Modifier are the same person
-	if irf.Creator.ID != modifyUserId {
+	if irf.Creator.ID != actor.UserID {
 		resStatus = i18n.StatusVoucherOnlyCreateEdit
 		return
 	}
+	// Vouchers waiting for approval can't be deleted
+	resStatus, err = checkApprovalPending(pub.IRF, irf.ID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 
 	// Begin a database transaction
 	tx, err := db.Begin()
@@ -255,11 +294,18 @@ func (irf *IssueResolutionForm) Delete(modifyUserId int32) (resStatus i18n.ResKe
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.IRF, AuditActionDelete, irf.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Modify the Issue Resolution Form delete flag to 1 in the issueresolutionform table
 	delSql := `update issueresolutionform set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp 
 	where id=$2 and dr=0 and ts=$3`
-	delRes, err := tx.Exec(delSql, modifyUserId, irf.ID, irf.Ts)
+	delRes, err := tx.Exec(delSql, actor.UserID, irf.ID, irf.Ts)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("IssueResolutionForm.Delete tx.Exec(delHeadSql) failed", zap.Error(err))
@@ -295,7 +341,7 @@ func (irf *IssueResolutionForm) Delete(modifyUserId int32) (resStatus i18n.ResKe
 		defer delFileStmt.Close()
 		// Write data to the issueresolutionform_file table
 		for _, row := range irf.FixFiles {
-			delFileRes, delFileErr := delFileStmt.Exec(modifyUserId, row.ID, row.BillBID, row.Ts)
+			delFileRes, delFileErr := delFileStmt.Exec(actor.UserID, row.ID, row.BillBID, row.Ts)
 			if delFileErr != nil {
 				resStatus = i18n.StatusInternalError
 				zap.L().Error("IssueResolutionForm.Delete delFileStmt.Exec() failed", zap.Error(delFileErr))
@@ -333,17 +379,44 @@ func (irf *IssueResolutionForm) Delete(modifyUserId int32) (resStatus i18n.ResKe
 		}
 	}
 
+	// Write the audit trail
+	err = at.write(irf.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
-// Confirm Issue Resolution Form
-func (irf *IssueResolutionForm) Confirm(confirmerID int32) (resStatus i18n.ResKey, err error) {
-	resStatus = i18n.StatusOK
-	// Check the Issue Resolution Form status
-	if irf.Status != 0 { // Must be 0
-		resStatus = i18n.StatusVoucherNoFree
+// Confirm Issue Resolution Form.
+// With an approval flow the Issue Resolution Form is submitted for approval instead,
+// it is confirmed once the last approval step is approved.
+func (irf *IssueResolutionForm) Confirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = submitForApproval(pub.IRF, irf.ID, actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return irf.confirm(actor)
+}
+
+// Confirm Issue Resolution Form without approval, then notify its creator
+func (irf *IssueResolutionForm) confirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = irf.writeConfirm(actor)
+	if resStatus != i18n.StatusOK || err != nil {
 		return
 	}
+	irf.confirmed(actor)
+	return
+}
+
+// Notify of the committed confirmation
+func (irf *IssueResolutionForm) confirmed(actor AuditActor) {
+	pushConfirmed(pub.IRF, irf.ID, actor.UserID)
+}
+
+// Write the confirmation of the Issue Resolution Form
+func (irf *IssueResolutionForm) writeConfirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
 	// Begin a database transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -352,14 +425,34 @@ func (irf *IssueResolutionForm) Confirm(confirmerID int32) (resStatus i18n.ResKe
 		return
 	}
 	defer tx.Commit()
+	resStatus, err = irf.writeConfirmTx(tx, actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		tx.Rollback()
+	}
+	return
+}
+
+// Write the confirmation of the Issue Resolution Form and its audit trail in the transaction
+func (irf *IssueResolutionForm) writeConfirmTx(tx *sql.Tx, actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	// Check the Issue Resolution Form status
+	if irf.Status != 0 { // Must be 0
+		resStatus = i18n.StatusVoucherNoFree
+		return
+	}
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.IRF, AuditActionConfirm, irf.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
 	// Write the confirmation infomation to the issueresolutionform table
 	sqlStr := `update issueresolutionform set status=1,confirmtime=current_timestamp,confirmerid=$1,ts=current_timestamp 
 	where id=$2 and dr=0 and status=0 and ts=$3`
-	confirmRes, err := tx.Exec(sqlStr, confirmerID, irf.ID, irf.Ts)
+	confirmRes, err := tx.Exec(sqlStr, actor.UserID, irf.ID, irf.Ts)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("IssueResolutionForm.Confirm tx.Exec(sqlStr) failed", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	// Check the number of rows affected by SQL statement
@@ -367,12 +460,10 @@ func (irf *IssueResolutionForm) Confirm(confirmerID int32) (resStatus i18n.ResKe
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("IssueResolutionForm.Confirm confirmRes.RowsAffected failed", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	if updateNumber < 1 {
 		resStatus = i18n.StatusOtherEdit
-		tx.Rollback()
 		return
 	}
 
@@ -386,15 +477,45 @@ func (irf *IssueResolutionForm) Confirm(confirmerID int32) (resStatus i18n.ResKe
 		edr.IRFNumber = irf.BillNumber
 		resStatus, err = edr.Complete()
 		if resStatus != i18n.StatusOK || err != nil {
-			tx.Rollback()
 			return
 		}
 	}
+	// Write the audit trail
+	err = at.write(irf.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+	}
+	return
+}
+
+// Confirm the Issue Resolution Form by ID in the transaction of its finished approval
+func confirmIRFByID(tx *sql.Tx, id int32, confirmer AuditActor) (confirmed func(), resStatus i18n.ResKey, err error) {
+	irf := &IssueResolutionForm{ID: id}
+	sqlStr := `select billnumber,status,sourcehid,sourcebid,ts
+	from issueresolutionform where id=$1 and dr=0`
+	err = db.QueryRow(sqlStr, id).Scan(&irf.BillNumber, &irf.Status, &irf.SourceHID, &irf.SourceBID, &irf.Ts)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("confirmIRFByID db.QueryRow failed", zap.Error(err))
+		return
+	}
+	resStatus, err = irf.writeConfirmTx(tx, confirmer)
+	confirmed = func() { irf.confirmed(confirmer) }
 	return
 }
 
-// UnConfirm Issue Resolution Form
-func (irf *IssueResolutionForm) UnConfirm(confirmerID int32) (resStatus i18n.ResKey, err error) {
+// UnConfirm Issue Resolution Form.
+// Its approval is canceled, it has to be approved again before it is confirmed.
+func (irf *IssueResolutionForm) UnConfirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = irf.unConfirm(actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return cancelApproval(pub.IRF, irf.ID, actor.UserID)
+}
+
+// UnConfirm Issue Resolution Form without touching its approval
+func (irf *IssueResolutionForm) unConfirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check the Issue Resolution Form status
 	if irf.Status != 1 { // Must be 1
@@ -402,7 +523,7 @@ func (irf *IssueResolutionForm) UnConfirm(confirmerID int32) (resStatus i18n.Res
 		return
 	}
 	// Check if the UnConfirmer and confirmer are the same person
-	if irf.Confirmer.ID != confirmerID {
+	if irf.Confirmer.ID != actor.UserID {
 		resStatus = i18n.StatusVoucherCancelConfirmSelf
 		return
 	}
@@ -414,6 +535,13 @@ func (irf *IssueResolutionForm) UnConfirm(confirmerID int32) (resStatus i18n.Res
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.IRF, AuditActionUnConfirm, irf.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Update the Confirm information in the issueresolutionform table
 	sqlStr := `update issueresolutionform set status=0,confirmerid=0,confirmtime=to_timestamp(0),ts=current_timestamp 
 	where id=$1 and dr=0 and status=1 and ts=$2`
@@ -452,12 +580,55 @@ func (irf *IssueResolutionForm) UnConfirm(confirmerID int32) (resStatus i18n.Res
 			return
 		}
 	}
+	// Write the audit trail
+	err = at.write(irf.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
+// Filterable fields of the Issue Resolution Form list
+var irfListFields = filter.Fields{
+	"billNumber":       {Column: "b.billnumber", Type: filter.String},
+	"billDate":         {Column: "b.billdate", Type: filter.Time},
+	"description":      {Column: "b.description", Type: filter.String},
+	"status":           {Column: "b.status", Type: filter.Number},
+	"isFinish":         {Column: "b.isfinish", Type: filter.Number},
+	"sourceType":       {Column: "b.sourcetype", Type: filter.String},
+	"sourceBillNumber": {Column: "b.sourcebillnumber", Type: filter.String},
+	"csaID":            {Column: "b.csaid", Type: filter.Number},
+	"csaCode":          {Column: "cs.code", Type: filter.String},
+	"csaName":          {Column: "cs.name", Type: filter.String},
+	"epaID":            {Column: "b.epaid", Type: filter.Number},
+	"epaCode":          {Column: "ep.code", Type: filter.String},
+	"epaName":          {Column: "ep.name", Type: filter.String},
+	"executorID":       {Column: "b.executorid", Type: filter.Number},
+	"executorName":     {Column: "executor.name", Type: filter.String},
+	"issueOwnerID":     {Column: "b.issueownerid", Type: filter.Number},
+	"issueOwnerName":   {Column: "issueowner.name", Type: filter.String},
+	"handlerID":        {Column: "b.handlerid", Type: filter.Number},
+	"riskLevelID":      {Column: "b.risklevelid", Type: filter.Number},
+	"departmentID":     {Column: "b.deptid", Type: filter.Number},
+	"departmentName":   {Column: "dept.name", Type: filter.String},
+	"startTime":        {Column: "b.starttime", Type: filter.Time},
+	"endTime":          {Column: "b.endtime", Type: filter.Time},
+	"creatorID":        {Column: "b.creatorid", Type: filter.Number},
+	"createDate":       {Column: "b.createtime", Type: filter.Time},
+	"confirmDate":      {Column: "b.confirmtime", Type: filter.Time},
+}
+
 // Get the Issue Resolution Form List
-func GetIRFList(queryString string) (irfs []IssueResolutionForm, resStatus i18n.ResKey, err error) {
+func GetIRFList(queryFilter filter.Group, ds DataScope) (irfs []IssueResolutionForm, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
+	// Restrict the query to the data scope of the user
+	queryFilter = ds.Restrict(queryFilter, "departmentID", "creatorID")
+	// Compile the query filter
+	whereSql, args, resStatus := compileFilter(queryFilter, irfListFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Assemble the SQL for checking
 	build.WriteString(`select count(b.id) as rownumber 
@@ -468,15 +639,15 @@ func GetIRFList(queryString string) (irfs []IssueResolutionForm, resStatus i18n.
 	left join sysuser as issueowner on b.issueownerid = issueowner.id
 	left join department as dept on b.deptid = dept.id
 	where (b.dr=0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 	// Check
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, args...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetIRFList db.QueryRow(checkSql) failed", zap.Error(err))
@@ -506,14 +677,14 @@ func GetIRFList(queryString string) (irfs []IssueResolutionForm, resStatus i18n.
 	left join sysuser as issueowner on b.issueownerid = issueowner.id
 	left join department as dept on b.deptid = dept.id
 	where (b.dr=0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	irfsSql := build.String()
 	// Retrieve the list of IRF from database
-	ddsRows, err := db.Query(irfsSql)
+	ddsRows, err := db.Query(irfsSql, args...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetIRFList db.Query failed", zap.Error(err))
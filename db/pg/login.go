@@ -4,16 +4,10 @@ import (
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
-	"fmt"
 	"sccsmsserver/cache"
 	"sccsmsserver/i18n"
-	"sccsmsserver/pkg/jwt"
-	"sccsmsserver/pkg/mysf"
 	"sccsmsserver/pkg/security"
-	"sccsmsserver/pub"
 	"sccsmsserver/setting"
-	"strconv"
-	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -26,15 +20,21 @@ type ParamLogin struct {
 	ClientIP   string `json:"clientIp"`
 	ClientType string `json:"clientType"`
 	UserAgent  string `json:"userAgent"`
+	// Key ID of the RSA public key the password is encrypted with
+	KeyID string `json:"keyId"`
+	// Issue a refresh token with the access token, the response data is then a TokenPair
+	IssueRefreshToken bool `json:"issueRefreshToken"`
 }
 
 // User login failure record struct
 type UserLoginFault struct {
+	ID        int32     `db:"id" json:"id"`
 	UserID    int32     `db:"userid" json:"userID"`
 	UserCode  string    `db:"usercode" json:"userCode"`
 	ClientIp  string    `db:"clientip" json:"clientIp"`
 	UserAgent string    `db:"useragent" json:"useragent"`
-	Type      int16     `db:"type" json:"type"` //0 default 1 invalid: password 2 User does not exist
+	Type      int16     `db:"type" json:"type"`       //0 default 1 invalid: password 2 User does not exist
+	Cleared   int16     `db:"cleared" json:"cleared"` // 1: cleared when an administrator unlocked the user or IP address
 	Ts        time.Time `db:"ts" json:"ts"`
 }
 
@@ -45,8 +45,7 @@ type IpLock struct {
 }
 
 // User login
-func Login(p *ParamLogin) (resStatus i18n.ResKey, token string, err error) {
-	token = ""
+func Login(p *ParamLogin) (resStatus i18n.ResKey, tp TokenPair, err error) {
 	// RSA decrypt the password field
 	op, err := base64.StdEncoding.DecodeString(p.Password)
 	if err != nil {
@@ -54,10 +53,10 @@ func Login(p *ParamLogin) (resStatus i18n.ResKey, token string, err error) {
 		zap.L().Error("Login base64.StdEncoding.DecodeString failed", zap.Error(err))
 		return
 	}
-	oriPassword, err := security.ScRsa.Decrypt(op)
+	oriPassword, err := security.Decrypt(p.KeyID, op)
 	if err != nil {
 		resStatus = i18n.CodeInternalError
-		zap.L().Error("Login security.ScRsa.Decrypt(op) failed", zap.Error(err))
+		zap.L().Error("Login security.Decrypt(op) failed", zap.Error(err))
 		return
 	}
 	p.Password = string(oriPassword)
@@ -66,93 +65,133 @@ func Login(p *ParamLogin) (resStatus i18n.ResKey, token string, err error) {
 		Code:     p.UserCode,
 		Password: p.Password,
 	}
-	// Record the password used during user login
-	oPassword := user.Password
-
 	// Check if the user exists.
-	sqlStr := "select id,password,status,locked from sysuser where code = $1 and dr=0 and isoperator=1 limit 1"
-	err = db.QueryRow(sqlStr, user.Code).Scan(&user.ID, &user.Password, &user.Status, &user.Locked)
+	var pwdChangeTime time.Time
+	pwdChange := i18n.StatusOK
+	sqlStr := `select id,password,status,locked,authsource,mustchangepwd,pwdchangetime
+	from sysuser where code = $1 and dr=0 and isoperator=1 limit 1`
+	err = db.QueryRow(sqlStr, user.Code).Scan(&user.ID, &user.Password, &user.Status, &user.Locked, &user.AuthSource,
+		&user.MustChangePwd, &pwdChangeTime)
 	if err != nil && err != sql.ErrNoRows {
 		resStatus = i18n.CodeInternalError
 		zap.L().Error("Login query user information failed:", zap.Error(err))
 		return
 	}
 	if err == sql.ErrNoRows {
+		// Users that don't exist yet may be created from an external directory
 		resStatus = i18n.StatusUserNotExist
-		// Process when the user doesn't exist
-		var ulf UserLoginFault
-		ulf.UserID = 0
-		ulf.UserCode = p.UserCode
-		ulf.ClientIp = p.ClientIP
-		ulf.UserAgent = p.UserAgent
-		ulf.Type = 2
-		ulf.process()
-		return
+		err = nil
+		for _, source := range provisioningSources {
+			resStatus, err = authenticators[source].Authenticate(p, user)
+			if resStatus != i18n.StatusUserNotExist || err != nil {
+				break
+			}
+		}
+		if resStatus == i18n.StatusUserNotExist || resStatus == i18n.StatusInvalidPassword {
+			// Process when the user doesn't exist
+			var ulf UserLoginFault
+			ulf.UserID = 0
+			ulf.UserCode = p.UserCode
+			ulf.ClientIp = p.ClientIP
+			ulf.UserAgent = p.UserAgent
+			ulf.Type = 2
+			ulf.process()
+			return
+		}
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	} else {
+		// Check if the user is disabled.
+		if user.Status != 0 {
+			resStatus = i18n.StatusUserDisabled
+			return
+		}
+		// Check if the user is locked
+		if user.Locked != 0 {
+			resStatus = i18n.StatusUserLocked
+			return
+		}
+		// Verify the credentials with the source of the user
+		authenticator, ok := authenticators[user.AuthSource]
+		if !ok {
+			resStatus = i18n.StatusAuthSourceUnavailable
+			zap.L().Warn("Login authenticator not enabled", zap.String("user", p.UserCode), zap.String("authSource", user.AuthSource))
+			return
+		}
+		resStatus, err = authenticator.Authenticate(p, user)
+		if resStatus == i18n.StatusInvalidPassword {
+			// Process if the passwords don't match
+			var ulfp UserLoginFault
+			ulfp.UserID = user.ID
+			ulfp.UserCode = user.Code
+			ulfp.ClientIp = p.ClientIP
+			ulfp.UserAgent = p.UserAgent
+			ulfp.Type = 1
+			ulfp.process()
+			return
+		}
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+		// Check if the password has to be changed, after the second factor
+		if user.AuthSource == AuthSourceLocal {
+			pwdChange = passwordChangeStatus(p.Password, user.MustChangePwd, pwdChangeTime)
+		}
 	}
 
-	// Check if the user is disabled.
-	if user.Status != 0 {
-		resStatus = i18n.StatusUserDisabled
-		return
-	}
-	// Check if the user is locked
-	if user.Locked != 0 {
-		resStatus = i18n.StatusUserLocked
-		return
-	}
+	tp, resStatus, err = continueLogin(user.ID, p.UserCode, p.ClientType, p.ClientIP, p.UserAgent, p.IssueRefreshToken, pwdChange)
+	return
+}
 
-	// Check if the password matchs.
-	password := encryptPassword(oPassword)
-	result := strings.EqualFold(user.Password, password)
-
-	if !result {
-		resStatus = i18n.StatusInvalidPassword
-		// Process if the passwords don't match
-		var ulfp UserLoginFault
-		ulfp.UserID = user.ID
-		ulfp.UserCode = user.Code
-		ulfp.ClientIp = p.ClientIP
-		ulfp.UserAgent = p.UserAgent
-		ulfp.Type = 1
-		ulfp.process()
-		return
+// Continue the login after the user is authenticated.
+// Users with TOTP enabled, or required by a role, get a pre-auth token for the second step.
+// pwdChange is the password change the user has to make before the login completes, StatusOK for none.
+// It is only offered after the second factor, so that the password alone can't replace the password.
+func continueLogin(userID int32, userCode string, clientType string, clientIP string, userAgent string, issueRefreshToken bool, pwdChange i18n.ResKey) (tp TokenPair, resStatus i18n.ResKey, err error) {
+	pa := preAuth{
+		UserID:            userID,
+		UserCode:          userCode,
+		ClientType:        clientType,
+		IssueRefreshToken: issueRefreshToken,
 	}
-
-	// Generate Token ID
-	tokenID := strconv.FormatInt(mysf.GenID(), 10)
-	// Generate Json Web Token (JWT)
-	token, expireTime, err := jwt.GenToken(user.ID, user.Code, tokenID)
+	if pwdChange != i18n.StatusOK {
+		pa.PwdChange = pwdChange
+	}
+	// Check if the second factor is required
+	totpEnabled, totpRequired, err := getUserTOTPState(userID)
 	if err != nil {
 		resStatus = i18n.CodeInternalError
-		zap.L().Error("Login GenToken failed", zap.Error(err))
 		return
 	}
-
-	// Get Person information
-	person := Person{
-		ID: user.ID,
-	}
-	resStatus, err = person.GetPersonInfoByID()
-	if err != nil {
+	if totpEnabled || totpRequired {
+		pa.Step = preAuthStepTOTP
+		tp.PreAuthToken, tp.PreAuthExpireTime, resStatus, err = createPreAuth(pa)
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+		resStatus = i18n.StatusTOTPRequired
+		if !totpEnabled {
+			resStatus = i18n.StatusTOTPEnrollRequired
+		}
 		return
 	}
+	return finishLogin(pa, clientIP, userAgent)
+}
 
-	// Add the user to online users.
-	ou := OnlineUser{
-		User:       person,
-		TokenID:    tokenID,
-		ClientType: p.ClientType,
-		FromIp:     p.ClientIP,
-		ExpireTime: expireTime,
+// Finish the login after the second factor.
+// A user who has to change the password gets a pre-auth token for the change, otherwise the tokens are issued.
+func finishLogin(pa preAuth, clientIP string, userAgent string) (tp TokenPair, resStatus i18n.ResKey, err error) {
+	if pa.PwdChange == "" {
+		return completeLogin(pa.UserID, pa.UserCode, pa.ClientType, clientIP, userAgent, pa.IssueRefreshToken)
 	}
-
-	resStatus, err = ou.Add()
-	if err != nil {
+	pa.Step = preAuthStepChangePwd
+	pa.Attempts = 0
+	tp.PreAuthToken, tp.PreAuthExpireTime, resStatus, err = createPreAuth(pa)
+	if resStatus != i18n.StatusOK || err != nil {
 		return
 	}
-	zap.L().Info("login Success", zap.String("user:", p.UserCode))
-
-	resStatus = i18n.StatusOK
+	resStatus = pa.PwdChange
 	return
 }
 
@@ -197,7 +236,7 @@ func (ulf *UserLoginFault) handlingInvalidPassword() (err error) {
 	var pwdFaultNum int32
 	sqlStr := `select count(id) as faultnum from sysloginfault 
 	where ts > (current_timestamp - interval '30 minutes') 
-	and type=1 
+	and type=1 and cleared=0 
 	and userid=$1`
 	err = db.QueryRow(sqlStr, &ulf.UserID).Scan(&pwdFaultNum)
 	if err != nil {
@@ -224,10 +263,15 @@ func lockUser(userID int32) (err error) {
 
 // User not exist error handling
 func (ulf *UserLoginFault) handlingUserNotExist() (err error) {
+	// IP addresses on the allow list are never locked
+	accessType, err := CheckIPAccess(ulf.ClientIp)
+	if err != nil || accessType == IPAccessAllow {
+		return
+	}
 	var userNotExistNum int32
 	sqlStr := `select count(id) as falultnum from sysloginfault 
 	where ts > (current_timestamp - interval '30 minutes') 
-	and type=2 and clientip=$1`
+	and type=2 and cleared=0 and clientip=$1`
 	err = db.QueryRow(sqlStr, ulf.ClientIp).Scan(&userNotExistNum)
 	if err != nil {
 		zap.L().Error("UserLoginFault TreatmentUserNotExist", zap.Error(err))
@@ -235,10 +279,9 @@ func (ulf *UserLoginFault) handlingUserNotExist() (err error) {
 	}
 	if userNotExistNum > setting.Conf.IpLockTh {
 		// Write the IP lockout record to cache.
-		key := fmt.Sprintf("%s%s%s", pub.IPBlack, ":", ulf.ClientIp)
 		l := IpLock{ulf.ClientIp, time.Now()}
 		jsonL, _ := json.Marshal(l)
-		err = cache.SetOther(key, jsonL)
+		err = cache.SetOther(ipLockKey(ulf.ClientIp), jsonL)
 	}
 	return
 }
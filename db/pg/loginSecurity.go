@@ -0,0 +1,589 @@
+package pg
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/netip"
+	"sccsmsserver/cache"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/filter"
+	"sccsmsserver/pub"
+	"sccsmsserver/setting"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// IP address access types
+const (
+	IPAccessNone  int16 = 0 // No rule matches the IP address
+	IPAccessAllow int16 = 1 // Never locked by login failures
+	IPAccessDeny  int16 = 2 // All requests are rejected
+)
+
+// IP address access rule
+type IPAccessRule struct {
+	ID          int32     `db:"id" json:"id"`
+	CIDR        string    `db:"cidr" json:"cidr"`
+	AccessType  int16     `db:"accesstype" json:"accessType"` // 1 allow 2 deny
+	Description string    `db:"description" json:"description"`
+	CreateDate  time.Time `db:"createtime" json:"createDate"`
+	Creator     Person    `db:"creatorid" json:"creator"`
+	ModifyDate  time.Time `db:"modifytime" json:"modifyDate"`
+	Modifier    Person    `db:"modifierid" json:"modifier"`
+	Ts          time.Time `db:"ts" json:"ts"`
+	Dr          int16     `db:"dr" json:"dr"`
+}
+
+// Locked IP address
+type LockedIP struct {
+	ClientIp   string    `json:"clientIp"`
+	StartTime  time.Time `json:"startTime"`
+	ExpireTime time.Time `json:"expireTime"`
+}
+
+// Locked user
+type LockedUser struct {
+	User          Person    `json:"user"`
+	FaultNumber   int32     `json:"faultNumber"`
+	LastFaultTime time.Time `json:"lastFaultTime"`
+	LastFaultIp   string    `json:"lastFaultIp"`
+}
+
+// Login failure record pagination
+type LoginFaultsPaging struct {
+	Faults  []UserLoginFault `json:"faults"`
+	Count   int32            `json:"count"`
+	Page    int32            `json:"page"`
+	PerPage int32            `json:"perPage"`
+}
+
+// Login failure record query fields
+var loginFaultFields = filter.Fields{
+	"userID":    {Column: "userid", Type: filter.Number},
+	"userCode":  {Column: "usercode", Type: filter.String},
+	"clientIp":  {Column: "clientip", Type: filter.String},
+	"userAgent": {Column: "useragent", Type: filter.String},
+	"type":      {Column: "type", Type: filter.Number},
+	"cleared":   {Column: "cleared", Type: filter.Number},
+	"ts":        {Column: "ts", Type: filter.Time},
+}
+
+// Cache key of the IP address lockout
+func ipLockKey(clientIp string) string {
+	return fmt.Sprintf("%s%s%s", pub.IPBlack, ":", clientIp)
+}
+
+// Cache key of the IP address access rules
+func ipAccessKey() string {
+	return fmt.Sprintf("%s%s%s", pub.IPAccess, ":", "rules")
+}
+
+// Normalize an IP address or CIDR block, a single address becomes a host prefix
+func normalizeCIDR(s string) (cidr string, ok bool) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return "", false
+		}
+		return prefix.Masked().String(), true
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return "", false
+	}
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()).String(), true
+}
+
+// Get the IP address access rules
+func GetIPAccessRules() (rules []IPAccessRule, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	rules = make([]IPAccessRule, 0)
+	sqlStr := `select id,cidr,accesstype,description,createtime,
+	creatorid,modifytime,modifierid,ts,dr
+	from sysipaccess
+	where dr=0 order by accesstype,cidr`
+	rows, err := db.Query(sqlStr)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetIPAccessRules db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r IPAccessRule
+		err = rows.Scan(&r.ID, &r.CIDR, &r.AccessType, &r.Description, &r.CreateDate,
+			&r.Creator.ID, &r.ModifyDate, &r.Modifier.ID, &r.Ts, &r.Dr)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("GetIPAccessRules rows.Scan failed", zap.Error(err))
+			return
+		}
+		// Get creator detail
+		if r.Creator.ID > 0 {
+			resStatus, err = r.Creator.GetPersonInfoByID()
+			if resStatus != i18n.StatusOK || err != nil {
+				return
+			}
+		}
+		// Get modifier detail
+		if r.Modifier.ID > 0 {
+			resStatus, err = r.Modifier.GetPersonInfoByID()
+			if resStatus != i18n.StatusOK || err != nil {
+				return
+			}
+		}
+		rules = append(rules, r)
+	}
+	return
+}
+
+// Get the IP address access rules used to check requests.
+// The rules are cached, the cache is cleared whenever a rule changes.
+func getIPAccessPrefixes() (prefixes map[netip.Prefix]int16, err error) {
+	type cachedRule struct {
+		CIDR       string `json:"cidr"`
+		AccessType int16  `json:"accessType"`
+	}
+	cached := make([]cachedRule, 0)
+	key := ipAccessKey()
+	exist, v, err := cache.GetOther(key)
+	if err != nil {
+		zap.L().Error("getIPAccessPrefixes cache.GetOther failed", zap.Error(err))
+		return
+	}
+	if exist == 1 {
+		err = json.Unmarshal(v, &cached)
+		if err != nil {
+			zap.L().Error("getIPAccessPrefixes json.Unmarshal failed", zap.Error(err))
+			return
+		}
+	} else {
+		rows, errQuery := db.Query("select cidr,accesstype from sysipaccess where dr=0")
+		if errQuery != nil {
+			zap.L().Error("getIPAccessPrefixes db.Query failed", zap.Error(errQuery))
+			return nil, errQuery
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var r cachedRule
+			err = rows.Scan(&r.CIDR, &r.AccessType)
+			if err != nil {
+				zap.L().Error("getIPAccessPrefixes rows.Scan failed", zap.Error(err))
+				return
+			}
+			cached = append(cached, r)
+		}
+		v, _ = json.Marshal(cached)
+		_ = cache.SetOther(key, v)
+	}
+	prefixes = make(map[netip.Prefix]int16, len(cached))
+	for _, r := range cached {
+		prefix, errParse := netip.ParsePrefix(r.CIDR)
+		if errParse != nil {
+			zap.L().Warn("getIPAccessPrefixes invalid cidr", zap.String("cidr", r.CIDR))
+			continue
+		}
+		// Deny wins over allow on the same block
+		if prefixes[prefix] != IPAccessDeny {
+			prefixes[prefix] = r.AccessType
+		}
+	}
+	return
+}
+
+// Check the access type of the IP address.
+// The most specific matching rule applies, deny wins over allow on the same block.
+func CheckIPAccess(clientIp string) (accessType int16, err error) {
+	addr, errParse := netip.ParseAddr(clientIp)
+	if errParse != nil {
+		return IPAccessNone, nil
+	}
+	addr = addr.Unmap()
+	prefixes, err := getIPAccessPrefixes()
+	if err != nil {
+		return
+	}
+	bits := -1
+	for prefix, t := range prefixes {
+		if !prefix.Contains(addr) {
+			continue
+		}
+		if prefix.Bits() > bits || (prefix.Bits() == bits && t == IPAccessDeny) {
+			bits = prefix.Bits()
+			accessType = t
+		}
+	}
+	return
+}
+
+// Check the IP address access rule
+func (r *IPAccessRule) check() (resStatus i18n.ResKey) {
+	resStatus = i18n.StatusOK
+	cidr, ok := normalizeCIDR(r.CIDR)
+	if !ok {
+		return i18n.StatusIPAccessInvalid
+	}
+	if r.AccessType != IPAccessAllow && r.AccessType != IPAccessDeny {
+		return i18n.StatusIPAccessInvalid
+	}
+	r.CIDR = cidr
+	return
+}
+
+// Check if the IP address block already has a rule
+func (r *IPAccessRule) CheckCIDRExist() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	var count int32
+	sqlStr := "select count(id) from sysipaccess where dr=0 and cidr=$1 and id <> $2"
+	err = db.QueryRow(sqlStr, r.CIDR, r.ID).Scan(&count)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("IPAccessRule.CheckCIDRExist db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if count > 0 {
+		resStatus = i18n.StatusIPAccessExist
+	}
+	return
+}
+
+// Add IP address access rule
+func (r *IPAccessRule) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus = r.check()
+	if resStatus != i18n.StatusOK {
+		return
+	}
+	resStatus, err = r.CheckCIDRExist()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return writeAudited(actor, pub.IPAccess, AuditActionAdd, &r.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `insert into sysipaccess(cidr,accesstype,description,creatorid)
+		values($1,$2,$3,$4)
+		returning id,createtime,ts`
+		err = tx.QueryRow(sqlStr, r.CIDR, r.AccessType, r.Description, r.Creator.ID).Scan(&r.ID, &r.CreateDate, &r.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("IPAccessRule.Add tx.QueryRow failed", zap.Error(err))
+			return
+		}
+		r.DelFromLocalCache()
+		return
+	})
+}
+
+// Modify IP address access rule
+func (r *IPAccessRule) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus = r.check()
+	if resStatus != i18n.StatusOK {
+		return
+	}
+	resStatus, err = r.CheckCIDRExist()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return writeAudited(actor, pub.IPAccess, AuditActionEdit, &r.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update sysipaccess set cidr=$1,accesstype=$2,description=$3,modifierid=$4,
+		modifytime=current_timestamp,ts=current_timestamp
+		where id=$5 and ts=$6 and dr=0`
+		res, err := tx.Exec(sqlStr, r.CIDR, r.AccessType, r.Description, r.Modifier.ID, r.ID, r.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("IPAccessRule.Edit tx.Exec failed", zap.Error(err))
+			return
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("IPAccessRule.Edit res.RowsAffected failed", zap.Error(err))
+			return
+		}
+		// Someone else has already updated the data
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		r.DelFromLocalCache()
+		return
+	})
+}
+
+// Delete IP address access rule
+func (r *IPAccessRule) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	return writeAudited(actor, pub.IPAccess, AuditActionDelete, &r.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update sysipaccess set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp
+		where id=$2 and ts=$3 and dr=0`
+		res, err := tx.Exec(sqlStr, r.Modifier.ID, r.ID, r.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("IPAccessRule.Delete tx.Exec failed", zap.Error(err))
+			return
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("IPAccessRule.Delete res.RowsAffected failed", zap.Error(err))
+			return
+		}
+		// Someone else has already updated the data
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		r.DelFromLocalCache()
+		return
+	})
+}
+
+// Delete the IP address access rules from cache
+func (r *IPAccessRule) DelFromLocalCache() {
+	_ = cache.DelOther(ipAccessKey())
+}
+
+// Get the IP address lockout from cache
+func getIPLock(clientIp string) (lip LockedIP, exist bool, err error) {
+	number, v, err := cache.GetOther(ipLockKey(clientIp))
+	if err != nil {
+		zap.L().Error("getIPLock cache.GetOther failed", zap.Error(err))
+		return
+	}
+	if number == 0 {
+		return
+	}
+	var ipLock IpLock
+	err = json.Unmarshal(v, &ipLock)
+	if err != nil {
+		zap.L().Error("getIPLock json.Unmarshal failed", zap.Error(err))
+		return
+	}
+	lip = LockedIP{
+		ClientIp:   ipLock.ClientIp,
+		StartTime:  ipLock.StartTime,
+		ExpireTime: ipLock.StartTime.Add(time.Minute * time.Duration(setting.Conf.IpLockedMinutes)),
+	}
+	return lip, time.Now().Before(lip.ExpireTime), nil
+}
+
+// Get the currently locked IP addresses.
+// An IP address is locked after a login failure, so only the addresses that failed recently are checked.
+func GetLockedIPs() (lips []LockedIP, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	lips = make([]LockedIP, 0)
+	sqlStr := `select distinct clientip from sysloginfault
+	where type=2 and cleared=0 and ts > (current_timestamp - make_interval(mins => $1))`
+	rows, err := db.Query(sqlStr, setting.Conf.IpLockedMinutes)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetLockedIPs db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var clientIp string
+		err = rows.Scan(&clientIp)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("GetLockedIPs rows.Scan failed", zap.Error(err))
+			return
+		}
+		lip, exist, errGet := getIPLock(clientIp)
+		if errGet != nil {
+			resStatus = i18n.StatusInternalError
+			return lips, resStatus, errGet
+		}
+		if exist {
+			lips = append(lips, lip)
+		}
+	}
+	return
+}
+
+// Unlock the IP address.
+// The login failures that caused the lockout no longer count towards a new lockout.
+func (lip *LockedIP) Unlock(operatorID int32) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	err = cache.DelOther(ipLockKey(lip.ClientIp))
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("LockedIP.Unlock cache.DelOther failed", zap.Error(err))
+		return
+	}
+	_, err = db.Exec("update sysloginfault set cleared=1 where clientip=$1 and type=2 and cleared=0", lip.ClientIp)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("LockedIP.Unlock db.Exec failed", zap.Error(err))
+		return
+	}
+	zap.L().Info("IP address unlocked", zap.String("clientIp", lip.ClientIp), zap.Int32("operatorID", operatorID))
+	return
+}
+
+// Get the locked users
+func GetLockedUsers() (lus []LockedUser, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	lus = make([]LockedUser, 0)
+	sqlStr := `select u.id,
+	(select count(f.id) from sysloginfault as f where f.userid=u.id and f.type=1 and f.cleared=0
+		and f.ts > (current_timestamp - interval '30 minutes')),
+	f.ts,f.clientip
+	from sysuser as u
+	left join lateral (select ts,clientip from sysloginfault
+		where userid=u.id and type=1 order by id desc limit 1) as f on true
+	where u.locked=1 and u.dr=0
+	order by u.code`
+	rows, err := db.Query(sqlStr)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetLockedUsers db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var lu LockedUser
+		var lastFaultTime sql.NullTime
+		var lastFaultIp sql.NullString
+		err = rows.Scan(&lu.User.ID, &lu.FaultNumber, &lastFaultTime, &lastFaultIp)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("GetLockedUsers rows.Scan failed", zap.Error(err))
+			return
+		}
+		lu.LastFaultTime = lastFaultTime.Time
+		lu.LastFaultIp = lastFaultIp.String
+		resStatus, err = lu.User.GetPersonInfoByID()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+		lus = append(lus, lu)
+	}
+	return
+}
+
+// Unlock the user.
+// The invalid password failures that caused the lockout no longer count towards a new lockout.
+func (lu *LockedUser) Unlock(operatorID int32) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("LockedUser.Unlock db.Begin failed", zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	sqlStr := `update sysuser set locked=0,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp
+	where id=$2 and dr=0`
+	res, err := tx.Exec(sqlStr, operatorID, lu.User.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("LockedUser.Unlock tx.Exec failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("LockedUser.Unlock res.RowsAffected failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	if affected < 1 {
+		resStatus = i18n.StatusUserNotExist
+		tx.Rollback()
+		return
+	}
+	_, err = tx.Exec("update sysloginfault set cleared=1 where userid=$1 and type=1 and cleared=0", lu.User.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("LockedUser.Unlock tx.Exec update sysloginfault failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	user := User{ID: lu.User.ID}
+	user.DelFromLocalCache()
+	zap.L().Info("User unlocked", zap.Int32("userID", lu.User.ID), zap.Int32("operatorID", operatorID))
+	return
+}
+
+// Get the login failure records by pagination
+func GetLoginFaultsPagination(con PagingQueryParams) (lfp LoginFaultsPaging, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	lfp.Faults = make([]UserLoginFault, 0)
+	whereSql, args, resStatus := compileFilter(con.Filter, loginFaultFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
+	var build strings.Builder
+	// Assemble the SQL for checking
+	build.WriteString("select count(id) as rownumber from sysloginfault where dr=0")
+	if whereSql != "" {
+		build.WriteString(" and (")
+		build.WriteString(whereSql)
+		build.WriteString(")")
+	}
+	err = db.QueryRow(build.String(), args...).Scan(&lfp.Count)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetLoginFaultsPagination db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if lfp.Count == 0 {
+		resStatus = i18n.StatusResNoData
+		return
+	}
+	if lfp.Count > setting.Conf.PqConfig.MaxRecord {
+		resStatus = i18n.StatusOverRecord
+		return
+	}
+	// Recalculate pagination
+	if con.PerPage <= 0 || con.PerPage > lfp.Count {
+		con.Page = 0
+		con.PerPage = lfp.Count
+	} else {
+		var totalPage = int32(math.Ceil(float64(lfp.Count) / float64(con.PerPage)))
+		if (con.Page + 1) > totalPage {
+			con.Page = totalPage - 1
+		}
+	}
+	lfp.Page = con.Page
+	lfp.PerPage = con.PerPage
+	build.Reset()
+	// Assemble the SQL for data retrieve
+	build.WriteString(`select id,userid,coalesce(usercode,''),coalesce(clientip,''),coalesce(useragent,''),
+	type,cleared,ts
+	from sysloginfault where dr=0`)
+	if whereSql != "" {
+		build.WriteString(" and (")
+		build.WriteString(whereSql)
+		build.WriteString(")")
+	}
+	limitIndex := len(args) + 1
+	build.WriteString(fmt.Sprintf(" order by id desc limit $%d offset $%d", limitIndex, limitIndex+1))
+	args = append(args, con.PerPage, con.Page*con.PerPage)
+	rows, err := db.Query(build.String(), args...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetLoginFaultsPagination db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f UserLoginFault
+		err = rows.Scan(&f.ID, &f.UserID, &f.UserCode, &f.ClientIp, &f.UserAgent,
+			&f.Type, &f.Cleared, &f.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("GetLoginFaultsPagination rows.Scan failed", zap.Error(err))
+			return
+		}
+		lfp.Faults = append(lfp.Faults, f)
+	}
+	return
+}
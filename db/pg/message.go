@@ -2,8 +2,8 @@ package pg
 
 import (
 	"database/sql"
-	"fmt"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/filter"
 	"sccsmsserver/setting"
 	"strconv"
 	"strings"
@@ -160,10 +160,32 @@ func GetUserUnReadComments(userID int32) (comments []CommentMessage, resStatus i
 	return
 }
 
+// Filterable fields of the read comment list
+var readCommentFields = filter.Fields{
+	"billNumber": {Column: "c.billnumber", Type: filter.String},
+	"rowNumber":  {Column: "c.rownumber", Type: filter.Number},
+	"content":    {Column: "c.content", Type: filter.String},
+	"sendTime":   {Column: "c.sendtime", Type: filter.Time},
+	"readTime":   {Column: "c.readtime", Type: filter.Time},
+	"senderID":   {Column: "c.creatorid", Type: filter.Number},
+	"csaID":      {Column: "h.csaid", Type: filter.Number},
+	"csaCode":    {Column: "csa.code", Type: filter.String},
+	"csaName":    {Column: "csa.name", Type: filter.String},
+	"epaID":      {Column: "b.epaid", Type: filter.Number},
+	"epaCode":    {Column: "epa.code", Type: filter.String},
+	"epaName":    {Column: "epa.name", Type: filter.String},
+}
+
 // Get User Read Comments
-func GetUserReadComments(userID int32, queryString string) (comments []CommentMessage, resStatus i18n.ResKey, err error) {
+func GetUserReadComments(userID int32, queryFilter filter.Group) (comments []CommentMessage, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	comments = make([]CommentMessage, 0)
+	// Compile the query filter, the user ID is bound to $1
+	whereSql, args, resStatus := compileFilter(queryFilter, readCommentFields, 2)
+	if resStatus != i18n.StatusOK {
+		return
+	}
+	args = append([]interface{}{userID}, args...)
 	var build strings.Builder
 	// Concatenate the SQL string for inspection
 	build.WriteString(`select count(c.id) as rowcount 
@@ -172,18 +194,16 @@ func GetUserReadComments(userID int32, queryString string) (comments []CommentMe
 	left join executionorder_b as b on c.bid = b.id
 	left join epa on b.epaid = epa.id
 	left join csa on h.csaid = csa.id
-	where  (c.dr=0 and b.dr=0 and c.isread=1 and c.sendtoid=`)
-	build.WriteString(strconv.Itoa(int(userID)))
-	build.WriteString(`)`)
-	if queryString != "" {
+	where  (c.dr=0 and b.dr=0 and c.isread=1 and c.sendtoid=$1)`)
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	// Check
 	checkSql := build.String()
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, args...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetUserReadComments db.QueryRow(checkSql) failed", zap.Error(err))
@@ -226,25 +246,23 @@ func GetUserReadComments(userID int32, queryString string) (comments []CommentMe
 	left join executionorder_b as b on c.bid = b.id
 	left join epa on b.epaid = epa.id
 	left join csa on h.csaid = csa.id
-	where  (c.dr=0 and b.dr=0 and c.isread=1 and c.sendtoid=`)
-	build.WriteString(strconv.Itoa(int(userID)))
-	build.WriteString(`)`)
-	if queryString != "" {
+	where  (c.dr=0 and b.dr=0 and c.isread=1 and c.sendtoid=$1)`)
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	build.WriteString(` order by c.id desc`)
 	sqlStr := build.String()
 	// Retrieve comments from database
-	res, err := db.Query(sqlStr)
+	res, err := db.Query(sqlStr, args...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			resStatus = i18n.StatusResNoData
 			return
 		}
 		resStatus = i18n.StatusInternalError
-		zap.L().Error("GetUserReadComments db.Query(sqlStr) failed", zap.Error(err))
+		zap.L().Error("GetUserReadComments db.Query(sqlStr, args...) failed", zap.Error(err))
 		return
 	}
 	defer res.Close()
@@ -289,15 +307,19 @@ func GetUserReadComments(userID int32, queryString string) (comments []CommentMe
 
 // Get User To-Do Issues
 func GetUserEORefs(userID int32) (reds []ReferExecutionOrder, resStatus i18n.ResKey, err error) {
-	queryString := fmt.Sprintf("issueownerid=%d", userID)
-	reds, resStatus, err = GetReferEOs(queryString)
+	queryFilter := filter.Group{Conditions: []filter.Condition{
+		{Field: "issueOwnerID", Operator: filter.OpEq, Value: userID},
+	}}
+	reds, resStatus, err = GetReferEOs(queryFilter, DataScope{Scope: DataScopeAll})
 	return
 }
 
 // Get User work Orders awaiting execution
 func GetUserWORefs(userID int32) (wors []WorkOrderRow, resStauts i18n.ResKey, err error) {
-	queryString := fmt.Sprintf("executorid=%d", userID)
-	wors, resStauts, err = GetWORefer(queryString)
+	queryFilter := filter.Group{Conditions: []filter.Condition{
+		{Field: "executorID", Operator: filter.OpEq, Value: userID},
+	}}
+	wors, resStauts, err = GetWORefer(queryFilter, DataScope{Scope: DataScopeAll})
 	return
 }
 
@@ -0,0 +1,313 @@
+package pg
+
+import (
+	"database/sql"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/mailer"
+	"sccsmsserver/pub"
+	"sccsmsserver/setting"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// Events users can be emailed about
+const (
+	NotifyWOAssigned    = "woassigned"    // Work Order row assigned to the user
+	NotifyIssueAssigned = "issueassigned" // Execution Order issue assigned to the user
+	NotifyEOComment     = "eocomment"     // Comment on an Execution Order of the user
+	NotifyIRFOverdue    = "irfoverdue"    // Issue Resolution Form overdue
+	NotifyPPEReady      = "ppeready"      // PPE ready for issuance to the user
+)
+
+// Queued email status
+const (
+	MailPending int16 = 0
+	MailSent    int16 = 1
+	MailFailed  int16 = 2
+)
+
+// Default sends of a queued email before it is given up
+const defaultMailAttempts = 5
+
+// Queued emails sent by each run of the job
+const mailBatchSize = 100
+
+// Localized email template of an event.
+// The subject and body are translation keys, formatted with the user name as %[1]s,
+// the bill number as %[2]s, the row number as %[3]d and the comment content as %[4]s.
+type notifyTemplate struct {
+	Subject i18n.ResKey
+	Body    i18n.ResKey
+}
+
+// Email templates of the events, in the order the preferences list them
+var notifyEvents = []string{NotifyWOAssigned, NotifyIssueAssigned, NotifyEOComment, NotifyIRFOverdue, NotifyPPEReady}
+var notifyTemplates = map[string]notifyTemplate{
+	NotifyWOAssigned:    {Subject: i18n.MailWOAssignedSubject, Body: i18n.MailWOAssignedBody},
+	NotifyIssueAssigned: {Subject: i18n.MailIssueAssignedSubject, Body: i18n.MailIssueAssignedBody},
+	NotifyEOComment:     {Subject: i18n.MailEOCommentSubject, Body: i18n.MailEOCommentBody},
+	NotifyIRFOverdue:    {Subject: i18n.MailIRFOverdueSubject, Body: i18n.MailIRFOverdueBody},
+	NotifyPPEReady:      {Subject: i18n.MailPPEReadySubject, Body: i18n.MailPPEReadyBody},
+}
+
+// Email preference of an event
+type NotifyEventPref struct {
+	Event string `json:"event"`
+	Email int16  `json:"email"` // 0 No 1 Yes
+}
+
+// Notification preferences of a user.
+// Users without preferences get the emails of all events in the default language.
+type NotifyPrefs struct {
+	UserID   int32             `json:"userID"`
+	Language string            `json:"language"` // Language of the emails, e.g. zh-CN, empty for the default language
+	Email    int16             `json:"email"`    // 0 no emails 1 the emails of the events chosen below
+	Events   []NotifyEventPref `json:"events"`
+	Ts       time.Time         `json:"ts"`
+}
+
+// Email notification queued for sending
+type queuedMail struct {
+	ID       int32
+	To       string
+	Subject  string
+	Body     string
+	Attempts int
+}
+
+// Notification event of a push message, empty when the message isn't emailed
+func (msg PushMessage) notifyEvent() string {
+	switch msg.Event {
+	case PushWO:
+		return NotifyWOAssigned
+	case PushIssue:
+		return NotifyIssueAssigned
+	case PushComment:
+		return NotifyEOComment
+	case PushPPE:
+		return NotifyPPEReady
+	case PushEscalation:
+		if msg.VoucherType == pub.IRF {
+			return NotifyIRFOverdue
+		}
+	}
+	return ""
+}
+
+// Get the notification preferences of the user
+func (np *NotifyPrefs) Get() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	np.Language = ""
+	np.Email = 1
+	var muted []string
+	sqlStr := `select language,email,mutedevents,ts from sysnotifypref where userid=$1`
+	err = db.QueryRow(sqlStr, np.UserID).Scan(&np.Language, &np.Email, pq.Array(&muted), &np.Ts)
+	if err != nil && err != sql.ErrNoRows {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("NotifyPrefs.Get db.QueryRow failed", zap.Error(err))
+		return
+	}
+	err = nil
+	np.Events = make([]NotifyEventPref, 0, len(notifyEvents))
+	for _, event := range notifyEvents {
+		pref := NotifyEventPref{Event: event, Email: 1}
+		if containsString(muted, event) {
+			pref.Email = 0
+		}
+		np.Events = append(np.Events, pref)
+	}
+	return
+}
+
+// Save the notification preferences of the user
+func (np *NotifyPrefs) Save() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	// Check the preferences
+	if np.Language != "" && !supportedLanguage(np.Language) {
+		resStatus = i18n.StatusNotifyPrefInvalid
+		return
+	}
+	muted := make([]string, 0)
+	for _, pref := range np.Events {
+		if _, ok := notifyTemplates[pref.Event]; !ok {
+			resStatus = i18n.StatusNotifyPrefInvalid
+			return
+		}
+		if pref.Email == 0 {
+			muted = append(muted, pref.Event)
+		}
+	}
+	sqlStr := `insert into sysnotifypref(userid,language,email,mutedevents)
+	values($1,$2,$3,$4)
+	on conflict (userid) do update set language=excluded.language,email=excluded.email,
+	mutedevents=excluded.mutedevents,modifytime=current_timestamp,ts=current_timestamp
+	returning ts`
+	err = db.QueryRow(sqlStr, np.UserID, np.Language, np.Email, pq.Array(muted)).Scan(&np.Ts)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("NotifyPrefs.Save db.QueryRow failed", zap.Error(err))
+		return
+	}
+	return
+}
+
+// Check whether the language is one of the system languages
+func supportedLanguage(lang string) bool {
+	for _, l := range i18n.SupportLanguages {
+		if l.Language == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// Check whether the slice contains the string
+func containsString(arr []string, s string) bool {
+	for _, v := range arr {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Queue the email notifications of the push message for the users who want them
+func queueMails(userIDs []int32, msg PushMessage) {
+	if !mailer.Enabled() {
+		return
+	}
+	event := msg.notifyEvent()
+	if event == "" {
+		return
+	}
+	tpl := notifyTemplates[event]
+	userSql := `select u.name,u.email,coalesce(p.language,''),coalesce(p.email,1),coalesce(p.mutedevents,'{}')
+	from sysuser as u
+	left join sysnotifypref as p on p.userid = u.id
+	where u.id=$1 and u.dr=0`
+	queueSql := `insert into sysmailqueue(userid,event,toaddress,subject,body)
+	values($1,$2,$3,$4,$5)`
+	for _, userID := range userIDs {
+		var name, address, lang string
+		var email int16
+		var muted []string
+		err := db.QueryRow(userSql, userID).Scan(&name, &address, &lang, &email, pq.Array(&muted))
+		if err != nil {
+			if err != sql.ErrNoRows {
+				zap.L().Error("queueMails db.QueryRow failed", zap.Int32("userID", userID), zap.Error(err))
+			}
+			continue
+		}
+		if address == "" || email == 0 || containsString(muted, event) {
+			continue
+		}
+		subject := tpl.Subject.Msg(lang, name, msg.BillNumber, msg.RowNumber, msg.Content)
+		body := tpl.Body.Msg(lang, name, msg.BillNumber, msg.RowNumber, msg.Content)
+		_, err = db.Exec(queueSql, userID, event, address, subject, body)
+		if err != nil {
+			zap.L().Error("queueMails db.Exec failed", zap.Int32("userID", userID), zap.Error(err))
+		}
+	}
+}
+
+// Background job: send the queued emails that are due.
+// A failed send is retried after attempts² minutes until the attempts run out.
+func sendQueuedMails() (err error) {
+	if !mailer.Enabled() {
+		return
+	}
+	maxAttempts := defaultMailAttempts
+	if setting.Conf.SMTPConfig.MaxAttempts > 0 {
+		maxAttempts = setting.Conf.SMTPConfig.MaxAttempts
+	}
+	mails, err := getDueMails()
+	if err != nil {
+		return
+	}
+	sentSql := `update sysmailqueue set status=1,attempts=attempts+1,senttime=current_timestamp,lasterror='',ts=current_timestamp
+	where id=$1`
+	failedSql := `update sysmailqueue set status=$2,attempts=attempts+1,lasterror=$3,
+	nextattempt=current_timestamp + make_interval(mins => $4),ts=current_timestamp
+	where id=$1`
+	for _, m := range mails {
+		errSend := mailer.Send(m.To, m.Subject, m.Body)
+		if errSend == nil {
+			_, err = db.Exec(sentSql, m.ID)
+		} else {
+			attempts := m.Attempts + 1
+			status := MailPending
+			if attempts >= maxAttempts {
+				status = MailFailed
+			}
+			zap.L().Warn("sendQueuedMails mailer.Send failed", zap.Int32("id", m.ID), zap.Int("attempts", attempts), zap.Error(errSend))
+			lastError := errSend.Error()
+			if len(lastError) > 1024 {
+				lastError = lastError[:1024]
+			}
+			_, err = db.Exec(failedSql, m.ID, status, lastError, attempts*attempts)
+		}
+		if err != nil {
+			zap.L().Error("sendQueuedMails db.Exec failed", zap.Int32("id", m.ID), zap.Error(err))
+			return
+		}
+	}
+	return
+}
+
+// Get the queued emails that are due
+func getDueMails() (mails []queuedMail, err error) {
+	sqlStr := `select id,toaddress,subject,body,attempts
+	from sysmailqueue
+	where status=0 and nextattempt <= current_timestamp
+	order by id limit $1`
+	rows, err := db.Query(sqlStr, mailBatchSize)
+	if err != nil {
+		zap.L().Error("getDueMails db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m queuedMail
+		err = rows.Scan(&m.ID, &m.To, &m.Subject, &m.Body, &m.Attempts)
+		if err != nil {
+			zap.L().Error("getDueMails rows.Scan failed", zap.Error(err))
+			return
+		}
+		mails = append(mails, m)
+	}
+	return
+}
+
+// Send a test email to the user straight away, to check the SMTP configuration
+func SendTestMail(userID int32) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	if !mailer.Enabled() {
+		resStatus = i18n.StatusMailDisabled
+		return
+	}
+	np := NotifyPrefs{UserID: userID}
+	resStatus, err = np.Get()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	var name, address string
+	err = db.QueryRow("select name,email from sysuser where id=$1 and dr=0", userID).Scan(&name, &address)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("SendTestMail db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if address == "" {
+		resStatus = i18n.StatusMailNoAddress
+		return
+	}
+	err = mailer.Send(address, i18n.MailTestSubject.Msg(np.Language, name), i18n.MailTestBody.Msg(np.Language, name))
+	if err != nil {
+		resStatus = i18n.StatusMailSendFailed
+		zap.L().Error("SendTestMail mailer.Send failed", zap.Error(err))
+		return
+	}
+	return
+}
@@ -0,0 +1,204 @@
+package pg
+
+import (
+	"database/sql/driver"
+	"errors"
+	"sccsmsserver/pkg/mailer"
+	"sccsmsserver/pkg/mailer/mailertest"
+	"sccsmsserver/setting"
+	"strings"
+	"testing"
+)
+
+// Start the SMTP server and enable the email notifications with it
+func startMailServer(t *testing.T, maxAttempts int) *mailertest.Server {
+	t.Helper()
+	s, err := mailertest.NewServer()
+	if err != nil {
+		t.Fatalf("mailertest.NewServer: %v", err)
+	}
+	t.Cleanup(s.Close)
+	c := &setting.SMTPConfig{
+		Enabled:     true,
+		Host:        s.Host,
+		Port:        s.Port,
+		TLS:         mailer.TLSNone,
+		From:        "SCCSMS <noreply@example.com>",
+		MaxAttempts: maxAttempts,
+	}
+	if err = mailer.Init(c); err != nil {
+		t.Fatalf("mailer.Init: %v", err)
+	}
+	old := setting.Conf.SMTPConfig
+	setting.Conf.SMTPConfig = c
+	t.Cleanup(func() {
+		mailer.Init(nil)
+		setting.Conf.SMTPConfig = old
+	})
+	return s
+}
+
+// Answer the query of the due emails with the queued emails
+func dueMails(mails ...queuedMail) func(string, []driver.Value) (fakeRows, error) {
+	return func(query string, args []driver.Value) (rows fakeRows, err error) {
+		if !strings.Contains(query, "from sysmailqueue") {
+			return rows, errors.New("unexpected query: " + query)
+		}
+		rows.Columns = []string{"id", "toaddress", "subject", "body", "attempts"}
+		for _, m := range mails {
+			rows.Values = append(rows.Values, []driver.Value{int64(m.ID), m.To, m.Subject, m.Body, int64(m.Attempts)})
+		}
+		return
+	}
+}
+
+func TestSendQueuedMails(t *testing.T) {
+	s := startMailServer(t, 0)
+	f := useFakeDB(t, dueMails(
+		queuedMail{ID: 1, To: "ann@example.com", Subject: "Work Order WO-0001 row 1 assigned to you", Body: "Hello Ann,\n"},
+		queuedMail{ID: 2, To: "bob@example.com", Subject: "工单 WO-0002 第 2 行已指派给您", Body: "Bob，您好：\n", Attempts: 2},
+	))
+	if err := sendQueuedMails(); err != nil {
+		t.Fatalf("sendQueuedMails: %v", err)
+	}
+	msgs := s.Messages()
+	if len(msgs) != 2 {
+		t.Fatalf("received %d messages, want 2", len(msgs))
+	}
+	for i, want := range []struct{ to, subject, body string }{
+		{"ann@example.com", "Work Order WO-0001 row 1 assigned to you", "Hello Ann,\n"},
+		{"bob@example.com", "工单 WO-0002 第 2 行已指派给您", "Bob，您好：\n"},
+	} {
+		m := msgs[i]
+		if len(m.To) != 1 || m.To[0] != want.to {
+			t.Errorf("message %d To = %q, want %q", i, m.To, want.to)
+		}
+		if got, _ := m.Subject(); got != want.subject {
+			t.Errorf("message %d Subject() = %q, want %q", i, got, want.subject)
+		}
+		if got, _ := m.Body(); got != want.body {
+			t.Errorf("message %d Body() = %q, want %q", i, got, want.body)
+		}
+	}
+	execs := f.Execs()
+	if len(execs) != 2 {
+		t.Fatalf("ran %d statements, want 2", len(execs))
+	}
+	for i, e := range execs {
+		if !strings.Contains(e.Query, "set status=1") || e.Args[0] != int64(i+1) {
+			t.Errorf("statement %d = %q %v, want email %d marked as sent", i, e.Query, e.Args, i+1)
+		}
+	}
+}
+
+func TestSendQueuedMailsRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		attempts    int
+		wantStatus  int16
+		wantDelay   int
+	}{
+		{name: "first failure", attempts: 0, wantStatus: MailPending, wantDelay: 1},
+		{name: "third failure", attempts: 2, wantStatus: MailPending, wantDelay: 9},
+		{name: "default attempts run out", attempts: defaultMailAttempts - 1, wantStatus: MailFailed, wantDelay: defaultMailAttempts * defaultMailAttempts},
+		{name: "configured attempts", maxAttempts: 8, attempts: defaultMailAttempts - 1, wantStatus: MailPending, wantDelay: defaultMailAttempts * defaultMailAttempts},
+		{name: "configured attempts run out", maxAttempts: 2, attempts: 1, wantStatus: MailFailed, wantDelay: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := startMailServer(t, tt.maxAttempts)
+			s.RejectRcpt("450 4.2.0 mailbox busy")
+			f := useFakeDB(t, dueMails(queuedMail{ID: 7, To: "ann@example.com", Subject: "Subject", Body: "Body", Attempts: tt.attempts}))
+			if err := sendQueuedMails(); err != nil {
+				t.Fatalf("sendQueuedMails: %v", err)
+			}
+			if len(s.Messages()) != 0 {
+				t.Errorf("received %d messages, want 0", len(s.Messages()))
+			}
+			execs := f.Execs()
+			if len(execs) != 1 {
+				t.Fatalf("ran %d statements, want 1", len(execs))
+			}
+			e := execs[0]
+			if !strings.Contains(e.Query, "nextattempt=current_timestamp + make_interval(mins => $4)") {
+				t.Fatalf("statement = %q, want the failed send recorded", e.Query)
+			}
+			if e.Args[0] != int64(7) || e.Args[1] != int64(tt.wantStatus) || e.Args[3] != int64(tt.wantDelay) {
+				t.Errorf("args = %v, want id 7, status %d and a delay of %d minutes", e.Args, tt.wantStatus, tt.wantDelay)
+			}
+			if lastError, _ := e.Args[2].(string); !strings.Contains(lastError, "mailbox busy") {
+				t.Errorf("last error = %q, want the reply of the server", lastError)
+			}
+		})
+	}
+}
+
+func TestSendQueuedMailsDisabled(t *testing.T) {
+	mailer.Init(nil)
+	f := useFakeDB(t, dueMails(queuedMail{ID: 1, To: "ann@example.com", Subject: "Subject", Body: "Body"}))
+	if err := sendQueuedMails(); err != nil {
+		t.Fatalf("sendQueuedMails: %v", err)
+	}
+	if len(f.Execs()) != 0 {
+		t.Errorf("ran %d statements, want 0", len(f.Execs()))
+	}
+}
+
+// Queue the email of a Work Order row for the users and send it, in the language of each user
+func TestQueuedMailsLocalized(t *testing.T) {
+	s := startMailServer(t, 0)
+	users := map[int64][]driver.Value{
+		1: {"Ann", "ann@example.com", "", int64(1), "{}"},
+		2: {"Bob", "bob@example.com", "zh-CN", int64(1), "{}"},
+		3: {"Cat", "cat@example.com", "zh-CN", int64(1), "{" + NotifyWOAssigned + "}"},
+		4: {"Dan", "dan@example.com", "", int64(0), "{}"},
+		5: {"Eve", "", "", int64(1), "{}"},
+	}
+	f := useFakeDB(t, func(query string, args []driver.Value) (rows fakeRows, err error) {
+		if !strings.Contains(query, "from sysuser") {
+			return rows, errors.New("unexpected query: " + query)
+		}
+		rows.Columns = []string{"name", "email", "language", "email", "mutedevents"}
+		if u, ok := users[args[0].(int64)]; ok {
+			rows.Values = append(rows.Values, u)
+		}
+		return
+	})
+	queueMails([]int32{1, 2, 3, 4, 5, 6}, PushMessage{Event: PushWO, BillNumber: "WO-0001", RowNumber: 3})
+
+	// Send the queued emails
+	var mails []queuedMail
+	for i, e := range f.Execs() {
+		if e.Args[1] != NotifyWOAssigned {
+			t.Errorf("queued event = %v, want %s", e.Args[1], NotifyWOAssigned)
+		}
+		mails = append(mails, queuedMail{ID: int32(i + 1), To: e.Args[2].(string), Subject: e.Args[3].(string), Body: e.Args[4].(string)})
+	}
+	useFakeDB(t, dueMails(mails...))
+	if err := sendQueuedMails(); err != nil {
+		t.Fatalf("sendQueuedMails: %v", err)
+	}
+	msgs := s.Messages()
+	want := []struct{ to, subject, body string }{
+		{"ann@example.com", "Work Order WO-0001 row 3 assigned to you",
+			"Hello Ann,\n\nRow 3 of Work Order WO-0001 has been assigned to you. Open the app to see the details and execute it.\n"},
+		{"bob@example.com", "工单 WO-0001 第 3 行已指派给您",
+			"Bob，您好：\n\n工单 WO-0001 的第 3 行已指派给您。请打开应用查看详情并执行。\n"},
+	}
+	if len(msgs) != len(want) {
+		t.Fatalf("received %d messages, want %d", len(msgs), len(want))
+	}
+	for i, w := range want {
+		m := msgs[i]
+		if len(m.To) != 1 || m.To[0] != w.to {
+			t.Errorf("message %d To = %q, want %q", i, m.To, w.to)
+		}
+		if got, _ := m.Subject(); got != w.subject {
+			t.Errorf("message %d Subject() = %q, want %q", i, got, w.subject)
+		}
+		if got, _ := m.Body(); got != w.body {
+			t.Errorf("message %d Body() = %q, want %q", i, got, w.body)
+		}
+	}
+}
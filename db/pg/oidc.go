@@ -0,0 +1,228 @@
+package pg
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"sccsmsserver/cache"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/oidcauth"
+	"sccsmsserver/pub"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// OpenID Connect login request parameters
+type ParamOIDCLogin struct {
+	ClientType string `json:"clientType"`
+	// Issue a refresh token with the access token
+	IssueRefreshToken bool `json:"issueRefreshToken"`
+}
+
+// OpenID Connect callback parameters, the query parameters the IdP appended to the redirect URL
+type ParamOIDCCallback struct {
+	Code             string `json:"code"`
+	State            string `json:"state" binding:"required"`
+	Binding          string `json:"binding" binding:"required"` // Binding returned with the authorization request
+	Error            string `json:"error"`
+	ErrorDescription string `json:"errorDescription"`
+	ClientIP         string `json:"clientIp"`
+	ClientType       string `json:"clientType"`
+	UserAgent        string `json:"userAgent"`
+}
+
+// IdP authorization request, the client navigates to AuthURL.
+// The client keeps the binding, e.g. in the session storage, and sends it back with the callback,
+// so a callback is only accepted from the client that started the login.
+type OIDCAuthRequest struct {
+	AuthURL string `json:"authURL"`
+	State   string `json:"state"`
+	Binding string `json:"binding"`
+}
+
+// Pending authorization request, keyed by the state
+type oidcState struct {
+	Nonce             string    `json:"nonce"`
+	BindingHash       string    `json:"bindingHash"`
+	CodeVerifier      string    `json:"codeVerifier"`
+	ClientType        string    `json:"clientType"`
+	IssueRefreshToken bool      `json:"issueRefreshToken"`
+	ExpireTime        time.Time `json:"expireTime"`
+}
+
+// Sysuser columns the match claim can be compared with
+var oidcMatchColumns = map[string]string{
+	"code":   "code",
+	"email":  "email",
+	"mobile": "mobile",
+}
+
+// Cache key of the authorization request
+func oidcStateKey(state string) string {
+	return fmt.Sprintf("%s%s%s", pub.OIDCState, ":", hashRefreshToken(state))
+}
+
+// Start an OpenID Connect login
+func OIDCLogin(p *ParamOIDCLogin) (ar OIDCAuthRequest, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	if !oidcauth.Enabled() {
+		resStatus = i18n.StatusOIDCDisabled
+		return
+	}
+	st := oidcState{
+		CodeVerifier:      oidcauth.GenerateVerifier(),
+		ClientType:        p.ClientType,
+		IssueRefreshToken: p.IssueRefreshToken,
+		ExpireTime:        time.Now().Add(pub.OIDCStateExpireDuration),
+	}
+	ar.State, err = genRefreshToken()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	st.Nonce, err = genRefreshToken()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	ar.Binding, err = genRefreshToken()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	st.BindingHash = hashRefreshToken(ar.Binding)
+	ar.AuthURL, err = oidcauth.AuthCodeURL(ar.State, st.Nonce, st.CodeVerifier)
+	if err != nil {
+		resStatus = i18n.StatusOIDCFailed
+		zap.L().Error("OIDCLogin oidcauth.AuthCodeURL failed", zap.Error(err))
+		return
+	}
+	v, _ := json.Marshal(st)
+	err = cache.SetOther(oidcStateKey(ar.State), v)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("OIDCLogin cache.SetOther failed", zap.Error(err))
+		return
+	}
+	return
+}
+
+// Get and remove the authorization request of the state, each state can only be used once
+func takeOIDCState(state string) (st oidcState, exist bool, err error) {
+	number, v, err := cache.GetDelOther(oidcStateKey(state))
+	if err != nil {
+		zap.L().Error("takeOIDCState cache.GetDelOther failed", zap.Error(err))
+		return
+	}
+	if number == 0 {
+		return
+	}
+	err = json.Unmarshal(v, &st)
+	if err != nil {
+		zap.L().Error("takeOIDCState json.Unmarshal failed", zap.Error(err))
+		return
+	}
+	return st, time.Now().Before(st.ExpireTime), nil
+}
+
+// Complete an OpenID Connect login.
+// The user is found by the configured claim, the tokens are then issued as with a password login.
+func OIDCCallback(p *ParamOIDCCallback) (tp TokenPair, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	if !oidcauth.Enabled() {
+		resStatus = i18n.StatusOIDCDisabled
+		return
+	}
+	st, exist, err := takeOIDCState(p.State)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	// The state must come back from the client that started the login
+	bindingHash := hashRefreshToken(p.Binding)
+	if !exist || st.ClientType != p.ClientType ||
+		subtle.ConstantTimeCompare([]byte(st.BindingHash), []byte(bindingHash)) != 1 {
+		resStatus = i18n.StatusOIDCStateInvalid
+		return
+	}
+	// The IdP declined the authorization
+	if p.Error != "" {
+		resStatus = i18n.StatusOIDCFailed
+		zap.L().Warn("OIDCCallback authorization failed", zap.String("error", p.Error), zap.String("description", p.ErrorDescription))
+		return
+	}
+	claims, err := oidcauth.Exchange(p.Code, st.Nonce, st.CodeVerifier)
+	if err != nil {
+		resStatus = i18n.StatusOIDCFailed
+		zap.L().Error("OIDCCallback oidcauth.Exchange failed", zap.Error(err))
+		return
+	}
+	// Find the user
+	matchValue, _ := claims[oidcauth.MatchClaim()].(string)
+	if matchValue == "" {
+		resStatus = i18n.StatusOIDCFailed
+		zap.L().Error("OIDCCallback match claim missing", zap.String("claim", oidcauth.MatchClaim()))
+		return
+	}
+	// An email matches only when the IdP states it is verified, a missing claim isn't enough
+	if oidcauth.MatchField() == "email" {
+		if verified, _ := claims["email_verified"].(bool); !verified {
+			resStatus = i18n.StatusOIDCFailed
+			zap.L().Warn("OIDCCallback email not verified", zap.String("email", matchValue))
+			return
+		}
+	}
+	var userID int32
+	var userCode string
+	var status, locked int16
+	sqlStr := fmt.Sprintf(`select id,code,status,locked from sysuser
+	where lower(%s)=lower($1) and dr=0 and isoperator=1 limit 2`, oidcMatchColumns[oidcauth.MatchField()])
+	rows, err := db.Query(sqlStr, matchValue)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("OIDCCallback db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	var number int32
+	for rows.Next() {
+		number++
+		err = rows.Scan(&userID, &userCode, &status, &locked)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("OIDCCallback rows.Scan failed", zap.Error(err))
+			return
+		}
+	}
+	// An ambiguous match must not sign in as an arbitrary user
+	if number != 1 {
+		resStatus = i18n.StatusUserNotExist
+		zap.L().Warn("OIDCCallback no unique user matches the claim", zap.String("claim", oidcauth.MatchClaim()),
+			zap.String("value", matchValue), zap.Int32("number", number))
+		return
+	}
+	if status != 0 {
+		resStatus = i18n.StatusUserDisabled
+		return
+	}
+	if locked != 0 {
+		resStatus = i18n.StatusUserLocked
+		return
+	}
+	return continueLogin(userID, userCode, st.ClientType, p.ClientIP, p.UserAgent, st.IssueRefreshToken, i18n.StatusOK)
+}
+
+// Sign-on options shown on the login page
+type SSOOptions struct {
+	OIDCEnabled  bool   `json:"oidcEnabled"`
+	ProviderName string `json:"providerName"`
+}
+
+// Get the sign-on options
+func GetSSOOptions() SSOOptions {
+	return SSOOptions{
+		OIDCEnabled:  oidcauth.Enabled(),
+		ProviderName: oidcauth.ProviderName(),
+	}
+}
@@ -5,44 +5,182 @@ import (
 	"fmt"
 	"sccsmsserver/cache"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/jwt"
 	"sccsmsserver/pub"
+	"sccsmsserver/setting"
+	"sort"
 	"time"
 
 	"go.uber.org/zap"
 )
 
+// Online user session, one for each device the user logged in on
 type OnlineUser struct {
-	User       Person `json:"user"`
-	TokenID    string `json:"id"`
-	ClientType string `json:"clientType"`
-	FromIp     string `json:"fromIp"`
-	ExpireTime int64  `json:"expireTime"`
+	User         Person    `json:"user"`
+	SessionID    string    `json:"sessionID"`
+	TokenID      string    `json:"id"`
+	ClientType   string    `json:"clientType"`
+	FromIp       string    `json:"fromIp"`
+	UserAgent    string    `json:"userAgent"`
+	LoginTime    time.Time `json:"loginTime"`
+	LastSeenTime time.Time `json:"lastSeenTime"`
+	ExpireTime   int64     `json:"expireTime"`
+	// The session was signed out because the user exceeded the session limit
+	Evicted bool `json:"evicted"`
 }
 
-// Add online user
-func (ou *OnlineUser) Add() (resStatus i18n.ResKey, err error) {
+// Cache key of the session
+func sessionKey(sessionID string) string {
+	return fmt.Sprintf("%s%s%s", pub.OnlineSession, ":", sessionID)
+}
+
+// Lock the sessions of the user.
+// With Redis the lock is shared by all application servers, so the session index and the sessions are updated by one server at a time.
+// ok is false when the lock isn't acquired within wait.
+func lockUserSessions(userID int32, wait time.Duration) (unlock func(), ok bool, err error) {
+	key := fmt.Sprintf("%s%s%d", pub.SessionsLock, ":", userID)
+	token, ok, err := cache.Lock(key, pub.SessionLockExpireDuration, wait)
+	if err != nil || !ok {
+		return
+	}
+	unlock = func() {
+		_ = cache.Unlock(key, token)
+	}
+	return
+}
+
+// Get the session IDs of the user from the cache
+func getUserSessionIDs(userID int32) (ids []string, err error) {
+	ids = make([]string, 0)
+	exist, v, err := cache.Get(pub.UserSessions, userID)
+	if err != nil {
+		zap.L().Error("getUserSessionIDs cache.Get failed:", zap.Error(err))
+		return
+	}
+	if exist == 1 {
+		err = json.Unmarshal(v, &ids)
+		if err != nil {
+			zap.L().Error("getUserSessionIDs json.Unmarshal failed:", zap.Error(err))
+		}
+	}
+	return
+}
+
+// Write the session IDs of the user to the cache
+func setUserSessionIDs(userID int32, ids []string) (err error) {
+	if len(ids) == 0 {
+		return cache.Del(pub.UserSessions, userID)
+	}
+	v, _ := json.Marshal(ids)
+	err = cache.Set(pub.UserSessions, userID, v)
+	if err != nil {
+		zap.L().Error("setUserSessionIDs cache.Set failed:", zap.Error(err))
+	}
+	return
+}
+
+// Maximum number of concurrent sessions per user on each client type
+func sessionLimit() int {
+	if setting.Conf.SessionLimit > 0 {
+		return int(setting.Conf.SessionLimit)
+	}
+	return 1
+}
+
+// Write the session to cache
+func (ou *OnlineUser) save() (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
-	key := fmt.Sprintf("%s%s%d", ou.ClientType, ":", ou.User.ID)
 	jsonL, err := json.Marshal(ou)
 	if err != nil {
-		zap.L().Error("OnlineUser.Add json.Marshal failed:", zap.Error(err))
+		zap.L().Error("OnlineUser.save json.Marshal failed:", zap.Error(err))
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	err = cache.SetOther(sessionKey(ou.SessionID), jsonL)
+	if err != nil {
+		zap.L().Error("OnlineUser.save cache.SetOther failed:", zap.Error(err))
 		resStatus = i18n.StatusInternalError
 		return
 	}
-	err = cache.SetOther(key, jsonL)
+	return
+}
+
+// Add online user session.
+// When the user exceeds the session limit on the client type,
+// the least recently seen sessions are signed out.
+func (ou *OnlineUser) Add() (resStatus i18n.ResKey, err error) {
+	unlock, ok, err := lockUserSessions(ou.User.ID, pub.SessionLockWait)
+	if err != nil || !ok {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("OnlineUser.Add lockUserSessions failed", zap.Int32("userID", ou.User.ID), zap.Error(err))
+		return
+	}
+	defer unlock()
+	resStatus, err = ou.save()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	ids, err := getUserSessionIDs(ou.User.ID)
 	if err != nil {
-		zap.L().Error("OnlineUser.Add cache.SetOther failed:", zap.Error(err))
 		resStatus = i18n.StatusInternalError
 		return
 	}
+	// Collect the other live sessions on the same client type
+	keep := make([]string, 0, len(ids)+1)
+	sameClient := make([]OnlineUser, 0)
+	for _, id := range ids {
+		if id == ou.SessionID {
+			continue
+		}
+		s := OnlineUser{SessionID: id}
+		exist, _, errGet := s.Get()
+		if errGet != nil || exist == 0 || s.Evicted {
+			continue
+		}
+		keep = append(keep, id)
+		if s.ClientType == ou.ClientType {
+			sameClient = append(sameClient, s)
+		}
+	}
+	keep = append(keep, ou.SessionID)
+	// Sign out the least recently seen sessions over the limit
+	if over := len(sameClient) + 1 - sessionLimit(); over > 0 {
+		sort.Slice(sameClient, func(i, j int) bool {
+			return sameClient[i].LastSeenTime.Before(sameClient[j].LastSeenTime)
+		})
+		for i := 0; i < over; i++ {
+			s := sameClient[i]
+			s.Evicted = true
+			resStatus, err = s.save()
+			if resStatus != i18n.StatusOK || err != nil {
+				return
+			}
+			revokeRefreshSession(s.SessionID)
+			keep = removeString(keep, s.SessionID)
+		}
+	}
+	err = setUserSessionIDs(ou.User.ID, keep)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+	}
 	return
 }
 
-// Get online user from cache
+// Remove a string from the slice
+func removeString(arr []string, s string) []string {
+	result := make([]string, 0, len(arr))
+	for _, item := range arr {
+		if item != s {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// Get online user session from cache by SessionID
 func (ou *OnlineUser) Get() (exist int32, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
-	key := fmt.Sprintf("%s%s%d", ou.ClientType, ":", ou.User.ID)
-	exist, v, err := cache.GetOther(key)
+	exist, v, err := cache.GetOther(sessionKey(ou.SessionID))
 	if err != nil {
 		zap.L().Error("OulineUser.Get cache.GetOther failed:", zap.Error(err))
 		resStatus = i18n.StatusInternalError
@@ -60,20 +198,125 @@ func (ou *OnlineUser) Get() (exist int32, resStatus i18n.ResKey, err error) {
 	return
 }
 
-// Delete online user
+// Check whether the session is still signed in with the token.
+// Like JWTAuthMiddleware, for connections that outlive the request that opened them.
+func (ou *OnlineUser) Active(tokenID string) bool {
+	exist, _, err := ou.Get()
+	return err == nil && exist == 1 && !ou.Evicted && ou.TokenID == tokenID
+}
+
+// Record the session activity.
+// The session is written back at most once per pub.SessionTouchInterval.
+// It is re-read under the lock of the user sessions, so a concurrent sign out, eviction or token refresh isn't overwritten.
+// The activity isn't recorded while the sessions are locked, a later request records it.
+func (ou *OnlineUser) Touch(clientIP string) {
+	if time.Since(ou.LastSeenTime) < pub.SessionTouchInterval && ou.FromIp == clientIP {
+		return
+	}
+	unlock, ok, err := lockUserSessions(ou.User.ID, 0)
+	if err != nil || !ok {
+		return
+	}
+	defer unlock()
+	s := OnlineUser{SessionID: ou.SessionID}
+	exist, _, err := s.Get()
+	if err != nil || exist == 0 || s.Evicted || s.TokenID != ou.TokenID {
+		return
+	}
+	s.LastSeenTime = time.Now()
+	s.FromIp = clientIP
+	_, _ = s.save()
+}
+
+// Delete online user session.
+// Without SessionID, all sessions of the user on the client type are deleted,
+// or all sessions of the user when ClientType is empty too.
 func (ou *OnlineUser) Del() (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
-	key := fmt.Sprintf("%s%s%d", ou.ClientType, ":", ou.User.ID)
-	err = cache.DelOther(key)
+	// Get the owner of the single session
+	if ou.SessionID != "" && ou.User.ID == 0 {
+		_, resStatus, err = ou.Get()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	unlock, ok, err := lockUserSessions(ou.User.ID, pub.SessionLockWait)
+	if err != nil || !ok {
+		resStatus = i18n.CodeInternalError
+		zap.L().Error("OnlineUser.Del lockUserSessions failed", zap.Int32("userID", ou.User.ID), zap.Error(err))
+		return
+	}
+	defer unlock()
+	ids, err := getUserSessionIDs(ou.User.ID)
+	if err != nil {
+		resStatus = i18n.CodeInternalError
+		return
+	}
+	keep := make([]string, 0, len(ids))
+	for _, id := range ids {
+		// The single session is deleted below
+		if ou.SessionID != "" {
+			if id != ou.SessionID {
+				keep = append(keep, id)
+			}
+			continue
+		}
+		s := OnlineUser{SessionID: id}
+		exist, _, errGet := s.Get()
+		remove := errGet == nil && (exist == 0 || ou.ClientType == "" || s.ClientType == ou.ClientType)
+		if !remove {
+			keep = append(keep, id)
+			continue
+		}
+		err = cache.DelOther(sessionKey(id))
+		if err != nil {
+			zap.L().Error("OnlineUser.Del cache.DelOther failed:", zap.Error(err))
+			resStatus = i18n.CodeInternalError
+			return
+		}
+		// Revoke the refresh tokens, so the device can't sign in again without the password
+		revokeRefreshSession(id)
+	}
+	// Delete the single session, it may be missing from the index when evicted
+	if ou.SessionID != "" {
+		err = cache.DelOther(sessionKey(ou.SessionID))
+		if err != nil {
+			zap.L().Error("OnlineUser.Del cache.DelOther failed:", zap.Error(err))
+			resStatus = i18n.CodeInternalError
+			return
+		}
+		revokeRefreshSession(ou.SessionID)
+	}
+	err = setUserSessionIDs(ou.User.ID, keep)
+	if err != nil {
+		resStatus = i18n.CodeInternalError
+	}
+	return
+}
+
+// Get the live sessions of the user
+func GetUserSessions(userID int32) (ous []OnlineUser, resStatus i18n.ResKey, err error) {
+	ous = make([]OnlineUser, 0)
+	resStatus = i18n.StatusOK
+	ids, err := getUserSessionIDs(userID)
 	if err != nil {
-		zap.L().Error("OnlineUser.Del cache.DelOther failed:", zap.Error(err))
 		resStatus = i18n.CodeInternalError
 		return
 	}
+	for _, id := range ids {
+		ou := OnlineUser{SessionID: id}
+		exist, resStatus, errGet := ou.Get()
+		if errGet != nil {
+			return ous, resStatus, errGet
+		}
+		if exist == 1 && !ou.Evicted && ou.ExpireTime > time.Now().Unix() {
+			ous = append(ous, ou)
+		}
+	}
 	return
 }
 
-// Get All online user list
+// Get All online user session list
 func GetAllOnlineUser() (ous []OnlineUser, resStatus i18n.ResKey, err error) {
 	ous = make([]OnlineUser, 0)
 	resStatus = i18n.StatusOK
@@ -89,26 +332,83 @@ func GetAllOnlineUser() (ous []OnlineUser, resStatus i18n.ResKey, err error) {
 
 	// Extract data row by row
 	for rows.Next() {
-		var ou OnlineUser
-		err = rows.Scan(&ou.User.ID)
+		var userID int32
+		err = rows.Scan(&userID)
 		if err != nil {
 			resStatus = i18n.CodeInternalError
 			zap.L().Error("GetAllOnlineUser row.Next() failed", zap.Error(err))
 			return
 		}
 
-		// Get User detail from cache
-		for _, clientType := range pub.ValidClientTypes {
-			ou.ClientType = clientType
-			exist, resStatus, errGet := ou.Get()
-			if errGet != nil {
-				zap.L().Error("GetAllOnlineUser ou.Get failed:", zap.Error(errGet))
-				return ous, resStatus, errGet
-			}
-			if exist == 1 && ou.ExpireTime > time.Now().Unix() {
-				ous = append(ous, ou)
-			}
+		// Get User sessions from cache
+		userSessions, resStatus, errGet := GetUserSessions(userID)
+		if errGet != nil {
+			zap.L().Error("GetAllOnlineUser GetUserSessions failed:", zap.Error(errGet))
+			return ous, resStatus, errGet
 		}
+		ous = append(ous, userSessions...)
+	}
+	return
+}
+
+// Generate an access token of the session and register the session as online.
+// A session that is no longer cached, e.g. after a long idle period, is added again.
+func issueAccessToken(userID int32, userCode string, sessionID string, clientType string, clientIP string, userAgent string) (token string, expireTime int64, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	// Generate Token ID
+	tokenID := newTokenID()
+	// Generate Json Web Token (JWT)
+	token, expireTime, err = jwt.GenToken(userID, userCode, tokenID, sessionID)
+	if err != nil {
+		resStatus = i18n.CodeInternalError
+		zap.L().Error("issueAccessToken GenToken failed", zap.Error(err))
+		return
+	}
+	// Update the existing session
+	unlock, ok, err := lockUserSessions(userID, pub.SessionLockWait)
+	if err != nil || !ok {
+		resStatus = i18n.CodeInternalError
+		zap.L().Error("issueAccessToken lockUserSessions failed", zap.Int32("userID", userID), zap.Error(err))
+		return
+	}
+	ou := OnlineUser{SessionID: sessionID}
+	exist, resStatus, err := ou.Get()
+	if resStatus != i18n.StatusOK || err != nil {
+		unlock()
+		return
+	}
+	if exist == 1 && !ou.Evicted && ou.User.ID == userID {
+		ou.TokenID = tokenID
+		ou.ExpireTime = expireTime
+		ou.FromIp = clientIP
+		ou.UserAgent = userAgent
+		ou.LastSeenTime = time.Now()
+		resStatus, err = ou.save()
+		unlock()
+		return
+	}
+	// Add locks the sessions itself
+	unlock()
+	// Get Person information
+	person := Person{
+		ID: userID,
+	}
+	resStatus, err = person.GetPersonInfoByID()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	// Add the session to online users.
+	ou = OnlineUser{
+		User:         person,
+		SessionID:    sessionID,
+		TokenID:      tokenID,
+		ClientType:   clientType,
+		FromIp:       clientIP,
+		UserAgent:    userAgent,
+		LoginTime:    time.Now(),
+		LastSeenTime: time.Now(),
+		ExpireTime:   expireTime,
 	}
+	resStatus, err = ou.Add()
 	return
 }
@@ -0,0 +1,279 @@
+package pg
+
+import (
+	"database/sql"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/password"
+	"sccsmsserver/pub"
+	"sccsmsserver/setting"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Change password during login parameters
+type ParamPreAuthChangePwd struct {
+	ParamPreAuth
+	NewPassword   string `json:"newPassword" binding:"required"`
+	ConfirmNewPwd string `json:"confirmNewPassword" binding:"required"`
+	KeyID         string `json:"keyId"` // Key ID of the RSA public key the passwords are encrypted with
+}
+
+// Get the password policy, with the defaults filled in
+func GetPasswordPolicy() (pp setting.PasswordPolicy) {
+	if setting.Conf.PasswordPolicy != nil {
+		pp = *setting.Conf.PasswordPolicy
+	}
+	if pp.MinLength <= 0 {
+		pp.MinLength = password.DefaultMinLength
+	}
+	if pp.HistoryCount < 0 {
+		pp.HistoryCount = 0
+	}
+	return
+}
+
+// Password complexity rules of the policy
+func passwordRules() password.Policy {
+	pp := GetPasswordPolicy()
+	return password.Policy{
+		MinLength:     pp.MinLength,
+		RequireUpper:  pp.RequireUpper,
+		RequireLower:  pp.RequireLower,
+		RequireDigit:  pp.RequireDigit,
+		RequireSymbol: pp.RequireSymbol,
+	}
+}
+
+// Check if the password complies with the password policy
+func checkPasswordPolicy(plain string) (resStatus i18n.ResKey) {
+	switch passwordRules().Check(plain) {
+	case nil:
+		return i18n.StatusOK
+	case password.ErrTooShort:
+		return i18n.StatusPasswordTooShort
+	default:
+		return i18n.StatusPasswordCharClasses
+	}
+}
+
+// Check if the password is the current password or one of the previous passwords of the user
+func checkPasswordHistory(userID int32, plain string, currentPassword string) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	historyCount := GetPasswordPolicy().HistoryCount
+	if historyCount == 0 {
+		return
+	}
+	if match, _ := checkPassword(plain, currentPassword); match {
+		resStatus = i18n.StatusPasswordReused
+		return
+	}
+	rows, err := db.Query("select password from syspasswordhistory where userid=$1 order by id desc limit $2", userID, historyCount)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("checkPasswordHistory db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var previous string
+		err = rows.Scan(&previous)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("checkPasswordHistory rows.Scan failed", zap.Error(err))
+			return
+		}
+		if match, _ := checkPassword(plain, previous); match {
+			resStatus = i18n.StatusPasswordReused
+			return
+		}
+	}
+	return
+}
+
+// Write the password to the history of the user and drop the entries beyond the policy
+func addPasswordHistory(tx *sql.Tx, userID int32, hashed string) (err error) {
+	_, err = tx.Exec("insert into syspasswordhistory(userid,password) values($1,$2)", userID, hashed)
+	if err != nil {
+		zap.L().Error("addPasswordHistory insert failed", zap.Error(err))
+		return
+	}
+	sqlStr := `delete from syspasswordhistory where userid=$1 and id not in
+	(select id from syspasswordhistory where userid=$1 order by id desc limit $2)`
+	_, err = tx.Exec(sqlStr, userID, GetPasswordPolicy().HistoryCount)
+	if err != nil {
+		zap.L().Error("addPasswordHistory delete failed", zap.Error(err))
+	}
+	return
+}
+
+// Change the password of the user after checking the policy and history.
+// Sessions started with the old password can no longer be refreshed.
+func setUserPassword(userID int32, plain string) (resStatus i18n.ResKey, err error) {
+	resStatus = checkPasswordPolicy(plain)
+	if resStatus != i18n.StatusOK {
+		return
+	}
+	var currentPassword string
+	err = db.QueryRow("select password from sysuser where id=$1", userID).Scan(&currentPassword)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("setUserPassword db.QueryRow failed", zap.Error(err))
+		return
+	}
+	resStatus, err = checkPasswordHistory(userID, plain, currentPassword)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	hashed, err := encryptPassword(plain)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("setUserPassword db.Begin failed", zap.Error(err))
+		return
+	}
+	sqlStr := `update sysuser set password=$1,mustchangepwd=0,pwdchangetime=current_timestamp
+	where id=$2`
+	_, err = tx.Exec(sqlStr, hashed, userID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("setUserPassword tx.Exec failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	err = addPasswordHistory(tx, userID, hashed)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("setUserPassword tx.Commit failed", zap.Error(err))
+		return
+	}
+	// The sessions signed in with the old password are ended
+	return RevokeRefreshTokens(userID, "")
+}
+
+// Check if the user has to change the password before logging in
+func passwordChangeStatus(plain string, mustChangePwd int16, pwdChangeTime time.Time) i18n.ResKey {
+	if mustChangePwd == 1 || plain == pub.DefaultPassword {
+		return i18n.StatusPasswordChangeRequired
+	}
+	maxAgeDays := GetPasswordPolicy().MaxAgeDays
+	if maxAgeDays > 0 && time.Since(pwdChangeTime) > time.Duration(maxAgeDays)*24*time.Hour {
+		return i18n.StatusPasswordExpired
+	}
+	return i18n.StatusOK
+}
+
+// Change the password during login and complete the login.
+// The pre-auth token is only issued after the second factor.
+func ChangePasswordWithPreAuth(p *ParamPreAuthChangePwd) (tp TokenPair, resStatus i18n.ResKey, err error) {
+	if p.NewPassword != p.ConfirmNewPwd {
+		resStatus = i18n.StatusPasswordDisaccord
+		return
+	}
+	pa, resStatus, err := getPreAuth(&p.ParamPreAuth, preAuthStepChangePwd)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	resStatus, err = setUserPassword(pa.UserID, p.NewPassword)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	delPreAuth(p.PreAuthToken)
+	return completeLogin(pa.UserID, pa.UserCode, pa.ClientType, p.ClientIP, p.UserAgent, pa.IssueRefreshToken)
+}
+
+// Reset the user password to a generated one.
+// The user has to change it on the next login, the generated password is returned in InitialPassword.
+func (user *User) ResetPassword(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	var authSource string
+	err = db.QueryRow("select authsource from sysuser where id=$1 and dr=0", user.ID).Scan(&authSource)
+	if err == sql.ErrNoRows {
+		resStatus = i18n.StatusUserNotExist
+		err = nil
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("User.ResetPassword db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if authSource != AuthSourceLocal {
+		resStatus = i18n.StatusPasswordManagedExternally
+		return
+	}
+	plain, err := passwordRules().Generate()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("User.ResetPassword generate password failed", zap.Error(err))
+		return
+	}
+	hashed, err := encryptPassword(plain)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("User.ResetPassword db.Begin failed", zap.Error(err))
+		return
+	}
+	// Lock the user and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.User, AuditActionEdit, user.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	sqlStr := `update sysuser set password=$1,mustchangepwd=1,pwdchangetime=current_timestamp,
+	modifierid=$2,modifytime=current_timestamp,ts=current_timestamp
+	where id=$3`
+	_, err = tx.Exec(sqlStr, hashed, user.Modifier.ID, user.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("User.ResetPassword tx.Exec failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	err = addPasswordHistory(tx, user.ID, hashed)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	err = at.write(user.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("User.ResetPassword tx.Commit failed", zap.Error(err))
+		return
+	}
+	// Sign out the user everywhere
+	ou := OnlineUser{}
+	ou.User.ID = user.ID
+	resStatus, err = ou.Del()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	user.DelFromLocalCache()
+	user.Password = ""
+	user.InitialPassword = plain
+	zap.L().Info("User password reset", zap.Int32("userID", user.ID), zap.Int32("operatorID", user.Modifier.ID))
+	return
+}
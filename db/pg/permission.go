@@ -0,0 +1,226 @@
+package pg
+
+import (
+	"encoding/json"
+	"sccsmsserver/cache"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pub"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Permission actions on a system menu
+const (
+	ActionView    = "view"
+	ActionAdd     = "add"
+	ActionEdit    = "edit"
+	ActionDelete  = "delete"
+	ActionConfirm = "confirm"
+)
+
+// All permission actions
+var PermissionActions = []string{ActionView, ActionAdd, ActionEdit, ActionDelete, ActionConfirm}
+
+// Check whether the action is a valid permission action
+func isPermissionAction(action string) bool {
+	for _, a := range PermissionActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// System menu IDs used by the API route permissions.
+// The values are the IDs in SysFunctionList.
+const (
+	MenuIDDashboard      int32 = 1
+	MenuIDWO             int32 = 110
+	MenuIDWOS            int32 = 120
+	MenuIDEO             int32 = 210
+	MenuIDEOReview       int32 = 220
+	MenuIDIRF            int32 = 310
+	MenuIDWOStatus       int32 = 410
+	MenuIDEOStatus       int32 = 420
+	MenuIDIRFStatus      int32 = 430
+	MenuIDDC             int32 = 510
+	MenuIDDocumentUpload int32 = 520
+	MenuIDDocumentFind   int32 = 530
+	MenuIDTC             int32 = 610
+	MenuIDTR             int32 = 620
+	MenuIDTS             int32 = 630
+	MenuIDTPS            int32 = 640
+	MenuIDPQ             int32 = 710
+	MenuIDPPEWizard      int32 = 720
+	MenuIDPPEIF          int32 = 730
+	MenuIDPPES           int32 = 740
+	MenuIDDepartment     int32 = 1010
+	MenuIDPosition       int32 = 1011
+	MenuIDCSC            int32 = 1016
+	MenuIDCSA            int32 = 1020
+	MenuIDUDC            int32 = 1030
+	MenuIDUDA            int32 = 1040
+	MenuIDEPC            int32 = 1050
+	MenuIDEP             int32 = 1060
+	MenuIDRL             int32 = 1070
+	MenuIDPPE            int32 = 1080
+	MenuIDEPT            int32 = 1110
+	MenuIDRole           int32 = 9010
+	MenuIDUser           int32 = 9020
+	MenuIDPA             int32 = 9030
+	MenuIDOU             int32 = 9040
+	MenuIDLS             int32 = 9050
+	MenuIDAT             int32 = 9060
+	MenuIDAK             int32 = 9070
+	MenuIDCSO            int32 = 9110
+	MenuIDLPS            int32 = 9130
+	MenuIDAF             int32 = 9140
+	MenuIDWH             int32 = 9150
+)
+
+// Action on a system menu
+type MenuPermission struct {
+	MenuID int32
+	Action string
+}
+
+// The system default role 'systemadmin' is granted all permissions
+const systemAdminRoleID int32 = 10000
+
+// Permissions granted to a user through the user's roles
+type UserPermissions struct {
+	UserID  int32 `json:"userID"`
+	IsAdmin bool  `json:"isAdmin"`
+	// Granted actions by menu ID. An empty action list grants all actions on the menu.
+	Menus map[int32][]string `json:"menus"`
+	// Widest data scope of the user's roles
+	DataScope int16 `json:"dataScope"`
+}
+
+// Check whether the action on the menu is granted
+func (up *UserPermissions) Allowed(menuID int32, action string) bool {
+	if up.IsAdmin {
+		return true
+	}
+	actions, ok := up.Menus[menuID]
+	if !ok {
+		return false
+	}
+	if len(actions) == 0 {
+		return true
+	}
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the user permissions, from the cache when possible
+func GetUserPermissions(userID int32) (up UserPermissions, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	// Get from cache
+	number, b, _ := cache.Get(pub.UserPerm, userID)
+	if number > 0 {
+		err = json.Unmarshal(b, &up)
+		if err == nil {
+			return
+		}
+		zap.L().Error("GetUserPermissions json.Unmarshal failed", zap.Error(err))
+	}
+	// Get from database
+	up.UserID = userID
+	up.Menus = make(map[int32][]string)
+	var adminNumber int32
+	err = db.QueryRow("select count(id) from sysuserrole where userid=$1 and roleid=$2", userID, systemAdminRoleID).Scan(&adminNumber)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetUserPermissions db.QueryRow failed", zap.Error(err))
+		return
+	}
+	up.IsAdmin = adminNumber > 0
+	up.DataScope, err = getRolesDataScope(userID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	sqlStr := `select rm.menuid,coalesce(rm.actions,'')
+	from sysrolemenu as rm
+	left join sysrole as r on rm.roleid = r.id
+	where rm.dr=0 and r.dr=0
+	and rm.roleid in (select roleid from sysuserrole where userid=$1)`
+	rows, err := db.Query(sqlStr, userID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetUserPermissions db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var menuID int32
+		var actions string
+		err = rows.Scan(&menuID, &actions)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("GetUserPermissions rows.Scan failed", zap.Error(err))
+			return
+		}
+		up.Menus[menuID] = mergeActions(up.Menus[menuID], actions)
+	}
+	// Write into cache
+	upB, _ := json.Marshal(up)
+	_ = cache.Set(pub.UserPerm, userID, upB)
+	return
+}
+
+// Merge the actions of a role menu record into the granted actions.
+// An empty action list means all actions, and stays empty.
+func mergeActions(granted []string, actions string) []string {
+	if granted != nil && len(granted) == 0 {
+		return granted
+	}
+	if actions == "" {
+		return []string{}
+	}
+	for _, a := range strings.Split(actions, ",") {
+		exist := false
+		for _, g := range granted {
+			if g == a {
+				exist = true
+				break
+			}
+		}
+		if !exist {
+			granted = append(granted, a)
+		}
+	}
+	return granted
+}
+
+// Delete the cached permissions of the users
+func clearUserPermissions(userIDs ...int32) {
+	for _, id := range userIDs {
+		_ = cache.Del(pub.UserPerm, id)
+	}
+}
+
+// Delete the cached permissions of all role members and API keys with the role
+func clearRolePermissions(roleID int32) {
+	clearRoleAPIKeyPermissions(roleID)
+	rows, err := db.Query("select userid from sysuserrole where roleid=$1", roleID)
+	if err != nil {
+		zap.L().Error("clearRolePermissions db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID int32
+		if err = rows.Scan(&userID); err != nil {
+			zap.L().Error("clearRolePermissions rows.Scan failed", zap.Error(err))
+			return
+		}
+		clearUserPermissions(userID)
+	}
+}
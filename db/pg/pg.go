@@ -54,13 +54,6 @@ func Init(cfg *setting.PqConfig) (err error) {
 		}
 	}
 
-	// Setp 7: Initialize RSA
-	_, err = initRsa()
-	if err != nil {
-		zap.L().Error("postgresql database Init initRsa failed:", zap.Error(err))
-		return
-	}
-
 	// Step 8: Initialize Current Server public information
 	err = ServerPubInfo.Init()
 	if err != nil {
@@ -80,6 +73,12 @@ func Init(cfg *setting.PqConfig) (err error) {
 		zap.L().Error("postgresql database Init upgradeDb failed", zap.Error(err))
 		return
 	}
+	// Step 13: Initialize RSA, the key table may have been added by the upgrade
+	_, err = initRsa()
+	if err != nil {
+		zap.L().Error("postgresql database Init initRsa failed:", zap.Error(err))
+		return
+	}
 
 	zap.L().Info("Database connection initialized successfully.")
 	return
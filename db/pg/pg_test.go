@@ -0,0 +1,117 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"os"
+	"sccsmsserver/cache"
+	"sccsmsserver/i18n"
+	"sync"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	if err := i18n.InitTranslators(); err != nil {
+		panic(err)
+	}
+	if err := cache.Init(false); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// Rows returned by the fake database for a query
+type fakeRows struct {
+	Columns []string
+	Values  [][]driver.Value
+}
+
+// Statement run on the fake database
+type fakeCall struct {
+	Query string
+	Args  []driver.Value
+}
+
+// Fake database/sql driver: queries are answered by a function of the test, statements are recorded
+type fakeDB struct {
+	query func(query string, args []driver.Value) (fakeRows, error)
+	mu    sync.Mutex
+	execs []fakeCall
+}
+
+// Replace the database of the package with a fake one for the test
+func useFakeDB(t *testing.T, query func(query string, args []driver.Value) (fakeRows, error)) *fakeDB {
+	t.Helper()
+	f := &fakeDB{query: query}
+	old := db
+	db = sql.OpenDB(f)
+	t.Cleanup(func() {
+		db.Close()
+		db = old
+	})
+	return f
+}
+
+// Statements run so far
+func (f *fakeDB) Execs() []fakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeCall(nil), f.execs...)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return f }
+func (f *fakeDB) Open(string) (driver.Conn, error)             { return fakeConn{f}, nil }
+
+type fakeConn struct{ f *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.f, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	f     *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	s.f.execs = append(s.f.execs, fakeCall{Query: s.query, Args: args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, err := s.f.query(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeCursor{rows: rows}, nil
+}
+
+type fakeCursor struct {
+	rows fakeRows
+	next int
+}
+
+func (c *fakeCursor) Columns() []string { return c.rows.Columns }
+func (c *fakeCursor) Close() error      { return nil }
+
+func (c *fakeCursor) Next(dest []driver.Value) error {
+	if c.next >= len(c.rows.Values) {
+		return io.EOF
+	}
+	copy(dest, c.rows.Values[c.next])
+	c.next++
+	return nil
+}
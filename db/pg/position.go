@@ -223,7 +223,7 @@ func (pc *PositionCache) GetOPsCache() (resStatus i18n.ResKey, err error) {
 }
 
 // Add Position
-func (p *Position) Add() (resStatus i18n.ResKey, err error) {
+func (p *Position) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the position name exists
 	resStatus, err = p.CheckNameExist()
@@ -231,21 +231,24 @@ func (p *Position) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Insert a record to positon table
-	sqlStr := `insert into position(name,description,status,creatorid) 
-	values($1,$2,$3,$4) 
-	returning id`
-	err = db.QueryRow(sqlStr, p.Name, p.Description, p.Status, p.Creator.ID).Scan(&p.ID)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("Position.Add db.QueryRow failed", zap.Error(err))
-		return
-	}
+	return writeAudited(actor, pub.Position, AuditActionAdd, &p.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `insert into position(name,description,status,creatorid) 
+		values($1,$2,$3,$4) 
+		returning id`
+		err = tx.QueryRow(sqlStr, p.Name, p.Description, p.Status, p.Creator.ID).Scan(&p.ID)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("Position.Add tx.QueryRow failed", zap.Error(err))
+			return
+		}
 
-	return
+		return
+	})
 }
 
 // Edit position
-func (p *Position) Edit() (resStatus i18n.ResKey, err error) {
+func (p *Position) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the position name exists
 	resStatus, err = p.CheckNameExist()
@@ -253,38 +256,41 @@ func (p *Position) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the record in the position table
-	sqlStr := `update position set 
-		name=$1,description=$2,status=$3,modifierid=$4,modifytime=current_timestamp,
-		ts=current_timestamp 
-		where id=$5 and ts=$6 and dr=0`
-	res, err := db.Exec(sqlStr, p.Name, p.Description, p.Status, p.Modifier.ID,
-		p.ID, p.Ts)
-	if err != nil {
-		zap.L().Error("Position.Edit db.exec failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// Get the  number of rows affected by  the SQL statement update
-	affected, err := res.RowsAffected()
-	if err != nil {
-		zap.L().Error("Position.Edit  get res.RowsAffected failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// If the number of affected rows is less than one,
-	// it means that someone else has already modified the record
-	if affected < 1 {
-		zap.L().Info("Position.Edit failed,Other user are Editing")
-		resStatus = i18n.StatusOtherEdit
+	return writeAudited(actor, pub.Position, AuditActionEdit, &p.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update position set 
+			name=$1,description=$2,status=$3,modifierid=$4,modifytime=current_timestamp,
+			ts=current_timestamp 
+			where id=$5 and ts=$6 and dr=0`
+		res, err := tx.Exec(sqlStr, p.Name, p.Description, p.Status, p.Modifier.ID,
+			p.ID, p.Ts)
+		if err != nil {
+			zap.L().Error("Position.Edit db.exec failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// Get the  number of rows affected by  the SQL statement update
+		affected, err := res.RowsAffected()
+		if err != nil {
+			zap.L().Error("Position.Edit  get res.RowsAffected failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// If the number of affected rows is less than one,
+		// it means that someone else has already modified the record
+		if affected < 1 {
+			zap.L().Info("Position.Edit failed,Other user are Editing")
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		// Delete from local cache
+		p.DelFromLocalCache()
 		return
-	}
-	// Delete from local cache
-	p.DelFromLocalCache()
-	return
+	})
 }
 
 // Delete Position
-func (p *Position) Delete() (resStatus i18n.ResKey, err error) {
+func (p *Position) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the position id refrenced
 	resStatus, err = p.CheckUsed()
@@ -292,32 +298,35 @@ func (p *Position) Delete() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the record in the position table
-	sqlStr := `update position set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp 
-	where id=$2 and dr=0 and ts=$3`
+	return writeAudited(actor, pub.Position, AuditActionDelete, &p.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update position set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp 
+		where id=$2 and dr=0 and ts=$3`
 
-	res, err := db.Exec(sqlStr, p.Modifier.ID, p.ID, p.Ts)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("Position.Delete db.exec failed", zap.Error(err))
-		return
-	}
-	// Get the number of rows affected by the SQL statement update
-	effected, err := res.RowsAffected()
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("Position.Delete res.RowsAffected failed", zap.Error(err))
-		return
-	}
-	// If the number of affected rows is less than one,
-	// it means that someone else has already modified the record.
-	if effected < 1 {
-		resStatus = i18n.StatusOtherEdit
-		return
-	}
-	// Delete from local cache
-	p.DelFromLocalCache()
+		res, err := tx.Exec(sqlStr, p.Modifier.ID, p.ID, p.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("Position.Delete db.exec failed", zap.Error(err))
+			return
+		}
+		// Get the number of rows affected by the SQL statement update
+		effected, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("Position.Delete res.RowsAffected failed", zap.Error(err))
+			return
+		}
+		// If the number of affected rows is less than one,
+		// it means that someone else has already modified the record.
+		if effected < 1 {
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		// Delete from local cache
+		p.DelFromLocalCache()
 
-	return
+		return
+	})
 }
 
 // Chcek the position name exists
@@ -340,22 +349,33 @@ func (p *Position) CheckNameExist() (resStatus i18n.ResKey, err error) {
 }
 
 // Batch delete position
-func DeleteOPs(ops *[]Position, modifyUserId int32) (statusCode i18n.ResKey, err error) {
-	statusCode = i18n.StatusOK
+func DeleteOPs(ops *[]Position, actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
 	// begin a database transaction
 	tx, err := db.Begin()
 	if err != nil {
-		statusCode = i18n.StatusInternalError
+		resStatus = i18n.StatusInternalError
 		zap.L().Error("DeleteOPs db.begin failed", zap.Error(err))
 		return
 	}
 	defer tx.Commit()
+	// Lock the positions and take their state for the audit trail
+	ids := make([]int32, 0, len(*ops))
+	for _, item := range *ops {
+		ids = append(ids, item.ID)
+	}
+	at, err := beginAudit(tx, actor, pub.Position, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	delSqlStr := `update position set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp 
 		where id=$2 and dr=0 and ts=$3`
 	stmt, err := tx.Prepare(delSqlStr)
 	if err != nil {
-		statusCode = i18n.StatusInternalError
+		resStatus = i18n.StatusInternalError
 		zap.L().Error("DeleteOPs tx.Prepare failed", zap.Error(err))
 		tx.Rollback()
 		return
@@ -364,13 +384,13 @@ func DeleteOPs(ops *[]Position, modifyUserId int32) (statusCode i18n.ResKey, err
 
 	for _, p := range *ops {
 		// Check if the postion id  referenced
-		statusCode, err = p.CheckUsed()
-		if statusCode != i18n.StatusOK || err != nil {
+		resStatus, err = p.CheckUsed()
+		if resStatus != i18n.StatusOK || err != nil {
 			tx.Rollback()
 			return
 		}
 		// Update the record in postion table
-		result, err1 := stmt.Exec(modifyUserId, p.ID, p.Ts)
+		result, err1 := stmt.Exec(actor.UserID, p.ID, p.Ts)
 		if err1 != nil {
 			zap.L().Error("DeleteOPs stmt.exec failed", zap.Error(err))
 			tx.Rollback()
@@ -391,6 +411,12 @@ func DeleteOPs(ops *[]Position, modifyUserId int32) (statusCode i18n.ResKey, err
 		// Delete from local cache
 		p.DelFromLocalCache()
 	}
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
@@ -417,6 +443,11 @@ func (p *Position) CheckUsed() (resStatus i18n.ResKey, err error) {
 			SqlStr:         "select count(id) as usednum from ppequotas_h where dr=0 and positionid=$1",
 			UsedReturnCode: i18n.StatusPQPositionUsed,
 		},
+		{
+			Description:    "Referenced by approval flow step",
+			SqlStr:         "select count(id) as usednum from sysapprovalstep where dr=0 and approvertype=2 and approverid=$1",
+			UsedReturnCode: i18n.StatusApprovalFlowUsed,
+		},
 	}
 	// Check item by item
 	var usedNum int32
@@ -171,7 +171,7 @@ func (ppec *PPECache) GetPPEsCache() (resStatus i18n.ResKey, err error) {
 }
 
 // Add Personal Protective Equipment
-func (ppe *PPE) Add() (resStatus i18n.ResKey, err error) {
+func (ppe *PPE) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the PPE Code exist
 	resStatus, err = ppe.CheckCodeExist()
@@ -179,18 +179,21 @@ func (ppe *PPE) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Insert a record to ppe table
-	sqlStr := `insert into ppe(code,name,model,unit,description,
-		creatorid,status) 
-		values($1,$2,$3,$4,$5,$6,$7) 
-		returning id`
-	err = db.QueryRow(sqlStr, ppe.Code, ppe.Name, ppe.Model, ppe.Unit, ppe.Description,
-		ppe.Creator.ID, ppe.Status).Scan(&ppe.ID)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("PPE.Add db.QueryRow failed", zap.Error(err))
+	return writeAudited(actor, pub.PPE, AuditActionAdd, &ppe.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `insert into ppe(code,name,model,unit,description,
+			creatorid,status) 
+			values($1,$2,$3,$4,$5,$6,$7) 
+			returning id`
+		err = tx.QueryRow(sqlStr, ppe.Code, ppe.Name, ppe.Model, ppe.Unit, ppe.Description,
+			ppe.Creator.ID, ppe.Status).Scan(&ppe.ID)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("PPE.Add tx.QueryRow failed", zap.Error(err))
+			return
+		}
 		return
-	}
-	return
+	})
 }
 
 // Get PPE Information by ID
@@ -239,7 +242,7 @@ func (ppe *PPE) GetInfoByID() (resStatus i18n.ResKey, err error) {
 }
 
 // Modify Personal Protective Equipment
-func (ppe *PPE) Edit() (resStatus i18n.ResKey, err error) {
+func (ppe *PPE) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the PPE code exists
 	resStatus, err = ppe.CheckCodeExist()
@@ -247,40 +250,43 @@ func (ppe *PPE) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the record in the ppe table
-	sqlStr := `update ppe set 
-		code=$1,name=$2,model=$3,unit=$4,description=$5,
-		status=$6,modifierid=$7,modifytime=current_timestamp,ts=current_timestamp 
-		where id=$8 and ts=$9 and dr=0`
-	res, err := db.Exec(sqlStr, ppe.Code, ppe.Name, ppe.Model, ppe.Unit, ppe.Description,
-		ppe.Status, ppe.Modifier.ID,
-		ppe.ID, ppe.Ts)
-	if err != nil {
-		zap.L().Error("PPE.Edit db.exec failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// Get the number of rows affected by the SQL statement update
-	affected, err := res.RowsAffected()
-	if err != nil {
-		zap.L().Error("PPE.Edit  get res.RowsAffected failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// If the number of affected rows is less than one,
-	// it means that someone else has already modified the record.
-	if affected < 1 {
-		zap.L().Info("PPE.Edit failed,Other user are Editing")
-		resStatus = i18n.StatusOtherEdit
-		return
-	}
-	// Delete from cache
-	ppe.DelFromLocalCache()
+	return writeAudited(actor, pub.PPE, AuditActionEdit, &ppe.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update ppe set 
+			code=$1,name=$2,model=$3,unit=$4,description=$5,
+			status=$6,modifierid=$7,modifytime=current_timestamp,ts=current_timestamp 
+			where id=$8 and ts=$9 and dr=0`
+		res, err := tx.Exec(sqlStr, ppe.Code, ppe.Name, ppe.Model, ppe.Unit, ppe.Description,
+			ppe.Status, ppe.Modifier.ID,
+			ppe.ID, ppe.Ts)
+		if err != nil {
+			zap.L().Error("PPE.Edit db.exec failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// Get the number of rows affected by the SQL statement update
+		affected, err := res.RowsAffected()
+		if err != nil {
+			zap.L().Error("PPE.Edit  get res.RowsAffected failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// If the number of affected rows is less than one,
+		// it means that someone else has already modified the record.
+		if affected < 1 {
+			zap.L().Info("PPE.Edit failed,Other user are Editing")
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		// Delete from cache
+		ppe.DelFromLocalCache()
 
-	return
+		return
+	})
 }
 
 // Delete PPE master data
-func (ppe *PPE) Delete() (resStatus i18n.ResKey, err error) {
+func (ppe *PPE) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the PPE id is refereced
 	resStatus, err = ppe.CheckUsed()
@@ -288,30 +294,33 @@ func (ppe *PPE) Delete() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the record in the ppe table
-	sqlStr := `update ppe set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp where id=$2 and dr=0 and ts=$3`
-	res, err := db.Exec(sqlStr, ppe.Modifier.ID, ppe.ID, ppe.Ts)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("PPE.Delete db.exec failed", zap.Error(err))
-		return
-	}
-	// Check the number of rows affected by the SQL update statement
-	affected, err := res.RowsAffected()
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("PPE.Delete res.RowsAffected failed", zap.Error(err))
-		return
-	}
-	// If the number of affected rows is less than one,
-	// it means that someone else has already updated the record.
-	if affected < 1 {
-		resStatus = i18n.StatusOtherEdit
-		return
-	}
-	// delete from cache
-	ppe.DelFromLocalCache()
+	return writeAudited(actor, pub.PPE, AuditActionDelete, &ppe.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update ppe set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp where id=$2 and dr=0 and ts=$3`
+		res, err := tx.Exec(sqlStr, ppe.Modifier.ID, ppe.ID, ppe.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("PPE.Delete db.exec failed", zap.Error(err))
+			return
+		}
+		// Check the number of rows affected by the SQL update statement
+		affected, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("PPE.Delete res.RowsAffected failed", zap.Error(err))
+			return
+		}
+		// If the number of affected rows is less than one,
+		// it means that someone else has already updated the record.
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		// delete from cache
+		ppe.DelFromLocalCache()
 
-	return
+		return
+	})
 }
 
 // Check if the PPE code exists
@@ -334,7 +343,7 @@ func (ppe *PPE) CheckCodeExist() (resStatus i18n.ResKey, err error) {
 }
 
 // Batch Delete PPE master data
-func DeletePPEs(ppes *[]PPE, modifyUserId int32) (resStatus i18n.ResKey, err error) {
+func DeletePPEs(ppes *[]PPE, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Begin a database transaction
 	tx, err := db.Begin()
@@ -344,6 +353,17 @@ func DeletePPEs(ppes *[]PPE, modifyUserId int32) (resStatus i18n.ResKey, err err
 		return
 	}
 	defer tx.Commit()
+	// Lock the PPE and take their state for the audit trail
+	ids := make([]int32, 0, len(*ppes))
+	for _, item := range *ppes {
+		ids = append(ids, item.ID)
+	}
+	at, err := beginAudit(tx, actor, pub.PPE, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Prepare update SQL statement
 	delSqlStr := `update ppe set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp 
 		where id=$2 and dr=0 and ts=$3`
@@ -364,7 +384,7 @@ func DeletePPEs(ppes *[]PPE, modifyUserId int32) (resStatus i18n.ResKey, err err
 			return
 		}
 		// Execute the update
-		result, err1 := stmt.Exec(modifyUserId, ppe.ID, ppe.Ts)
+		result, err1 := stmt.Exec(actor.UserID, ppe.ID, ppe.Ts)
 		if err1 != nil {
 			zap.L().Error("DeletePPEs stmt.exec failed", zap.Error(err))
 			tx.Rollback()
@@ -385,6 +405,12 @@ func DeletePPEs(ppes *[]PPE, modifyUserId int32) (resStatus i18n.ResKey, err err
 		// Delete from cache
 		ppe.DelFromLocalCache()
 	}
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
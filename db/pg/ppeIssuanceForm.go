@@ -1,7 +1,10 @@
 package pg
 
 import (
+	"database/sql"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/filter"
+	"sccsmsserver/pub"
 	"sccsmsserver/setting"
 	"strings"
 	"time"
@@ -113,18 +116,18 @@ type PPEIssuanceFormReport struct {
 }
 
 // Generate a PPE Issuance Form via Wizard
-func (pifw *PPEIssuanceFormWizard) Generate() (resStatus i18n.ResKey, err error) {
+func (pifw *PPEIssuanceFormWizard) Generate(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	if pifw.Params.GenerationType == 0 {
-		resStatus, err = pifw.CombinedGeneration()
+		resStatus, err = pifw.CombinedGeneration(actor)
 	} else {
-		resStatus, err = pifw.SeparateGeneration()
+		resStatus, err = pifw.SeparateGeneration(actor)
 	}
 	return
 }
 
 // Generate a combined PPE Issuance Form via Wizard
-func (pifw *PPEIssuanceFormWizard) CombinedGeneration() (resStatus i18n.ResKey, err error) {
+func (pifw *PPEIssuanceFormWizard) CombinedGeneration(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	pifw.VoucherNumbers = make([]string, 0)
 	var pif PPEIssuanceForm
@@ -181,7 +184,7 @@ func (pifw *PPEIssuanceFormWizard) CombinedGeneration() (resStatus i18n.ResKey,
 	}
 
 	// Add PPE Issuance Form
-	resStatus, err = pif.Add()
+	resStatus, err = pif.Add(actor)
 	if resStatus != i18n.StatusOK || err != nil {
 		return
 	}
@@ -192,7 +195,7 @@ func (pifw *PPEIssuanceFormWizard) CombinedGeneration() (resStatus i18n.ResKey,
 }
 
 // Generate separate PPE Issuance Forms via Wizard
-func (pifw *PPEIssuanceFormWizard) SeparateGeneration() (resStatus i18n.ResKey, err error) {
+func (pifw *PPEIssuanceFormWizard) SeparateGeneration(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	pifw.VoucherNumbers = make([]string, 0)
 	// Get PPE Quota from database
@@ -247,7 +250,7 @@ func (pifw *PPEIssuanceFormWizard) SeparateGeneration() (resStatus i18n.ResKey,
 			pif.Body = append(pif.Body, pifr)
 		}
 		// Add PPE Issuance Form
-		resStatus, err = pif.Add()
+		resStatus, err = pif.Add(actor)
 		if resStatus != i18n.StatusOK || err != nil {
 			return
 		}
@@ -258,7 +261,7 @@ func (pifw *PPEIssuanceFormWizard) SeparateGeneration() (resStatus i18n.ResKey,
 }
 
 // Add PPE Issuance Form
-func (pif *PPEIssuanceForm) Add() (resStatus i18n.ResKey, err error) {
+func (pif *PPEIssuanceForm) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check the number of rows in the body, it cannot be zero
 	if len(pif.Body) == 0 {
@@ -273,6 +276,13 @@ func (pif *PPEIssuanceForm) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Start the audit trail
+	at, err := beginAudit(tx, actor, pub.PPEIF, AuditActionAdd)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Get the latest Serial Number
 	billNo, resStatus, err := GetLatestSerialNo(tx, "PIF")
 	if resStatus != i18n.StatusOK || err != nil {
@@ -362,6 +372,12 @@ func (pif *PPEIssuanceForm) Add() (resStatus i18n.ResKey, err error) {
 			}
 		}
 	}
+	// Write the audit trail
+	err = at.write(pif.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
@@ -609,10 +625,40 @@ func (pif *PPEIssuanceForm) FillHead() (resStatus i18n.ResKey, err error) {
 	return
 }
 
+// Filterable fields of the PPE Issuance Form list
+var ppeifListFields = filter.Fields{
+	"billNumber":     {Column: "h.billnumber", Type: filter.String},
+	"billDate":       {Column: "h.billdate", Type: filter.Time},
+	"description":    {Column: "h.description", Type: filter.String},
+	"period":         {Column: "h.period", Type: filter.String},
+	"startDate":      {Column: "h.startdate", Type: filter.Time},
+	"endDate":        {Column: "h.enddate", Type: filter.Time},
+	"sourceType":     {Column: "h.sourcetype", Type: filter.String},
+	"status":         {Column: "h.status", Type: filter.Number},
+	"departmentID":   {Column: "h.deptid", Type: filter.Number},
+	"departmentCode": {Column: "department.code", Type: filter.String},
+	"departmentName": {Column: "department.name", Type: filter.String},
+	"creatorID":      {Column: "h.creatorid", Type: filter.Number},
+	"creatorCode":    {Column: "creator.code", Type: filter.String},
+	"creatorName":    {Column: "creator.name", Type: filter.String},
+	"modifierID":     {Column: "h.modifierid", Type: filter.Number},
+	"modifierName":   {Column: "modifier.name", Type: filter.String},
+	"createDate":     {Column: "h.createtime", Type: filter.Time},
+	"confirmDate":    {Column: "h.confirmtime", Type: filter.Time},
+	"modifyDate":     {Column: "h.modifytime", Type: filter.Time},
+}
+
 // Get PPE Issuance Form List
-func GetPPEIFList(queryString string) (pifs []PPEIssuanceForm, resStatus i18n.ResKey, err error) {
+func GetPPEIFList(queryFilter filter.Group, ds DataScope) (pifs []PPEIssuanceForm, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
+	// Restrict the query to the data scope of the user
+	queryFilter = ds.Restrict(queryFilter, "departmentID", "creatorID")
 	pifs = make([]PPEIssuanceForm, 0)
+	// Compile the query filter
+	whereSql, args, resStatus := compileFilter(queryFilter, ppeifListFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Concatenate SQL String for checking
 	build.WriteString(`select count(h.id) as rownumber
@@ -621,15 +667,15 @@ func GetPPEIFList(queryString string) (pifs []PPEIssuanceForm, resStatus i18n.Re
 	left join sysuser as creator on h.creatorid = creator.id
 	left join sysuser as modifier on h.modifierid = modifier.id
 	where (h.dr = 0) `)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 	// Check
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, args...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetPPEIFList db.QueryRow(checkSql) failed:", zap.Error(err))
@@ -654,14 +700,14 @@ func GetPPEIFList(queryString string) (pifs []PPEIssuanceForm, resStatus i18n.Re
 	left join sysuser as creator on h.creatorid = creator.id
 	left join sysuser as modifier on h.modifierid = modifier.id
 	where (h.dr = 0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	headSql := build.String()
 
-	headRows, err := db.Query(headSql)
+	headRows, err := db.Query(headSql, args...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetPPEIFList db.Query failed:", zap.Error(err))
@@ -692,7 +738,7 @@ func GetPPEIFList(queryString string) (pifs []PPEIssuanceForm, resStatus i18n.Re
 }
 
 // Edit PPE Issuance Form
-func (pif *PPEIssuanceForm) Edit() (resStatus i18n.ResKey, err error) {
+func (pif *PPEIssuanceForm) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check the number of rows in the body, it cannot be zero
 	if len(pif.Body) == 0 {
@@ -704,6 +750,11 @@ func (pif *PPEIssuanceForm) Edit() (resStatus i18n.ResKey, err error) {
 		resStatus = i18n.StatusVoucherOnlyCreateEdit
 		return
 	}
+	// Vouchers waiting for approval can't be modified
+	resStatus, err = checkApprovalPending(pub.PPEIF, pif.HID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 
 	// Begin a database transaction
 	tx, err := db.Begin()
@@ -713,6 +764,13 @@ func (pif *PPEIssuanceForm) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.PPEIF, AuditActionEdit, pif.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Modify the header content in the ppeissuanceform_h table
 	ldHeadSql := `update ppeissuanceform_h set billdate=$1,deptid=$2,description=$3, period=$4,startdate=$5,
 	enddate=$6,sourcetype=$7,modifytime=current_timestamp,modifierid=$8,ts=current_timestamp  
@@ -937,11 +995,17 @@ func (pif *PPEIssuanceForm) Edit() (resStatus i18n.ResKey, err error) {
 			}
 		}
 	}
+	// Write the audit trail
+	err = at.write(pif.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Delete PPE Issuance Form
-func (pif *PPEIssuanceForm) Delete(operatorID int32) (resStatus i18n.ResKey, err error) {
+func (pif *PPEIssuanceForm) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Get PPE Issuance Form Details
 	resStatus, err = pif.GetDetailByHID()
@@ -954,10 +1018,15 @@ func (pif *PPEIssuanceForm) Delete(operatorID int32) (resStatus i18n.ResKey, err
 		return
 	}
 	// Check if the modifier is the creator
-	if pif.Creator.ID != operatorID {
+	if pif.Creator.ID != actor.UserID {
 		resStatus = i18n.StatusVoucherOnlyCreateEdit
 		return
 	}
+	// Vouchers waiting for approval can't be deleted
+	resStatus, err = checkApprovalPending(pub.PPEIF, pif.HID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 	// Begin a database transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -966,10 +1035,17 @@ func (pif *PPEIssuanceForm) Delete(operatorID int32) (resStatus i18n.ResKey, err
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.PPEIF, AuditActionDelete, pif.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Update delete flag in the ppeissuanceform_h table
 	delHeadSql := `update ppeissuanceform_h set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp 
 	where id=$2 and dr=0 and ts=$3`
-	delHeadRes, err := tx.Exec(delHeadSql, operatorID, pif.HID, pif.Ts)
+	delHeadRes, err := tx.Exec(delHeadSql, actor.UserID, pif.HID, pif.Ts)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("PPEIssuanceForm.Delete tx.Exec(delHeadSql) failed:", zap.Error(err))
@@ -1003,7 +1079,7 @@ func (pif *PPEIssuanceForm) Delete(operatorID int32) (resStatus i18n.ResKey, err
 
 	if len(pif.HFiles) > 0 {
 		for _, hFile := range pif.HFiles {
-			delHFileRes, delHFileErr := delHFileStmt.Exec(operatorID, hFile.ID, pif.HID, hFile.Ts)
+			delHFileRes, delHFileErr := delHFileStmt.Exec(actor.UserID, hFile.ID, pif.HID, hFile.Ts)
 			if delHFileErr != nil {
 				resStatus = i18n.StatusInternalError
 				zap.L().Error("PPEIssuanceForm.Delete delHFileStmt.Exec() failed:", zap.Error(delHFileErr))
@@ -1056,7 +1132,7 @@ func (pif *PPEIssuanceForm) Delete(operatorID int32) (resStatus i18n.ResKey, err
 			tx.Rollback()
 			return
 		}
-		delRowRes, errDelRow := delRowStmt.Exec(operatorID, row.BID, row.Ts)
+		delRowRes, errDelRow := delRowStmt.Exec(actor.UserID, row.BID, row.Ts)
 		if errDelRow != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("PPEIssuanceForm.Delete delRowStmt.Exec() failed:", zap.Error(errDelRow))
@@ -1079,7 +1155,7 @@ func (pif *PPEIssuanceForm) Delete(operatorID int32) (resStatus i18n.ResKey, err
 
 		if len(row.BFiles) > 0 {
 			for _, file := range row.BFiles {
-				delFileRes, delFileErr := delFileStmt.Exec(operatorID, file.ID, row.BID, file.Ts)
+				delFileRes, delFileErr := delFileStmt.Exec(actor.UserID, file.ID, row.BID, file.Ts)
 				if delFileErr != nil {
 					resStatus = i18n.StatusInternalError
 					zap.L().Error("PPEIssuanceForm.Delete delFileStmt.Exec() failed:", zap.Error(delFileErr))
@@ -1103,11 +1179,64 @@ func (pif *PPEIssuanceForm) Delete(operatorID int32) (resStatus i18n.ResKey, err
 		}
 	}
 
+	// Write the audit trail
+	err = at.write(pif.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
+	return
+}
+
+// Confirm PPE Issuance Form.
+// With an approval flow the PPE Issuance Form is submitted for approval instead,
+// it is confirmed once the last approval step is approved.
+func (pif *PPEIssuanceForm) Confirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = submitForApproval(pub.PPEIF, pif.HID, actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return pif.confirm(actor)
+}
+
+// Confirm PPE Issuance Form without approval, then tell the recipients their PPE is ready
+func (pif *PPEIssuanceForm) confirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = pif.writeConfirm(actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	pif.confirmed()
+	return
+}
+
+// Notify the recipients of the committed confirmation
+func (pif *PPEIssuanceForm) confirmed() {
+	pushRows(PushPPE, pub.PPEIF, pif.HID, `select b.id,b.rownumber,b.recipientid,h.billnumber
+	from ppeissuanceform_b as b
+	left join ppeissuanceform_h as h on b.hid = h.id
+	where b.hid=$1 and b.dr=0`)
+}
+
+// Write the confirmation of the PPE Issuance Form
+func (pif *PPEIssuanceForm) writeConfirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	// Begin a database transaction
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("PPEIssuanceForm.Confirm db.Begin failed:", zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	resStatus, err = pif.writeConfirmTx(tx, actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		tx.Rollback()
+	}
 	return
 }
 
-// Confirm PPE Issuance Form
-func (pif *PPEIssuanceForm) Confirm(operatorID int32) (resStatus i18n.ResKey, err error) {
+// Write the confirmation of the PPE Issuance Form and its audit trail in the transaction
+func (pif *PPEIssuanceForm) writeConfirmTx(tx *sql.Tx, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Get PPE Issuance Form Details
 	resStatus, err = pif.GetDetailByHID()
@@ -1119,22 +1248,19 @@ func (pif *PPEIssuanceForm) Confirm(operatorID int32) (resStatus i18n.ResKey, er
 		resStatus = i18n.StatusVoucherNoFree
 		return
 	}
-	// Begin a database transaction
-	tx, err := db.Begin()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.PPEIF, AuditActionConfirm, pif.HID)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
-		zap.L().Error("PPEIssuanceForm.Confirm db.Begin failed:", zap.Error(err))
 		return
 	}
-	defer tx.Commit()
 	// Update header to confirmed status
 	confirmHeadSql := `update ppeissuanceform_h set status=1,confirmtime=current_timestamp,confirmerid=$1,ts=current_timestamp 
 	where id=$2 and dr=0 and status=0 and ts=$3`
-	headRes, err := tx.Exec(confirmHeadSql, operatorID, pif.HID, pif.Ts)
+	headRes, err := tx.Exec(confirmHeadSql, actor.UserID, pif.HID, pif.Ts)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("PPEIssuanceForm.Confirm tx.Exec(confirmHeadSql) failed:", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	// Check the number of affected rows
@@ -1142,12 +1268,10 @@ func (pif *PPEIssuanceForm) Confirm(operatorID int32) (resStatus i18n.ResKey, er
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("PPEIssuanceForm.Confirm headRes.RowsAffected failed:", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	if confirmHeadNumber < 1 {
 		resStatus = i18n.StatusOtherEdit
-		tx.Rollback()
 		return
 	}
 
@@ -1158,7 +1282,6 @@ func (pif *PPEIssuanceForm) Confirm(operatorID int32) (resStatus i18n.ResKey, er
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("PPEIssuanceForm.Confirm tx.Prepare(confirmRowSql) failed:", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	defer rowStmt.Close()
@@ -1167,14 +1290,12 @@ func (pif *PPEIssuanceForm) Confirm(operatorID int32) (resStatus i18n.ResKey, er
 		// Check the status of the body row
 		if row.Status != 0 {
 			resStatus = i18n.StatusVoucherNoFree
-			tx.Rollback()
 			return
 		}
-		confirmRowRes, errConfirmRow := rowStmt.Exec(operatorID, row.BID, row.Ts)
+		confirmRowRes, errConfirmRow := rowStmt.Exec(actor.UserID, row.BID, row.Ts)
 		if errConfirmRow != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("PPEIssuanceForm.Confirm rowStmt.Exec failed:", zap.Error(errConfirmRow))
-			tx.Rollback()
 			return resStatus, errConfirmRow
 		}
 
@@ -1182,20 +1303,33 @@ func (pif *PPEIssuanceForm) Confirm(operatorID int32) (resStatus i18n.ResKey, er
 		if errConfirmRow != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("PPEIssuanceForm.Confirm confirmRowRes.RowsAffected failed:", zap.Error(errConfirmRow))
-			tx.Rollback()
 			return resStatus, errConfirmRow
 		}
 		if confirmRowNumber < 1 {
 			resStatus = i18n.StatusOtherEdit
-			tx.Rollback()
 			return
 		}
 	}
+	// Write the audit trail
+	err = at.write(pif.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+	}
 	return
 }
 
-// Unconfirm PPE Issuance Form
-func (pif *PPEIssuanceForm) Unconfirm(operatorID int32) (resStatus i18n.ResKey, err error) {
+// Unconfirm PPE Issuance Form.
+// Its approval is canceled, it has to be approved again before it is confirmed.
+func (pif *PPEIssuanceForm) Unconfirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = pif.unconfirm(actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return cancelApproval(pub.PPEIF, pif.HID, actor.UserID)
+}
+
+// Unconfirm PPE Issuance Form without touching its approval
+func (pif *PPEIssuanceForm) unconfirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Get PPE Issuance Form Details
 	resStatus, err = pif.GetDetailByHID()
@@ -1208,7 +1342,7 @@ func (pif *PPEIssuanceForm) Unconfirm(operatorID int32) (resStatus i18n.ResKey,
 		return
 	}
 	// Check if the operator is the confirmer
-	if pif.Confirmer.ID != operatorID {
+	if pif.Confirmer.ID != actor.UserID {
 		resStatus = i18n.StatusVoucherCancelConfirmSelf
 		return
 	}
@@ -1231,6 +1365,13 @@ func (pif *PPEIssuanceForm) Unconfirm(operatorID int32) (resStatus i18n.ResKey,
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.PPEIF, AuditActionUnConfirm, pif.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Update header to unconfirmed status
 	confirmHeadSql := `update ppeissuanceform_h set status=0,confirmerid=0,confirmtime=to_timestamp(0),ts=current_timestamp 
 	where id=$1 and dr=0 and status=1 and ts=$2`
@@ -1294,13 +1435,52 @@ func (pif *PPEIssuanceForm) Unconfirm(operatorID int32) (resStatus i18n.ResKey,
 			return
 		}
 	}
+	// Write the audit trail
+	err = at.write(pif.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
+// Filterable fields of the PPE Issuance Form report
+var ppeifReportFields = filter.Fields{
+	"billNumber":     {Column: "h.billnumber", Type: filter.String},
+	"billDate":       {Column: "h.billdate", Type: filter.Time},
+	"period":         {Column: "h.period", Type: filter.String},
+	"startDate":      {Column: "h.startdate", Type: filter.Time},
+	"endDate":        {Column: "h.enddate", Type: filter.Time},
+	"sourceType":     {Column: "h.sourcetype", Type: filter.String},
+	"status":         {Column: "h.status", Type: filter.Number},
+	"departmentID":   {Column: "h.deptid", Type: filter.Number},
+	"departmentCode": {Column: "issuedept.code", Type: filter.String},
+	"departmentName": {Column: "issuedept.name", Type: filter.String},
+	"recipientID":    {Column: "b.recipientid", Type: filter.Number},
+	"recipientCode":  {Column: "recipient.code", Type: filter.String},
+	"recipientName":  {Column: "recipient.name", Type: filter.String},
+	"positionName":   {Column: "b.positionname", Type: filter.String},
+	"deptName":       {Column: "b.deptname", Type: filter.String},
+	"ppeID":          {Column: "b.ppeid", Type: filter.Number},
+	"ppeCode":        {Column: "ppe.code", Type: filter.String},
+	"ppeName":        {Column: "ppe.name", Type: filter.String},
+	"ppeModel":       {Column: "ppe.model", Type: filter.String},
+	"quantity":       {Column: "b.quantity", Type: filter.Number},
+	"creatorID":      {Column: "h.creatorid", Type: filter.Number},
+	"creatorName":    {Column: "creator.name", Type: filter.String},
+}
+
 // Get PPE Issuance Form Report
-func GetPPEIFReport(queryString string) (pifrs []PPEIssuanceFormReport, resStatus i18n.ResKey, err error) {
+func GetPPEIFReport(queryFilter filter.Group, ds DataScope) (pifrs []PPEIssuanceFormReport, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
+	// Restrict the query to the data scope of the user
+	queryFilter = ds.Restrict(queryFilter, "departmentID", "recipientID")
 	pifrs = make([]PPEIssuanceFormReport, 0)
+	// Compile the query filter
+	whereSql, args, resStatus := compileFilter(queryFilter, ppeifReportFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Concatenate SQL to check the number of records
 	build.WriteString(`select count(b.hid) as rowcount 
@@ -1311,15 +1491,15 @@ func GetPPEIFReport(queryString string) (pifrs []PPEIssuanceFormReport, resStatu
 	left join department as issuedept on h.deptid = issuedept.id
 	left join sysuser as creator on h.creatorid=creator.id
 	where (b.dr=0 and h.dr=0) `)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 	// Check the number of records
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, args...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetPPEIFReport db.QueryRow(checkSql) failed", zap.Error(err))
@@ -1374,14 +1554,14 @@ func GetPPEIFReport(queryString string) (pifrs []PPEIssuanceFormReport, resStatu
 	left join department as issuedept on h.deptid = issuedept.id
 	left join sysuser as creator on h.creatorid=creator.id
 	where (b.dr=0 and h.dr=0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	repSql := build.String()
 	// Get report data
-	ldRep, err := db.Query(repSql)
+	ldRep, err := db.Query(repSql, args...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetQueryDocumentReport db.Query failed", zap.Error(err))
@@ -1,7 +1,10 @@
 package pg
 
 import (
+	"database/sql"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/filter"
+	"sccsmsserver/pub"
 	"sccsmsserver/setting"
 	"strings"
 	"time"
@@ -54,7 +57,7 @@ type PPEQuotaRow struct {
 }
 
 // Add Personal Protective Equipment Quota
-func (pq *PPEQuota) Add() (resStatus i18n.ResKey, err error) {
+func (pq *PPEQuota) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check the number of rows in the body, it cannot be zero
 	if len(pq.Body) == 0 {
@@ -74,6 +77,13 @@ func (pq *PPEQuota) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Start the audit trail
+	at, err := beginAudit(tx, actor, pub.PQ, AuditActionAdd)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Insert  the header content to the ppequota_h table
 	headSql := `insert into ppequotas_h(billdate,positionid,period,description,status,
@@ -112,6 +122,12 @@ func (pq *PPEQuota) Add() (resStatus i18n.ResKey, err error) {
 			return
 		}
 	}
+	// Write the audit trail
+	err = at.write(pq.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
@@ -133,10 +149,32 @@ func (pq *PPEQuota) CheckExist() (resStatus i18n.ResKey, err error) {
 	return
 }
 
+// Filterable fields of the PPE Quota list
+var pqListFields = filter.Fields{
+	"billDate":     {Column: "h.billdate", Type: filter.Time},
+	"positionID":   {Column: "h.positionid", Type: filter.Number},
+	"positionName": {Column: "position.name", Type: filter.String},
+	"period":       {Column: "h.period", Type: filter.String},
+	"description":  {Column: "h.description", Type: filter.String},
+	"status":       {Column: "h.status", Type: filter.Number},
+	"creatorID":    {Column: "h.creatorid", Type: filter.Number},
+	"creatorName":  {Column: "creator.name", Type: filter.String},
+	"modifierID":   {Column: "h.modifierid", Type: filter.Number},
+	"modifierName": {Column: "modifier.name", Type: filter.String},
+	"createDate":   {Column: "h.createtime", Type: filter.Time},
+	"confirmDate":  {Column: "h.confirmtime", Type: filter.Time},
+	"modifyDate":   {Column: "h.modifytime", Type: filter.Time},
+}
+
 // Get Personal Protective Equipment Quota List
-func GetPQList(queryString string) (pqs []PPEQuota, resStatus i18n.ResKey, err error) {
+func GetPQList(queryFilter filter.Group) (pqs []PPEQuota, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	pqs = make([]PPEQuota, 0)
+	// Compile the query filter
+	whereSql, args, resStatus := compileFilter(queryFilter, pqListFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Concatenate the SQL for inspection
 	build.WriteString(`select count(h.id) as rownumber
@@ -145,15 +183,15 @@ func GetPQList(queryString string) (pqs []PPEQuota, resStatus i18n.ResKey, err e
 	left join sysuser as creator on h.creatorid=creator.id
 	left join sysuser as modifier on h.modifierid=modifier.id
 	where (h.dr = 0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 	// Check
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, args...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetPQList db.QueryRow(checkSql) failed", zap.Error(err))
@@ -177,15 +215,15 @@ func GetPQList(queryString string) (pqs []PPEQuota, resStatus i18n.ResKey, err e
 	left join sysuser as creator on h.creatorid=creator.id
 	left join sysuser as modifier on h.modifierid=modifier.id
 	where (h.dr = 0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	build.WriteString(`order by h.ts desc`)
 	headSql := build.String()
 	// Retrieve the PPEQuota list from database
-	headRows, err := db.Query(headSql)
+	headRows, err := db.Query(headSql, args...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetPQList db.Query failed", zap.Error(err))
@@ -335,7 +373,7 @@ func (pq *PPEQuota) GetDetailByHID() (resStatus i18n.ResKey, err error) {
 }
 
 // Edit Personal Protective Equipment Quota
-func (pq *PPEQuota) Edit() (resStatus i18n.ResKey, err error) {
+func (pq *PPEQuota) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Checke the number of rows in the body, it cannot not be zero
 	if len(pq.Body) == 0 {
@@ -347,6 +385,11 @@ func (pq *PPEQuota) Edit() (resStatus i18n.ResKey, err error) {
 		resStatus = i18n.StatusVoucherOnlyCreateEdit
 		return
 	}
+	// Vouchers waiting for approval can't be modified
+	resStatus, err = checkApprovalPending(pub.PQ, pq.HID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 	// Check if a PPE Position Quota for the same period
 	resStatus, err = pq.CheckExist()
 	if resStatus != i18n.StatusOK || err != nil {
@@ -360,6 +403,13 @@ func (pq *PPEQuota) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.PQ, AuditActionEdit, pq.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Update the header content in the ppequotas_h table
 	editHeadSql := `update ppequotas_h set billdate=$1, positionid=$2,period=$3,description=$4,status=$5,
 	modifytime=current_timestamp,modifierid=$6,ts=current_timestamp
@@ -445,11 +495,17 @@ func (pq *PPEQuota) Edit() (resStatus i18n.ResKey, err error) {
 			}
 		}
 	}
+	// Write the audit trail
+	err = at.write(pq.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Delete Personal Protective Equipment Quota
-func (pq *PPEQuota) Delete(operatorID int32) (resStatus i18n.ResKey, err error) {
+func (pq *PPEQuota) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Get the PPEQuota Details
 	resStatus, err = pq.GetDetailByHID()
@@ -462,10 +518,15 @@ func (pq *PPEQuota) Delete(operatorID int32) (resStatus i18n.ResKey, err error)
 		return
 	}
 	// Check if the Creator and Operator are the same person
-	if pq.Creator.ID != operatorID {
+	if pq.Creator.ID != actor.UserID {
 		resStatus = i18n.StatusVoucherOnlyCreateEdit
 		return
 	}
+	// Vouchers waiting for approval can't be deleted
+	resStatus, err = checkApprovalPending(pub.PQ, pq.HID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 	// Begin a database transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -474,11 +535,18 @@ func (pq *PPEQuota) Delete(operatorID int32) (resStatus i18n.ResKey, err error)
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.PQ, AuditActionDelete, pq.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Update the deletion flag in the ppequotas_h table
 	delHeadSql := `update ppequotas_h set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp 
 	where id=$2 and dr=0 and ts=$3`
-	delHeadRes, err := tx.Exec(delHeadSql, operatorID, pq.HID, pq.Ts)
+	delHeadRes, err := tx.Exec(delHeadSql, actor.UserID, pq.HID, pq.Ts)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("PPEQuota.Delete tx.Exec(delHeadSql) failed", zap.Error(err))
@@ -517,7 +585,7 @@ func (pq *PPEQuota) Delete(operatorID int32) (resStatus i18n.ResKey, err error)
 			tx.Rollback()
 			return
 		}
-		delRowRes, errDelRow := delRowStmt.Exec(operatorID, row.BID, row.Ts)
+		delRowRes, errDelRow := delRowStmt.Exec(actor.UserID, row.BID, row.Ts)
 		if errDelRow != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("PPEQuota.Delete delRowStmt.Exec() failed", zap.Error(errDelRow))
@@ -539,11 +607,46 @@ func (pq *PPEQuota) Delete(operatorID int32) (resStatus i18n.ResKey, err error)
 		}
 	}
 
+	// Write the audit trail
+	err = at.write(pq.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
+	return
+}
+
+// Confirm Personal Protective Equipment Quota.
+// With an approval flow the Personal Protective Equipment Quota is submitted for approval instead,
+// it is confirmed once the last approval step is approved.
+func (pq *PPEQuota) Confirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = submitForApproval(pub.PQ, pq.HID, actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return pq.confirm(actor)
+}
+
+// Confirm Personal Protective Equipment Quota without approval
+func (pq *PPEQuota) confirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	// Begin a database transaction
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("PPEQuota.Confirm db.Begin failed", zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	resStatus, err = pq.confirmTx(tx, actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		tx.Rollback()
+	}
 	return
 }
 
-// Confirm Personal Protective Equipment Quota
-func (pq *PPEQuota) Confirm(operatorID int32) (resStatus i18n.ResKey, err error) {
+// Write the confirmation of the Personal Protective Equipment Quota and its audit trail in the transaction
+func (pq *PPEQuota) confirmTx(tx *sql.Tx, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Get the PPEQuota details
 	resStatus, err = pq.GetDetailByHID()
@@ -555,22 +658,19 @@ func (pq *PPEQuota) Confirm(operatorID int32) (resStatus i18n.ResKey, err error)
 		resStatus = i18n.StatusVoucherNoFree
 		return
 	}
-	// Begin a database transaction
-	tx, err := db.Begin()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.PQ, AuditActionConfirm, pq.HID)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
-		zap.L().Error("PPEQuota.Confirm db.Begin failed", zap.Error(err))
 		return
 	}
-	defer tx.Commit()
 	// Update the confirmation flag in the ppequotas_h table
 	confirmHeadSql := `update ppequotas_h set status=1,confirmtime=current_timestamp,confirmerid=$1,ts=current_timestamp 
 	where id=$2 and dr=0 and status=0 and ts=$3`
-	headRes, err := tx.Exec(confirmHeadSql, operatorID, pq.HID, pq.Ts)
+	headRes, err := tx.Exec(confirmHeadSql, actor.UserID, pq.HID, pq.Ts)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("PPEQuota.Confirm tx.Exec(confirmHeadSql) failed", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	// Check the number of rows affected by SQL statement
@@ -578,12 +678,10 @@ func (pq *PPEQuota) Confirm(operatorID int32) (resStatus i18n.ResKey, err error)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("PPEQuota.Confirm headRes.RowsAffected failed", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	if confirmHeadNumber < 1 {
 		resStatus = i18n.StatusOtherEdit
-		tx.Rollback()
 		return
 	}
 	// Prepare to update the comfirmation flage in the ppequotas_b table
@@ -593,7 +691,6 @@ func (pq *PPEQuota) Confirm(operatorID int32) (resStatus i18n.ResKey, err error)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("PPEQuota.Confirm tx.Prepare(confirmRowSql) failed", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	defer rowStmt.Close()
@@ -602,34 +699,45 @@ func (pq *PPEQuota) Confirm(operatorID int32) (resStatus i18n.ResKey, err error)
 		// Check the row status
 		if row.Status != 0 { // Cannot confirm if the status is not 0
 			resStatus = i18n.StatusVoucherNoFree
-			tx.Rollback()
 			return
 		}
-		confirmRowRes, errConfirmRow := rowStmt.Exec(operatorID, row.BID, row.Ts)
+		confirmRowRes, errConfirmRow := rowStmt.Exec(actor.UserID, row.BID, row.Ts)
 		if errConfirmRow != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("PPEQuota.Confirm rowStmt.Exec failed", zap.Error(errConfirmRow))
-			tx.Rollback()
 			return resStatus, errConfirmRow
 		}
 		confirmRowNumber, errConfirmRow := confirmRowRes.RowsAffected()
 		if errConfirmRow != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("PPEQuota.Confirm confirmRowRes.RowsAffected failed", zap.Error(errConfirmRow))
-			tx.Rollback()
 			return resStatus, errConfirmRow
 		}
 		if confirmRowNumber < 1 {
 			resStatus = i18n.StatusOtherEdit
-			tx.Rollback()
 			return
 		}
 	}
+	// Write the audit trail
+	err = at.write(pq.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+	}
 	return
 }
 
-// Unconfirm Personal Protective Equipment Quota
-func (pq *PPEQuota) Unconfirm(operatorID int32) (resStatus i18n.ResKey, err error) {
+// Unconfirm Personal Protective Equipment Quota.
+// Its approval is canceled, it has to be approved again before it is confirmed.
+func (pq *PPEQuota) Unconfirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = pq.unconfirm(actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return cancelApproval(pub.PQ, pq.HID, actor.UserID)
+}
+
+// Unconfirm Personal Protective Equipment Quota without touching its approval
+func (pq *PPEQuota) unconfirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Get the PPEQuota Details
 	resStatus, err = pq.GetDetailByHID()
@@ -642,7 +750,7 @@ func (pq *PPEQuota) Unconfirm(operatorID int32) (resStatus i18n.ResKey, err erro
 		return
 	}
 	// Check the Confirmer and Operator are the same person
-	if pq.Confirmer.ID != operatorID {
+	if pq.Confirmer.ID != actor.UserID {
 		resStatus = i18n.StatusVoucherCancelConfirmSelf
 		return
 	}
@@ -654,6 +762,13 @@ func (pq *PPEQuota) Unconfirm(operatorID int32) (resStatus i18n.ResKey, err erro
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.PQ, AuditActionUnConfirm, pq.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Update the confirmation flag in the ppequotas_h table
 	confirmHeadSql := `update ppequotas_h set status=0,confirmerid=0,confirmtime=to_timestamp(0),ts=current_timestamp 
 	where id=$1 and dr=0 and status=1 and ts=$2`
@@ -718,6 +833,12 @@ func (pq *PPEQuota) Unconfirm(operatorID int32) (resStatus i18n.ResKey, err erro
 			return
 		}
 	}
+	// Write the audit trail
+	err = at.write(pq.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
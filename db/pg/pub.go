@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/filter"
 	"sccsmsserver/pkg/mysf"
 	"sccsmsserver/pkg/security"
 	"time"
@@ -31,14 +32,14 @@ type DataReferenceCheck struct {
 
 // Data query conditions
 type QueryParams struct {
-	QueryString string `json:"queryString"`
+	Filter filter.Group `json:"filter"`
 }
 
 // Data query pagination
 type PagingQueryParams struct {
-	QueryString string `json:"queryString"`
-	Page        int32  `json:"page"`
-	PerPage     int32  `json:"perPage"`
+	Filter  filter.Group `json:"filter"`
+	Page    int32        `json:"page"`
+	PerPage int32        `json:"perPage"`
 }
 
 // the struct Check the archive is refreneced
@@ -169,3 +170,16 @@ func GetLatestSerialNo(tx *sql.Tx, voucherType string) (serialno string, resStat
 
 	return
 }
+
+// Compile the query filter against the fields whitelist of the query.
+// Placeholders in the returned where clause start at argStart.
+func compileFilter(f filter.Group, fields filter.Fields, argStart int) (whereSql string, args []interface{}, resStatus i18n.ResKey) {
+	resStatus = i18n.StatusOK
+	whereSql, args, err := f.Compile(fields, argStart)
+	if err != nil {
+		zap.L().Info("compileFilter f.Compile failed", zap.Error(err))
+		resStatus = i18n.StatusFilterInvalid
+		return
+	}
+	return
+}
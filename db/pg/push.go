@@ -0,0 +1,172 @@
+package pg
+
+import (
+	"encoding/json"
+	"sccsmsserver/cache"
+	"sccsmsserver/pub"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Cache channel the push messages go through, so that every application server
+// delivers them to the users connected to it
+const pushChannel = "push"
+
+// Messages buffered for a connection, a slow connection misses the messages beyond it
+const pushBuffer = 32
+
+// Push events
+const (
+	PushComment    = "comment"    // New Execution Order comment
+	PushWO         = "wo"         // Work Order row assigned to the executor
+	PushIssue      = "issue"      // Execution Order issue assigned to the issue owner
+	PushConfirm    = "confirm"    // Voucher of the creator confirmed by another user
+	PushEscalation = "escalation" // Overdue item escalated to the user
+	PushPPE        = "ppe"        // PPE of the recipient ready for issuance
+)
+
+// Header tables of the vouchers whose confirmations are pushed
+var pushVoucherTables = map[pub.DataType]string{
+	pub.WO:  "workorder_h",
+	pub.EO:  "executionorder_h",
+	pub.IRF: "issueresolutionform",
+}
+
+// Message pushed to the connected users.
+// The clients fetch the details through the message center.
+type PushMessage struct {
+	Event       string       `json:"event"`
+	VoucherType pub.DataType `json:"voucherType"`
+	HID         int32        `json:"hid"`
+	BID         int32        `json:"bid"`
+	BillNumber  string       `json:"billNumber"`
+	RowNumber   int32        `json:"rowNumber"`
+	Content     string       `json:"content,omitempty"` // Comment content
+	SendTime    time.Time    `json:"sendTime"`
+}
+
+// Push message with its recipients, as published to the cache channel
+type pushEnvelope struct {
+	UserIDs []int32     `json:"userIDs"`
+	Message PushMessage `json:"message"`
+}
+
+// Connections of the users to this application server
+var pushConnections = make(map[int32]map[chan PushMessage]struct{})
+var pushMutex sync.RWMutex
+
+// Start delivering the push messages published by all application servers.
+// Must be called after the cache initialization.
+func StartPush() {
+	cache.Subscribe(pushChannel, deliverPush)
+}
+
+// Connect the user to the push messages.
+// cancel must be called when the connection is closed.
+func SubscribePush(userID int32) (messages <-chan PushMessage, cancel func()) {
+	ch := make(chan PushMessage, pushBuffer)
+	pushMutex.Lock()
+	if pushConnections[userID] == nil {
+		pushConnections[userID] = make(map[chan PushMessage]struct{})
+	}
+	pushConnections[userID][ch] = struct{}{}
+	pushMutex.Unlock()
+	cancel = func() {
+		pushMutex.Lock()
+		defer pushMutex.Unlock()
+		delete(pushConnections[userID], ch)
+		if len(pushConnections[userID]) == 0 {
+			delete(pushConnections, userID)
+		}
+	}
+	return ch, cancel
+}
+
+// Push the message to the users, and queue its email notifications.
+// Pushing is best effort, a failure is logged and doesn't fail the operation that caused it.
+func push(userIDs []int32, msg PushMessage) {
+	recipients := make([]int32, 0, len(userIDs))
+	seen := make(map[int32]bool)
+	for _, id := range userIDs {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			recipients = append(recipients, id)
+		}
+	}
+	if len(recipients) == 0 {
+		return
+	}
+	msg.SendTime = time.Now()
+	v, err := json.Marshal(pushEnvelope{UserIDs: recipients, Message: msg})
+	if err != nil {
+		zap.L().Error("push json.Marshal failed", zap.Error(err))
+		return
+	}
+	_ = cache.Publish(pushChannel, v)
+	// Email the message to the users who want it
+	queueMails(recipients, msg)
+}
+
+// Deliver a published message to the connections of its recipients on this server
+func deliverPush(v []byte) {
+	var envelope pushEnvelope
+	err := json.Unmarshal(v, &envelope)
+	if err != nil {
+		zap.L().Error("deliverPush json.Unmarshal failed", zap.Error(err))
+		return
+	}
+	pushMutex.RLock()
+	defer pushMutex.RUnlock()
+	for _, userID := range envelope.UserIDs {
+		for ch := range pushConnections[userID] {
+			select {
+			case ch <- envelope.Message:
+			default:
+				zap.L().Warn("deliverPush connection buffer full, message dropped", zap.Int32("userID", userID))
+			}
+		}
+	}
+}
+
+// Push the confirmation of the voucher to its creator, unless the creator confirmed it
+func pushConfirmed(voucherType pub.DataType, voucherID int32, confirmerID int32) {
+	table, ok := pushVoucherTables[voucherType]
+	if !ok {
+		return
+	}
+	var billNumber string
+	var creatorID int32
+	sqlStr := "select billnumber,creatorid from " + table + " where id=$1"
+	err := db.QueryRow(sqlStr, voucherID).Scan(&billNumber, &creatorID)
+	if err != nil {
+		zap.L().Error("pushConfirmed db.QueryRow failed", zap.String("voucherType", string(voucherType)), zap.Error(err))
+		return
+	}
+	if creatorID == confirmerID {
+		return
+	}
+	push([]int32{creatorID}, PushMessage{Event: PushConfirm, VoucherType: voucherType, HID: voucherID, BillNumber: billNumber})
+}
+
+// Push the rows of the voucher selected by sqlStr to their users.
+// sqlStr selects the row ID, row number, user ID and bill number of the voucher $1.
+func pushRows(event string, voucherType pub.DataType, hid int32, sqlStr string) {
+	rows, err := db.Query(sqlStr, hid)
+	if err != nil {
+		zap.L().Error("pushRows db.Query failed", zap.String("event", event), zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		msg := PushMessage{Event: event, VoucherType: voucherType, HID: hid}
+		var userID int32
+		err = rows.Scan(&msg.BID, &msg.RowNumber, &userID, &msg.BillNumber)
+		if err != nil {
+			zap.L().Error("pushRows rows.Scan failed", zap.String("event", event), zap.Error(err))
+			return
+		}
+		push([]int32{userID}, msg)
+	}
+}
@@ -0,0 +1,229 @@
+package pg
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/mysf"
+	"sccsmsserver/pub"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Access token and refresh token issued to a client
+type TokenPair struct {
+	Token             string `json:"token"`
+	ExpireTime        int64  `json:"expireTime"`
+	RefreshToken      string `json:"refreshToken"`
+	RefreshExpireTime int64  `json:"refreshExpireTime"`
+	// Short-lived token of a login waiting for the second factor
+	PreAuthToken      string `json:"preAuthToken,omitempty"`
+	PreAuthExpireTime int64  `json:"preAuthExpireTime,omitempty"`
+}
+
+// Refresh token request parameters
+type ParamRefreshToken struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+	ClientIP     string `json:"clientIp"`
+	ClientType   string `json:"clientType"`
+	UserAgent    string `json:"userAgent"`
+}
+
+// Server-side refresh token record.
+// All the refresh tokens rotated from the same login share the session ID.
+type refreshToken struct {
+	ID                int32
+	UserID            int32
+	ClientType        string
+	SessionID         string
+	ExpireTime        time.Time
+	SessionExpireTime time.Time
+	UsedTime          sql.NullTime
+	Revoked           int16
+}
+
+// Hash a refresh token, only the hash is stored
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Generate a random refresh token
+func genRefreshToken() (token string, err error) {
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		zap.L().Error("genRefreshToken rand.Read failed", zap.Error(err))
+		return
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Generate a token ID, also used as session ID
+func newTokenID() string {
+	return strconv.FormatInt(mysf.GenID(), 10)
+}
+
+// Create a refresh token of the session
+func createRefreshToken(userID int32, clientType string, sessionID string, sessionExpireTime time.Time) (token string, expireTime time.Time, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	token, err = genRefreshToken()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	expireTime = time.Now().Add(pub.RefreshTokenExpireDuration)
+	if expireTime.After(sessionExpireTime) {
+		expireTime = sessionExpireTime
+	}
+	sqlStr := `insert into sysrefreshtoken(userid,clienttype,sessionid,tokenhash,expiretime,sessionexpiretime)
+	values($1,$2,$3,$4,$5,$6)`
+	_, err = db.Exec(sqlStr, userID, clientType, sessionID, hashRefreshToken(token), expireTime, sessionExpireTime)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("createRefreshToken db.Exec failed", zap.Error(err))
+		return
+	}
+	return
+}
+
+// Start a refresh token session after the user logged in
+func startRefreshSession(userID int32, clientType string, sessionID string) (token string, expireTime time.Time, resStatus i18n.ResKey, err error) {
+	// Purge the refresh tokens expired more than a day ago
+	_, err = db.Exec("delete from sysrefreshtoken where expiretime < current_timestamp - interval '1 day'")
+	if err != nil {
+		zap.L().Error("startRefreshSession purge expired tokens failed", zap.Error(err))
+	}
+	return createRefreshToken(userID, clientType, sessionID, time.Now().Add(pub.RefreshTokenSessionDuration))
+}
+
+// Revoke the refresh tokens of the user on the client type.
+// An empty client type revokes the tokens on all clients.
+func RevokeRefreshTokens(userID int32, clientType string) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	if clientType == "" {
+		_, err = db.Exec("update sysrefreshtoken set revoked=1 where userid=$1 and revoked=0", userID)
+	} else {
+		_, err = db.Exec("update sysrefreshtoken set revoked=1 where userid=$1 and clienttype=$2 and revoked=0", userID, clientType)
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("RevokeRefreshTokens db.Exec failed", zap.Error(err))
+	}
+	return
+}
+
+// Revoke all the refresh tokens of a session
+func revokeRefreshSession(sessionID string) {
+	_, err := db.Exec("update sysrefreshtoken set revoked=1 where sessionid=$1", sessionID)
+	if err != nil {
+		zap.L().Error("revokeRefreshSession db.Exec failed", zap.Error(err))
+	}
+}
+
+// Exchange a refresh token for a new token pair.
+// The refresh token is rotated on each use. Presenting a token that has already
+// been used means it was stolen or replayed, the whole session is then revoked.
+func RefreshToken(p *ParamRefreshToken) (tp TokenPair, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	// Get the refresh token record
+	var rt refreshToken
+	sqlStr := `select id,userid,clienttype,sessionid,expiretime,sessionexpiretime,usedtime,revoked
+	from sysrefreshtoken where tokenhash=$1`
+	err = db.QueryRow(sqlStr, hashRefreshToken(p.RefreshToken)).Scan(&rt.ID, &rt.UserID, &rt.ClientType,
+		&rt.SessionID, &rt.ExpireTime, &rt.SessionExpireTime, &rt.UsedTime, &rt.Revoked)
+	if err == sql.ErrNoRows {
+		resStatus = i18n.CodeInvalidRefreshToken
+		err = nil
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("RefreshToken db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if rt.Revoked != 0 || rt.ClientType != p.ClientType || time.Now().After(rt.ExpireTime) {
+		resStatus = i18n.CodeInvalidRefreshToken
+		return
+	}
+	// Reuse detection
+	if rt.UsedTime.Valid {
+		resStatus, err = revokeReusedSession(rt, p)
+		return
+	}
+	// Mark the refresh token used, a concurrent use of the same token counts as reuse
+	res, err := db.Exec("update sysrefreshtoken set usedtime=current_timestamp where id=$1 and usedtime is null", rt.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("RefreshToken db.Exec failed", zap.Error(err))
+		return
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("RefreshToken res.RowsAffected failed", zap.Error(err))
+		return
+	}
+	if affected < 1 {
+		resStatus, err = revokeReusedSession(rt, p)
+		return
+	}
+	// Check if the user can still log in
+	var userCode string
+	var status, locked int16
+	sqlStr = "select code,status,locked from sysuser where id=$1 and dr=0 and isoperator=1"
+	err = db.QueryRow(sqlStr, rt.UserID).Scan(&userCode, &status, &locked)
+	if err == sql.ErrNoRows {
+		revokeRefreshSession(rt.SessionID)
+		resStatus = i18n.StatusUserNotExist
+		err = nil
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("RefreshToken db.QueryRow(sysuser) failed", zap.Error(err))
+		return
+	}
+	if status != 0 {
+		revokeRefreshSession(rt.SessionID)
+		resStatus = i18n.StatusUserDisabled
+		return
+	}
+	if locked != 0 {
+		revokeRefreshSession(rt.SessionID)
+		resStatus = i18n.StatusUserLocked
+		return
+	}
+	// Issue the new token pair
+	tp.Token, tp.ExpireTime, resStatus, err = issueAccessToken(rt.UserID, userCode, rt.SessionID, rt.ClientType, p.ClientIP, p.UserAgent)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	var refreshExpireTime time.Time
+	tp.RefreshToken, refreshExpireTime, resStatus, err = createRefreshToken(rt.UserID, rt.ClientType, rt.SessionID, rt.SessionExpireTime)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	tp.RefreshExpireTime = refreshExpireTime.Unix()
+	return
+}
+
+// Revoke the session of a reused refresh token and sign the device out
+func revokeReusedSession(rt refreshToken, p *ParamRefreshToken) (resStatus i18n.ResKey, err error) {
+	zap.L().Warn("Refresh token reuse detected, the session is revoked",
+		zap.Int32("userID", rt.UserID), zap.String("clientType", rt.ClientType),
+		zap.String("clientIp", p.ClientIP), zap.String("userAgent", p.UserAgent))
+	ou := OnlineUser{SessionID: rt.SessionID}
+	ou.User.ID = rt.UserID
+	resStatus, err = ou.Del()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	resStatus = i18n.CodeRefreshTokenReused
+	return
+}
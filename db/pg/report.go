@@ -2,7 +2,9 @@ package pg
 
 import (
 	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/filter"
 	"sccsmsserver/setting"
+	"strconv"
 	"strings"
 	"time"
 
@@ -239,10 +241,63 @@ type IssueResolutionFormReport struct {
 	Udf10Name          string        `json:"udf10Name"`
 }
 
+// Add the fields of the CSA user defined attributes to a report whitelist.
+// The report queries join them as udf1 to udf10.
+func withCSAUDFFields(fields filter.Fields) filter.Fields {
+	for i := 1; i <= 10; i++ {
+		n := strconv.Itoa(i)
+		fields["csaUdf"+n+"ID"] = filter.Field{Column: "csa.udf" + n, Type: filter.Number}
+		fields["csaUdf"+n+"Name"] = filter.Field{Column: "udf" + n + ".name", Type: filter.String}
+	}
+	return fields
+}
+
+// Filterable fields of the Work Order report
+var woReportFields = withCSAUDFFields(filter.Fields{
+	"billNumber":         {Column: "h.billnumber", Type: filter.String},
+	"billDate":           {Column: "h.billdate", Type: filter.Time},
+	"workDate":           {Column: "h.workdate", Type: filter.Time},
+	"headerDescription":  {Column: "h.description", Type: filter.String},
+	"departmentID":       {Column: "h.deptid", Type: filter.Number},
+	"departmentName":     {Column: "hdept.name", Type: filter.String},
+	"description":        {Column: "b.description", Type: filter.String},
+	"status":             {Column: "b.status", Type: filter.Number},
+	"csaID":              {Column: "b.csaid", Type: filter.Number},
+	"csaCode":            {Column: "csa.code", Type: filter.String},
+	"csaName":            {Column: "csa.name", Type: filter.String},
+	"respDeptID":         {Column: "csa.respdeptid", Type: filter.Number},
+	"respDeptName":       {Column: "respdept.name", Type: filter.String},
+	"respPersonID":       {Column: "csa.resppersonid", Type: filter.Number},
+	"respPersonName":     {Column: "respperson.name", Type: filter.String},
+	"executorID":         {Column: "b.executorid", Type: filter.Number},
+	"executorName":       {Column: "executor.name", Type: filter.String},
+	"actualExecutorID":   {Column: "eoh.creatorid", Type: filter.Number},
+	"actualExecutorName": {Column: "acturalep.name", Type: filter.String},
+	"eptID":              {Column: "b.eptid", Type: filter.Number},
+	"eptCode":            {Column: "ept_h.code", Type: filter.String},
+	"eptName":            {Column: "ept_h.name", Type: filter.String},
+	"eoID":               {Column: "b.eoid", Type: filter.Number},
+	"eoNumber":           {Column: "b.eonumber", Type: filter.String},
+	"startTime":          {Column: "b.starttime", Type: filter.Time},
+	"endTime":            {Column: "b.endtime", Type: filter.Time},
+	"creatorID":          {Column: "b.creatorid", Type: filter.Number},
+	"creatorName":        {Column: "creator.name", Type: filter.String},
+	"confirmerID":        {Column: "b.confirmerid", Type: filter.Number},
+	"confirmerName":      {Column: "confirmer.name", Type: filter.String},
+	"confirmDate":        {Column: "b.confirmtime", Type: filter.Time},
+})
+
 // Get Work Order Status Report
-func GetWorkOrderReport(queryString string) (wors []WorkOrderReport, resStatus i18n.ResKey, err error) {
+func GetWorkOrderReport(queryFilter filter.Group, ds DataScope) (wors []WorkOrderReport, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
+	// Restrict the query to the data scope of the user
+	queryFilter = ds.Restrict(queryFilter, "departmentID", "creatorID")
 	wors = make([]WorkOrderReport, 0)
+	// Compile the query filter
+	whereSql, args, resStatus := compileFilter(queryFilter, woReportFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Concatenate the SQL string for inspection
 	build.WriteString(`select count(b.id) as rownumber
@@ -269,15 +324,15 @@ func GetWorkOrderReport(queryString string) (wors []WorkOrderReport, resStatus i
 	left join department as hdept on h.deptid = hdept.id
 	left join department as respdept on csa.respdeptid = respdept.id
 	where (b.dr=0) `)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 	// Check
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, args...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetWorkOrderReport db.QueryRow(checkSql) failed", zap.Error(err))
@@ -385,14 +440,14 @@ func GetWorkOrderReport(queryString string) (wors []WorkOrderReport, resStatus i
 	left join department as hdept on h.deptid = hdept.id
 	left join department as respdept on csa.respdeptid = respdept.id
 	where (b.dr=0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	repSql := build.String()
 	// Retrieve Work Order status list from database
-	woRep, err := db.Query(repSql)
+	woRep, err := db.Query(repSql, args...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetWorkOrderReport db.Query failed", zap.Error(err))
@@ -426,10 +481,61 @@ func GetWorkOrderReport(queryString string) (wors []WorkOrderReport, resStatus i
 	return
 }
 
+// Filterable fields of the Execution Order report
+var eoReportFields = withCSAUDFFields(filter.Fields{
+	"billNumber":        {Column: "h.billnumber", Type: filter.String},
+	"billDate":          {Column: "h.billdate", Type: filter.Time},
+	"headerDescription": {Column: "h.description", Type: filter.String},
+	"status":            {Column: "h.status", Type: filter.Number},
+	"sourceType":        {Column: "h.sourcetype", Type: filter.String},
+	"sourceBillNumber":  {Column: "h.sourcebillnumber", Type: filter.String},
+	"departmentID":      {Column: "h.deptid", Type: filter.Number},
+	"departmentName":    {Column: "dept.name", Type: filter.String},
+	"csaID":             {Column: "h.csaid", Type: filter.Number},
+	"csaCode":           {Column: "csa.code", Type: filter.String},
+	"csaName":           {Column: "csa.name", Type: filter.String},
+	"executorID":        {Column: "h.executorid", Type: filter.Number},
+	"executorName":      {Column: "executor.name", Type: filter.String},
+	"eptID":             {Column: "h.eptid", Type: filter.Number},
+	"eptCode":           {Column: "ept_h.code", Type: filter.String},
+	"eptName":           {Column: "ept_h.name", Type: filter.String},
+	"startTime":         {Column: "h.starttime", Type: filter.Time},
+	"endTime":           {Column: "h.endtime", Type: filter.Time},
+	"epaID":             {Column: "b.epaid", Type: filter.Number},
+	"epaCode":           {Column: "epa.code", Type: filter.String},
+	"epaName":           {Column: "epa.name", Type: filter.String},
+	"executionValue":    {Column: "b.executionvaluedisp", Type: filter.String},
+	"description":       {Column: "b.description", Type: filter.String},
+	"isCheckError":      {Column: "b.ischeckerror", Type: filter.Number},
+	"isIssue":           {Column: "b.isissue", Type: filter.Number},
+	"isRectify":         {Column: "b.isrectify", Type: filter.Number},
+	"isHandle":          {Column: "b.ishandle", Type: filter.Number},
+	"isFinish":          {Column: "b.isfinish", Type: filter.Number},
+	"issueOwnerID":      {Column: "b.issueownerid", Type: filter.Number},
+	"issueOwnerName":    {Column: "issueowner.name", Type: filter.String},
+	"handleStartTime":   {Column: "b.handlestarttime", Type: filter.Time},
+	"handleEndTime":     {Column: "b.handleendtime", Type: filter.Time},
+	"riskLevelID":       {Column: "b.risklevelid", Type: filter.Number},
+	"riskLevelName":     {Column: "rl.name", Type: filter.String},
+	"irfNumber":         {Column: "b.irfnumber", Type: filter.String},
+	"creatorID":         {Column: "b.creatorid", Type: filter.Number},
+	"creatorName":       {Column: "creator.name", Type: filter.String},
+	"confirmerID":       {Column: "b.confirmerid", Type: filter.Number},
+	"confirmerName":     {Column: "confirmer.name", Type: filter.String},
+	"confirmDate":       {Column: "b.confirmtime", Type: filter.Time},
+})
+
 // Get Execution Order status report
-func GetExecutionOrderReport(queryString string) (eors []ExecutionOrderReport, resStatus i18n.ResKey, err error) {
+func GetExecutionOrderReport(queryFilter filter.Group, ds DataScope) (eors []ExecutionOrderReport, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
+	// Restrict the query to the data scope of the user
+	queryFilter = ds.Restrict(queryFilter, "departmentID", "creatorID")
 	eors = make([]ExecutionOrderReport, 0)
+	// Compile the query filter
+	whereSql, args, resStatus := compileFilter(queryFilter, eoReportFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Concatenate the SQL string for check
 	build.WriteString(`select count(b.id) as rownumber 
@@ -455,15 +561,15 @@ func GetExecutionOrderReport(queryString string) (eors []ExecutionOrderReport, r
 	left join uda as udf9 on csa.udf9 = udf9.id
 	left join uda as udf10 on csa.udf10 = udf10.id
 	where (b.dr=0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 	// Check
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, args...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetExecutionOrderReport db.QueryRow(checkSql) failed", zap.Error(err))
@@ -582,14 +688,14 @@ func GetExecutionOrderReport(queryString string) (eors []ExecutionOrderReport, r
 	left join uda as udf9 on csa.udf9 = udf9.id
 	left join uda as udf10 on csa.udf10 = udf10.id
 	where (b.dr=0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	repSql := build.String()
 	// Retrieve Execution Order Reports from database
-	eoRep, err := db.Query(repSql)
+	eoRep, err := db.Query(repSql, args...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetExecutionOrderReport db.Query failed", zap.Error(err))
@@ -626,10 +732,59 @@ func GetExecutionOrderReport(queryString string) (eors []ExecutionOrderReport, r
 	return
 }
 
+// Filterable fields of the Issue Resolution Form report
+var irfReportFields = withCSAUDFFields(filter.Fields{
+	"billNumber":      {Column: "h.billnumber", Type: filter.String},
+	"billDate":        {Column: "h.billdate", Type: filter.Time},
+	"departmentID":    {Column: "h.deptid", Type: filter.Number},
+	"departmentName":  {Column: "dept.name", Type: filter.String},
+	"csaID":           {Column: "h.csaid", Type: filter.Number},
+	"csaCode":         {Column: "csa.code", Type: filter.String},
+	"csaName":         {Column: "csa.name", Type: filter.String},
+	"executorID":      {Column: "h.executorid", Type: filter.Number},
+	"executorName":    {Column: "executor.name", Type: filter.String},
+	"eptID":           {Column: "h.eptid", Type: filter.Number},
+	"eptName":         {Column: "ept_h.name", Type: filter.String},
+	"epaID":           {Column: "b.epaid", Type: filter.Number},
+	"epaCode":         {Column: "epa.code", Type: filter.String},
+	"epaName":         {Column: "epa.name", Type: filter.String},
+	"executionValue":  {Column: "b.executionvaluedisp", Type: filter.String},
+	"isRectify":       {Column: "b.isrectify", Type: filter.Number},
+	"isHandle":        {Column: "b.ishandle", Type: filter.Number},
+	"isFinish":        {Column: "b.isfinish", Type: filter.Number},
+	"issueOwnerID":    {Column: "b.issueownerid", Type: filter.Number},
+	"issueOwnerName":  {Column: "issueowner.name", Type: filter.String},
+	"handleStartTime": {Column: "b.handlestarttime", Type: filter.Time},
+	"handleEndTime":   {Column: "b.handleendtime", Type: filter.Time},
+	"riskLevelID":     {Column: "b.risklevelid", Type: filter.Number},
+	"riskLevelName":   {Column: "rl.name", Type: filter.String},
+	"irfID":           {Column: "b.irfid", Type: filter.Number},
+	"irfNumber":       {Column: "b.irfnumber", Type: filter.String},
+	"irfBillDate":     {Column: "irf.billdate", Type: filter.Time},
+	"irfStatus":       {Column: "irf.status", Type: filter.Number},
+	"irfDescription":  {Column: "irf.description", Type: filter.String},
+	"handlerID":       {Column: "irf.handlerid", Type: filter.Number},
+	"handlerName":     {Column: "handler.name", Type: filter.String},
+	"irfStartTime":    {Column: "irf.starttime", Type: filter.Time},
+	"irfEndTime":      {Column: "irf.endtime", Type: filter.Time},
+	"creatorID":       {Column: "irf.creatorid", Type: filter.Number},
+	"creatorName":     {Column: "creator.name", Type: filter.String},
+	"confirmerID":     {Column: "irf.confirmerid", Type: filter.Number},
+	"confirmerName":   {Column: "confirmer.name", Type: filter.String},
+	"confirmDate":     {Column: "irf.confirmtime", Type: filter.Time},
+})
+
 // Get Issue Resolution Form Report
-func GetIssueResolutionFormReport(queryString string) (irfs []IssueResolutionFormReport, resStatus i18n.ResKey, err error) {
+func GetIssueResolutionFormReport(queryFilter filter.Group, ds DataScope) (irfs []IssueResolutionFormReport, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
+	// Restrict the query to the data scope of the user
+	queryFilter = ds.Restrict(queryFilter, "departmentID", "creatorID")
 	irfs = make([]IssueResolutionFormReport, 0)
+	// Compile the query filter
+	whereSql, args, resStatus := compileFilter(queryFilter, irfReportFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Concatenate the SQL string for
 	build.WriteString(`select count(b.id) as rowcount
@@ -657,15 +812,15 @@ func GetIssueResolutionFormReport(queryString string) (irfs []IssueResolutionFor
 	left join uda as udf9 on csa.udf9 = udf9.id
 	left join uda as udf10 on csa.udf10 = udf10.id
 	where (b.dr=0 and b.isissue = 1)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 	// Check
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, args...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetIssueResolutionFormReport db.QueryRow(checkSql) failed", zap.Error(err))
@@ -775,14 +930,14 @@ func GetIssueResolutionFormReport(queryString string) (irfs []IssueResolutionFor
 	left join uda as udf9 on csa.udf9 = udf9.id
 	left join uda as udf10 on csa.udf10 = udf10.id
 	where (b.dr=0 and b.isissue = 1)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	repSql := build.String()
 	// Retrieve IRF data from database
-	irfRep, err := db.Query(repSql)
+	irfRep, err := db.Query(repSql, args...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetIssueResolutionFormReport db.Query failed", zap.Error(err))
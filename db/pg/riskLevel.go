@@ -13,17 +13,22 @@ import (
 
 // Risk Level struct
 type RiskLevel struct {
-	ID          int32     `db:"id" json:"id"`
-	Name        string    `db:"name" json:"name"`
-	Description string    `db:"description" json:"description"`
-	Color       string    `db:"color" json:"color"`
-	Status      int16     `db:"status" json:"status"`
-	CreateDate  time.Time `db:"createtime" json:"createDate"`
-	Creator     Person    `db:"creatorid" json:"creator"`
-	Modifier    Person    `db:"modifierid" json:"modifier"`
-	ModifyDate  time.Time `db:"modifytime" json:"modifyDate"`
-	Ts          time.Time `db:"ts" json:"ts"`
-	Dr          int16     `db:"dr" json:"dr"`
+	ID          int32  `db:"id" json:"id"`
+	Name        string `db:"name" json:"name"`
+	Description string `db:"description" json:"description"`
+	Color       string `db:"color" json:"color"`
+	// Hours overdue before escalating to the issue owner, the CSA responsible person and the department head.
+	// When absent, a new Risk Level takes the defaults and a modified one keeps its hours.
+	OwnerHours *int32    `db:"ownerhours" json:"ownerHours"`
+	RespHours  *int32    `db:"resphours" json:"respHours"`
+	HeadHours  *int32    `db:"headhours" json:"headHours"`
+	Status     int16     `db:"status" json:"status"`
+	CreateDate time.Time `db:"createtime" json:"createDate"`
+	Creator    Person    `db:"creatorid" json:"creator"`
+	Modifier   Person    `db:"modifierid" json:"modifier"`
+	ModifyDate time.Time `db:"modifytime" json:"modifyDate"`
+	Ts         time.Time `db:"ts" json:"ts"`
+	Dr         int16     `db:"dr" json:"dr"`
 }
 
 // Risk Level front-end cache struct
@@ -70,8 +75,8 @@ func GetRLList() (rls []RiskLevel, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	rls = make([]RiskLevel, 0)
 	// Retrieve Risk Level list from risklevel table
-	sqlStr := `select id,name,description,color,status,
-			createtime,creatorid,modifytime,modifierid,ts,
+	sqlStr := `select id,name,description,color,ownerhours,
+			resphours,headhours,status,createtime,creatorid,modifytime,modifierid,ts,
 			dr 
 			from risklevel 
 			where dr=0 order by ts desc`
@@ -85,8 +90,8 @@ func GetRLList() (rls []RiskLevel, resStatus i18n.ResKey, err error) {
 
 	for rows.Next() {
 		var rl RiskLevel
-		err = rows.Scan(&rl.ID, &rl.Name, &rl.Description, &rl.Color, &rl.Status,
-			&rl.CreateDate, &rl.Creator.ID, &rl.ModifyDate, &rl.Modifier.ID, &rl.Ts,
+		err = rows.Scan(&rl.ID, &rl.Name, &rl.Description, &rl.Color, &rl.OwnerHours,
+			&rl.RespHours, &rl.HeadHours, &rl.Status, &rl.CreateDate, &rl.Creator.ID, &rl.ModifyDate, &rl.Modifier.ID, &rl.Ts,
 			&rl.Dr)
 		if err != nil {
 			zap.L().Error("GetRLList rows.Scan failed", zap.Error(err))
@@ -135,8 +140,8 @@ func (rlc *RLCache) GetRLsCache() (resStatus i18n.ResKey, err error) {
 	}
 
 	// Retrieve all data greator than the QueryTs
-	sqlStr = `select a.id,a.name,a.description,a.color,a.status,
-		a.createtime,a.creatorid,a.modifytime,a.modifierid,a.ts,
+	sqlStr = `select a.id,a.name,a.description,a.color,a.ownerhours,
+		a.resphours,a.headhours,a.status,a.createtime,a.creatorid,a.modifytime,a.modifierid,a.ts,
 		a.dr
 		from risklevel a
 		where a.ts > $1 order by a.ts desc`
@@ -151,8 +156,8 @@ func (rlc *RLCache) GetRLsCache() (resStatus i18n.ResKey, err error) {
 	// Extract data from result set
 	for rows.Next() {
 		var rl RiskLevel
-		err = rows.Scan(&rl.ID, &rl.Name, &rl.Description, &rl.Color, &rl.Status,
-			&rl.CreateDate, &rl.Creator.ID, &rl.ModifyDate, &rl.Modifier.ID, &rl.Ts,
+		err = rows.Scan(&rl.ID, &rl.Name, &rl.Description, &rl.Color, &rl.OwnerHours,
+			&rl.RespHours, &rl.HeadHours, &rl.Status, &rl.CreateDate, &rl.Creator.ID, &rl.ModifyDate, &rl.Modifier.ID, &rl.Ts,
 			&rl.Dr)
 		if err != nil {
 			zap.L().Error("RLCache.GetRLCsCache rows.next failed", zap.Error(err))
@@ -193,25 +198,56 @@ func (rlc *RLCache) GetRLsCache() (resStatus i18n.ResKey, err error) {
 	return
 }
 
+// Check that the escalation hours aren't negative and increase level by level
+func (rl *RiskLevel) checkHours() (resStatus i18n.ResKey) {
+	resStatus = i18n.StatusOK
+	if *rl.OwnerHours < 0 || *rl.RespHours <= *rl.OwnerHours || *rl.HeadHours <= *rl.RespHours {
+		resStatus = i18n.StatusRLHoursInvalid
+	}
+	return
+}
+
 // Add Risk Level
-func (rl *RiskLevel) Add() (resStatus i18n.ResKey, err error) {
+func (rl *RiskLevel) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the Risk Level name exists
 	resStatus, err = rl.CheckNameExist()
 	if resStatus != i18n.StatusOK || err != nil {
 		return
 	}
-	// Add data to the risklevel table
-	sqlStr := `insert into risklevel(name,description,color,status,creatorid) 
-	values($1,$2,$3,$4,$5) 
-	returning id`
-	err = db.QueryRow(sqlStr, rl.Name, rl.Description, rl.Color, rl.Status, rl.Creator.ID).Scan(&rl.ID)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("RiskLevel.Add  stmt.QueryRow failed", zap.Error(err))
+	// Absent escalation hours take the defaults of the risklevel table
+	if rl.OwnerHours == nil {
+		ownerHours := int32(defaultOwnerHours)
+		rl.OwnerHours = &ownerHours
+	}
+	if rl.RespHours == nil {
+		respHours := int32(defaultRespHours)
+		rl.RespHours = &respHours
+	}
+	if rl.HeadHours == nil {
+		headHours := int32(defaultHeadHours)
+		rl.HeadHours = &headHours
+	}
+	resStatus = rl.checkHours()
+	if resStatus != i18n.StatusOK {
 		return
 	}
-	return
+	// Add data to the risklevel table
+	return writeAudited(actor, pub.RL, AuditActionAdd, &rl.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `insert into risklevel(name,description,color,ownerhours,resphours,
+		headhours,status,creatorid) 
+		values($1,$2,$3,$4,$5,$6,$7,$8) 
+		returning id`
+		err = tx.QueryRow(sqlStr, rl.Name, rl.Description, rl.Color, rl.OwnerHours, rl.RespHours,
+			rl.HeadHours, rl.Status, rl.Creator.ID).Scan(&rl.ID)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("RiskLevel.Add  stmt.QueryRow failed", zap.Error(err))
+			return
+		}
+		return
+	})
 }
 
 // Check if the Risk Level name exist
@@ -242,13 +278,13 @@ func (rl *RiskLevel) GetRLInfoByID() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// If the Risk Level not in cache, then retrieve it from database
-	sqlStr := `select a.name,a.description,a.color,a.status,a.createtime,
-	a.creatorid,a.modifytime,a.modifierid,a.ts,a.dr
+	sqlStr := `select a.name,a.description,a.color,a.ownerhours,a.resphours,
+	a.headhours,a.status,a.createtime,a.creatorid,a.modifytime,a.modifierid,a.ts,a.dr
 	from risklevel a
 	where a.id = $1`
 
-	err = db.QueryRow(sqlStr, rl.ID).Scan(&rl.Name, &rl.Description, &rl.Color, &rl.Status, &rl.CreateDate,
-		&rl.Creator.ID, &rl.ModifyDate, &rl.Modifier.ID, &rl.Ts, &rl.Dr)
+	err = db.QueryRow(sqlStr, rl.ID).Scan(&rl.Name, &rl.Description, &rl.Color, &rl.OwnerHours, &rl.RespHours,
+		&rl.HeadHours, &rl.Status, &rl.CreateDate, &rl.Creator.ID, &rl.ModifyDate, &rl.Modifier.ID, &rl.Ts, &rl.Dr)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("RiskLevel.GetRLInfoByID  db.QueryRow failed", zap.Error(err))
@@ -276,7 +312,7 @@ func (rl *RiskLevel) GetRLInfoByID() (resStatus i18n.ResKey, err error) {
 }
 
 // Modify Risk Level
-func (rl *RiskLevel) Edit() (resStatus i18n.ResKey, err error) {
+func (rl *RiskLevel) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the Risk Level name exists
 	resStatus, err = rl.CheckNameExist()
@@ -284,39 +320,41 @@ func (rl *RiskLevel) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Modify record in the risklevel table
-	sqlStr := `update risklevel set 
-	name=$1,description=$2,color=$3,status=$4,modifierid=$5,
-	modifytime=current_timestamp,ts=current_timestamp 
-	where id=$6 and ts=$7 and dr=0`
-	res, err := db.Exec(sqlStr, rl.Name, rl.Description, rl.Color, rl.Status, rl.Modifier.ID,
-		rl.ID, rl.Ts)
-	if err != nil {
-		zap.L().Error("RiskLevel.Edit db.exec failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// Check the number of rows affected by the SQL update operation
-	affected, err := res.RowsAffected()
-	if err != nil {
-		zap.L().Error("RiskLevel.Edit  get res.RowsAffected failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// If the number of rows effected by the update operation is less than one,
-	// it means someone else has already updated the data
-	if affected < 1 {
-		zap.L().Info("RiskLevel.Edit failed,Other user are Editing")
-		resStatus = i18n.StatusOtherEdit
-		return
-	}
-	// Delete from cache
-	rl.DelFromLocalCache()
+	return writeAudited(actor, pub.RL, AuditActionEdit, &rl.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		// Absent escalation hours keep their values, the resulting hours are checked
+		sqlStr := `update risklevel set 
+		name=$1,description=$2,color=$3,ownerhours=coalesce($4,ownerhours),resphours=coalesce($5,resphours),
+		headhours=coalesce($6,headhours),status=$7,modifierid=$8,modifytime=current_timestamp,ts=current_timestamp 
+		where id=$9 and ts=$10 and dr=0
+		returning ownerhours,resphours,headhours`
+		err = tx.QueryRow(sqlStr, rl.Name, rl.Description, rl.Color, rl.OwnerHours, rl.RespHours,
+			rl.HeadHours, rl.Status, rl.Modifier.ID, rl.ID, rl.Ts).Scan(&rl.OwnerHours, &rl.RespHours, &rl.HeadHours)
+		// If no row is updated, it means someone else has already updated the data
+		if err == sql.ErrNoRows {
+			zap.L().Info("RiskLevel.Edit failed,Other user are Editing")
+			resStatus = i18n.StatusOtherEdit
+			err = nil
+			return
+		}
+		if err != nil {
+			zap.L().Error("RiskLevel.Edit tx.QueryRow failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		resStatus = rl.checkHours()
+		if resStatus != i18n.StatusOK {
+			return
+		}
+		// Delete from cache
+		rl.DelFromLocalCache()
 
-	return
+		return
+	})
 }
 
 // Delete Risk Level
-func (rl *RiskLevel) Delete() (resStatus i18n.ResKey, err error) {
+func (rl *RiskLevel) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the Risk Level id refrenced
 	resStatus, err = rl.CheckUsed()
@@ -324,35 +362,38 @@ func (rl *RiskLevel) Delete() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the delete flag for this record in risklevel table
-	sqlStr := `update risklevel set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp where id=$2 and dr=0 and ts=$3`
-	res, err := db.Exec(sqlStr, rl.Modifier.ID, rl.ID, rl.Ts)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("RiskLevel.Delete db.exec failed", zap.Error(err))
-		return
-	}
+	return writeAudited(actor, pub.RL, AuditActionDelete, &rl.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update risklevel set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp where id=$2 and dr=0 and ts=$3`
+		res, err := tx.Exec(sqlStr, rl.Modifier.ID, rl.ID, rl.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("RiskLevel.Delete db.exec failed", zap.Error(err))
+			return
+		}
 
-	// Check the number of rows affected by SQL update operation
-	affected, err := res.RowsAffected()
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("RiskLevel.Delete res.RowsAffected failed", zap.Error(err))
-		return
-	}
-	// if the number of rows affected by SQL update operation is less than one,
-	// it means that someone else already updated the data.
-	if affected < 1 {
-		resStatus = i18n.StatusOtherEdit
-		return
-	}
-	// Delete from cache
-	rl.DelFromLocalCache()
+		// Check the number of rows affected by SQL update operation
+		affected, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("RiskLevel.Delete res.RowsAffected failed", zap.Error(err))
+			return
+		}
+		// if the number of rows affected by SQL update operation is less than one,
+		// it means that someone else already updated the data.
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		// Delete from cache
+		rl.DelFromLocalCache()
 
-	return
+		return
+	})
 }
 
 // Batch delete Risk Level
-func DeleteRLs(rls *[]RiskLevel, modifyUserId int32) (resStatus i18n.ResKey, err error) {
+func DeleteRLs(rls *[]RiskLevel, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Begin a database transaction
 	tx, err := db.Begin()
@@ -362,6 +403,17 @@ func DeleteRLs(rls *[]RiskLevel, modifyUserId int32) (resStatus i18n.ResKey, err
 		return
 	}
 	defer tx.Commit()
+	// Lock the Risk Levels and take their state for the audit trail
+	ids := make([]int32, 0, len(*rls))
+	for _, item := range *rls {
+		ids = append(ids, item.ID)
+	}
+	at, err := beginAudit(tx, actor, pub.RL, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Prepare a SQL statement for execution
 	delSqlStr := "update risklevel set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp where id=$2 and dr=0 and ts=$3"
 	stmt, err := tx.Prepare(delSqlStr)
@@ -381,7 +433,7 @@ func DeleteRLs(rls *[]RiskLevel, modifyUserId int32) (resStatus i18n.ResKey, err
 			return
 		}
 		// Execute the update operation
-		result, err1 := stmt.Exec(modifyUserId, rl.ID, rl.Ts)
+		result, err1 := stmt.Exec(actor.UserID, rl.ID, rl.Ts)
 		if err1 != nil {
 			zap.L().Error("DeleteRLs stmt.exec failed", zap.Error(err))
 			_ = tx.Rollback()
@@ -404,6 +456,12 @@ func DeleteRLs(rls *[]RiskLevel, modifyUserId int32) (resStatus i18n.ResKey, err
 		// Delete from cache
 		rl.DelFromLocalCache()
 	}
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
@@ -3,7 +3,9 @@ package pg
 import (
 	"database/sql"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pub"
 	"strconv"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -16,6 +18,8 @@ type Role struct {
 	Description string    `db:"description" json:"description" `
 	SystemFlag  int16     `db:"systemflag" json:"systemFlag" `
 	AllUserFlag int16     `db:"alluserflag" json:"allUserFlag"`
+	DataScope   int16     `db:"datascope" json:"dataScope"`
+	RequireTOTP int16     `db:"requiretotp" json:"requireTotp"`
 	Status      int16     `db:"status" json:"status"`
 	Member      []Person  `json:"member"`
 	CreateDate  time.Time `db:"createtime" json:"createDate"`
@@ -153,7 +157,8 @@ func GetRoles() (roles []Role, resStatus i18n.ResKey, err error) {
 	roles = make([]Role, 0)
 	// Retrieve from sysrole table
 	sqlStr := `select a.id, a.name,a.description,a.systemflag,a.alluserflag,
-	a.createtime,a.creatorid,a.modifytime,a.modifierid,a.dr,a.ts 
+	a.datascope,a.requiretotp,a.createtime,a.creatorid,a.modifytime,a.modifierid,
+	a.dr,a.ts 
 	from sysrole a
 	where a.dr = 0
 	order by a.name`
@@ -171,7 +176,8 @@ func GetRoles() (roles []Role, resStatus i18n.ResKey, err error) {
 	for rows.Next() {
 		var role Role
 		err = rows.Scan(&role.ID, &role.Name, &role.Description, &role.SystemFlag, &role.AllUserFlag,
-			&role.CreateDate, &role.Creator.ID, &role.ModifyDate, &role.Modifier.ID, &role.Dr, &role.Ts)
+			&role.DataScope, &role.RequireTOTP, &role.CreateDate, &role.Creator.ID, &role.ModifyDate, &role.Modifier.ID,
+			&role.Dr, &role.Ts)
 		if err != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("GetRoles rows.next failed", zap.Error(err))
@@ -270,13 +276,18 @@ func (r *Role) CheckNameExist() (resStatus i18n.ResKey, err error) {
 }
 
 // Add Role
-func (role *Role) Add() (resStatus i18n.ResKey, err error) {
+func (role *Role) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the role name exists
 	resStatus, err = role.CheckNameExist()
 	if resStatus != i18n.StatusOK || err != nil {
 		return
 	}
+	// Check the data scope
+	if !isDataScope(role.DataScope) {
+		resStatus = i18n.StatusRoleDataScopeInvalid
+		return
+	}
 	// Begin a database transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -284,11 +295,17 @@ func (role *Role) Add() (resStatus i18n.ResKey, err error) {
 		zap.L().Error("Role.Add tx.Begin failed", zap.Error(err))
 		return
 	}
-	defer tx.Commit()
+	// Start the audit trail
+	at, err := beginAudit(tx, actor, pub.Role, AuditActionAdd)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Insert a Role record into the database
-	sqlStr := "insert into sysrole(name,description,creatorid) values($1,$2,$3) returning id"
-	err = tx.QueryRow(sqlStr, role.Name, role.Description, role.Creator.ID).Scan(&role.ID)
+	sqlStr := "insert into sysrole(name,description,datascope,requiretotp,creatorid) values($1,$2,$3,$4,$5) returning id"
+	err = tx.QueryRow(sqlStr, role.Name, role.Description, role.DataScope, role.RequireTOTP, role.Creator.ID).Scan(&role.ID)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("Role.Add tx.QueryRow failed", zap.Error(err))
@@ -318,18 +335,39 @@ func (role *Role) Add() (resStatus i18n.ResKey, err error) {
 			}
 		}
 	}
+	// Write the audit trail
+	err = at.write(role.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("Role.Add tx.Commit failed", zap.Error(err))
+		return
+	}
+	// Clear the cached permissions of the members
+	for _, item := range role.Member {
+		clearUserPermissions(item.ID)
+	}
 	return
 }
 
 // Edit Role
-func (role *Role) Edit() (resStatus i18n.ResKey, err error) {
+func (role *Role) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the Role name exists.
 	resStatus, err = role.CheckNameExist()
 	if resStatus != i18n.StatusOK || err != nil {
 		return
 	}
-
+	// Check the data scope
+	if !isDataScope(role.DataScope) {
+		resStatus = i18n.StatusRoleDataScopeInvalid
+		return
+	}
 	// Begin a database transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -337,12 +375,18 @@ func (role *Role) Edit() (resStatus i18n.ResKey, err error) {
 		zap.L().Error("Role.Edit db.Begin failed", zap.Error(err))
 		return
 	}
-	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.Role, AuditActionEdit, role.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Update database record.
-	sqlStr := `update sysrole set name=$1,description=$2,modifierid=$3,modifytime=current_timestamp,ts=current_timestamp
-	where id=$4 and ts=$5 and dr=0`
-	res, err := tx.Exec(sqlStr, role.Name, role.Description, role.Modifier.ID, role.ID, role.Ts)
+	sqlStr := `update sysrole set name=$1,description=$2,datascope=$3,requiretotp=$4,modifierid=$5,modifytime=current_timestamp,ts=current_timestamp
+	where id=$6 and ts=$7 and dr=0`
+	res, err := tx.Exec(sqlStr, role.Name, role.Description, role.DataScope, role.RequireTOTP, role.Modifier.ID, role.ID, role.Ts)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("Role.Edit tx.Exec failed", zap.Error(err))
@@ -362,21 +406,34 @@ func (role *Role) Edit() (resStatus i18n.ResKey, err error) {
 	if affected < 1 {
 		resStatus = i18n.StatusOtherEdit
 		zap.L().Info("DeleteRoles other edit")
+		tx.Rollback()
 		return
 	}
 
-	// Query the number of records in the sysuserrole table.
-	var recordNumber int32
-	findSqlStr := `select count(id) from sysuserrole where roleid = $1`
-	err = tx.QueryRow(findSqlStr, role.ID).Scan(&recordNumber)
+	// Query the previous members, their cached permissions are cleared after the commit.
+	var memberIDs []int32
+	findSqlStr := `select userid from sysuserrole where roleid = $1`
+	rows, err := tx.Query(findSqlStr, role.ID)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
-		zap.L().Error("Role.Edit tx.QueryRow(findSqlStr) failed", zap.Error(err))
+		zap.L().Error("Role.Edit tx.Query(findSqlStr) failed", zap.Error(err))
 		tx.Rollback()
 		return
 	}
+	for rows.Next() {
+		var userID int32
+		if err = rows.Scan(&userID); err != nil {
+			rows.Close()
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("Role.Edit rows.Scan failed", zap.Error(err))
+			tx.Rollback()
+			return
+		}
+		memberIDs = append(memberIDs, userID)
+	}
+	rows.Close()
 	// Delete related records from the sysuserrole table.
-	if recordNumber > 0 {
+	if len(memberIDs) > 0 {
 		delSqlStr := "delete from sysuserrole where roleid = $1"
 		_, err := tx.Exec(delSqlStr, role.ID)
 		if err != nil {
@@ -407,13 +464,31 @@ func (role *Role) Edit() (resStatus i18n.ResKey, err error) {
 				tx.Rollback()
 				return resStatus, err3
 			}
+			memberIDs = append(memberIDs, item.ID)
 		}
 	}
+	// Write the audit trail
+	err = at.write(role.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("Role.Edit tx.Commit failed", zap.Error(err))
+		return
+	}
+	// Clear the cached permissions of the previous and new members,
+	// the data scope of the API keys with the role may change too
+	clearUserPermissions(memberIDs...)
+	clearRoleAPIKeyPermissions(role.ID)
 	return
 }
 
 // Delete Role
-func (role *Role) Delete() (resStatus i18n.ResKey, err error) {
+func (role *Role) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the role id referenced.
 	resStatus, err = role.CheckIsUsed()
@@ -421,26 +496,29 @@ func (role *Role) Delete() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the Role record in the database.
-	sqlStr := "update sysrole set dr=1,ts=current_timestamp,modifytime=current_timestamp,modifierid=$1 where id = $2 and ts=$3 and dr=0"
-	res, err := db.Exec(sqlStr, role.Modifier.ID, role.ID, role.Ts)
-	if err != nil {
-		zap.L().Error("DeleteRole exec delete role failed", zap.Error(err))
-		return i18n.StatusResCodeError, err
-	}
-	// Check the number of rows affected by the SQL update operation.
-	errected, err := res.RowsAffected()
-	if err != nil {
-		zap.L().Error("DeleteRoles get RowsAffected failed", zap.Error(err))
-		return i18n.StatusResCodeError, err
-	}
-	// If the update operation affects fewer than one row,
-	// it indicates that another user has already updated that row.
-	if errected < 1 {
-		zap.L().Info("DeleteRoles other edit")
-		return i18n.StatusOtherEdit, nil
-	}
+	return writeAudited(actor, pub.Role, AuditActionDelete, &role.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := "update sysrole set dr=1,ts=current_timestamp,modifytime=current_timestamp,modifierid=$1 where id = $2 and ts=$3 and dr=0"
+		res, err := tx.Exec(sqlStr, role.Modifier.ID, role.ID, role.Ts)
+		if err != nil {
+			zap.L().Error("DeleteRole exec delete role failed", zap.Error(err))
+			return i18n.StatusResCodeError, err
+		}
+		// Check the number of rows affected by the SQL update operation.
+		errected, err := res.RowsAffected()
+		if err != nil {
+			zap.L().Error("DeleteRoles get RowsAffected failed", zap.Error(err))
+			return i18n.StatusResCodeError, err
+		}
+		// If the update operation affects fewer than one row,
+		// it indicates that another user has already updated that row.
+		if errected < 1 {
+			zap.L().Info("DeleteRoles other edit")
+			return i18n.StatusOtherEdit, nil
+		}
 
-	return
+		return
+	})
 }
 
 // Check if the role is refrenced
@@ -453,6 +531,16 @@ func (role *Role) CheckIsUsed() (resStatus i18n.ResKey, err error) {
 			SqlStr:         "select count(id) from sysuserrole where roleid=$1",
 			UsedReturnCode: i18n.StatusRoleUserExist,
 		},
+		{
+			Description:    "API Key and Role Mapping",
+			SqlStr:         "select count(id) from sysapikeyrole where roleid=$1 and dr=0",
+			UsedReturnCode: i18n.StatusRoleAPIKeyExist,
+		},
+		{
+			Description:    "Approval flow step approver",
+			SqlStr:         "select count(id) from sysapprovalstep where approvertype=1 and approverid=$1 and dr=0",
+			UsedReturnCode: i18n.StatusApprovalFlowUsed,
+		},
 		{
 			Description:    "Role and Menu Mapping",
 			SqlStr:         `select count(id) from sysrolemenu where roleid = $1`,
@@ -483,10 +571,9 @@ func (role *Role) GetRoleMenus() (menus SystemMenus, resStatus i18n.ResKey, err
 	resStatus = i18n.StatusOK
 
 	sqlStr := `select b.id,b.fatherid,b.title,b.path,b.icon,
-	b.component,a.selected,a.indeterminate 
+	b.component,a.selected,a.indeterminate,coalesce(a.actions,'') 
 	from sysrolemenu a left join sysmenu b on (a.menuid = b.id) 
 	where a.roleid=$1`
-	var menu SystemMenu
 	rows, err := db.Query(sqlStr, role.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -499,13 +586,19 @@ func (role *Role) GetRoleMenus() (menus SystemMenus, resStatus i18n.ResKey, err
 	}
 
 	for rows.Next() {
+		var menu SystemMenu
+		var actions string
 		err = rows.Scan(&menu.ID, &menu.FatherID, &menu.Title, &menu.Path, &menu.Icon,
-			&menu.Component, &menu.Selected, &menu.Indeterminate)
+			&menu.Component, &menu.Selected, &menu.Indeterminate, &actions)
 		if err != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("GetRoleMenus rows.Next() failed", zap.Error(err))
 			return
 		}
+		menu.Actions = make([]string, 0)
+		if actions != "" {
+			menu.Actions = strings.Split(actions, ",")
+		}
 		menus = append(menus, menu)
 	}
 	resStatus = i18n.StatusOK
@@ -513,7 +606,7 @@ func (role *Role) GetRoleMenus() (menus SystemMenus, resStatus i18n.ResKey, err
 }
 
 // Batch delete roles.
-func DeleteRoles(roles *[]Role, modifyUserId int32) (resStatus i18n.ResKey, err error) {
+func DeleteRoles(roles *[]Role, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Begin a database transaction
 	tx, err := db.Begin()
@@ -522,6 +615,17 @@ func DeleteRoles(roles *[]Role, modifyUserId int32) (resStatus i18n.ResKey, err
 		return i18n.StatusResCodeError, err
 	}
 	defer tx.Commit()
+	// Lock the roles and take their state for the audit trail
+	ids := make([]int32, 0, len(*roles))
+	for _, item := range *roles {
+		ids = append(ids, item.ID)
+	}
+	at, err := beginAudit(tx, actor, pub.Role, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Delete role pre-processing
 	uSqlStr := `update sysrole set dr=1,ts=current_timestamp,modifytime=current_timestamp,modifierid=$1 
@@ -542,7 +646,7 @@ func DeleteRoles(roles *[]Role, modifyUserId int32) (resStatus i18n.ResKey, err
 			return
 		}
 		// Execute deletion operation.
-		res, err1 := stmt.Exec(modifyUserId, role.ID, role.Ts)
+		res, err1 := stmt.Exec(actor.UserID, role.ID, role.Ts)
 		if err1 != nil {
 			zap.L().Error("DeleteRoles stmt.Exec failed:", zap.Error(err1))
 			_ = tx.Rollback()
@@ -564,6 +668,12 @@ func DeleteRoles(roles *[]Role, modifyUserId int32) (resStatus i18n.ResKey, err
 		}
 	}
 
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
@@ -577,7 +687,6 @@ func (roleMenu *ParamsRoleMenu) RoleMenuUpdate() (resStatus i18n.ResKey, err err
 		zap.L().Error("RoleMenuUpdate db.Begin failed", zap.Error(err))
 		return
 	}
-	defer tx.Commit()
 
 	// Udpate sysrole table pre-processing
 	uSqlStr := `update sysrole set ts=current_timestamp,modifytime=current_timestamp,modifierid=$1 
@@ -606,7 +715,6 @@ func (roleMenu *ParamsRoleMenu) RoleMenuUpdate() (resStatus i18n.ResKey, err err
 		_ = tx.Rollback()
 		return
 	}
-
 	// Delete existing permission records from the table.
 	delSql := "delete from sysrolemenu where roleid=$1"
 	_, err = tx.Exec(delSql, roleMenu.Role.ID)
@@ -616,12 +724,8 @@ func (roleMenu *ParamsRoleMenu) RoleMenuUpdate() (resStatus i18n.ResKey, err err
 		_ = tx.Rollback()
 		return
 	}
-	// If the permissions list is empty, return immediately
-	if len(roleMenu.Auths) == 0 {
-		return
-	}
 	// Per-processing for inserting data into the sysrolemenu table
-	insertSql := "insert into sysrolemenu(roleid,menuid,selected,indeterminate,ts) values($1,$2,$3,$4,now())"
+	insertSql := "insert into sysrolemenu(roleid,menuid,selected,indeterminate,actions,ts) values($1,$2,$3,$4,$5,now())"
 	insertStmt, err := tx.Prepare(insertSql)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
@@ -632,7 +736,15 @@ func (roleMenu *ParamsRoleMenu) RoleMenuUpdate() (resStatus i18n.ResKey, err err
 	defer insertStmt.Close()
 	// Insert data row by row.
 	for _, item := range roleMenu.Auths {
-		_, err = insertStmt.Exec(roleMenu.Role.ID, item.ID, item.Selected, item.Indeterminate)
+		// Check the granted actions
+		for _, action := range item.Actions {
+			if !isPermissionAction(action) {
+				resStatus = i18n.StatusPermissionActionInvalid
+				_ = tx.Rollback()
+				return
+			}
+		}
+		_, err = insertStmt.Exec(roleMenu.Role.ID, item.ID, item.Selected, item.Indeterminate, strings.Join(item.Actions, ","))
 		if err != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("RoleMenuUpdate insertStmt.Exec MenuID="+strconv.FormatInt(int64(item.ID), 10)+"failed:", zap.Error(err))
@@ -640,5 +752,13 @@ func (roleMenu *ParamsRoleMenu) RoleMenuUpdate() (resStatus i18n.ResKey, err err
 			return
 		}
 	}
+	err = tx.Commit()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("RoleMenuUpdate tx.Commit failed", zap.Error(err))
+		return
+	}
+	// Clear the cached permissions of the role members
+	clearRolePermissions(roleMenu.Role.ID)
 	return
 }
@@ -1,35 +1,204 @@
 package pg
 
 import (
+	"os"
 	"sccsmsserver/pkg/security"
+	"sccsmsserver/setting"
+	"time"
 
 	"go.uber.org/zap"
 )
 
-// Get the RSA keys and Initialize RSA
-func initRsa() (isFinish bool, err error) {
-	var sqlStr string
+// Rotation of the login RSA keys kept in the database
+const (
+	defaultRsaRotateDays   = 30
+	defaultRsaOverlapHours = 24
+	// How often each server reloads the keys and checks whether they are due for rotation
+	rsaKeyRefreshInterval = time.Minute
+	// Advisory lock taken while rotating, only one server rotates at a time
+	rsaRotateLockID = 20160
+)
+
+// Initialize the sysrsakey table with the key generated by earlier versions
+func initSysRsaKey() (isFinish bool, err error) {
 	isFinish = true
-	// Step 1: Get the public key and private key from the sysinfo table
-	sqlStr = "select publickey,privatekey from sysinfo"
-	var publickey, privatekey string
-	err = db.QueryRow(sqlStr).Scan(&publickey, &privatekey)
+	sqlStr := `insert into sysrsakey(kid,publickey,privatekey,activetime)
+	select 'initial',publickey,privatekey,coalesce(starttime,current_timestamp)
+	from sysinfo where coalesce(privatekey,'') <> '' limit 1`
+	_, err = db.Exec(sqlStr)
 	if err != nil {
 		isFinish = false
-		zap.L().Error("initRsa db.QueryRow failed:", zap.Error(err))
+		zap.L().Error("initSysRsaKey db.Exec failed", zap.Error(err))
+	}
+	return
+}
+
+// Get the RSA keys and Initialize RSA.
+// Keys configured as PEM files are used as they are,
+// otherwise the keys are kept in the database and rotated with overlapping validity.
+func initRsa() (isFinish bool, err error) {
+	isFinish = true
+	cfg := setting.Conf.RSAConfig
+	if cfg != nil && len(cfg.Keys) > 0 {
+		err = loadRsaKeysFromFiles(cfg)
+		if err != nil {
+			isFinish = false
+			zap.L().Error("initRsa loadRsaKeysFromFiles failed", zap.Error(err))
+		}
 		return
 	}
-	if publickey == "" {
+	err = refreshRsaKeys()
+	if err != nil {
 		isFinish = false
-		zap.L().Error("initRsa public key is null.")
 		return
 	}
-	if privatekey == "" {
-		isFinish = false
-		zap.L().Error("initRsa private key is null.")
+	go func() {
+		for range time.Tick(rsaKeyRefreshInterval) {
+			_ = refreshRsaKeys()
+		}
+	}()
+	return
+}
+
+// Load the RSA keys from the PEM files of the configuration
+func loadRsaKeysFromFiles(cfg *setting.RSAConfig) (err error) {
+	keys := make([]*security.Rsa, 0, len(cfg.Keys))
+	for _, kc := range cfg.Keys {
+		pemBytes, err := os.ReadFile(kc.PrivateKeyFile)
+		if err != nil {
+			return err
+		}
+		k, err := security.NewRsa(kc.ID, string(pemBytes))
+		if err != nil {
+			return err
+		}
+		keys = append(keys, k)
+	}
+	activeKeyID := cfg.ActiveKeyID
+	if activeKeyID == "" && len(cfg.Keys) == 1 {
+		activeKeyID = cfg.Keys[0].ID
+	}
+	return security.SetRsaKeys(keys, activeKeyID)
+}
+
+// Rotate the database keys when due and reload them
+func refreshRsaKeys() (err error) {
+	err = rotateRsaKeys()
+	if err != nil {
+		return
+	}
+	return loadRsaKeys()
+}
+
+// Get the rotation period and the overlap of the database keys
+func rsaRotation() (rotate time.Duration, overlap time.Duration) {
+	rotateDays, overlapHours := defaultRsaRotateDays, defaultRsaOverlapHours
+	if cfg := setting.Conf.RSAConfig; cfg != nil {
+		if cfg.RotateDays > 0 {
+			rotateDays = cfg.RotateDays
+		}
+		if cfg.OverlapHours > 0 {
+			overlapHours = cfg.OverlapHours
+		}
+	}
+	return time.Duration(rotateDays) * 24 * time.Hour, time.Duration(overlapHours) * time.Hour
+}
+
+// Generate a new database key when the newest key is older than the rotation period.
+// The new key is published ahead of its activation so that all servers have loaded it,
+// the previous keys are still accepted for the overlap after that.
+func rotateRsaKeys() (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		zap.L().Error("rotateRsaKeys db.Begin failed", zap.Error(err))
 		return
 	}
-	// step 2: Initialize RSA
-	security.NewRsa(publickey, privatekey)
+	defer tx.Commit()
+	_, err = tx.Exec("select pg_advisory_xact_lock($1)", rsaRotateLockID)
+	if err != nil {
+		zap.L().Error("rotateRsaKeys pg_advisory_xact_lock failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	var keyNumber int32
+	var lastActiveTime time.Time
+	sqlStr := "select count(id),coalesce(max(activetime),to_timestamp(0)) from sysrsakey where dr=0"
+	err = tx.QueryRow(sqlStr).Scan(&keyNumber, &lastActiveTime)
+	if err != nil {
+		zap.L().Error("rotateRsaKeys tx.QueryRow failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	rotate, overlap := rsaRotation()
+	if keyNumber > 0 && time.Since(lastActiveTime) < rotate {
+		return
+	}
+	privateKey, publicKey, err := security.GenRsaKey(2048)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+	// The first key is active at once
+	activeTime := time.Now()
+	if keyNumber > 0 {
+		activeTime = activeTime.Add(2 * rsaKeyRefreshInterval)
+	}
+	keyID := activeTime.UTC().Format("20060102150405")
+	var id int32
+	sqlStr = `insert into sysrsakey(kid,publickey,privatekey,activetime) values($1,$2,$3,$4) returning id`
+	err = tx.QueryRow(sqlStr, keyID, publicKey, privateKey, activeTime).Scan(&id)
+	if err != nil {
+		zap.L().Error("rotateRsaKeys insert failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	sqlStr = `update sysrsakey set retiretime=$1,ts=current_timestamp
+	where id<>$2 and retiretime=to_timestamp(0) and dr=0`
+	_, err = tx.Exec(sqlStr, activeTime.Add(overlap), id)
+	if err != nil {
+		zap.L().Error("rotateRsaKeys update failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	zap.L().Info("RSA key rotated", zap.String("kid", keyID), zap.Time("activeTime", activeTime))
+	return
+}
+
+// Load the database keys that aren't retired.
+// The active key is the newest key whose activation time has passed.
+func loadRsaKeys() (err error) {
+	sqlStr := `select kid,privatekey,activetime from sysrsakey
+	where dr=0 and (retiretime=to_timestamp(0) or retiretime > current_timestamp)
+	order by activetime`
+	rows, err := db.Query(sqlStr)
+	if err != nil {
+		zap.L().Error("loadRsaKeys db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	keys := make([]*security.Rsa, 0)
+	var activeKeyID string
+	for rows.Next() {
+		var keyID, privateKey string
+		var activeTime time.Time
+		err = rows.Scan(&keyID, &privateKey, &activeTime)
+		if err != nil {
+			zap.L().Error("loadRsaKeys rows.Scan failed", zap.Error(err))
+			return
+		}
+		k, err := security.NewRsa(keyID, privateKey)
+		if err != nil {
+			zap.L().Error("loadRsaKeys security.NewRsa failed", zap.Error(err))
+			return err
+		}
+		keys = append(keys, k)
+		if !activeTime.After(time.Now()) || activeKeyID == "" {
+			activeKeyID = keyID
+		}
+	}
+	err = security.SetRsaKeys(keys, activeKeyID)
+	if err != nil {
+		zap.L().Error("loadRsaKeys security.SetRsaKeys failed", zap.Error(err))
+	}
 	return
 }
@@ -16,6 +16,7 @@ type SystemMenu struct {
 	Component      string      `json:"component"`
 	Selected       bool        `json:"selected"`
 	Indeterminate  bool        `json:"indeterminate"`
+	Actions        []string    `json:"actions"` // Granted actions of a role menu, empty means all actions
 	AddFromVersion string      `json:"addFromVersion"`
 }
 
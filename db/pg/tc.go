@@ -40,7 +40,7 @@ type TCCache struct {
 }
 
 // Add Traning Course
-func (tc *TC) Add() (resStatus i18n.ResKey, err error) {
+func (tc *TC) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the name already exists
 	resStatus, err = tc.CheckNameExist()
@@ -55,6 +55,13 @@ func (tc *TC) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Start the audit trail
+	at, err := beginAudit(tx, actor, pub.TC, AuditActionAdd)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Insert the main record to the TC table
 	headSql := `insert into tc(name,classhour,isexamine,description,status,creatorid)
@@ -89,11 +96,17 @@ func (tc *TC) Add() (resStatus i18n.ResKey, err error) {
 			return
 		}
 	}
+	// Write the audit trail
+	err = at.write(tc.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Edit Traning Course
-func (tc *TC) Edit() (resStatus i18n.ResKey, err error) {
+func (tc *TC) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the modifier and creator are the same person
 	if tc.Creator.ID != tc.Modifier.ID {
@@ -113,6 +126,13 @@ func (tc *TC) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.TC, AuditActionEdit, tc.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Update the main record in the TC table
 	editDocSql := `update tc set code=$1,name=$2,classhour=$3,isexamine=$4,description=$5,
 		status=$6,modifytime=current_timestamp,modifierid=$7,ts=current_timestamp
@@ -200,6 +220,12 @@ func (tc *TC) Edit() (resStatus i18n.ResKey, err error) {
 	}
 	// Delete form cache
 	tc.DelFromCache()
+	// Write the audit trail
+	err = at.write(tc.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
@@ -230,7 +256,7 @@ func (tc *TC) DelFromCache() {
 }
 
 // Delete Traning Course
-func (tc *TC) Delete(modifyUserId int32) (resStatus i18n.ResKey, err error) {
+func (tc *TC) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the training course is referenced
 	resStatus, err = tc.CheckIsUsed()
@@ -238,7 +264,7 @@ func (tc *TC) Delete(modifyUserId int32) (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Check if the modifier and creator are the same person
-	if tc.Creator.ID != modifyUserId {
+	if tc.Creator.ID != actor.UserID {
 		resStatus = i18n.StatusOtherEdit
 		return
 	}
@@ -250,10 +276,17 @@ func (tc *TC) Delete(modifyUserId int32) (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.TC, AuditActionDelete, tc.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Update the main record in the TC table to mark it as deleted
 	delDocSql := `update tc set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp
 		where id=$2 and dr=0 and ts=$3`
-	delDocRes, err := tx.Exec(delDocSql, modifyUserId, tc.ID, tc.Ts)
+	delDocRes, err := tx.Exec(delDocSql, actor.UserID, tc.ID, tc.Ts)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("TC.Delete tx.Exec(delDocSql) failed", zap.Error(err))
@@ -288,7 +321,7 @@ func (tc *TC) Delete(modifyUserId int32) (resStatus i18n.ResKey, err error) {
 	defer delFileStmt.Close()
 	// Process attachments one by one
 	for _, file := range tc.Files {
-		delFileRes, errDelFile := delFileStmt.Exec(modifyUserId, file.ID, file.BillHID, file.Ts)
+		delFileRes, errDelFile := delFileStmt.Exec(actor.UserID, file.ID, file.BillHID, file.Ts)
 		if errDelFile != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("TC.Delete delFileStmt.Exec failed", zap.Error(errDelFile))
@@ -310,11 +343,17 @@ func (tc *TC) Delete(modifyUserId int32) (resStatus i18n.ResKey, err error) {
 	}
 	// Delete from cache
 	tc.DelFromCache()
+	// Write the audit trail
+	err = at.write(tc.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Batch delete Traning Courses
-func DeleteTCs(tcs *[]TC, modifyUserID int32) (resStatus i18n.ResKey, err error) {
+func DeleteTCs(tcs *[]TC, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	if len(*tcs) == 0 {
 		return
@@ -327,6 +366,17 @@ func DeleteTCs(tcs *[]TC, modifyUserID int32) (resStatus i18n.ResKey, err error)
 		return
 	}
 	defer tx.Commit()
+	// Lock the courses and take their state for the audit trail
+	ids := make([]int32, 0, len(*tcs))
+	for _, item := range *tcs {
+		ids = append(ids, item.ID)
+	}
+	at, err := beginAudit(tx, actor, pub.TC, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Prepare to delete main records
 	delDocSql := `update tc set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp
 		where id=$2 and dr=0 and ts=$3`
@@ -358,13 +408,13 @@ func DeleteTCs(tcs *[]TC, modifyUserID int32) (resStatus i18n.ResKey, err error)
 			return
 		}
 		// Check if the modifier and creator are the same person
-		if tc.Creator.ID != modifyUserID {
+		if tc.Creator.ID != actor.UserID {
 			resStatus = i18n.StatusOtherEdit
 			tx.Rollback()
 			return
 		}
 		// Delete the main record
-		delDocRes, errDelDoc := docStmt.Exec(modifyUserID, tc.ID, tc.Ts)
+		delDocRes, errDelDoc := docStmt.Exec(actor.UserID, tc.ID, tc.Ts)
 		if errDelDoc != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("DeleteTCs docStmt.Exec failed", zap.Error(errDelDoc))
@@ -388,7 +438,7 @@ func DeleteTCs(tcs *[]TC, modifyUserID int32) (resStatus i18n.ResKey, err error)
 
 		// Delete attachments one by one
 		for _, file := range tc.Files {
-			delFileRes, errDelFile := fileStmt.Exec(modifyUserID, file.ID, file.BillHID, file.Ts)
+			delFileRes, errDelFile := fileStmt.Exec(actor.UserID, file.ID, file.BillHID, file.Ts)
 			if errDelFile != nil {
 				resStatus = i18n.StatusInternalError
 				zap.L().Error("DeleteTCs fileStmt.Exec failed", zap.Error(errDelFile))
@@ -411,6 +461,12 @@ func DeleteTCs(tcs *[]TC, modifyUserID int32) (resStatus i18n.ResKey, err error)
 		// Delete from cache
 		tc.DelFromCache()
 	}
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
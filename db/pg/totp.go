@@ -0,0 +1,670 @@
+package pg
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sccsmsserver/cache"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/totp"
+	"sccsmsserver/pub"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Number of recovery codes generated for a user
+const recoveryCodeNumber = 10
+
+// Maximum number of second factor attempts with one pre-auth token
+const preAuthMaxAttempts = 5
+
+// Steps a pre-auth token can be used for
+const (
+	preAuthStepTOTP      = "totp"
+	preAuthStepChangePwd = "changepwd"
+)
+
+// Login that passed the password check and waits for another step
+type preAuth struct {
+	Step              string    `json:"step"`
+	UserID            int32     `json:"userID"`
+	UserCode          string    `json:"userCode"`
+	ClientType        string    `json:"clientType"`
+	IssueRefreshToken bool      `json:"issueRefreshToken"`
+	ExpireTime        time.Time `json:"expireTime"`
+	Attempts          int32     `json:"attempts"`
+	// Password change required after the second factor, empty for none
+	PwdChange i18n.ResKey `json:"pwdChange,omitempty"`
+}
+
+// Pre-auth token request parameters
+type ParamPreAuth struct {
+	PreAuthToken string `json:"preAuthToken" binding:"required"`
+	ClientIP     string `json:"clientIp"`
+	ClientType   string `json:"clientType"`
+	UserAgent    string `json:"userAgent"`
+}
+
+// Second login step parameters, either Code or RecoveryCode is required
+type ParamTOTPLogin struct {
+	ParamPreAuth
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// TOTP code parameters
+type ParamTOTPCode struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TOTP secret to be imported into an authenticator app
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// Result of enabling TOTP.
+// Token fields are only filled when TOTP was enabled during login.
+type TOTPEnrollment struct {
+	TokenPair
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// Cache key of the pre-auth token
+func preAuthKey(token string) string {
+	return fmt.Sprintf("%s%s%s", pub.PreAuth, ":", hashRefreshToken(token))
+}
+
+// Create a pre-auth token
+func createPreAuth(pa preAuth) (token string, expireTime int64, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	token, err = genRefreshToken()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	pa.ExpireTime = time.Now().Add(pub.PreAuthExpireDuration)
+	resStatus, err = savePreAuth(token, pa)
+	expireTime = pa.ExpireTime.Unix()
+	return
+}
+
+// Write the pre-auth to cache
+func savePreAuth(token string, pa preAuth) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	v, _ := json.Marshal(pa)
+	err = cache.SetOther(preAuthKey(token), v)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("savePreAuth cache.SetOther failed", zap.Error(err))
+	}
+	return
+}
+
+// Get the pre-auth of the token.
+// Expired tokens, tokens of another step and tokens presented from another client type are invalid.
+func getPreAuth(p *ParamPreAuth, step string) (pa preAuth, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	exist, v, err := cache.GetOther(preAuthKey(p.PreAuthToken))
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("getPreAuth cache.GetOther failed", zap.Error(err))
+		return
+	}
+	if exist == 0 {
+		resStatus = i18n.StatusPreAuthInvalid
+		return
+	}
+	err = json.Unmarshal(v, &pa)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("getPreAuth json.Unmarshal failed", zap.Error(err))
+		return
+	}
+	if pa.Step != step {
+		resStatus = i18n.StatusPreAuthInvalid
+		return
+	}
+	if time.Now().After(pa.ExpireTime) || pa.ClientType != p.ClientType {
+		delPreAuth(p.PreAuthToken)
+		resStatus = i18n.StatusPreAuthInvalid
+	}
+	return
+}
+
+// Delete the pre-auth token
+func delPreAuth(token string) {
+	err := cache.DelOther(preAuthKey(token))
+	if err != nil {
+		zap.L().Error("delPreAuth cache.DelOther failed", zap.Error(err))
+	}
+}
+
+// Get the TOTP state of the user.
+// required means one of the user's roles enforces TOTP.
+func getUserTOTPState(userID int32) (enabled bool, required bool, err error) {
+	var totpEnabled, requireNumber int32
+	sqlStr := `select totpenabled,
+	(select count(r.id) from sysrole as r
+	where r.dr=0 and r.requiretotp=1 and r.id in (select roleid from sysuserrole where userid=$1))
+	from sysuser where id=$1`
+	err = db.QueryRow(sqlStr, userID).Scan(&totpEnabled, &requireNumber)
+	if err != nil {
+		zap.L().Error("getUserTOTPState db.QueryRow failed", zap.Error(err))
+		return
+	}
+	return totpEnabled == 1, requireNumber > 0, nil
+}
+
+// Issue the tokens after all the login steps passed
+func completeLogin(userID int32, userCode string, clientType string, clientIP string, userAgent string, issueRefreshToken bool) (tp TokenPair, resStatus i18n.ResKey, err error) {
+	// Generate the access token of a new session
+	sessionID := newTokenID()
+	tp.Token, tp.ExpireTime, resStatus, err = issueAccessToken(userID, userCode, sessionID, clientType, clientIP, userAgent)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	// Generate the refresh token
+	if issueRefreshToken {
+		var refreshExpireTime time.Time
+		tp.RefreshToken, refreshExpireTime, resStatus, err = startRefreshSession(userID, clientType, sessionID)
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+		tp.RefreshExpireTime = refreshExpireTime.Unix()
+	}
+	zap.L().Info("login Success", zap.String("user:", userCode))
+	return
+}
+
+// Second login step, verify the TOTP code or a recovery code
+func VerifyTOTPLogin(p *ParamTOTPLogin) (tp TokenPair, resStatus i18n.ResKey, err error) {
+	pa, resStatus, err := getPreAuth(&p.ParamPreAuth, preAuthStepTOTP)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	// Check if the user can still log in
+	var secret string
+	var enabled, status, locked int16
+	sqlStr := "select totpsecret,totpenabled,status,locked from sysuser where id=$1 and dr=0 and isoperator=1"
+	err = db.QueryRow(sqlStr, pa.UserID).Scan(&secret, &enabled, &status, &locked)
+	if err == sql.ErrNoRows {
+		delPreAuth(p.PreAuthToken)
+		resStatus = i18n.StatusUserNotExist
+		err = nil
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("VerifyTOTPLogin db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if status != 0 {
+		delPreAuth(p.PreAuthToken)
+		resStatus = i18n.StatusUserDisabled
+		return
+	}
+	if locked != 0 {
+		delPreAuth(p.PreAuthToken)
+		resStatus = i18n.StatusUserLocked
+		return
+	}
+	if enabled != 1 {
+		resStatus = i18n.StatusTOTPEnrollRequired
+		return
+	}
+	// Verify the second factor
+	var ok bool
+	if p.Code != "" {
+		ok, err = useTOTPCode(pa.UserID, secret, p.Code)
+	} else if p.RecoveryCode != "" {
+		ok, err = useRecoveryCode(pa.UserID, p.RecoveryCode)
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	if !ok {
+		resStatus = i18n.StatusTOTPInvalidCode
+		// Too many attempts invalidate the pre-auth token
+		pa.Attempts++
+		if pa.Attempts >= preAuthMaxAttempts {
+			delPreAuth(p.PreAuthToken)
+		} else {
+			savePreAuth(p.PreAuthToken, pa)
+		}
+		// Counts as an invalid password, so repeated failures lock the user
+		ulf := UserLoginFault{
+			UserID:    pa.UserID,
+			UserCode:  pa.UserCode,
+			ClientIp:  p.ClientIP,
+			UserAgent: p.UserAgent,
+			Type:      1,
+		}
+		ulf.process()
+		return
+	}
+	delPreAuth(p.PreAuthToken)
+	return finishLogin(pa, p.ClientIP, p.UserAgent)
+}
+
+// Check the TOTP code against the stored secret of the user.
+// Each time step can only be used once, a replayed code is rejected.
+func useTOTPCode(userID int32, stored string, code string) (ok bool, err error) {
+	secret, err := totp.Open(stored, userID)
+	if err != nil {
+		zap.L().Error("useTOTPCode totp.Open failed", zap.Int32("userID", userID), zap.Error(err))
+		return false, err
+	}
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return
+	}
+	res, err := db.Exec("update sysuser set totplaststep=$1 where id=$2 and totplaststep<$1", step, userID)
+	if err != nil {
+		zap.L().Error("useTOTPCode db.Exec failed", zap.Error(err))
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		zap.L().Error("useTOTPCode res.RowsAffected failed", zap.Error(err))
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Normalize a recovery code, users may type it without the dash or in upper case
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// Hash a recovery code, only the hash is stored
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Use a recovery code, each code can only be used once
+func useRecoveryCode(userID int32, code string) (ok bool, err error) {
+	sqlStr := `update sysrecoverycode set usedtime=current_timestamp
+	where userid=$1 and codehash=$2 and usedtime is null`
+	res, err := db.Exec(sqlStr, userID, hashRecoveryCode(code))
+	if err != nil {
+		zap.L().Error("useRecoveryCode db.Exec failed", zap.Error(err))
+		return
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		zap.L().Error("useRecoveryCode res.RowsAffected failed", zap.Error(err))
+		return
+	}
+	if affected > 0 {
+		zap.L().Info("Recovery code used", zap.Int32("userID", userID))
+	}
+	return affected > 0, nil
+}
+
+// Replace the recovery codes of the user
+func genRecoveryCodes(userID int32) (codes []string, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("genRecoveryCodes db.Begin failed", zap.Error(err))
+		return
+	}
+	codes, err = writeRecoveryCodes(tx, userID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("genRecoveryCodes tx.Commit failed", zap.Error(err))
+	}
+	return
+}
+
+// Replace the recovery codes of the user in the transaction
+func writeRecoveryCodes(tx *sql.Tx, userID int32) (codes []string, err error) {
+	codes = make([]string, 0, recoveryCodeNumber)
+	_, err = tx.Exec("delete from sysrecoverycode where userid=$1", userID)
+	if err != nil {
+		zap.L().Error("writeRecoveryCodes tx.Exec(delete) failed", zap.Error(err))
+		return
+	}
+	stmt, err := tx.Prepare("insert into sysrecoverycode(userid,codehash) values($1,$2)")
+	if err != nil {
+		zap.L().Error("writeRecoveryCodes tx.Prepare failed", zap.Error(err))
+		return
+	}
+	defer stmt.Close()
+	b := make([]byte, 5)
+	for i := 0; i < recoveryCodeNumber; i++ {
+		_, err = rand.Read(b)
+		if err != nil {
+			zap.L().Error("writeRecoveryCodes rand.Read failed", zap.Error(err))
+			return
+		}
+		// 8 base32 characters, formatted as xxxx-xxxx
+		c := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		code := c[:4] + "-" + c[4:]
+		_, err = stmt.Exec(userID, hashRecoveryCode(code))
+		if err != nil {
+			zap.L().Error("writeRecoveryCodes stmt.Exec failed", zap.Error(err))
+			return
+		}
+		codes = append(codes, code)
+	}
+	return
+}
+
+// Generate a new TOTP secret for the user.
+// The secret only takes effect after EnableTOTP confirms a code from the authenticator app.
+func SetupTOTP(userID int32) (setup TOTPSetup, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	var code string
+	var enabled int16
+	err = db.QueryRow("select code,totpenabled from sysuser where id=$1 and dr=0", userID).Scan(&code, &enabled)
+	if err == sql.ErrNoRows {
+		resStatus = i18n.StatusUserNotExist
+		err = nil
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("SetupTOTP db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if enabled == 1 {
+		resStatus = i18n.StatusTOTPAlreadyEnabled
+		return
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("SetupTOTP totp.GenerateSecret failed", zap.Error(err))
+		return
+	}
+	// Only the encrypted secret is stored
+	sealed, err := totp.Seal(secret, userID)
+	if err == totp.ErrNoKey {
+		resStatus = i18n.StatusTOTPUnavailable
+		err = nil
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("SetupTOTP totp.Seal failed", zap.Error(err))
+		return
+	}
+	_, err = db.Exec("update sysuser set totpsecret=$1,totplaststep=0 where id=$2", sealed, userID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("SetupTOTP db.Exec failed", zap.Error(err))
+		return
+	}
+	setup.Secret = secret
+	setup.URI = totp.ProvisioningURI(pub.TokenIssuer, code, secret)
+	return
+}
+
+// Enable TOTP after the code from the authenticator app is confirmed.
+// Returns the recovery codes, they are only shown once.
+func EnableTOTP(userID int32, code string) (codes []string, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	var secret string
+	var enabled int16
+	err = db.QueryRow("select totpsecret,totpenabled from sysuser where id=$1 and dr=0", userID).Scan(&secret, &enabled)
+	if err == sql.ErrNoRows {
+		resStatus = i18n.StatusUserNotExist
+		err = nil
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("EnableTOTP db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if enabled == 1 {
+		resStatus = i18n.StatusTOTPAlreadyEnabled
+		return
+	}
+	if secret == "" {
+		resStatus = i18n.StatusTOTPNotSetup
+		return
+	}
+	ok, err := useTOTPCode(userID, secret, code)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	if !ok {
+		resStatus = i18n.StatusTOTPInvalidCode
+		return
+	}
+	// The recovery codes and the flag are written together
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("EnableTOTP db.Begin failed", zap.Error(err))
+		return
+	}
+	codes, err = writeRecoveryCodes(tx, userID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	_, err = tx.Exec("update sysuser set totpenabled=1,ts=current_timestamp where id=$1", userID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("EnableTOTP tx.Exec failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("EnableTOTP tx.Commit failed", zap.Error(err))
+		return
+	}
+	zap.L().Info("TOTP enabled", zap.Int32("userID", userID))
+	return
+}
+
+// Check the TOTP code of a user that has TOTP enabled
+func checkUserTOTPCode(userID int32, code string) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	var secret string
+	var enabled int16
+	err = db.QueryRow("select totpsecret,totpenabled from sysuser where id=$1 and dr=0", userID).Scan(&secret, &enabled)
+	if err == sql.ErrNoRows {
+		resStatus = i18n.StatusUserNotExist
+		err = nil
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("checkUserTOTPCode db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if enabled != 1 {
+		resStatus = i18n.StatusTOTPNotSetup
+		return
+	}
+	ok, err := useTOTPCode(userID, secret, code)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	if !ok {
+		resStatus = i18n.StatusTOTPInvalidCode
+	}
+	return
+}
+
+// Clear the TOTP secret and the recovery codes of the user
+func clearTOTP(userID int32, operatorID int32) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("clearTOTP db.Begin failed", zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	sqlStr := `update sysuser set totpsecret='',totpenabled=0,totplaststep=0,
+	modifierid=$1,modifytime=current_timestamp,ts=current_timestamp
+	where id=$2`
+	_, err = tx.Exec(sqlStr, operatorID, userID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("clearTOTP tx.Exec(sysuser) failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	_, err = tx.Exec("delete from sysrecoverycode where userid=$1", userID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("clearTOTP tx.Exec(sysrecoverycode) failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	return
+}
+
+// Disable TOTP of the user.
+// Not allowed when one of the user's roles enforces TOTP.
+func DisableTOTP(userID int32, code string) (resStatus i18n.ResKey, err error) {
+	_, required, err := getUserTOTPState(userID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
+	if required {
+		resStatus = i18n.StatusTOTPRequiredByRole
+		return
+	}
+	resStatus, err = checkUserTOTPCode(userID, code)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	resStatus, err = clearTOTP(userID, userID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	zap.L().Info("TOTP disabled", zap.Int32("userID", userID))
+	return
+}
+
+// Replace the recovery codes of the user after the TOTP code is confirmed
+func RegenerateRecoveryCodes(userID int32, code string) (codes []string, resStatus i18n.ResKey, err error) {
+	resStatus, err = checkUserTOTPCode(userID, code)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return genRecoveryCodes(userID)
+}
+
+// Reset the TOTP of a user who lost the authenticator and the recovery codes.
+// The user has to enroll again on the next login if a role enforces TOTP.
+func (user *User) ResetTOTP() (resStatus i18n.ResKey, err error) {
+	resStatus, err = clearTOTP(user.ID, user.Modifier.ID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	user.DelFromLocalCache()
+	zap.L().Info("TOTP reset", zap.Int32("userID", user.ID), zap.Int32("operatorID", user.Modifier.ID))
+	return
+}
+
+// Generate the TOTP secret during login, for users whose role enforces TOTP
+func PreAuthSetupTOTP(p *ParamPreAuth) (setup TOTPSetup, resStatus i18n.ResKey, err error) {
+	pa, resStatus, err := getPreAuth(p, preAuthStepTOTP)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return SetupTOTP(pa.UserID)
+}
+
+// Enable TOTP during login and complete the login
+func PreAuthEnableTOTP(p *ParamTOTPLogin) (te TOTPEnrollment, resStatus i18n.ResKey, err error) {
+	pa, resStatus, err := getPreAuth(&p.ParamPreAuth, preAuthStepTOTP)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	te.RecoveryCodes, resStatus, err = EnableTOTP(pa.UserID, p.Code)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	delPreAuth(p.PreAuthToken)
+	te.TokenPair, resStatus, err = finishLogin(pa, p.ClientIP, p.UserAgent)
+	return
+}
+
+// Encrypt the stored TOTP secrets again with the active key,
+// the secrets stored before the encryption and the ones encrypted with a retired key.
+// A secret that can't be decrypted is left as it is, its user needs a TOTP reset.
+func SealTOTPSecrets() (err error) {
+	type storedSecret struct {
+		userID int32
+		stored string
+	}
+	rows, err := db.Query("select id,totpsecret from sysuser where totpsecret<>''")
+	if err != nil {
+		zap.L().Error("SealTOTPSecrets db.Query failed", zap.Error(err))
+		return
+	}
+	var secrets []storedSecret
+	for rows.Next() {
+		var ss storedSecret
+		err = rows.Scan(&ss.userID, &ss.stored)
+		if err != nil {
+			rows.Close()
+			zap.L().Error("SealTOTPSecrets rows.Scan failed", zap.Error(err))
+			return
+		}
+		if totp.NeedsSeal(ss.stored) {
+			secrets = append(secrets, ss)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		zap.L().Error("SealTOTPSecrets rows.Err failed", zap.Error(err))
+		return
+	}
+	var sealedNumber int
+	for _, ss := range secrets {
+		secret, err := totp.Open(ss.stored, ss.userID)
+		if err != nil {
+			zap.L().Warn("SealTOTPSecrets totp.Open failed", zap.Int32("userID", ss.userID), zap.Error(err))
+			continue
+		}
+		sealed, err := totp.Seal(secret, ss.userID)
+		if err != nil {
+			zap.L().Error("SealTOTPSecrets totp.Seal failed", zap.Error(err))
+			return err
+		}
+		// Another server may have encrypted it already
+		_, err = db.Exec("update sysuser set totpsecret=$1 where id=$2 and totpsecret=$3", sealed, ss.userID, ss.stored)
+		if err != nil {
+			zap.L().Error("SealTOTPSecrets db.Exec failed", zap.Error(err))
+			return err
+		}
+		sealedNumber++
+	}
+	if sealedNumber > 0 {
+		zap.L().Info("TOTP secrets encrypted with the active key", zap.Int("number", sealedNumber))
+	}
+	return
+}
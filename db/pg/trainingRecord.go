@@ -1,7 +1,10 @@
 package pg
 
 import (
+	"database/sql"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/filter"
+	"sccsmsserver/pub"
 	"sccsmsserver/setting"
 	"strings"
 	"time"
@@ -130,7 +133,7 @@ type RecivedTrainingReport struct {
 }
 
 // Add Training Record
-func (tr *TrainingRecord) Add() (resStatus i18n.ResKey, err error) {
+func (tr *TrainingRecord) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check the number of body rows, zero is not allowed
 	if len(tr.Body) == 0 {
@@ -145,6 +148,13 @@ func (tr *TrainingRecord) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Start the audit trail
+	at, err := beginAudit(tx, actor, pub.TR, AuditActionAdd)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Get the latest serial number
 	billNo, resStatus, err := GetLatestSerialNo(tx, "TR")
 	if resStatus != i18n.StatusOK || err != nil {
@@ -238,6 +248,12 @@ func (tr *TrainingRecord) Add() (resStatus i18n.ResKey, err error) {
 			}
 		}
 	}
+	// Write the audit trail
+	err = at.write(tr.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
@@ -494,10 +510,47 @@ func (tr *TrainingRecord) FillHead() (resStatus i18n.ResKey, err error) {
 	return
 }
 
+// Filterable fields of the Training Record list
+var trListFields = filter.Fields{
+	"billNumber":     {Column: "h.billnumber", Type: filter.String},
+	"billDate":       {Column: "h.billdate", Type: filter.Time},
+	"description":    {Column: "h.description", Type: filter.String},
+	"status":         {Column: "h.status", Type: filter.Number},
+	"departmentID":   {Column: "h.deptid", Type: filter.Number},
+	"departmentCode": {Column: "department.code", Type: filter.String},
+	"departmentName": {Column: "department.name", Type: filter.String},
+	"trainingDate":   {Column: "h.trainingdate", Type: filter.Time},
+	"tcID":           {Column: "h.tcid", Type: filter.Number},
+	"tcCode":         {Column: "tc.code", Type: filter.String},
+	"tcName":         {Column: "tc.name", Type: filter.String},
+	"lecturerID":     {Column: "h.lecturerid", Type: filter.Number},
+	"lecturerCode":   {Column: "lecturer.code", Type: filter.String},
+	"lecturerName":   {Column: "lecturer.name", Type: filter.String},
+	"startTime":      {Column: "h.starttime", Type: filter.Time},
+	"endTime":        {Column: "h.endtime", Type: filter.Time},
+	"classHour":      {Column: "h.classhour", Type: filter.Number},
+	"isExamine":      {Column: "h.isexam", Type: filter.Number},
+	"creatorID":      {Column: "h.creatorid", Type: filter.Number},
+	"creatorCode":    {Column: "creator.code", Type: filter.String},
+	"creatorName":    {Column: "creator.name", Type: filter.String},
+	"modifierID":     {Column: "h.modifierid", Type: filter.Number},
+	"modifierName":   {Column: "modifier.name", Type: filter.String},
+	"createDate":     {Column: "h.createtime", Type: filter.Time},
+	"confirmDate":    {Column: "h.confirmtime", Type: filter.Time},
+	"modifyDate":     {Column: "h.modifytime", Type: filter.Time},
+}
+
 // Get Training Record List
-func GetTRList(queryString string) (trs []TrainingRecord, resStatus i18n.ResKey, err error) {
+func GetTRList(queryFilter filter.Group, ds DataScope) (trs []TrainingRecord, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
+	// Restrict the query to the data scope of the user
+	queryFilter = ds.Restrict(queryFilter, "departmentID", "creatorID")
 	trs = make([]TrainingRecord, 0)
+	// Compile the query filter
+	whereSql, args, resStatus := compileFilter(queryFilter, trListFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Concatenate SQL string for check
 	build.WriteString(`select count(h.id) as rownumber
@@ -508,15 +561,15 @@ func GetTRList(queryString string) (trs []TrainingRecord, resStatus i18n.ResKey,
 	left join sysuser as creator on h.creatorid = creator.id
 	left join sysuser as modifier on h.modifierid = modifier.id
 	where (h.dr = 0) `)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 	// Check
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, args...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetTRList db.QueryRow(checkSql) failed:", zap.Error(err))
@@ -545,14 +598,14 @@ func GetTRList(queryString string) (trs []TrainingRecord, resStatus i18n.ResKey,
 	left join sysuser as creator on h.creatorid = creator.id
 	left join sysuser as modifier on h.modifierid = modifier.id
 	where (h.dr = 0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	headSql := build.String()
 	// Retrieve Training Record from database
-	headRows, err := db.Query(headSql)
+	headRows, err := db.Query(headSql, args...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetTRList db.Query failed:", zap.Error(err))
@@ -583,7 +636,7 @@ func GetTRList(queryString string) (trs []TrainingRecord, resStatus i18n.ResKey,
 }
 
 // Edit Training Record
-func (tr *TrainingRecord) Edit() (resStatus i18n.ResKey, err error) {
+func (tr *TrainingRecord) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check the number of body rows, zero cannot allowed
 	if len(tr.Body) == 0 {
@@ -595,6 +648,11 @@ func (tr *TrainingRecord) Edit() (resStatus i18n.ResKey, err error) {
 		resStatus = i18n.StatusVoucherOnlyCreateEdit
 		return
 	}
+	// Vouchers waiting for approval can't be modified
+	resStatus, err = checkApprovalPending(pub.TR, tr.HID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 
 	// Begin a database transaction
 	tx, err := db.Begin()
@@ -604,6 +662,13 @@ func (tr *TrainingRecord) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.TR, AuditActionEdit, tr.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Update the Training Record in the trainingrecord_h table
 	trHeadSql := `update trainingrecord_h set billdate=$1,deptid=$2,description=$3, lecturerid=$4,trainingdate=$5,
@@ -834,11 +899,17 @@ func (tr *TrainingRecord) Edit() (resStatus i18n.ResKey, err error) {
 			}
 		}
 	}
+	// Write the audit trail
+	err = at.write(tr.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Deleete Training Record
-func (tr *TrainingRecord) Delete(operatorID int32) (resStatus i18n.ResKey, err error) {
+func (tr *TrainingRecord) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Get Training Record by HID
 	resStatus, err = tr.GetDetailByHID()
@@ -851,10 +922,15 @@ func (tr *TrainingRecord) Delete(operatorID int32) (resStatus i18n.ResKey, err e
 		return
 	}
 	// Check if the creator and Operator are same person
-	if tr.Creator.ID != operatorID {
+	if tr.Creator.ID != actor.UserID {
 		resStatus = i18n.StatusVoucherOnlyCreateEdit
 		return
 	}
+	// Vouchers waiting for approval can't be deleted
+	resStatus, err = checkApprovalPending(pub.TR, tr.HID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 
 	// Begin a database transction
 	tx, err := db.Begin()
@@ -864,10 +940,17 @@ func (tr *TrainingRecord) Delete(operatorID int32) (resStatus i18n.ResKey, err e
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.TR, AuditActionDelete, tr.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Modify the Training Record header's deletion flag
 	delHeadSql := `update trainingrecord_h set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp 
 	where id=$2 and dr=0 and ts=$3`
-	delHeadRes, err := tx.Exec(delHeadSql, operatorID, tr.HID, tr.Ts)
+	delHeadRes, err := tx.Exec(delHeadSql, actor.UserID, tr.HID, tr.Ts)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("TrainingRecord.Delete tx.Exec(delHeadSql) failed:", zap.Error(err))
@@ -902,7 +985,7 @@ func (tr *TrainingRecord) Delete(operatorID int32) (resStatus i18n.ResKey, err e
 	if len(tr.HFiles) > 0 {
 		// Wirte data into table row by row
 		for _, hFile := range tr.HFiles {
-			delHFileRes, delHFileErr := delHFileStmt.Exec(operatorID, hFile.ID, tr.HID, hFile.Ts)
+			delHFileRes, delHFileErr := delHFileStmt.Exec(actor.UserID, hFile.ID, tr.HID, hFile.Ts)
 			if delHFileErr != nil {
 				resStatus = i18n.StatusInternalError
 				zap.L().Error("TrainingRecord.Delete delHFileStmt.Exec() failed:", zap.Error(delHFileErr))
@@ -956,7 +1039,7 @@ func (tr *TrainingRecord) Delete(operatorID int32) (resStatus i18n.ResKey, err e
 			return
 		}
 		// Modify row data
-		delRowRes, errDelRow := delRowStmt.Exec(operatorID, row.BID, row.Ts)
+		delRowRes, errDelRow := delRowStmt.Exec(actor.UserID, row.BID, row.Ts)
 		if errDelRow != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("TrainingRecord.Delete delRowStmt.Exec() failed:", zap.Error(errDelRow))
@@ -980,7 +1063,7 @@ func (tr *TrainingRecord) Delete(operatorID int32) (resStatus i18n.ResKey, err e
 		if len(row.BFiles) > 0 {
 			// Modify attachments deletion flag item by item
 			for _, file := range row.BFiles {
-				delFileRes, delFileErr := delFileStmt.Exec(operatorID, file.ID, row.BID, file.Ts)
+				delFileRes, delFileErr := delFileStmt.Exec(actor.UserID, file.ID, row.BID, file.Ts)
 				if delFileErr != nil {
 					resStatus = i18n.StatusInternalError
 					zap.L().Error("TrainingRecord.Delete delFileStmt.Exec() failed:", zap.Error(delFileErr))
@@ -1003,11 +1086,46 @@ func (tr *TrainingRecord) Delete(operatorID int32) (resStatus i18n.ResKey, err e
 			}
 		}
 	}
+	// Write the audit trail
+	err = at.write(tr.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
+	return
+}
+
+// Confirm Training Record.
+// With an approval flow the Training Record is submitted for approval instead,
+// it is confirmed once the last approval step is approved.
+func (tr *TrainingRecord) Confirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = submitForApproval(pub.TR, tr.HID, actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return tr.confirm(actor)
+}
+
+// Confirm Training Record without approval
+func (tr *TrainingRecord) confirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	// Begin a database transaction
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("TrainingRecord.Confirm db.Begin failed:", zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	resStatus, err = tr.confirmTx(tx, actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		tx.Rollback()
+	}
 	return
 }
 
-// Confirm Training Record
-func (tr *TrainingRecord) Confirm(operatorID int32) (resStatus i18n.ResKey, err error) {
+// Write the confirmation of the Training Record and its audit trail in the transaction
+func (tr *TrainingRecord) confirmTx(tx *sql.Tx, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Get Training Record details
 	resStatus, err = tr.GetDetailByHID()
@@ -1019,22 +1137,19 @@ func (tr *TrainingRecord) Confirm(operatorID int32) (resStatus i18n.ResKey, err
 		resStatus = i18n.StatusVoucherNoFree
 		return
 	}
-	// Begin a database transaction
-	tx, err := db.Begin()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.TR, AuditActionConfirm, tr.HID)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
-		zap.L().Error("TrainingRecord.Confirm db.Begin failed:", zap.Error(err))
 		return
 	}
-	defer tx.Commit()
 	// Update the Training Record header in the trainingrecord_h table
 	confirmHeadSql := `update trainingrecord_h set status=1,confirmtime=current_timestamp,confirmerid=$1,ts=current_timestamp 
 	where id=$2 and dr=0 and status=0 and ts=$3`
-	headRes, err := tx.Exec(confirmHeadSql, operatorID, tr.HID, tr.Ts)
+	headRes, err := tx.Exec(confirmHeadSql, actor.UserID, tr.HID, tr.Ts)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("TrainingRecord.Confirm tx.Exec(confirmHeadSql) failed:", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	// Check the number of rows affected by SQL statement
@@ -1042,12 +1157,10 @@ func (tr *TrainingRecord) Confirm(operatorID int32) (resStatus i18n.ResKey, err
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("TrainingRecord.Confirm headRes.RowsAffected failed:", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	if confirmHeadNumber < 1 {
 		resStatus = i18n.StatusOtherEdit
-		tx.Rollback()
 		return
 	}
 
@@ -1058,7 +1171,6 @@ func (tr *TrainingRecord) Confirm(operatorID int32) (resStatus i18n.ResKey, err
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("TrainingRecord.Confirm tx.Prepare(confirmRowSql) failed:", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	defer rowStmt.Close()
@@ -1068,14 +1180,12 @@ func (tr *TrainingRecord) Confirm(operatorID int32) (resStatus i18n.ResKey, err
 		// Check the row status
 		if row.Status != 0 { // Only status value is zero allowed
 			resStatus = i18n.StatusVoucherNoFree
-			tx.Rollback()
 			return
 		}
-		confirmRowRes, errConfirmRow := rowStmt.Exec(operatorID, row.BID, row.Ts)
+		confirmRowRes, errConfirmRow := rowStmt.Exec(actor.UserID, row.BID, row.Ts)
 		if errConfirmRow != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("TrainingRecord.Confirm rowStmt.Exec failed:", zap.Error(errConfirmRow))
-			tx.Rollback()
 			return resStatus, errConfirmRow
 		}
 
@@ -1083,21 +1193,34 @@ func (tr *TrainingRecord) Confirm(operatorID int32) (resStatus i18n.ResKey, err
 		if errConfirmRow != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("TrainingRecord.Confirm confirmRowRes.RowsAffected failed:", zap.Error(errConfirmRow))
-			tx.Rollback()
 			return resStatus, errConfirmRow
 		}
 		if confirmRowNumber < 1 {
 			resStatus = i18n.StatusOtherEdit
-			tx.Rollback()
 			return
 		}
 	}
 
+	// Write the audit trail
+	err = at.write(tr.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+	}
 	return
 }
 
-// UnConfirm Training Record
-func (tr *TrainingRecord) UnConfirm(operatorID int32) (resStatus i18n.ResKey, err error) {
+// UnConfirm Training Record.
+// Its approval is canceled, it has to be approved again before it is confirmed.
+func (tr *TrainingRecord) UnConfirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = tr.unConfirm(actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return cancelApproval(pub.TR, tr.HID, actor.UserID)
+}
+
+// UnConfirm Training Record without touching its approval
+func (tr *TrainingRecord) unConfirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Get the Training Record details
 	resStatus, err = tr.GetDetailByHID()
@@ -1110,7 +1233,7 @@ func (tr *TrainingRecord) UnConfirm(operatorID int32) (resStatus i18n.ResKey, er
 		return
 	}
 	// Check the confirmer and operator are not same person
-	if tr.Confirmer.ID != operatorID {
+	if tr.Confirmer.ID != actor.UserID {
 		resStatus = i18n.StatusVoucherCancelConfirmSelf
 		return
 	}
@@ -1134,6 +1257,13 @@ func (tr *TrainingRecord) UnConfirm(operatorID int32) (resStatus i18n.ResKey, er
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.TR, AuditActionUnConfirm, tr.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Update the header record in the trainingrecord_h table
 	confirmHeadSql := `update trainingrecord_h set status=0,confirmerid=0,confirmtime=to_timestamp(0),ts=current_timestamp 
 	where id=$1 and dr=0 and status=1 and ts=$2`
@@ -1197,13 +1327,49 @@ func (tr *TrainingRecord) UnConfirm(operatorID int32) (resStatus i18n.ResKey, er
 			return
 		}
 	}
+	// Write the audit trail
+	err = at.write(tr.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
+// Filterable fields of the Taught Lessons report
+var taughtLessonsReportFields = filter.Fields{
+	"billNumber":     {Column: "h.billnumber", Type: filter.String},
+	"billDate":       {Column: "h.billdate", Type: filter.Time},
+	"description":    {Column: "h.description", Type: filter.String},
+	"status":         {Column: "h.status", Type: filter.Number},
+	"departmentID":   {Column: "h.deptid", Type: filter.Number},
+	"departmentName": {Column: "dept.name", Type: filter.String},
+	"trainingDate":   {Column: "h.trainingdate", Type: filter.Time},
+	"tcID":           {Column: "h.tcid", Type: filter.Number},
+	"tcCode":         {Column: "tc.code", Type: filter.String},
+	"tcName":         {Column: "tc.name", Type: filter.String},
+	"lecturerID":     {Column: "h.lecturerid", Type: filter.Number},
+	"lecturerCode":   {Column: "lecturer.code", Type: filter.String},
+	"lecturerName":   {Column: "lecturer.name", Type: filter.String},
+	"startTime":      {Column: "h.starttime", Type: filter.Time},
+	"endTime":        {Column: "h.endtime", Type: filter.Time},
+	"classHour":      {Column: "h.classhour", Type: filter.Number},
+	"isExamine":      {Column: "h.isexam", Type: filter.Number},
+	"creatorID":      {Column: "h.creatorid", Type: filter.Number},
+	"creatorName":    {Column: "creator.name", Type: filter.String},
+}
+
 // Get Taught Lesson Report
-func GetTaughtLessonsReport(queryString string) (tlrs []TaughtLessonsReport, resStatus i18n.ResKey, err error) {
+func GetTaughtLessonsReport(queryFilter filter.Group, ds DataScope) (tlrs []TaughtLessonsReport, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
+	// Restrict the query to the data scope of the user
+	queryFilter = ds.Restrict(queryFilter, "departmentID", "lecturerID")
 	tlrs = make([]TaughtLessonsReport, 0)
+	// Compile the query filter
+	whereSql, args, resStatus := compileFilter(queryFilter, taughtLessonsReportFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Concatenate the SQL strings for check
 	build.WriteString(`select count(h.id) as hid 
@@ -1213,15 +1379,15 @@ func GetTaughtLessonsReport(queryString string) (tlrs []TaughtLessonsReport, res
 	left join tc on h.tcid=tc.id
 	left join sysuser as creator on h.creatorid=creator.id
 	where (h.dr=0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 	// Check
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, args...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetTaughtLessonsReport db.QueryRow(checkSql) failed", zap.Error(err))
@@ -1270,14 +1436,14 @@ func GetTaughtLessonsReport(queryString string) (tlrs []TaughtLessonsReport, res
 	left join tc on h.tcid=tc.id
 	left join sysuser as creator on h.creatorid=creator.id
 	where (h.dr=0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	repSql := build.String()
 	// Get Report from database
-	glRep, err := db.Query(repSql)
+	glRep, err := db.Query(repSql, args...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetTaughtLessonsReport db.Query failed", zap.Error(err))
@@ -1304,10 +1470,45 @@ func GetTaughtLessonsReport(queryString string) (tlrs []TaughtLessonsReport, res
 	return
 }
 
+// Filterable fields of the Received Training report
+var recivedTrainingReportFields = filter.Fields{
+	"billNumber":     {Column: "h.billnumber", Type: filter.String},
+	"billDate":       {Column: "h.billdate", Type: filter.Time},
+	"status":         {Column: "h.status", Type: filter.Number},
+	"departmentID":   {Column: "h.deptid", Type: filter.Number},
+	"departmentName": {Column: "dept.name", Type: filter.String},
+	"trainingDate":   {Column: "h.trainingdate", Type: filter.Time},
+	"tcID":           {Column: "h.tcid", Type: filter.Number},
+	"tcCode":         {Column: "tc.code", Type: filter.String},
+	"tcName":         {Column: "tc.name", Type: filter.String},
+	"lecturerID":     {Column: "h.lecturerid", Type: filter.Number},
+	"lecturerName":   {Column: "lecturer.name", Type: filter.String},
+	"studentID":      {Column: "b.studentid", Type: filter.Number},
+	"studentCode":    {Column: "student.code", Type: filter.String},
+	"studentName":    {Column: "student.name", Type: filter.String},
+	"positionName":   {Column: "b.positionname", Type: filter.String},
+	"deptName":       {Column: "b.deptname", Type: filter.String},
+	"startTime":      {Column: "b.starttime", Type: filter.Time},
+	"endTime":        {Column: "b.endtime", Type: filter.Time},
+	"classHour":      {Column: "b.classhour", Type: filter.Number},
+	"examRes":        {Column: "b.examres", Type: filter.Number},
+	"examScore":      {Column: "b.examscore", Type: filter.Number},
+	"description":    {Column: "b.description", Type: filter.String},
+	"creatorID":      {Column: "h.creatorid", Type: filter.Number},
+	"creatorName":    {Column: "creator.name", Type: filter.String},
+}
+
 // Get Recived Training Report
-func GetRecivedTrainingReport(queryString string) (rtrs []RecivedTrainingReport, resStatus i18n.ResKey, err error) {
+func GetRecivedTrainingReport(queryFilter filter.Group, ds DataScope) (rtrs []RecivedTrainingReport, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
+	// Restrict the query to the data scope of the user
+	queryFilter = ds.Restrict(queryFilter, "departmentID", "studentID")
 	rtrs = make([]RecivedTrainingReport, 0)
+	// Compile the query filter
+	whereSql, args, resStatus := compileFilter(queryFilter, recivedTrainingReportFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Concatenate the SQL string for check
 	build.WriteString(`select count(b.id) as rowcount 
@@ -1319,15 +1520,15 @@ func GetRecivedTrainingReport(queryString string) (rtrs []RecivedTrainingReport,
 	left join tc on h.tcid=tc.id
 	left join sysuser as creator on h.creatorid=creator.id
 	where (h.dr=0 and b.dr=0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 	// Check
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, args...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetTaughtLessonsReport db.QueryRow(checkSql) failed", zap.Error(err))
@@ -1387,14 +1588,14 @@ func GetRecivedTrainingReport(queryString string) (rtrs []RecivedTrainingReport,
 	left join tc on h.tcid=tc.id
 	left join sysuser as creator on h.creatorid=creator.id
 	where (h.dr=0 and b.dr=0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	repSql := build.String()
 	// Get Report data from database
-	rtRep, err := db.Query(repSql)
+	rtRep, err := db.Query(repSql, args...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetTaughtLessonsReport db.Query failed", zap.Error(err))
@@ -225,7 +225,7 @@ func (udac *UDACache) GetUDACache() (resStatus i18n.ResKey, err error) {
 }
 
 // Add UDA
-func (uda *UserDefinedArchive) Add() (resStatus i18n.ResKey, err error) {
+func (uda *UserDefinedArchive) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the UDA name exists
 	resStatus, err = uda.CheckCodeExist()
@@ -233,21 +233,24 @@ func (uda *UserDefinedArchive) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Insert a record into  the uda table
-	sqlStr := `insert into uda(udcid,code,name,description,fatherID,
-	status,creatorid)
-	values($1,$2,$3,$4,$5,$6,$7) returning id`
-	err = db.QueryRow(sqlStr, uda.UDC.ID, uda.Code, uda.Name, uda.Description, uda.FatherId,
-		uda.Status, uda.Creator.ID).Scan(&uda.ID)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("UserDefinedArchive.add db.QueryRow failed:", zap.Error(err))
+	return writeAudited(actor, pub.UDA, AuditActionAdd, &uda.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `insert into uda(udcid,code,name,description,fatherID,
+		status,creatorid)
+		values($1,$2,$3,$4,$5,$6,$7) returning id`
+		err = tx.QueryRow(sqlStr, uda.UDC.ID, uda.Code, uda.Name, uda.Description, uda.FatherId,
+			uda.Status, uda.Creator.ID).Scan(&uda.ID)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("UserDefinedArchive.add tx.QueryRow failed:", zap.Error(err))
+			return
+		}
 		return
-	}
-	return
+	})
 }
 
 // Edit UDA
-func (uda *UserDefinedArchive) Edit() (resStatus i18n.ResKey, err error) {
+func (uda *UserDefinedArchive) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the UDA name exists
 	resStatus, err = uda.CheckCodeExist()
@@ -255,39 +258,42 @@ func (uda *UserDefinedArchive) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the record in the uda table
-	sqlStr := `update uda set
-	udcid=$1,code=$2,name=$3,description=$4,fatherID=$5,
-	status=$6,modifierid=$7,modifytime=current_timestamp,ts=current_timestamp
-	where id=$8 and ts=$9 and dr=0`
-	res, err := db.Exec(sqlStr, uda.UDC.ID, uda.Code, uda.Name, uda.Description, uda.FatherId,
-		uda.Status,
-		uda.Modifier.ID, uda.ID, uda.Ts)
-	if err != nil {
-		zap.L().Error("UserDefinedArchive.Edit db.Exec failed:", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// Get the number of rows affected by the SQL update operation
-	affected, err := res.RowsAffected()
-	if err != nil {
-		zap.L().Error("UserDefinedArchive.Edit get res.RowsAffected failed:", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// If the number of affected  rows is less than one,
-	// it means that someone else has already modified the record.
-	if affected < 1 {
-		zap.L().Info("UserDefinedArchive.Edit failed,Other user are Editing")
-		resStatus = i18n.StatusOtherEdit
+	return writeAudited(actor, pub.UDA, AuditActionEdit, &uda.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update uda set
+		udcid=$1,code=$2,name=$3,description=$4,fatherID=$5,
+		status=$6,modifierid=$7,modifytime=current_timestamp,ts=current_timestamp
+		where id=$8 and ts=$9 and dr=0`
+		res, err := tx.Exec(sqlStr, uda.UDC.ID, uda.Code, uda.Name, uda.Description, uda.FatherId,
+			uda.Status,
+			uda.Modifier.ID, uda.ID, uda.Ts)
+		if err != nil {
+			zap.L().Error("UserDefinedArchive.Edit tx.Exec failed:", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// Get the number of rows affected by the SQL update operation
+		affected, err := res.RowsAffected()
+		if err != nil {
+			zap.L().Error("UserDefinedArchive.Edit get res.RowsAffected failed:", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// If the number of affected  rows is less than one,
+		// it means that someone else has already modified the record.
+		if affected < 1 {
+			zap.L().Info("UserDefinedArchive.Edit failed,Other user are Editing")
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		// Delete from cache
+		uda.DelFromLocalCache()
 		return
-	}
-	// Delete from cache
-	uda.DelFromLocalCache()
-	return
+	})
 }
 
 // Delete UDA
-func (uda *UserDefinedArchive) Delete() (resStatus i18n.ResKey, err error) {
+func (uda *UserDefinedArchive) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the UDA is refrenced
 	resStatus, err = uda.CheckUsed()
@@ -295,35 +301,38 @@ func (uda *UserDefinedArchive) Delete() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the deletion flag for this record
-	sqlStr := `update uda set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp
-	where id=$2 and dr=0 and ts=$3`
-	res, err := db.Exec(sqlStr, uda.Modifier.ID, uda.ID, uda.Ts)
-	if err != nil {
-		zap.L().Error("UserDefinedArchive.Delete db.Exec failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
+	return writeAudited(actor, pub.UDA, AuditActionDelete, &uda.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update uda set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp
+		where id=$2 and dr=0 and ts=$3`
+		res, err := tx.Exec(sqlStr, uda.Modifier.ID, uda.ID, uda.Ts)
+		if err != nil {
+			zap.L().Error("UserDefinedArchive.Delete tx.Exec failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
 
-	// Check the number of rows affected by the SQL update operation
-	affected, err := res.RowsAffected()
-	if err != nil {
-		zap.L().Error("UserDefinedArchive.Delete res.RowsAffected failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// if the number of affected rows is less than one,
-	// it means that someone else has already modified the record.
-	if affected < 1 {
-		resStatus = i18n.StatusOtherEdit
+		// Check the number of rows affected by the SQL update operation
+		affected, err := res.RowsAffected()
+		if err != nil {
+			zap.L().Error("UserDefinedArchive.Delete res.RowsAffected failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// if the number of affected rows is less than one,
+		// it means that someone else has already modified the record.
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		// Delete from cache
+		uda.DelFromLocalCache()
 		return
-	}
-	// Delete from cache
-	uda.DelFromLocalCache()
-	return
+	})
 }
 
 // Batch delete UDA
-func DeleteUDAs(udas *[]UserDefinedArchive, modifyUserId int32) (resStatus i18n.ResKey, err error) {
+func DeleteUDAs(udas *[]UserDefinedArchive, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Start a databse transaction
 	tx, err := db.Begin()
@@ -333,6 +342,17 @@ func DeleteUDAs(udas *[]UserDefinedArchive, modifyUserId int32) (resStatus i18n.
 		return
 	}
 	defer tx.Commit()
+	// Lock the archives and take their state for the audit trail
+	ids := make([]int32, 0, len(*udas))
+	for _, item := range *udas {
+		ids = append(ids, item.ID)
+	}
+	at, err := beginAudit(tx, actor, pub.UDA, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	delSqlStr := `update uda set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp 
 	where id=$2 and dr=0 and ts=$3`
@@ -354,7 +374,7 @@ func DeleteUDAs(udas *[]UserDefinedArchive, modifyUserId int32) (resStatus i18n.
 			return
 		}
 		// Update the deletion flag for this record
-		res, err1 := stmt.Exec(modifyUserId, uda.ID, uda.Ts)
+		res, err1 := stmt.Exec(actor.UserID, uda.ID, uda.Ts)
 		if err1 != nil {
 			zap.L().Error("DeleteUDAs stmt.Exec failed:", zap.Error(err))
 			tx.Rollback()
@@ -379,6 +399,12 @@ func DeleteUDAs(udas *[]UserDefinedArchive, modifyUserId int32) (resStatus i18n.
 		uda.DelFromLocalCache()
 	}
 
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
@@ -163,7 +163,7 @@ func (udcc *UDCCache) GetUDCsCache() (resStatus i18n.ResKey, err error) {
 }
 
 // Add User-defined Category
-func (udfc *UserDefineCategory) Add() (resStatus i18n.ResKey, err error) {
+func (udfc *UserDefineCategory) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the udc name exist
 	resStatus, err = udfc.CheckNameExist()
@@ -171,14 +171,17 @@ func (udfc *UserDefineCategory) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Write a record to the udc table
-	sqlStr := `insert into udc(name,description,status,Creatorid) values($1,$2,$3,$4) returning id`
-	err = db.QueryRow(sqlStr, udfc.Name, udfc.Description, udfc.Status, udfc.Creator.ID).Scan(&udfc.ID)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("UserDefineCategory Add stmt.QueryRow failed", zap.Error(err))
+	return writeAudited(actor, pub.UDC, AuditActionAdd, &udfc.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `insert into udc(name,description,status,Creatorid) values($1,$2,$3,$4) returning id`
+		err = tx.QueryRow(sqlStr, udfc.Name, udfc.Description, udfc.Status, udfc.Creator.ID).Scan(&udfc.ID)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("UserDefineCategory Add stmt.QueryRow failed", zap.Error(err))
+			return
+		}
 		return
-	}
-	return
+	})
 }
 
 // Get User-defined Category detail by ID
@@ -223,7 +226,7 @@ func (udc *UserDefineCategory) GetUDCInfoByID() (resStatus i18n.ResKey, err erro
 }
 
 // Edit User-defined Category
-func (udfc *UserDefineCategory) Edit() (resStatus i18n.ResKey, err error) {
+func (udfc *UserDefineCategory) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the UDC name exists.
 	resStatus, err = udfc.CheckNameExist()
@@ -231,38 +234,41 @@ func (udfc *UserDefineCategory) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update record in udc table
-	sqlStr := `update udc set 
-	name=$1,description=$2,islevel=$3,status=$4,modifierid=$5,
-	modifytime=current_timestamp,ts=current_timestamp
-	where id=$6 and ts=$7 and dr=0`
-	res, err := db.Exec(sqlStr, udfc.Name, udfc.Description, udfc.IsLevel, udfc.Status, udfc.Modifier.ID,
-		udfc.ID, udfc.Ts)
-	if err != nil {
-		zap.L().Error("UserDefineCategory.Edit db.exec failed:", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// Check the number of rows affected by update operation.
-	affected, err := res.RowsAffected()
-	if err != nil {
-		zap.L().Error("UserDefineCategory.Edit  get res.RowsAffected failed", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	// If the number of affected rows is less than 1,
-	// it means that someone else has already updated the record.
-	if affected < 1 {
-		zap.L().Info("UserDefineCategory.Edit failed: another user is updating this data.")
-		resStatus = i18n.StatusOtherEdit
+	return writeAudited(actor, pub.UDC, AuditActionEdit, &udfc.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update udc set 
+		name=$1,description=$2,islevel=$3,status=$4,modifierid=$5,
+		modifytime=current_timestamp,ts=current_timestamp
+		where id=$6 and ts=$7 and dr=0`
+		res, err := tx.Exec(sqlStr, udfc.Name, udfc.Description, udfc.IsLevel, udfc.Status, udfc.Modifier.ID,
+			udfc.ID, udfc.Ts)
+		if err != nil {
+			zap.L().Error("UserDefineCategory.Edit db.exec failed:", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// Check the number of rows affected by update operation.
+		affected, err := res.RowsAffected()
+		if err != nil {
+			zap.L().Error("UserDefineCategory.Edit  get res.RowsAffected failed", zap.Error(err))
+			resStatus = i18n.StatusInternalError
+			return
+		}
+		// If the number of affected rows is less than 1,
+		// it means that someone else has already updated the record.
+		if affected < 1 {
+			zap.L().Info("UserDefineCategory.Edit failed: another user is updating this data.")
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		// Delete from cache
+		udfc.DelFromCache()
 		return
-	}
-	// Delete from cache
-	udfc.DelFromCache()
-	return
+	})
 }
 
 // Delete User-define Category master data
-func (udfc *UserDefineCategory) Delete() (resStatus i18n.ResKey, err error) {
+func (udfc *UserDefineCategory) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the data is referenced.
 	resStatus, err = udfc.CheckUsed()
@@ -270,29 +276,32 @@ func (udfc *UserDefineCategory) Delete() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the deletion field for this data in the udc table.
-	sqlStr := `update udc set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp 
-	where id=$2 and dr=0 and ts=$3`
+	return writeAudited(actor, pub.UDC, AuditActionDelete, &udfc.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update udc set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp 
+		where id=$2 and dr=0 and ts=$3`
 
-	res, err := db.Exec(sqlStr, udfc.Modifier.ID, udfc.ID, udfc.Ts)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("UserDefineCategory.Delete stmt.exec failed", zap.Error(err))
-		return
-	}
-	// Check the number of rows affected by update operation.
-	affected, err := res.RowsAffected()
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("UserDefineCategory.Delete res.RowsAffected failed", zap.Error(err))
-		return
-	}
-	if affected < 1 {
-		resStatus = i18n.StatusOtherEdit
+		res, err := tx.Exec(sqlStr, udfc.Modifier.ID, udfc.ID, udfc.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("UserDefineCategory.Delete stmt.exec failed", zap.Error(err))
+			return
+		}
+		// Check the number of rows affected by update operation.
+		affected, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("UserDefineCategory.Delete res.RowsAffected failed", zap.Error(err))
+			return
+		}
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+			return
+		}
+		// Delete from local cache
+		udfc.DelFromCache()
 		return
-	}
-	// Delete from local cache
-	udfc.DelFromCache()
-	return
+	})
 }
 
 // Check if the UDC name exists
@@ -314,7 +323,7 @@ func (udfc *UserDefineCategory) CheckNameExist() (resStatus i18n.ResKey, err err
 }
 
 // Batch delete UDC
-func DeleteUDCs(udcs *[]UserDefineCategory, modifyUserId int32) (resStatus i18n.ResKey, err error) {
+func DeleteUDCs(udcs *[]UserDefineCategory, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Begin a database transaction
 	tx, err := db.Begin()
@@ -324,6 +333,17 @@ func DeleteUDCs(udcs *[]UserDefineCategory, modifyUserId int32) (resStatus i18n.
 		return
 	}
 	defer tx.Commit()
+	// Lock the categories and take their state for the audit trail
+	ids := make([]int32, 0, len(*udcs))
+	for _, item := range *udcs {
+		ids = append(ids, item.ID)
+	}
+	at, err := beginAudit(tx, actor, pub.UDC, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	delSqlStr := `update udc set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp 
 	where id=$2 and dr=0 and ts=$3`
@@ -344,7 +364,7 @@ func DeleteUDCs(udcs *[]UserDefineCategory, modifyUserId int32) (resStatus i18n.
 			return
 		}
 		// Update the deletion field for this data in the udc table.
-		result, err1 := stmt.Exec(modifyUserId, udc.ID, udc.Ts)
+		result, err1 := stmt.Exec(actor.UserID, udc.ID, udc.Ts)
 		if err1 != nil {
 			zap.L().Error("DeleteUDCs stmt.exec failed", zap.Error(err))
 			_ = tx.Rollback()
@@ -365,6 +385,12 @@ func DeleteUDCs(udcs *[]UserDefineCategory, modifyUserId int32) (resStatus i18n.
 		// Delete item from cache
 		udc.DelFromCache()
 	}
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
@@ -2,11 +2,14 @@ package pg
 
 import (
 	"crypto/md5"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/hex"
 	"sccsmsserver/cache"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/password"
 	"sccsmsserver/pub"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -14,30 +17,37 @@ import (
 
 // User Master Data
 type User struct {
-	ID          int32       `db:"id" json:"id"`
-	Code        string      `db:"code" json:"code"`
-	Name        string      `db:"name" json:"name"`
-	Password    string      `db:"password" json:"password"`
-	Mobile      string      `db:"mobile" json:"mobile"`
-	Email       string      `db:"email" json:"email"`
-	IsOperator  int16       `db:"isoperator" json:"isOperator"`
-	Position    Position    `db:"positionid" json:"position"`
-	Avatar      File        `db:"fileid" json:"avatar"`
-	Dept        SimpDept    `db:"deptid" json:"department"`
-	Description string      `db:"description" json:"description"`
-	Gender      int16       `db:"gender" json:"gender"`
-	Locked      int16       `db:"locked" json:"locked"`
-	Status      int16       `db:"status" json:"status"`
-	SystemFlag  int16       `db:"systemflag" json:"systemFlag"`
-	MenuList    SystemMenus `json:"menuList"`
-	Roles       []Role      `json:"roles"`
-	Person      Person      `json:"person"`
-	CreateDate  time.Time   `db:"createtime" json:"createDate"`
-	Creator     Person      `db:"creatorid" json:"creator"`
-	ModifyDate  time.Time   `db:"modifytime" json:"modifyDate"`
-	Modifier    Person      `db:"modifierid" json:"modifier"`
-	Dr          int16       `db:"dr" json:"dr"`
-	Ts          time.Time   `db:"ts" json:"ts"`
+	ID          int32    `db:"id" json:"id"`
+	Code        string   `db:"code" json:"code"`
+	Name        string   `db:"name" json:"name"`
+	Password    string   `db:"password" json:"password"`
+	Mobile      string   `db:"mobile" json:"mobile"`
+	Email       string   `db:"email" json:"email"`
+	IsOperator  int16    `db:"isoperator" json:"isOperator"`
+	Position    Position `db:"positionid" json:"position"`
+	Avatar      File     `db:"fileid" json:"avatar"`
+	Dept        SimpDept `db:"deptid" json:"department"`
+	Description string   `db:"description" json:"description"`
+	Gender      int16    `db:"gender" json:"gender"`
+	Locked      int16    `db:"locked" json:"locked"`
+	Status      int16    `db:"status" json:"status"`
+	SystemFlag  int16    `db:"systemflag" json:"systemFlag"`
+	TOTPEnabled int16    `db:"totpenabled" json:"totpEnabled"`
+	AuthSource  string   `db:"authsource" json:"authSource"`
+	// The user has to change the password on the next login
+	MustChangePwd int16 `db:"mustchangepwd" json:"mustChangePwd"`
+	// Generated password, only returned once after the user is created or the password is reset
+	InitialPassword string      `json:"initialPassword,omitempty"`
+	KeyID           string      `json:"keyId,omitempty"` // Key ID of the RSA public key the password is encrypted with
+	MenuList        SystemMenus `json:"menuList"`
+	Roles           []Role      `json:"roles"`
+	Person          Person      `json:"person"`
+	CreateDate      time.Time   `db:"createtime" json:"createDate"`
+	Creator         Person      `db:"creatorid" json:"creator"`
+	ModifyDate      time.Time   `db:"modifytime" json:"modifyDate"`
+	Modifier        Person      `db:"modifierid" json:"modifier"`
+	Dr              int16       `db:"dr" json:"dr"`
+	Ts              time.Time   `db:"ts" json:"ts"`
 }
 
 // User password change struct
@@ -48,6 +58,7 @@ type ParamChangePwd struct {
 	Password      string `json:"password" binding:"required"`
 	NewPassword   string `json:"newPassword" binding:"required"`
 	ConfirmNewPwd string `json:"confirmNewPassword" binding:"required"`
+	KeyID         string `json:"keyId"` // Key ID of the RSA public key the passwords are encrypted with
 }
 
 // Initialize user table
@@ -60,11 +71,16 @@ func initSysUser() (isFinish bool, err error) {
 		return
 	}
 	// Step 3: Insert a record for the system default user 'admin' into the sysuser table.
+	defaultPassword, err := encryptPassword(pub.DefaultPassword)
+	if err != nil {
+		isFinish = false
+		return
+	}
 	sqlStr = `insert into sysuser(id,name,password,createtime,description,
-		systemflag,code,creatorid) 
+		systemflag,code,creatorid,mustchangepwd) 
 		values(10000,'admin',$1,now(),'System default',
-		1,'admin',10000)`
-	_, err = db.Exec(sqlStr, encryptPassword(pub.DefaultPassword))
+		1,'admin',10000,1)`
+	_, err = db.Exec(sqlStr, defaultPassword)
 	if err != nil {
 		isFinish = false
 		zap.L().Error("initSysUser db.Exec failed:", zap.Error(err))
@@ -74,24 +90,62 @@ func initSysUser() (isFinish bool, err error) {
 }
 
 // Encrypt Password
-func encryptPassword(oPassword string) string {
+func encryptPassword(oPassword string) (string, error) {
+	pwd, err := password.Hash(oPassword)
+	if err != nil {
+		zap.L().Error("encryptPassword password.Hash failed", zap.Error(err))
+	}
+	return pwd, err
+}
+
+// Legacy salted MD5 password, only used to verify passwords stored before the algorithm prefix
+func legacyMd5Password(oPassword string) string {
 	h := md5.New()
 	h.Write(pub.Md5Secret)
 	return hex.EncodeToString(h.Sum([]byte(oPassword)))
 }
 
+// Check the password against the stored hash.
+// needsUpgrade reports a match whose stored hash should be replaced by encryptPassword.
+func checkPassword(oPassword string, storedPassword string) (match bool, needsUpgrade bool) {
+	if !password.IsHashed(storedPassword) {
+		legacy := legacyMd5Password(oPassword)
+		match = subtle.ConstantTimeCompare([]byte(strings.ToLower(storedPassword)), []byte(legacy)) == 1
+		return match, match
+	}
+	match, needsUpgrade, err := password.Verify(oPassword, storedPassword)
+	if err != nil {
+		zap.L().Error("checkPassword password.Verify failed", zap.Error(err))
+		return false, false
+	}
+	return
+}
+
+// Upgrade the stored password of the user to the current algorithm.
+// Failures are logged only, the user can still log in with the old hash.
+func upgradePassword(userID int32, oPassword string) {
+	newPwd, err := encryptPassword(oPassword)
+	if err != nil {
+		return
+	}
+	_, err = db.Exec("update sysuser set password=$1 where id=$2", newPwd, userID)
+	if err != nil {
+		zap.L().Error("upgradePassword db.Exec failed", zap.Error(err))
+	}
+}
+
 // Get User Information by ID
 func (user *User) GetUserInfoByID() (resStatus i18n.ResKey, err error) {
 	// Query user information from the database.
 	sqlStr := `select code,name,mobile,email,fileid,
 		isoperator,positionid,deptid,COALESCE(description,''),gender,
-		locked,systemflag,createtime,creatorid,modifytime,
-		modifierid,ts,dr 
+		locked,systemflag,totpenabled,authsource,mustchangepwd,createtime,
+		creatorid,modifytime,modifierid,ts,dr 
 		from sysuser where id = $1`
 	err = db.QueryRow(sqlStr, user.ID).Scan(&user.Code, &user.Name, &user.Mobile, &user.Email, &user.Avatar.ID,
 		&user.IsOperator, &user.Position.ID, &user.Dept.ID, &user.Description, &user.Gender,
-		&user.Locked, &user.SystemFlag, &user.CreateDate, &user.Creator.ID, &user.ModifyDate,
-		&user.Modifier.ID, &user.Ts, &user.Dr)
+		&user.Locked, &user.SystemFlag, &user.TOTPEnabled, &user.AuthSource, &user.MustChangePwd, &user.CreateDate,
+		&user.Creator.ID, &user.ModifyDate, &user.Modifier.ID, &user.Ts, &user.Dr)
 	if err != nil && err != sql.ErrNoRows {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("dap.GetUserInfoByID failed", zap.Error(err))
@@ -241,10 +295,31 @@ func (user *User) CheckUserCodeExist() (resStatus i18n.ResKey, err error) {
 }
 
 // Add user
-func (user *User) Add() (resStatus i18n.ResKey, err error) {
+func (user *User) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
-	// Encrypt the password field useing MD5
-	user.Password = encryptPassword(user.Password)
+	// Without a password, or with the default one, a password is generated.
+	// The user has to change it on the first login.
+	if user.Password == "" || user.Password == pub.DefaultPassword {
+		user.InitialPassword, err = passwordRules().Generate()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("User.Add generate password failed", zap.Error(err))
+			return
+		}
+		user.Password = user.InitialPassword
+	} else {
+		resStatus = checkPasswordPolicy(user.Password)
+		if resStatus != i18n.StatusOK {
+			return
+		}
+	}
+	user.MustChangePwd = 1
+	// Encrypt the password field
+	user.Password, err = encryptPassword(user.Password)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
 	// Check if the user code exists.
 	resStatus, err = user.CheckUserCodeExist()
 	if resStatus != i18n.StatusOK || err != nil {
@@ -258,26 +333,42 @@ func (user *User) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Start the audit trail
+	at, err := beginAudit(tx, actor, pub.User, AuditActionAdd)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Insert a record to sysuser table.
 	sqlStr1 := `insert into 
 	sysuser(code,name,password,mobile,email,
 		isoperator,positionid,fileid,deptid,description,
-		gender,status,locked,creatorid) 
+		gender,status,locked,creatorid,mustchangepwd) 
 		values($1,$2,$3,$4,$5,
 		$6,$7,$8,$9,$10,
-		$11,$12,$13,$14) returning id`
+		$11,$12,$13,$14,$15) returning id`
 
-	err = db.QueryRow(sqlStr1,
+	err = tx.QueryRow(sqlStr1,
 		user.Code, user.Name, user.Password, user.Mobile, user.Email,
 		user.IsOperator, user.Position.ID, user.Avatar.ID, user.Dept.ID, user.Description,
-		user.Gender, user.Status, user.Locked, user.Creator.ID).Scan(&user.ID)
+		user.Gender, user.Status, user.Locked, user.Creator.ID, user.MustChangePwd).Scan(&user.ID)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("User.Add db.QueryRow failed", zap.Error(err))
 		tx.Rollback()
 		return
 	}
+	// Record the password in the history
+	err = addPasswordHistory(tx, user.ID, user.Password)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	// The password hash is not returned to the client
+	user.Password = ""
 	// Pre-processing for Insert records into the sysuserrole table.
 	sqlStr2 := "insert into sysuserrole(userid,roleid) values($1,$2)"
 	stmt2, err := tx.Prepare(sqlStr2)
@@ -298,15 +389,30 @@ func (user *User) Add() (resStatus i18n.ResKey, err error) {
 			return
 		}
 	}
+	// Write the audit trail
+	err = at.write(user.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Edit user
-func (user *User) Edit() (resStatus i18n.ResKey, err error) {
+func (user *User) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
-	//Encrypt the password field using MD5
+	// Encrypt the password field.
+	// A password set by the administrator has to be changed by the user on the next login.
 	if user.Password != "" {
-		user.Password = encryptPassword(user.Password)
+		resStatus = checkPasswordPolicy(user.Password)
+		if resStatus != i18n.StatusOK {
+			return
+		}
+		user.Password, err = encryptPassword(user.Password)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			return
+		}
 	}
 	// Check if the user code exists.
 	resStatus, err = user.CheckUserCodeExist()
@@ -320,14 +426,21 @@ func (user *User) Edit() (resStatus i18n.ResKey, err error) {
 		zap.L().Error("User.Edit db.Begin failed", zap.Error(err))
 		return
 	}
-	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.User, AuditActionEdit, user.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Update the record in the sysuser table.
 	var uSql string
 	if user.Password != "" {
 		uSql = `update sysuser set code=$1,name=$2,mobile=$3, email=$4, fileid=$5,
 		isoperator=$6,positionid=$7,deptid=$8,description=$9, gender=$10,
-		status=$11,locked=$12,modifytime=now(), modifierid = $13,ts=current_timestamp,password=$14 
+		status=$11,locked=$12,modifytime=now(), modifierid = $13,ts=current_timestamp,password=$14,
+		mustchangepwd=1,pwdchangetime=current_timestamp 
 		where id=$15 and ts=$16 and dr=0`
 	} else {
 		uSql = `update sysuser set code=$1,name=$2,mobile=$3, email=$4, fileid=$5,
@@ -338,12 +451,12 @@ func (user *User) Edit() (resStatus i18n.ResKey, err error) {
 
 	var res sql.Result
 	if user.Password != "" {
-		res, err = db.Exec(uSql, user.Code, user.Name, user.Mobile, user.Email, user.Avatar.ID,
+		res, err = tx.Exec(uSql, user.Code, user.Name, user.Mobile, user.Email, user.Avatar.ID,
 			user.IsOperator, user.Position.ID, user.Dept.ID, user.Description, user.Gender,
 			user.Status, user.Locked, user.Modifier.ID, user.Password,
 			user.ID, user.Ts)
 	} else {
-		res, err = db.Exec(uSql, user.Code, user.Name, user.Mobile, user.Email, user.Avatar.ID,
+		res, err = tx.Exec(uSql, user.Code, user.Name, user.Mobile, user.Email, user.Avatar.ID,
 			user.IsOperator, user.Position.ID, user.Dept.ID, user.Description, user.Gender,
 			user.Status, user.Locked, user.Modifier.ID,
 			user.ID, user.Ts)
@@ -371,6 +484,15 @@ func (user *User) Edit() (resStatus i18n.ResKey, err error) {
 		_ = tx.Rollback()
 		return
 	}
+	// Record the password set by the administrator in the history
+	if user.Password != "" {
+		err = addPasswordHistory(tx, user.ID, user.Password)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			tx.Rollback()
+			return
+		}
+	}
 
 	// Delete existing records from the sysuserrole table.
 	dSql := "delete from sysuserrole where userid=$1"
@@ -413,6 +535,19 @@ func (user *User) Edit() (resStatus i18n.ResKey, err error) {
 	}
 	// Delete the user from local cache
 	user.DelFromLocalCache()
+	// Write the audit trail
+	err = at.write(user.ID)
+	if err != nil {
+		tx.Rollback()
+		return i18n.StatusInternalError, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		zap.L().Error("User.Edit tx.Commit failed", zap.Error(err))
+		return i18n.StatusInternalError, err
+	}
+	// The roles of the user may change
+	clearUserPermissions(user.ID)
 	return i18n.StatusOK, nil
 }
 
@@ -450,8 +585,8 @@ func GetUsers() (users []User, resStatus i18n.ResKey, err error) {
 	users = make([]User, 0)
 	sqlStr := `select id,code,name, COALESCE(mobile,'') as mobile,COALESCE(email,'') as email,
 	fileid,isoperator,positionid,deptid,COALESCE(description,''),
-	gender,status,locked,systemflag,createtime,
-	creatorid,modifytime,modifierid,dr,ts 
+	gender,status,locked,systemflag,totpenabled,
+	authsource,mustchangepwd,createtime,creatorid,modifytime,modifierid,dr,ts 
 	from sysuser where dr=0`
 	rows, err := db.Query(sqlStr)
 	if err != nil {
@@ -464,8 +599,8 @@ func GetUsers() (users []User, resStatus i18n.ResKey, err error) {
 		var user User
 		err = rows.Scan(&user.ID, &user.Code, &user.Name, &user.Mobile, &user.Email,
 			&user.Avatar.ID, &user.IsOperator, &user.Position.ID, &user.Dept.ID, &user.Description,
-			&user.Gender, &user.Status, &user.Locked, &user.SystemFlag, &user.CreateDate,
-			&user.Creator.ID, &user.ModifyDate, &user.Modifier.ID, &user.Dr, &user.Ts)
+			&user.Gender, &user.Status, &user.Locked, &user.SystemFlag, &user.TOTPEnabled,
+			&user.AuthSource, &user.MustChangePwd, &user.CreateDate, &user.Creator.ID, &user.ModifyDate, &user.Modifier.ID, &user.Dr, &user.Ts)
 		if err != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("GetUsers row.Next() failed", zap.Error(err))
@@ -523,7 +658,7 @@ func GetUsers() (users []User, resStatus i18n.ResKey, err error) {
 }
 
 // Delete user
-func (user *User) Delete() (resStatus i18n.ResKey, err error) {
+func (user *User) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check if the user id referenced.
 	resStatus, err = user.CheckIsUsed()
@@ -531,35 +666,38 @@ func (user *User) Delete() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	// Update the sysuser table with a deletion flag.
-	sqlStr := `update sysuser set dr=1,ts=current_timestamp,modifierid=$1,modifytime=current_timestamp 
-	where id = $2 and ts=$3 and dr=0`
-	res, err := db.Exec(sqlStr, user.Modifier.ID, user.ID, user.Ts)
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("User.delete db.Exec failed", zap.Error(err))
-		return
-	}
-	// Check the number of rows affected by the SQL update operation.
-	delNum, err := res.RowsAffected()
-	if err != nil {
-		resStatus = i18n.StatusInternalError
-		zap.L().Error("DeleteUsers Code"+user.Code+" res.RowsAffected() failed", zap.Error(err))
-		return
-	}
-	// If the update operation affects equals zero,
-	// it indicates that another user has already updated that row.
-	if delNum == 0 {
-		resStatus = i18n.StatusOtherEdit
-		zap.L().Info("DeleteUsers Code" + user.Code + " failed,the doc has been refreshed")
+	return writeAudited(actor, pub.User, AuditActionDelete, &user.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update sysuser set dr=1,ts=current_timestamp,modifierid=$1,modifytime=current_timestamp 
+		where id = $2 and ts=$3 and dr=0`
+		res, err := tx.Exec(sqlStr, user.Modifier.ID, user.ID, user.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("User.delete tx.Exec failed", zap.Error(err))
+			return
+		}
+		// Check the number of rows affected by the SQL update operation.
+		delNum, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("DeleteUsers Code"+user.Code+" res.RowsAffected() failed", zap.Error(err))
+			return
+		}
+		// If the update operation affects equals zero,
+		// it indicates that another user has already updated that row.
+		if delNum == 0 {
+			resStatus = i18n.StatusOtherEdit
+			zap.L().Info("DeleteUsers Code" + user.Code + " failed,the doc has been refreshed")
+			return
+		}
+		// Delete from local cache
+		user.DelFromLocalCache()
 		return
-	}
-	// Delete from local cache
-	user.DelFromLocalCache()
-	return
+	})
 }
 
 // Batch delete users
-func DeleteUsers(users *[]User, modifyUserID int32) (resStatus i18n.ResKey, err error) {
+func DeleteUsers(users *[]User, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	// Begin a database transaction.
 	tx, err := db.Begin()
 	if err != nil {
@@ -568,6 +706,17 @@ func DeleteUsers(users *[]User, modifyUserID int32) (resStatus i18n.ResKey, err
 		return
 	}
 	defer tx.Commit()
+	// Lock the users and take their state for the audit trail
+	ids := make([]int32, 0, len(*users))
+	for _, item := range *users {
+		ids = append(ids, item.ID)
+	}
+	at, err := beginAudit(tx, actor, pub.User, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Pre-Processing for update the sysuser table deletion flag.
 	sqlStr := "update sysuser set dr = 1,ts = current_timestamp,modifierid=$1,modifytime=current_timestamp where id = $2 and ts=$3 and dr=0"
 	stmt, err := tx.Prepare(sqlStr)
@@ -586,7 +735,7 @@ func DeleteUsers(users *[]User, modifyUserID int32) (resStatus i18n.ResKey, err
 			return
 		}
 		// Write data
-		res, err := stmt.Exec(modifyUserID, u.ID, u.Ts)
+		res, err := stmt.Exec(actor.UserID, u.ID, u.Ts)
 		if err != nil {
 			zap.L().Error("DeleteUsers Code:"+u.Code+"delete failed", zap.Error(err))
 			tx.Rollback()
@@ -611,6 +760,12 @@ func DeleteUsers(users *[]User, modifyUserID int32) (resStatus i18n.ResKey, err
 		u.DelFromLocalCache()
 	}
 
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		tx.Rollback()
+		return i18n.StatusInternalError, err
+	}
 	return i18n.StatusOK, nil
 }
 
@@ -973,6 +1128,16 @@ func (user *User) CheckIsUsed() (resStatus i18n.ResKey, err error) {
 			SqlStr:         "select count(id) from executionorder_review where dr = 0 and creatorid=$1",
 			UsedReturnCode: i18n.StatusEOReviewUsed,
 		},
+		{
+			Description:    "Referenced by Work Order schedule executor",
+			SqlStr:         "select count(id) from woschedule where dr = 0 and executorid=$1",
+			UsedReturnCode: i18n.StatusWOScheduleUsed,
+		},
+		{
+			Description:    "Referenced by Work Order schedule creator",
+			SqlStr:         "select count(id) from woschedule where dr = 0 and creatorid=$1",
+			UsedReturnCode: i18n.StatusWOScheduleUsed,
+		},
 	}
 
 	// Check item by item
@@ -1000,9 +1165,9 @@ func (pcp *ParamChangePwd) ChangePassword() (resStatus i18n.ResKey, err error) {
 		return i18n.StatusPasswordDisaccord, nil
 	}
 	// Check if the old password is correct
-	var oldPassword string
-	sqlStr := "select password from sysuser where id=$1"
-	err = db.QueryRow(sqlStr, pcp.UserID).Scan(&oldPassword)
+	var oldPassword, authSource string
+	sqlStr := "select password,authsource from sysuser where id=$1"
+	err = db.QueryRow(sqlStr, pcp.UserID).Scan(&oldPassword, &authSource)
 	if err != nil && err != sql.ErrNoRows {
 		zap.L().Error("ParmChangePwd.ChangePassword db.QueryRow failed:", zap.Error(err))
 		resStatus = i18n.StatusErrorUnknow
@@ -1013,20 +1178,17 @@ func (pcp *ParamChangePwd) ChangePassword() (resStatus i18n.ResKey, err error) {
 		resStatus = i18n.StatusUserNotExist
 		return
 	}
-	if oldPassword != encryptPassword(pcp.Password) {
+	// The password of a directory user is changed in the directory
+	if authSource != AuthSourceLocal {
+		resStatus = i18n.StatusPasswordManagedExternally
+		return
+	}
+	if match, _ := checkPassword(pcp.Password, oldPassword); !match {
 		zap.L().Info("ParmChangePwd.ChangePassword invalid password.")
 		resStatus = i18n.StatusInvalidPassword
 		return
 	}
 
-	// change password
-	sqlStr = "update sysuser set password=$1 where id=$2"
-	newPwd := encryptPassword(pcp.NewPassword)
-	_, err = db.Exec(sqlStr, newPwd, pcp.UserID)
-	if err != nil {
-		zap.L().Error("ChangePassword update exec failed:", zap.Error(err))
-		resStatus = i18n.StatusInternalError
-		return
-	}
-	return
+	// Change password, sessions started with the old password can no longer be refreshed
+	return setUserPassword(pcp.UserID, pcp.NewPassword)
 }
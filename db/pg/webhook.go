@@ -0,0 +1,744 @@
+package pg
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/filter"
+	"sccsmsserver/pub"
+	"sccsmsserver/setting"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// Webhook status
+const (
+	WebhookEnabled  int16 = 0
+	WebhookDisabled int16 = 1
+)
+
+// Webhook delivery status
+const (
+	WebhookPending   int16 = 0
+	WebhookDelivered int16 = 1
+	WebhookFailed    int16 = 2
+)
+
+// Headers of a webhook request.
+// The signature is "sha256=" followed by the hex HMAC-SHA256 of the request body, keyed with the webhook secret.
+const (
+	WebhookEventHeader     = "X-Sccsms-Event"
+	WebhookDeliveryHeader  = "X-Sccsms-Delivery"
+	WebhookSignatureHeader = "X-Sccsms-Signature"
+)
+
+// Matches every voucher type or every action in an event filter
+const webhookWildcard = "*"
+
+// Bytes of a generated signing secret
+const webhookSecretBytes = 32
+
+// Default requests of a delivery before it is given up
+const defaultWebhookAttempts = 8
+
+// Default seconds a webhook request waits for the response
+const defaultWebhookTimeout = 10
+
+// Longest delay between two requests of a delivery, in minutes
+const maxWebhookBackoff = 24 * 60
+
+// Deliveries sent by each run of the job
+const webhookBatchSize = 100
+
+// Error of a webhook request to an address webhooks may not post to
+var errWebhookAddressDenied = errors.New("webhook: the address is private, loopback or link-local")
+
+// Vouchers whose lifecycle events are delivered to the webhooks
+var webhookVouchers = []pub.DataType{pub.WO, pub.EO, pub.IRF, pub.TR, pub.PQ, pub.PPEIF}
+
+// Voucher lifecycle actions
+var webhookActions = []string{AuditActionAdd, AuditActionEdit, AuditActionDelete, AuditActionConfirm, AuditActionUnConfirm}
+
+// Webhook subscription.
+// Events are "<voucher type>.<action>" filters, e.g. "ppeif.confirm", "irf.*", "*.delete" or "*".
+type Webhook struct {
+	ID          int32    `db:"id" json:"id"`
+	Name        string   `db:"name" json:"name" binding:"required"`
+	URL         string   `db:"url" json:"url" binding:"required"`
+	Events      []string `db:"events" json:"events"`
+	Description string   `db:"description" json:"description"`
+	Status      int16    `db:"status" json:"status"`
+	// Signing secret, generated when it is empty on add and kept when it is empty on edit.
+	// It is only returned by the add and edit that set it.
+	Secret     string    `db:"secret" json:"secret,omitempty"`
+	CreateDate time.Time `db:"createtime" json:"createDate"`
+	Creator    Person    `db:"creatorid" json:"creator"`
+	ModifyDate time.Time `db:"modifytime" json:"modifyDate"`
+	Modifier   Person    `db:"modifierid" json:"modifier"`
+	Dr         int16     `db:"dr" json:"dr"`
+	Ts         time.Time `db:"ts" json:"ts"`
+}
+
+// Delivery of a voucher event to a webhook
+type WebhookDelivery struct {
+	ID           int32           `db:"id" json:"id"`
+	WebhookID    int32           `db:"webhookid" json:"webhookID"`
+	WebhookName  string          `json:"webhookName"`
+	Event        string          `db:"event" json:"event"`
+	VoucherType  pub.DataType    `db:"vouchertype" json:"voucherType"`
+	VoucherID    int32           `db:"voucherid" json:"voucherID"`
+	BillNumber   string          `db:"billnumber" json:"billNumber"`
+	Payload      json.RawMessage `db:"payload" json:"payload"`
+	Status       int16           `db:"status" json:"status"`
+	Attempts     int32           `db:"attempts" json:"attempts"`
+	NextAttempt  time.Time       `db:"nextattempt" json:"nextAttempt"`
+	ResponseCode int32           `db:"responsecode" json:"responseCode"`
+	LastError    string          `db:"lasterror" json:"lastError"`
+	RedeliveryOf int32           `db:"redeliveryof" json:"redeliveryOf"` // Delivery this one redelivers, 0 for the first delivery
+	CreateDate   time.Time       `db:"createtime" json:"createDate"`
+	DeliveryDate time.Time       `db:"deliverytime" json:"deliveryDate"`
+}
+
+// Webhook delivery pagination
+type WebhookDeliveriesPaging struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	Count      int32             `json:"count"`
+	Page       int32             `json:"page"`
+	PerPage    int32             `json:"perPage"`
+}
+
+// Webhook delivery query fields
+var webhookDeliveryFields = filter.Fields{
+	"webhookID":   {Column: "d.webhookid", Type: filter.Number},
+	"event":       {Column: "d.event", Type: filter.String},
+	"voucherType": {Column: "d.vouchertype", Type: filter.String},
+	"voucherID":   {Column: "d.voucherid", Type: filter.Number},
+	"billNumber":  {Column: "d.billnumber", Type: filter.String},
+	"status":      {Column: "d.status", Type: filter.Number},
+	"createDate":  {Column: "d.createtime", Type: filter.Time},
+}
+
+// Request body of a webhook delivery
+type webhookPayload struct {
+	Event       string                              `json:"event"`
+	VoucherType pub.DataType                        `json:"voucherType"`
+	Action      string                              `json:"action"`
+	VoucherID   int32                               `json:"voucherID"`
+	BillNumber  string                              `json:"billNumber"`
+	OccurTime   time.Time                           `json:"occurTime"`
+	Voucher     map[string]interface{}              `json:"voucher"` // Header row after the action
+	Details     map[string][]map[string]interface{} `json:"details"` // Rows of the detail tables after the action
+}
+
+// Delivery due to be sent
+type dueWebhookDelivery struct {
+	ID       int32
+	Event    string
+	Payload  []byte
+	Attempts int
+	URL      string
+	Secret   string
+}
+
+// Webhook event name of the voucher action
+func webhookEvent(voucherType pub.DataType, action string) string {
+	return string(voucherType) + "." + action
+}
+
+// Check whether the voucher lifecycle events are delivered to the webhooks
+func isWebhookVoucher(voucherType pub.DataType) bool {
+	for _, v := range webhookVouchers {
+		if v == voucherType {
+			return true
+		}
+	}
+	return false
+}
+
+// Check an event filter of a webhook
+func validWebhookEvent(event string) bool {
+	if event == webhookWildcard {
+		return true
+	}
+	voucherType, action, ok := strings.Cut(event, ".")
+	if !ok {
+		return false
+	}
+	if voucherType != webhookWildcard && !isWebhookVoucher(pub.DataType(voucherType)) {
+		return false
+	}
+	return action == webhookWildcard || containsString(webhookActions, action)
+}
+
+// Generate a new signing secret
+func generateWebhookSecret() (secret string, err error) {
+	b := make([]byte, webhookSecretBytes)
+	_, err = rand.Read(b)
+	if err != nil {
+		zap.L().Error("generateWebhookSecret rand.Read failed", zap.Error(err))
+		return
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Signature of the request body
+func signWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Get webhook list.
+// The signing secrets are not returned.
+func GetWebhooks() (whs []Webhook, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	whs = make([]Webhook, 0)
+	sqlStr := `select id,name,url,events,description,status,
+	createtime,creatorid,modifytime,modifierid,dr,ts
+	from syswebhook
+	where dr=0 order by id`
+	rows, err := db.Query(sqlStr)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetWebhooks db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var wh Webhook
+		err = rows.Scan(&wh.ID, &wh.Name, &wh.URL, pq.Array(&wh.Events), &wh.Description, &wh.Status,
+			&wh.CreateDate, &wh.Creator.ID, &wh.ModifyDate, &wh.Modifier.ID, &wh.Dr, &wh.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("GetWebhooks rows.Scan failed", zap.Error(err))
+			return
+		}
+		whs = append(whs, wh)
+	}
+	for i := range whs {
+		resStatus, err = whs[i].fillDetail()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Get the creator and modifier of the webhook
+func (wh *Webhook) fillDetail() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	if wh.Creator.ID > 0 {
+		resStatus, err = wh.Creator.GetPersonInfoByID()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	if wh.Modifier.ID > 0 {
+		resStatus, err = wh.Modifier.GetPersonInfoByID()
+	}
+	return
+}
+
+// Check the webhook before it is written
+func (wh *Webhook) check() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	if wh.Status != WebhookEnabled && wh.Status != WebhookDisabled {
+		resStatus = i18n.CodeInvalidParm
+		return
+	}
+	u, errParse := url.Parse(wh.URL)
+	if errParse != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		resStatus = i18n.StatusWebhookInvalid
+		return
+	}
+	if !webhookHostAllowed(u.Hostname()) {
+		resStatus = i18n.StatusWebhookAddressDenied
+		return
+	}
+	if len(wh.Events) == 0 {
+		resStatus = i18n.StatusWebhookInvalid
+		return
+	}
+	for _, event := range wh.Events {
+		if !validWebhookEvent(event) {
+			resStatus = i18n.StatusWebhookInvalid
+			return
+		}
+	}
+	var number int32
+	err = db.QueryRow("select count(id) from syswebhook where name=$1 and id<>$2 and dr=0", wh.Name, wh.ID).Scan(&number)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("Webhook.check db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if number > 0 {
+		resStatus = i18n.StatusWebhookNameExist
+	}
+	return
+}
+
+// Check whether webhooks may post to the address.
+// Private, loopback, link-local (e.g. the cloud metadata service 169.254.169.254), unspecified and multicast
+// addresses are refused, so webhooks can't reach the internal network of the server.
+func webhookAddressAllowed(ip net.IP) bool {
+	return !(ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// Check whether webhooks may post to the host, by its IP or the addresses it resolves to.
+// A host that doesn't resolve yet is accepted, the address is checked again when a request is sent.
+func webhookHostAllowed(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return webhookAddressAllowed(ip)
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return false
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		zap.L().Warn("webhookHostAllowed net.LookupIP failed", zap.String("host", host), zap.Error(err))
+		return true
+	}
+	for _, ip := range ips {
+		if !webhookAddressAllowed(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// HTTP client of the webhook requests.
+// The address is checked when the connection is made, after the host is resolved, so a DNS change can't
+// point a webhook to the internal network. Redirects and proxies aren't followed for the same reason,
+// a redirect response fails the delivery.
+func newWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !webhookAddressAllowed(ip) {
+				return errWebhookAddressDenied
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Add webhook.
+// A generated secret is returned once in Secret, it can't be read again.
+func (wh *Webhook) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = wh.check()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	if wh.Secret == "" {
+		wh.Secret, err = generateWebhookSecret()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			return
+		}
+	}
+	return writeAudited(actor, pub.Webhook, AuditActionAdd, &wh.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `insert into syswebhook(name,url,secret,events,description,status,creatorid)
+		values($1,$2,$3,$4,$5,$6,$7)
+		returning id,createtime,ts`
+		err = tx.QueryRow(sqlStr, wh.Name, wh.URL, wh.Secret, pq.Array(wh.Events), wh.Description, wh.Status,
+			wh.Creator.ID).Scan(&wh.ID, &wh.CreateDate, &wh.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("Webhook.Add tx.QueryRow failed", zap.Error(err))
+			return
+		}
+		return
+	})
+}
+
+// Modify webhook.
+// The secret is kept when Secret is empty.
+func (wh *Webhook) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = wh.check()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return writeAudited(actor, pub.Webhook, AuditActionEdit, &wh.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update syswebhook set name=$1,url=$2,secret=case when $3::varchar='' then secret else $3::varchar end,
+		events=$4,description=$5,status=$6,modifierid=$7,modifytime=current_timestamp,ts=current_timestamp
+		where id=$8 and ts=$9 and dr=0`
+		res, err := tx.Exec(sqlStr, wh.Name, wh.URL, wh.Secret, pq.Array(wh.Events), wh.Description, wh.Status,
+			wh.Modifier.ID, wh.ID, wh.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("Webhook.Edit tx.Exec failed", zap.Error(err))
+			return
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("Webhook.Edit res.RowsAffected failed", zap.Error(err))
+			return
+		}
+		// Someone else has already updated the data
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+		}
+		return
+	})
+}
+
+// Delete webhook.
+// Its pending deliveries are given up.
+func (wh *Webhook) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("Webhook.Delete db.Begin failed", zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.Webhook, AuditActionDelete, wh.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
+	sqlStr := `update syswebhook set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp
+	where id=$2 and ts=$3 and dr=0`
+	res, err := tx.Exec(sqlStr, wh.Modifier.ID, wh.ID, wh.Ts)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("Webhook.Delete tx.Exec failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("Webhook.Delete res.RowsAffected failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	// Someone else has already updated the data
+	if affected < 1 {
+		resStatus = i18n.StatusOtherEdit
+		tx.Rollback()
+		return
+	}
+	sqlStr = `update syswebhookdelivery set status=$1,lasterror='webhook deleted',ts=current_timestamp
+	where webhookid=$2 and status=$3`
+	_, err = tx.Exec(sqlStr, WebhookFailed, wh.ID, WebhookPending)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("Webhook.Delete tx.Exec deliveries failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	// Write the audit trail
+	err = at.write(wh.ID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
+	return
+}
+
+// Queue the deliveries of a voucher action to the enabled webhooks subscribed to its event,
+// in the transaction of the action.
+// s is the state of the voucher after the action.
+func queueWebhooks(tx *sql.Tx, voucherType pub.DataType, action string, voucherID int32, s *auditSnapshot) (err error) {
+	if !isWebhookVoucher(voucherType) || !containsString(webhookActions, action) || voucherID <= 0 {
+		return
+	}
+	event := webhookEvent(voucherType, action)
+	p := webhookPayload{
+		Event:       event,
+		VoucherType: voucherType,
+		Action:      action,
+		VoucherID:   voucherID,
+		OccurTime:   time.Now(),
+		Voucher:     s.Row,
+		Details:     s.Details,
+	}
+	if column := auditEntities[voucherType].NumberColumn; column != "" && s.Row[column] != nil {
+		p.BillNumber = fmt.Sprint(s.Row[column])
+	}
+	payload, err := json.Marshal(p)
+	if err != nil {
+		zap.L().Error("queueWebhooks json.Marshal failed", zap.String("event", event), zap.Error(err))
+		return
+	}
+	// Filters matching the event
+	filters := []string{
+		webhookWildcard,
+		event,
+		webhookEvent(voucherType, webhookWildcard),
+		webhookEvent(webhookWildcard, action),
+		webhookEvent(webhookWildcard, webhookWildcard),
+	}
+	sqlStr := `insert into syswebhookdelivery(webhookid,event,vouchertype,voucherid,billnumber,payload)
+	select id,$1,$2,$3,$4,$5 from syswebhook
+	where status=$6 and dr=0 and events && $7`
+	_, err = tx.Exec(sqlStr, event, voucherType, voucherID, p.BillNumber, string(payload), WebhookEnabled, pq.Array(filters))
+	if err != nil {
+		zap.L().Error("queueWebhooks tx.Exec failed", zap.String("event", event), zap.Int32("voucherID", voucherID), zap.Error(err))
+	}
+	return
+}
+
+// Background job: send the webhook deliveries that are due.
+// A failed delivery is retried with an exponential backoff until the attempts run out.
+// Deliveries of disabled webhooks wait until the webhook is enabled again.
+func sendWebhookDeliveries() (err error) {
+	maxAttempts := defaultWebhookAttempts
+	timeout := defaultWebhookTimeout
+	if cfg := setting.Conf.WebhookConfig; cfg != nil {
+		if cfg.MaxAttempts > 0 {
+			maxAttempts = cfg.MaxAttempts
+		}
+		if cfg.Timeout > 0 {
+			timeout = cfg.Timeout
+		}
+	}
+	deliveries, err := getDueWebhookDeliveries()
+	if err != nil {
+		return
+	}
+	client := newWebhookClient(time.Duration(timeout) * time.Second)
+	defer client.CloseIdleConnections()
+	deliveredSql := `update syswebhookdelivery set status=$2,attempts=attempts+1,responsecode=$3,lasterror='',
+	deliverytime=current_timestamp,ts=current_timestamp
+	where id=$1`
+	failedSql := `update syswebhookdelivery set status=$2,attempts=attempts+1,responsecode=$3,lasterror=$4,
+	nextattempt=current_timestamp + make_interval(mins => $5),ts=current_timestamp
+	where id=$1`
+	for _, d := range deliveries {
+		code, errSend := d.send(client)
+		if errSend == nil {
+			_, err = db.Exec(deliveredSql, d.ID, WebhookDelivered, code)
+		} else {
+			attempts := d.Attempts + 1
+			status := WebhookPending
+			if attempts >= maxAttempts {
+				status = WebhookFailed
+			}
+			zap.L().Warn("sendWebhookDeliveries send failed", zap.Int32("id", d.ID), zap.Int("attempts", attempts), zap.Error(errSend))
+			lastError := errSend.Error()
+			if len(lastError) > 1024 {
+				lastError = lastError[:1024]
+			}
+			// 1, 2, 4, 8... minutes
+			backoff := int(math.Min(math.Pow(2, float64(attempts-1)), maxWebhookBackoff))
+			_, err = db.Exec(failedSql, d.ID, status, code, lastError, backoff)
+		}
+		if err != nil {
+			zap.L().Error("sendWebhookDeliveries db.Exec failed", zap.Int32("id", d.ID), zap.Error(err))
+			return
+		}
+	}
+	return
+}
+
+// Get the deliveries of the enabled webhooks that are due
+func getDueWebhookDeliveries() (deliveries []dueWebhookDelivery, err error) {
+	sqlStr := `select d.id,d.event,d.payload,d.attempts,w.url,w.secret
+	from syswebhookdelivery as d
+	inner join syswebhook as w on w.id = d.webhookid
+	where d.status=$1 and d.nextattempt <= current_timestamp and w.status=$2 and w.dr=0
+	order by d.id limit $3`
+	rows, err := db.Query(sqlStr, WebhookPending, WebhookEnabled, webhookBatchSize)
+	if err != nil {
+		zap.L().Error("getDueWebhookDeliveries db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d dueWebhookDelivery
+		var payload string
+		err = rows.Scan(&d.ID, &d.Event, &payload, &d.Attempts, &d.URL, &d.Secret)
+		if err != nil {
+			zap.L().Error("getDueWebhookDeliveries rows.Scan failed", zap.Error(err))
+			return
+		}
+		d.Payload = []byte(payload)
+		deliveries = append(deliveries, d)
+	}
+	return
+}
+
+// Post the delivery to its webhook.
+// Only a 2xx response is a successful delivery.
+func (d dueWebhookDelivery) send(client *http.Client) (code int, err error) {
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SCCSMS-Webhook/"+pub.DbVersion)
+	req.Header.Set(WebhookEventHeader, d.Event)
+	req.Header.Set(WebhookDeliveryHeader, strconv.Itoa(int(d.ID)))
+	req.Header.Set(WebhookSignatureHeader, signWebhookPayload(d.Secret, d.Payload))
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	code = resp.StatusCode
+	if code < 200 || code > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err = fmt.Errorf("HTTP %d: %s", code, strings.TrimSpace(string(body)))
+	}
+	return
+}
+
+// Get the webhook delivery log by pagination
+func GetWebhookDeliveriesPagination(con PagingQueryParams) (wdp WebhookDeliveriesPaging, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	wdp.Deliveries = make([]WebhookDelivery, 0)
+	whereSql, args, resStatus := compileFilter(con.Filter, webhookDeliveryFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
+	var build strings.Builder
+	// Assemble the SQL for checking
+	build.WriteString("select count(d.id) as rownumber from syswebhookdelivery as d where 1=1")
+	if whereSql != "" {
+		build.WriteString(" and (")
+		build.WriteString(whereSql)
+		build.WriteString(")")
+	}
+	err = db.QueryRow(build.String(), args...).Scan(&wdp.Count)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetWebhookDeliveriesPagination db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if wdp.Count == 0 {
+		resStatus = i18n.StatusResNoData
+		return
+	}
+	if wdp.Count > setting.Conf.PqConfig.MaxRecord {
+		resStatus = i18n.StatusOverRecord
+		return
+	}
+	// Recalculate pagination
+	if con.PerPage <= 0 || con.PerPage > wdp.Count {
+		con.Page = 0
+		con.PerPage = wdp.Count
+	} else {
+		var totalPage = int32(math.Ceil(float64(wdp.Count) / float64(con.PerPage)))
+		if (con.Page + 1) > totalPage {
+			con.Page = totalPage - 1
+		}
+	}
+	wdp.Page = con.Page
+	wdp.PerPage = con.PerPage
+	build.Reset()
+	// Assemble the SQL for data retrieve
+	build.WriteString(`select d.id,d.webhookid,coalesce(w.name,''),d.event,d.vouchertype,d.voucherid,d.billnumber,
+	d.payload,d.status,d.attempts,d.nextattempt,d.responsecode,d.lasterror,d.redeliveryof,d.createtime,d.deliverytime
+	from syswebhookdelivery as d
+	left join syswebhook as w on w.id = d.webhookid
+	where 1=1`)
+	if whereSql != "" {
+		build.WriteString(" and (")
+		build.WriteString(whereSql)
+		build.WriteString(")")
+	}
+	limitIndex := len(args) + 1
+	build.WriteString(fmt.Sprintf(" order by d.id desc limit $%d offset $%d", limitIndex, limitIndex+1))
+	args = append(args, con.PerPage, con.Page*con.PerPage)
+	rows, err := db.Query(build.String(), args...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetWebhookDeliveriesPagination db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var wd WebhookDelivery
+		var payload string
+		err = rows.Scan(&wd.ID, &wd.WebhookID, &wd.WebhookName, &wd.Event, &wd.VoucherType, &wd.VoucherID, &wd.BillNumber,
+			&payload, &wd.Status, &wd.Attempts, &wd.NextAttempt, &wd.ResponseCode, &wd.LastError, &wd.RedeliveryOf,
+			&wd.CreateDate, &wd.DeliveryDate)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("GetWebhookDeliveriesPagination rows.Scan failed", zap.Error(err))
+			return
+		}
+		wd.Payload = json.RawMessage(payload)
+		wdp.Deliveries = append(wdp.Deliveries, wd)
+	}
+	return
+}
+
+// Redeliver the delivery.
+// A new delivery with the same payload is queued, it is sent by the next run of the job.
+func (wd *WebhookDelivery) Redeliver() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	var webhookStatus int16
+	sqlStr := `select w.status from syswebhookdelivery as d
+	inner join syswebhook as w on w.id = d.webhookid
+	where d.id=$1 and w.dr=0`
+	err = db.QueryRow(sqlStr, wd.ID).Scan(&webhookStatus)
+	if err == sql.ErrNoRows {
+		resStatus = i18n.StatusDataDeleted
+		err = nil
+		return
+	}
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("WebhookDelivery.Redeliver db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if webhookStatus != WebhookEnabled {
+		resStatus = i18n.StatusWebhookDisabled
+		return
+	}
+	sqlStr = `insert into syswebhookdelivery(webhookid,event,vouchertype,voucherid,billnumber,payload,redeliveryof)
+	select webhookid,event,vouchertype,voucherid,billnumber,payload,id from syswebhookdelivery where id=$1
+	returning id,webhookid,event,vouchertype,voucherid,billnumber,status,attempts,nextattempt,redeliveryof,createtime`
+	err = db.QueryRow(sqlStr, wd.ID).Scan(&wd.ID, &wd.WebhookID, &wd.Event, &wd.VoucherType, &wd.VoucherID, &wd.BillNumber,
+		&wd.Status, &wd.Attempts, &wd.NextAttempt, &wd.RedeliveryOf, &wd.CreateDate)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("WebhookDelivery.Redeliver db.QueryRow insert failed", zap.Error(err))
+		return
+	}
+	return
+}
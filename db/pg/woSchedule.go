@@ -0,0 +1,411 @@
+package pg
+
+import (
+	"database/sql"
+	"errors"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/recurrence"
+	"sccsmsserver/pub"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// Work Order schedule frequencies
+const (
+	WOScheduleDaily   int16 = 1
+	WOScheduleWeekly  int16 = 2
+	WOScheduleMonthly int16 = 3
+	WOScheduleRRule   int16 = 4 // iCalendar RRULE
+)
+
+// Work Order schedule status
+const (
+	WOScheduleEnabled  int16 = 0
+	WOScheduleDisabled int16 = 1
+)
+
+// Maximum days a schedule generates its Work Orders ahead of time
+const woScheduleMaxLeadDays = 366
+
+// Recurring Work Order schedule.
+// The Work Orders of the schedule are generated by a background job LeadDays ahead of their work date,
+// each one with a single row for the construction site, template and executor of the schedule.
+type WOSchedule struct {
+	ID          int32            `db:"id" json:"id"`
+	Name        string           `db:"name" json:"name" binding:"required"`
+	Description string           `db:"description" json:"description"`
+	Department  SimpDept         `db:"deptid" json:"department"`
+	CSA         ConstructionSite `db:"csaid" json:"csa"`
+	EPT         EPT              `db:"eptid" json:"ept"`
+	Executor    Person           `db:"executorid" json:"executor"`
+	Frequency   int16            `db:"frequency" json:"frequency"`
+	Interval    int32            `db:"repeatinterval" json:"interval"` // Every Interval days, weeks or months
+	Weekdays    []int32          `db:"weekdays" json:"weekdays"`       // Weekly: 0 Sunday to 6 Saturday
+	MonthDay    int32            `db:"monthday" json:"monthDay"`       // Monthly: day of the month, -1 for the last day, 0 for the day of StartDate
+	RRule       string           `db:"rrule" json:"rrule"`             // RRULE of the schedules with the RRULE frequency, e.g. FREQ=MONTHLY;BYDAY=1MO
+	StartDate   time.Time        `db:"startdate" json:"startDate"`     // First possible work date
+	EndDate     time.Time        `db:"enddate" json:"endDate"`         // Last possible work date, the zero time (1970-01-01) for none
+	StartTime   string           `db:"starttime" json:"startTime"`     // Start time of the work, HH:MM
+	Duration    int32            `db:"duration" json:"duration"`       // Work duration in minutes
+	LeadDays    int32            `db:"leaddays" json:"leadDays"`       // Days the Work Orders are generated ahead of their work date
+	AutoConfirm int16            `db:"autoconfirm" json:"autoConfirm"` // 1 to confirm the generated Work Orders, they go through their approval flow if any
+	Status      int16            `db:"status" json:"status"`
+	LastDate    time.Time        `db:"lastdate" json:"lastDate"` // Work date of the last generated Work Order
+	CreateDate  time.Time        `db:"createtime" json:"createDate"`
+	Creator     Person           `db:"creatorid" json:"creator"`
+	ModifyDate  time.Time        `db:"modifytime" json:"modifyDate"`
+	Modifier    Person           `db:"modifierid" json:"modifier"`
+	Dr          int16            `db:"dr" json:"dr"`
+	Ts          time.Time        `db:"ts" json:"ts"`
+}
+
+const woScheduleColumns = `id,name,description,deptid,csaid,eptid,executorid,
+	frequency,repeatinterval,weekdays,monthday,rrule,startdate,enddate,starttime,duration,
+	leaddays,autoconfirm,status,lastdate,
+	createtime,creatorid,modifytime,modifierid,dr,ts`
+
+// Scan a woschedule row
+func (s *WOSchedule) scan(row interface{ Scan(...interface{}) error }) error {
+	return row.Scan(&s.ID, &s.Name, &s.Description, &s.Department.ID, &s.CSA.ID, &s.EPT.HID, &s.Executor.ID,
+		&s.Frequency, &s.Interval, pq.Array(&s.Weekdays), &s.MonthDay, &s.RRule, &s.StartDate, &s.EndDate, &s.StartTime, &s.Duration,
+		&s.LeadDays, &s.AutoConfirm, &s.Status, &s.LastDate,
+		&s.CreateDate, &s.Creator.ID, &s.ModifyDate, &s.Modifier.ID, &s.Dr, &s.Ts)
+}
+
+// Get Work Order schedule list
+func GetWOSchedules() (wss []WOSchedule, resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	wss = make([]WOSchedule, 0)
+	rows, err := db.Query("select " + woScheduleColumns + " from woschedule where dr=0 order by id")
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("GetWOSchedules db.Query failed", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ws WOSchedule
+		err = ws.scan(rows)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("GetWOSchedules rows.Scan failed", zap.Error(err))
+			return
+		}
+		wss = append(wss, ws)
+	}
+	for i := range wss {
+		resStatus, err = wss[i].fillDetail()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Get the department, construction site, template, executor, creator and modifier of the schedule
+func (s *WOSchedule) fillDetail() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	if s.Department.ID > 0 {
+		resStatus, err = s.Department.GetSimpDeptInfoByID()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	resStatus, err = s.CSA.GetInfoByID()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	resStatus, err = s.EPT.GetEPTHeaderByHid()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	resStatus, err = s.Executor.GetPersonInfoByID()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	if s.Creator.ID > 0 {
+		resStatus, err = s.Creator.GetPersonInfoByID()
+		if resStatus != i18n.StatusOK || err != nil {
+			return
+		}
+	}
+	if s.Modifier.ID > 0 {
+		resStatus, err = s.Modifier.GetPersonInfoByID()
+	}
+	return
+}
+
+// Recurrence rule of the schedule
+func (s *WOSchedule) rule() (r recurrence.Rule, err error) {
+	switch s.Frequency {
+	case WOScheduleDaily:
+		r = recurrence.Rule{Freq: recurrence.Daily, Interval: int(s.Interval)}
+	case WOScheduleWeekly:
+		r = recurrence.Rule{Freq: recurrence.Weekly, Interval: int(s.Interval)}
+		for _, wd := range s.Weekdays {
+			r.ByDay = append(r.ByDay, recurrence.WeekdayNum{Weekday: time.Weekday(wd)})
+		}
+	case WOScheduleMonthly:
+		r = recurrence.Rule{Freq: recurrence.Monthly, Interval: int(s.Interval)}
+		if s.MonthDay != 0 {
+			r.ByMonthDay = []int{int(s.MonthDay)}
+		}
+	case WOScheduleRRule:
+		return recurrence.Parse(s.RRule)
+	}
+	return r, r.Validate()
+}
+
+// Check the schedule before it is written
+func (s *WOSchedule) check() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	if s.Status != WOScheduleEnabled && s.Status != WOScheduleDisabled {
+		resStatus = i18n.CodeInvalidParm
+		return
+	}
+	_, err = s.rule()
+	if err != nil {
+		resStatus = i18n.StatusWOScheduleRuleInvalid
+		err = nil
+		return
+	}
+	_, errTime := time.Parse("15:04", s.StartTime)
+	if errTime != nil || s.StartDate.IsZero() || (s.EndDate.Unix() > 0 && s.EndDate.Before(s.StartDate)) ||
+		s.Duration < 0 || s.LeadDays < 0 || s.LeadDays > woScheduleMaxLeadDays {
+		resStatus = i18n.StatusWOScheduleInvalid
+		return
+	}
+	// The construction site, template and executor must be in use
+	var csaNumber, eptNumber, executorNumber int32
+	sqlStr := `select (select count(id) from csa where id=$1 and status=0 and dr=0),
+	(select count(id) from ept_h where id=$2 and status=0 and dr=0),
+	(select count(id) from sysuser where id=$3 and status=0 and dr=0)`
+	err = db.QueryRow(sqlStr, s.CSA.ID, s.EPT.HID, s.Executor.ID).Scan(&csaNumber, &eptNumber, &executorNumber)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("WOSchedule.check db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if csaNumber == 0 || eptNumber == 0 || executorNumber == 0 {
+		resStatus = i18n.StatusWOScheduleInvalid
+	}
+	return
+}
+
+// Add Work Order schedule
+func (s *WOSchedule) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = s.check()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return writeAudited(actor, pub.WOSchedule, AuditActionAdd, &s.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `insert into woschedule(name,description,deptid,csaid,eptid,executorid,
+		frequency,repeatinterval,weekdays,monthday,rrule,startdate,enddate,starttime,duration,
+		leaddays,autoconfirm,status,creatorid)
+		values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19)
+		returning id,createtime,ts`
+		err = tx.QueryRow(sqlStr, s.Name, s.Description, s.Department.ID, s.CSA.ID, s.EPT.HID, s.Executor.ID,
+			s.Frequency, s.Interval, pq.Array(s.Weekdays), s.MonthDay, s.RRule, s.StartDate, s.EndDate, s.StartTime, s.Duration,
+			s.LeadDays, s.AutoConfirm, s.Status, s.Creator.ID).Scan(&s.ID, &s.CreateDate, &s.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("WOSchedule.Add tx.QueryRow failed", zap.Error(err))
+		}
+		return
+	})
+}
+
+// Modify Work Order schedule.
+// The Work Orders already generated are kept, the new rule applies after the last generated work date.
+func (s *WOSchedule) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = s.check()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return writeAudited(actor, pub.WOSchedule, AuditActionEdit, &s.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update woschedule set name=$1,description=$2,deptid=$3,csaid=$4,eptid=$5,executorid=$6,
+		frequency=$7,repeatinterval=$8,weekdays=$9,monthday=$10,rrule=$11,startdate=$12,enddate=$13,starttime=$14,duration=$15,
+		leaddays=$16,autoconfirm=$17,status=$18,modifierid=$19,modifytime=current_timestamp,ts=current_timestamp
+		where id=$20 and ts=$21 and dr=0`
+		res, err := tx.Exec(sqlStr, s.Name, s.Description, s.Department.ID, s.CSA.ID, s.EPT.HID, s.Executor.ID,
+			s.Frequency, s.Interval, pq.Array(s.Weekdays), s.MonthDay, s.RRule, s.StartDate, s.EndDate, s.StartTime, s.Duration,
+			s.LeadDays, s.AutoConfirm, s.Status, s.Modifier.ID,
+			s.ID, s.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("WOSchedule.Edit tx.Exec failed", zap.Error(err))
+			return
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("WOSchedule.Edit res.RowsAffected failed", zap.Error(err))
+			return
+		}
+		// Someone else has already updated the data
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+		}
+		return
+	})
+}
+
+// Delete Work Order schedule, the Work Orders already generated are kept
+func (s *WOSchedule) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	return writeAudited(actor, pub.WOSchedule, AuditActionDelete, &s.ID, func(tx *sql.Tx) (resStatus i18n.ResKey, err error) {
+		resStatus = i18n.StatusOK
+		sqlStr := `update woschedule set dr=1,modifierid=$1,modifytime=current_timestamp,ts=current_timestamp
+		where id=$2 and ts=$3 and dr=0`
+		res, err := tx.Exec(sqlStr, s.Modifier.ID, s.ID, s.Ts)
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("WOSchedule.Delete tx.Exec failed", zap.Error(err))
+			return
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			resStatus = i18n.StatusInternalError
+			zap.L().Error("WOSchedule.Delete res.RowsAffected failed", zap.Error(err))
+			return
+		}
+		// Someone else has already updated the data
+		if affected < 1 {
+			resStatus = i18n.StatusOtherEdit
+		}
+		return
+	})
+}
+
+// Generate the Work Orders of the enabled schedules that are due.
+// Past work dates missed while no server was running are generated on the next run.
+func generateScheduledWOs() (err error) {
+	rows, err := db.Query("select "+woScheduleColumns+" from woschedule where status=$1 and dr=0 order by id",
+		WOScheduleEnabled)
+	if err != nil {
+		zap.L().Error("generateScheduledWOs db.Query failed", zap.Error(err))
+		return
+	}
+	var wss []WOSchedule
+	for rows.Next() {
+		var ws WOSchedule
+		err = ws.scan(rows)
+		if err != nil {
+			rows.Close()
+			zap.L().Error("generateScheduledWOs rows.Scan failed", zap.Error(err))
+			return
+		}
+		wss = append(wss, ws)
+	}
+	rows.Close()
+	now := time.Now()
+	for i := range wss {
+		// A failed schedule doesn't stop the others, it is tried again on the next run
+		_ = wss[i].generate(now)
+	}
+	return
+}
+
+// Generate the Work Orders of the schedule up to LeadDays after now.
+// The work dates start the day after the last generated one, or at the start date for a schedule that hasn't run yet,
+// work dates before the day the schedule was created aren't generated.
+func (s *WOSchedule) generate(now time.Time) (err error) {
+	r, err := s.rule()
+	if err != nil {
+		zap.L().Error("WOSchedule.generate rule failed", zap.Int32("scheduleID", s.ID), zap.Error(err))
+		return
+	}
+	from := localDate(s.StartDate)
+	if created := localDate(s.CreateDate); created.After(from) {
+		from = created
+	}
+	if next := localDate(s.LastDate).AddDate(0, 0, 1); s.LastDate.Unix() > 0 && next.After(from) {
+		from = next
+	}
+	to := now.AddDate(0, 0, int(s.LeadDays))
+	if s.EndDate.Unix() > 0 && s.EndDate.Before(to) {
+		to = s.EndDate
+	}
+	for _, workDate := range r.Between(localDate(s.StartDate), from, to) {
+		err = s.generateWO(workDate)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Generate the Work Order of the work date
+func (s *WOSchedule) generateWO(workDate time.Time) (err error) {
+	startTime, _ := time.Parse("15:04", s.StartTime)
+	rowStart := workDate.Add(time.Duration(startTime.Hour())*time.Hour + time.Duration(startTime.Minute())*time.Minute)
+	wo := WorkOrder{
+		BillDate:    time.Now(),
+		Department:  s.Department,
+		Description: s.Name,
+		WorkDate:    workDate,
+		Creator:     s.Creator,
+		Body: []WorkOrderRow{{
+			RowNumber:   1,
+			CSA:         s.CSA,
+			Executor:    s.Executor,
+			Description: s.Description,
+			EPT:         s.EPT,
+			StartTime:   rowStart,
+			EndTime:     rowStart.Add(time.Duration(s.Duration) * time.Minute),
+		}},
+	}
+	err = s.addWO(&wo)
+	if err != nil {
+		return
+	}
+	if s.AutoConfirm != 1 {
+		return
+	}
+	// The Work Order is kept when it can't be confirmed
+	resStatus, errConfirm := wo.Confirm(systemActor(s.Creator.ID))
+	if (resStatus != i18n.StatusOK && resStatus != i18n.StatusApprovalSubmitted) || errConfirm != nil {
+		zap.L().Error("WOSchedule.generateWO wo.Confirm failed", zap.Int32("scheduleID", s.ID),
+			zap.String("billNumber", wo.BillNumber), zap.String("status", string(resStatus)), zap.Error(errConfirm))
+	}
+	return
+}
+
+// Write the generated Work Order.
+// The Work Order and the last generated work date are written in one transaction, so a work date is generated once.
+func (s *WOSchedule) addWO(wo *WorkOrder) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		zap.L().Error("WOSchedule.addWO db.Begin failed", zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	resStatus, err := wo.add(tx, systemActor(s.Creator.ID))
+	if resStatus != i18n.StatusOK || err != nil {
+		zap.L().Error("WOSchedule.addWO wo.add failed", zap.Int32("scheduleID", s.ID),
+			zap.String("status", string(resStatus)), zap.Error(err))
+		if err == nil {
+			err = errors.New(string(resStatus))
+		}
+		tx.Rollback()
+		return
+	}
+	// The ts isn't changed, the schedule may be open for editing
+	_, err = tx.Exec("update woschedule set lastdate=$1 where id=$2", wo.WorkDate, s.ID)
+	if err != nil {
+		zap.L().Error("WOSchedule.addWO tx.Exec failed", zap.Error(err))
+		tx.Rollback()
+		return
+	}
+	s.LastDate = wo.WorkDate
+	return
+}
+
+// Midnight in the local time zone of the date of t
+func localDate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
@@ -1,7 +1,10 @@
 package pg
 
 import (
+	"database/sql"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/filter"
+	"sccsmsserver/pub"
 	"sccsmsserver/setting"
 	"strings"
 	"time"
@@ -18,6 +21,7 @@ type WorkOrder struct {
 	Description string         `db:"description" json:"description"`
 	Status      int16          `db:"status" json:"status"`
 	WorkDate    time.Time      `db:"workdate" json:"workDate"`
+	DraftEO     int16          `db:"drafteo" json:"draftEO"` // Execution Order drafts: 0 server default, 1 create, 2 don't create
 	Body        []WorkOrderRow `json:"body"`
 	CreateDate  time.Time      `db:"createtime" json:"createDate"`
 	Creator     Person         `db:"creatorid" json:"creator"`
@@ -58,26 +62,64 @@ type WorkOrderRow struct {
 	WorkDate     string           `json:"workDate"`
 }
 
+// Execution Order drafts of a Work Order
+const (
+	WODraftEODefault int16 = 0 // Server configuration
+	WODraftEOYes     int16 = 1
+	WODraftEONo      int16 = 2
+)
+
+// Filterable fields of the Work Order refer list
+var woReferFields = filter.Fields{
+	"billNumber":        {Column: "h.billnumber", Type: filter.String},
+	"billDate":          {Column: "h.billdate", Type: filter.Time},
+	"workDate":          {Column: "h.workdate", Type: filter.Time},
+	"departmentID":      {Column: "h.deptid", Type: filter.Number},
+	"headerDescription": {Column: "h.description", Type: filter.String},
+	"csaID":             {Column: "b.csaid", Type: filter.Number},
+	"executorID":        {Column: "b.executorid", Type: filter.Number},
+	"eptID":             {Column: "b.eptid", Type: filter.Number},
+	"eptCode":           {Column: "epth.code", Type: filter.String},
+	"eptName":           {Column: "epth.name", Type: filter.String},
+	"description":       {Column: "b.description", Type: filter.String},
+	"startTime":         {Column: "b.starttime", Type: filter.Time},
+	"endTime":           {Column: "b.endtime", Type: filter.Time},
+	"eoID":              {Column: "b.eoid", Type: filter.Number},
+	"eoNumber":          {Column: "b.eonumber", Type: filter.String},
+}
+
+// Work Order rows to be executed: the confirmed rows,
+// and the rows whose Execution Order is still a draft, the row's EOID points to the draft
+const woReferStatusSql = `(b.status=1 or (b.status=2 and exists(select 1 from executionorder_h as eo
+	where eo.id=b.eoid and eo.status=0 and eo.dr=0)))`
+
 // Get the list of Work Order to be executed
-func GetWORefer(queryString string) (wors []WorkOrderRow, resStatus i18n.ResKey, err error) {
+func GetWORefer(queryFilter filter.Group, ds DataScope) (wors []WorkOrderRow, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
+	// Restrict the query to the data scope of the user
+	queryFilter = ds.Restrict(queryFilter, "departmentID", "executorID")
 	wors = make([]WorkOrderRow, 0)
+	// Compile the query filter
+	whereSql, args, resStatus := compileFilter(queryFilter, woReferFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Concatenate the SQL for inspection.
 	build.WriteString(`select count(b.id) as rownumber
 	from workorder_b as b
 	left join workorder_h as h on b.hid = h.id
 	left join ept_h as epth on b.eptid = epth.id
-	where (b.dr=0 and h.dr=0 and b.status=1)`)
-	if queryString != "" {
+	where (b.dr=0 and h.dr=0 and ` + woReferStatusSql + `)`)
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 	// Check
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, args...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetWORefer db.QueryRow(checkSql) failed", zap.Error(err))
@@ -103,15 +145,15 @@ func GetWORefer(queryString string) (wors []WorkOrderRow, resStatus i18n.ResKey,
 	from workorder_b as b
 	left join workorder_h as h on b.hid = h.id
 	left join ept_h as epth on b.eptid = epth.id
-	where (b.dr=0 and h.dr=0 and b.status=1)`)
-	if queryString != "" {
+	where (b.dr=0 and h.dr=0 and ` + woReferStatusSql + `)`)
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	refSql := build.String()
 	// Get Work Order List
-	woRef, err := db.Query(refSql)
+	woRef, err := db.Query(refSql, args...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetWORefer db.Query failed", zap.Error(err))
@@ -184,10 +226,37 @@ func GetWORefer(queryString string) (wors []WorkOrderRow, resStatus i18n.ResKey,
 	return
 }
 
+// Filterable fields of the Work Order list
+var woListFields = filter.Fields{
+	"billNumber":     {Column: "workorder_h.billnumber", Type: filter.String},
+	"billDate":       {Column: "workorder_h.billdate", Type: filter.Time},
+	"workDate":       {Column: "workorder_h.workdate", Type: filter.Time},
+	"description":    {Column: "workorder_h.description", Type: filter.String},
+	"status":         {Column: "workorder_h.status", Type: filter.Number},
+	"departmentID":   {Column: "workorder_h.deptid", Type: filter.Number},
+	"departmentCode": {Column: "department.code", Type: filter.String},
+	"departmentName": {Column: "department.name", Type: filter.String},
+	"creatorID":      {Column: "workorder_h.creatorid", Type: filter.Number},
+	"creatorCode":    {Column: "creator.code", Type: filter.String},
+	"creatorName":    {Column: "creator.name", Type: filter.String},
+	"modifierID":     {Column: "workorder_h.modifierid", Type: filter.Number},
+	"modifierName":   {Column: "modifier.name", Type: filter.String},
+	"createDate":     {Column: "workorder_h.createtime", Type: filter.Time},
+	"confirmDate":    {Column: "workorder_h.confirmtime", Type: filter.Time},
+	"modifyDate":     {Column: "workorder_h.modifytime", Type: filter.Time},
+}
+
 // Get Work Order List
-func GetWOList(queryString string) (wos []WorkOrder, resStatus i18n.ResKey, err error) {
+func GetWOList(queryFilter filter.Group, ds DataScope) (wos []WorkOrder, resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
+	// Restrict the query to the data scope of the user
+	queryFilter = ds.Restrict(queryFilter, "departmentID", "creatorID")
 	wos = make([]WorkOrder, 0)
+	// Compile the query filter
+	whereSql, args, resStatus := compileFilter(queryFilter, woListFields, 1)
+	if resStatus != i18n.StatusOK {
+		return
+	}
 	var build strings.Builder
 	// Concatenate the SQL for inspection
 	build.WriteString(`select count(workorder_h.id) as rownumber
@@ -196,15 +265,15 @@ func GetWOList(queryString string) (wos []WorkOrder, resStatus i18n.ResKey, err
 	left join sysuser as creator on workorder_h.creatorid = creator.id
 	left join sysuser as modifier on workorder_h.modifierid = modifier.id
 	where (workorder_h.dr = 0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	checkSql := build.String()
 	// Check
 	var rowNumber int32
-	err = db.QueryRow(checkSql).Scan(&rowNumber)
+	err = db.QueryRow(checkSql, args...).Scan(&rowNumber)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetWOList db.QueryRow(checkSql) failed", zap.Error(err))
@@ -222,21 +291,22 @@ func GetWOList(queryString string) (wos []WorkOrder, resStatus i18n.ResKey, err
 	// Concatenate the SQL for data retrieval
 	build.WriteString(`select workorder_h.id,workorder_h.billnumber,workorder_h.billdate,workorder_h.deptid,workorder_h.description,
 	workorder_h.status,workorder_h.workdate,workorder_h.createtime,workorder_h.creatorid,workorder_h.confirmtime,
-	workorder_h.confirmerid,workorder_h.modifytime,workorder_h.modifierid,workorder_h.dr,workorder_h.ts
+	workorder_h.confirmerid,workorder_h.modifytime,workorder_h.modifierid,workorder_h.dr,workorder_h.ts,
+	workorder_h.drafteo
 	from workorder_h
 	left join department on workorder_h.deptid = department.id
 	left join sysuser as creator on workorder_h.creatorid = creator.id
 	left join sysuser as modifier on workorder_h.modifierid = modifier.id
 	where (workorder_h.dr = 0)`)
-	if queryString != "" {
+	if whereSql != "" {
 		build.WriteString(" and (")
-		build.WriteString(queryString)
+		build.WriteString(whereSql)
 		build.WriteString(")")
 	}
 	build.WriteString(`order by workorder_h.ts desc`)
 	headSql := build.String()
 	// Get Work Order List
-	headRows, err := db.Query(headSql)
+	headRows, err := db.Query(headSql, args...)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("GetWOList db.Query failed", zap.Error(err))
@@ -248,7 +318,8 @@ func GetWOList(queryString string) (wos []WorkOrder, resStatus i18n.ResKey, err
 		var wo WorkOrder
 		err = headRows.Scan(&wo.HID, &wo.BillNumber, &wo.BillDate, &wo.Department.ID, &wo.Description,
 			&wo.Status, &wo.WorkDate, &wo.CreateDate, &wo.Creator.ID, &wo.ConfirmDate,
-			&wo.Confirmer.ID, &wo.ModifyDate, &wo.Modifier.ID, &wo.Dr, &wo.Ts)
+			&wo.Confirmer.ID, &wo.ModifyDate, &wo.Modifier.ID, &wo.Dr, &wo.Ts,
+			&wo.DraftEO)
 		if err != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("GetWOList headRows.Next failed", zap.Error(err))
@@ -399,7 +470,7 @@ func (wo *WorkOrder) GetDetailByHID() (resStatus i18n.ResKey, err error) {
 }
 
 // Add Work Order
-func (wo *WorkOrder) Add() (resStatus i18n.ResKey, err error) {
+func (wo *WorkOrder) Add(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check the number of rows in the body,
 	// It cannot be zero
@@ -415,22 +486,34 @@ func (wo *WorkOrder) Add() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	resStatus, err = wo.add(tx, actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		tx.Rollback()
+	}
+	return
+}
+
+// Write a new Work Order and its audit trail in the transaction
+func (wo *WorkOrder) add(tx *sql.Tx, actor AuditActor) (resStatus i18n.ResKey, err error) {
+	at, err := beginAudit(tx, actor, pub.WO, AuditActionAdd)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		return
+	}
 	// Get the latest Serial Number
 	billNo, resStatus, err := GetLatestSerialNo(tx, "WO")
 	if resStatus != i18n.StatusOK || err != nil {
-		tx.Rollback()
 		return
 	}
 	wo.BillNumber = billNo
 	// Write the header content to the database
 	headSql := `insert into workorder_h(billnumber,billdate,deptid,description,status,
-	workdate,creatorid) values($1,$2,$3,$4,$5,$6,$7) returning id`
+	workdate,drafteo,creatorid) values($1,$2,$3,$4,$5,$6,$7,$8) returning id`
 	err = tx.QueryRow(headSql, wo.BillNumber, wo.BillDate, wo.Department.ID, wo.Description, wo.Status,
-		wo.WorkDate, wo.Creator.ID).Scan(&wo.HID)
+		wo.WorkDate, wo.DraftEO, wo.Creator.ID).Scan(&wo.HID)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
-		zap.L().Error("WorkOrder.Add tx.QueryRow Failed", zap.Error(err))
-		tx.Rollback()
+		zap.L().Error("WorkOrder.add tx.QueryRow Failed", zap.Error(err))
 		return
 	}
 	// Prepare Write the body content to the database
@@ -440,8 +523,7 @@ func (wo *WorkOrder) Add() (resStatus i18n.ResKey, err error) {
 	bodyStmt, err := tx.Prepare(bodySql)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
-		zap.L().Error("WorkOrder.Add tx.Prepare(bodySql) failed", zap.Error(err))
-		tx.Rollback()
+		zap.L().Error("WorkOrder.add tx.Prepare(bodySql) failed", zap.Error(err))
 		return
 	}
 	defer bodyStmt.Close()
@@ -451,16 +533,20 @@ func (wo *WorkOrder) Add() (resStatus i18n.ResKey, err error) {
 			row.EPT.HID, row.StartTime, row.EndTime, row.Status, wo.Creator.ID).Scan(&row.BID)
 		if err != nil {
 			resStatus = i18n.StatusInternalError
-			zap.L().Error("WorkOrder.Add bodyStmt.QueryRow falied", zap.Error(err))
-			tx.Rollback()
+			zap.L().Error("WorkOrder.add bodyStmt.QueryRow falied", zap.Error(err))
 			return
 		}
 	}
+	// Write the audit trail
+	err = at.write(wo.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+	}
 	return
 }
 
 // Edit the  Work Order content
-func (wo *WorkOrder) Edit() (resStatus i18n.ResKey, err error) {
+func (wo *WorkOrder) Edit(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Check the number of rows in the body, it cannot be zero
 	if len(wo.Body) == 0 {
@@ -472,6 +558,11 @@ func (wo *WorkOrder) Edit() (resStatus i18n.ResKey, err error) {
 		resStatus = i18n.StatusVoucherOnlyCreateEdit
 		return
 	}
+	// Vouchers waiting for approval can't be modified
+	resStatus, err = checkApprovalPending(pub.WO, wo.HID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 
 	// Begin a database transaction
 	tx, err := db.Begin()
@@ -481,13 +572,20 @@ func (wo *WorkOrder) Edit() (resStatus i18n.ResKey, err error) {
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.WO, AuditActionEdit, wo.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Update the Work Order header content in the database
 	editHeadSql := `update workorder_h set billdate=$1,deptid=$2,description=$3,status=$4,workdate=$5,
-	modifytime=current_timestamp,modifierid=$6,ts=current_timestamp
-	where id=$7 and dr=0 and status=0 and ts=$8`
+	drafteo=$6,modifytime=current_timestamp,modifierid=$7,ts=current_timestamp
+	where id=$8 and dr=0 and status=0 and ts=$9`
 	editHeadRes, err := tx.Exec(editHeadSql, wo.BillDate, wo.Department.ID, wo.Description, wo.Status, wo.WorkDate,
-		wo.Modifier.ID,
+		wo.DraftEO, wo.Modifier.ID,
 		wo.HID, wo.Ts)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
@@ -574,11 +672,17 @@ func (wo *WorkOrder) Edit() (resStatus i18n.ResKey, err error) {
 		}
 	}
 
+	// Write the audit trail
+	err = at.write(wo.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Delete Work Order
-func (wo *WorkOrder) Delete(modifyUserId int32) (resStatus i18n.ResKey, err error) {
+func (wo *WorkOrder) Delete(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Get Work Order details by HID
 	resStatus, err = wo.GetDetailByHID()
@@ -592,10 +696,15 @@ func (wo *WorkOrder) Delete(modifyUserId int32) (resStatus i18n.ResKey, err erro
 	}
 	// Check if the creator and the modifier are the same person
 	// only creator can  delete the voucher.
-	if wo.Creator.ID != modifyUserId {
+	if wo.Creator.ID != actor.UserID {
 		resStatus = i18n.StatusVoucherOnlyCreateEdit
 		return
 	}
+	// Vouchers waiting for approval can't be deleted
+	resStatus, err = checkApprovalPending(pub.WO, wo.HID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 	// Begin a database the transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -604,10 +713,17 @@ func (wo *WorkOrder) Delete(modifyUserId int32) (resStatus i18n.ResKey, err erro
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.WO, AuditActionDelete, wo.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 	// Update the header deletion flag in the workorder_h table
 	delHeadSql := `update workorder_h set dr=1,modifytime=current_timestamp,modifierid=$1,ts=current_timestamp 
 	where id=$2 and dr=0 and ts=$3`
-	delHeadRes, err := tx.Exec(delHeadSql, modifyUserId, wo.HID, wo.Ts)
+	delHeadRes, err := tx.Exec(delHeadSql, actor.UserID, wo.HID, wo.Ts)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("WorkOrder.Delete tx.Exec(delHeadSql) failed", zap.Error(err))
@@ -648,7 +764,7 @@ func (wo *WorkOrder) Delete(modifyUserId int32) (resStatus i18n.ResKey, err erro
 			tx.Rollback()
 			return
 		}
-		delRowRes, errDelRow := delRowStmt.Exec(modifyUserId, row.BID, row.Ts)
+		delRowRes, errDelRow := delRowStmt.Exec(actor.UserID, row.BID, row.Ts)
 		if errDelRow != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("WorkOrder.Delete delRowStmt.Exec() failed", zap.Error(errDelRow))
@@ -670,11 +786,17 @@ func (wo *WorkOrder) Delete(modifyUserId int32) (resStatus i18n.ResKey, err erro
 		}
 	}
 
+	// Write the audit trail
+	err = at.write(wo.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
 	return
 }
 
 // Batch Delete Work Order
-func DeleteWOs(wos *[]WorkOrder, modifyUserID int32) (resStatus i18n.ResKey, err error) {
+func DeleteWOs(wos *[]WorkOrder, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Begin a database transaction
 	tx, err := db.Begin()
@@ -684,6 +806,17 @@ func DeleteWOs(wos *[]WorkOrder, modifyUserID int32) (resStatus i18n.ResKey, err
 		return
 	}
 	defer tx.Commit()
+	// Lock the Work Orders and take their state for the audit trail
+	ids := make([]int32, 0, len(*wos))
+	for _, item := range *wos {
+		ids = append(ids, item.HID)
+	}
+	at, err := beginAudit(tx, actor, pub.WO, AuditActionDelete, ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// SQL statement preparation
 	// SQL for updating the header deletion flag
@@ -723,14 +856,20 @@ func DeleteWOs(wos *[]WorkOrder, modifyUserID int32) (resStatus i18n.ResKey, err
 			return
 		}
 		// Check the creator and the modifier are the same person
-		if wo.Creator.ID != modifyUserID {
+		if wo.Creator.ID != actor.UserID {
 			resStatus = i18n.StatusVoucherOnlyCreateEdit
 			tx.Rollback()
 			return
 		}
+		// Check the Work Order isn't waiting for approval
+		resStatus, err = checkApprovalPending(pub.WO, wo.HID)
+		if resStatus != i18n.StatusOK || err != nil {
+			tx.Rollback()
+			return
+		}
 
 		// Write the deletion flag into the workorder_h table
-		delHeadRes, errDelHead := delHeadStmt.Exec(modifyUserID, wo.HID, wo.Ts)
+		delHeadRes, errDelHead := delHeadStmt.Exec(actor.UserID, wo.HID, wo.Ts)
 		if errDelHead != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("DeleteWOs delHeadStmt.Exec failed", zap.Error(errDelHead))
@@ -759,7 +898,7 @@ func DeleteWOs(wos *[]WorkOrder, modifyUserID int32) (resStatus i18n.ResKey, err
 				tx.Rollback()
 				return
 			}
-			delRowRes, errDelRow := delRowStmt.Exec(modifyUserID, row.BID, row.Ts)
+			delRowRes, errDelRow := delRowStmt.Exec(actor.UserID, row.BID, row.Ts)
 			if errDelRow != nil {
 				resStatus = i18n.StatusInternalError
 				zap.L().Error("DeleteWOs delRowStmt.Exec() failed", zap.Error(errDelRow))
@@ -781,11 +920,69 @@ func DeleteWOs(wos *[]WorkOrder, modifyUserID int32) (resStatus i18n.ResKey, err
 			}
 		}
 	}
+	// Write the audit trail
+	err = at.write(ids...)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
+	return
+}
+
+// Confirm Work Order.
+// With an approval flow the Work Order is submitted for approval instead,
+// it is confirmed once the last approval step is approved.
+func (wo *WorkOrder) Confirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = submitForApproval(pub.WO, wo.HID, actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return wo.confirm(actor)
+}
+
+// Confirm Work Order without approval.
+// The Execution Order drafts of the rows are created once the Work Order is confirmed,
+// then the executors are notified.
+func (wo *WorkOrder) confirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = wo.writeConfirm(actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	wo.confirmed(actor)
+	return
+}
+
+// Create the Execution Order drafts and notify the executors of the committed confirmation
+func (wo *WorkOrder) confirmed(actor AuditActor) {
+	wo.draftEOs(actor)
+	// Push the assignments to the executors
+	pushRows(PushWO, pub.WO, wo.HID, `select b.id,b.rownumber,b.executorid,h.billnumber
+	from workorder_b as b
+	left join workorder_h as h on b.hid = h.id
+	where b.hid=$1 and b.dr=0`)
+	pushConfirmed(pub.WO, wo.HID, actor.UserID)
+}
+
+// Write the confirmation of the Work Order
+func (wo *WorkOrder) writeConfirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	// Begin a database transaction
+	tx, err := db.Begin()
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("WorkOrder.Confirm db.Begin failed", zap.Error(err))
+		return
+	}
+	defer tx.Commit()
+	resStatus, err = wo.writeConfirmTx(tx, actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		tx.Rollback()
+	}
 	return
 }
 
-// Confirm Work Order
-func (wo *WorkOrder) Confirm(confirmUserID int32) (resStatus i18n.ResKey, err error) {
+// Write the confirmation of the Work Order and its audit trail in the transaction
+func (wo *WorkOrder) writeConfirmTx(tx *sql.Tx, actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Get Work Order details
 	resStatus, err = wo.GetDetailByHID()
@@ -798,23 +995,20 @@ func (wo *WorkOrder) Confirm(confirmUserID int32) (resStatus i18n.ResKey, err er
 		resStatus = i18n.StatusVoucherNoFree
 		return
 	}
-	// Begin a database transaction
-	tx, err := db.Begin()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.WO, AuditActionConfirm, wo.HID)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
-		zap.L().Error("WorkOrder.Confirm db.Begin failed", zap.Error(err))
 		return
 	}
-	defer tx.Commit()
 
 	// Update the header confirmation flag
 	confirmHeadSql := `update workorder_h set status=1,confirmtime=current_timestamp,confirmerid=$1,ts=current_timestamp 
 	where id=$2 and dr=0 and status=0 and ts=$3`
-	headRes, err := tx.Exec(confirmHeadSql, confirmUserID, wo.HID, wo.Ts)
+	headRes, err := tx.Exec(confirmHeadSql, actor.UserID, wo.HID, wo.Ts)
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("WorKOrder.Confirm tx.Exec(confirmHeadSql) failed", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	// Check the number of rows affected by SQL statement
@@ -822,12 +1016,10 @@ func (wo *WorkOrder) Confirm(confirmUserID int32) (resStatus i18n.ResKey, err er
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("WorkOrder.Confirm headRes.RowsAffected failed", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	if confirmHeadNumber < 1 {
 		resStatus = i18n.StatusOtherEdit
-		tx.Rollback()
 		return
 	}
 
@@ -838,7 +1030,6 @@ func (wo *WorkOrder) Confirm(confirmUserID int32) (resStatus i18n.ResKey, err er
 	if err != nil {
 		resStatus = i18n.StatusInternalError
 		zap.L().Error("WorkOrder.Confirm tx.Prepare(confirmRowSql) failed", zap.Error(err))
-		tx.Rollback()
 		return
 	}
 	defer rowStmt.Close()
@@ -848,14 +1039,12 @@ func (wo *WorkOrder) Confirm(confirmUserID int32) (resStatus i18n.ResKey, err er
 		// Check the body row status
 		if row.Status != 0 {
 			resStatus = i18n.StatusVoucherNoFree
-			tx.Rollback()
 			return
 		}
-		confirmRowRes, errConfirmRow := rowStmt.Exec(confirmUserID, row.BID, row.Ts)
+		confirmRowRes, errConfirmRow := rowStmt.Exec(actor.UserID, row.BID, row.Ts)
 		if errConfirmRow != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("WorkOrder.Confirm rowStmt.Exec failed", zap.Error(errConfirmRow))
-			tx.Rollback()
 			return resStatus, errConfirmRow
 		}
 
@@ -863,21 +1052,137 @@ func (wo *WorkOrder) Confirm(confirmUserID int32) (resStatus i18n.ResKey, err er
 		if errConfirmRow != nil {
 			resStatus = i18n.StatusInternalError
 			zap.L().Error("WorkOrder.Confirm confirmRowRes.RowsAffected failed", zap.Error(errConfirmRow))
-			tx.Rollback()
 			return resStatus, errConfirmRow
 		}
 		if confirmRowNumber < 1 {
 			resStatus = i18n.StatusOtherEdit
-			tx.Rollback()
 			return
 		}
 	}
 
+	// Write the audit trail
+	err = at.write(wo.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+	}
 	return
 }
 
-// Unconfirm Work Order
-func (wo *WorkOrder) UnConfirm(confirmUserID int32) (resStatus i18n.ResKey, err error) {
+// Create the Execution Order drafts of the confirmed Work Order rows.
+// The drafts are created in the name of the executors, who find them through their Work Order refer list.
+// A row that can't be drafted is left to its executor.
+// The drafts are audited as added by the server on behalf of the confirmer.
+func (wo *WorkOrder) draftEOs(confirmer AuditActor) {
+	var billNumber string
+	var deptID int32
+	var draftEO int16
+	sqlStr := "select billnumber,deptid,drafteo from workorder_h where id=$1 and dr=0"
+	err := db.QueryRow(sqlStr, wo.HID).Scan(&billNumber, &deptID, &draftEO)
+	if err != nil {
+		zap.L().Error("WorkOrder.draftEOs db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if draftEO == WODraftEONo || (draftEO == WODraftEODefault && !draftEOByDefault()) {
+		return
+	}
+	// Get the rows as they are after the confirmation
+	wo.Body = nil
+	resStatus, err := wo.GetDetailByHID()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	for _, row := range wo.Body {
+		if row.Status != 1 || row.EOID > 0 {
+			continue
+		}
+		resStatus, err = row.draftEO(billNumber, deptID, systemActor(confirmer.UserID))
+		if resStatus != i18n.StatusOK || err != nil {
+			zap.L().Error("WorkOrder.draftEOs row.draftEO failed", zap.String("billNumber", billNumber),
+				zap.Int32("rowNumber", row.RowNumber), zap.String("status", string(resStatus)), zap.Error(err))
+		}
+	}
+}
+
+// Check whether Work Orders without their own choice get Execution Order drafts
+func draftEOByDefault() bool {
+	return setting.Conf.WorkOrderConfig != nil && setting.Conf.WorkOrderConfig.DraftEO
+}
+
+// Create the Execution Order draft of the Work Order row.
+// The body rows are the rows of the template, with their default values.
+func (wor *WorkOrderRow) draftEO(billNumber string, deptID int32, actor AuditActor) (resStatus i18n.ResKey, err error) {
+	ept := EPT{HID: wor.EPT.HID}
+	resStatus, err = ept.GetEPTHeaderByHid()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	eptRows, resStatus, err := getEPTBody(ept.HID)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	eo := ExecutionOrder{
+		BillDate:         time.Now(),
+		Department:       SimpDept{ID: deptID},
+		Description:      wor.Description,
+		SourceType:       "wo",
+		SourceBillNumber: billNumber,
+		SourceHid:        wor.HID,
+		SourceRowNumber:  wor.RowNumber,
+		SourceBid:        wor.BID,
+		SourceRowTs:      wor.Ts,
+		StartTime:        wor.StartTime,
+		EndTime:          wor.EndTime,
+		CSA:              wor.CSA,
+		Executor:         wor.Executor,
+		EPT:              ept,
+		AllowAddRow:      ept.AllowAddRow,
+		AllowDelRow:      ept.AllowDelRow,
+		Creator:          wor.Executor,
+	}
+	for _, er := range eptRows {
+		eor := ExecutionOrderRow{
+			RowNumber:          er.RowNumber,
+			EPA:                er.EP,
+			AllowDelRow:        er.AllowDelRow,
+			ExecutionValue:     er.DefaultValue,
+			ExecutionValueDisp: er.DefaultValueDisp,
+			Description:        er.Description,
+			EpaDescription:     er.EP.Description,
+			IsCheckError:       er.IsCheckError,
+			ErrorValue:         er.ErrorValue,
+			ErrorValueDisp:     er.ErrorValueDisp,
+			IsRequireFile:      er.IsRequireFile,
+			IsOnsitePhoto:      er.IsOnsitePhoto,
+			IsFromEPT:          1,
+			RiskLevel:          er.RiskLevel,
+		}
+		eo.Body = append(eo.Body, eor)
+	}
+	// The executor attaches the files and photos before the draft is confirmed
+	resStatus, err = eo.checkRows(false)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	// Default values can be issues
+	resStatus, err = eo.flagIssues()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return eo.add(actor)
+}
+
+// Unconfirm Work Order.
+// Its approval is canceled, it has to be approved again before it is confirmed.
+func (wo *WorkOrder) UnConfirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
+	resStatus, err = wo.unConfirm(actor)
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
+	return cancelApproval(pub.WO, wo.HID, actor.UserID)
+}
+
+// Unconfirm Work Order without touching its approval
+func (wo *WorkOrder) unConfirm(actor AuditActor) (resStatus i18n.ResKey, err error) {
 	resStatus = i18n.StatusOK
 	// Get the Work Order details
 	resStatus, err = wo.GetDetailByHID()
@@ -891,10 +1196,16 @@ func (wo *WorkOrder) UnConfirm(confirmUserID int32) (resStatus i18n.ResKey, err
 		return
 	}
 	// Check if the confirmer and the uncomfirmer are the same person
-	if wo.Confirmer.ID != confirmUserID {
+	if wo.Confirmer.ID != actor.UserID {
 		resStatus = i18n.StatusVoucherCancelConfirmSelf
 		return
 	}
+	// Rows with an unconfirmed Execution Order, such as the drafts created at the confirmation,
+	// keep the Work Order confirmed until the Execution Order is deleted
+	resStatus, err = wo.checkUnconfirmedEOs()
+	if resStatus != i18n.StatusOK || err != nil {
+		return
+	}
 	// Begin a database transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -903,6 +1214,13 @@ func (wo *WorkOrder) UnConfirm(confirmUserID int32) (resStatus i18n.ResKey, err
 		return
 	}
 	defer tx.Commit()
+	// Lock the record and take its state for the audit trail
+	at, err := beginAudit(tx, actor, pub.WO, AuditActionUnConfirm, wo.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+		return
+	}
 
 	// Update the header confirmation flag
 	confirmHeadSql := `update workorder_h set status=0,confirmerid=0,confirmtime=to_timestamp(0),ts=current_timestamp 
@@ -970,6 +1288,31 @@ func (wo *WorkOrder) UnConfirm(confirmUserID int32) (resStatus i18n.ResKey, err
 		}
 	}
 
+	// Write the audit trail
+	err = at.write(wo.HID)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		tx.Rollback()
+	}
+	return
+}
+
+// Check whether a row of the Work Order has an unconfirmed Execution Order
+func (wo *WorkOrder) checkUnconfirmedEOs() (resStatus i18n.ResKey, err error) {
+	resStatus = i18n.StatusOK
+	var number int32
+	sqlStr := `select count(b.id) from workorder_b as b
+	inner join executionorder_h as h on h.id = b.eoid
+	where b.hid=$1 and b.dr=0 and h.dr=0 and h.status=0`
+	err = db.QueryRow(sqlStr, wo.HID).Scan(&number)
+	if err != nil {
+		resStatus = i18n.StatusInternalError
+		zap.L().Error("WorkOrder.checkUnconfirmedEOs db.QueryRow failed", zap.Error(err))
+		return
+	}
+	if number > 0 {
+		resStatus = i18n.StatusWOEOUnconfirmed
+	}
 	return
 }
 
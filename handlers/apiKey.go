@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"sccsmsserver/db/pg"
+	"sccsmsserver/i18n"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Get API key list handler
+func GetAPIKeysHandler(c *gin.Context) {
+	aks, resStatus, _ := pg.GetAPIKeys()
+	ResponseWithMsg(c, resStatus, aks)
+}
+
+// Add API key handler
+func AddAPIKeyHandler(c *gin.Context) {
+	ak := new(pg.APIKey)
+	err := c.ShouldBind(ak)
+	if err != nil {
+		zap.L().Error("AddAPIKeyHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, ak)
+		return
+	}
+	ak.Creator.ID = operatorID
+	resStatus, _ = ak.Add(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, ak)
+}
+
+// Modify API key handler
+func EditAPIKeyHandler(c *gin.Context) {
+	ak := new(pg.APIKey)
+	err := c.ShouldBind(ak)
+	if err != nil {
+		zap.L().Error("EditAPIKeyHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, ak)
+		return
+	}
+	ak.Modifier.ID = operatorID
+	resStatus, _ = ak.Edit(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, ak)
+}
+
+// Delete API key handler
+func DeleteAPIKeyHandler(c *gin.Context) {
+	ak := new(pg.APIKey)
+	err := c.ShouldBind(ak)
+	if err != nil {
+		zap.L().Error("DeleteAPIKeyHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, ak)
+		return
+	}
+	ak.Modifier.ID = operatorID
+	resStatus, _ = ak.Delete(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, ak)
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"sccsmsserver/db/pg"
+	"sccsmsserver/i18n"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Get approval flow list handler
+func GetApprovalFlowsHandler(c *gin.Context) {
+	afs, resStatus, _ := pg.GetApprovalFlows()
+	ResponseWithMsg(c, resStatus, afs)
+}
+
+// Add approval flow handler
+func AddApprovalFlowHandler(c *gin.Context) {
+	af := new(pg.ApprovalFlow)
+	err := c.ShouldBind(af)
+	if err != nil {
+		zap.L().Error("AddApprovalFlowHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, af)
+		return
+	}
+	af.Creator.ID = operatorID
+	resStatus, _ = af.Add(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, af)
+}
+
+// Modify approval flow handler
+func EditApprovalFlowHandler(c *gin.Context) {
+	af := new(pg.ApprovalFlow)
+	err := c.ShouldBind(af)
+	if err != nil {
+		zap.L().Error("EditApprovalFlowHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, af)
+		return
+	}
+	af.Modifier.ID = operatorID
+	resStatus, _ = af.Edit(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, af)
+}
+
+// Delete approval flow handler
+func DeleteApprovalFlowHandler(c *gin.Context) {
+	af := new(pg.ApprovalFlow)
+	err := c.ShouldBind(af)
+	if err != nil {
+		zap.L().Error("DeleteApprovalFlowHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, af)
+		return
+	}
+	af.Modifier.ID = operatorID
+	resStatus, _ = af.Delete(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, af)
+}
+
+// Approve voucher handler
+func ApproveVoucherHandler(c *gin.Context) {
+	p := new(pg.ApprovalParams)
+	err := c.ShouldBind(p)
+	if err != nil {
+		zap.L().Error("ApproveVoucherHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, p)
+		return
+	}
+	resStatus, _ = p.Approve(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, p)
+}
+
+// Reject voucher handler
+func RejectVoucherHandler(c *gin.Context) {
+	p := new(pg.ApprovalParams)
+	err := c.ShouldBind(p)
+	if err != nil {
+		zap.L().Error("RejectVoucherHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, p)
+		return
+	}
+	resStatus, _ = p.Reject(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, p)
+}
+
+// Return voucher handler
+func ReturnVoucherHandler(c *gin.Context) {
+	p := new(pg.ApprovalParams)
+	err := c.ShouldBind(p)
+	if err != nil {
+		zap.L().Error("ReturnVoucherHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, p)
+		return
+	}
+	resStatus, _ = p.Return(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, p)
+}
+
+// Get the approval history of the voucher handler
+func GetVoucherApprovalsHandler(c *gin.Context) {
+	p := new(pg.ApprovalParams)
+	err := c.ShouldBind(p)
+	if err != nil {
+		zap.L().Error("GetVoucherApprovalsHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get the permissions and the data scope of the operator
+	up, resStatus := GetOperatorPermissions(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
+	vas, resStatus, _ := pg.GetVoucherApprovals(p.VoucherType, p.VoucherID, up, ds)
+	ResponseWithMsg(c, resStatus, vas)
+}
+
+// Get the approvals waiting for the operator handler
+func GetPendingApprovalsHandler(c *gin.Context) {
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
+	vas, resStatus, _ := pg.GetPendingApprovals(operatorID)
+	ResponseWithMsg(c, resStatus, vas)
+}
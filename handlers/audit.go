@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"sccsmsserver/db/pg"
+	"sccsmsserver/i18n"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Get audit log list by pagination handler
+func GetAuditLogsPaginationHandler(c *gin.Context) {
+	pqp := new(pg.PagingQueryParams)
+	err := c.ShouldBind(pqp)
+	if err != nil {
+		zap.L().Error("GetAuditLogsPaginationHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	alp, resStatus, _ := pg.GetAuditLogsPagination(*pqp)
+	ResponseWithMsg(c, resStatus, alp)
+}
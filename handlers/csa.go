@@ -31,7 +31,7 @@ func AddCSHandler(c *gin.Context) {
 	}
 	cs.Creator.ID = operatorID
 	// Add
-	resStatus, _ = cs.Add()
+	resStatus, _ = cs.Add(GetAuditActor(c, operatorID))
 	ResponseWithMsg(c, resStatus, cs)
 }
 
@@ -52,7 +52,7 @@ func EditCSHandler(c *gin.Context) {
 	}
 	cs.Modifier.ID = operationID
 	// Modify
-	resStatus, _ = cs.Edit()
+	resStatus, _ = cs.Edit(GetAuditActor(c, operationID))
 	// Response
 	ResponseWithMsg(c, resStatus, cs)
 }
@@ -104,7 +104,7 @@ func DeleteCSHandler(c *gin.Context) {
 	}
 	cs.Modifier.ID = operationID
 	// Delete
-	resStatus, _ = cs.Delete()
+	resStatus, _ = cs.Delete(GetAuditActor(c, operationID))
 	// Response
 	ResponseWithMsg(c, resStatus, cs)
 }
@@ -125,7 +125,7 @@ func DeleteCSsHandler(c *gin.Context) {
 		return
 	}
 	// Batch Delete
-	resStatus, _ = pg.DeleteCSs(css, operationID)
+	resStatus, _ = pg.DeleteCSs(css, GetAuditActor(c, operationID))
 	// Response
 	ResponseWithMsg(c, resStatus, css)
 }
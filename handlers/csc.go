@@ -66,7 +66,7 @@ func AddCSCHandler(c *gin.Context) {
 	}
 	csc.Creator.ID = operatorID
 	// Add
-	resStatus, _ = csc.Add()
+	resStatus, _ = csc.Add(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, csc)
 }
@@ -89,7 +89,7 @@ func EditCSCHandler(c *gin.Context) {
 	csc.Modifier.ID = operatorID
 
 	// Edit
-	resStatus, _ = csc.Edit()
+	resStatus, _ = csc.Edit(GetAuditActor(c, operatorID))
 	ResponseWithMsg(c, resStatus, csc)
 }
 
@@ -110,7 +110,7 @@ func DeleteCSCHandler(c *gin.Context) {
 	}
 	csc.Modifier.ID = operatorID
 	// Delete
-	resStatus, _ = csc.Delete()
+	resStatus, _ = csc.Delete(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, csc)
 }
@@ -130,7 +130,7 @@ func DeleteCSCsHandler(c *gin.Context) {
 		return
 	}
 	// Batch delete
-	resStatus, _ = pg.DeleteCSCs(cscs, operatorID)
+	resStatus, _ = pg.DeleteCSCs(cscs, GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, cscs)
 }
@@ -33,7 +33,7 @@ func EditCSOHandler(c *gin.Context) {
 	}
 	cso.Modifier.ID = operatorID
 
-	resStatus, _ = cso.Edit()
+	resStatus, _ = cso.Edit(GetAuditActor(c, operatorID))
 	ResponseWithMsg(c, resStatus, cso)
 }
 
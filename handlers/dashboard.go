@@ -17,14 +17,14 @@ func GetDashboardDataHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
-	// Get Operator ID
-	operatorID, resStatus := GetOperatorID(c)
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
 	if resStatus != i18n.StatusOK {
 		ResponseWithMsg(c, resStatus, d)
 		return
 	}
 	// Get Dashboard Data
-	resStatus, _ = d.Get(operatorID)
+	resStatus, _ = d.Get(ds)
 	// Response
 	ResponseWithMsg(c, resStatus, d)
 }
@@ -38,8 +38,14 @@ func GetRiskTrendDataHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, rtd)
+		return
+	}
 	// Get Data
-	resStatus, _ := rtd.Get()
+	resStatus, _ = rtd.Get(ds)
 	// Response
 	ResponseWithMsg(c, resStatus, rtd)
 }
@@ -65,7 +65,7 @@ func AddDCHandler(c *gin.Context) {
 	}
 	dc.Creator.ID = operatorID
 	// Add Document Category
-	resStatus, _ = dc.Add()
+	resStatus, _ = dc.Add(GetAuditActor(c, operatorID))
 	ResponseWithMsg(c, resStatus, dc)
 }
 
@@ -88,7 +88,7 @@ func EditDCHandler(c *gin.Context) {
 	dc.Modifier.ID = operatorID
 
 	// Edit Document Category
-	resStatus, _ = dc.Edit()
+	resStatus, _ = dc.Edit(GetAuditActor(c, operatorID))
 	ResponseWithMsg(c, resStatus, dc)
 }
 
@@ -111,7 +111,7 @@ func DeleteDCHandler(c *gin.Context) {
 	dc.Modifier.ID = operatorID
 
 	// Delete Document Category
-	resStatus, _ = dc.Delete()
+	resStatus, _ = dc.Delete(GetAuditActor(c, operatorID))
 	ResponseWithMsg(c, resStatus, dc)
 }
 
@@ -132,7 +132,7 @@ func DeleteDCsHandler(c *gin.Context) {
 		return
 	}
 	// Delete Document Categories
-	resStatus, _ = pg.DeleteDCs(dcs, operatorID)
+	resStatus, _ = pg.DeleteDCs(dcs, GetAuditActor(c, operatorID))
 	// R
 	ResponseWithMsg(c, resStatus, dcs)
 }
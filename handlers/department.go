@@ -68,7 +68,7 @@ func AddDeptHandler(c *gin.Context) {
 	}
 	d.Creator.ID = operatorID
 	// Add department
-	resStatus, _ = d.AddDept()
+	resStatus, _ = d.AddDept(GetAuditActor(c, operatorID))
 	// Resopnse
 	ResponseWithMsg(c, resStatus, d)
 }
@@ -91,7 +91,7 @@ func EditDeptHandler(c *gin.Context) {
 	d.Modifier.ID = operatorID
 
 	// edit department
-	resStatus, _ = d.Edit()
+	resStatus, _ = d.Edit(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, d)
 }
@@ -113,7 +113,7 @@ func DelDeptHandler(c *gin.Context) {
 	}
 	d.Modifier.ID = operatorID
 	// Delete
-	resStatus, _ = d.Delete()
+	resStatus, _ = d.Delete(GetAuditActor(c, operatorID))
 	// Resopnse
 	ResponseWithMsg(c, resStatus, d)
 }
@@ -134,7 +134,7 @@ func DelDeptsHandler(c *gin.Context) {
 		return
 	}
 	// Delete departments
-	resStatus, _ = pg.DeleteDepts(depts, operatorID)
+	resStatus, _ = pg.DeleteDepts(depts, GetAuditActor(c, operatorID))
 	// Resopnse
 	ResponseWithMsg(c, resStatus, depts)
 }
@@ -25,7 +25,7 @@ func AddDocumentHandler(c *gin.Context) {
 	}
 	d.Creator.ID = operatorID
 	// Add
-	resStatus, _ = d.Add()
+	resStatus, _ = d.Add(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, d)
 }
@@ -47,7 +47,7 @@ func EditDocumentHandler(c *gin.Context) {
 	}
 	d.Modifier.ID = operaorID
 	// Modify
-	resStatus, _ = d.Edit()
+	resStatus, _ = d.Edit(GetAuditActor(c, operaorID))
 	// Response
 	ResponseWithMsg(c, resStatus, d)
 }
@@ -83,7 +83,7 @@ func DeleteDocumentHandler(c *gin.Context) {
 		return
 	}
 	// Delete
-	resStatus, _ = d.Delete(operaorID)
+	resStatus, _ = d.Delete(GetAuditActor(c, operaorID))
 	// Response
 	ResponseWithMsg(c, resStatus, d)
 }
@@ -103,7 +103,7 @@ func DeleteDocumentsHandler(c *gin.Context) {
 		return
 	}
 	// Batch Delete
-	resStatus, _ = pg.DeleteDocuments(docs, operaorID)
+	resStatus, _ = pg.DeleteDocuments(docs, GetAuditActor(c, operaorID))
 	// Response
 	ResponseWithMsg(c, resStatus, docs)
 }
@@ -118,7 +118,7 @@ func GetDocumentReportHandler(c *gin.Context) {
 		return
 	}
 	// Get Report
-	rep, resStatus, _ := pg.GetQueryDocumentReport(qp.QueryString)
+	rep, resStatus, _ := pg.GetQueryDocumentReport(qp.Filter)
 	// Response
 	ResponseWithMsg(c, resStatus, rep)
 }
@@ -64,7 +64,7 @@ func AddEPHandler(c *gin.Context) {
 	}
 	epa.Creator.ID = opeartorID
 	// Add EP
-	resStatus, _ = epa.Add()
+	resStatus, _ = epa.Add(GetAuditActor(c, opeartorID))
 	// Response
 	ResponseWithMsg(c, resStatus, epa)
 }
@@ -88,7 +88,7 @@ func EditEPHandler(c *gin.Context) {
 	epa.Modifier.ID = opeartorID
 
 	// Modify
-	resStats, _ := epa.Edit()
+	resStats, _ := epa.Edit(GetAuditActor(c, opeartorID))
 	// Response
 	ResponseWithMsg(c, resStats, epa)
 }
@@ -111,7 +111,7 @@ func DeleteEPHandler(c *gin.Context) {
 	}
 	epa.Modifier.ID = opeartorID
 	// Delete
-	resStatus, _ = epa.Delete()
+	resStatus, _ = epa.Delete(GetAuditActor(c, opeartorID))
 	// Response
 	ResponseWithMsg(c, resStatus, epa)
 }
@@ -132,7 +132,7 @@ func DeleteEPsHandler(c *gin.Context) {
 		return
 	}
 	// Batch delete
-	resStatus, _ = pg.DeleteEPs(epas, opeartorID)
+	resStatus, _ = pg.DeleteEPs(epas, GetAuditActor(c, opeartorID))
 	// Response
 	ResponseWithMsg(c, resStatus, epas)
 }
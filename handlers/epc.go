@@ -66,7 +66,7 @@ func AddEPCHandler(c *gin.Context) {
 	}
 	epc.Creator.ID = operatorID
 	// Add
-	resStatus, _ = epc.Add()
+	resStatus, _ = epc.Add(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, epc)
 }
@@ -88,7 +88,7 @@ func EditEPCHandler(c *gin.Context) {
 	}
 	epc.Modifier.ID = operatorID
 	// Modify
-	resStatus, _ = epc.Edit()
+	resStatus, _ = epc.Edit(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, epc)
 }
@@ -111,7 +111,7 @@ func DeleteEPCHandler(c *gin.Context) {
 	}
 	epc.Modifier.ID = operatorID
 	// Delete
-	resStatus, _ = epc.Delete()
+	resStatus, _ = epc.Delete(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, epc)
 }
@@ -133,7 +133,7 @@ func DeleteEPCsHandler(c *gin.Context) {
 		return
 	}
 	// Batch delete
-	resStatus, _ = pg.DeleteEPCs(epcs, operatorID)
+	resStatus, _ = pg.DeleteEPCs(epcs, GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, epcs)
 }
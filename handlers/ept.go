@@ -26,7 +26,7 @@ func AddEPTHandler(c *gin.Context) {
 	}
 	ept.Creator.ID = operatorID
 	// Add
-	resStatus, _ = ept.Add()
+	resStatus, _ = ept.Add(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, ept)
 }
@@ -49,7 +49,7 @@ func EditEPTHandler(c *gin.Context) {
 	}
 	ept.Modifier.ID = operatorID
 	// Edit
-	resStatus, _ = ept.Edit()
+	resStatus, _ = ept.Edit(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, ept)
 }
@@ -72,7 +72,7 @@ func DeleteEPTHandler(c *gin.Context) {
 	}
 	ept.Modifier.ID = operatorID
 	// Delete
-	resStatus, _ = ept.Delete()
+	resStatus, _ = ept.Delete(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, ept)
 }
@@ -134,7 +134,7 @@ func DeleteEPTsHandler(c *gin.Context) {
 		return
 	}
 	// Delete
-	resStatus, _ = pg.DeleteEPTs(epts, operatorID)
+	resStatus, _ = pg.DeleteEPTs(epts, GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, epts)
 }
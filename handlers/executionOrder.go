@@ -3,6 +3,7 @@ package handlers
 import (
 	"sccsmsserver/db/pg"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pub"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -17,12 +18,27 @@ func GetReferEOHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, qp)
+		return
+	}
 	// Get list
-	reos, resStatus, _ := pg.GetReferEOs(qp.QueryString)
+	reos, resStatus, _ := pg.GetReferEOs(qp.Filter, ds)
 	// Response
 	ResponseWithMsg(c, resStatus, reos)
 }
 
+// Fill the messages of the Execution Order row errors in the language of the request
+func fillEORowErrorMsgs(c *gin.Context, eo *pg.ExecutionOrder) {
+	lang := c.GetHeader("Accept-Language")
+	for i := range eo.RowErrors {
+		re := &eo.RowErrors[i]
+		re.Msg = re.ResKey.Msg(lang, re.RowNumber)
+	}
+}
+
 // Add Execution Order handler
 func AddEOHandler(c *gin.Context) {
 	eo := new(pg.ExecutionOrder)
@@ -40,7 +56,8 @@ func AddEOHandler(c *gin.Context) {
 	}
 	eo.Creator.ID = operatorID
 	// Add
-	resStatus, _ = eo.Add()
+	resStatus, _ = eo.Add(GetAuditActor(c, operatorID))
+	fillEORowErrorMsgs(c, eo)
 	// Resoponse
 	ResponseWithMsg(c, resStatus, eo)
 }
@@ -62,7 +79,8 @@ func EditEOHandler(c *gin.Context) {
 	}
 	eo.Modifier.ID = operatorID
 	// Modify
-	resStatus, _ = eo.Edit()
+	resStatus, _ = eo.Edit(GetAuditActor(c, operatorID))
+	fillEORowErrorMsgs(c, eo)
 	// Response
 	ResponseWithMsg(c, resStatus, eo)
 }
@@ -83,7 +101,7 @@ func DeleteEOHandler(c *gin.Context) {
 		return
 	}
 	// Delete
-	resStatus, _ = eo.Delete(operatorID)
+	resStatus, _ = eo.Delete(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, eo)
 }
@@ -104,7 +122,8 @@ func ConfirmEOHandler(c *gin.Context) {
 		return
 	}
 	// Confirm
-	resStatus, _ = eo.Confirm(operatorID)
+	resStatus, _ = eo.Confirm(GetAuditActor(c, operatorID))
+	fillEORowErrorMsgs(c, eo)
 	// Response
 	ResponseWithMsg(c, resStatus, eo)
 }
@@ -125,7 +144,7 @@ func CancelConfirmEOHandler(c *gin.Context) {
 		return
 	}
 	// Un-Confirm
-	resStatus, _ = eo.UnConfirm(operatorID)
+	resStatus, _ = eo.UnConfirm(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, eo)
 }
@@ -139,8 +158,14 @@ func GetEOListHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, qp)
+		return
+	}
 	// Get list
-	eos, resStatus, _ := pg.GetEOList(qp.QueryString)
+	eos, resStatus, _ := pg.GetEOList(qp.Filter, ds)
 	// Response
 	ResponseWithMsg(c, resStatus, eos)
 }
@@ -160,8 +185,14 @@ func GetEOReviewListHandler(c *gin.Context) {
 		ResponseWithMsg(c, resStatus, qp)
 		return
 	}
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, qp)
+		return
+	}
 	// Get List
-	eos, resStatus, _ := pg.GetEOReviewList(qp.QueryString, operatorID)
+	eos, resStatus, _ := pg.GetEOReviewList(qp.Filter, operatorID, ds)
 	// Response
 	ResponseWithMsg(c, resStatus, eos)
 }
@@ -181,8 +212,14 @@ func GetEOReviewListPaginationHandler(c *gin.Context) {
 		ResponseWithMsg(c, resStatus, pqp)
 		return
 	}
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, pqp)
+		return
+	}
 	// Get list
-	eosp, resStatus, _ := pg.GetEOReviewListPagination(*pqp, operatorID)
+	eosp, resStatus, _ := pg.GetEOReviewListPagination(*pqp, operatorID, ds)
 	// Response
 	ResponseWithMsg(c, resStatus, eosp)
 }
@@ -196,8 +233,19 @@ func GetEOInfoByHIDHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// The voucher must be in the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
+	resStatus, _ = ds.CheckVoucher(pub.EO, eo.HID)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
 	// Get Details
-	resStatus, _ := eo.GetDetailByHID()
+	resStatus, _ = eo.GetDetailByHID()
 	// Resopnse
 	ResponseWithMsg(c, resStatus, eo)
 }
@@ -25,7 +25,7 @@ func AddIRFHandler(c *gin.Context) {
 	}
 	irf.Creator.ID = operatorID
 	// Add
-	resStatus, _ = irf.Add()
+	resStatus, _ = irf.Add(GetAuditActor(c, operatorID))
 	// Resopnse
 	ResponseWithMsg(c, resStatus, irf)
 }
@@ -47,7 +47,7 @@ func EditIRFHandler(c *gin.Context) {
 	}
 	irf.Modifier.ID = operatorID
 	// Modify
-	resStatus, _ = irf.Edit()
+	resStatus, _ = irf.Edit(GetAuditActor(c, operatorID))
 	// Resopnse
 	ResponseWithMsg(c, resStatus, irf)
 }
@@ -68,7 +68,7 @@ func DeleteIRFhandler(c *gin.Context) {
 		return
 	}
 	// Delete
-	resStatus, _ = irf.Delete(operatorID)
+	resStatus, _ = irf.Delete(GetAuditActor(c, operatorID))
 	// Resopnse
 	ResponseWithMsg(c, resStatus, irf)
 }
@@ -89,7 +89,7 @@ func ConfirmIRFhandler(c *gin.Context) {
 		return
 	}
 	// Confirm
-	resStatus, _ = irf.Confirm(operationID)
+	resStatus, _ = irf.Confirm(GetAuditActor(c, operationID))
 	// Response
 	ResponseWithMsg(c, resStatus, irf)
 }
@@ -110,7 +110,7 @@ func UnConfirmIRFhandler(c *gin.Context) {
 		return
 	}
 	// UnConfirm
-	resStatus, _ = irf.UnConfirm(operationID)
+	resStatus, _ = irf.UnConfirm(GetAuditActor(c, operationID))
 	// Response
 	ResponseWithMsg(c, resStatus, irf)
 }
@@ -124,8 +124,14 @@ func GetIRFListHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, qp)
+		return
+	}
 	// Get List
-	irfs, resStauts, _ := pg.GetIRFList(qp.QueryString)
+	irfs, resStauts, _ := pg.GetIRFList(qp.Filter, ds)
 	// Response
 	ResponseWithMsg(c, resStauts, irfs)
 }
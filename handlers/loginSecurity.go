@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"sccsmsserver/db/pg"
+	"sccsmsserver/i18n"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Get locked IP address list handler
+func GetLockedIPsHandler(c *gin.Context) {
+	lips, resStatus, _ := pg.GetLockedIPs()
+	ResponseWithMsg(c, resStatus, lips)
+}
+
+// Unlock IP address handler
+func UnlockIPHandler(c *gin.Context) {
+	lip := new(pg.LockedIP)
+	err := c.ShouldBind(lip)
+	if err != nil || lip.ClientIp == "" {
+		zap.L().Error("UnlockIPHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, lip)
+		return
+	}
+	resStatus, _ = lip.Unlock(operatorID)
+	ResponseWithMsg(c, resStatus, lip)
+}
+
+// Get locked user list handler
+func GetLockedUsersHandler(c *gin.Context) {
+	lus, resStatus, _ := pg.GetLockedUsers()
+	ResponseWithMsg(c, resStatus, lus)
+}
+
+// Unlock user handler
+func UnlockUserHandler(c *gin.Context) {
+	lu := new(pg.LockedUser)
+	err := c.ShouldBind(lu)
+	if err != nil {
+		zap.L().Error("UnlockUserHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, lu)
+		return
+	}
+	resStatus, _ = lu.Unlock(operatorID)
+	ResponseWithMsg(c, resStatus, lu)
+}
+
+// Get IP address access rule list handler
+func GetIPAccessRulesHandler(c *gin.Context) {
+	rules, resStatus, _ := pg.GetIPAccessRules()
+	ResponseWithMsg(c, resStatus, rules)
+}
+
+// Add IP address access rule handler
+func AddIPAccessRuleHandler(c *gin.Context) {
+	r := new(pg.IPAccessRule)
+	err := c.ShouldBind(r)
+	if err != nil {
+		zap.L().Error("AddIPAccessRuleHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, r)
+		return
+	}
+	r.Creator.ID = operatorID
+	resStatus, _ = r.Add(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, r)
+}
+
+// Modify IP address access rule handler
+func EditIPAccessRuleHandler(c *gin.Context) {
+	r := new(pg.IPAccessRule)
+	err := c.ShouldBind(r)
+	if err != nil {
+		zap.L().Error("EditIPAccessRuleHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, r)
+		return
+	}
+	r.Modifier.ID = operatorID
+	resStatus, _ = r.Edit(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, r)
+}
+
+// Delete IP address access rule handler
+func DeleteIPAccessRuleHandler(c *gin.Context) {
+	r := new(pg.IPAccessRule)
+	err := c.ShouldBind(r)
+	if err != nil {
+		zap.L().Error("DeleteIPAccessRuleHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, r)
+		return
+	}
+	r.Modifier.ID = operatorID
+	resStatus, _ = r.Delete(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, r)
+}
+
+// Get login failure records by pagination handler
+func GetLoginFaultsPaginationHandler(c *gin.Context) {
+	pqp := new(pg.PagingQueryParams)
+	err := c.ShouldBind(pqp)
+	if err != nil {
+		zap.L().Error("GetLoginFaultsPaginationHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	lfp, resStatus, _ := pg.GetLoginFaultsPagination(*pqp)
+	ResponseWithMsg(c, resStatus, lfp)
+}
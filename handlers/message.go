@@ -38,7 +38,7 @@ func GetUserReadCommentsHandler(c *gin.Context) {
 		return
 	}
 	// Get Comments
-	comments, resStatus, _ := pg.GetUserReadComments(opeartorID, qp.QueryString)
+	comments, resStatus, _ := pg.GetUserReadComments(opeartorID, qp.Filter)
 	// Response
 	ResponseWithMsg(c, resStatus, comments)
 }
@@ -92,3 +92,87 @@ func ReadCommentMessageHandler(c *gin.Context) {
 	// Response
 	ResponseWithMsg(c, resStatus, cm)
 }
+
+// Get User open escalations of overdue items handler
+func GetUserEscalationsHandler(c *gin.Context) {
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, 0)
+		return
+	}
+	// Get escalations
+	escalations, resStatus, _ := pg.GetUserEscalations(operatorID)
+	// Response
+	ResponseWithMsg(c, resStatus, escalations)
+}
+
+// Read escalation handler
+func ReadEscalationHandler(c *gin.Context) {
+	e := new(pg.Escalation)
+	err := c.ShouldBind(e)
+	if err != nil {
+		zap.L().Error("ReadEscalationHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, 0)
+		return
+	}
+	// Read
+	resStatus, _ = e.Read(operatorID)
+	// Response
+	ResponseWithMsg(c, resStatus, e)
+}
+
+// Get the notification preferences of the user handler
+func GetNotifyPrefsHandler(c *gin.Context) {
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, 0)
+		return
+	}
+	np := pg.NotifyPrefs{UserID: operatorID}
+	resStatus, _ = np.Get()
+	// Response
+	ResponseWithMsg(c, resStatus, np)
+}
+
+// Save the notification preferences of the user handler
+func SaveNotifyPrefsHandler(c *gin.Context) {
+	np := new(pg.NotifyPrefs)
+	err := c.ShouldBind(np)
+	if err != nil {
+		zap.L().Error("SaveNotifyPrefsHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, 0)
+		return
+	}
+	// Users only save their own preferences
+	np.UserID = operatorID
+	resStatus, _ = np.Save()
+	// Response
+	ResponseWithMsg(c, resStatus, np)
+}
+
+// Send a test email to the user handler
+func SendTestMailHandler(c *gin.Context) {
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, 0)
+		return
+	}
+	resStatus, _ = pg.SendTestMail(operatorID)
+	// Response
+	ResponseWithMsg(c, resStatus, nil)
+}
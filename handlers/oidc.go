@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"sccsmsserver/db/pg"
+	"sccsmsserver/i18n"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Get single sign-on options handler
+func GetSSOOptionsHandler(c *gin.Context) {
+	ResponseWithMsg(c, i18n.StatusOK, pg.GetSSOOptions())
+}
+
+// Start OpenID Connect login handler
+func OIDCLoginHandler(c *gin.Context) {
+	p := new(pg.ParamOIDCLogin)
+	if err := c.ShouldBind(p); err != nil {
+		zap.L().Error("OIDCLoginHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, nil)
+		return
+	}
+	p.ClientType = c.Request.Header.Get("XClientType")
+	ar, resStatus, _ := pg.OIDCLogin(p)
+	ResponseWithMsg(c, resStatus, ar)
+}
+
+// Complete OpenID Connect login handler
+func OIDCCallbackHandler(c *gin.Context) {
+	p := new(pg.ParamOIDCCallback)
+	if err := c.ShouldBind(p); err != nil {
+		zap.L().Error("OIDCCallbackHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, nil)
+		return
+	}
+	p.ClientIP = c.ClientIP()
+	p.ClientType = c.Request.Header.Get("XClientType")
+	p.UserAgent = c.Request.UserAgent()
+	tp, resStatus, _ := pg.OIDCCallback(p)
+	ResponseWithMsg(c, resStatus, tp)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"sccsmsserver/db/pg"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Respond with the password policy limits the message refers to
+func responseWithPasswordStatus(c *gin.Context, resStatus i18n.ResKey, data interface{}) {
+	pp := pg.GetPasswordPolicy()
+	switch resStatus {
+	case i18n.StatusPasswordTooShort:
+		ResponseWithMsg(c, resStatus, data, pp.MinLength)
+	case i18n.StatusPasswordReused:
+		ResponseWithMsg(c, resStatus, data, pp.HistoryCount)
+	default:
+		ResponseWithMsg(c, resStatus, data)
+	}
+}
+
+// Get password policy handler
+func GetPasswordPolicyHandler(c *gin.Context) {
+	ResponseWithMsg(c, i18n.StatusOK, pg.GetPasswordPolicy())
+}
+
+// Change the expired or initial password during login handler
+func ChangeExpiredPasswordHandler(c *gin.Context) {
+	p := new(pg.ParamPreAuthChangePwd)
+	if err := c.ShouldBind(p); err != nil {
+		zap.L().Error("ChangeExpiredPasswordHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, nil)
+		return
+	}
+	// Decrypt the RSA fields sent from the frent end
+	np, err := base64.StdEncoding.DecodeString(p.NewPassword)
+	if err != nil {
+		zap.L().Error("ChangeExpiredPasswordHandler base64.StdEncoding.DecodeString(p.NewPassword) failed", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInternalError, nil)
+		return
+	}
+	cnp, err := base64.StdEncoding.DecodeString(p.ConfirmNewPwd)
+	if err != nil {
+		zap.L().Error("ChangeExpiredPasswordHandler base64.StdEncoding.DecodeString(p.ConfirmNewPwd) failed", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInternalError, nil)
+		return
+	}
+	oriNewPwd, err := security.Decrypt(p.KeyID, np)
+	if err != nil {
+		zap.L().Error("ChangeExpiredPasswordHandler security.Decrypt(np) failed", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInternalError, nil)
+		return
+	}
+	oriConfirmNewPwd, err := security.Decrypt(p.KeyID, cnp)
+	if err != nil {
+		zap.L().Error("ChangeExpiredPasswordHandler security.Decrypt(cnp) failed", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInternalError, nil)
+		return
+	}
+	p.NewPassword = string(oriNewPwd)
+	p.ConfirmNewPwd = string(oriConfirmNewPwd)
+	p.ClientIP = c.ClientIP()
+	p.ClientType = c.Request.Header.Get("XClientType")
+	p.UserAgent = c.Request.UserAgent()
+
+	tp, resStatus, _ := pg.ChangePasswordWithPreAuth(p)
+	responseWithPasswordStatus(c, resStatus, tp)
+}
+
+// Reset user password handler
+func ResetUserPasswordHandler(c *gin.Context) {
+	u := new(pg.User)
+	err := c.ShouldBind(u)
+	if err != nil {
+		zap.L().Error("ResetUserPasswordHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get operator id
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, u)
+		return
+	}
+	u.Modifier.ID = operatorID
+	resStatus, _ = u.ResetPassword(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, u)
+}
@@ -25,7 +25,7 @@ func AddPositionHandler(c *gin.Context) {
 	}
 	p.Creator.ID = operatorID
 	// Add
-	resStatus, _ = p.Add()
+	resStatus, _ = p.Add(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, p)
 }
@@ -53,7 +53,7 @@ func EditPositionHandler(c *gin.Context) {
 	}
 	p.Modifier.ID = operatorID
 	// Edit
-	resStatus, _ = p.Edit()
+	resStatus, _ = p.Edit(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, p)
 }
@@ -75,7 +75,7 @@ func DeletePositionHandler(c *gin.Context) {
 	}
 	p.Modifier.ID = operatorID
 	// Delete
-	resStatus, _ = p.Delete()
+	resStatus, _ = p.Delete(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, p)
 }
@@ -126,7 +126,7 @@ func DeletePositionsHandler(c *gin.Context) {
 		return
 	}
 	// Batch Delete
-	resStatus, _ = pg.DeleteOPs(ps, operatorID)
+	resStatus, _ = pg.DeleteOPs(ps, GetAuditActor(c, operatorID))
 	// Resopnse
 	ResponseWithMsg(c, resStatus, ps)
 }
@@ -25,7 +25,7 @@ func AddPPEHandler(c *gin.Context) {
 	}
 	ppe.Creator.ID = operatorID
 	// Add
-	resStatus, _ = ppe.Add()
+	resStatus, _ = ppe.Add(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, ppe)
 }
@@ -53,7 +53,7 @@ func EditPPEHandler(c *gin.Context) {
 	}
 	ppe.Modifier.ID = operatorID
 	// Modify
-	resStatus, _ = ppe.Edit()
+	resStatus, _ = ppe.Edit(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, ppe)
 }
@@ -75,7 +75,7 @@ func DeletePPEHandler(c *gin.Context) {
 	}
 	ppe.Modifier.ID = operatorID
 	// Delete
-	resStatus, _ = ppe.Delete()
+	resStatus, _ = ppe.Delete(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, ppe)
 }
@@ -127,7 +127,7 @@ func DeletePPEsHandler(c *gin.Context) {
 		return
 	}
 	// Batch Delete
-	statusCode, _ := pg.DeletePPEs(ppes, operatorID)
+	statusCode, _ := pg.DeletePPEs(ppes, GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, statusCode, ppes)
 }
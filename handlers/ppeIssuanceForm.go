@@ -3,6 +3,7 @@ package handlers
 import (
 	"sccsmsserver/db/pg"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pub"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -25,7 +26,7 @@ func AddPPEIFHandler(c *gin.Context) {
 	}
 	pif.Creator.ID = operatorID
 	// Add
-	resStatus, _ = pif.Add()
+	resStatus, _ = pif.Add(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, pif)
 }
@@ -48,7 +49,7 @@ func WiardAddPPEIFHandler(c *gin.Context) {
 	lidw.Params.Creator.ID = operatorID
 
 	// Generate
-	resStatus, _ = lidw.Generate()
+	resStatus, _ = lidw.Generate(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, lidw)
 }
@@ -62,8 +63,14 @@ func GetPPEIFListHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, qp)
+		return
+	}
 	// Get List
-	pls, resStatus, _ := pg.GetPPEIFList(qp.QueryString)
+	pls, resStatus, _ := pg.GetPPEIFList(qp.Filter, ds)
 	ResponseWithMsg(c, resStatus, pls)
 }
 
@@ -76,8 +83,19 @@ func GetPPEIFInfoByHIDHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// The voucher must be in the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
+	resStatus, _ = ds.CheckVoucher(pub.PPEIF, pif.HID)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
 	// Get Detail
-	resStatus, _ := pif.GetDetailByHID()
+	resStatus, _ = pif.GetDetailByHID()
 	// Response
 	ResponseWithMsg(c, resStatus, pif)
 }
@@ -99,7 +117,7 @@ func EditPPEIFHandler(c *gin.Context) {
 	}
 	pif.Modifier.ID = operatorID
 	// Modify
-	resStatus, _ = pif.Edit()
+	resStatus, _ = pif.Edit(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, pif)
 }
@@ -120,7 +138,7 @@ func DeletePPEIFHandler(c *gin.Context) {
 		return
 	}
 	// Delete
-	resStatus, _ = pif.Delete(operatorID)
+	resStatus, _ = pif.Delete(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, pif)
 }
@@ -141,7 +159,7 @@ func ConfirmPPEIFHandler(c *gin.Context) {
 		return
 	}
 	// Confirm
-	resStatus, _ = pif.Confirm(confirmUserID)
+	resStatus, _ = pif.Confirm(GetAuditActor(c, confirmUserID))
 	// Response
 	ResponseWithMsg(c, resStatus, pif)
 }
@@ -162,7 +180,7 @@ func UnconfirmPPEIFHandler(c *gin.Context) {
 		return
 	}
 	// Unconfirm
-	resStatus, _ = pif.Unconfirm(confirmUserID)
+	resStatus, _ = pif.Unconfirm(GetAuditActor(c, confirmUserID))
 	// Response
 	ResponseWithMsg(c, resStatus, pif)
 }
@@ -176,8 +194,14 @@ func GetPPEIFReportHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, qp)
+		return
+	}
 	// Get Report
-	ldrs, resStatus, _ := pg.GetPPEIFReport(qp.QueryString)
+	ldrs, resStatus, _ := pg.GetPPEIFReport(qp.Filter, ds)
 	// Response
 	ResponseWithMsg(c, resStatus, ldrs)
 }
@@ -18,7 +18,7 @@ func GetPPEQuotaListHandler(c *gin.Context) {
 		return
 	}
 	// Get PPEQuota List
-	pqs, resStatus, _ := pg.GetPQList(qp.QueryString)
+	pqs, resStatus, _ := pg.GetPQList(qp.Filter)
 	// Response
 	ResponseWithMsg(c, resStatus, pqs)
 }
@@ -55,7 +55,7 @@ func AddPPEQuotaHandler(c *gin.Context) {
 	}
 	pq.Creator.ID = operatorID
 	// Add
-	resStatus, _ = pq.Add()
+	resStatus, _ = pq.Add(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, pq)
 }
@@ -77,7 +77,7 @@ func EditPPEQuotaHandler(c *gin.Context) {
 	}
 	pq.Modifier.ID = operatorID
 	// Modify
-	resStatus, _ = pq.Edit()
+	resStatus, _ = pq.Edit(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, pq)
 }
@@ -98,7 +98,7 @@ func DeletePPEQuotaHandler(c *gin.Context) {
 		return
 	}
 	// Delete
-	resStatus, _ = pq.Delete(operatorID)
+	resStatus, _ = pq.Delete(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, pq)
 }
@@ -119,7 +119,7 @@ func ConfirmPPEQuotaHandler(c *gin.Context) {
 		return
 	}
 	// Confirm
-	resStatus, _ = pq.Confirm(operatorID)
+	resStatus, _ = pq.Confirm(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, pq)
 }
@@ -141,7 +141,7 @@ func UnconfirmPPEQuotaHandler(c *gin.Context) {
 		return
 	}
 	// Unconfirm
-	resStatus, _ = pq.Unconfirm(operatorID)
+	resStatus, _ = pq.Unconfirm(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, pq)
 }
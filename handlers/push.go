@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"io"
+	"sccsmsserver/db/pg"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pub"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Time between two keep-alive events of a push stream, the session is checked again at each one
+const pushKeepAlive = 30 * time.Second
+
+// Push stream handler.
+// Server-Sent Events: the messages of the user are sent as they happen,
+// until the client disconnects or its session ends.
+// A client whose token is refreshed reconnects with the new token.
+func PushStreamHandler(c *gin.Context) {
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, 0)
+		return
+	}
+	// API key callers have no session
+	sessionID := c.GetString(pub.CTXSessionID)
+	tokenID := c.GetString(pub.CTXTokenID)
+	messages, cancel := pg.SubscribePush(operatorID)
+	defer cancel()
+	keepAlive := time.NewTicker(pushKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("ready", operatorID)
+	c.Writer.Flush()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case msg := <-messages:
+			c.SSEvent(msg.Event, msg)
+			return true
+		case <-keepAlive.C:
+			if sessionID != "" {
+				ou := pg.OnlineUser{SessionID: sessionID}
+				if !ou.Active(tokenID) {
+					c.SSEvent("close", i18n.CodeTokenDestroy)
+					return false
+				}
+			}
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		}
+	})
+}
@@ -17,8 +17,14 @@ func GetWoReportHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, qp)
+		return
+	}
 	// Get Report
-	wors, resStatus, _ := pg.GetWorkOrderReport(qp.QueryString)
+	wors, resStatus, _ := pg.GetWorkOrderReport(qp.Filter, ds)
 	// Response
 	ResponseWithMsg(c, resStatus, wors)
 }
@@ -32,8 +38,14 @@ func GetEoReportHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, qp)
+		return
+	}
 	// Get report
-	edrs, resStatus, _ := pg.GetExecutionOrderReport(qp.QueryString)
+	edrs, resStatus, _ := pg.GetExecutionOrderReport(qp.Filter, ds)
 	// Response
 	ResponseWithMsg(c, resStatus, edrs)
 }
@@ -47,8 +59,14 @@ func GetIRFReportHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, qp)
+		return
+	}
 	// Get Report
-	ddrs, resStatus, _ := pg.GetIssueResolutionFormReport(qp.QueryString)
+	ddrs, resStatus, _ := pg.GetIssueResolutionFormReport(qp.Filter, ds)
 	// Response
 	ResponseWithMsg(c, resStatus, ddrs)
 }
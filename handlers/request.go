@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/i18n"
 	"sccsmsserver/pub"
 
@@ -8,9 +9,15 @@ import (
 	"go.uber.org/zap"
 )
 
-// Get the current user ID from the request
+// Get the current user ID from the request.
+// An API key only acts as its service user on the routes checked by the permission middleware.
 func GetOperatorID(c *gin.Context) (userID int32, resStatus i18n.ResKey) {
 	resStatus = i18n.StatusOK
+	if keyID := GetAPIKeyID(c); keyID > 0 && !c.GetBool(pub.CTXPermissionChecked) {
+		zap.L().Info("GetOperatorID API key denied", zap.Int32("keyID", keyID), zap.String("path", c.FullPath()))
+		resStatus = i18n.StatusAPIKeyRouteDenied
+		return
+	}
 	uid, ok := c.Get(pub.CTXUserID)
 	if !ok {
 		zap.L().Error("GetOperatorID c.Get(pub.CTXUserID) failed.")
@@ -26,3 +33,49 @@ func GetOperatorID(c *gin.Context) (userID int32, resStatus i18n.ResKey) {
 
 	return
 }
+
+// Get the user and client of the request for the audit trail
+func GetAuditActor(c *gin.Context, userID int32) pg.AuditActor {
+	return pg.AuditActor{
+		UserID:     userID,
+		ClientIP:   c.ClientIP(),
+		ClientType: c.GetString(pub.CTXClientType),
+		APIKeyID:   GetAPIKeyID(c),
+	}
+}
+
+// Get the API key ID of the request, 0 when the user signed in with a token
+func GetAPIKeyID(c *gin.Context) int32 {
+	keyID, _ := c.Get(pub.CTXAPIKeyID)
+	id, _ := keyID.(int32)
+	return id
+}
+
+// Get the permissions of the current user.
+// A request with an API key is granted the permissions of the key roles.
+func GetOperatorPermissions(c *gin.Context) (up pg.UserPermissions, resStatus i18n.ResKey) {
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		return
+	}
+	if keyID := GetAPIKeyID(c); keyID > 0 {
+		up, resStatus, _ = pg.GetAPIKeyPermissions(keyID)
+		return
+	}
+	up, resStatus, _ = pg.GetUserPermissions(operatorID)
+	return
+}
+
+// Get the data scope of the current user
+func GetOperatorDataScope(c *gin.Context) (ds pg.DataScope, resStatus i18n.ResKey) {
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		return
+	}
+	if keyID := GetAPIKeyID(c); keyID > 0 {
+		ds, resStatus, _ = pg.GetAPIKeyDataScope(keyID)
+		return
+	}
+	ds, resStatus, _ = pg.GetUserDataScope(operatorID)
+	return
+}
@@ -25,7 +25,7 @@ func AddRLHandler(c *gin.Context) {
 	}
 	rl.Creator.ID = operatorID
 	// Add
-	resStatus, _ = rl.Add()
+	resStatus, _ = rl.Add(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, rl)
 }
@@ -55,7 +55,7 @@ func EditRLHandler(c *gin.Context) {
 	}
 	rl.Modifier.ID = operatorID
 	// Modify
-	resStatus, _ = rl.Edit()
+	resStatus, _ = rl.Edit(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, rl)
 }
@@ -77,7 +77,7 @@ func DeleteRLHandler(c *gin.Context) {
 	}
 	rl.Modifier.ID = operatorID
 	// Delete
-	resStatus, _ = rl.Delete()
+	resStatus, _ = rl.Delete(GetAuditActor(c, operatorID))
 	// Resopnse
 	ResponseWithMsg(c, resStatus, rl)
 }
@@ -129,7 +129,7 @@ func DeleteRLsHandler(c *gin.Context) {
 		return
 	}
 	// Batch delete
-	resStatus, _ = pg.DeleteRLs(rls, modifyUserId)
+	resStatus, _ = pg.DeleteRLs(rls, GetAuditActor(c, modifyUserId))
 	// Response
 	ResponseWithMsg(c, resStatus, rls)
 }
@@ -47,7 +47,7 @@ func AddRoleHandler(c *gin.Context) {
 	}
 	r.Creator.ID = creatorID
 	// Add the role
-	resStatus, _ = r.Add()
+	resStatus, _ = r.Add(GetAuditActor(c, creatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, r)
 }
@@ -68,7 +68,7 @@ func DeleteRoleHandler(c *gin.Context) {
 		return
 	}
 	r.Modifier.ID = modifierID
-	resStatus, _ = r.Delete()
+	resStatus, _ = r.Delete(GetAuditActor(c, modifierID))
 
 	// Response
 	ResponseWithMsg(c, resStatus, r)
@@ -91,7 +91,7 @@ func EditRoleHandler(c *gin.Context) {
 	}
 	r.Modifier.ID = modifierId
 	// Edit role
-	statusCode, _ := r.Edit()
+	statusCode, _ := r.Edit(GetAuditActor(c, modifierId))
 	// Response
 	ResponseWithMsg(c, statusCode, r)
 }
@@ -111,7 +111,7 @@ func DeleteRolesHandler(c *gin.Context) {
 		ResponseWithMsg(c, resStatus, r)
 		return
 	}
-	resStatus, _ = pg.DeleteRoles(r, operatorID)
+	resStatus, _ = pg.DeleteRoles(r, GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, r)
 }
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"net/http"
 	"sccsmsserver/i18n"
 	"sccsmsserver/pkg/jwt"
 	"sccsmsserver/pkg/security"
@@ -11,10 +12,15 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// Publish public key
+// Publish the public key of the active key with its key ID.
+// Clients encrypt the login password with the key and send the key ID back with it.
 func GetPublicKeyHandler(c *gin.Context) {
-	publicKey := security.ScRsa.GetPublicKey()
-	ResponseWithMsg(c, i18n.StatusOK, publicKey)
+	ResponseWithMsg(c, i18n.StatusOK, security.GetPublicKey())
+}
+
+// Publish the public keys used to verify tokens as a JSON Web Key Set
+func GetJWKSHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, jwt.PublicKeys())
 }
 
 // Check Token
@@ -22,19 +28,23 @@ func ValidateToken(c *gin.Context) {
 	authHeader := c.Request.Header.Get("Authorization")
 
 	parts := strings.SplitN(authHeader, " ", 2)
+	// Requests with an API key carry no token
 	if !(len(parts) == 2 && parts[0] == "Bearer") {
 		ResponseWithMsg(c, i18n.CodeInvalidToken, false)
+		return
 	}
 	// Parse Token
 	mc, resStaus := jwt.ParseToken(parts[1])
 	if resStaus != i18n.StatusOK {
 		ResponseWithMsg(c, resStaus, false)
+		return
 	}
 
 	expireAt := time.Unix(mc.ExpiresAt, 0)
 	d := time.Since(expireAt)
 	if (d.Seconds() + pub.TokenAboutToExpirtSeconds) > 0 {
 		ResponseWithMsg(c, i18n.CodeAboutToExpireToken, false)
+		return
 	}
 
 	ResponseWithMsg(c, i18n.StatusOK, true)
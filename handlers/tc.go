@@ -25,7 +25,7 @@ func AddTCHandler(c *gin.Context) {
 	}
 	tc.Creator.ID = operatorID
 	// Add
-	resStatus, _ = tc.Add()
+	resStatus, _ = tc.Add(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, tc)
 }
@@ -47,7 +47,7 @@ func EditTCHandler(c *gin.Context) {
 	}
 	tc.Modifier.ID = operatorID
 	// Modify
-	resStatus, _ = tc.Edit()
+	resStatus, _ = tc.Edit(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, tc)
 }
@@ -68,7 +68,7 @@ func DeleteTCHandler(c *gin.Context) {
 		return
 	}
 	// Delete
-	resStatus, _ = tc.Delete(operatorID)
+	resStatus, _ = tc.Delete(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, tc)
 }
@@ -89,7 +89,7 @@ func DeleteTCsHandler(c *gin.Context) {
 		return
 	}
 	// Batch delete
-	resStatus, _ = pg.DeleteTCs(tcs, operatorID)
+	resStatus, _ = pg.DeleteTCs(tcs, GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, tcs)
 }
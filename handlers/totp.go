@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"sccsmsserver/db/pg"
+	"sccsmsserver/i18n"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Second login step handler
+func VerifyTOTPLoginHandler(c *gin.Context) {
+	p := new(pg.ParamTOTPLogin)
+	if err := c.ShouldBind(p); err != nil {
+		zap.L().Error("VerifyTOTPLoginHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, nil)
+		return
+	}
+	p.ClientIP = c.ClientIP()
+	p.ClientType = c.Request.Header.Get("XClientType")
+	p.UserAgent = c.Request.UserAgent()
+	tp, resStatus, _ := pg.VerifyTOTPLogin(p)
+	ResponseWithMsg(c, resStatus, tp)
+}
+
+// Generate TOTP secret during login handler
+func PreAuthSetupTOTPHandler(c *gin.Context) {
+	p := new(pg.ParamPreAuth)
+	if err := c.ShouldBind(p); err != nil {
+		zap.L().Error("PreAuthSetupTOTPHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, nil)
+		return
+	}
+	p.ClientType = c.Request.Header.Get("XClientType")
+	setup, resStatus, _ := pg.PreAuthSetupTOTP(p)
+	ResponseWithMsg(c, resStatus, setup)
+}
+
+// Enable TOTP during login handler
+func PreAuthEnableTOTPHandler(c *gin.Context) {
+	p := new(pg.ParamTOTPLogin)
+	if err := c.ShouldBind(p); err != nil {
+		zap.L().Error("PreAuthEnableTOTPHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, nil)
+		return
+	}
+	p.ClientIP = c.ClientIP()
+	p.ClientType = c.Request.Header.Get("XClientType")
+	p.UserAgent = c.Request.UserAgent()
+	te, resStatus, _ := pg.PreAuthEnableTOTP(p)
+	ResponseWithMsg(c, resStatus, te)
+}
+
+// Generate TOTP secret handler
+func SetupTOTPHandler(c *gin.Context) {
+	userID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
+	setup, resStatus, _ := pg.SetupTOTP(userID)
+	ResponseWithMsg(c, resStatus, setup)
+}
+
+// Enable TOTP handler
+func EnableTOTPHandler(c *gin.Context) {
+	p := new(pg.ParamTOTPCode)
+	if err := c.ShouldBind(p); err != nil {
+		zap.L().Error("EnableTOTPHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, nil)
+		return
+	}
+	userID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
+	var te pg.TOTPEnrollment
+	te.RecoveryCodes, resStatus, _ = pg.EnableTOTP(userID, p.Code)
+	ResponseWithMsg(c, resStatus, te)
+}
+
+// Disable TOTP handler
+func DisableTOTPHandler(c *gin.Context) {
+	p := new(pg.ParamTOTPCode)
+	if err := c.ShouldBind(p); err != nil {
+		zap.L().Error("DisableTOTPHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, nil)
+		return
+	}
+	userID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
+	resStatus, _ = pg.DisableTOTP(userID, p.Code)
+	ResponseWithMsg(c, resStatus, nil)
+}
+
+// Regenerate recovery codes handler
+func RegenerateRecoveryCodesHandler(c *gin.Context) {
+	p := new(pg.ParamTOTPCode)
+	if err := c.ShouldBind(p); err != nil {
+		zap.L().Error("RegenerateRecoveryCodesHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, nil)
+		return
+	}
+	userID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
+	var te pg.TOTPEnrollment
+	te.RecoveryCodes, resStatus, _ = pg.RegenerateRecoveryCodes(userID, p.Code)
+	ResponseWithMsg(c, resStatus, te)
+}
+
+// Reset user TOTP handler
+func ResetUserTOTPHandler(c *gin.Context) {
+	u := new(pg.User)
+	err := c.ShouldBind(u)
+	if err != nil {
+		zap.L().Error("ResetUserTOTPHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get operator id
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, u)
+		return
+	}
+	u.Modifier.ID = operatorID
+	resStatus, _ = u.ResetTOTP()
+	ResponseWithMsg(c, resStatus, u)
+}
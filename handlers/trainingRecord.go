@@ -3,6 +3,7 @@ package handlers
 import (
 	"sccsmsserver/db/pg"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pub"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -26,7 +27,7 @@ func AddTRHandler(c *gin.Context) {
 	}
 	tr.Creator.ID = operatorID
 	// Add
-	resStatus, _ = tr.Add()
+	resStatus, _ = tr.Add(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, tr)
 }
@@ -40,8 +41,14 @@ func GetTRListHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, qp)
+		return
+	}
 	// Get List
-	trs, resStatus, _ := pg.GetTRList(qp.QueryString)
+	trs, resStatus, _ := pg.GetTRList(qp.Filter, ds)
 	// Response
 	ResponseWithMsg(c, resStatus, trs)
 }
@@ -55,8 +62,19 @@ func GetTRInfoByHIDHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// The voucher must be in the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
+	resStatus, _ = ds.CheckVoucher(pub.TR, tr.HID)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
 	// Get Training Record List
-	resStatus, _ := tr.GetDetailByHID()
+	resStatus, _ = tr.GetDetailByHID()
 	// Response
 	ResponseWithMsg(c, resStatus, tr)
 }
@@ -78,7 +96,7 @@ func EditTRHandler(c *gin.Context) {
 	}
 	tr.Modifier.ID = operatorID
 	// Modify
-	resStatus, _ = tr.Edit()
+	resStatus, _ = tr.Edit(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, tr)
 }
@@ -99,7 +117,7 @@ func DeleteTRHandler(c *gin.Context) {
 		return
 	}
 	// Delete
-	resStatus, _ = tr.Delete(operatorID)
+	resStatus, _ = tr.Delete(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, tr)
 }
@@ -120,7 +138,7 @@ func ConfirmTRHandler(c *gin.Context) {
 		return
 	}
 	// Confirm
-	resStatus, _ = tr.Confirm(operatorID)
+	resStatus, _ = tr.Confirm(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, tr)
 }
@@ -141,7 +159,7 @@ func UnConfirmTRHandler(c *gin.Context) {
 		return
 	}
 	// UnConfirm
-	resStatus, _ = tr.UnConfirm(operatorID)
+	resStatus, _ = tr.UnConfirm(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, tr)
 }
@@ -155,8 +173,14 @@ func GetTaughtLessonsReportHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, qp)
+		return
+	}
 	// Get report
-	glrs, resStatus, _ := pg.GetTaughtLessonsReport(qp.QueryString)
+	glrs, resStatus, _ := pg.GetTaughtLessonsReport(qp.Filter, ds)
 	// Response
 	ResponseWithMsg(c, resStatus, glrs)
 }
@@ -170,8 +194,14 @@ func GetRecivedTrainingReportHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, qp)
+		return
+	}
 	// Get Report
-	rtrs, resStatus, _ := pg.GetRecivedTrainingReport(qp.QueryString)
+	rtrs, resStatus, _ := pg.GetRecivedTrainingReport(qp.Filter, ds)
 	// Response
 	ResponseWithMsg(c, resStatus, rtrs)
 }
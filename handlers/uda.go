@@ -26,7 +26,7 @@ func AddUDAHandler(c *gin.Context) {
 	}
 	uda.Creator.ID = creatorID
 	// Add
-	resStatus, _ = uda.Add()
+	resStatus, _ = uda.Add(GetAuditActor(c, creatorID))
 	ResponseWithMsg(c, resStatus, uda)
 }
 
@@ -46,7 +46,7 @@ func EditUDAHandler(c *gin.Context) {
 	}
 	uda.Modifier.ID = modifierID
 	// Modify
-	resStatus, _ = uda.Edit()
+	resStatus, _ = uda.Edit(GetAuditActor(c, modifierID))
 	ResponseWithMsg(c, resStatus, uda)
 }
 
@@ -67,7 +67,7 @@ func DeleteUDAHandler(c *gin.Context) {
 	}
 	uda.Modifier.ID = modifierID
 	// Delete
-	resStatus, _ = uda.Delete()
+	resStatus, _ = uda.Delete(GetAuditActor(c, modifierID))
 	// Response
 	ResponseWithMsg(c, resStatus, uda)
 }
@@ -87,7 +87,7 @@ func DeleteUDAsHandler(c *gin.Context) {
 		return
 	}
 	// Batch delete
-	resStatus, _ = pg.DeleteUDAs(udas, modifyUserId)
+	resStatus, _ = pg.DeleteUDAs(udas, GetAuditActor(c, modifyUserId))
 	// Response
 	ResponseWithMsg(c, resStatus, udas)
 }
@@ -25,7 +25,7 @@ func AddUDCHandler(c *gin.Context) {
 	}
 	udc.Creator.ID = creatorID
 	// Add
-	resStatus, _ = udc.Add()
+	resStatus, _ = udc.Add(GetAuditActor(c, creatorID))
 	ResponseWithMsg(c, resStatus, udc)
 }
 
@@ -52,7 +52,7 @@ func EditUDCHandler(c *gin.Context) {
 	}
 	udc.Modifier.ID = modifierID
 	// Modify
-	resStatus, _ = udc.Edit()
+	resStatus, _ = udc.Edit(GetAuditActor(c, modifierID))
 
 	ResponseWithMsg(c, resStatus, udc)
 }
@@ -74,7 +74,7 @@ func DeleteUDCHandler(c *gin.Context) {
 	}
 	udc.Modifier.ID = modifierID
 	// Delete
-	resStatus, _ = udc.Delete()
+	resStatus, _ = udc.Delete(GetAuditActor(c, modifierID))
 	ResponseWithMsg(c, resStatus, udc)
 }
 
@@ -121,7 +121,7 @@ func DeleteUDCsHandler(c *gin.Context) {
 		return
 	}
 	// Batch Delete
-	statusCode, _ := pg.DeleteUDCs(udcs, modifierID)
+	statusCode, _ := pg.DeleteUDCs(udcs, GetAuditActor(c, modifierID))
 	// Resopnse
 	ResponseWithMsg(c, statusCode, udcs)
 }
@@ -6,6 +6,7 @@ import (
 	"sccsmsserver/i18n"
 	"sccsmsserver/pkg/aws"
 	"sccsmsserver/pkg/security"
+	"sccsmsserver/pub"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -27,9 +28,30 @@ func LoginHandler(c *gin.Context) {
 	p.UserAgent = c.Request.UserAgent()
 
 	// User login validation
-	resStatus, token, _ := pg.Login(p)
+	resStatus, tp, _ := pg.Login(p)
 	// Respond to client request
-	ResponseWithMsg(c, resStatus, token)
+	if p.IssueRefreshToken || resStatus == i18n.StatusTOTPRequired || resStatus == i18n.StatusTOTPEnrollRequired ||
+		resStatus == i18n.StatusPasswordChangeRequired || resStatus == i18n.StatusPasswordExpired {
+		ResponseWithMsg(c, resStatus, tp)
+		return
+	}
+	ResponseWithMsg(c, resStatus, tp.Token)
+}
+
+// Refresh token handler
+func RefreshTokenHandler(c *gin.Context) {
+	p := new(pg.ParamRefreshToken)
+	if err := c.ShouldBind(p); err != nil {
+		zap.L().Error("RefreshTokenHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, nil)
+		return
+	}
+	p.ClientIP = c.ClientIP()
+	p.ClientType = c.Request.Header.Get("XClientType")
+	p.UserAgent = c.Request.UserAgent()
+	// Rotate the refresh token
+	tp, resStatus, _ := pg.RefreshToken(p)
+	ResponseWithMsg(c, resStatus, tp)
 }
 
 // Get User Information handler
@@ -61,19 +83,22 @@ func AddUserHandler(c *gin.Context) {
 	}
 	u.Creator.ID = operatorID
 
-	// Decrypt the RSA password sent from the frent end
-	op, _ := base64.StdEncoding.DecodeString(u.Password)
-	oriPassword, err := security.ScRsa.Decrypt(op)
-	if err != nil {
-		zap.L().Error("Decrypt password failed", zap.Error(err))
-		ResponseWithMsg(c, i18n.CodeInternalError, nil)
-		return
+	// Decrypt the RSA password sent from the frent end.
+	// Without a password, a password is generated.
+	if u.Password != "" {
+		op, _ := base64.StdEncoding.DecodeString(u.Password)
+		oriPassword, err := security.Decrypt(u.KeyID, op)
+		if err != nil {
+			zap.L().Error("Decrypt password failed", zap.Error(err))
+			ResponseWithMsg(c, i18n.CodeInternalError, nil)
+			return
+		}
+		u.Password = string(oriPassword)
 	}
-	u.Password = string(oriPassword)
 	// Add user
-	resStatus, _ = u.Add()
+	resStatus, _ = u.Add(GetAuditActor(c, operatorID))
 	// Response
-	ResponseWithMsg(c, resStatus, u)
+	responseWithPasswordStatus(c, resStatus, u)
 }
 
 // Edit user handler
@@ -90,7 +115,7 @@ func EditUserHandler(c *gin.Context) {
 	if u.Password != "" {
 		// Decrypt the RSA password sent from the frent end.
 		op, _ := base64.StdEncoding.DecodeString(u.Password)
-		oriPassword, err := security.ScRsa.Decrypt(op)
+		oriPassword, err := security.Decrypt(u.KeyID, op)
 		if err != nil {
 			zap.L().Error("Decrypt password failed", zap.Error(err))
 			ResponseWithMsg(c, i18n.CodeInternalError, nil)
@@ -106,9 +131,9 @@ func EditUserHandler(c *gin.Context) {
 	}
 	u.Modifier.ID = operatorID
 	// Edit user
-	resStatus, _ = u.Edit()
+	resStatus, _ = u.Edit(GetAuditActor(c, operatorID))
 	// Resopnse
-	ResponseWithMsg(c, resStatus, u)
+	responseWithPasswordStatus(c, resStatus, u)
 }
 
 // User Updates VIA personal center handler
@@ -143,10 +168,11 @@ func LogoutHandler(c *gin.Context) {
 		ResponseWithMsg(c, resStatus, nil)
 		return
 	}
-	// Delete online user from local cache
+	// Delete the current session from cache
 	var ou pg.OnlineUser
 	ou.ClientType = clientType
 	ou.User.ID = operatorID
+	ou.SessionID = c.GetString(pub.CTXSessionID)
 	resStatus, err := ou.Del()
 	if err != nil {
 		zap.L().Error("LogoutHandler ou.Del failed", zap.Error(err))
@@ -179,7 +205,7 @@ func DeleteUserHandler(c *gin.Context) {
 	}
 	u.Modifier.ID = operatorID
 	// Delete user
-	resStatus, _ = u.Delete()
+	resStatus, _ = u.Delete(GetAuditActor(c, operatorID))
 	ResponseWithMsg(c, resStatus, u)
 }
 
@@ -199,7 +225,7 @@ func DeleteUsersHandler(c *gin.Context) {
 		return
 	}
 	// Batch Delete
-	resStatus, _ = pg.DeleteUsers(users, operatorID)
+	resStatus, _ = pg.DeleteUsers(users, GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, users)
 }
@@ -260,21 +286,21 @@ func ChangeUserPasswordHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInternalError, err)
 		return
 	}
-	oriPwd, err := security.ScRsa.Decrypt(op)
+	oriPwd, err := security.Decrypt(p.KeyID, op)
 	if err != nil {
-		zap.L().Error("ChangeUserPasswordHandler security.ScRsa.Decrypt(op) failed", zap.Error(err))
+		zap.L().Error("ChangeUserPasswordHandler security.Decrypt(op) failed", zap.Error(err))
 		ResponseWithMsg(c, i18n.CodeInternalError, err)
 		return
 	}
-	oriNewPwd, err := security.ScRsa.Decrypt(np)
+	oriNewPwd, err := security.Decrypt(p.KeyID, np)
 	if err != nil {
-		zap.L().Error("ChangeUserPasswordHandler security.ScRsa.Decrypt(np) failed", zap.Error(err))
+		zap.L().Error("ChangeUserPasswordHandler security.Decrypt(np) failed", zap.Error(err))
 		ResponseWithMsg(c, i18n.CodeInternalError, err)
 		return
 	}
-	oriConfirmNewPwd, err := security.ScRsa.Decrypt(cnp)
+	oriConfirmNewPwd, err := security.Decrypt(p.KeyID, cnp)
 	if err != nil {
-		zap.L().Error("ChangeUserPasswordHandler security.ScRsa.Decrypt(cnp) failed", zap.Error(err))
+		zap.L().Error("ChangeUserPasswordHandler security.Decrypt(cnp) failed", zap.Error(err))
 		ResponseWithMsg(c, i18n.CodeInternalError, err)
 		return
 	}
@@ -285,7 +311,7 @@ func ChangeUserPasswordHandler(c *gin.Context) {
 
 	resStatus, _ := p.ChangePassword()
 
-	ResponseWithMsg(c, resStatus, nil)
+	responseWithPasswordStatus(c, resStatus, nil)
 }
 
 // Change User avatar handler
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"sccsmsserver/db/pg"
+	"sccsmsserver/i18n"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Get webhook list handler
+func GetWebhooksHandler(c *gin.Context) {
+	whs, resStatus, _ := pg.GetWebhooks()
+	ResponseWithMsg(c, resStatus, whs)
+}
+
+// Add webhook handler
+func AddWebhookHandler(c *gin.Context) {
+	wh := new(pg.Webhook)
+	err := c.ShouldBind(wh)
+	if err != nil {
+		zap.L().Error("AddWebhookHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
+	wh.Creator.ID = operatorID
+	resStatus, _ = wh.Add(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, wh)
+}
+
+// Modify webhook handler
+func EditWebhookHandler(c *gin.Context) {
+	wh := new(pg.Webhook)
+	err := c.ShouldBind(wh)
+	if err != nil {
+		zap.L().Error("EditWebhookHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
+	wh.Modifier.ID = operatorID
+	resStatus, _ = wh.Edit(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, wh)
+}
+
+// Delete webhook handler
+func DeleteWebhookHandler(c *gin.Context) {
+	wh := new(pg.Webhook)
+	err := c.ShouldBind(wh)
+	if err != nil {
+		zap.L().Error("DeleteWebhookHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
+	wh.Modifier.ID = operatorID
+	wh.Secret = ""
+	resStatus, _ = wh.Delete(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, wh)
+}
+
+// Get webhook delivery log by pagination handler
+func GetWebhookDeliveriesPaginationHandler(c *gin.Context) {
+	pqp := new(pg.PagingQueryParams)
+	err := c.ShouldBind(pqp)
+	if err != nil {
+		zap.L().Error("GetWebhookDeliveriesPaginationHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	wdp, resStatus, _ := pg.GetWebhookDeliveriesPagination(*pqp)
+	ResponseWithMsg(c, resStatus, wdp)
+}
+
+// Redeliver webhook delivery handler
+func RedeliverWebhookHandler(c *gin.Context) {
+	wd := new(pg.WebhookDelivery)
+	err := c.ShouldBind(wd)
+	if err != nil {
+		zap.L().Error("RedeliverWebhookHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	resStatus, _ := wd.Redeliver()
+	ResponseWithMsg(c, resStatus, wd)
+}
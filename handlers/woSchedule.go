@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"sccsmsserver/db/pg"
+	"sccsmsserver/i18n"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Get Work Order schedule list handler
+func GetWOSchedulesHandler(c *gin.Context) {
+	wss, resStatus, _ := pg.GetWOSchedules()
+	ResponseWithMsg(c, resStatus, wss)
+}
+
+// Add Work Order schedule handler
+func AddWOScheduleHandler(c *gin.Context) {
+	ws := new(pg.WOSchedule)
+	err := c.ShouldBind(ws)
+	if err != nil {
+		zap.L().Error("AddWOScheduleHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, ws)
+		return
+	}
+	ws.Creator.ID = operatorID
+	resStatus, _ = ws.Add(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, ws)
+}
+
+// Modify Work Order schedule handler
+func EditWOScheduleHandler(c *gin.Context) {
+	ws := new(pg.WOSchedule)
+	err := c.ShouldBind(ws)
+	if err != nil {
+		zap.L().Error("EditWOScheduleHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, ws)
+		return
+	}
+	ws.Modifier.ID = operatorID
+	resStatus, _ = ws.Edit(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, ws)
+}
+
+// Delete Work Order schedule handler
+func DeleteWOScheduleHandler(c *gin.Context) {
+	ws := new(pg.WOSchedule)
+	err := c.ShouldBind(ws)
+	if err != nil {
+		zap.L().Error("DeleteWOScheduleHandler invalid param", zap.Error(err))
+		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
+		return
+	}
+	// Get Operator ID
+	operatorID, resStatus := GetOperatorID(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, ws)
+		return
+	}
+	ws.Modifier.ID = operatorID
+	resStatus, _ = ws.Delete(GetAuditActor(c, operatorID))
+	ResponseWithMsg(c, resStatus, ws)
+}
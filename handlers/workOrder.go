@@ -3,6 +3,7 @@ package handlers
 import (
 	"sccsmsserver/db/pg"
 	"sccsmsserver/i18n"
+	"sccsmsserver/pub"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -17,8 +18,14 @@ func GetWOReferHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, qp)
+		return
+	}
 	// Get list
-	wors, resStatus, _ := pg.GetWORefer(qp.QueryString)
+	wors, resStatus, _ := pg.GetWORefer(qp.Filter, ds)
 	// Response
 	ResponseWithMsg(c, resStatus, wors)
 }
@@ -32,8 +39,14 @@ func GetWOListHanlder(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// Get the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, qp)
+		return
+	}
 	// Get list
-	wos, resStatus, _ := pg.GetWOList(qp.QueryString)
+	wos, resStatus, _ := pg.GetWOList(qp.Filter, ds)
 	// Response
 	ResponseWithMsg(c, resStatus, wos)
 }
@@ -47,8 +60,19 @@ func GetWOInfoByIDHandler(c *gin.Context) {
 		ResponseWithMsg(c, i18n.CodeInvalidParm, err)
 		return
 	}
+	// The voucher must be in the data scope of the operator
+	ds, resStatus := GetOperatorDataScope(c)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
+	resStatus, _ = ds.CheckVoucher(pub.WO, wo.HID)
+	if resStatus != i18n.StatusOK {
+		ResponseWithMsg(c, resStatus, nil)
+		return
+	}
 	// Get Details
-	resStatus, _ := wo.GetDetailByHID()
+	resStatus, _ = wo.GetDetailByHID()
 	// Resoponse
 	ResponseWithMsg(c, resStatus, wo)
 }
@@ -70,7 +94,7 @@ func AddWOHandler(c *gin.Context) {
 	}
 	wo.Creator.ID = operatorID
 	// Add
-	resStatus, _ = wo.Add()
+	resStatus, _ = wo.Add(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, wo)
 }
@@ -92,7 +116,7 @@ func EditWOHandler(c *gin.Context) {
 	}
 	wo.Modifier.ID = operatorID
 	// Modify
-	resStatus, _ = wo.Edit()
+	resStatus, _ = wo.Edit(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, wo)
 }
@@ -113,7 +137,7 @@ func DeleteWOHandler(c *gin.Context) {
 		return
 	}
 	// Delete
-	resStatus, _ = wo.Delete(operatorID)
+	resStatus, _ = wo.Delete(GetAuditActor(c, operatorID))
 	// Resoponse
 	ResponseWithMsg(c, resStatus, wo)
 }
@@ -134,7 +158,7 @@ func DeleteWOsHandler(c *gin.Context) {
 		return
 	}
 	// Batch Delete
-	resStatus, _ = pg.DeleteWOs(wos, operatorID)
+	resStatus, _ = pg.DeleteWOs(wos, GetAuditActor(c, operatorID))
 	ResponseWithMsg(c, resStatus, wos)
 }
 
@@ -154,7 +178,7 @@ func ConfirmWOHandler(c *gin.Context) {
 		return
 	}
 	// Confirm
-	resStatus, _ = wo.Confirm(operatorID)
+	resStatus, _ = wo.Confirm(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, wo)
 }
@@ -175,7 +199,7 @@ func UnConfirmWOHandler(c *gin.Context) {
 		return
 	}
 	// UnConfirm
-	resStatus, _ = wo.UnConfirm(operatorID)
+	resStatus, _ = wo.UnConfirm(GetAuditActor(c, operatorID))
 	// Response
 	ResponseWithMsg(c, resStatus, wo)
 }
@@ -3,16 +3,18 @@ package i18n
 const (
 	// System Message
 	// CodeSuccess       ResKey = "CodeSuccess"
-	CodeInvalidToken       ResKey = "CodeInvalidToken"
-	CodeAboutToExpireToken ResKey = "CodeAboutToExpireToken"
-	CodeNeedLogin          ResKey = "CodeNeedLogin"
-	CodeTokenDestroy       ResKey = "CodeTokenDestroy"
-	CodeLoginOther         ResKey = "CodeLoginOther"
-	CodeInvalidParm        ResKey = "CodeInvalidParm"
-	CodeServerBusy         ResKey = "CodeServerBusy"
-	CodeInternalError      ResKey = "CodeInternalError"
-	CodeClientUnknown      ResKey = "CodeClientUnknown"
-	CodeClientEmpty        ResKey = "CodeClientEmpty"
+	CodeInvalidToken        ResKey = "CodeInvalidToken"
+	CodeAboutToExpireToken  ResKey = "CodeAboutToExpireToken"
+	CodeNeedLogin           ResKey = "CodeNeedLogin"
+	CodeTokenDestroy        ResKey = "CodeTokenDestroy"
+	CodeLoginOther          ResKey = "CodeLoginOther"
+	CodeInvalidRefreshToken ResKey = "CodeInvalidRefreshToken"
+	CodeRefreshTokenReused  ResKey = "CodeRefreshTokenReused"
+	CodeInvalidParm         ResKey = "CodeInvalidParm"
+	CodeServerBusy          ResKey = "CodeServerBusy"
+	CodeInternalError       ResKey = "CodeInternalError"
+	CodeClientUnknown       ResKey = "CodeClientUnknown"
+	CodeClientEmpty         ResKey = "CodeClientEmpty"
 	// Menu Name
 	MenuDashboard      ResKey = "MenuDashboard"
 	MenuCalendar       ResKey = "MenuCalendar"
@@ -20,6 +22,7 @@ const (
 	MenuAddressBook    ResKey = "MenuAddressBook"
 	MenuCSM            ResKey = "MenuCSM"
 	MenuWO             ResKey = "MenuWO"
+	MenuWOS            ResKey = "MenuWOS"
 	MenuEO             ResKey = "MenuEO"
 	MenuEOReview       ResKey = "MenuEOReview"
 	MenuIRF            ResKey = "MenuIRF"
@@ -58,9 +61,14 @@ const (
 	MenuUser           ResKey = "MenuUser"
 	MenuPA             ResKey = "MenuPA"
 	MenuOU             ResKey = "MenuOU"
+	MenuLS             ResKey = "MenuLS"
+	MenuAT             ResKey = "MenuAT"
+	MenuAK             ResKey = "MenuAK"
 	MenuSettings       ResKey = "MenuSettings"
 	MenuCSO            ResKey = "MenuCSO"
 	MenuLPS            ResKey = "MenuLPS"
+	MenuAF             ResKey = "MenuAF"
+	MenuWH             ResKey = "MenuWH"
 	MenuProfile        ResKey = "MenuProfile"
 	MenuAbout          ResKey = "MenuAbout"
 	// Logic Message
@@ -70,16 +78,41 @@ const (
 	StatusOtherEdit   ResKey = "StatusOtherEdit"
 	StatusDataDeleted ResKey = "StatusDataDeleted"
 	// Authorization (10100-10199)
-	StatusUserNotExist      ResKey = "StatusUserNotExist"
-	StatusInvalidPassword   ResKey = "StatusInvalidPassword"
-	StatusPasswordDisaccord ResKey = "StatusPasswordDisaccord"
-	StatusUserDisabled      ResKey = "StatusUserDisabled"
-	StatusUserLocked        ResKey = "StatusUserLocked"
-	StatusOverAuthorization ResKey = "StatusOverAuthorization"
+	StatusUserNotExist              ResKey = "StatusUserNotExist"
+	StatusInvalidPassword           ResKey = "StatusInvalidPassword"
+	StatusPasswordDisaccord         ResKey = "StatusPasswordDisaccord"
+	StatusUserDisabled              ResKey = "StatusUserDisabled"
+	StatusUserLocked                ResKey = "StatusUserLocked"
+	StatusOverAuthorization         ResKey = "StatusOverAuthorization"
+	StatusPermissionDenied          ResKey = "StatusPermissionDenied"
+	StatusTOTPRequired              ResKey = "StatusTOTPRequired"
+	StatusTOTPEnrollRequired        ResKey = "StatusTOTPEnrollRequired"
+	StatusTOTPInvalidCode           ResKey = "StatusTOTPInvalidCode"
+	StatusTOTPAlreadyEnabled        ResKey = "StatusTOTPAlreadyEnabled"
+	StatusTOTPNotSetup              ResKey = "StatusTOTPNotSetup"
+	StatusTOTPRequiredByRole        ResKey = "StatusTOTPRequiredByRole"
+	StatusTOTPUnavailable           ResKey = "StatusTOTPUnavailable"
+	StatusPreAuthInvalid            ResKey = "StatusPreAuthInvalid"
+	StatusAuthSourceUnavailable     ResKey = "StatusAuthSourceUnavailable"
+	StatusPasswordManagedExternally ResKey = "StatusPasswordManagedExternally"
+	StatusOIDCDisabled              ResKey = "StatusOIDCDisabled"
+	StatusOIDCStateInvalid          ResKey = "StatusOIDCStateInvalid"
+	StatusOIDCFailed                ResKey = "StatusOIDCFailed"
+	StatusPasswordTooShort          ResKey = "StatusPasswordTooShort"
+	StatusPasswordCharClasses       ResKey = "StatusPasswordCharClasses"
+	StatusPasswordReused            ResKey = "StatusPasswordReused"
+	StatusPasswordChangeRequired    ResKey = "StatusPasswordChangeRequired"
+	StatusPasswordExpired           ResKey = "StatusPasswordExpired"
+	StatusIPAccessInvalid           ResKey = "StatusIPAccessInvalid"
+	StatusIPAccessExist             ResKey = "StatusIPAccessExist"
+	StatusIPDenied                  ResKey = "StatusIPDenied"
+	StatusTooManyRequests           ResKey = "StatusTooManyRequests"
 	// Role(10200-10299)
-	StatusRoleNameExist ResKey = "StatusRoleNameExist"
-	StatusRoleUserExist ResKey = "StatusRoleUserExist"
-	StatusRoleAuthExist ResKey = "StatusRoleAuthExist"
+	StatusRoleNameExist           ResKey = "StatusRoleNameExist"
+	StatusRoleUserExist           ResKey = "StatusRoleUserExist"
+	StatusRoleAuthExist           ResKey = "StatusRoleAuthExist"
+	StatusPermissionActionInvalid ResKey = "StatusPermissionActionInvalid"
+	StatusRoleDataScopeInvalid    ResKey = "StatusRoleDataScopeInvalid"
 	// User(10300-10399)
 	StatusUserCodeExist   ResKey = "StatusUserCodeExist"
 	StatusUserNameExist   ResKey = "StatusUserNameExist"
@@ -110,8 +143,10 @@ const (
 	StatusEPCFatherCircle  ResKey = "StatusEPCFatherCircle"
 	StatusEPCLowLevelExist ResKey = "StatusEPCLowLevelExist"
 	// Execution Project Master Data (11000-11099)
-	StatusEPCodeExist        ResKey = "StatusEPCodeExist"
-	StatusEPChangeResultType ResKey = "StatusEPChangeResultType"
+	StatusEPCodeExist         ResKey = "StatusEPCodeExist"
+	StatusEPChangeResultType  ResKey = "StatusEPChangeResultType"
+	StatusErrorRuleInvalid    ResKey = "StatusErrorRuleInvalid"
+	StatusErrorRuleRLNotExist ResKey = "StatusErrorRuleRLNotExist"
 	// Execution Project Template (11100-11199)
 	StatusEPTCodeExist ResKey = "StatusEPTCodeExist"
 	// File Metadata(11200-11299)
@@ -120,14 +155,24 @@ const (
 	StatusFileGetUrlFailed ResKey = "StatusFileGetUrlFailed"
 	StatusFileNotExist     ResKey = "StatusFileNotExist"
 	// Work Order (11300-11399)
-	StatusWOOtherEdit ResKey = "StatusWOOtherEdit"
+	StatusWOOtherEdit     ResKey = "StatusWOOtherEdit"
+	StatusWOEOUnconfirmed ResKey = "StatusWOEOUnconfirmed"
 	// Execution Order (11400-11499)
-	StatusEOBodyNoConfirm ResKey = "StatusEOBodyNoConfirm"
-	StatusIssueResolved   ResKey = "StatusIssueResolved"
+	StatusEOBodyNoConfirm  ResKey = "StatusEOBodyNoConfirm"
+	StatusIssueResolved    ResKey = "StatusIssueResolved"
+	StatusEORowInvalid     ResKey = "StatusEORowInvalid"
+	StatusEORowEPAInvalid  ResKey = "StatusEORowEPAInvalid"
+	StatusEOValueNotNumber ResKey = "StatusEOValueNotNumber"
+	StatusEOValueNotDate   ResKey = "StatusEOValueNotDate"
+	StatusEOValueNotOption ResKey = "StatusEOValueNotOption"
+	StatusEOValueNotFound  ResKey = "StatusEOValueNotFound"
+	StatusEOFileRequired   ResKey = "StatusEOFileRequired"
+	StatusEOPhotoRequired  ResKey = "StatusEOPhotoRequired"
 	// Message (11500-11599)
 	StatusMsgOnlyReadSelf ResKey = "StatusMsgOnlyReadSelf"
 	// Risk Level（11600-11699)
-	StatusRLNameExist ResKey = "StatusRLNameExist"
+	StatusRLNameExist    ResKey = "StatusRLNameExist"
+	StatusRLHoursInvalid ResKey = "StatusRLHoursInvalid"
 	// Document Category (11700-11799)
 	StatusDCNameExist     ResKey = "StatusDCNameExist"
 	StatusDCLowLevelExist ResKey = "StatusDCLowLevelExist"
@@ -146,6 +191,39 @@ const (
 	StatusTRBodyNoConfirm ResKey = "StatusTRBodyNoConfirm"
 	// PPE Issuance Form (12400-12499)
 	StatusPPEIFBodyNoConfirm ResKey = "StatusPPEIFBodyNoConfirm"
+	// API Key (12500-12599)
+	StatusAPIKeyInvalid        ResKey = "StatusAPIKeyInvalid"
+	StatusAPIKeyNotServiceUser ResKey = "StatusAPIKeyNotServiceUser"
+	StatusAPIKeyExpiryInvalid  ResKey = "StatusAPIKeyExpiryInvalid"
+	StatusRoleAPIKeyExist      ResKey = "StatusRoleAPIKeyExist"
+	StatusAPIKeyRoleNotHeld    ResKey = "StatusAPIKeyRoleNotHeld"
+	StatusAPIKeyRouteDenied    ResKey = "StatusAPIKeyRouteDenied"
+	// Approval (12600-12699)
+	StatusApprovalSubmitted       ResKey = "StatusApprovalSubmitted"
+	StatusApprovalPending         ResKey = "StatusApprovalPending"
+	StatusApprovalNotPending      ResKey = "StatusApprovalNotPending"
+	StatusApprovalNotApprover     ResKey = "StatusApprovalNotApprover"
+	StatusApprovalSubmitter       ResKey = "StatusApprovalSubmitter"
+	StatusApprovalStageApproved   ResKey = "StatusApprovalStageApproved"
+	StatusApprovalCommentRequired ResKey = "StatusApprovalCommentRequired"
+	StatusApprovalFlowExist       ResKey = "StatusApprovalFlowExist"
+	StatusApprovalFlowInvalid     ResKey = "StatusApprovalFlowInvalid"
+	StatusApprovalFlowInUse       ResKey = "StatusApprovalFlowInUse"
+	StatusApprovalFlowUsed        ResKey = "StatusApprovalFlowUsed"
+	// Work Order Schedule (12700-12799)
+	StatusWOScheduleInvalid     ResKey = "StatusWOScheduleInvalid"
+	StatusWOScheduleRuleInvalid ResKey = "StatusWOScheduleRuleInvalid"
+	StatusWOScheduleUsed        ResKey = "StatusWOScheduleUsed"
+	// Email Notification (12800-12899)
+	StatusNotifyPrefInvalid ResKey = "StatusNotifyPrefInvalid"
+	StatusMailDisabled      ResKey = "StatusMailDisabled"
+	StatusMailNoAddress     ResKey = "StatusMailNoAddress"
+	StatusMailSendFailed    ResKey = "StatusMailSendFailed"
+	// Webhook (12900-12999)
+	StatusWebhookInvalid       ResKey = "StatusWebhookInvalid"
+	StatusWebhookNameExist     ResKey = "StatusWebhookNameExist"
+	StatusWebhookDisabled      ResKey = "StatusWebhookDisabled"
+	StatusWebhookAddressDenied ResKey = "StatusWebhookAddressDenied"
 	// Referenced （80000-89999）
 	StatusUDUsed             ResKey = "StatusUDUsed"
 	StatusEPAUsed            ResKey = "StatusEPAUsed"
@@ -247,4 +325,18 @@ const (
 	StatusVoucherOnlyCreateEdit    ResKey = "StatusVoucherOnlyCreateEdit"
 	StatusVoucherNoConfirm         ResKey = "StatusVoucherNoConfirm"
 	StatusVoucherCancelConfirmSelf ResKey = "StatusVoucherCancelConfirmSelf"
+	StatusFilterInvalid            ResKey = "StatusFilterInvalid"
+	// Email notification templates
+	MailWOAssignedSubject    ResKey = "MailWOAssignedSubject"
+	MailWOAssignedBody       ResKey = "MailWOAssignedBody"
+	MailIssueAssignedSubject ResKey = "MailIssueAssignedSubject"
+	MailIssueAssignedBody    ResKey = "MailIssueAssignedBody"
+	MailEOCommentSubject     ResKey = "MailEOCommentSubject"
+	MailEOCommentBody        ResKey = "MailEOCommentBody"
+	MailIRFOverdueSubject    ResKey = "MailIRFOverdueSubject"
+	MailIRFOverdueBody       ResKey = "MailIRFOverdueBody"
+	MailPPEReadySubject      ResKey = "MailPPEReadySubject"
+	MailPPEReadyBody         ResKey = "MailPPEReadyBody"
+	MailTestSubject          ResKey = "MailTestSubject"
+	MailTestBody             ResKey = "MailTestBody"
 )
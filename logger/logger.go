@@ -4,6 +4,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"os"
 	"runtime/debug"
 	"sccsmsserver/setting"
@@ -75,12 +76,37 @@ func getLogWriter(filename string, maxSize, maxBackup, maxAge int) zapcore.Write
 	return zapcore.AddSync(lumberJackLogger)
 }
 
+// Query parameters carrying credentials, e.g. the access token of the message stream.
+// Their values are replaced in the logs.
+var redactedQueryParams = []string{"token"}
+
+// Replace the values of the credential query parameters
+func redactQuery(rawQuery string) string {
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		key, _, found := strings.Cut(param, "=")
+		if !found {
+			continue
+		}
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		for _, redacted := range redactedQueryParams {
+			if strings.EqualFold(key, redacted) {
+				params[i] = key + "=REDACTED"
+				break
+			}
+		}
+	}
+	return strings.Join(params, "&")
+}
+
 // Receive Gin's default logs.
 func GinLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
+		query := redactQuery(c.Request.URL.RawQuery)
 		c.Next()
 		cost := time.Since(start)
 		zap.L().Info(path,
@@ -114,6 +140,9 @@ func GinRecovery(stack bool) gin.HandlerFunc {
 				}
 
 				httpRequest, _ := httputil.DumpRequest(c.Request, false)
+				if rawQuery := c.Request.URL.RawQuery; rawQuery != "" {
+					httpRequest = []byte(strings.Replace(string(httpRequest), rawQuery, redactQuery(rawQuery), 1))
+				}
 
 				if brokenPipe {
 					zap.L().Error(c.Request.URL.Path,
@@ -11,7 +11,11 @@ import (
 	"sccsmsserver/logger"
 	"sccsmsserver/pkg/aws"
 	"sccsmsserver/pkg/environment"
+	"sccsmsserver/pkg/jwt"
+	"sccsmsserver/pkg/mailer"
 	"sccsmsserver/pkg/mysf"
+	"sccsmsserver/pkg/oidcauth"
+	"sccsmsserver/pkg/totp"
 	"sccsmsserver/route"
 	"sccsmsserver/setting"
 	"strconv"
@@ -40,30 +44,62 @@ func main() {
 		zap.L().Error("snowflake Generator component initialization failed:", zap.Error(err))
 		return
 	}
-	// step 4: Database connection initialization
+	// step 4: JWT signing keys initialization
+	if err := jwt.Init(setting.Conf.JWTConfig, setting.Conf.RedisConfig.Enabled); err != nil {
+		zap.L().Error("JWT signing keys initialization failed:", zap.Error(err))
+		return
+	}
+	// step 5: Database connection initialization
 	if err := pg.Init(setting.Conf.PqConfig); err != nil {
 		zap.L().Error("Database connection initialization failed:", zap.Error(err))
 		return
 	}
 	defer pg.Close()
-	// step 5: Cache component initialization
+	// step 6: Cache component initialization
 	if err := cache.Init(setting.Conf.RedisConfig.Enabled); err != nil {
 		zap.L().Error("Cache component initialization failed:", zap.Error(err))
 		return
 	}
 	defer cache.Close()
+	// step 7: Login authenticators initialization
+	if err := pg.InitAuthenticators(setting.Conf.LDAPConfig); err != nil {
+		zap.L().Error("Login authenticators initialization failed:", zap.Error(err))
+		return
+	}
+	if err := oidcauth.Init(setting.Conf.OIDCConfig); err != nil {
+		zap.L().Error("OpenID Connect initialization failed:", zap.Error(err))
+		return
+	}
+	// step 7.1: TOTP secret encryption keys, the stored secrets are encrypted again with the active key
+	if err := totp.Init(setting.Conf.TOTPConfig); err != nil {
+		zap.L().Error("TOTP secret encryption keys initialization failed:", zap.Error(err))
+		return
+	}
+	if err := pg.SealTOTPSecrets(); err != nil {
+		zap.L().Error("TOTP secret encryption failed:", zap.Error(err))
+		return
+	}
 
-	// setp 6: aws s3 client initialization
+	// setp 8: aws s3 client initialization
 	if err := aws.Init(setting.Conf.S3Storage.Endpoint, setting.Conf.S3Storage.AccessKeyID, setting.Conf.S3Storage.SecretAccessKey,
 		setting.Conf.S3Storage.Secure, setting.Conf.SelfSigned, setting.Conf.S3Storage.DefaultBucket, setting.Conf.S3Storage.Location); err != nil {
 		zap.L().Error("S3 Object Storage Init failed:", zap.Error(err))
 		return
 	}
+	// step 8.1: Email notifications
+	if err := mailer.Init(setting.Conf.SMTPConfig); err != nil {
+		zap.L().Error("Email notifications initialization failed:", zap.Error(err))
+		return
+	}
+	// step 8.2: Background jobs, e.g. the Work Orders of the schedules
+	pg.StartBackgroundJobs(setting.Conf.SchedulerConfig)
+	// step 8.3: Push messages to the connected clients
+	pg.StartPush()
 
-	// Step 7: Route Setup
+	// Step 9: Route Setup
 	r := route.Setup(setting.Conf.Mode)
 
-	// Step 8: Start HTTP server
+	// Step 10: Start HTTP server
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%d", setting.Conf.Port),
 		Handler: r,
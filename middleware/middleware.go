@@ -30,6 +30,12 @@ func CheckClientTypeMiddleware() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		// Frontend requests require the "XClientType" custom header.
 		clientType := c.Request.Header.Get("XClientType")
+		// Integrations calling with an API key instead of a token don't need it
+		if clientType == "" && c.Request.Header.Get(pub.APIKeyHeader) != "" && c.Request.Header.Get("Authorization") == "" {
+			c.Set(pub.CTXClientType, pub.APIKeyClientType)
+			c.Next()
+			return
+		}
 		// Verify if the request includes the custom “XClientType” header.
 		if clientType == "" {
 			handlers.ResponseWithMsg(c, i18n.CodeClientEmpty, nil)
@@ -49,12 +55,47 @@ func CheckClientTypeMiddleware() func(c *gin.Context) {
 	}
 }
 
+// Take the token and the client type from the query string when the headers don't carry them.
+// For browser EventSource connections, which can't set headers.
+// Must be used before CheckClientTypeMiddleware and JWTAuthMiddleware.
+func QueryTokenMiddleware() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if token := c.Query("token"); token != "" && c.Request.Header.Get("Authorization") == "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		if clientType := c.Query("clientType"); clientType != "" && c.Request.Header.Get("XClientType") == "" {
+			c.Request.Header.Set("XClientType", clientType)
+		}
+		c.Next()
+	}
+}
+
 // JWT authentication middleware
 func JWTAuthMiddleware() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		authHeader := c.Request.Header.Get("Authorization")
 		clientType := c.Request.Header.Get("XClientType")
 
+		// Service users authenticate with an API key instead of a token
+		if apiKey := c.Request.Header.Get(pub.APIKeyHeader); authHeader == "" && apiKey != "" {
+			caller, resStatus, _ := pg.AuthenticateAPIKey(apiKey, c.ClientIP())
+			if resStatus != i18n.StatusOK {
+				handlers.ResponseWithMsg(c, resStatus, nil)
+				c.Abort()
+				return
+			}
+			// The key roles grant the actions checked by PermissionMiddleware,
+			// handlers.GetOperatorID refuses the key on the other routes, they act on the signed-in user
+			c.Set(pub.CTXUserCode, caller.UserCode)
+			c.Set(pub.CTXUserID, caller.UserID)
+			c.Set(pub.CTXAPIKeyID, caller.KeyID)
+			if !takeAPIKeyRateLimitToken(c, caller.KeyID) {
+				return
+			}
+			c.Next()
+			return
+		}
+
 		if authHeader == "" {
 			handlers.ResponseWithMsg(c, i18n.CodeNeedLogin, nil)
 			c.Abort()
@@ -75,10 +116,9 @@ func JWTAuthMiddleware() func(c *gin.Context) {
 			return
 		}
 
-		// Get Current User from cache
+		// Get Current User session from cache
 		var ou pg.OnlineUser
-		ou.User.ID = mc.UserID
-		ou.ClientType = clientType
+		ou.SessionID = mc.SessionID
 		exist, _, err := ou.Get()
 		if err != nil {
 			handlers.ResponseWithMsg(c, i18n.CodeInternalError, nil)
@@ -86,26 +126,74 @@ func JWTAuthMiddleware() func(c *gin.Context) {
 			return
 		}
 
-		// If the user token is not found in the cache,
+		// If the user session is not found in the cache,
 		// it indicate that the token has been invalidated bye the administrator.
-		if exist == 0 {
+		if exist == 0 || ou.User.ID != mc.UserID || ou.ClientType != clientType {
 			handlers.ResponseWithMsg(c, i18n.CodeTokenDestroy, nil)
 			c.Abort()
 			return
 		}
 
-		// If the token exists but the ID is mismatched,
-		// it means the user has already logged in another device
-		if ou.TokenID != mc.Id {
+		// If the session was evicted,
+		// it means the user has logged in on more devices than the session limit allows
+		if ou.Evicted {
 			handlers.ResponseWithMsg(c, i18n.CodeLoginOther, nil)
 			c.Abort()
 			return
 		}
 
+		// If the session exists but the token ID is mismatched,
+		// the token has been replaced by a refreshed one
+		if ou.TokenID != mc.Id {
+			handlers.ResponseWithMsg(c, i18n.CodeInvalidToken, nil)
+			c.Abort()
+			return
+		}
+		// Record the session activity
+		ou.Touch(c.ClientIP())
+
 		// Save the current request's user information to the context
 		c.Set(pub.CTXUserCode, mc.UserCode)
 		c.Set(pub.CTXUserID, mc.UserID)
 		c.Set(pub.CTXTokenID, mc.Id)
+		c.Set(pub.CTXSessionID, mc.SessionID)
+		if !takeUserRateLimitToken(c, mc.UserID) {
+			return
+		}
 		c.Next()
 	}
 }
+
+// Permission middleware.
+// Checks whether the roles of the current user grant the action on the system menu.
+// Must be used after JWTAuthMiddleware.
+func PermissionMiddleware(menuID int32, action string) func(c *gin.Context) {
+	return AnyPermissionMiddleware(pg.MenuPermission{MenuID: menuID, Action: action})
+}
+
+// Permission middleware for routes shared by several menus,
+// e.g. the details of a voucher opened from its own menu and from the review menu.
+// Checks whether the roles of the current user grant any of the actions.
+// Must be used after JWTAuthMiddleware.
+func AnyPermissionMiddleware(perms ...pg.MenuPermission) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Mark the route, the operator is then available to API keys
+		c.Set(pub.CTXPermissionChecked, true)
+		up, resStatus := handlers.GetOperatorPermissions(c)
+		if resStatus != i18n.StatusOK {
+			handlers.ResponseWithMsg(c, resStatus, nil)
+			c.Abort()
+			return
+		}
+		for _, p := range perms {
+			if up.Allowed(p.MenuID, p.Action) {
+				c.Next()
+				return
+			}
+		}
+		zap.L().Info("PermissionMiddleware permission denied",
+			zap.Int32("userID", up.UserID), zap.Any("permissions", perms))
+		handlers.ResponseWithMsg(c, i18n.StatusPermissionDenied, nil)
+		c.Abort()
+	}
+}
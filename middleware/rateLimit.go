@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"sccsmsserver/cache"
+	"sccsmsserver/handlers"
+	"sccsmsserver/i18n"
+	"sccsmsserver/pub"
+	"sccsmsserver/setting"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Get the limit of the route group, false if the group isn't limited
+func rateLimitOf(cfg *setting.RateLimitConfig, group string) (rl setting.RateLimit, limited bool) {
+	rl, ok := cfg.Groups[group]
+	if !ok {
+		rl = cfg.Default
+	}
+	if rl.Rate <= 0 {
+		return rl, false
+	}
+	if rl.Burst <= 0 {
+		rl.Burst = int32(math.Ceil(rl.Rate))
+	}
+	return rl, true
+}
+
+// Get the route group of the request and its limit, false if the request isn't limited.
+// The route group is the first path segment after pub.APIPath, the other paths aren't limited.
+func requestRateLimit(c *gin.Context) (group string, rl setting.RateLimit, limited bool) {
+	cfg := setting.Conf.RateLimitConfig
+	if cfg == nil || !cfg.Enabled || !strings.HasPrefix(c.Request.URL.Path, pub.APIPath+"/") {
+		return
+	}
+	group, _, _ = strings.Cut(strings.TrimPrefix(c.Request.URL.Path, pub.APIPath+"/"), "/")
+	rl, limited = rateLimitOf(cfg, group)
+	return
+}
+
+// Take a token from the bucket of the client for the route group of the request.
+// When the bucket is empty the request is answered and aborted, false is returned.
+func takeRateLimitToken(c *gin.Context, client string) (allowed bool) {
+	group, rl, limited := requestRateLimit(c)
+	if !limited {
+		return true
+	}
+	key := fmt.Sprintf("%s%s%s%s%s", pub.RateLimit, ":", group, ":", client)
+	allowed, retryAfter, err := cache.TakeToken(key, rl.Rate, rl.Burst)
+	// The cache being unavailable must not take the API down
+	if err != nil || allowed {
+		return true
+	}
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	zap.L().Info("takeRateLimitToken too many requests", zap.String("group", group), zap.String("client", client))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	handlers.ResponseWithMsg(c, i18n.StatusTooManyRequests, nil, seconds)
+	c.Abort()
+	return false
+}
+
+// Take a token from the bucket of the signed-in user, after JWTAuthMiddleware authenticated the token
+func takeUserRateLimitToken(c *gin.Context, userID int32) bool {
+	return takeRateLimitToken(c, fmt.Sprintf("%s%d", "user:", userID))
+}
+
+// Take a token from the bucket of the API key, after JWTAuthMiddleware authenticated the key
+func takeAPIKeyRateLimitToken(c *gin.Context, keyID int32) bool {
+	return takeRateLimitToken(c, fmt.Sprintf("%s%d", "apikey:", keyID))
+}
+
+// API rate limiting middleware.
+// Each client has a token bucket per route group, the first path segment after pub.APIPath.
+// Every request takes a token from the bucket of its IP address here,
+// JWTAuthMiddleware takes another one from the bucket of the signed-in user or the API key.
+// Only routes of the API path are limited, so "/ping" and the UI are exempt.
+func RateLimitMiddleware() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if !takeRateLimitToken(c, fmt.Sprintf("%s%s", "ip:", c.ClientIP())) {
+			return
+		}
+		c.Next()
+	}
+}
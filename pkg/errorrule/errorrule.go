@@ -0,0 +1,569 @@
+// Package errorrule evaluates the error rules of Execution Projects.
+// A rule is a condition on the execution value that makes it an error, for example:
+//
+//	value < 15
+//	value not between 19.5 and 23.5
+//	text in ('No', 'N/A')
+//	value >= 10 and (value * 2 > 35 or text = 'Unknown')
+//
+// value (or v) is the execution value and text is its display value.
+// A rule may start with the comparison, in which case it compares the value: "< 15", "between 1 and 2", "in ('No')".
+// Operators: = != <> < <= > >=, [not] between x and y, [not] in (x, ...), and, or, not, + - * / and parentheses.
+// Keywords are case-insensitive, strings are quoted with ' or " and a quote is escaped by doubling it.
+// Two operands are compared as numbers when both are numbers, otherwise as strings.
+package errorrule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidRule = errors.New("invalid error rule")
+
+// Parsed error rule
+type Expr struct {
+	src  string
+	root boolNode
+}
+
+// Parse an error rule
+func Parse(s string) (e *Expr, err error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: empty rule", ErrInvalidRule)
+	}
+	// A rule starting with the comparison compares the value
+	if startsWithComparison(tokens) {
+		tokens = append([]token{{kind: tokIdent, text: "value"}}, tokens...)
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return
+	}
+	if !p.atEnd() {
+		return nil, p.unexpected()
+	}
+	return &Expr{src: s, root: root}, nil
+}
+
+// Check whether the value with the display text matches the rule.
+// err is returned when the rule can't be evaluated for the value, e.g. arithmetic on text.
+func (e *Expr) Match(value string, text string) (bool, error) {
+	return e.root.eval(env{value: value, text: text})
+}
+
+// Source of the rule
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Values the rule is evaluated with
+type env struct {
+	value string
+	text  string
+}
+
+// Operand of a comparison, a number when isNum
+type operand struct {
+	s     string
+	n     float64
+	isNum bool
+}
+
+func textOperand(s string) operand {
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return operand{s: s, n: n, isNum: err == nil}
+}
+
+func numberOperand(n float64) operand {
+	return operand{s: strconv.FormatFloat(n, 'f', -1, 64), n: n, isNum: true}
+}
+
+// Compare two operands, returns -1, 0 or 1
+func compare(a operand, b operand) int {
+	if a.isNum && b.isNum {
+		switch {
+		case a.n < b.n:
+			return -1
+		case a.n > b.n:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a.s, b.s)
+}
+
+// Expression nodes
+type boolNode interface {
+	eval(en env) (bool, error)
+}
+
+type valueNode interface {
+	value(en env) (operand, error)
+}
+
+type andNode struct{ left, right boolNode }
+type orNode struct{ left, right boolNode }
+type notNode struct{ inner boolNode }
+type cmpNode struct {
+	op          string
+	left, right valueNode
+}
+type betweenNode struct {
+	negate         bool
+	val, low, high valueNode
+}
+type inNode struct {
+	negate bool
+	val    valueNode
+	set    []valueNode
+}
+type arithNode struct {
+	op          string
+	left, right valueNode
+}
+type negNode struct{ inner valueNode }
+type literalNode struct{ op operand }
+type varNode struct{ name string }
+
+func (n andNode) eval(en env) (bool, error) {
+	l, err := n.left.eval(en)
+	if err != nil || !l {
+		return false, err
+	}
+	return n.right.eval(en)
+}
+
+func (n orNode) eval(en env) (bool, error) {
+	l, err := n.left.eval(en)
+	if err != nil || l {
+		return l, err
+	}
+	return n.right.eval(en)
+}
+
+func (n notNode) eval(en env) (bool, error) {
+	b, err := n.inner.eval(en)
+	return !b && err == nil, err
+}
+
+func (n cmpNode) eval(en env) (bool, error) {
+	l, err := n.left.value(en)
+	if err != nil {
+		return false, err
+	}
+	r, err := n.right.value(en)
+	if err != nil {
+		return false, err
+	}
+	c := compare(l, r)
+	switch n.op {
+	case "=":
+		return c == 0, nil
+	case "!=", "<>":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	}
+	return c >= 0, nil
+}
+
+func (n betweenNode) eval(en env) (bool, error) {
+	v, err := n.val.value(en)
+	if err != nil {
+		return false, err
+	}
+	low, err := n.low.value(en)
+	if err != nil {
+		return false, err
+	}
+	high, err := n.high.value(en)
+	if err != nil {
+		return false, err
+	}
+	in := compare(v, low) >= 0 && compare(v, high) <= 0
+	return in != n.negate, nil
+}
+
+func (n inNode) eval(en env) (bool, error) {
+	v, err := n.val.value(en)
+	if err != nil {
+		return false, err
+	}
+	for _, item := range n.set {
+		o, err := item.value(en)
+		if err != nil {
+			return false, err
+		}
+		if compare(v, o) == 0 {
+			return !n.negate, nil
+		}
+	}
+	return n.negate, nil
+}
+
+func (n arithNode) value(en env) (o operand, err error) {
+	l, err := n.left.value(en)
+	if err != nil {
+		return
+	}
+	r, err := n.right.value(en)
+	if err != nil {
+		return
+	}
+	if !l.isNum || !r.isNum {
+		return o, fmt.Errorf("%s of %q and %q: not a number", n.op, l.s, r.s)
+	}
+	switch n.op {
+	case "+":
+		return numberOperand(l.n + r.n), nil
+	case "-":
+		return numberOperand(l.n - r.n), nil
+	case "*":
+		return numberOperand(l.n * r.n), nil
+	}
+	if r.n == 0 {
+		return o, errors.New("division by zero")
+	}
+	return numberOperand(l.n / r.n), nil
+}
+
+func (n negNode) value(en env) (o operand, err error) {
+	v, err := n.inner.value(en)
+	if err != nil {
+		return
+	}
+	if !v.isNum {
+		return o, fmt.Errorf("- of %q: not a number", v.s)
+	}
+	return numberOperand(-v.n), nil
+}
+
+func (n literalNode) value(en env) (operand, error) {
+	return n.op, nil
+}
+
+func (n varNode) value(en env) (operand, error) {
+	if n.name == "text" {
+		return textOperand(en.text), nil
+	}
+	return textOperand(en.value), nil
+}
+
+// Tokens
+type tokenKind int
+
+const (
+	tokNumber tokenKind = iota
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string // Keywords and identifiers are lower case
+	pos  int
+}
+
+var variables = map[string]string{"value": "value", "v": "value", "text": "text"}
+var comparisons = map[string]bool{"=": true, "!=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// Split the rule into tokens
+func lex(s string) (tokens []token, err error) {
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			start := i
+			for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+				i++
+			}
+			if _, errParse := strconv.ParseFloat(s[start:i], 64); errParse != nil {
+				return nil, fmt.Errorf("%w: bad number %q at %d", ErrInvalidRule, s[start:i], start+1)
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: s[start:i], pos: start})
+		case c == '\'' || c == '"':
+			start := i
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(s) {
+					return nil, fmt.Errorf("%w: unterminated string at %d", ErrInvalidRule, start+1)
+				}
+				if s[i] == c {
+					if i+1 < len(s) && s[i+1] == c {
+						sb.WriteByte(c)
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				sb.WriteByte(s[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokString, text: sb.String(), pos: start})
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_':
+			start := i
+			for i < len(s) && (s[i] >= 'a' && s[i] <= 'z' || s[i] >= 'A' && s[i] <= 'Z' || s[i] == '_' || s[i] >= '0' && s[i] <= '9') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: strings.ToLower(s[start:i]), pos: start})
+		case c == '<' || c == '>' || c == '!':
+			op := s[i : i+1]
+			if i+1 < len(s) && (s[i+1] == '=' || c == '<' && s[i+1] == '>') {
+				op = s[i : i+2]
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("%w: unexpected \"!\" at %d", ErrInvalidRule, i+1)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		case strings.IndexByte("=+-*/(),", c) >= 0:
+			tokens = append(tokens, token{kind: tokOp, text: s[i : i+1], pos: i})
+			i++
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q at %d", ErrInvalidRule, c, i+1)
+		}
+	}
+	return
+}
+
+// Check whether the tokens start with a comparison without its left operand
+func startsWithComparison(tokens []token) bool {
+	t := tokens[0]
+	if t.kind == tokOp {
+		return comparisons[t.text]
+	}
+	if t.kind != tokIdent {
+		return false
+	}
+	if t.text == "between" || t.text == "in" {
+		return true
+	}
+	return t.text == "not" && len(tokens) > 1 && tokens[1].kind == tokIdent &&
+		(tokens[1].text == "between" || tokens[1].text == "in")
+}
+
+// Recursive descent parser
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) atEnd() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.atEnd() {
+		return token{}
+	}
+	return p.tokens[p.pos]
+}
+
+// Check whether the next token is the operator or keyword
+func (p *parser) is(text string) bool {
+	if p.atEnd() {
+		return false
+	}
+	t := p.tokens[p.pos]
+	return (t.kind == tokOp || t.kind == tokIdent) && t.text == text
+}
+
+// Consume the next token if it is the operator or keyword
+func (p *parser) accept(text string) bool {
+	if p.is(text) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	if p.atEnd() {
+		return fmt.Errorf("%w: unexpected end", ErrInvalidRule)
+	}
+	t := p.peek()
+	return fmt.Errorf("%w: unexpected %q at %d", ErrInvalidRule, t.text, t.pos+1)
+}
+
+// or := and {"or" and}
+func (p *parser) parseOr() (n boolNode, err error) {
+	n, err = p.parseAnd()
+	for err == nil && p.accept("or") {
+		var right boolNode
+		right, err = p.parseAnd()
+		n = orNode{left: n, right: right}
+	}
+	return
+}
+
+// and := not {"and" not}
+func (p *parser) parseAnd() (n boolNode, err error) {
+	n, err = p.parseNot()
+	for err == nil && p.accept("and") {
+		var right boolNode
+		right, err = p.parseNot()
+		n = andNode{left: n, right: right}
+	}
+	return
+}
+
+// not := "not" not | "(" or ")" | comparison
+func (p *parser) parseNot() (n boolNode, err error) {
+	if p.accept("not") {
+		var inner boolNode
+		inner, err = p.parseNot()
+		return notNode{inner: inner}, err
+	}
+	if p.is("(") {
+		// A parenthesized condition, otherwise the parentheses group the left operand of a comparison
+		start := p.pos
+		p.pos++
+		n, err = p.parseOr()
+		if err == nil && p.accept(")") && !p.continuesOperand() {
+			return
+		}
+		p.pos = start
+	}
+	return p.parseComparison()
+}
+
+// Check whether the next token continues an operand or a comparison
+func (p *parser) continuesOperand() bool {
+	t := p.peek()
+	if p.atEnd() {
+		return false
+	}
+	if t.kind == tokOp {
+		return t.text != ")" && t.text != ","
+	}
+	return t.kind == tokIdent && (t.text == "between" || t.text == "in" || t.text == "not")
+}
+
+// comparison := sum (op sum | ["not"] "between" sum "and" sum | ["not"] "in" "(" sum {"," sum} ")")
+func (p *parser) parseComparison() (n boolNode, err error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return
+	}
+	t := p.peek()
+	if !p.atEnd() && t.kind == tokOp && comparisons[t.text] {
+		p.pos++
+		var right valueNode
+		right, err = p.parseSum()
+		return cmpNode{op: t.text, left: left, right: right}, err
+	}
+	negate := p.accept("not")
+	switch {
+	case p.accept("between"):
+		bn := betweenNode{negate: negate, val: left}
+		if bn.low, err = p.parseSum(); err != nil {
+			return
+		}
+		if err = p.expect("and"); err != nil {
+			return
+		}
+		bn.high, err = p.parseSum()
+		return bn, err
+	case p.accept("in"):
+		in := inNode{negate: negate, val: left}
+		if err = p.expect("("); err != nil {
+			return
+		}
+		for {
+			var item valueNode
+			if item, err = p.parseSum(); err != nil {
+				return
+			}
+			in.set = append(in.set, item)
+			if !p.accept(",") {
+				break
+			}
+		}
+		err = p.expect(")")
+		return in, err
+	}
+	return nil, p.unexpected()
+}
+
+// sum := term {("+" | "-") term}
+func (p *parser) parseSum() (n valueNode, err error) {
+	n, err = p.parseTerm()
+	for err == nil && (p.is("+") || p.is("-")) {
+		op := p.peek().text
+		p.pos++
+		var right valueNode
+		right, err = p.parseTerm()
+		n = arithNode{op: op, left: n, right: right}
+	}
+	return
+}
+
+// term := unary {("*" | "/") unary}
+func (p *parser) parseTerm() (n valueNode, err error) {
+	n, err = p.parseUnary()
+	for err == nil && (p.is("*") || p.is("/")) {
+		op := p.peek().text
+		p.pos++
+		var right valueNode
+		right, err = p.parseUnary()
+		n = arithNode{op: op, left: n, right: right}
+	}
+	return
+}
+
+// unary := "-" unary | number | string | variable | "(" sum ")"
+func (p *parser) parseUnary() (n valueNode, err error) {
+	if p.accept("-") {
+		var inner valueNode
+		inner, err = p.parseUnary()
+		return negNode{inner: inner}, err
+	}
+	if p.accept("(") {
+		n, err = p.parseSum()
+		if err == nil {
+			err = p.expect(")")
+		}
+		return
+	}
+	if p.atEnd() {
+		return nil, p.unexpected()
+	}
+	t := p.peek()
+	switch t.kind {
+	case tokNumber:
+		p.pos++
+		return literalNode{op: textOperand(t.text)}, nil
+	case tokString:
+		p.pos++
+		return literalNode{op: operand{s: t.text}}, nil
+	case tokIdent:
+		if name, ok := variables[t.text]; ok {
+			p.pos++
+			return varNode{name: name}, nil
+		}
+	}
+	return nil, p.unexpected()
+}
@@ -0,0 +1,178 @@
+package errorrule
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		rule  string
+		value string
+		text  string
+		want  bool
+	}{
+		// Comparisons, with and without the value
+		{"value < 15", "14.9", "", true},
+		{"value < 15", "15", "", false},
+		{"v <= 15", "15", "", true},
+		{"< 15", "9", "", true},
+		{">= 15", "9", "", false},
+		{"value = 3", "3.0", "", true},
+		{"value != 3", "3", "", false},
+		{"value <> 3", "4", "", true},
+		{"= 'No'", "No", "", true},
+
+		// Two numbers compare as numbers, anything else as text, a quoted string is text
+		{"value < 15", "9", "", true},
+		{"value < '15'", "9", "", false},
+		{"text < 'b'", "", "apple", true},
+		{"value > 100", "abc", "", true},
+		{"value = 'abc'", "abc", "", true},
+		{"value = '7'", "7", "", true},
+		{"value = '7.0'", "7", "", false},
+		{"value = 7.0", " 7 ", "", true},
+		{"text = 'No'", "2", "No", true},
+		{"text = 'no'", "2", "No", false},
+
+		// between
+		{"value between 19.5 and 23.5", "19.5", "", true},
+		{"value between 19.5 and 23.5", "23.5", "", true},
+		{"value between 19.5 and 23.5", "23.6", "", false},
+		{"value not between 19.5 and 23.5", "18", "", true},
+		{"value not between 19.5 and 23.5", "20", "", false},
+		{"between 1 and 2", "1.5", "", true},
+		{"not between 1 and 2", "1.5", "", false},
+		{"value between 9 and 10", "10", "", true},
+		{"text between 'a' and 'c'", "", "b", true},
+
+		// in
+		{"text in ('No', 'N/A')", "", "N/A", true},
+		{"text in ('No', 'N/A')", "", "Yes", false},
+		{"text not in ('Yes')", "", "No", true},
+		{"in (1, 2, 3)", "2.0", "", true},
+		{"not in (1, 2, 3)", "4", "", true},
+		{"value in ('It''s')", "It's", "", true},
+		{`value in ("say ""hi""")`, `say "hi"`, "", true},
+
+		// and binds tighter than or, not binds tighter than and
+		{"value > 10 or value < 0 and text = 'x'", "11", "y", true},
+		{"value > 10 or value < 0 and text = 'x'", "-1", "y", false},
+		{"(value > 10 or value < 0) and text = 'x'", "11", "y", false},
+		{"value >= 10 and (value * 2 > 35 or text = 'Unknown')", "18", "", true},
+		{"value >= 10 and (value * 2 > 35 or text = 'Unknown')", "12", "Unknown", true},
+		{"value >= 10 and (value * 2 > 35 or text = 'Unknown')", "12", "", false},
+		{"not value > 10 and value > 5", "7", "", true},
+		{"not (value > 10 and value > 5)", "11", "", false},
+		{"not not value = 1", "1", "", true},
+
+		// Arithmetic: * and / before + and -, unary minus, parentheses
+		{"value + 2 * 3 = 7", "1", "", true},
+		{"(value + 2) * 3 = 9", "1", "", true},
+		{"value - 10 / 2 = 0", "5", "", true},
+		{"value - 3 - 2 = 0", "5", "", true},
+		{"value / 2 / 5 = 1", "10", "", true},
+		{"-value > 0", "-1", "", true},
+		{"value * -1 = -3", "3", "", true},
+		{"(value) > 1", "2", "", true},
+		{"((value + 1)) * 2 > 5", "2", "", true},
+
+		// Keywords are case-insensitive
+		{"VALUE NOT BETWEEN 1 AND 2 OR TEXT IN ('X')", "5", "", true},
+		{"Value In (1)", "1", "", true},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.rule)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.rule, err)
+			continue
+		}
+		got, err := e.Match(tt.value, tt.text)
+		if err != nil {
+			t.Errorf("Parse(%q).Match(%q, %q): %v", tt.rule, tt.value, tt.text, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q).Match(%q, %q) = %v, want %v", tt.rule, tt.value, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMatchError(t *testing.T) {
+	tests := []struct {
+		rule  string
+		value string
+		text  string
+	}{
+		{"value / 0 > 1", "5", ""},
+		{"value / (value - 5) > 1", "5", ""},
+		{"value * 2 > 1", "abc", ""},
+		{"text + 1 > 1", "1", "one"},
+		{"-text > 0", "", "one"},
+		{"value between 1 and text * 2", "1", "x"},
+		{"value in (1, text / 2)", "5", "x"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.rule)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.rule, err)
+			continue
+		}
+		if got, err := e.Match(tt.value, tt.text); err == nil || got {
+			t.Errorf("Parse(%q).Match(%q, %q) = %v, %v, want an error", tt.rule, tt.value, tt.text, got, err)
+		}
+	}
+	// An error on the right of a decided and/or isn't evaluated
+	e, _ := Parse("value > 10 or value / 0 > 1")
+	if got, err := e.Match("11", ""); err != nil || !got {
+		t.Errorf("short-circuit or = %v, %v, want true", got, err)
+	}
+	// not of an error isn't a match
+	e, _ = Parse("not value / 0 > 1")
+	if got, err := e.Match("1", ""); err == nil || got {
+		t.Errorf("not of an error = %v, %v, want an error", got, err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	rules := []string{
+		"",
+		"   ",
+		"value",
+		"value <",
+		"value < 15 and",
+		"value 15",
+		"value < 15)",
+		"(value < 15",
+		"value between 1",
+		"value between 1 or 2",
+		"value in 1, 2",
+		"value in ()",
+		"value in (1, 2",
+		"value not 1",
+		"text = 'No",
+		`text = "No`,
+		"value < 1.2.3",
+		"value ! 3",
+		"value < 15 # comment",
+		"amount < 15",
+		"value < 15 value",
+		"* 2",
+	}
+	for _, rule := range rules {
+		if e, err := Parse(rule); !errors.Is(err, ErrInvalidRule) || e != nil {
+			t.Errorf("Parse(%q) = %v, %v, want ErrInvalidRule", rule, e, err)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	rule := "value not between 19.5 and 23.5"
+	e, err := Parse(rule)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", rule, err)
+	}
+	if e.String() != rule {
+		t.Errorf("String() = %q, want %q", e.String(), rule)
+	}
+}
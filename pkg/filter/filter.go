@@ -0,0 +1,324 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Value type of a filterable field
+type FieldType int16
+
+const (
+	String FieldType = iota // Text column
+	Number                  // Integer or numeric column
+	Time                    // Timestamp column
+)
+
+// Filterable field definition
+type Field struct {
+	Column string    // SQL expression the field is compiled to
+	Type   FieldType // Value type used to validate the condition value
+}
+
+// Whitelist of the fields a query accepts, keyed by the field name used by clients
+type Fields map[string]Field
+
+// Single filter condition
+type Condition struct {
+	Field    string      `json:"field"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value"`
+}
+
+// Group of conditions and sub-groups joined by the same logic operator
+type Group struct {
+	Logic      string      `json:"logic"` // "and" || "or", default "and"
+	Conditions []Condition `json:"conditions"`
+	Groups     []Group     `json:"groups"`
+	// Groups added by the server, such as the data scope, joined to the group with "and".
+	// They are never decoded from the client and don't count against the limits.
+	Restrictions []Group `json:"-"`
+}
+
+// Supported operators
+const (
+	OpEq         = "eq"
+	OpNe         = "ne"
+	OpGt         = "gt"
+	OpGte        = "gte"
+	OpLt         = "lt"
+	OpLte        = "lte"
+	OpContains   = "contains"
+	OpStartsWith = "startsWith"
+	OpEndsWith   = "endsWith"
+	OpIn         = "in"
+	OpNotIn      = "notIn"
+	OpBetween    = "between"
+	OpIsNull     = "isNull"
+	OpNotNull    = "notNull"
+)
+
+// Comparison operators and the SQL they compile to
+var comparisonOperators = map[string]string{
+	OpEq:  "=",
+	OpNe:  "<>",
+	OpGt:  ">",
+	OpGte: ">=",
+	OpLt:  "<",
+	OpLte: "<=",
+}
+
+// Limits that keep a single filter from producing an excessive query
+const (
+	MaxDepth      = 5
+	MaxConditions = 50
+	MaxListValues = 500
+)
+
+// Accepted layouts for Time values
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+var (
+	ErrUnknownField    = errors.New("filter: unknown field")
+	ErrInvalidOperator = errors.New("filter: invalid operator")
+	ErrInvalidValue    = errors.New("filter: invalid value")
+	ErrInvalidLogic    = errors.New("filter: invalid logic")
+	ErrTooComplex      = errors.New("filter: too complex")
+)
+
+// Check whether the group contains no conditions
+func (g *Group) IsEmpty() bool {
+	if len(g.Conditions) > 0 {
+		return false
+	}
+	for i := range g.Groups {
+		if !g.Groups[i].IsEmpty() {
+			return false
+		}
+	}
+	for i := range g.Restrictions {
+		if !g.Restrictions[i].IsEmpty() {
+			return false
+		}
+	}
+	return true
+}
+
+// Compile the group into a SQL boolean expression.
+// Values are never written into the SQL; they are returned in args
+// and referenced by $n placeholders numbered from argStart.
+// An empty group compiles to an empty string.
+func (g *Group) Compile(fields Fields, argStart int) (sqlStr string, args []interface{}, err error) {
+	c := compiler{fields: fields, argIndex: argStart}
+	sqlStr, err = c.group(g, 1)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(g.Restrictions) == 0 {
+		return sqlStr, c.args, nil
+	}
+	// The restrictions are trusted, only the client group is held to the limits
+	c.trusted = true
+	parts := make([]string, 0, len(g.Restrictions)+1)
+	if sqlStr != "" {
+		parts = append(parts, "("+sqlStr+")")
+	}
+	for i := range g.Restrictions {
+		s, err := c.group(&g.Restrictions[i], 1)
+		if err != nil {
+			return "", nil, err
+		}
+		if s != "" {
+			parts = append(parts, "("+s+")")
+		}
+	}
+	return strings.Join(parts, " and "), c.args, nil
+}
+
+// Filter compiler state
+type compiler struct {
+	fields     Fields
+	args       []interface{}
+	argIndex   int
+	conditions int
+	trusted    bool // Compiling server restrictions, the limits don't apply
+}
+
+// Add a bound value and return its placeholder
+func (c *compiler) bind(v interface{}) string {
+	c.args = append(c.args, v)
+	p := "$" + strconv.Itoa(c.argIndex)
+	c.argIndex++
+	return p
+}
+
+// Compile a group
+func (c *compiler) group(g *Group, depth int) (string, error) {
+	if depth > MaxDepth && !c.trusted {
+		return "", ErrTooComplex
+	}
+	var logic string
+	switch strings.ToLower(g.Logic) {
+	case "", "and":
+		logic = " and "
+	case "or":
+		logic = " or "
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidLogic, g.Logic)
+	}
+
+	parts := make([]string, 0, len(g.Conditions)+len(g.Groups))
+	for i := range g.Conditions {
+		c.conditions++
+		if c.conditions > MaxConditions && !c.trusted {
+			return "", ErrTooComplex
+		}
+		s, err := c.condition(&g.Conditions[i])
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, s)
+	}
+	for i := range g.Groups {
+		s, err := c.group(&g.Groups[i], depth+1)
+		if err != nil {
+			return "", err
+		}
+		if s != "" {
+			parts = append(parts, "("+s+")")
+		}
+	}
+	return strings.Join(parts, logic), nil
+}
+
+// Compile a single condition
+func (c *compiler) condition(cond *Condition) (string, error) {
+	f, ok := c.fields[cond.Field]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownField, cond.Field)
+	}
+
+	if sqlOp, ok := comparisonOperators[cond.Operator]; ok {
+		v, err := convertValue(f.Type, cond.Value)
+		if err != nil {
+			return "", err
+		}
+		return f.Column + " " + sqlOp + " " + c.bind(v), nil
+	}
+
+	switch cond.Operator {
+	case OpContains, OpStartsWith, OpEndsWith:
+		if f.Type != String {
+			return "", fmt.Errorf("%w: %s on %q", ErrInvalidOperator, cond.Operator, cond.Field)
+		}
+		s, ok := cond.Value.(string)
+		if !ok {
+			return "", fmt.Errorf("%w: %q expects a string", ErrInvalidValue, cond.Field)
+		}
+		pattern := escapeLike(s)
+		switch cond.Operator {
+		case OpContains:
+			pattern = "%" + pattern + "%"
+		case OpStartsWith:
+			pattern = pattern + "%"
+		case OpEndsWith:
+			pattern = "%" + pattern
+		}
+		return f.Column + " ilike " + c.bind(pattern), nil
+	case OpIn, OpNotIn:
+		list, ok := cond.Value.([]interface{})
+		if !ok || len(list) == 0 || len(list) > MaxListValues {
+			return "", fmt.Errorf("%w: %q expects a list of 1 to %d values", ErrInvalidValue, cond.Field, MaxListValues)
+		}
+		placeholders := make([]string, 0, len(list))
+		for _, item := range list {
+			v, err := convertValue(f.Type, item)
+			if err != nil {
+				return "", err
+			}
+			placeholders = append(placeholders, c.bind(v))
+		}
+		sqlOp := " in ("
+		if cond.Operator == OpNotIn {
+			sqlOp = " not in ("
+		}
+		return f.Column + sqlOp + strings.Join(placeholders, ",") + ")", nil
+	case OpBetween:
+		list, ok := cond.Value.([]interface{})
+		if !ok || len(list) != 2 {
+			return "", fmt.Errorf("%w: %q expects two values", ErrInvalidValue, cond.Field)
+		}
+		from, err := convertValue(f.Type, list[0])
+		if err != nil {
+			return "", err
+		}
+		to, err := convertValue(f.Type, list[1])
+		if err != nil {
+			return "", err
+		}
+		return f.Column + " between " + c.bind(from) + " and " + c.bind(to), nil
+	case OpIsNull:
+		return f.Column + " is null", nil
+	case OpNotNull:
+		return f.Column + " is not null", nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidOperator, cond.Operator)
+}
+
+// Convert a JSON decoded value to the Go type bound for the field type
+func convertValue(t FieldType, v interface{}) (interface{}, error) {
+	switch t {
+	case String:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case Number:
+		var n float64
+		switch value := v.(type) {
+		case int32:
+			return int64(value), nil
+		case int64:
+			return value, nil
+		case int:
+			return int64(value), nil
+		case float64:
+			n = value
+		case string:
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q is not a number", ErrInvalidValue, value)
+			}
+			n = parsed
+		default:
+			return nil, fmt.Errorf("%w: expects a number", ErrInvalidValue)
+		}
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, fmt.Errorf("%w: expects a finite number", ErrInvalidValue)
+		}
+		// Bind whole numbers as integers so they compare against integer columns
+		if n == math.Trunc(n) && math.Abs(n) < 1<<53 {
+			return int64(n), nil
+		}
+		return n, nil
+	case Time:
+		if s, ok := v.(string); ok {
+			for _, layout := range timeLayouts {
+				if tm, err := time.Parse(layout, s); err == nil {
+					return tm, nil
+				}
+			}
+			return nil, fmt.Errorf("%w: %q is not a time", ErrInvalidValue, s)
+		}
+	}
+	return nil, fmt.Errorf("%w: unexpected %T", ErrInvalidValue, v)
+}
+
+// Escape the LIKE wildcard characters in a user supplied string
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
@@ -0,0 +1,262 @@
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testFields = Fields{
+	"code":       {Column: "h.code", Type: String},
+	"status":     {Column: "h.status", Type: Number},
+	"createDate": {Column: "h.createtime", Type: Time},
+}
+
+// Decode the filter the way the handlers bind it
+func parseGroup(t *testing.T, s string) *Group {
+	t.Helper()
+	g := new(Group)
+	if err := json.Unmarshal([]byte(s), g); err != nil {
+		t.Fatalf("json.Unmarshal(%s): %v", s, err)
+	}
+	return g
+}
+
+func TestCompile(t *testing.T) {
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		filter   string
+		argStart int
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{`{}`, 1, "", nil},
+		{`{"groups":[{},{"conditions":[]}]}`, 1, "", nil},
+		{`{"conditions":[{"field":"code","operator":"eq","value":"WO-1"}]}`, 1,
+			"h.code = $1", []interface{}{"WO-1"}},
+		{`{"conditions":[{"field":"status","operator":"gte","value":1},{"field":"status","operator":"lt","value":"2.5"}]}`, 1,
+			"h.status >= $1 and h.status < $2", []interface{}{int64(1), 2.5}},
+		{`{"logic":"OR","conditions":[{"field":"status","operator":"ne","value":0},{"field":"code","operator":"isNull"}]}`, 1,
+			"h.status <> $1 or h.code is null", []interface{}{int64(0)}},
+		{`{"conditions":[{"field":"createDate","operator":"between","value":["2025-03-01","2025-03-01T00:00:00Z"]}]}`, 1,
+			"h.createtime between $1 and $2", []interface{}{day, day}},
+		{`{"conditions":[{"field":"status","operator":"notIn","value":[1,2,3]},{"field":"code","operator":"notNull"}]}`, 1,
+			"h.status not in ($1,$2,$3) and h.code is not null", []interface{}{int64(1), int64(2), int64(3)}},
+		{`{"conditions":[{"field":"code","operator":"contains","value":"a"},{"field":"code","operator":"startsWith","value":"b"},{"field":"code","operator":"endsWith","value":"c"}]}`, 1,
+			"h.code ilike $1 and h.code ilike $2 and h.code ilike $3", []interface{}{"%a%", "b%", "%c"}},
+
+		// Placeholders are numbered from argStart, the conditions of a group before its sub-groups
+		{`{"conditions":[{"field":"code","operator":"eq","value":"x"}],"groups":[{"logic":"or","conditions":[{"field":"status","operator":"in","value":[1,2]},{"field":"status","operator":"eq","value":9}]}]}`, 4,
+			"h.code = $4 and (h.status in ($5,$6) or h.status = $7)", []interface{}{"x", int64(1), int64(2), int64(9)}},
+		{`{"groups":[{"groups":[{"conditions":[{"field":"status","operator":"eq","value":1}]}]}]}`, 3,
+			"((h.status = $3))", []interface{}{int64(1)}},
+		// Values are bound, never written into the SQL
+		{`{"conditions":[{"field":"code","operator":"eq","value":"x' or '1'='1"}]}`, 1,
+			"h.code = $1", []interface{}{"x' or '1'='1"}},
+	}
+	for _, tt := range tests {
+		sqlStr, args, err := parseGroup(t, tt.filter).Compile(testFields, tt.argStart)
+		if err != nil {
+			t.Errorf("Compile(%s): %v", tt.filter, err)
+			continue
+		}
+		if sqlStr != tt.wantSQL {
+			t.Errorf("Compile(%s) sql = %q, want %q", tt.filter, sqlStr, tt.wantSQL)
+		}
+		if !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("Compile(%s) args = %#v, want %#v", tt.filter, args, tt.wantArgs)
+		}
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   error
+	}{
+		// Unknown fields, also as the column name or in a sub-group
+		{`{"conditions":[{"field":"password","operator":"eq","value":"x"}]}`, ErrUnknownField},
+		{`{"conditions":[{"field":"h.code","operator":"eq","value":"x"}]}`, ErrUnknownField},
+		{`{"conditions":[{"field":"Code","operator":"eq","value":"x"}]}`, ErrUnknownField},
+		{`{"groups":[{"conditions":[{"field":"code; drop table sysuser","operator":"isNull"}]}]}`, ErrUnknownField},
+
+		// Operators
+		{`{"conditions":[{"field":"code","operator":"like","value":"x"}]}`, ErrInvalidOperator},
+		{`{"conditions":[{"field":"code","operator":"=","value":"x"}]}`, ErrInvalidOperator},
+		{`{"conditions":[{"field":"code","operator":"","value":"x"}]}`, ErrInvalidOperator},
+		{`{"conditions":[{"field":"status","operator":"contains","value":"1"}]}`, ErrInvalidOperator},
+		{`{"conditions":[{"field":"createDate","operator":"startsWith","value":"2025"}]}`, ErrInvalidOperator},
+		{`{"logic":"xor","conditions":[{"field":"code","operator":"isNull"}]}`, ErrInvalidLogic},
+		{`{"groups":[{"logic":"and not","conditions":[{"field":"code","operator":"isNull"}]}]}`, ErrInvalidLogic},
+
+		// Values of the wrong type
+		{`{"conditions":[{"field":"code","operator":"eq","value":1}]}`, ErrInvalidValue},
+		{`{"conditions":[{"field":"code","operator":"eq"}]}`, ErrInvalidValue},
+		{`{"conditions":[{"field":"code","operator":"contains","value":["a"]}]}`, ErrInvalidValue},
+		{`{"conditions":[{"field":"status","operator":"eq","value":"1 or 1=1"}]}`, ErrInvalidValue},
+		{`{"conditions":[{"field":"status","operator":"eq","value":"NaN"}]}`, ErrInvalidValue},
+		{`{"conditions":[{"field":"status","operator":"eq","value":true}]}`, ErrInvalidValue},
+		{`{"conditions":[{"field":"createDate","operator":"gt","value":"yesterday"}]}`, ErrInvalidValue},
+		{`{"conditions":[{"field":"createDate","operator":"gt","value":1740787200}]}`, ErrInvalidValue},
+		{`{"conditions":[{"field":"status","operator":"in","value":1}]}`, ErrInvalidValue},
+		{`{"conditions":[{"field":"status","operator":"in","value":[]}]}`, ErrInvalidValue},
+		{`{"conditions":[{"field":"status","operator":"in","value":[1,"x"]}]}`, ErrInvalidValue},
+		{`{"conditions":[{"field":"status","operator":"between","value":[1]}]}`, ErrInvalidValue},
+		{`{"conditions":[{"field":"status","operator":"between","value":[1,2,3]}]}`, ErrInvalidValue},
+		{`{"conditions":[{"field":"createDate","operator":"between","value":["2025-03-01","x"]}]}`, ErrInvalidValue},
+	}
+	for _, tt := range tests {
+		sqlStr, args, err := parseGroup(t, tt.filter).Compile(testFields, 1)
+		if !errors.Is(err, tt.want) || sqlStr != "" || args != nil {
+			t.Errorf("Compile(%s) = %q, %v, %v, want %v", tt.filter, sqlStr, args, err, tt.want)
+		}
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"abc", "abc"},
+		{"100%", `100\%`},
+		{"a_b", `a\_b`},
+		{`C:\temp`, `C:\\temp`},
+		{`\%_`, `\\\%\_`},
+		{`%%`, `\%\%`},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := escapeLike(tt.s); got != tt.want {
+			t.Errorf("escapeLike(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+	// The escaped value is bound with the wildcards of the operator around it
+	g := &Group{Conditions: []Condition{{Field: "code", Operator: OpContains, Value: `5%_\`}}}
+	_, args, err := g.Compile(testFields, 1)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	if want := []interface{}{`%5\%\_\\%`}; !reflect.DeepEqual(args, want) {
+		t.Errorf("contains args = %q, want %q", args, want)
+	}
+}
+
+// Group with a condition nested depth levels deep, the group itself is level 1
+func nestedGroup(depth int) Group {
+	g := Group{Conditions: []Condition{{Field: "code", Operator: OpIsNull}}}
+	for i := 1; i < depth; i++ {
+		g = Group{Groups: []Group{g}}
+	}
+	return g
+}
+
+// Group of n conditions on the status
+func conditionsGroup(n int) Group {
+	g := Group{}
+	for i := 0; i < n; i++ {
+		g.Conditions = append(g.Conditions, Condition{Field: "status", Operator: OpEq, Value: float64(i)})
+	}
+	return g
+}
+
+// Condition on a list of n values
+func listGroup(n int) Group {
+	list := make([]interface{}, n)
+	for i := range list {
+		list[i] = float64(i)
+	}
+	return Group{Conditions: []Condition{{Field: "status", Operator: OpIn, Value: list}}}
+}
+
+func TestCompileLimits(t *testing.T) {
+	// Conditions spread over the sub-groups count together
+	spread := conditionsGroup(MaxConditions - 1)
+	spread.Groups = []Group{conditionsGroup(1)}
+	tooMany := conditionsGroup(MaxConditions - 1)
+	tooMany.Groups = []Group{conditionsGroup(1), conditionsGroup(1)}
+
+	tests := []struct {
+		name string
+		g    Group
+		want error
+	}{
+		{"max depth", nestedGroup(MaxDepth), nil},
+		{"too deep", nestedGroup(MaxDepth + 1), ErrTooComplex},
+		{"max conditions", conditionsGroup(MaxConditions), nil},
+		{"too many conditions", conditionsGroup(MaxConditions + 1), ErrTooComplex},
+		{"max conditions in sub-groups", spread, nil},
+		{"too many conditions in sub-groups", tooMany, ErrTooComplex},
+		{"max list values", listGroup(MaxListValues), nil},
+		{"too many list values", listGroup(MaxListValues + 1), ErrInvalidValue},
+	}
+	for _, tt := range tests {
+		sqlStr, _, err := tt.g.Compile(testFields, 1)
+		if tt.want == nil && (err != nil || sqlStr == "") {
+			t.Errorf("%s: Compile() = %q, %v, want a condition", tt.name, sqlStr, err)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: Compile() = %q, %v, want %v", tt.name, sqlStr, err, tt.want)
+		}
+	}
+	// The list values are bound one placeholder each
+	g := listGroup(MaxListValues)
+	sqlStr, args, err := g.Compile(testFields, 1)
+	if err != nil || len(args) != MaxListValues || !strings.HasSuffix(sqlStr, ",$500)") {
+		t.Errorf("Compile() of %d values = %d args, %v, want $1 to $500", MaxListValues, len(args), err)
+	}
+}
+
+func TestCompileRestrictions(t *testing.T) {
+	scope := Group{Logic: "or", Conditions: []Condition{
+		{Field: "status", Operator: OpIn, Value: []interface{}{float64(1), float64(2)}},
+		{Field: "code", Operator: OpEq, Value: "own"},
+	}}
+
+	// The restrictions are joined with and, after the client group
+	g := Group{Logic: "or", Conditions: []Condition{
+		{Field: "code", Operator: OpEq, Value: "a"},
+		{Field: "code", Operator: OpEq, Value: "b"},
+	}, Restrictions: []Group{scope}}
+	sqlStr, args, err := g.Compile(testFields, 2)
+	wantSQL := "(h.code = $2 or h.code = $3) and (h.status in ($4,$5) or h.code = $6)"
+	wantArgs := []interface{}{"a", "b", int64(1), int64(2), "own"}
+	if err != nil || sqlStr != wantSQL || !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("Compile() = %q, %#v, %v, want %q, %#v", sqlStr, args, err, wantSQL, wantArgs)
+	}
+
+	// An empty client group compiles to the restrictions alone
+	g = Group{Restrictions: []Group{scope}}
+	if g.IsEmpty() {
+		t.Errorf("IsEmpty() of a restricted group = true, want false")
+	}
+	sqlStr, _, err = g.Compile(testFields, 1)
+	if want := "(h.status in ($1,$2) or h.code = $3)"; err != nil || sqlStr != want {
+		t.Errorf("Compile() = %q, %v, want %q", sqlStr, err, want)
+	}
+
+	// The restrictions don't count against the limits of the client group
+	tests := []struct {
+		name string
+		g    Group
+		want error
+	}{
+		{"max depth", nestedGroup(MaxDepth), nil},
+		{"too deep", nestedGroup(MaxDepth + 1), ErrTooComplex},
+		{"max conditions", conditionsGroup(MaxConditions), nil},
+		{"too many conditions", conditionsGroup(MaxConditions + 1), ErrTooComplex},
+	}
+	for _, tt := range tests {
+		tt.g.Restrictions = []Group{scope, nestedGroup(MaxDepth), conditionsGroup(MaxConditions)}
+		sqlStr, _, err := tt.g.Compile(testFields, 1)
+		if tt.want == nil && (err != nil || sqlStr == "") {
+			t.Errorf("%s: Compile() = %q, %v, want a condition", tt.name, sqlStr, err)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: Compile() = %q, %v, want %v", tt.name, sqlStr, err, tt.want)
+		}
+	}
+}
@@ -12,15 +12,18 @@ import (
 type MyClaims struct {
 	UserID   int32  `json:"userid"`
 	UserCode string `json:"usercode"`
+	// Login session the token belongs to, kept when the token is refreshed
+	SessionID string `json:"sid"`
 	jwt.StandardClaims
 }
 
 // Generate token
-func GenToken(userID int32, usercode string, tokenID string) (tokenString string, expireTime int64, err error) {
+func GenToken(userID int32, usercode string, tokenID string, sessionID string) (tokenString string, expireTime int64, err error) {
 	expireTime = time.Now().Add(pub.TokenExpireDuration).Unix()
 	c := MyClaims{
-		UserID:   userID,
-		UserCode: usercode,
+		UserID:    userID,
+		UserCode:  usercode,
+		SessionID: sessionID,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: expireTime,
 			Issuer:    pub.TokenIssuer,
@@ -28,9 +31,10 @@ func GenToken(userID int32, usercode string, tokenID string) (tokenString string
 		},
 	}
 	// create signature object
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
+	token := jwt.NewWithClaims(currentKey.method, c)
+	token.Header["kid"] = currentKey.id
 	// signed token
-	tokenString, err = token.SignedString(pub.TokenSecret)
+	tokenString, err = token.SignedString(currentKey.signKey)
 	if err != nil {
 		zap.L().Error("GenToken token.SignedString failed:", zap.Error(err))
 	}
@@ -41,9 +45,7 @@ func GenToken(userID int32, usercode string, tokenID string) (tokenString string
 func ParseToken(tokenString string) (*MyClaims, i18n.ResKey) {
 	var mc = new(MyClaims)
 
-	token, err := jwt.ParseWithClaims(tokenString, mc, func(token *jwt.Token) (i interface{}, err error) {
-		return pub.TokenSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, mc, lookupKey)
 
 	if err != nil {
 		return nil, i18n.CodeInvalidToken
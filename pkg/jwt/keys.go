@@ -0,0 +1,247 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sccsmsserver/setting"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+	"go.uber.org/zap"
+)
+
+// Supported signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Token signing key
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{} // nil for keys that only verify tokens
+	verifyKey interface{}
+}
+
+var (
+	// Keys accepted when verifying tokens, by key ID
+	verifyKeys = make(map[string]*signingKey)
+	// Key used to sign new tokens
+	currentKey *signingKey
+)
+
+// Initialize the token signing keys.
+// Without configured keys a random HS256 key is generated,
+// tokens are then invalidated by a restart and can't be shared between servers,
+// so keys are required when the servers share the Redis cache.
+func Init(cfg *setting.JWTConfig, shared bool) (err error) {
+	verifyKeys = make(map[string]*signingKey)
+	currentKey = nil
+	if cfg == nil || len(cfg.Keys) == 0 {
+		if shared {
+			return errors.New("jwt: no signing key configured, the servers sharing the Redis cache need the same keys")
+		}
+		secret := make([]byte, 32)
+		if _, err = rand.Read(secret); err != nil {
+			return
+		}
+		currentKey = &signingKey{id: "ephemeral", method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+		verifyKeys[currentKey.id] = currentKey
+		zap.L().Warn("No JWT signing key configured, a random key is used. Tokens become invalid when the server restarts and other servers reject them.")
+		return
+	}
+	for _, kc := range cfg.Keys {
+		if kc.ID == "" {
+			return errors.New("jwt: key without kid")
+		}
+		if _, ok := verifyKeys[kc.ID]; ok {
+			return fmt.Errorf("jwt: duplicate kid %q", kc.ID)
+		}
+		k, err := loadKey(kc)
+		if err != nil {
+			return fmt.Errorf("jwt: key %q: %w", kc.ID, err)
+		}
+		verifyKeys[k.id] = k
+	}
+	signingKID := cfg.SigningKeyID
+	if signingKID == "" && len(cfg.Keys) == 1 {
+		signingKID = cfg.Keys[0].ID
+	}
+	currentKey = verifyKeys[signingKID]
+	if currentKey == nil {
+		return fmt.Errorf("jwt: signing key %q not found", signingKID)
+	}
+	if currentKey.signKey == nil {
+		return fmt.Errorf("jwt: signing key %q has no private key", signingKID)
+	}
+	zap.L().Info("JWT signing keys initialized successfully.", zap.String("kid", currentKey.id), zap.String("alg", currentKey.method.Alg()))
+	return
+}
+
+// Load a key from the configuration
+func loadKey(kc setting.JWTKey) (k *signingKey, err error) {
+	k = &signingKey{id: kc.ID}
+	switch kc.Algorithm {
+	case AlgHS256, "":
+		k.method = jwt.SigningMethodHS256
+		secret := []byte(kc.Secret)
+		if kc.SecretFile != "" {
+			secret, err = os.ReadFile(kc.SecretFile)
+			if err != nil {
+				return nil, err
+			}
+			secret = []byte(strings.TrimSpace(string(secret)))
+		}
+		if len(secret) < 32 {
+			return nil, errors.New("HS256 secret must be at least 32 bytes")
+		}
+		k.signKey = secret
+		k.verifyKey = secret
+	case AlgRS256:
+		k.method = jwt.SigningMethodRS256
+		if kc.PrivateKeyFile != "" {
+			pemBytes, err := os.ReadFile(kc.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+			if err != nil {
+				return nil, err
+			}
+			k.signKey = privateKey
+			k.verifyKey = &privateKey.PublicKey
+		}
+		if kc.PublicKeyFile != "" {
+			pemBytes, err := os.ReadFile(kc.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			publicKey, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes)
+			if err != nil {
+				return nil, err
+			}
+			k.verifyKey = publicKey
+		}
+	case AlgEdDSA:
+		k.method = SigningMethodEdDSA
+		if kc.PrivateKeyFile != "" {
+			der, err := readPEM(kc.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			parsed, err := x509.ParsePKCS8PrivateKey(der)
+			if err != nil {
+				return nil, err
+			}
+			privateKey, ok := parsed.(ed25519.PrivateKey)
+			if !ok {
+				return nil, errEd25519Key
+			}
+			k.signKey = privateKey
+			k.verifyKey = privateKey.Public().(ed25519.PublicKey)
+		}
+		if kc.PublicKeyFile != "" {
+			der, err := readPEM(kc.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			parsed, err := x509.ParsePKIXPublicKey(der)
+			if err != nil {
+				return nil, err
+			}
+			publicKey, ok := parsed.(ed25519.PublicKey)
+			if !ok {
+				return nil, errEd25519Key
+			}
+			k.verifyKey = publicKey
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", kc.Algorithm)
+	}
+	if k.verifyKey == nil {
+		return nil, errors.New("privatekeyfile or publickeyfile is required")
+	}
+	return
+}
+
+// Read the DER bytes of the first PEM block in the file
+func readPEM(fileName string) ([]byte, error) {
+	pemBytes, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM data found in " + fileName)
+	}
+	return block.Bytes, nil
+}
+
+// Look up the verification key of a token by its kid header.
+// The token algorithm must match the key, so a public key can never be used as an HMAC secret.
+func lookupKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	k, ok := verifyKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+	}
+	return k.verifyKey, nil
+}
+
+// JSON Web Key, public part only
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // OKP curve
+	X   string `json:"x,omitempty"`   // OKP public key
+}
+
+// JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Get the public keys that other services can use to verify tokens.
+// HS256 secrets are never published.
+func PublicKeys() JWKS {
+	jwks := JWKS{Keys: make([]JWK, 0)}
+	for _, k := range verifyKeys {
+		switch key := k.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA",
+				Kid: k.id,
+				Use: "sig",
+				Alg: k.method.Alg(),
+				N:   jwt.EncodeSegment(key.N.Bytes()),
+				E:   jwt.EncodeSegment(big.NewInt(int64(key.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "OKP",
+				Kid: k.id,
+				Use: "sig",
+				Alg: k.method.Alg(),
+				Crv: "Ed25519",
+				X:   jwt.EncodeSegment(key),
+			})
+		}
+	}
+	return jwks
+}
@@ -0,0 +1,169 @@
+package ldapauth
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sccsmsserver/setting"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("ldap: invalid credentials")
+	ErrUserNotFound       = errors.New("ldap: user not found")
+	ErrAmbiguousUser      = errors.New("ldap: user filter matches more than one entry")
+)
+
+// Default attribute names, match Active Directory
+const (
+	defaultUserFilter      = "(&(objectClass=user)(sAMAccountName=%s))"
+	defaultNameAttribute   = "displayName"
+	defaultEmailAttribute  = "mail"
+	defaultMobileAttribute = "mobile"
+	defaultGroupAttribute  = "memberOf"
+	defaultTimeout         = 10 * time.Second
+)
+
+// Directory connection, satisfied by *ldap.Conn
+type Conn interface {
+	Bind(username, password string) error
+	Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// Connect to the directory server.
+// Replace it to authenticate against an in-process directory stub.
+var Dial = func(cfg *setting.LDAPConfig) (Conn, error) {
+	timeout := defaultTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	// StartTLS needs the server name to verify the certificate
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if u, err := url.Parse(cfg.URL); err == nil {
+		tlsConfig.ServerName = u.Hostname()
+	}
+	conn, err := ldap.DialURL(cfg.URL, ldap.DialWithDialer(&net.Dialer{Timeout: timeout}), ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(timeout)
+	if cfg.StartTLS {
+		if err = conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Directory user
+type Entry struct {
+	DN     string
+	Name   string
+	Email  string
+	Mobile string
+	Dept   string   // Department code or name
+	Groups []string // Group DNs
+}
+
+// Check if the user is a member of the group, given as DN or CN
+func (e *Entry) InGroup(group string) bool {
+	for _, g := range e.Groups {
+		if strings.EqualFold(g, group) || strings.EqualFold(firstRDN(g, "cn"), group) {
+			return true
+		}
+	}
+	return false
+}
+
+// Value of the first relative DN with the attribute type
+func firstRDN(dn string, attrType string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return ""
+	}
+	for _, rdn := range parsed.RDNs {
+		for _, a := range rdn.Attributes {
+			if strings.EqualFold(a.Type, attrType) {
+				return a.Value
+			}
+		}
+	}
+	return ""
+}
+
+// Return the value, or the default value when empty
+func orDefault(v string, d string) string {
+	if v == "" {
+		return d
+	}
+	return v
+}
+
+// Authenticate the user by binding as the user entry.
+// The entry is first searched with the service account, so users log in with their account name instead of the DN.
+func Authenticate(cfg *setting.LDAPConfig, userCode string, password string) (e *Entry, err error) {
+	// An empty password would make an unauthenticated bind that always succeeds
+	if userCode == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+	conn, err := Dial(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: dial %s: %w", cfg.URL, err)
+	}
+	defer conn.Close()
+	if cfg.BindDN != "" {
+		if err = conn.Bind(cfg.BindDN, cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("ldap: service account bind: %w", err)
+		}
+	}
+	// Search the user entry
+	nameAttr := orDefault(cfg.NameAttribute, defaultNameAttribute)
+	emailAttr := orDefault(cfg.EmailAttribute, defaultEmailAttribute)
+	mobileAttr := orDefault(cfg.MobileAttribute, defaultMobileAttribute)
+	groupAttr := orDefault(cfg.GroupAttribute, defaultGroupAttribute)
+	attributes := []string{nameAttr, emailAttr, mobileAttr, groupAttr}
+	if cfg.DeptAttribute != "" {
+		attributes = append(attributes, cfg.DeptAttribute)
+	}
+	filter := fmt.Sprintf(orDefault(cfg.UserFilter, defaultUserFilter), ldap.EscapeFilter(userCode))
+	req := ldap.NewSearchRequest(cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		filter, attributes, nil)
+	res, err := conn.Search(req)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: search user: %w", err)
+	}
+	if len(res.Entries) == 0 {
+		return nil, ErrUserNotFound
+	}
+	if len(res.Entries) > 1 {
+		return nil, ErrAmbiguousUser
+	}
+	entry := res.Entries[0]
+	// Verify the password
+	if err = conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap: user bind: %w", err)
+	}
+	e = &Entry{
+		DN:     entry.DN,
+		Name:   entry.GetAttributeValue(nameAttr),
+		Email:  entry.GetAttributeValue(emailAttr),
+		Mobile: entry.GetAttributeValue(mobileAttr),
+		Groups: entry.GetAttributeValues(groupAttr),
+	}
+	if cfg.DeptAttribute != "" {
+		e.Dept = entry.GetAttributeValue(cfg.DeptAttribute)
+	} else {
+		e.Dept = firstRDN(entry.DN, "ou")
+	}
+	return
+}
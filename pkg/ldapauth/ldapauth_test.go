@@ -0,0 +1,168 @@
+package ldapauth_test
+
+import (
+	"errors"
+	"reflect"
+	"sccsmsserver/pkg/ldapauth"
+	"sccsmsserver/pkg/ldapauth/ldapauthtest"
+	"sccsmsserver/setting"
+	"strings"
+	"testing"
+)
+
+const (
+	annDN = "CN=Ann Lee,OU=Sales,DC=example,DC=com"
+	bobDN = "CN=Bob Wu,OU=Plant,DC=example,DC=com"
+)
+
+// Directory with the service account and two users, connected in place of the server
+func useDirectory(t *testing.T) *ldapauthtest.Directory {
+	t.Helper()
+	d := &ldapauthtest.Directory{
+		BindDN:       "CN=svc,DC=example,DC=com",
+		BindPassword: "svc-secret",
+		Users: []ldapauthtest.User{
+			{DN: annDN, Password: "ann-secret", Attributes: map[string][]string{
+				"objectClass":    {"top", "person", "user"},
+				"sAMAccountName": {"ann"},
+				"displayName":    {"Ann Lee"},
+				"mail":           {"ann@example.com"},
+				"mobile":         {"13800000001"},
+				"department":     {"S01"},
+				"memberOf":       {"CN=Safety Officers,OU=Groups,DC=example,DC=com", "CN=Staff,OU=Groups,DC=example,DC=com"},
+			}},
+			{DN: bobDN, Password: "bob-secret", Attributes: map[string][]string{
+				"objectClass":    {"top", "person", "user"},
+				"sAMAccountName": {"bob"},
+			}},
+		},
+	}
+	old := ldapauth.Dial
+	ldapauth.Dial = d.Dial
+	t.Cleanup(func() { ldapauth.Dial = old })
+	return d
+}
+
+func config() *setting.LDAPConfig {
+	return &setting.LDAPConfig{
+		Enabled:      true,
+		URL:          "ldap://dc1.example.com",
+		BindDN:       "CN=svc,DC=example,DC=com",
+		BindPassword: "svc-secret",
+		BaseDN:       "DC=example,DC=com",
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	d := useDirectory(t)
+	e, err := ldapauth.Authenticate(config(), "ann", "ann-secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	want := &ldapauth.Entry{
+		DN:     annDN,
+		Name:   "Ann Lee",
+		Email:  "ann@example.com",
+		Mobile: "13800000001",
+		Dept:   "Sales",
+		Groups: []string{"CN=Safety Officers,OU=Groups,DC=example,DC=com", "CN=Staff,OU=Groups,DC=example,DC=com"},
+	}
+	if !reflect.DeepEqual(e, want) {
+		t.Errorf("Authenticate() = %+v, want %+v", e, want)
+	}
+	// The entry is searched with the service account, then the password verified by binding as the user
+	if binds := d.Binds(); !reflect.DeepEqual(binds, []string{"CN=svc,DC=example,DC=com", annDN}) {
+		t.Errorf("binds = %q, want the service account then the user", binds)
+	}
+	searches := d.Searches()
+	if len(searches) != 1 || searches[0].Filter != "(&(objectClass=user)(sAMAccountName=ann))" || searches[0].BaseDN != "DC=example,DC=com" {
+		t.Errorf("searches = %+v, want the default filter under the base DN", searches)
+	}
+}
+
+func TestAuthenticateConfiguredAttributes(t *testing.T) {
+	useDirectory(t)
+	cfg := config()
+	cfg.BindDN, cfg.BindPassword = "", ""
+	cfg.UserFilter = "(&(objectClass=person)(sAMAccountName=%s))"
+	cfg.NameAttribute = "sAMAccountName"
+	cfg.DeptAttribute = "department"
+	e, err := ldapauth.Authenticate(cfg, "ann", "ann-secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if e.Name != "ann" || e.Dept != "S01" {
+		t.Errorf("Name, Dept = %q, %q, want ann, S01", e.Name, e.Dept)
+	}
+}
+
+func TestAuthenticateFailed(t *testing.T) {
+	tests := []struct {
+		name     string
+		userCode string
+		password string
+		cfg      func(*setting.LDAPConfig)
+		dialErr  error
+		want     error
+	}{
+		{name: "bad password", userCode: "ann", password: "bob-secret", want: ldapauth.ErrInvalidCredentials},
+		{name: "empty password", userCode: "ann", password: "", want: ldapauth.ErrInvalidCredentials},
+		{name: "empty user code", userCode: "", password: "ann-secret", want: ldapauth.ErrInvalidCredentials},
+		{name: "missing user", userCode: "cat", password: "cat-secret", want: ldapauth.ErrUserNotFound},
+		{name: "wildcard user code", userCode: "*", password: "ann-secret", want: ldapauth.ErrUserNotFound},
+		{name: "user outside the base DN", userCode: "ann", password: "ann-secret",
+			cfg: func(c *setting.LDAPConfig) { c.BaseDN = "OU=Plant,DC=example,DC=com" }, want: ldapauth.ErrUserNotFound},
+		{name: "ambiguous filter", userCode: "user", password: "ann-secret",
+			cfg: func(c *setting.LDAPConfig) { c.UserFilter = "(objectClass=%s)" }, want: ldapauth.ErrAmbiguousUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useDirectory(t)
+			cfg := config()
+			if tt.cfg != nil {
+				tt.cfg(cfg)
+			}
+			e, err := ldapauth.Authenticate(cfg, tt.userCode, tt.password)
+			if !errors.Is(err, tt.want) || e != nil {
+				t.Fatalf("Authenticate() = %+v, %v, want %v", e, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateUnavailable(t *testing.T) {
+	d := useDirectory(t)
+	d.DialErr = errors.New("connection refused")
+	if _, err := ldapauth.Authenticate(config(), "ann", "ann-secret"); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("Authenticate() error = %v, want the dial error", err)
+	}
+
+	d.DialErr = nil
+	cfg := config()
+	cfg.BindPassword = "wrong"
+	_, err := ldapauth.Authenticate(cfg, "ann", "ann-secret")
+	// A wrong service account password is a configuration error, not a wrong user password
+	if err == nil || errors.Is(err, ldapauth.ErrInvalidCredentials) {
+		t.Errorf("Authenticate() error = %v, want the service account bind error", err)
+	}
+}
+
+func TestInGroup(t *testing.T) {
+	e := &ldapauth.Entry{Groups: []string{"CN=Safety Officers,OU=Groups,DC=example,DC=com"}}
+	tests := []struct {
+		group string
+		want  bool
+	}{
+		{"CN=Safety Officers,OU=Groups,DC=example,DC=com", true},
+		{"cn=safety officers,ou=groups,dc=example,dc=com", true},
+		{"Safety Officers", true},
+		{"safety officers", true},
+		{"Groups", false},
+		{"Staff", false},
+	}
+	for _, tt := range tests {
+		if got := e.InGroup(tt.group); got != tt.want {
+			t.Errorf("InGroup(%q) = %v, want %v", tt.group, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Package ldapauthtest provides an in-memory directory for testing the LDAP authentication.
+package ldapauthtest
+
+import (
+	"errors"
+	"regexp"
+	"sccsmsserver/pkg/ldapauth"
+	"sccsmsserver/setting"
+	"strings"
+	"sync"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Directory user entry
+type User struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string // e.g. objectClass, sAMAccountName, displayName, memberOf
+}
+
+// In-memory directory, connect to it by replacing ldapauth.Dial with its Dial method
+type Directory struct {
+	BindDN       string // Service account, empty for anonymous search
+	BindPassword string
+	Users        []User
+	DialErr      error // Error returned by Dial, e.g. to test an unreachable server
+
+	mu       sync.Mutex
+	binds    []string
+	searches []*ldap.SearchRequest
+}
+
+// Connect to the directory
+func (d *Directory) Dial(cfg *setting.LDAPConfig) (ldapauth.Conn, error) {
+	if d.DialErr != nil {
+		return nil, d.DialErr
+	}
+	return &conn{d: d}, nil
+}
+
+// DNs bound so far, successfully or not
+func (d *Directory) Binds() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.binds...)
+}
+
+// Search requests made so far
+func (d *Directory) Searches() []*ldap.SearchRequest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*ldap.SearchRequest(nil), d.searches...)
+}
+
+type conn struct {
+	d *Directory
+}
+
+func (c *conn) Bind(username, password string) error {
+	d := c.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.binds = append(d.binds, username)
+	if d.BindDN != "" && username == d.BindDN && password == d.BindPassword {
+		return nil
+	}
+	for _, u := range d.Users {
+		if strings.EqualFold(u.DN, username) && password != "" && password == u.Password {
+			return nil
+		}
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+// Equality assertions of a filter, e.g. (sAMAccountName=ann)
+var assertionRe = regexp.MustCompile(`\(([^()=]+)=([^()]*)\)`)
+
+// Return the users matching all equality assertions of the filter,
+// enough for the default filter and filters of the same shape
+func (c *conn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	d := c.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.searches = append(d.searches, req)
+	assertions := assertionRe.FindAllStringSubmatch(req.Filter, -1)
+	if len(assertions) == 0 {
+		return nil, ldap.NewError(ldap.LDAPResultFilterError, errors.New("unsupported filter "+req.Filter))
+	}
+	res := &ldap.SearchResult{}
+	for _, u := range d.Users {
+		if !strings.HasSuffix(strings.ToLower(u.DN), strings.ToLower(req.BaseDN)) || !u.matches(assertions) {
+			continue
+		}
+		attributes := make(map[string][]string, len(req.Attributes))
+		for _, name := range req.Attributes {
+			if v, ok := u.attribute(name); ok {
+				attributes[name] = v
+			}
+		}
+		res.Entries = append(res.Entries, ldap.NewEntry(u.DN, attributes))
+	}
+	return res, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+// Check whether the user has all the attribute values
+func (u *User) matches(assertions [][]string) bool {
+	for _, a := range assertions {
+		values, _ := u.attribute(a[1])
+		found := false
+		for _, v := range values {
+			if strings.EqualFold(v, a[2]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Values of the attribute, the name is case insensitive
+func (u *User) attribute(name string) ([]string, bool) {
+	for k, v := range u.Attributes {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,169 @@
+// Package mailer sends plain text emails through an SMTP server.
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"sccsmsserver/setting"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrDisabled = errors.New("mailer: email notifications are not enabled")
+
+// TLS modes
+const (
+	TLSAuto     = ""         // STARTTLS when the server offers it
+	TLSStart    = "starttls" // STARTTLS required
+	TLSImplicit = "tls"      // TLS from the start of the connection
+	TLSNone     = "none"     // Plain connection, e.g. to a local SMTP stub
+)
+
+// Timeout of the whole conversation with the SMTP server
+const sendTimeout = 30 * time.Second
+
+var cfg *setting.SMTPConfig
+
+// Initialize the SMTP configuration
+func Init(c *setting.SMTPConfig) (err error) {
+	cfg = nil
+	if c == nil || !c.Enabled {
+		return
+	}
+	if c.Host == "" || c.From == "" {
+		return errors.New("mailer: host and from are required")
+	}
+	if _, err = mail.ParseAddress(c.From); err != nil {
+		return fmt.Errorf("mailer: invalid from address: %w", err)
+	}
+	switch c.TLS {
+	case TLSAuto, TLSStart, TLSImplicit, TLSNone:
+	default:
+		return fmt.Errorf("mailer: unknown tls mode %q", c.TLS)
+	}
+	// smtp.PlainAuth only sends the credentials over TLS or to the local host
+	if c.TLS == TLSNone && c.Username != "" && !isLocalhost(c.Host) {
+		return errors.New("mailer: the username and password can't be sent over a plain connection to a remote host, use tls or starttls")
+	}
+	cfg = c
+	return
+}
+
+// Check whether the host is the local host, the way smtp.PlainAuth does
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+// Check whether email notifications are enabled
+func Enabled() bool {
+	return cfg != nil
+}
+
+// Send a plain text email
+func Send(to string, subject string, body string) (err error) {
+	if cfg == nil {
+		return ErrDisabled
+	}
+	from, _ := mail.ParseAddress(cfg.From)
+	rcpt, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("mailer: invalid recipient address: %w", err)
+	}
+	msg, err := compose(from, rcpt, subject, body)
+	if err != nil {
+		return
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+		if cfg.TLS == TLSImplicit {
+			port = 465
+		}
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: cfg.Host, InsecureSkipVerify: cfg.InsecureSkipVerify}
+	dialer := &net.Dialer{Timeout: sendTimeout}
+	var conn net.Conn
+	if cfg.TLS == TLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return
+	}
+	_ = conn.SetDeadline(time.Now().Add(sendTimeout))
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer c.Close()
+	if cfg.TLS == TLSAuto || cfg.TLS == TLSStart {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err = c.StartTLS(tlsConfig); err != nil {
+				return
+			}
+		} else if cfg.TLS == TLSStart {
+			return errors.New("mailer: the server doesn't support STARTTLS")
+		}
+	}
+	if cfg.Username != "" {
+		if err = c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return
+		}
+	}
+	if err = c.Mail(from.Address); err != nil {
+		return
+	}
+	if err = c.Rcpt(rcpt.Address); err != nil {
+		return
+	}
+	w, err := c.Data()
+	if err != nil {
+		return
+	}
+	if _, err = w.Write(msg); err != nil {
+		return
+	}
+	if err = w.Close(); err != nil {
+		return
+	}
+	return c.Quit()
+}
+
+// Compose the message with its headers, the body is quoted-printable UTF-8 text
+func compose(from *mail.Address, to *mail.Address, subject string, body string) (msg []byte, err error) {
+	id := make([]byte, 12)
+	if _, err = rand.Read(id); err != nil {
+		return
+	}
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+	var b bytes.Buffer
+	b.WriteString("From: " + from.String() + "\r\n")
+	b.WriteString("To: " + to.String() + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("Message-ID: <" + hex.EncodeToString(id) + "@" + domain + ">\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	if _, err = qp.Write([]byte(body)); err != nil {
+		return
+	}
+	if err = qp.Close(); err != nil {
+		return
+	}
+	return b.Bytes(), nil
+}
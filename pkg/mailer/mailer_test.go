@@ -0,0 +1,136 @@
+package mailer
+
+import (
+	"errors"
+	"sccsmsserver/pkg/mailer/mailertest"
+	"sccsmsserver/setting"
+	"strings"
+	"testing"
+)
+
+// Start the SMTP server and point the mailer at it
+func startServer(t *testing.T, username string) *mailertest.Server {
+	t.Helper()
+	s, err := mailertest.NewServer()
+	if err != nil {
+		t.Fatalf("mailertest.NewServer: %v", err)
+	}
+	t.Cleanup(s.Close)
+	err = Init(&setting.SMTPConfig{
+		Enabled:  true,
+		Host:     s.Host,
+		Port:     s.Port,
+		TLS:      TLSNone,
+		Username: username,
+		Password: "secret",
+		From:     "SCCSMS <noreply@example.com>",
+	})
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() { cfg = nil })
+	return s
+}
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       *setting.SMTPConfig
+		enabled bool
+		wantErr bool
+	}{
+		{name: "no config", c: nil},
+		{name: "disabled", c: &setting.SMTPConfig{Host: "smtp.example.com"}},
+		{name: "starttls", c: &setting.SMTPConfig{Enabled: true, Host: "smtp.example.com", From: "noreply@example.com", Username: "u"}, enabled: true},
+		{name: "implicit tls", c: &setting.SMTPConfig{Enabled: true, Host: "smtp.example.com", TLS: TLSImplicit, From: "noreply@example.com", Username: "u"}, enabled: true},
+		{name: "plain without credentials", c: &setting.SMTPConfig{Enabled: true, Host: "smtp.example.com", TLS: TLSNone, From: "noreply@example.com"}, enabled: true},
+		{name: "plain credentials to localhost", c: &setting.SMTPConfig{Enabled: true, Host: "localhost", TLS: TLSNone, From: "noreply@example.com", Username: "u"}, enabled: true},
+		{name: "plain credentials to 127.0.0.1", c: &setting.SMTPConfig{Enabled: true, Host: "127.0.0.1", TLS: TLSNone, From: "noreply@example.com", Username: "u"}, enabled: true},
+		{name: "plain credentials to a remote host", c: &setting.SMTPConfig{Enabled: true, Host: "smtp.example.com", TLS: TLSNone, From: "noreply@example.com", Username: "u"}, wantErr: true},
+		{name: "missing host", c: &setting.SMTPConfig{Enabled: true, From: "noreply@example.com"}, wantErr: true},
+		{name: "missing from", c: &setting.SMTPConfig{Enabled: true, Host: "smtp.example.com"}, wantErr: true},
+		{name: "invalid from", c: &setting.SMTPConfig{Enabled: true, Host: "smtp.example.com", From: "noreply"}, wantErr: true},
+		{name: "unknown tls mode", c: &setting.SMTPConfig{Enabled: true, Host: "smtp.example.com", TLS: "ssl", From: "noreply@example.com"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { cfg = nil })
+			err := Init(tt.c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if Enabled() != tt.enabled {
+				t.Errorf("Enabled() = %v, want %v", Enabled(), tt.enabled)
+			}
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	s := startServer(t, "mailer")
+	subject := "工单 WO-0001 第 1 行已指派给您"
+	body := "Hello Ann,\n\nRow 1 of Work Order WO-0001 has been assigned to you, a line longer than seventy-six characters is soft wrapped.\n"
+	if err := Send("Ann <ann@example.com>", subject, body); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	msgs := s.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("received %d messages, want 1", len(msgs))
+	}
+	m := msgs[0]
+	if m.Username != "mailer" {
+		t.Errorf("Username = %q, want mailer", m.Username)
+	}
+	if m.From != "noreply@example.com" {
+		t.Errorf("From = %q, want noreply@example.com", m.From)
+	}
+	if len(m.To) != 1 || m.To[0] != "ann@example.com" {
+		t.Errorf("To = %q, want [ann@example.com]", m.To)
+	}
+	if got, err := m.Subject(); err != nil || got != subject {
+		t.Errorf("Subject() = %q, %v, want %q", got, err, subject)
+	}
+	if got, err := m.Body(); err != nil || got != body {
+		t.Errorf("Body() = %q, %v, want %q", got, err, body)
+	}
+	if !strings.Contains(string(m.Data), "Content-Type: text/plain; charset=UTF-8\r\n") {
+		t.Errorf("Data misses the Content-Type header:\n%s", m.Data)
+	}
+}
+
+func TestSendWithoutCredentials(t *testing.T) {
+	s := startServer(t, "")
+	if err := Send("ann@example.com", "Subject", "Body"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	msgs := s.Messages()
+	if len(msgs) != 1 || msgs[0].Username != "" {
+		t.Fatalf("Messages() = %+v, want one message without login", msgs)
+	}
+}
+
+func TestSendRejected(t *testing.T) {
+	s := startServer(t, "mailer")
+	s.RejectRcpt("550 5.1.1 mailbox unavailable")
+	err := Send("ann@example.com", "Subject", "Body")
+	if err == nil || !strings.Contains(err.Error(), "mailbox unavailable") {
+		t.Fatalf("Send() error = %v, want the rejection of the server", err)
+	}
+	if len(s.Messages()) != 0 {
+		t.Errorf("received %d messages, want 0", len(s.Messages()))
+	}
+}
+
+func TestSendInvalidRecipient(t *testing.T) {
+	startServer(t, "")
+	if err := Send("ann", "Subject", "Body"); err == nil {
+		t.Fatal("Send() error = nil, want an invalid recipient error")
+	}
+}
+
+func TestSendDisabled(t *testing.T) {
+	cfg = nil
+	if err := Send("ann@example.com", "Subject", "Body"); !errors.Is(err, ErrDisabled) {
+		t.Fatalf("Send() error = %v, want ErrDisabled", err)
+	}
+}
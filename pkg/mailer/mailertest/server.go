@@ -0,0 +1,179 @@
+// Package mailertest provides an in-process SMTP server for testing the emails sent by mailer.
+package mailertest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Email received by the server
+type Message struct {
+	Username string // User of AUTH PLAIN, empty when the client didn't log in
+	From     string // Envelope sender
+	To       []string
+	Data     []byte // Message with its headers
+}
+
+// Subject of the message, decoded
+func (m Message) Subject() (subject string, err error) {
+	msg, err := mail.ReadMessage(strings.NewReader(string(m.Data)))
+	if err != nil {
+		return
+	}
+	return new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+}
+
+// Body of the message, decoded from quoted-printable, with LF line endings
+func (m Message) Body() (body string, err error) {
+	msg, err := mail.ReadMessage(strings.NewReader(string(m.Data)))
+	if err != nil {
+		return
+	}
+	b, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	return strings.ReplaceAll(string(b), "\r\n", "\n"), err
+}
+
+// SMTP server on a plain connection to the local host, for mailer.TLSNone
+type Server struct {
+	Host string
+	Port int
+
+	listener   net.Listener
+	wg         sync.WaitGroup
+	mu         sync.Mutex
+	messages   []Message
+	rejectRcpt string
+}
+
+// Start the server on a free port of 127.0.0.1
+func NewServer() (s *Server, err error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return
+	}
+	addr := l.Addr().(*net.TCPAddr)
+	s = &Server{Host: "127.0.0.1", Port: addr.Port, listener: l}
+	s.wg.Add(1)
+	go s.serve()
+	return
+}
+
+// Stop the server
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+// Messages received so far
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Set the reply of the server to RCPT, e.g. "550 5.1.1 mailbox unavailable", empty to accept the recipients
+func (s *Server) RejectRcpt(reply string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejectRcpt = reply
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// Talk SMTP with a client, just enough for net/smtp
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(lines ...string) {
+		for _, line := range lines {
+			io.WriteString(conn, line+"\r\n")
+		}
+	}
+	reply("220 " + s.Host + " ESMTP mailertest")
+	var msg Message
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			reply("250-"+s.Host, "250-AUTH PLAIN", "250 SIZE "+strconv.Itoa(10<<20))
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			cred, err := base64.StdEncoding.DecodeString(initial)
+			parts := strings.Split(string(cred), "\x00")
+			if !strings.EqualFold(mechanism, "PLAIN") || err != nil || len(parts) != 3 {
+				reply("535 5.7.8 authentication failed")
+				continue
+			}
+			msg.Username = parts[1]
+			reply("235 2.7.0 authentication succeeded")
+		case "MAIL":
+			msg.From = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			reply("250 2.1.0 ok")
+		case "RCPT":
+			s.mu.Lock()
+			reject := s.rejectRcpt
+			s.mu.Unlock()
+			if reject != "" {
+				reply(reject)
+				continue
+			}
+			msg.To = append(msg.To, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			reply("250 2.1.5 ok")
+		case "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.Data = []byte(data.String())
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			msg = Message{Username: msg.Username}
+			reply("250 2.0.0 queued")
+		case "RSET":
+			msg = Message{Username: msg.Username}
+			reply("250 2.0.0 ok")
+		case "NOOP":
+			reply("250 2.0.0 ok")
+		case "QUIT":
+			reply("221 2.0.0 bye")
+			return
+		default:
+			reply("502 5.5.2 command not implemented")
+		}
+	}
+}
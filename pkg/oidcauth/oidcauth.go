@@ -0,0 +1,153 @@
+package oidcauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sccsmsserver/setting"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrDisabled      = errors.New("oidc: single sign-on is not enabled")
+	ErrNonceMismatch = errors.New("oidc: nonce mismatch")
+)
+
+// Timeout of the requests to the IdP
+const requestTimeout = 15 * time.Second
+
+var (
+	cfg *setting.OIDCConfig
+	// The provider is discovered on first use, so an unreachable IdP doesn't prevent the server from starting
+	mu           sync.Mutex
+	provider     *oidc.Provider
+	verifier     *oidc.IDTokenVerifier
+	oauth2Config *oauth2.Config
+)
+
+// Initialize the OpenID Connect configuration
+func Init(c *setting.OIDCConfig) (err error) {
+	cfg = nil
+	provider = nil
+	if c == nil || !c.Enabled {
+		return
+	}
+	if c.Issuer == "" || c.ClientID == "" || c.RedirectURL == "" {
+		return errors.New("oidc: issuer, clientid and redirecturl are required")
+	}
+	switch c.MatchField {
+	case "", "code", "email", "mobile":
+	default:
+		return fmt.Errorf("oidc: unsupported matchfield %q", c.MatchField)
+	}
+	cfg = c
+	return
+}
+
+// Check if OpenID Connect login is enabled
+func Enabled() bool {
+	return cfg != nil
+}
+
+// Name of the IdP shown on the login button
+func ProviderName() string {
+	if cfg == nil || cfg.ProviderName == "" {
+		return "SSO"
+	}
+	return cfg.ProviderName
+}
+
+// ID token claim used to find the user
+func MatchClaim() string {
+	if cfg.MatchClaim == "" {
+		return "preferred_username"
+	}
+	return cfg.MatchClaim
+}
+
+// User field compared with the claim
+func MatchField() string {
+	if cfg.MatchField == "" {
+		return "code"
+	}
+	return cfg.MatchField
+}
+
+// Discover the provider endpoints and keys
+func getProvider(ctx context.Context) (*oidc.Provider, *oidc.IDTokenVerifier, *oauth2.Config, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if cfg == nil {
+		return nil, nil, nil, ErrDisabled
+	}
+	if provider != nil {
+		return provider, verifier, oauth2Config, nil
+	}
+	p, err := oidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+	provider = p
+	verifier = p.Verifier(&oidc.Config{ClientID: cfg.ClientID})
+	oauth2Config = &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Endpoint:     p.Endpoint(),
+		Scopes:       scopes,
+	}
+	return provider, verifier, oauth2Config, nil
+}
+
+// Generate a PKCE code verifier
+func GenerateVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// Build the IdP authorization URL
+func AuthCodeURL(state string, nonce string, codeVerifier string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	_, _, oc, err := getProvider(ctx)
+	if err != nil {
+		return "", err
+	}
+	return oc.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(codeVerifier)), nil
+}
+
+// Exchange the authorization code and verify the ID token.
+// Returns the ID token claims.
+func Exchange(code string, nonce string, codeVerifier string) (claims map[string]interface{}, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	_, v, oc, err := getProvider(ctx)
+	if err != nil {
+		return
+	}
+	token, err := oc.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: exchange code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("oidc: no id_token in the token response")
+	}
+	idToken, err := v.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: verify id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+	claims = make(map[string]interface{})
+	err = idToken.Claims(&claims)
+	return
+}
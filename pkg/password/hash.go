@@ -0,0 +1,104 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithm prefixes of the stored password hashes
+const (
+	PrefixArgon2id = "$argon2id$"
+	PrefixBcrypt   = "$2" // $2a$, $2b$ and $2y$
+)
+
+// Argon2id parameters used for new hashes
+const (
+	argon2Time    uint32 = 3
+	argon2Memory  uint32 = 64 * 1024
+	argon2Threads uint8  = 4
+	argon2KeyLen  uint32 = 32
+	argon2SaltLen        = 16
+)
+
+var (
+	ErrUnknownHash = errors.New("password: unknown hash format")
+	ErrInvalidHash = errors.New("password: invalid hash")
+)
+
+// Hash the password with argon2id.
+// The result is encoded in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>
+func Hash(plain string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(plain), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", PrefixArgon2id, argon2.Version,
+		argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Check whether the stored value carries a supported algorithm prefix
+func IsHashed(encoded string) bool {
+	return strings.HasPrefix(encoded, PrefixArgon2id) || strings.HasPrefix(encoded, PrefixBcrypt)
+}
+
+// Verify the password against an argon2id or bcrypt hash.
+// needsRehash reports a match whose hash is not argon2id with the current parameters.
+func Verify(plain string, encoded string) (match bool, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, PrefixArgon2id):
+		return verifyArgon2id(plain, encoded)
+	case strings.HasPrefix(encoded, PrefixBcrypt):
+		err = bcrypt.CompareHashAndPassword([]byte(encoded), []byte(plain))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+		return true, true, nil
+	}
+	return false, false, ErrUnknownHash
+}
+
+// Verify the password against an argon2id hash in the PHC string format
+func verifyArgon2id(plain string, encoded string) (match bool, needsRehash bool, err error) {
+	// "", "argon2id", "v=19", "m=65536,t=3,p=4", salt, key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, false, ErrInvalidHash
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, ErrInvalidHash
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, false, ErrInvalidHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false, ErrInvalidHash
+	}
+	otherKey := argon2.IDKey([]byte(plain), salt, time, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, otherKey) != 1 {
+		return false, false, nil
+	}
+	needsRehash = memory != argon2Memory || time != argon2Time || threads != argon2Threads || uint32(len(key)) != argon2KeyLen
+	return true, needsRehash, nil
+}
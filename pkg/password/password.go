@@ -15,12 +15,21 @@ const (
 
 // Generate a random password with a variable length of 8 to 16 characters
 func GenerateSecurePassword() (string, error) {
-	// Step 1: Lenght of the generated random password
+	// Lenght of the generated random password
 	length, err := rand.Int(rand.Reader, big.NewInt(9))
 	if err != nil {
 		return "", err
 	}
-	passwordLength := int(length.Int64()) + 8
+	return GenerateSecurePasswordOfLength(int(length.Int64()) + 8)
+}
+
+// Generate a random password of the given length, at least 8 characters
+func GenerateSecurePasswordOfLength(passwordLength int) (string, error) {
+	var err error
+	// Step 1: Passwords are at least 8 characters long
+	if passwordLength < DefaultMinLength {
+		passwordLength = DefaultMinLength
+	}
 
 	// Step 2: Ensure the password contains at least on uppercase letter,
 	// one lowercase letter, one number, and one special character
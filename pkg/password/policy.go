@@ -0,0 +1,60 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrTooShort    = errors.New("password: too short")
+	ErrCharClasses = errors.New("password: missing required character classes")
+)
+
+// Default minimum password length
+const DefaultMinLength = 8
+
+// Password policy
+type Policy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// Check if the password complies with the policy
+func (p Policy) Check(plain string) error {
+	minLength := p.MinLength
+	if minLength <= 0 {
+		minLength = DefaultMinLength
+	}
+	if utf8.RuneCountInString(plain) < minLength {
+		return ErrTooShort
+	}
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range plain {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || strings.ContainsRune(symbols, r):
+			hasSymbol = true
+		}
+	}
+	if (p.RequireUpper && !hasUpper) || (p.RequireLower && !hasLower) ||
+		(p.RequireDigit && !hasDigit) || (p.RequireSymbol && !hasSymbol) {
+		return ErrCharClasses
+	}
+	return nil
+}
+
+// Generate a random password that complies with the policy.
+// The password is MinLength long and contains every character class.
+func (p Policy) Generate() (string, error) {
+	return GenerateSecurePasswordOfLength(p.MinLength)
+}
@@ -0,0 +1,334 @@
+// Package recurrence expands recurrence rules into occurrence dates.
+// Rules are written directly or parsed from a subset of the iCalendar RRULE (RFC 5545):
+// FREQ (DAILY, WEEKLY, MONTHLY or YEARLY), INTERVAL, BYDAY, BYMONTHDAY, BYMONTH, COUNT, UNTIL and WKST=MO.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence frequency
+type Frequency int16
+
+const (
+	Daily   Frequency = 1
+	Weekly  Frequency = 2
+	Monthly Frequency = 3
+	Yearly  Frequency = 4
+)
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// Weekday of a rule.
+// Nth is the occurrence of the weekday in the month, e.g. 2 for the second Monday (2MO),
+// -1 for the last Friday (-1FR) and 0 for every one.
+type WeekdayNum struct {
+	Weekday time.Weekday
+	Nth     int
+}
+
+// Recurrence rule.
+// Weeks start on Monday, ordinal weekdays count within the month.
+type Rule struct {
+	Freq       Frequency
+	Interval   int          // Every Interval days, weeks, months or years, 0 is the same as 1
+	ByDay      []WeekdayNum // Weekdays the occurrences fall on
+	ByMonthDay []int        // Days of the month, negative values count from the end of the month
+	ByMonth    []time.Month // Months of the year
+	Count      int          // Number of occurrences, 0 for no limit
+	Until      time.Time    // Last possible occurrence date, zero for no limit
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Parse an RRULE such as "FREQ=MONTHLY;BYDAY=-1FR" or "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH".
+// Dates without a time zone are in the local time zone.
+func Parse(s string) (r Rule, err error) {
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "RRULE:")
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return r, fmt.Errorf("%w: %q", ErrInvalidRule, part)
+		}
+		switch name {
+		case "FREQ":
+			switch value {
+			case "DAILY":
+				r.Freq = Daily
+			case "WEEKLY":
+				r.Freq = Weekly
+			case "MONTHLY":
+				r.Freq = Monthly
+			case "YEARLY":
+				r.Freq = Yearly
+			default:
+				return r, fmt.Errorf("%w: unsupported FREQ %q", ErrInvalidRule, value)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+		case "UNTIL":
+			r.Until, err = parseUntil(value)
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				var wd WeekdayNum
+				wd, err = parseWeekdayNum(v)
+				if err != nil {
+					break
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseInts(value)
+		case "BYMONTH":
+			var months []int
+			months, err = parseInts(value)
+			for _, m := range months {
+				r.ByMonth = append(r.ByMonth, time.Month(m))
+			}
+		case "WKST":
+			if value != "MO" {
+				return r, fmt.Errorf("%w: only WKST=MO is supported", ErrInvalidRule)
+			}
+		default:
+			return r, fmt.Errorf("%w: unsupported %s", ErrInvalidRule, name)
+		}
+		if err != nil {
+			return r, fmt.Errorf("%w: %q", ErrInvalidRule, part)
+		}
+	}
+	return r, r.Validate()
+}
+
+// Parse the UNTIL value, a date or a date and time
+func parseUntil(value string) (t time.Time, err error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if strings.HasSuffix(layout, "Z") {
+			t, err = time.Parse(layout, value)
+		} else {
+			t, err = time.ParseInLocation(layout, value, time.Local)
+		}
+		if err == nil {
+			return
+		}
+	}
+	return
+}
+
+// Parse a BYDAY value such as MO, 2TU or -1FR
+func parseWeekdayNum(value string) (wd WeekdayNum, err error) {
+	if len(value) < 2 {
+		return wd, ErrInvalidRule
+	}
+	weekday, ok := weekdayNames[value[len(value)-2:]]
+	if !ok {
+		return wd, ErrInvalidRule
+	}
+	wd.Weekday = weekday
+	if nth := value[:len(value)-2]; nth != "" {
+		wd.Nth, err = strconv.Atoi(nth)
+	}
+	return
+}
+
+// Parse a comma separated list of integers
+func parseInts(value string) (ints []int, err error) {
+	for _, v := range strings.Split(value, ",") {
+		var i int
+		i, err = strconv.Atoi(v)
+		if err != nil {
+			return
+		}
+		ints = append(ints, i)
+	}
+	return
+}
+
+// Check the rule
+func (r Rule) Validate() error {
+	if r.Freq < Daily || r.Freq > Yearly {
+		return fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if r.Interval < 0 || r.Count < 0 {
+		return fmt.Errorf("%w: INTERVAL and COUNT can't be negative", ErrInvalidRule)
+	}
+	for _, wd := range r.ByDay {
+		if wd.Weekday < time.Sunday || wd.Weekday > time.Saturday || wd.Nth < -5 || wd.Nth > 5 {
+			return fmt.Errorf("%w: invalid BYDAY", ErrInvalidRule)
+		}
+		if wd.Nth != 0 && r.Freq != Monthly && r.Freq != Yearly {
+			return fmt.Errorf("%w: ordinal BYDAY needs FREQ=MONTHLY or YEARLY", ErrInvalidRule)
+		}
+	}
+	for _, d := range r.ByMonthDay {
+		if d == 0 || d < -31 || d > 31 {
+			return fmt.Errorf("%w: invalid BYMONTHDAY", ErrInvalidRule)
+		}
+	}
+	for _, m := range r.ByMonth {
+		if m < time.January || m > time.December {
+			return fmt.Errorf("%w: invalid BYMONTH", ErrInvalidRule)
+		}
+	}
+	return nil
+}
+
+// Occurrence dates of the rule from start, that fall between from and to inclusive.
+// The dates are at midnight in the location of start, the time of day of the arguments is ignored.
+func (r Rule) Between(start, from, to time.Time) (dates []time.Time) {
+	loc := start.Location()
+	start = dateOf(start, loc)
+	from = dateOf(from, loc)
+	to = dateOf(to, loc)
+	if !r.Until.IsZero() {
+		if until := dateOf(r.Until, loc); until.Before(to) {
+			to = until
+		}
+	}
+	// COUNT counts the occurrences from the start
+	day := from
+	if r.Count > 0 || day.Before(start) {
+		day = start
+	}
+	occurrences := 0
+	for ; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if !r.matches(start, day) {
+			continue
+		}
+		occurrences++
+		if r.Count > 0 && occurrences > r.Count {
+			break
+		}
+		if !day.Before(from) {
+			dates = append(dates, day)
+		}
+	}
+	return
+}
+
+// Check whether the date is an occurrence of the rule from start
+func (r Rule) matches(start, day time.Time) bool {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	noDay := len(r.ByDay) == 0 && len(r.ByMonthDay) == 0
+	switch r.Freq {
+	case Daily:
+		if daysBetween(start, day)%interval != 0 {
+			return false
+		}
+	case Weekly:
+		if daysBetween(weekStart(start), weekStart(day))/7%interval != 0 {
+			return false
+		}
+		if noDay && day.Weekday() != start.Weekday() {
+			return false
+		}
+	case Monthly:
+		months := (day.Year()-start.Year())*12 + int(day.Month()-start.Month())
+		if months%interval != 0 {
+			return false
+		}
+		if noDay && day.Day() != start.Day() {
+			return false
+		}
+	case Yearly:
+		if (day.Year()-start.Year())%interval != 0 {
+			return false
+		}
+		if len(r.ByMonth) == 0 && day.Month() != start.Month() {
+			return false
+		}
+		if noDay && day.Day() != start.Day() {
+			return false
+		}
+	default:
+		return false
+	}
+	if len(r.ByMonth) > 0 && !containsMonth(r.ByMonth, day.Month()) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 && !matchMonthDay(r.ByMonthDay, day) {
+		return false
+	}
+	if len(r.ByDay) > 0 && !matchWeekday(r.ByDay, day) {
+		return false
+	}
+	return true
+}
+
+func containsMonth(months []time.Month, month time.Month) bool {
+	for _, m := range months {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+func matchMonthDay(monthDays []int, day time.Time) bool {
+	last := daysIn(day)
+	for _, d := range monthDays {
+		if d == day.Day() || (d < 0 && last+d+1 == day.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchWeekday(weekdays []WeekdayNum, day time.Time) bool {
+	for _, wd := range weekdays {
+		if wd.Weekday != day.Weekday() {
+			continue
+		}
+		switch {
+		case wd.Nth == 0:
+			return true
+		case wd.Nth > 0 && (day.Day()-1)/7+1 == wd.Nth:
+			return true
+		case wd.Nth < 0 && (daysIn(day)-day.Day())/7+1 == -wd.Nth:
+			return true
+		}
+	}
+	return false
+}
+
+// Midnight of the date of t in loc
+func dateOf(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// Number of days in the month of t
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// Number of calendar days from a to b, daylight saving time changes don't count
+func daysBetween(a, b time.Time) int {
+	ua := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	ub := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(ub.Sub(ua).Hours() / 24)
+}
+
+// Monday of the week of t
+func weekStart(t time.Time) time.Time {
+	return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+}
@@ -0,0 +1,226 @@
+package recurrence
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		rule string
+		want Rule
+	}{
+		{"FREQ=DAILY", Rule{Freq: Daily}},
+		{" rrule:freq=weekly;interval=2;byday=mo,th; ", Rule{Freq: Weekly, Interval: 2,
+			ByDay: []WeekdayNum{{time.Monday, 0}, {time.Thursday, 0}}}},
+		{"RRULE:FREQ=MONTHLY;BYDAY=2TU,-1FR;COUNT=10", Rule{Freq: Monthly, Count: 10,
+			ByDay: []WeekdayNum{{time.Tuesday, 2}, {time.Friday, -1}}}},
+		{"FREQ=MONTHLY;BYDAY=+3WE", Rule{Freq: Monthly, ByDay: []WeekdayNum{{time.Wednesday, 3}}}},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1", Rule{Freq: Monthly, ByMonthDay: []int{1, -1}}},
+		{"FREQ=YEARLY;BYMONTH=3,9;BYMONTHDAY=1;WKST=MO", Rule{Freq: Yearly,
+			ByMonth: []time.Month{time.March, time.September}, ByMonthDay: []int{1}}},
+		{"FREQ=DAILY;UNTIL=20250110T120000Z", Rule{Freq: Daily, Until: time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)}},
+		{"FREQ=DAILY;UNTIL=20250110T083000", Rule{Freq: Daily, Until: time.Date(2025, 1, 10, 8, 30, 0, 0, time.Local)}},
+		{"FREQ=DAILY;UNTIL=20250110", Rule{Freq: Daily, Until: time.Date(2025, 1, 10, 0, 0, 0, 0, time.Local)}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.rule)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.rule, err)
+			continue
+		}
+		if !got.Until.Equal(tt.want.Until) || got.Until.Location() != tt.want.Until.Location() {
+			t.Errorf("Parse(%q) Until = %v, want %v", tt.rule, got.Until, tt.want.Until)
+		}
+		got.Until, tt.want.Until = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"INTERVAL=2",
+		"FREQ",
+		"FREQ=",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=-1",
+		"FREQ=DAILY;INTERVAL=x",
+		"FREQ=DAILY;COUNT=-3",
+		"FREQ=DAILY;UNTIL=2025",
+		"FREQ=WEEKLY;BYDAY=2MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=MONTHLY;BYDAY=-6MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYDAY=M",
+		"FREQ=MONTHLY;BYDAY=MO,",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=-32",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=DAILY;WKST=SU",
+		"FREQ=DAILY;BYSETPOS=1",
+	}
+	for _, rule := range tests {
+		if _, err := Parse(rule); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Parse(%q) error = %v, want %v", rule, err, ErrInvalidRule)
+		}
+	}
+}
+
+// Midnight of the date in UTC
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Dates formatted for the comparison
+func formatDates(dates []time.Time) []string {
+	s := make([]string, 0, len(dates))
+	for _, d := range dates {
+		s = append(s, d.Format("2006-01-02"))
+	}
+	return s
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		rule  string
+		start string
+		from  string
+		to    string
+		want  []string
+	}{
+		// INTERVAL
+		{"FREQ=DAILY;INTERVAL=3", "2025-01-01", "2025-01-01", "2025-01-10",
+			[]string{"2025-01-01", "2025-01-04", "2025-01-07", "2025-01-10"}},
+		{"FREQ=DAILY;INTERVAL=3", "2025-01-01", "2025-01-05", "2025-01-10",
+			[]string{"2025-01-07", "2025-01-10"}},
+		{"FREQ=WEEKLY", "2025-01-01", "2025-01-01", "2025-01-20",
+			[]string{"2025-01-01", "2025-01-08", "2025-01-15"}},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", "2025-01-06", "2025-01-01", "2025-01-31",
+			[]string{"2025-01-06", "2025-01-09", "2025-01-20", "2025-01-23"}},
+		// The weeks start on Monday, a Sunday start is in the week before the next Monday
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,SU", "2025-01-05", "2025-01-01", "2025-01-31",
+			[]string{"2025-01-05", "2025-01-13", "2025-01-19", "2025-01-27"}},
+		{"FREQ=MONTHLY;INTERVAL=2", "2025-01-31", "2025-01-01", "2025-07-31",
+			[]string{"2025-01-31", "2025-03-31", "2025-05-31", "2025-07-31"}},
+		{"FREQ=YEARLY;INTERVAL=2", "2024-06-15", "2024-01-01", "2029-12-31",
+			[]string{"2024-06-15", "2026-06-15", "2028-06-15"}},
+
+		// Ordinal BYDAY
+		{"FREQ=MONTHLY;BYDAY=-1FR", "2025-01-01", "2025-01-01", "2025-04-30",
+			[]string{"2025-01-31", "2025-02-28", "2025-03-28", "2025-04-25"}},
+		{"FREQ=MONTHLY;BYDAY=2TU", "2025-01-01", "2025-01-01", "2025-03-31",
+			[]string{"2025-01-14", "2025-02-11", "2025-03-11"}},
+		{"FREQ=MONTHLY;BYDAY=1MO,-2MO", "2025-03-01", "2025-03-01", "2025-03-31",
+			[]string{"2025-03-03", "2025-03-24"}},
+		// A fifth weekday only exists in some months
+		{"FREQ=MONTHLY;BYDAY=5SA", "2025-01-01", "2025-01-01", "2025-06-30",
+			[]string{"2025-03-29", "2025-05-31"}},
+		{"FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", "2024-01-01", "2024-01-01", "2025-12-31",
+			[]string{"2024-11-28", "2025-11-27"}},
+
+		// Negative BYMONTHDAY
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", "2024-01-15", "2024-01-01", "2024-04-30",
+			[]string{"2024-01-31", "2024-02-29", "2024-03-31", "2024-04-30"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=-3", "2025-02-01", "2025-02-01", "2025-03-31",
+			[]string{"2025-02-26", "2025-03-29"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1", "2025-02-01", "2025-02-01", "2025-03-31",
+			[]string{"2025-02-01", "2025-02-28", "2025-03-01", "2025-03-31"}},
+		// Months without the day are skipped
+		{"FREQ=MONTHLY;BYMONTHDAY=30", "2025-01-01", "2025-01-01", "2025-03-31",
+			[]string{"2025-01-30", "2025-03-30"}},
+		{"FREQ=YEARLY;BYMONTH=3,9;BYMONTHDAY=1", "2025-01-01", "2025-01-01", "2025-12-31",
+			[]string{"2025-03-01", "2025-09-01"}},
+		{"FREQ=YEARLY", "2024-02-29", "2024-01-01", "2029-12-31",
+			[]string{"2024-02-29", "2028-02-29"}},
+
+		// COUNT counts the occurrences from the start, also the ones before from
+		{"FREQ=DAILY;COUNT=3", "2025-01-01", "2025-01-01", "2025-01-10",
+			[]string{"2025-01-01", "2025-01-02", "2025-01-03"}},
+		{"FREQ=DAILY;COUNT=3", "2025-01-01", "2025-01-02", "2025-01-10",
+			[]string{"2025-01-02", "2025-01-03"}},
+		{"FREQ=DAILY;COUNT=3", "2025-01-01", "2025-01-05", "2025-01-10", nil},
+		{"FREQ=MONTHLY;BYDAY=-1FR;COUNT=2", "2025-01-01", "2025-01-01", "2025-12-31",
+			[]string{"2025-01-31", "2025-02-28"}},
+		// UNTIL is inclusive
+		{"FREQ=WEEKLY;UNTIL=20250115", "2025-01-01", "2025-01-01", "2025-01-31",
+			[]string{"2025-01-01", "2025-01-08", "2025-01-15"}},
+		{"FREQ=DAILY;INTERVAL=4;UNTIL=20250110T120000Z", "2025-01-01", "2025-01-01", "2025-01-31",
+			[]string{"2025-01-01", "2025-01-05", "2025-01-09"}},
+		{"FREQ=DAILY;UNTIL=20241231T000000Z", "2025-01-01", "2025-01-01", "2025-01-31", nil},
+
+		// Nothing before the start or after to
+		{"FREQ=DAILY", "2025-01-05", "2025-01-01", "2025-01-06",
+			[]string{"2025-01-05", "2025-01-06"}},
+		{"FREQ=DAILY", "2025-01-05", "2025-01-06", "2025-01-05", nil},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.rule)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.rule, err)
+			continue
+		}
+		got := r.Between(date(tt.start), date(tt.from), date(tt.to))
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if s := formatDates(got); !reflect.DeepEqual(s, tt.want) {
+			t.Errorf("%q from %s: Between(%s, %s) = %v, want %v", tt.rule, tt.start, tt.from, tt.to, s, tt.want)
+		}
+	}
+}
+
+func TestBetweenDST(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("time.LoadLocation: %v", err)
+	}
+	tests := []struct {
+		rule  string
+		start time.Time
+		from  time.Time
+		to    time.Time
+		want  []string
+	}{
+		// The clocks go forward on 2025-03-30 and back on 2025-10-26, a day is 23 or 25 hours
+		{"FREQ=DAILY", time.Date(2025, 3, 29, 0, 0, 0, 0, loc), time.Date(2025, 3, 29, 0, 0, 0, 0, loc), time.Date(2025, 4, 1, 0, 0, 0, 0, loc),
+			[]string{"2025-03-29", "2025-03-30", "2025-03-31", "2025-04-01"}},
+		{"FREQ=DAILY;INTERVAL=2", time.Date(2025, 10, 24, 9, 0, 0, 0, loc), time.Date(2025, 10, 24, 0, 0, 0, 0, loc), time.Date(2025, 10, 31, 0, 0, 0, 0, loc),
+			[]string{"2025-10-24", "2025-10-26", "2025-10-28", "2025-10-30"}},
+		{"FREQ=WEEKLY", time.Date(2025, 3, 24, 0, 0, 0, 0, loc), time.Date(2025, 3, 1, 0, 0, 0, 0, loc), time.Date(2025, 4, 14, 0, 0, 0, 0, loc),
+			[]string{"2025-03-24", "2025-03-31", "2025-04-07", "2025-04-14"}},
+		{"FREQ=MONTHLY;BYDAY=-1SU", time.Date(2025, 1, 1, 0, 0, 0, 0, loc), time.Date(2025, 1, 1, 0, 0, 0, 0, loc), time.Date(2025, 12, 31, 0, 0, 0, 0, loc),
+			[]string{"2025-01-26", "2025-02-23", "2025-03-30", "2025-04-27", "2025-05-25", "2025-06-29",
+				"2025-07-27", "2025-08-31", "2025-09-28", "2025-10-26", "2025-11-30", "2025-12-28"}},
+		// The arguments are taken as dates in the location of start, whatever their own location
+		{"FREQ=DAILY", time.Date(2025, 3, 30, 0, 0, 0, 0, loc), time.Date(2025, 3, 29, 23, 30, 0, 0, time.UTC), time.Date(2025, 3, 30, 22, 30, 0, 0, time.UTC),
+			[]string{"2025-03-30", "2025-03-31"}},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.rule)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.rule, err)
+			continue
+		}
+		got := r.Between(tt.start, tt.from, tt.to)
+		if s := formatDates(got); !reflect.DeepEqual(s, tt.want) {
+			t.Errorf("%q from %v: Between() = %v, want %v", tt.rule, tt.start, s, tt.want)
+		}
+		// Each occurrence is at midnight in the location of start
+		for _, d := range got {
+			if d.Location() != loc || d.Hour() != 0 || d.Minute() != 0 {
+				t.Errorf("%q: occurrence %v is not at midnight in %v", tt.rule, d, loc)
+			}
+		}
+	}
+}
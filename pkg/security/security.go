@@ -7,53 +7,93 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"strings"
+	"sync"
 
 	"go.uber.org/zap"
 )
 
+// RSA key the clients encrypt passwords with
 type Rsa struct {
-	privateKey    string
+	id            string
 	publicKey     string
 	rsaPrivateKey *rsa.PrivateKey
-	rsaPublicKey  *rsa.PublicKey
 }
 
-var ScRsa *Rsa
-
-// Initialize RSA
-func NewRsa(publicKey, privateKey string) *Rsa {
-	ScRsa = &Rsa{
-		privateKey: privateKey,
-		publicKey:  publicKey,
-	}
-	ScRsa.init()
-	return ScRsa
+// Public key given to the clients
+type RsaPublicKey struct {
+	KeyID     string `json:"keyId"`
+	PublicKey string `json:"publicKey"`
 }
 
-func (thisRsa *Rsa) init() {
-	if thisRsa.privateKey != "" {
-		block, _ := pem.Decode([]byte(thisRsa.privateKey))
-		//pkcs1
-		if strings.Index(thisRsa.privateKey, "BEGIN RSA") > 0 {
-			thisRsa.rsaPrivateKey, _ = x509.ParsePKCS1PrivateKey(block.Bytes)
-		} else { //pkcs8
-			privateKey, _ := x509.ParsePKCS8PrivateKey(block.Bytes)
-			thisRsa.rsaPrivateKey = privateKey.(*rsa.PrivateKey)
+var (
+	rsaMutex sync.RWMutex
+	// Keys accepted when decrypting, by key ID
+	rsaKeys = make(map[string]*Rsa)
+	// Key given to the clients
+	activeRsa *Rsa
+)
+
+// Parse the PEM private key (PKCS#1 or PKCS#8), the public key is derived from it
+func NewRsa(keyID string, privateKey string) (r *Rsa, err error) {
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil {
+		return nil, fmt.Errorf("rsa: key %q: no PEM data", keyID)
+	}
+	r = &Rsa{id: keyID}
+	if strings.Contains(privateKey, "BEGIN RSA") {
+		r.rsaPrivateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		var key interface{}
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err == nil {
+			var ok bool
+			if r.rsaPrivateKey, ok = key.(*rsa.PrivateKey); !ok {
+				err = errors.New("not an RSA private key")
+			}
 		}
 	}
+	if err != nil {
+		return nil, fmt.Errorf("rsa: key %q: %w", keyID, err)
+	}
+	derPkix, err := x509.MarshalPKIXPublicKey(&r.rsaPrivateKey.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("rsa: key %q: %w", keyID, err)
+	}
+	r.publicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: derPkix}))
+	return
+}
 
-	if thisRsa.publicKey != "" {
-		block, _ := pem.Decode([]byte(thisRsa.publicKey))
-		publickKey, _ := x509.ParsePKIXPublicKey(block.Bytes)
-		thisRsa.rsaPublicKey = publickKey.(*rsa.PublicKey)
+// Replace the keys accepted when decrypting.
+// The active key must be one of the keys, it is given to the clients.
+func SetRsaKeys(keys []*Rsa, activeKeyID string) (err error) {
+	m := make(map[string]*Rsa, len(keys))
+	for _, k := range keys {
+		m[k.id] = k
+	}
+	active, ok := m[activeKeyID]
+	if !ok {
+		return fmt.Errorf("rsa: active key %q not found", activeKeyID)
 	}
-	zap.L().Info("RSA component initialized successfully.")
+	rsaMutex.Lock()
+	defer rsaMutex.Unlock()
+	if activeRsa == nil || activeRsa.id != activeKeyID || len(rsaKeys) != len(m) {
+		zap.L().Info("RSA keys loaded.", zap.String("activeKid", activeKeyID), zap.Int("keys", len(m)))
+	}
+	rsaKeys = m
+	activeRsa = active
+	return
 }
 
-// Publish Public Key
-func (thisRsa *Rsa) GetPublicKey() string {
-	return thisRsa.publicKey
+// Publish the public key of the active key
+func GetPublicKey() (pk RsaPublicKey) {
+	rsaMutex.RLock()
+	defer rsaMutex.RUnlock()
+	if activeRsa != nil {
+		pk = RsaPublicKey{KeyID: activeRsa.id, PublicKey: activeRsa.publicKey}
+	}
+	return
 }
 
 // Generate RSA Private and Public Keys
@@ -86,9 +126,41 @@ func GenRsaKey(bits int) (private string, public string, err error) {
 	return
 }
 
-// Decrypt
-func (thisRsa *Rsa) Decrypt(secretData []byte) ([]byte, error) {
-	blockLength := thisRsa.rsaPublicKey.N.BitLen() / 8
+// Decrypt the data encrypted with the public key of the key ID.
+// Clients that don't send the key ID are served by the active key first, then by the other accepted keys.
+func Decrypt(keyID string, secretData []byte) ([]byte, error) {
+	rsaMutex.RLock()
+	active := activeRsa
+	keys := rsaKeys
+	rsaMutex.RUnlock()
+	if keyID != "" {
+		k, ok := keys[keyID]
+		if !ok {
+			return nil, fmt.Errorf("rsa: unknown key %q", keyID)
+		}
+		return k.decrypt(secretData)
+	}
+	if active == nil {
+		return nil, errors.New("rsa: no key loaded")
+	}
+	data, err := active.decrypt(secretData)
+	if err == nil {
+		return data, nil
+	}
+	for id, k := range keys {
+		if id == active.id {
+			continue
+		}
+		if data, errOther := k.decrypt(secretData); errOther == nil {
+			return data, nil
+		}
+	}
+	return nil, err
+}
+
+// Decrypt with the key
+func (thisRsa *Rsa) decrypt(secretData []byte) ([]byte, error) {
+	blockLength := thisRsa.rsaPrivateKey.N.BitLen() / 8
 	if len(secretData) <= blockLength {
 		return rsa.DecryptPKCS1v15(rand.Reader, thisRsa.rsaPrivateKey, secretData)
 	}
@@ -0,0 +1,139 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sccsmsserver/setting"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// Keys accepted when decrypting secrets, by key ID
+	secretKeys = make(map[string]cipher.AEAD)
+	// Key ID of the key that encrypts new secrets
+	activeKeyID string
+)
+
+var (
+	ErrNoKey         = errors.New("totp: no secret encryption key configured")
+	ErrUnknownKey    = errors.New("totp: unknown secret encryption key")
+	ErrInvalidSealed = errors.New("totp: invalid encrypted secret")
+)
+
+// Initialize the secret encryption keys.
+// Without configured keys the stored secrets can't be read and TOTP can't be set up.
+func Init(cfg *setting.TOTPConfig) (err error) {
+	secretKeys = make(map[string]cipher.AEAD)
+	activeKeyID = ""
+	if cfg == nil || len(cfg.Keys) == 0 {
+		zap.L().Warn("No TOTP secret encryption key configured, TOTP two-factor authentication is unavailable.")
+		return
+	}
+	for _, kc := range cfg.Keys {
+		if kc.ID == "" || strings.Contains(kc.ID, ":") {
+			return fmt.Errorf("totp: invalid kid %q", kc.ID)
+		}
+		if _, ok := secretKeys[kc.ID]; ok {
+			return fmt.Errorf("totp: duplicate kid %q", kc.ID)
+		}
+		aead, err := loadKey(kc)
+		if err != nil {
+			return fmt.Errorf("totp: key %q: %w", kc.ID, err)
+		}
+		secretKeys[kc.ID] = aead
+	}
+	activeKeyID = cfg.ActiveKeyID
+	if activeKeyID == "" && len(cfg.Keys) == 1 {
+		activeKeyID = cfg.Keys[0].ID
+	}
+	if _, ok := secretKeys[activeKeyID]; !ok {
+		return fmt.Errorf("totp: active key %q not found", activeKeyID)
+	}
+	zap.L().Info("TOTP secret encryption keys initialized successfully.", zap.String("kid", activeKeyID))
+	return
+}
+
+// Load a key from the configuration
+func loadKey(kc setting.TOTPKey) (aead cipher.AEAD, err error) {
+	encoded := kc.Key
+	if kc.KeyFile != "" {
+		b, err := os.ReadFile(kc.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		encoded = string(b)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, errors.New("key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Additional data of the user's secret, a secret can't be moved to another user
+func secretAAD(userID int32) []byte {
+	return []byte("sysuser:" + strconv.FormatInt(int64(userID), 10))
+}
+
+// Encrypt the secret of the user with the active key.
+// The result is the key ID and the base64 encoded nonce and ciphertext, separated by a colon.
+func Seal(secret string, userID int32) (string, error) {
+	aead, ok := secretKeys[activeKeyID]
+	if !ok {
+		return "", ErrNoKey
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(secret)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(secret), secretAAD(userID))
+	return activeKeyID + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt the secret of the user.
+// Secrets stored before the encryption have no key ID and are returned as they are,
+// base32 secrets never contain a colon.
+func Open(stored string, userID int32) (string, error) {
+	kid, encoded, found := strings.Cut(stored, ":")
+	if !found {
+		return stored, nil
+	}
+	aead, ok := secretKeys[kid]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrInvalidSealed
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	secret, err := aead.Open(nil, nonce, ciphertext, secretAAD(userID))
+	if err != nil {
+		return "", ErrInvalidSealed
+	}
+	return string(secret), nil
+}
+
+// Check whether the stored secret needs to be encrypted again with the active key
+func NeedsSeal(stored string) bool {
+	if stored == "" || activeKeyID == "" {
+		return false
+	}
+	kid, _, found := strings.Cut(stored, ":")
+	return !found || kid != activeKeyID
+}
@@ -0,0 +1,82 @@
+package totp
+
+import (
+	"encoding/base64"
+	"errors"
+	"sccsmsserver/setting"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func TestSealOpen(t *testing.T) {
+	if err := Init(nil); err != nil {
+		t.Fatalf("Init(nil): %v", err)
+	}
+	if _, err := Seal("JBSWY3DPEHPK3PXP", 1); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Seal() without keys = %v, want %v", err, ErrNoKey)
+	}
+
+	cfg := &setting.TOTPConfig{Keys: []setting.TOTPKey{{ID: "k1", Key: testKey('a')}}}
+	if err := Init(cfg); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	sealed, err := Seal("JBSWY3DPEHPK3PXP", 1)
+	if err != nil || !strings.HasPrefix(sealed, "k1:") || strings.Contains(sealed, "JBSWY3DPEHPK3PXP") {
+		t.Fatalf("Seal() = %q, %v", sealed, err)
+	}
+	if len(sealed) > 256 {
+		t.Errorf("Seal() is %d characters, the column holds 256", len(sealed))
+	}
+	if secret, err := Open(sealed, 1); err != nil || secret != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Open() = %q, %v", secret, err)
+	}
+	// The secret is bound to its user
+	if _, err := Open(sealed, 2); !errors.Is(err, ErrInvalidSealed) {
+		t.Errorf("Open() for another user = %v, want %v", err, ErrInvalidSealed)
+	}
+	// Secrets stored before the encryption are read as they are
+	if secret, err := Open("JBSWY3DPEHPK3PXP", 1); err != nil || secret != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Open() of a plain secret = %q, %v", secret, err)
+	}
+	if !NeedsSeal("JBSWY3DPEHPK3PXP") || NeedsSeal(sealed) || NeedsSeal("") {
+		t.Errorf("NeedsSeal() with k1 active is wrong")
+	}
+
+	// After a rotation the old key still decrypts, the secret needs sealing again
+	cfg = &setting.TOTPConfig{ActiveKeyID: "k2", Keys: []setting.TOTPKey{{ID: "k1", Key: testKey('a')}, {ID: "k2", Key: testKey('b')}}}
+	if err := Init(cfg); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if secret, err := Open(sealed, 1); err != nil || secret != "JBSWY3DPEHPK3PXP" || !NeedsSeal(sealed) {
+		t.Errorf("Open() with a retired key = %q, %v", secret, err)
+	}
+	// A removed key can't decrypt
+	cfg = &setting.TOTPConfig{Keys: []setting.TOTPKey{{ID: "k2", Key: testKey('b')}}}
+	if err := Init(cfg); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if _, err := Open(sealed, 1); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Open() with a removed key = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestInitInvalid(t *testing.T) {
+	tests := []*setting.TOTPConfig{
+		{Keys: []setting.TOTPKey{{ID: "", Key: testKey('a')}}},
+		{Keys: []setting.TOTPKey{{ID: "a:b", Key: testKey('a')}}},
+		{Keys: []setting.TOTPKey{{ID: "k1", Key: "short"}}},
+		{Keys: []setting.TOTPKey{{ID: "k1", Key: base64.StdEncoding.EncodeToString([]byte("16 bytes key....."))}}},
+		{Keys: []setting.TOTPKey{{ID: "k1", Key: testKey('a')}, {ID: "k1", Key: testKey('b')}}},
+		{ActiveKeyID: "k3", Keys: []setting.TOTPKey{{ID: "k1", Key: testKey('a')}, {ID: "k2", Key: testKey('b')}}},
+		{Keys: []setting.TOTPKey{{ID: "k1", Key: testKey('a')}, {ID: "k2", Key: testKey('b')}}},
+	}
+	for i, cfg := range tests {
+		if err := Init(cfg); err == nil {
+			t.Errorf("Init() of config %d succeeded, want an error", i)
+		}
+	}
+}
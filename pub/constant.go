@@ -56,48 +56,72 @@ const APIPath = "/api/v1"
 const DefaultPassword string = "sc@123"
 
 // Database Schema version
-const DbVersion = "1.0.0"
+const DbVersion = "1.19.0"
 
-// Md5 secret
+// Md5 secret, only used to verify legacy passwords stored before the algorithm prefix
 var Md5Secret = []byte("Sea&Cloud comes from a character in both my wife's and my names.")
 
-// JWT secret
-var TokenSecret = []byte("This is JWT secret")
-
 // Master Data Type
 const (
-	User       DataType = "user"       // User Profile
-	Person     DataType = "person"     // Simple User Profile
-	Department DataType = "department" // Department Profile
-	SimpDept   DataType = "simpdept"   // Simple Department Profile
-	File       DataType = "file"       // File metadata
-	FileHash   DataType = "filehash"   // File metadata by hash
-	CSC        DataType = "csc"        // Construction site Category
-	SimpCSC    DataType = "simpcsc"    // Simple Construction site Category
-	CSA        DataType = "csa"        // Construction Site Archive
-	UDC        DataType = "udc"        // User-defined Data Category
-	UDA        DataType = "uda"        // User-defined Archive Master Data
-	EPC        DataType = "epc"        // Execution Project Category
-	SimpEPC    DataType = "simpepc"    // Simple Execution Project Category
-	EPA        DataType = "epa"        // Execution Project Archive
-	EPT        DataType = "ept"        // Execution Project Template
-	EPTHead    DataType = "epthead"    // Execution Project Template Header
-	EPTBody    DataType = "eptbody"    // Execution Project Template Body
-	CFCS       DataType = "cfcs"       // Custom Fields for Construction Site
-	ACFCS      DataType = "acfcs"      // All custom fileds for Construction Site
-	RL         DataType = "rl"         // Risk Level Master Data
-	DC         DataType = "dc"         // Document Category
-	SimpDC     DataType = "simpdc"     // Simple Document Category
-	Document   DataType = "document"   // Document Archive
-	Position   DataType = "position"   // Position Master Data
-	TC         DataType = "tc"         // Training Course Master Data
-	PPE        DataType = "ppe"        // Personal Protective Equipment
-	IPBlack    DataType = "ipblack"    // IP Address Blacklist
+	User          DataType = "user"          // User Profile
+	Person        DataType = "person"        // Simple User Profile
+	Department    DataType = "department"    // Department Profile
+	SimpDept      DataType = "simpdept"      // Simple Department Profile
+	File          DataType = "file"          // File metadata
+	FileHash      DataType = "filehash"      // File metadata by hash
+	CSC           DataType = "csc"           // Construction site Category
+	SimpCSC       DataType = "simpcsc"       // Simple Construction site Category
+	CSA           DataType = "csa"           // Construction Site Archive
+	UDC           DataType = "udc"           // User-defined Data Category
+	UDA           DataType = "uda"           // User-defined Archive Master Data
+	EPC           DataType = "epc"           // Execution Project Category
+	SimpEPC       DataType = "simpepc"       // Simple Execution Project Category
+	EPA           DataType = "epa"           // Execution Project Archive
+	EPT           DataType = "ept"           // Execution Project Template
+	EPTHead       DataType = "epthead"       // Execution Project Template Header
+	EPTBody       DataType = "eptbody"       // Execution Project Template Body
+	CFCS          DataType = "cfcs"          // Custom Fields for Construction Site
+	ACFCS         DataType = "acfcs"         // All custom fileds for Construction Site
+	RL            DataType = "rl"            // Risk Level Master Data
+	DC            DataType = "dc"            // Document Category
+	SimpDC        DataType = "simpdc"        // Simple Document Category
+	Document      DataType = "document"      // Document Archive
+	Position      DataType = "position"      // Position Master Data
+	TC            DataType = "tc"            // Training Course Master Data
+	PPE           DataType = "ppe"           // Personal Protective Equipment
+	IPBlack       DataType = "ipblack"       // IP Address Blacklist
+	UserPerm      DataType = "userperm"      // User API permissions
+	UserSessions  DataType = "usersessions"  // Session IDs of a user
+	SessionsLock  DataType = "sessionslock"  // Lock of the session IDs of a user
+	OnlineSession DataType = "onlinesession" // Online user session
+	PreAuth       DataType = "preauth"       // Login waiting for the second factor
+	OIDCState     DataType = "oidcstate"     // Pending OpenID Connect authorization request
+	IPAccess      DataType = "ipaccess"      // IP address allow and deny list
+	RateLimit     DataType = "ratelimit"     // API rate limit token bucket
+	APIKey        DataType = "apikey"        // API key of a service user
+	APIKeyPerm    DataType = "apikeyperm"    // API key permissions
+	ApprovalFlow  DataType = "approvalflow"  // Voucher approval flow
+	WOSchedule    DataType = "woschedule"    // Work Order schedule
+	Webhook       DataType = "webhook"       // Webhook subscription
+	CSO           DataType = "cso"           // Construction Site Option
+	Role          DataType = "role"          // Role
+	WO            DataType = "wo"            // Work Order
+	EO            DataType = "eo"            // Execution Order
+	IRF           DataType = "irf"           // Issue Resolution Form
+	TR            DataType = "tr"            // Training Record
+	PQ            DataType = "pq"            // Personal Protective Equipment Quota
+	PPEIF         DataType = "ppeif"         // Personal Protective Equipment Issuance Form
 )
 
 // Valid values for the "clientType" request header
 var ValidClientTypes = [2]string{"sceneweb", "scenemob"}
 
+// Client type of the requests authenticated with an API key
+const APIKeyClientType = "apikey"
+
+// Request header carrying the API key of a service user
+const APIKeyHeader = "X-API-Key"
+
 // Minio File URL expiration time
 const FileURLExpireTime = 24 * time.Hour
 
@@ -107,6 +131,27 @@ const CacheExpiration = 2 * time.Hour
 // Token Expire Duration
 const TokenExpireDuration = 2 * time.Hour
 
+// Refresh Token idle expire duration, extended each time the refresh token is used
+const RefreshTokenExpireDuration = 7 * 24 * time.Hour
+
+// Refresh Token session lifetime, the user must log in again after it
+const RefreshTokenSessionDuration = 30 * 24 * time.Hour
+
+// Pre-auth token expire duration, the second login step must be completed within it
+const PreAuthExpireDuration = 5 * time.Minute
+
+// OpenID Connect authorization request expire duration, the user must sign in at the IdP within it
+const OIDCStateExpireDuration = 10 * time.Minute
+
+// Minimum interval between two writes of the session last seen time
+const SessionTouchInterval = time.Minute
+
+// Expire duration of the lock of the user sessions, the lock is released when the server holding it stops
+const SessionLockExpireDuration = 10 * time.Second
+
+// Maximum wait for the lock of the user sessions
+const SessionLockWait = 5 * time.Second
+
 // Token Issuer
 const TokenIssuer = "SeaCloud"
 
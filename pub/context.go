@@ -4,5 +4,9 @@ const (
 	CTXUserID     = "userID"
 	CTXUserCode   = "userCode"
 	CTXTokenID    = "tokenID"
+	CTXSessionID  = "sessionID"
 	CTXClientType = "clientType"
+	CTXAPIKeyID   = "apiKeyID"
+	// Set by the permission middleware, API keys act as the service user only on these routes
+	CTXPermissionChecked = "permissionChecked"
 )
@@ -0,0 +1,23 @@
+package route
+
+import (
+	"sccsmsserver/db/pg"
+	"sccsmsserver/handlers"
+	"sccsmsserver/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func APIKeyRoute(g *gin.RouterGroup) {
+	APIKeyGroup := g.Group("/apikey", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
+	{
+		// Get API key list
+		APIKeyGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDAK, pg.ActionView), handlers.GetAPIKeysHandler)
+		// Add API key
+		APIKeyGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDAK, pg.ActionAdd), handlers.AddAPIKeyHandler)
+		// Modify API key
+		APIKeyGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDAK, pg.ActionEdit), handlers.EditAPIKeyHandler)
+		// Delete API key
+		APIKeyGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDAK, pg.ActionDelete), handlers.DeleteAPIKeyHandler)
+	}
+}
@@ -0,0 +1,34 @@
+package route
+
+import (
+	"sccsmsserver/db/pg"
+	"sccsmsserver/handlers"
+	"sccsmsserver/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ApprovalRoute(g *gin.RouterGroup) {
+	approvalGroup := g.Group("/approval", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
+	{
+		// Get approval flow list
+		approvalGroup.POST("/flow/list", middleware.PermissionMiddleware(pg.MenuIDAF, pg.ActionView), handlers.GetApprovalFlowsHandler)
+		// Add approval flow
+		approvalGroup.POST("/flow/add", middleware.PermissionMiddleware(pg.MenuIDAF, pg.ActionAdd), handlers.AddApprovalFlowHandler)
+		// Modify approval flow
+		approvalGroup.POST("/flow/edit", middleware.PermissionMiddleware(pg.MenuIDAF, pg.ActionEdit), handlers.EditApprovalFlowHandler)
+		// Delete approval flow
+		approvalGroup.POST("/flow/del", middleware.PermissionMiddleware(pg.MenuIDAF, pg.ActionDelete), handlers.DeleteApprovalFlowHandler)
+		// The approvers of the current step are checked by the approval flow
+		// Approve voucher
+		approvalGroup.POST("/approve", handlers.ApproveVoucherHandler)
+		// Reject voucher
+		approvalGroup.POST("/reject", handlers.RejectVoucherHandler)
+		// Return voucher
+		approvalGroup.POST("/return", handlers.ReturnVoucherHandler)
+		// Get the approval history of a voucher, checked against the view permission and data scope of the voucher
+		approvalGroup.POST("/history", handlers.GetVoucherApprovalsHandler)
+		// Get the approvals waiting for the operator
+		approvalGroup.POST("/pending", handlers.GetPendingApprovalsHandler)
+	}
+}
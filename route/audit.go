@@ -0,0 +1,17 @@
+package route
+
+import (
+	"sccsmsserver/db/pg"
+	"sccsmsserver/handlers"
+	"sccsmsserver/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AuditRoute(g *gin.RouterGroup) {
+	auditGroup := g.Group("/audit", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
+	{
+		// Get audit log list by pagination
+		auditGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDAT, pg.ActionView), handlers.GetAuditLogsPaginationHandler)
+	}
+}
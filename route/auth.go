@@ -10,12 +10,30 @@ import (
 func AuthRoute(g *gin.RouterGroup) {
 	authGroup := g.Group("/auth", middleware.CheckClientTypeMiddleware())
 	{
-		//Rsa public key
+		// Rsa public key with its key ID
 		authGroup.POST("/publickey", handlers.GetPublicKeyHandler)
 		// Validate token
 		authGroup.POST("/validatetoken", middleware.JWTAuthMiddleware(), handlers.ValidateToken)
 		// User Login
 		authGroup.POST("/login", handlers.LoginHandler)
+		// Second login step, verify the TOTP code or a recovery code
+		authGroup.POST("/totp/verify", handlers.VerifyTOTPLoginHandler)
+		// Generate TOTP secret during login
+		authGroup.POST("/totp/setup", handlers.PreAuthSetupTOTPHandler)
+		// Enable TOTP during login
+		authGroup.POST("/totp/enable", handlers.PreAuthEnableTOTPHandler)
+		// Change the initial or expired password during login
+		authGroup.POST("/pwdchange", handlers.ChangeExpiredPasswordHandler)
+		// Password policy
+		authGroup.POST("/pwdpolicy", handlers.GetPasswordPolicyHandler)
+		// Single sign-on options of the login page
+		authGroup.POST("/oidc/options", handlers.GetSSOOptionsHandler)
+		// Start an OpenID Connect login
+		authGroup.POST("/oidc/login", handlers.OIDCLoginHandler)
+		// Complete an OpenID Connect login
+		authGroup.POST("/oidc/callback", handlers.OIDCCallbackHandler)
+		// Exchange a refresh token for a new token pair
+		authGroup.POST("/refresh", handlers.RefreshTokenHandler)
 		// Change user password
 		authGroup.POST("/changepwd", middleware.JWTAuthMiddleware(), handlers.ChangeUserPasswordHandler)
 		// Logout
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,17 +12,17 @@ func CSARoute(g *gin.RouterGroup) {
 	CSAGroup := g.Group("/csa", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Add CSA
-		CSAGroup.POST("/add", handlers.AddCSHandler)
+		CSAGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDCSA, pg.ActionAdd), handlers.AddCSHandler)
 		// Modify CSA
-		CSAGroup.POST("/edit", handlers.EditCSHandler)
+		CSAGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDCSA, pg.ActionEdit), handlers.EditCSHandler)
 		// Check if the CSA code exists
 		CSAGroup.POST("/checkcode", handlers.CheckCSCodeExistHandler)
 		// Delete CSA
-		CSAGroup.POST("/del", handlers.DeleteCSHandler)
+		CSAGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDCSA, pg.ActionDelete), handlers.DeleteCSHandler)
 		// Batch delete CSA
-		CSAGroup.POST("/dels", handlers.DeleteCSsHandler)
+		CSAGroup.POST("/dels", middleware.PermissionMiddleware(pg.MenuIDCSA, pg.ActionDelete), handlers.DeleteCSsHandler)
 		// Get CSA list
-		CSAGroup.POST("/list", handlers.GetCSsHandler)
+		CSAGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDCSA, pg.ActionView), handlers.GetCSsHandler)
 		// Get CSA front-end cache
 		CSAGroup.POST("/cache", handlers.GetCSCacheHandler)
 	}
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,7 +12,7 @@ func CSCRoute(g *gin.RouterGroup) {
 	CSCGroup := g.Group("/csc", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Get CSC list
-		CSCGroup.POST("/list", handlers.GetCSCListHandler)
+		CSCGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDCSC, pg.ActionView), handlers.GetCSCListHandler)
 		// Get Simple CSC list
 		CSCGroup.POST("/simplist", handlers.GetSimpCSCListHandler)
 		// Get front-end cache
@@ -19,12 +20,12 @@ func CSCRoute(g *gin.RouterGroup) {
 		// Check if the csc name exists
 		CSCGroup.POST("/checkname", handlers.CheckCSCNameExistHandler)
 		// Add CSC
-		CSCGroup.POST("/add", handlers.AddCSCHandler)
+		CSCGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDCSC, pg.ActionAdd), handlers.AddCSCHandler)
 		// Edit CSC
-		CSCGroup.POST("/edit", handlers.EditCSCHandler)
+		CSCGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDCSC, pg.ActionEdit), handlers.EditCSCHandler)
 		// Delete CSC
-		CSCGroup.POST("/del", handlers.DeleteCSCHandler)
+		CSCGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDCSC, pg.ActionDelete), handlers.DeleteCSCHandler)
 		// Batch delete CSC
-		CSCGroup.POST("/dels", handlers.DeleteCSCsHandler)
+		CSCGroup.POST("/dels", middleware.PermissionMiddleware(pg.MenuIDCSC, pg.ActionDelete), handlers.DeleteCSCsHandler)
 	}
 }
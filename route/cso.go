@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -13,7 +14,7 @@ func CSORoute(g *gin.RouterGroup) {
 		// Get CSO list
 		CSOGroup.POST("/options", handlers.GetCSOsHandler)
 		// Modify CSO
-		CSOGroup.POST("/edit", handlers.EditCSOHandler)
+		CSOGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDCSO, pg.ActionEdit), handlers.EditCSOHandler)
 		// Get CSO front-end cache
 		CSOGroup.POST("/cache", handlers.GetCSOCacheHandler)
 	}
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,8 +12,8 @@ func DashboardRoute(g *gin.RouterGroup) {
 	DashboardGroup := g.Group("/da", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Get Dashboard data
-		DashboardGroup.POST("/data", handlers.GetDashboardDataHandler)
+		DashboardGroup.POST("/data", middleware.PermissionMiddleware(pg.MenuIDDashboard, pg.ActionView), handlers.GetDashboardDataHandler)
 		// Get Risk Trends data
-		DashboardGroup.POST("/risktrend", handlers.GetRiskTrendDataHandler)
+		DashboardGroup.POST("/risktrend", middleware.PermissionMiddleware(pg.MenuIDDashboard, pg.ActionView), handlers.GetRiskTrendDataHandler)
 	}
 }
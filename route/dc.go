@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,7 +12,7 @@ func DCRoute(g *gin.RouterGroup) {
 	DCGroup := g.Group("/dc", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Get Document Categories List
-		DCGroup.POST("/list", handlers.GetDCListHandler)
+		DCGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDDC, pg.ActionView), handlers.GetDCListHandler)
 		// Get Simple Document Categories List
 		DCGroup.POST("/simplist", handlers.GetSimpDCListHandler)
 		// Get Simple Document Categories front-end Cache
@@ -19,12 +20,12 @@ func DCRoute(g *gin.RouterGroup) {
 		// Check Document Category Name Exist
 		DCGroup.POST("/checkname", handlers.CheckDCNameExistHandler)
 		// Add Document Category
-		DCGroup.POST("/add", handlers.AddDCHandler)
+		DCGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDDC, pg.ActionAdd), handlers.AddDCHandler)
 		// Edit Document Category
-		DCGroup.POST("/edit", handlers.EditDCHandler)
+		DCGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDDC, pg.ActionEdit), handlers.EditDCHandler)
 		// Delete Document Category
-		DCGroup.POST("/del", handlers.DeleteDCHandler)
+		DCGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDDC, pg.ActionDelete), handlers.DeleteDCHandler)
 		// Delete Multiple Document Categories
-		DCGroup.POST("/dels", handlers.DeleteDCsHandler)
+		DCGroup.POST("/dels", middleware.PermissionMiddleware(pg.MenuIDDC, pg.ActionDelete), handlers.DeleteDCsHandler)
 	}
 }
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,20 +12,20 @@ func DeptRoute(g *gin.RouterGroup) {
 	deptGroup := g.Group("/dept", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Get department list
-		deptGroup.POST("/list", handlers.GetDeptsHandler)
+		deptGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDDepartment, pg.ActionView), handlers.GetDeptsHandler)
 		// Get simplify department list
 		deptGroup.POST("/simplist", handlers.GetSimpDeptsHandler)
 		// Check if the department code eists
 		deptGroup.POST("/checkcode", handlers.CheckDeptCodeExistHandler)
 		// Add department
-		deptGroup.POST("/add", handlers.AddDeptHandler)
+		deptGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDDepartment, pg.ActionAdd), handlers.AddDeptHandler)
 		//  Get Simple Department latest front-end cache
 		deptGroup.POST("/simpcache", handlers.GetSimpDeptsCacheHandler)
 		// Modify department
-		deptGroup.POST("/edit", handlers.EditDeptHandler)
+		deptGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDDepartment, pg.ActionEdit), handlers.EditDeptHandler)
 		// Delete department
-		deptGroup.POST("/del", handlers.DelDeptHandler)
+		deptGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDDepartment, pg.ActionDelete), handlers.DelDeptHandler)
 		// Batch department
-		deptGroup.POST("/dels", handlers.DelDeptsHandler)
+		deptGroup.POST("/dels", middleware.PermissionMiddleware(pg.MenuIDDepartment, pg.ActionDelete), handlers.DelDeptsHandler)
 	}
 }
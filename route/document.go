@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -12,16 +13,16 @@ func DocRoute(g *gin.RouterGroup) {
 	DocGroup := g.Group("/doc", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Add Document
-		DocGroup.POST("/add", handlers.AddDocumentHandler)
+		DocGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDDocumentUpload, pg.ActionAdd), handlers.AddDocumentHandler)
 		// Modify Document
-		DocGroup.POST("/edit", handlers.EditDocumentHandler)
+		DocGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDDocumentUpload, pg.ActionEdit), handlers.EditDocumentHandler)
 		// Get Document list pagination
-		DocGroup.POST("/list", handlers.GetDocumentPagingListHanlder)
+		DocGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDDocumentUpload, pg.ActionView), handlers.GetDocumentPagingListHanlder)
 		// Delete Document
-		DocGroup.POST("/del", handlers.DeleteDocumentHandler)
+		DocGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDDocumentUpload, pg.ActionDelete), handlers.DeleteDocumentHandler)
 		// Batch Delte Document
-		DocGroup.POST("/dels", handlers.DeleteDocumentsHandler)
+		DocGroup.POST("/dels", middleware.PermissionMiddleware(pg.MenuIDDocumentUpload, pg.ActionDelete), handlers.DeleteDocumentsHandler)
 		// Get Document Report
-		DocGroup.POST("/rep", handlers.GetDocumentReportHandler)
+		DocGroup.POST("/rep", middleware.PermissionMiddleware(pg.MenuIDDocumentFind, pg.ActionView), handlers.GetDocumentReportHandler)
 	}
 }
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,17 +12,17 @@ func EPARoute(g *gin.RouterGroup) {
 	EPAGroup := g.Group("/epa", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Get EP list
-		EPAGroup.POST("/list", handlers.GetEPListHandler)
+		EPAGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDEP, pg.ActionView), handlers.GetEPListHandler)
 		// Get latest front-end cache
 		EPAGroup.POST("/cache", handlers.GetEPCacheHandler)
 		// Add EP
-		EPAGroup.POST("/add", handlers.AddEPHandler)
+		EPAGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDEP, pg.ActionAdd), handlers.AddEPHandler)
 		// Modify EP
-		EPAGroup.POST("/edit", handlers.EditEPHandler)
+		EPAGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDEP, pg.ActionEdit), handlers.EditEPHandler)
 		// Delete EP
-		EPAGroup.POST("/del", handlers.DeleteEPHandler)
+		EPAGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDEP, pg.ActionDelete), handlers.DeleteEPHandler)
 		// Batche Delete EP
-		EPAGroup.POST("/dels", handlers.DeleteEPsHandler)
+		EPAGroup.POST("/dels", middleware.PermissionMiddleware(pg.MenuIDEP, pg.ActionDelete), handlers.DeleteEPsHandler)
 		// Check if the EP's code exists
 		EPAGroup.POST("/checkcode", handlers.CheckEPCodeExistHandler)
 	}
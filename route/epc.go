@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,7 +12,7 @@ func EPCRoute(g *gin.RouterGroup) {
 	EPCGroup := g.Group("/epc", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Get EPC list
-		EPCGroup.POST("/list", handlers.GetEPCListHandler)
+		EPCGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDEPC, pg.ActionView), handlers.GetEPCListHandler)
 		// Get SimpEPC list
 		EPCGroup.POST("/simplist", handlers.GetSimpEPCListHandler)
 		// Get SimpEPC front-end cache
@@ -19,12 +20,12 @@ func EPCRoute(g *gin.RouterGroup) {
 		// Check if the EPC name exists
 		EPCGroup.POST("/checkname", handlers.CheckEPCNameExistHandler)
 		// Add EPC
-		EPCGroup.POST("/add", handlers.AddEPCHandler)
+		EPCGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDEPC, pg.ActionAdd), handlers.AddEPCHandler)
 		// Modify EPC
-		EPCGroup.POST("/edit", handlers.EditEPCHandler)
+		EPCGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDEPC, pg.ActionEdit), handlers.EditEPCHandler)
 		// Delete EPC
-		EPCGroup.POST("/del", handlers.DeleteEPCHandler)
+		EPCGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDEPC, pg.ActionDelete), handlers.DeleteEPCHandler)
 		// Batch delete EPC
-		EPCGroup.POST("/dels", handlers.DeleteEPCsHandler)
+		EPCGroup.POST("/dels", middleware.PermissionMiddleware(pg.MenuIDEPC, pg.ActionDelete), handlers.DeleteEPCsHandler)
 	}
 }
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,17 +12,17 @@ func EPTRoute(g *gin.RouterGroup) {
 	EPTGroup := g.Group("/ept", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Get execution project template list
-		EPTGroup.POST("/list", handlers.GetEPTListHandler)
+		EPTGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDEPT, pg.ActionView), handlers.GetEPTListHandler)
 		// Get latest Execution Project Template for fontend cache
 		EPTGroup.POST("/cache", handlers.GetEPTCacheHandler)
 		// Add Execution Project Template
-		EPTGroup.POST("/add", handlers.AddEPTHandler)
+		EPTGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDEPT, pg.ActionAdd), handlers.AddEPTHandler)
 		// Edit Execution Project Template
-		EPTGroup.POST("/edit", handlers.EditEPTHandler)
+		EPTGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDEPT, pg.ActionEdit), handlers.EditEPTHandler)
 		// Delete Execution Project Template
-		EPTGroup.POST("/del", handlers.DeleteEPTHandler)
+		EPTGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDEPT, pg.ActionDelete), handlers.DeleteEPTHandler)
 		// Batch delete Execution Project Template
-		EPTGroup.POST("dels", handlers.DeleteEPTsHandler)
+		EPTGroup.POST("dels", middleware.PermissionMiddleware(pg.MenuIDEPT, pg.ActionDelete), handlers.DeleteEPTsHandler)
 		// Check if the execution project template code exists
 		EPTGroup.POST("/checkcode", handlers.CheckEPTCodeExistHandler)
 	}
@@ -1,40 +1,51 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
+// The Execution Orders are also opened from the review menu
+var eoViewers = []pg.MenuPermission{
+	{MenuID: pg.MenuIDEO, Action: pg.ActionView},
+	{MenuID: pg.MenuIDEOReview, Action: pg.ActionView},
+	{MenuID: pg.MenuIDEOStatus, Action: pg.ActionView},
+}
+
 func EORoute(g *gin.RouterGroup) {
 	EOGroup := g.Group("/eo", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Get Execution Order List
-		EOGroup.POST("/list", handlers.GetEOListHandler)
+		EOGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDEO, pg.ActionView), handlers.GetEOListHandler)
 		// Get the list of Execution Orders to be reviewed by pagination
-		EOGroup.POST("/listpage", handlers.GetEOReviewListPaginationHandler)
+		EOGroup.POST("/listpage", middleware.PermissionMiddleware(pg.MenuIDEOReview, pg.ActionView), handlers.GetEOReviewListPaginationHandler)
 		// Add Execution Order
-		EOGroup.POST("/add", handlers.AddEOHandler)
+		EOGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDEO, pg.ActionAdd), handlers.AddEOHandler)
 		// Edit Execution Order
-		EOGroup.POST("/edit", handlers.EditEOHandler)
+		EOGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDEO, pg.ActionEdit), handlers.EditEOHandler)
 		// Delete Execution Order
-		EOGroup.POST("/del", handlers.DeleteEOHandler)
+		EOGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDEO, pg.ActionDelete), handlers.DeleteEOHandler)
 		// Confirm Execution Order
-		EOGroup.POST("/confirm", handlers.ConfirmEOHandler)
+		EOGroup.POST("/confirm", middleware.PermissionMiddleware(pg.MenuIDEO, pg.ActionConfirm), handlers.ConfirmEOHandler)
 		// Un-Confirm Execution Order
-		EOGroup.POST("/unconfirm", handlers.CancelConfirmEOHandler)
+		EOGroup.POST("/unconfirm", middleware.PermissionMiddleware(pg.MenuIDEO, pg.ActionConfirm), handlers.CancelConfirmEOHandler)
 		// Get the Execution Order details
-		EOGroup.POST("/detail", handlers.GetEOInfoByHIDHandler)
+		EOGroup.POST("/detail", middleware.AnyPermissionMiddleware(eoViewers...), handlers.GetEOInfoByHIDHandler)
 		// Get the list of Execution Orders to be referenced
-		EOGroup.POST("/refer", handlers.GetReferEOHandler)
+		EOGroup.POST("/refer", middleware.AnyPermissionMiddleware(
+			pg.MenuPermission{MenuID: pg.MenuIDEO, Action: pg.ActionView},
+			pg.MenuPermission{MenuID: pg.MenuIDIRF, Action: pg.ActionAdd},
+			pg.MenuPermission{MenuID: pg.MenuIDIRF, Action: pg.ActionEdit}), handlers.GetReferEOHandler)
 		// Add Execution Order comment
-		EOGroup.POST("/addcomment", handlers.AddCommentHandler)
+		EOGroup.POST("/addcomment", middleware.AnyPermissionMiddleware(eoViewers...), handlers.AddCommentHandler)
 		// Add Execution Order Review Record
-		EOGroup.POST("/addreview", handlers.AddReviewHandler)
+		EOGroup.POST("/addreview", middleware.PermissionMiddleware(pg.MenuIDEOReview, pg.ActionAdd), handlers.AddReviewHandler)
 		// Get Execution Order Review Records list
-		EOGroup.POST("/reviews", handlers.GetEOReviewsHandler)
+		EOGroup.POST("/reviews", middleware.AnyPermissionMiddleware(eoViewers...), handlers.GetEOReviewsHandler)
 		// Get Execution Order Comments list
-		EOGroup.POST("/comments", handlers.GetEOCommentsHandler)
+		EOGroup.POST("/comments", middleware.AnyPermissionMiddleware(eoViewers...), handlers.GetEOCommentsHandler)
 	}
 }
@@ -1,20 +1,38 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Files are uploaded as the attachments of the vouchers, documents and landing page
+var fileUploaders = []pg.MenuPermission{
+	{MenuID: pg.MenuIDEO, Action: pg.ActionAdd},
+	{MenuID: pg.MenuIDEO, Action: pg.ActionEdit},
+	{MenuID: pg.MenuIDIRF, Action: pg.ActionAdd},
+	{MenuID: pg.MenuIDIRF, Action: pg.ActionEdit},
+	{MenuID: pg.MenuIDDocumentUpload, Action: pg.ActionAdd},
+	{MenuID: pg.MenuIDDocumentUpload, Action: pg.ActionEdit},
+	{MenuID: pg.MenuIDTC, Action: pg.ActionAdd},
+	{MenuID: pg.MenuIDTC, Action: pg.ActionEdit},
+	{MenuID: pg.MenuIDTR, Action: pg.ActionAdd},
+	{MenuID: pg.MenuIDTR, Action: pg.ActionEdit},
+	{MenuID: pg.MenuIDPPEIF, Action: pg.ActionAdd},
+	{MenuID: pg.MenuIDPPEIF, Action: pg.ActionEdit},
+	{MenuID: pg.MenuIDLPS, Action: pg.ActionEdit},
+}
+
 func FileRoute(g *gin.RouterGroup) {
 	FileGroup := g.Group("/file", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Receive client files upload
-		FileGroup.POST("/receive", handlers.RecieveFilesHandler)
+		FileGroup.POST("/receive", middleware.AnyPermissionMiddleware(fileUploaders...), handlers.RecieveFilesHandler)
 		// Get file information by file hash
-		FileGroup.POST("/getfilebyhash", handlers.GetFileInfoByHashHandler)
+		FileGroup.POST("/getfilebyhash", middleware.AnyPermissionMiddleware(fileUploaders...), handlers.GetFileInfoByHashHandler)
 		// Get files information by file hash array
-		FileGroup.POST("/getfilesbyhash", handlers.GetFilesByHashHandler)
+		FileGroup.POST("/getfilesbyhash", middleware.AnyPermissionMiddleware(fileUploaders...), handlers.GetFilesByHashHandler)
 	}
 }
@@ -16,11 +16,29 @@ import (
 	"go.uber.org/zap"
 )
 
-// IP Address Blacklist
+// IP Address Blacklist.
+// The lists fail closed, the request is refused when they can't be read.
 func IpBlackListMiddleWare() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get the client's IP address
 		clientIp := c.ClientIP()
+		// Check the permanent allow and deny lists
+		accessType, err := pg.CheckIPAccess(clientIp)
+		if err != nil {
+			zap.L().Error("IpBlackListMiddleWare pg.CheckIPAccess failed", zap.String("clientIp", clientIp), zap.Error(err))
+			handlers.ResponseWithMsg(c, i18n.StatusInternalError, nil)
+			c.Abort()
+			return
+		}
+		if accessType == pg.IPAccessDeny {
+			handlers.ResponseWithMsg(c, i18n.StatusIPDenied, nil)
+			c.Abort()
+			return
+		}
+		if accessType == pg.IPAccessAllow {
+			c.Next()
+			return
+		}
 		// Check if the IP address is on the blacklist
 		key := fmt.Sprintf("%s%s%s", pub.IPBlack, ":", clientIp)
 		exist, v, err := cache.GetOther(key)
@@ -35,7 +53,7 @@ func IpBlackListMiddleWare() gin.HandlerFunc {
 			var ipLock pg.IpLock
 			err1 := json.Unmarshal(v, &ipLock)
 			if err1 != nil {
-				zap.L().Error("IpBlackListMiddleWare json.Unmarshal failed:", zap.Error(err))
+				zap.L().Error("IpBlackListMiddleWare json.Unmarshal failed:", zap.Error(err1))
 				handlers.ResponseWithMsg(c, i18n.StatusInternalError, nil)
 				c.Abort()
 				return
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,16 +12,16 @@ func IRFRoute(g *gin.RouterGroup) {
 	IRFGroup := g.Group("/irf", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Add Issue Resolution Form
-		IRFGroup.POST("/add", handlers.AddIRFHandler)
+		IRFGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDIRF, pg.ActionAdd), handlers.AddIRFHandler)
 		// Modify Issue Resolution Form
-		IRFGroup.POST("/edit", handlers.EditIRFHandler)
+		IRFGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDIRF, pg.ActionEdit), handlers.EditIRFHandler)
 		// Delete Issue Resolution Form
-		IRFGroup.POST("/del", handlers.DeleteIRFhandler)
+		IRFGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDIRF, pg.ActionDelete), handlers.DeleteIRFhandler)
 		// Confirm Issue Resolution Form
-		IRFGroup.POST("/confirm", handlers.ConfirmIRFhandler)
+		IRFGroup.POST("/confirm", middleware.PermissionMiddleware(pg.MenuIDIRF, pg.ActionConfirm), handlers.ConfirmIRFhandler)
 		// UnConfirm Issue Resolution Form
-		IRFGroup.POST("/unconfirm", handlers.UnConfirmIRFhandler)
+		IRFGroup.POST("/unconfirm", middleware.PermissionMiddleware(pg.MenuIDIRF, pg.ActionConfirm), handlers.UnConfirmIRFhandler)
 		// Get Issue Resolution Form List
-		IRFGroup.POST("/list", handlers.GetIRFListHandler)
+		IRFGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDIRF, pg.ActionView), handlers.GetIRFListHandler)
 	}
 }
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -13,6 +14,6 @@ func LandPageRoute(g *gin.RouterGroup) {
 		// Get Landing Page Info
 		landGroup.POST("/get", handlers.GetLandingPageInfoHandler)
 		// Modify Landing Page Info
-		landGroup.POST("/edit", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware(), handlers.ModifyLandingPageInfoHandler)
+		landGroup.POST("/edit", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware(), middleware.PermissionMiddleware(pg.MenuIDLPS, pg.ActionEdit), handlers.ModifyLandingPageInfoHandler)
 	}
 }
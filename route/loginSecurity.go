@@ -0,0 +1,33 @@
+package route
+
+import (
+	"sccsmsserver/db/pg"
+	"sccsmsserver/handlers"
+	"sccsmsserver/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func LSRoute(g *gin.RouterGroup) {
+	LSGroup := g.Group("/ls", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
+	{
+		// Get locked IP address list
+		LSGroup.POST("/lockedips", middleware.PermissionMiddleware(pg.MenuIDLS, pg.ActionView), handlers.GetLockedIPsHandler)
+		// Unlock IP address
+		LSGroup.POST("/unlockip", middleware.PermissionMiddleware(pg.MenuIDLS, pg.ActionEdit), handlers.UnlockIPHandler)
+		// Get locked user list
+		LSGroup.POST("/lockedusers", middleware.PermissionMiddleware(pg.MenuIDLS, pg.ActionView), handlers.GetLockedUsersHandler)
+		// Unlock user
+		LSGroup.POST("/unlockuser", middleware.PermissionMiddleware(pg.MenuIDLS, pg.ActionEdit), handlers.UnlockUserHandler)
+		// Get IP address access rule list
+		LSGroup.POST("/ipaccess/list", middleware.PermissionMiddleware(pg.MenuIDLS, pg.ActionView), handlers.GetIPAccessRulesHandler)
+		// Add IP address access rule
+		LSGroup.POST("/ipaccess/add", middleware.PermissionMiddleware(pg.MenuIDLS, pg.ActionAdd), handlers.AddIPAccessRuleHandler)
+		// Modify IP address access rule
+		LSGroup.POST("/ipaccess/edit", middleware.PermissionMiddleware(pg.MenuIDLS, pg.ActionEdit), handlers.EditIPAccessRuleHandler)
+		// Delete IP address access rule
+		LSGroup.POST("/ipaccess/del", middleware.PermissionMiddleware(pg.MenuIDLS, pg.ActionDelete), handlers.DeleteIPAccessRuleHandler)
+		// Get login failure records by pagination
+		LSGroup.POST("/faults", middleware.PermissionMiddleware(pg.MenuIDLS, pg.ActionView), handlers.GetLoginFaultsPaginationHandler)
+	}
+}
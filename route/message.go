@@ -20,5 +20,21 @@ func MsgRoute(g *gin.RouterGroup) {
 		MSGGroup.POST("/eos", handlers.GetUserEORefsHandler)
 		// Read message
 		MSGGroup.POST("/toread", handlers.ReadCommentMessageHandler)
+		// Get user escalations of overdue items
+		MSGGroup.POST("/escalations", handlers.GetUserEscalationsHandler)
+		// Read escalation
+		MSGGroup.POST("/escalation/read", handlers.ReadEscalationHandler)
+		// Get user notification preferences
+		MSGGroup.POST("/notify/prefs", handlers.GetNotifyPrefsHandler)
+		// Save user notification preferences
+		MSGGroup.POST("/notify/prefs/save", handlers.SaveNotifyPrefsHandler)
+		// Send a test email to the user
+		MSGGroup.POST("/notify/test", handlers.SendTestMailHandler)
+	}
+	// Push stream of the user's messages (Server-Sent Events).
+	// GET so that browsers can open it with EventSource, passing the token and client type in the query string.
+	PushGroup := g.Group("/msg/stream", middleware.QueryTokenMiddleware(), middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
+	{
+		PushGroup.GET("", handlers.PushStreamHandler)
 	}
 }
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,8 +12,8 @@ func OuRoute(g *gin.RouterGroup) {
 	ouGroup := g.Group("/ou", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Get online user list
-		ouGroup.POST("/list", handlers.GetOnlineUserHandler)
+		ouGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDOU, pg.ActionView), handlers.GetOnlineUserHandler)
 		// Remove online user
-		ouGroup.POST("/remove", handlers.RemoveOnlineUserHandler)
+		ouGroup.POST("/remove", middleware.PermissionMiddleware(pg.MenuIDOU, pg.ActionDelete), handlers.RemoveOnlineUserHandler)
 	}
 }
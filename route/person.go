@@ -1,18 +1,36 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
+// The Person cache fills the person fields of the vouchers and master data
+var personCacheReaders = []pg.MenuPermission{
+	{MenuID: pg.MenuIDWO, Action: pg.ActionView},
+	{MenuID: pg.MenuIDWOS, Action: pg.ActionView},
+	{MenuID: pg.MenuIDEO, Action: pg.ActionView},
+	{MenuID: pg.MenuIDEOReview, Action: pg.ActionView},
+	{MenuID: pg.MenuIDIRF, Action: pg.ActionView},
+	{MenuID: pg.MenuIDWOStatus, Action: pg.ActionView},
+	{MenuID: pg.MenuIDEOStatus, Action: pg.ActionView},
+	{MenuID: pg.MenuIDIRFStatus, Action: pg.ActionView},
+	{MenuID: pg.MenuIDDocumentFind, Action: pg.ActionView},
+	{MenuID: pg.MenuIDTR, Action: pg.ActionView},
+	{MenuID: pg.MenuIDPPEIF, Action: pg.ActionView},
+	{MenuID: pg.MenuIDDepartment, Action: pg.ActionView},
+	{MenuID: pg.MenuIDUser, Action: pg.ActionView},
+}
+
 func PersonRoute(g *gin.RouterGroup) {
 	personGroup := g.Group("/person", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Get Person Master Data list
-		personGroup.POST("/list", handlers.GetPersonsHandler)
+		personGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDUser, pg.ActionView), handlers.GetPersonsHandler)
 		// Get Latest Person Master data for front-end caching
-		personGroup.POST("/cache", handlers.GetPersonsCacheHandler)
+		personGroup.POST("/cache", middleware.AnyPermissionMiddleware(personCacheReaders...), handlers.GetPersonsCacheHandler)
 	}
 }
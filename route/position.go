@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,15 +12,15 @@ func PositionRoute(g *gin.RouterGroup) {
 	PositionGroup := g.Group("/position", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Add position
-		PositionGroup.POST("/add", handlers.AddPositionHandler)
+		PositionGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDPosition, pg.ActionAdd), handlers.AddPositionHandler)
 		// Get position list
-		PositionGroup.POST("/list", handlers.GetPositionListHandler)
+		PositionGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDPosition, pg.ActionView), handlers.GetPositionListHandler)
 		// Edit position
-		PositionGroup.POST("/edit", handlers.EditPositionHandler)
+		PositionGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDPosition, pg.ActionEdit), handlers.EditPositionHandler)
 		// Delete position
-		PositionGroup.POST("/del", handlers.DeletePositionHandler)
+		PositionGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDPosition, pg.ActionDelete), handlers.DeletePositionHandler)
 		// Batch delete positions
-		PositionGroup.POST("/dels", handlers.DeletePositionsHandler)
+		PositionGroup.POST("/dels", middleware.PermissionMiddleware(pg.MenuIDPosition, pg.ActionDelete), handlers.DeletePositionsHandler)
 		// Check name exists
 		PositionGroup.POST("/checkname", handlers.CheckPositionNameExistHandler)
 		// Get position master data front-end cache
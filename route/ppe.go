@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,15 +12,15 @@ func PPERoute(g *gin.RouterGroup) {
 	PPEGroup := g.Group("/ppe", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Add PPE
-		PPEGroup.POST("/add", handlers.AddPPEHandler)
+		PPEGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDPPE, pg.ActionAdd), handlers.AddPPEHandler)
 		// Get PPE list
-		PPEGroup.POST("/list", handlers.GetPPEListHandler)
+		PPEGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDPPE, pg.ActionView), handlers.GetPPEListHandler)
 		// Modify PPE
-		PPEGroup.POST("/edit", handlers.EditPPEHandler)
+		PPEGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDPPE, pg.ActionEdit), handlers.EditPPEHandler)
 		// Delete PPE
-		PPEGroup.POST("/del", handlers.DeletePPEHandler)
+		PPEGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDPPE, pg.ActionDelete), handlers.DeletePPEHandler)
 		// Batch Delete PPE
-		PPEGroup.POST("/dels", handlers.DeletePPEsHandler)
+		PPEGroup.POST("/dels", middleware.PermissionMiddleware(pg.MenuIDPPE, pg.ActionDelete), handlers.DeletePPEsHandler)
 		// Check if the PPE code exists
 		PPEGroup.POST("/checkcode", handlers.CheckPPECodeExistHandler)
 		// Get latest PPE front-end cache
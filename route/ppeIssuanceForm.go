@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,22 +12,22 @@ func PPEIFRoute(g *gin.RouterGroup) {
 	PPEIFGroup := g.Group("/ppeif", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Add PPE Issuance Form
-		PPEIFGroup.POST("/add", handlers.AddPPEIFHandler)
+		PPEIFGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDPPEIF, pg.ActionAdd), handlers.AddPPEIFHandler)
 		// Use the wizard to generate PPE Issuance Form
-		PPEIFGroup.POST("/wizard", handlers.WiardAddPPEIFHandler)
+		PPEIFGroup.POST("/wizard", middleware.PermissionMiddleware(pg.MenuIDPPEWizard, pg.ActionAdd), handlers.WiardAddPPEIFHandler)
 		// Get PPE Issuance Form List
-		PPEIFGroup.POST("/list", handlers.GetPPEIFListHandler)
+		PPEIFGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDPPEIF, pg.ActionView), handlers.GetPPEIFListHandler)
 		// Get PPE Issuance Form detail by HID
-		PPEIFGroup.POST("/detail", handlers.GetPPEIFInfoByHIDHandler)
+		PPEIFGroup.POST("/detail", middleware.PermissionMiddleware(pg.MenuIDPPEIF, pg.ActionView), handlers.GetPPEIFInfoByHIDHandler)
 		// Modify PPE Issuance Form
-		PPEIFGroup.POST("/edit", handlers.EditPPEIFHandler)
+		PPEIFGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDPPEIF, pg.ActionEdit), handlers.EditPPEIFHandler)
 		// Delete PPE Issuance Form
-		PPEIFGroup.POST("/del", handlers.DeletePPEIFHandler)
+		PPEIFGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDPPEIF, pg.ActionDelete), handlers.DeletePPEIFHandler)
 		// Confirm PPE Issuance Form
-		PPEIFGroup.POST("/confirm", handlers.ConfirmPPEIFHandler)
+		PPEIFGroup.POST("/confirm", middleware.PermissionMiddleware(pg.MenuIDPPEIF, pg.ActionConfirm), handlers.ConfirmPPEIFHandler)
 		// Unconfirm PPE Issuance Form
-		PPEIFGroup.POST("/unconfirm", handlers.UnconfirmPPEIFHandler)
+		PPEIFGroup.POST("/unconfirm", middleware.PermissionMiddleware(pg.MenuIDPPEIF, pg.ActionConfirm), handlers.UnconfirmPPEIFHandler)
 		// Get PPE Issuance Form Report
-		PPEIFGroup.POST("/rep", handlers.GetPPEIFReportHandler)
+		PPEIFGroup.POST("/rep", middleware.PermissionMiddleware(pg.MenuIDPPES, pg.ActionView), handlers.GetPPEIFReportHandler)
 	}
 }
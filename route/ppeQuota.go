@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,19 +12,19 @@ func PPEQuotaRoute(g *gin.RouterGroup) {
 	LQGroup := g.Group("/ppeq", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Get Personal Protective Equipment Quota List
-		LQGroup.POST("/list", handlers.GetPPEQuotaListHandler)
+		LQGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDPQ, pg.ActionView), handlers.GetPPEQuotaListHandler)
 		// Get The Personal Protective Equipment Quota detail by HID
-		LQGroup.POST("/detail", handlers.GetPPEQuotaInfoByHIDHandler)
+		LQGroup.POST("/detail", middleware.PermissionMiddleware(pg.MenuIDPQ, pg.ActionView), handlers.GetPPEQuotaInfoByHIDHandler)
 		// Add Personal Protective Equipment Quota
-		LQGroup.POST("/add", handlers.AddPPEQuotaHandler)
+		LQGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDPQ, pg.ActionAdd), handlers.AddPPEQuotaHandler)
 		// Modify Personal Protective Equipment Quota
-		LQGroup.POST("/edit", handlers.EditPPEQuotaHandler)
+		LQGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDPQ, pg.ActionEdit), handlers.EditPPEQuotaHandler)
 		// Delete Personal Protective Equipment Quota
-		LQGroup.POST("/del", handlers.DeletePPEQuotaHandler)
+		LQGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDPQ, pg.ActionDelete), handlers.DeletePPEQuotaHandler)
 		// Confirm PPE Quota
-		LQGroup.POST("/confirm", handlers.ConfirmPPEQuotaHandler)
+		LQGroup.POST("/confirm", middleware.PermissionMiddleware(pg.MenuIDPQ, pg.ActionConfirm), handlers.ConfirmPPEQuotaHandler)
 		// Unconfirm PPE Quota
-		LQGroup.POST("/unconfirm", handlers.UnconfirmPPEQuotaHandler)
+		LQGroup.POST("/unconfirm", middleware.PermissionMiddleware(pg.MenuIDPQ, pg.ActionConfirm), handlers.UnconfirmPPEQuotaHandler)
 		// Check if a PPE Position Quota for the same period
 		LQGroup.POST("/check", handlers.CheckPPEQuotaExistHandler)
 		// Get the list of all position that have PPE Quotas within the same period
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,10 +12,10 @@ func RepRoute(g *gin.RouterGroup) {
 	REPGroup := g.Group("/rep", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Work Order Status Report
-		REPGroup.POST("/wor", handlers.GetWoReportHandler)
+		REPGroup.POST("/wor", middleware.PermissionMiddleware(pg.MenuIDWOStatus, pg.ActionView), handlers.GetWoReportHandler)
 		// Execution Order status Report
-		REPGroup.POST("/eor", handlers.GetEoReportHandler)
+		REPGroup.POST("/eor", middleware.PermissionMiddleware(pg.MenuIDEOStatus, pg.ActionView), handlers.GetEoReportHandler)
 		// Issue Resolution Form Report
-		REPGroup.POST("/irfr", handlers.GetIRFReportHandler)
+		REPGroup.POST("/irfr", middleware.PermissionMiddleware(pg.MenuIDIRFStatus, pg.ActionView), handlers.GetIRFReportHandler)
 	}
 }
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,15 +12,15 @@ func RLRoute(g *gin.RouterGroup) {
 	RLGroup := g.Group("/rl", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Add Risk Level
-		RLGroup.POST("/add", handlers.AddRLHandler)
+		RLGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDRL, pg.ActionAdd), handlers.AddRLHandler)
 		// Get Risk Level List
-		RLGroup.POST("/list", handlers.GetRLListHandler)
+		RLGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDRL, pg.ActionView), handlers.GetRLListHandler)
 		// Modify Risk Level
-		RLGroup.POST("/edit", handlers.EditRLHandler)
+		RLGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDRL, pg.ActionEdit), handlers.EditRLHandler)
 		// Delete Risk Level
-		RLGroup.POST("/del", handlers.DeleteRLHandler)
+		RLGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDRL, pg.ActionDelete), handlers.DeleteRLHandler)
 		// Batch datele Risk Level
-		RLGroup.POST("/dels", handlers.DeleteRLsHandler)
+		RLGroup.POST("/dels", middleware.PermissionMiddleware(pg.MenuIDRL, pg.ActionDelete), handlers.DeleteRLsHandler)
 		// Check if the Risk Level name exists
 		RLGroup.POST("/checkname", handlers.CheckRLNameExistHandler)
 		// Get Risk Level front-end cache
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,20 +12,20 @@ func RoleRoute(g *gin.RouterGroup) {
 	roleGroup := g.Group("/role", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Get role list
-		roleGroup.POST("/list", handlers.GetRolesHandler)
+		roleGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDRole, pg.ActionView), handlers.GetRolesHandler)
 		// Check the Role name exists
 		roleGroup.POST("/checkname", handlers.CheckRoleNameExistHandler)
 		// Add role
-		roleGroup.POST("/add", handlers.AddRoleHandler)
+		roleGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDRole, pg.ActionAdd), handlers.AddRoleHandler)
 		// Edit role
-		roleGroup.POST("/edit", handlers.EditRoleHandler)
+		roleGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDRole, pg.ActionEdit), handlers.EditRoleHandler)
 		// Delete Role
-		roleGroup.POST("/del", handlers.DeleteRoleHandler)
+		roleGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDRole, pg.ActionDelete), handlers.DeleteRoleHandler)
 		// Batch delete roles
-		roleGroup.POST("/dels", handlers.DeleteRolesHandler)
+		roleGroup.POST("/dels", middleware.PermissionMiddleware(pg.MenuIDRole, pg.ActionDelete), handlers.DeleteRolesHandler)
 		// Get role permissions
-		roleGroup.POST("/getmenu", handlers.GetRoleMenusHandler)
+		roleGroup.POST("/getmenu", middleware.PermissionMiddleware(pg.MenuIDPA, pg.ActionView), handlers.GetRoleMenusHandler)
 		// Modify role permissions
-		roleGroup.POST("/updaterolemenus", handlers.UpdateRoleMenusHandler)
+		roleGroup.POST("/updaterolemenus", middleware.PermissionMiddleware(pg.MenuIDPA, pg.ActionEdit), handlers.UpdateRoleMenusHandler)
 	}
 }
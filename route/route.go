@@ -3,6 +3,7 @@ package route
 import (
 	"sccsmsserver/handlers"
 	"sccsmsserver/logger"
+	"sccsmsserver/middleware"
 	"sccsmsserver/pub"
 	"sccsmsserver/ui"
 
@@ -15,8 +16,11 @@ func Setup(mode string) *gin.Engine {
 	r.Use(logger.GinLogger(), logger.GinRecovery(true)) // Global middleware
 	r.Use(IpBlackListMiddleWare())                      // IP Black list
 	// Globle path
-	superGroup := r.Group(pub.APIPath)
+	superGroup := r.Group(pub.APIPath, middleware.RateLimitMiddleware()) // API rate limiting
 	{
+		APIKeyRoute(superGroup)    // API keys of service users
+		ApprovalRoute(superGroup)  // Voucher approval
+		AuditRoute(superGroup)     // Audit trail
 		AuthRoute(superGroup)      // Auth
 		CSARoute(superGroup)       // Construction Site Archive
 		CSCRoute(superGroup)       // Construction Site Category
@@ -33,6 +37,7 @@ func Setup(mode string) *gin.Engine {
 		FileRoute(superGroup)      // File
 		IRFRoute(superGroup)       // Issue Resolution Form
 		LandPageRoute(superGroup)  // Landing Page define
+		LSRoute(superGroup)        // Login security
 		MsgRoute(superGroup)       // Message
 		OuRoute(superGroup)        // Online user
 		PersonRoute(superGroup)    // Person
@@ -49,10 +54,13 @@ func Setup(mode string) *gin.Engine {
 		UDARoute(superGroup)       // User-defined Archive
 		UDCRoute(superGroup)       // User-defined Category
 		UserRoute(superGroup)      // User
+		WebhookRoute(superGroup)   // Voucher event webhooks
 		WORoute(superGroup)        // Work Order
 	}
 	// Client tests the server is running
 	r.POST("/ping", handlers.PubServerPing)
+	// Public keys other services use to verify tokens
+	r.GET("/.well-known/jwks.json", handlers.GetJWKSHandler)
 	// Monolithic application
 	ui.AddRoutes(r)
 
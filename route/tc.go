@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,18 +12,18 @@ func TCRoute(g *gin.RouterGroup) {
 	TCGroup := g.Group("/tc", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Get Training Course List
-		TCGroup.POST("/list", handlers.GetTCListHandler)
+		TCGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDTC, pg.ActionView), handlers.GetTCListHandler)
 		// Get Training Course Frontend Cache
 		TCGroup.POST("/cache", handlers.GetTCCacheHandler)
 		// Check Training Course Name Exist
 		TCGroup.POST("/checkname", handlers.CheckTCNameExistHandler)
 		// Add
-		TCGroup.POST("/add", handlers.AddTCHandler)
+		TCGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDTC, pg.ActionAdd), handlers.AddTCHandler)
 		// Edit
-		TCGroup.POST("/edit", handlers.EditTCHandler)
+		TCGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDTC, pg.ActionEdit), handlers.EditTCHandler)
 		// Delete
-		TCGroup.POST("/del", handlers.DeleteTCHandler)
+		TCGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDTC, pg.ActionDelete), handlers.DeleteTCHandler)
 		// Batch Delete
-		TCGroup.POST("/dels", handlers.DeleteTCsHandler)
+		TCGroup.POST("/dels", middleware.PermissionMiddleware(pg.MenuIDTC, pg.ActionDelete), handlers.DeleteTCsHandler)
 	}
 }
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,22 +12,22 @@ func TRRoute(g *gin.RouterGroup) {
 	TRGroup := g.Group("/tr", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Add Training Record
-		TRGroup.POST("/add", handlers.AddTRHandler)
+		TRGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDTR, pg.ActionAdd), handlers.AddTRHandler)
 		// Get Training Record list
-		TRGroup.POST("/list", handlers.GetTRListHandler)
+		TRGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDTR, pg.ActionView), handlers.GetTRListHandler)
 		// Get Training Record details
-		TRGroup.POST("/detail", handlers.GetTRInfoByHIDHandler)
+		TRGroup.POST("/detail", middleware.PermissionMiddleware(pg.MenuIDTR, pg.ActionView), handlers.GetTRInfoByHIDHandler)
 		// Modify Training Record
-		TRGroup.POST("/edit", handlers.EditTRHandler)
+		TRGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDTR, pg.ActionEdit), handlers.EditTRHandler)
 		// Delete Training Record
-		TRGroup.POST("/del", handlers.DeleteTRHandler)
+		TRGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDTR, pg.ActionDelete), handlers.DeleteTRHandler)
 		// Confirm Training Record
-		TRGroup.POST("/confirm", handlers.ConfirmTRHandler)
+		TRGroup.POST("/confirm", middleware.PermissionMiddleware(pg.MenuIDTR, pg.ActionConfirm), handlers.ConfirmTRHandler)
 		// UnConfirm Training Record
-		TRGroup.POST("/unconfirm", handlers.UnConfirmTRHandler)
+		TRGroup.POST("/unconfirm", middleware.PermissionMiddleware(pg.MenuIDTR, pg.ActionConfirm), handlers.UnConfirmTRHandler)
 		// Get Taught Lessons Report
-		TRGroup.POST("/tlrep", handlers.GetTaughtLessonsReportHandler)
+		TRGroup.POST("/tlrep", middleware.PermissionMiddleware(pg.MenuIDTS, pg.ActionView), handlers.GetTaughtLessonsReportHandler)
 		// Get Recieved Training Report
-		TRGroup.POST("/rtrep", handlers.GetRecivedTrainingReportHandler)
+		TRGroup.POST("/rtrep", middleware.PermissionMiddleware(pg.MenuIDTPS, pg.ActionView), handlers.GetRecivedTrainingReportHandler)
 	}
 }
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,15 +12,15 @@ func UDARoute(g *gin.RouterGroup) {
 	UDAGroup := g.Group("/uda", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Add UDA
-		UDAGroup.POST("/add", handlers.AddUDAHandler)
+		UDAGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDUDA, pg.ActionAdd), handlers.AddUDAHandler)
 		// Edit UDA
-		UDAGroup.POST("/edit", handlers.EditUDAHandler)
+		UDAGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDUDA, pg.ActionEdit), handlers.EditUDAHandler)
 		// Delete UDA
-		UDAGroup.POST("/del", handlers.DeleteUDAHandler)
+		UDAGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDUDA, pg.ActionDelete), handlers.DeleteUDAHandler)
 		// Batch delete UDA
-		UDAGroup.POST("/dels", handlers.DeleteUDAsHandler)
+		UDAGroup.POST("/dels", middleware.PermissionMiddleware(pg.MenuIDUDA, pg.ActionDelete), handlers.DeleteUDAsHandler)
 		// Get UDA list under the UDC
-		UDAGroup.POST("/list", handlers.GetUDAListHandler)
+		UDAGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDUDA, pg.ActionView), handlers.GetUDAListHandler)
 		// Get all UDA list
 		UDAGroup.POST("/all", handlers.GetUDAAllHandler)
 		// Check if the UDA code exist
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,15 +12,15 @@ func UDCRoute(g *gin.RouterGroup) {
 	UDCGroup := g.Group("/udc", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Add UDC
-		UDCGroup.POST("/add", handlers.AddUDCHandler)
+		UDCGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDUDC, pg.ActionAdd), handlers.AddUDCHandler)
 		// Get UDC list
-		UDCGroup.POST("/list", handlers.GetUDCListHandler)
+		UDCGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDUDC, pg.ActionView), handlers.GetUDCListHandler)
 		// Edit UDC
-		UDCGroup.POST("/edit", handlers.EditUDCHandler)
+		UDCGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDUDC, pg.ActionEdit), handlers.EditUDCHandler)
 		// Delete UDC
-		UDCGroup.POST("/del", handlers.DeleteUDCHandler)
+		UDCGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDUDC, pg.ActionDelete), handlers.DeleteUDCHandler)
 		// Batch delete UDC
-		UDCGroup.POST("/dels", handlers.DeleteUDCsHandler)
+		UDCGroup.POST("/dels", middleware.PermissionMiddleware(pg.MenuIDUDC, pg.ActionDelete), handlers.DeleteUDCsHandler)
 		// Check if the UDC name exists
 		UDCGroup.POST("/checkname", handlers.CheckUDCNameExistHandler)
 		// Get latest UDC front-end cache
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,26 +12,38 @@ func UserRoute(g *gin.RouterGroup) {
 	userGroup := g.Group("/user", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Get User list
-		userGroup.POST("/list", handlers.GetUsersHandler)
+		userGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDUser, pg.ActionView), handlers.GetUsersHandler)
 		// Get Menu List
 		userGroup.POST("/getmenu", handlers.GetMenuHandler)
 		// Delete User
-		userGroup.POST("/del", handlers.DeleteUserHandler)
+		userGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDUser, pg.ActionDelete), handlers.DeleteUserHandler)
 		// Batch Delete User
-		userGroup.POST("/dels", handlers.DeleteUsersHandler)
+		userGroup.POST("/dels", middleware.PermissionMiddleware(pg.MenuIDUser, pg.ActionDelete), handlers.DeleteUsersHandler)
 		// Check if the user code exists
 		userGroup.POST("/checkcode", handlers.CheckUserCodeExistHandler)
 		// Check if the user name exists
 		userGroup.POST("/checkname", handlers.CheckUserNameExistHandler)
 		// Add User
-		userGroup.POST("/add", handlers.AddUserHandler)
+		userGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDUser, pg.ActionAdd), handlers.AddUserHandler)
 		// Edit User
-		userGroup.POST("/edit", handlers.EditUserHandler)
+		userGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDUser, pg.ActionEdit), handlers.EditUserHandler)
+		// Reset user password
+		userGroup.POST("/resetpwd", middleware.PermissionMiddleware(pg.MenuIDUser, pg.ActionEdit), handlers.ResetUserPasswordHandler)
 		// Change user avatar
 		userGroup.POST("/changeavatar", handlers.ChangeUserAvatarHandler)
 		// Get user information based on token
 		userGroup.POST("/info", handlers.UserInfoHandler)
 		// User update VIA personal center
 		userGroup.POST("/modifyprofile", handlers.ModifyProfileHandler)
+		// Generate TOTP secret
+		userGroup.POST("/totp/setup", handlers.SetupTOTPHandler)
+		// Enable TOTP
+		userGroup.POST("/totp/enable", handlers.EnableTOTPHandler)
+		// Disable TOTP
+		userGroup.POST("/totp/disable", handlers.DisableTOTPHandler)
+		// Regenerate TOTP recovery codes
+		userGroup.POST("/totp/recoverycodes", handlers.RegenerateRecoveryCodesHandler)
+		// Reset user TOTP
+		userGroup.POST("/totp/reset", middleware.PermissionMiddleware(pg.MenuIDUser, pg.ActionEdit), handlers.ResetUserTOTPHandler)
 	}
 }
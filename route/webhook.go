@@ -0,0 +1,27 @@
+package route
+
+import (
+	"sccsmsserver/db/pg"
+	"sccsmsserver/handlers"
+	"sccsmsserver/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func WebhookRoute(g *gin.RouterGroup) {
+	webhookGroup := g.Group("/webhook", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
+	{
+		// Get webhook list
+		webhookGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDWH, pg.ActionView), handlers.GetWebhooksHandler)
+		// Add webhook
+		webhookGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDWH, pg.ActionAdd), handlers.AddWebhookHandler)
+		// Modify webhook
+		webhookGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDWH, pg.ActionEdit), handlers.EditWebhookHandler)
+		// Delete webhook
+		webhookGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDWH, pg.ActionDelete), handlers.DeleteWebhookHandler)
+		// Get webhook delivery log by pagination
+		webhookGroup.POST("/delivery/list", middleware.PermissionMiddleware(pg.MenuIDWH, pg.ActionView), handlers.GetWebhookDeliveriesPaginationHandler)
+		// Redeliver webhook delivery
+		webhookGroup.POST("/delivery/redeliver", middleware.PermissionMiddleware(pg.MenuIDWH, pg.ActionEdit), handlers.RedeliverWebhookHandler)
+	}
+}
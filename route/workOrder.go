@@ -1,6 +1,7 @@
 package route
 
 import (
+	"sccsmsserver/db/pg"
 	"sccsmsserver/handlers"
 	"sccsmsserver/middleware"
 
@@ -11,22 +12,33 @@ func WORoute(g *gin.RouterGroup) {
 	WOGroup := g.Group("/wo", middleware.CheckClientTypeMiddleware(), middleware.JWTAuthMiddleware())
 	{
 		// Get Work Order list
-		WOGroup.POST("/list", handlers.GetWOListHanlder)
+		WOGroup.POST("/list", middleware.PermissionMiddleware(pg.MenuIDWO, pg.ActionView), handlers.GetWOListHanlder)
 		// Get Work Order details
-		WOGroup.POST("/detail", handlers.GetWOInfoByIDHandler)
+		WOGroup.POST("/detail", middleware.PermissionMiddleware(pg.MenuIDWO, pg.ActionView), handlers.GetWOInfoByIDHandler)
 		// Add Work Order
-		WOGroup.POST("/add", handlers.AddWOHandler)
+		WOGroup.POST("/add", middleware.PermissionMiddleware(pg.MenuIDWO, pg.ActionAdd), handlers.AddWOHandler)
 		// Edit Work Order
-		WOGroup.POST("/edit", handlers.EditWOHandler)
+		WOGroup.POST("/edit", middleware.PermissionMiddleware(pg.MenuIDWO, pg.ActionEdit), handlers.EditWOHandler)
 		// Delete Work Order
-		WOGroup.POST("/del", handlers.DeleteWOHandler)
+		WOGroup.POST("/del", middleware.PermissionMiddleware(pg.MenuIDWO, pg.ActionDelete), handlers.DeleteWOHandler)
 		// Batch delete Work Order
-		WOGroup.POST("/dels", handlers.DeleteWOsHandler)
+		WOGroup.POST("/dels", middleware.PermissionMiddleware(pg.MenuIDWO, pg.ActionDelete), handlers.DeleteWOsHandler)
 		// Confirm Work Order
-		WOGroup.POST("/confirm", handlers.ConfirmWOHandler)
+		WOGroup.POST("/confirm", middleware.PermissionMiddleware(pg.MenuIDWO, pg.ActionConfirm), handlers.ConfirmWOHandler)
 		// Unconfirm Work Order
-		WOGroup.POST("/unconfirm", handlers.UnConfirmWOHandler)
+		WOGroup.POST("/unconfirm", middleware.PermissionMiddleware(pg.MenuIDWO, pg.ActionConfirm), handlers.UnConfirmWOHandler)
 		// Get the list of Work Order awaiting execution
-		WOGroup.POST("/refer", handlers.GetWOReferHandler)
+		WOGroup.POST("/refer", middleware.AnyPermissionMiddleware(
+			pg.MenuPermission{MenuID: pg.MenuIDWO, Action: pg.ActionView},
+			pg.MenuPermission{MenuID: pg.MenuIDEO, Action: pg.ActionAdd},
+			pg.MenuPermission{MenuID: pg.MenuIDEO, Action: pg.ActionEdit}), handlers.GetWOReferHandler)
+		// Get Work Order schedule list
+		WOGroup.POST("/schedule/list", middleware.PermissionMiddleware(pg.MenuIDWOS, pg.ActionView), handlers.GetWOSchedulesHandler)
+		// Add Work Order schedule
+		WOGroup.POST("/schedule/add", middleware.PermissionMiddleware(pg.MenuIDWOS, pg.ActionAdd), handlers.AddWOScheduleHandler)
+		// Modify Work Order schedule
+		WOGroup.POST("/schedule/edit", middleware.PermissionMiddleware(pg.MenuIDWOS, pg.ActionEdit), handlers.EditWOScheduleHandler)
+		// Delete Work Order schedule
+		WOGroup.POST("/schedule/del", middleware.PermissionMiddleware(pg.MenuIDWOS, pg.ActionDelete), handlers.DeleteWOScheduleHandler)
 	}
 }
@@ -11,22 +11,35 @@ var Conf = new(AppConfig)
 
 // AppConfig defines the entire application's configuration structure
 type AppConfig struct {
-	Name            string                                        `mapstructure:"name" json:"name"`                       // Application's name
-	Addr            string                                        `mapstructure:"addr" json:"addr"`                       // IP address of the application
-	Mode            string                                        `mapstructure:"mode" json:"mode"`                       // The application's run mode, can be set to "debug" or "release"
-	Port            int32                                         `mapstructure:"port" json:"port"`                       // Port number of the application
-	StartTime       string                                        `mapstructure:"start_time" json:"startTime"`            // Timestamp when the application started. If you are using multiple application servers, you can use this field to record the start time each one
-	MachineID       int64                                         `mapstructure:"machine_id" json:"machineID"`            // If you're using multiple application servers, you can use this field to record the machine identifier for each one
-	TLS             bool                                          `mapstructure:"tls" json:"TLS"`                         // Enable TLS or not
-	CertificateFile string                                        `mapstructure:"certificatefile" json:"certificateFile"` // TLS client certificate file name, required when TLS is enabled
-	PrivateKeyFile  string                                        `mapstructure:"privatekeyfile" json:"privateKeyFile"`   // TLS private key certificate file name, required when TLS is enabled
-	UserLockTh      int32                                         `mapstructure:"userlockth" json:"userLockTh"`           // Maximum number of allowed password failures within 30 minutes; exceeding this will lock the account
-	IpLockTh        int32                                         `mapstructure:"iplockth" json:"ipLockTh"`               // Maxium number of login attempts with non-existent usernames within 30 minutes; exceeding this will result in the IP address being locked
-	IpLockedMinutes int32                                         `mapstructure:"iplockedminutes" json:"ipLockedMinutes"` // Duration of IP address lock (in minutes)
-	*LogConfig      `mapstructure:"log" json:"log"`               // Application log configuration
-	*PqConfig       `mapstructure:"postgresql" json:"postgresql"` // Application PostgreSQL database configuration
-	*S3Storage      `mapstructure:"s3storage" json:"s3storage"`   // AWS s3 object storage server configuration
-	*RedisConfig    `mapstructure:"redis" json:"redis"`           // Redis cache configuration
+	Name             string                                                `mapstructure:"name" json:"name"`                       // Application's name
+	Addr             string                                                `mapstructure:"addr" json:"addr"`                       // IP address of the application
+	Mode             string                                                `mapstructure:"mode" json:"mode"`                       // The application's run mode, can be set to "debug" or "release"
+	Port             int32                                                 `mapstructure:"port" json:"port"`                       // Port number of the application
+	StartTime        string                                                `mapstructure:"start_time" json:"startTime"`            // Timestamp when the application started. If you are using multiple application servers, you can use this field to record the start time each one
+	MachineID        int64                                                 `mapstructure:"machine_id" json:"machineID"`            // If you're using multiple application servers, you can use this field to record the machine identifier for each one
+	TLS              bool                                                  `mapstructure:"tls" json:"TLS"`                         // Enable TLS or not
+	CertificateFile  string                                                `mapstructure:"certificatefile" json:"certificateFile"` // TLS client certificate file name, required when TLS is enabled
+	PrivateKeyFile   string                                                `mapstructure:"privatekeyfile" json:"privateKeyFile"`   // TLS private key certificate file name, required when TLS is enabled
+	UserLockTh       int32                                                 `mapstructure:"userlockth" json:"userLockTh"`           // Maximum number of allowed password failures within 30 minutes; exceeding this will lock the account
+	IpLockTh         int32                                                 `mapstructure:"iplockth" json:"ipLockTh"`               // Maxium number of login attempts with non-existent usernames within 30 minutes; exceeding this will result in the IP address being locked
+	IpLockedMinutes  int32                                                 `mapstructure:"iplockedminutes" json:"ipLockedMinutes"` // Duration of IP address lock (in minutes)
+	SessionLimit     int32                                                 `mapstructure:"sessionlimit" json:"sessionLimit"`       // Maximum number of concurrent sessions per user on each client type, default 1. The least recently seen session is signed out when exceeded
+	*LogConfig       `mapstructure:"log" json:"log"`                       // Application log configuration
+	*PqConfig        `mapstructure:"postgresql" json:"postgresql"`         // Application PostgreSQL database configuration
+	*S3Storage       `mapstructure:"s3storage" json:"s3storage"`           // AWS s3 object storage server configuration
+	*RedisConfig     `mapstructure:"redis" json:"redis"`                   // Redis cache configuration
+	*JWTConfig       `mapstructure:"jwt" json:"jwt"`                       // JSON Web Token signing keys configuration
+	*LDAPConfig      `mapstructure:"ldap" json:"ldap"`                     // LDAP / Active Directory authentication configuration
+	*OIDCConfig      `mapstructure:"oidc" json:"oidc"`                     // OpenID Connect single sign-on configuration
+	*PasswordPolicy  `mapstructure:"passwordpolicy" json:"passwordPolicy"` // Password policy of the local users
+	*RateLimitConfig `mapstructure:"ratelimit" json:"rateLimit"`           // API rate limiting configuration
+	*RSAConfig       `mapstructure:"rsa" json:"rsa"`                       // Login RSA keys configuration
+	*TOTPConfig      `mapstructure:"totp" json:"totp"`                     // TOTP secret encryption keys configuration
+	*SchedulerConfig `mapstructure:"scheduler" json:"scheduler"`           // Background jobs configuration
+	*WorkOrderConfig `mapstructure:"workorder" json:"workOrder"`           // Work Order configuration
+	*OverdueConfig   `mapstructure:"overdue" json:"overdue"`               // Overdue escalation configuration
+	*SMTPConfig      `mapstructure:"smtp" json:"smtp"`                     // Email notification configuration
+	*WebhookConfig   `mapstructure:"webhook" json:"webhook"`               // Webhook delivery configuration
 }
 
 // Application's log configuration structure
@@ -71,6 +84,164 @@ type RedisConfig struct {
 	PoolSize int    `mapstructure:"pool_size" json:"poolSize"`
 }
 
+// JSON Web Token signing keys configuration
+type JWTConfig struct {
+	SigningKeyID string   `mapstructure:"signing_kid" json:"signingKid"` // Key ID of the key that signs new tokens, may be omitted when only one key is configured
+	Keys         []JWTKey `mapstructure:"keys" json:"keys"`              // Keys accepted when verifying tokens. Keep a retired key until the tokens it signed have expired
+}
+
+// JSON Web Token signing key
+type JWTKey struct {
+	ID             string `mapstructure:"kid" json:"kid"`                       // Key ID, written to the token kid header
+	Algorithm      string `mapstructure:"algorithm" json:"algorithm"`           // HS256, RS256 or EdDSA, default HS256
+	Secret         string `mapstructure:"secret" json:"-"`                      // HS256 shared secret, at least 32 bytes
+	SecretFile     string `mapstructure:"secretfile" json:"secretFile"`         // File containing the HS256 shared secret, takes precedence over secret
+	PrivateKeyFile string `mapstructure:"privatekeyfile" json:"privateKeyFile"` // PEM private key file for RS256 (PKCS#1 or PKCS#8) and EdDSA (PKCS#8), only required by the signing key
+	PublicKeyFile  string `mapstructure:"publickeyfile" json:"publicKeyFile"`   // PEM public key file for RS256 and EdDSA, enough for keys that only verify tokens
+}
+
+// Login RSA keys configuration.
+// The clients encrypt passwords with the public key of the active key and send its key ID back.
+// Without configured keys the keys are kept in the database and rotated automatically.
+type RSAConfig struct {
+	ActiveKeyID  string   `mapstructure:"active_kid" json:"activeKid"`      // Key ID of the key given to the clients, may be omitted when only one key is configured
+	Keys         []RSAKey `mapstructure:"keys" json:"keys"`                 // Keys accepted when decrypting. Keep a retired key until the login pages that fetched it are gone
+	RotateDays   int      `mapstructure:"rotatedays" json:"rotateDays"`     // Days between rotations of the database keys, default 30
+	OverlapHours int      `mapstructure:"overlaphours" json:"overlapHours"` // Hours a rotated database key is still accepted, default 24
+}
+
+// Login RSA key
+type RSAKey struct {
+	ID             string `mapstructure:"kid" json:"kid"`                       // Key ID, sent back by the clients
+	PrivateKeyFile string `mapstructure:"privatekeyfile" json:"privateKeyFile"` // PEM private key file (PKCS#1 or PKCS#8), the public key is derived from it
+}
+
+// TOTP secret encryption keys configuration.
+// The secrets are stored encrypted with the active key, TOTP can't be set up without a key.
+type TOTPConfig struct {
+	ActiveKeyID string    `mapstructure:"active_kid" json:"activeKid"` // Key ID of the key that encrypts new secrets, may be omitted when only one key is configured
+	Keys        []TOTPKey `mapstructure:"keys" json:"keys"`            // Keys accepted when decrypting. The secrets are encrypted again with the active key on startup
+}
+
+// TOTP secret encryption key
+type TOTPKey struct {
+	ID      string `mapstructure:"kid" json:"kid"`         // Key ID, stored with the encrypted secrets
+	Key     string `mapstructure:"key" json:"-"`           // Base64 encoded 32 byte AES-256 key
+	KeyFile string `mapstructure:"keyfile" json:"keyFile"` // File containing the base64 encoded key, takes precedence over key
+}
+
+// Background jobs configuration, e.g. the generation of the Work Orders of the schedules.
+// Every server runs the jobs, a database lock lets only one server do each run.
+type SchedulerConfig struct {
+	Disabled bool `mapstructure:"disabled" json:"disabled"` // Don't run the background jobs on this server
+	Interval int  `mapstructure:"interval" json:"interval"` // Seconds between two runs of the jobs, default 60
+}
+
+// Work Order configuration
+type WorkOrderConfig struct {
+	DraftEO bool `mapstructure:"drafteo" json:"draftEO"` // Create the Execution Order drafts of confirmed Work Orders, unless a Work Order says otherwise
+}
+
+// Overdue escalation configuration.
+// Risk Levels set their own delays, these apply to Work Orders and to items without a Risk Level.
+type OverdueConfig struct {
+	OwnerHours int `mapstructure:"ownerhours" json:"ownerHours"` // Hours overdue before escalating to the owner, default 0
+	RespHours  int `mapstructure:"resphours" json:"respHours"`   // Hours overdue before escalating to the CSA responsible person, default 24
+	HeadHours  int `mapstructure:"headhours" json:"headHours"`   // Hours overdue before escalating to the department head, default 72
+}
+
+// Email notification configuration.
+// The notifications are queued in the database and sent by a background job,
+// a failed send is retried with a growing delay.
+type SMTPConfig struct {
+	Enabled            bool   `mapstructure:"enabled" json:"enabled"`                       // Send email notifications
+	Host               string `mapstructure:"host" json:"host"`                             // SMTP server host, e.g. 127.0.0.1 for a local SMTP stub
+	Port               int    `mapstructure:"port" json:"port"`                             // SMTP server port, default 587, or 465 with tls
+	TLS                string `mapstructure:"tls" json:"tls"`                               // starttls, tls (implicit TLS) or none, default starttls when the server offers it
+	InsecureSkipVerify bool   `mapstructure:"insecureskipverify" json:"insecureSkipVerify"` // Skip the server certificate verification, only for testing
+	Username           string `mapstructure:"username" json:"username"`                     // Login user, empty for servers without authentication
+	Password           string `mapstructure:"password" json:"-"`                            // Login password
+	From               string `mapstructure:"from" json:"from"`                             // Sender address, e.g. "SCCSMS <noreply@example.com>"
+	MaxAttempts        int    `mapstructure:"maxattempts" json:"maxAttempts"`               // Sends of a notification before it is given up, default 5
+}
+
+// Webhook delivery configuration.
+// Voucher events are queued in the database and posted by a background job,
+// a failed delivery is retried with an exponential backoff.
+type WebhookConfig struct {
+	Timeout     int `mapstructure:"timeout" json:"timeout"`         // Seconds a request waits for the response, default 10
+	MaxAttempts int `mapstructure:"maxattempts" json:"maxAttempts"` // Requests of a delivery before it is given up, default 8
+}
+
+// LDAP / Active Directory authentication configuration.
+// Users that don't exist yet are created on their first successful login,
+// their name, email, mobile, department and mapped roles are updated on each login.
+type LDAPConfig struct {
+	Enabled            bool              `mapstructure:"enabled" json:"enabled"`                       // Enable LDAP authentication
+	URL                string            `mapstructure:"url" json:"url"`                               // Directory server URL, e.g. ldaps://dc1.example.com:636
+	StartTLS           bool              `mapstructure:"starttls" json:"startTLS"`                     // Upgrade an ldap:// connection with StartTLS
+	InsecureSkipVerify bool              `mapstructure:"insecureskipverify" json:"insecureSkipVerify"` // Skip the server certificate verification, only for testing
+	Timeout            int               `mapstructure:"timeout" json:"timeout"`                       // Connection timeout in seconds, default 10
+	BindDN             string            `mapstructure:"binddn" json:"bindDN"`                         // Service account used to search the user, empty for anonymous search
+	BindPassword       string            `mapstructure:"bindpassword" json:"-"`                        // Service account password
+	BaseDN             string            `mapstructure:"basedn" json:"baseDN"`                         // Search base of the users
+	UserFilter         string            `mapstructure:"userfilter" json:"userFilter"`                 // User search filter, %s is replaced with the login user code. Default (&(objectClass=user)(sAMAccountName=%s))
+	NameAttribute      string            `mapstructure:"nameattribute" json:"nameAttribute"`           // Default displayName
+	EmailAttribute     string            `mapstructure:"emailattribute" json:"emailAttribute"`         // Default mail
+	MobileAttribute    string            `mapstructure:"mobileattribute" json:"mobileAttribute"`       // Default mobile
+	DeptAttribute      string            `mapstructure:"deptattribute" json:"deptAttribute"`           // Attribute holding the department code or name, empty to use the first OU of the user DN
+	GroupAttribute     string            `mapstructure:"groupattribute" json:"groupAttribute"`         // Default memberOf
+	RoleMappings       []LDAPRoleMapping `mapstructure:"rolemappings" json:"roleMappings"`             // Directory groups mapped to roles
+	DefaultRoles       []string          `mapstructure:"defaultroles" json:"defaultRoles"`             // Names of the roles assigned to users created from the directory
+}
+
+// Directory group to role mapping
+type LDAPRoleMapping struct {
+	Group string `mapstructure:"group" json:"group"` // Group DN or CN
+	Role  string `mapstructure:"role" json:"role"`   // Role name
+}
+
+// OpenID Connect single sign-on configuration.
+// The IdP redirects the browser to RedirectURL, the page there posts the code and state to /auth/oidc/callback.
+type OIDCConfig struct {
+	Enabled      bool     `mapstructure:"enabled" json:"enabled"`           // Enable OpenID Connect login
+	Issuer       string   `mapstructure:"issuer" json:"issuer"`             // Issuer URL, the discovery document is read from <issuer>/.well-known/openid-configuration
+	ClientID     string   `mapstructure:"clientid" json:"clientID"`         // Client ID registered at the IdP
+	ClientSecret string   `mapstructure:"clientsecret" json:"-"`            // Client secret, may be empty for public clients
+	RedirectURL  string   `mapstructure:"redirecturl" json:"redirectURL"`   // Redirect URL registered at the IdP
+	Scopes       []string `mapstructure:"scopes" json:"scopes"`             // Requested scopes, default openid, profile and email
+	MatchClaim   string   `mapstructure:"matchclaim" json:"matchClaim"`     // ID token claim used to find the user, default preferred_username
+	MatchField   string   `mapstructure:"matchfield" json:"matchField"`     // User field compared with the claim: code, email or mobile. Default code
+	ProviderName string   `mapstructure:"providername" json:"providerName"` // Name shown on the login button
+}
+
+// Password policy of the local users
+type PasswordPolicy struct {
+	MinLength     int  `mapstructure:"minlength" json:"minLength"`         // Minimum password length, default 8
+	RequireUpper  bool `mapstructure:"requireupper" json:"requireUpper"`   // Require an uppercase letter
+	RequireLower  bool `mapstructure:"requirelower" json:"requireLower"`   // Require a lowercase letter
+	RequireDigit  bool `mapstructure:"requiredigit" json:"requireDigit"`   // Require a digit
+	RequireSymbol bool `mapstructure:"requiresymbol" json:"requireSymbol"` // Require a symbol
+	HistoryCount  int  `mapstructure:"historycount" json:"historyCount"`   // Number of previous passwords that can't be reused, 0 to allow reuse
+	MaxAgeDays    int  `mapstructure:"maxagedays" json:"maxAgeDays"`       // Days after which the password must be changed, 0 for no expiry
+}
+
+// API rate limiting configuration.
+// Each IP address, and each signed-in user or API key, has a token bucket per route group, e.g. "file" or "report".
+// An authenticated request takes a token from both of its buckets.
+// Route groups not in Groups use the Default limit.
+type RateLimitConfig struct {
+	Enabled bool                 `mapstructure:"enabled" json:"enabled"` // Enable API rate limiting
+	Default RateLimit            `mapstructure:"default" json:"default"` // Limit of the route groups without their own limit
+	Groups  map[string]RateLimit `mapstructure:"groups" json:"groups"`   // Limits by route group name
+}
+
+// Token bucket limit
+type RateLimit struct {
+	Rate  float64 `mapstructure:"rate" json:"rate"`   // Requests allowed per second on average, 0 for no limit
+	Burst int32   `mapstructure:"burst" json:"burst"` // Requests allowed at once, default the rate rounded up
+}
+
 // Read global configuration
 func Init() (err error) {
 	viper.SetConfigFile("config.yaml")